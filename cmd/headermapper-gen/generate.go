@@ -0,0 +1,405 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/bhatti/grpc-header-mapper/headermapper"
+)
+
+// options controls the generated file
+type options struct {
+	// Package is the package of the generated file
+	Package string
+	// Prefix is prepended to the generated function names
+	Prefix string
+	// Source is the configuration file named in the generated header
+	Source string
+}
+
+// model is the data rendered by the template
+type model struct {
+	options
+	Overwrite     bool
+	CaseSensitive bool
+	SkipPaths     []string
+
+	// Sources lists the distinct incoming headers; Keys the distinct
+	// metadata keys they produce
+	Sources  []variable
+	Keys     []variable
+	Incoming []incomingMapping
+
+	// Matches and FoldMatches hold the HeaderMatcher cases for exact and
+	// lowercased names
+	Matches     []matchCase
+	FoldMatches []matchCase
+
+	// OutgoingKeys lists the distinct metadata keys read by outgoing mappings
+	OutgoingKeys    []variable
+	Outgoing        []outgoingMapping
+	OutgoingDefault bool
+
+	LastValue bool
+}
+
+// variable is a local variable of the generated code holding the value of
+// a header or metadata key
+type variable struct {
+	Var  string
+	Name string
+}
+
+type incomingMapping struct {
+	Header  string
+	Source  string
+	Key     string
+	KeyVar  string
+	Default string
+	// Guard keeps the value of an earlier mapping to the same key
+	Guard bool
+}
+
+type outgoingMapping struct {
+	Key     string
+	Source  string
+	Header  string
+	Default string
+}
+
+type matchCase struct {
+	Names []string
+	Key   string
+}
+
+// unsupported lists the configuration features that need runtime state and
+// cannot be generated
+func unsupported(config *headermapper.Config) []string {
+	var features []string
+	add := func(set bool, name string) {
+		if set {
+			features = append(features, name)
+		}
+	}
+	add(len(config.InternalNamespaces) > 0, "internal_namespaces")
+	add(len(config.TrustedProxies) > 0, "trusted_proxies")
+	add(len(config.BlockedHeaders) > 0, "blocked_headers")
+	add(config.StrictAllowList, "strict_allow_list")
+	add(config.StatusMetadataKey != "", "status_metadata_key")
+	add(config.DuplicateHeaders == headermapper.DuplicateHeaderReject, "duplicate_headers: reject")
+	add(config.OutgoingOrder == headermapper.HeaderOrderAlphabetical, "outgoing_order: alphabetical")
+	add(config.FIPSMode, "fips_mode")
+	add(config.StrictMode, "strict_mode")
+	add(config.Signature != nil, "signature")
+	add(config.SPIFFE != nil, "spiffe")
+	add(config.JWTClaims != nil, "jwt_claims")
+	add(config.BasicAuth != nil, "basic_auth")
+	add(len(config.Composites) > 0, "composites")
+	add(config.Authorization != nil, "authorization")
+	add(config.RateLimit != nil, "rate_limit")
+	add(config.IPFilter != nil, "ip_filter")
+	add(config.CSRF != nil, "csrf")
+	add(config.ReplayGuard != nil, "replay_guard")
+	add(config.CORS != nil, "cors")
+	add(config.MetadataLimit != nil, "metadata_limit")
+	add(config.Audit != nil, "audit")
+	add(config.Encryption != nil, "encryption")
+	add(config.PropagationSigning != nil, "propagation_signing")
+	add(config.SharedSecret != nil, "shared_secret")
+	add(config.TransformCache != nil, "transform_cache")
+	add(config.Idempotency != nil, "idempotency")
+	add(config.Timeout != nil, "timeout")
+	add(config.SSE != nil, "sse")
+	add(config.ETag != nil, "etag")
+	add(config.Experiment != nil, "experiment")
+	add(config.TraceContext != nil, "trace_context")
+	add(config.DebugEchoHeader, "debug_echo_header")
+	for _, mapping := range config.Mappings {
+		add(mapping.Transform != nil || mapping.CacheTransform, "transform of "+mapping.HTTPHeader)
+		add(mapping.Generator != nil, "generator of "+mapping.HTTPHeader)
+		add(len(mapping.Aliases) > 0, "aliases of "+mapping.HTTPHeader)
+		add(mapping.AppendValues, "append_values of "+mapping.HTTPHeader)
+		add(strings.HasSuffix(strings.ToLower(mapping.GRPCMetadata), "-bin"), "binary key "+mapping.GRPCMetadata)
+		add(mapping.Source != "" && mapping.Source != headermapper.SourceHeader, "source of "+mapping.HTTPHeader)
+		add(mapping.HTTPHeaderPattern != "", "http_header_pattern "+mapping.HTTPHeaderPattern)
+		add(mapping.QueryParam != "", "query_param "+mapping.QueryParam)
+		add(mapping.PathParam != "", "path_param "+mapping.PathParam)
+		add(strings.HasSuffix(mapping.HTTPHeader, "*"), "wildcard "+mapping.HTTPHeader)
+	}
+	return features
+}
+
+// generate renders the Go source implementing config
+func generate(config *headermapper.Config, opts options) ([]byte, error) {
+	if err := headermapper.ValidateConfig(config); err != nil {
+		return nil, err
+	}
+	if features := unsupported(config); len(features) > 0 {
+		return nil, fmt.Errorf("unsupported configuration: %s", strings.Join(features, ", "))
+	}
+
+	opts.Source = filepath.Base(opts.Source)
+	m := &model{
+		options:       opts,
+		Overwrite:     config.OverwriteExisting,
+		CaseSensitive: config.CaseSensitive,
+		SkipPaths:     config.SkipPaths,
+		LastValue:     config.DuplicateHeaders == headermapper.DuplicateHeaderLast,
+	}
+
+	sources := make(map[string]string)
+	keys := make(map[string]string)
+	outgoingKeys := make(map[string]string)
+	matcher := make(map[string]string)
+	var matcherOrder []string
+
+	for _, mapping := range config.Mappings {
+		header := http.CanonicalHeaderKey(mapping.HTTPHeader)
+		key := strings.ToLower(mapping.GRPCMetadata)
+
+		if mapping.Direction != headermapper.Outgoing {
+			src, ok := sources[header]
+			if !ok {
+				src = fmt.Sprintf("h%d", len(m.Sources))
+				sources[header] = src
+				m.Sources = append(m.Sources, variable{Var: src, Name: header})
+			}
+			keyVar, shared := keys[key]
+			if !shared {
+				keyVar = fmt.Sprintf("k%d", len(m.Keys))
+				keys[key] = keyVar
+				m.Keys = append(m.Keys, variable{Var: keyVar, Name: key})
+			}
+			m.Incoming = append(m.Incoming, incomingMapping{
+				Header:  header,
+				Source:  src,
+				Key:     key,
+				KeyVar:  keyVar,
+				Default: mapping.DefaultValue,
+				Guard:   shared && !config.OverwriteExisting,
+			})
+
+			name := mapping.HTTPHeader
+			if !config.CaseSensitive {
+				name = strings.ToLower(name)
+			}
+			if _, ok := matcher[name]; !ok {
+				matcherOrder = append(matcherOrder, name)
+			}
+			matcher[name] = mapping.GRPCMetadata
+		}
+
+		if mapping.Direction != headermapper.Incoming {
+			src, ok := outgoingKeys[key]
+			if !ok {
+				src = fmt.Sprintf("o%d", len(m.OutgoingKeys))
+				outgoingKeys[key] = src
+				m.OutgoingKeys = append(m.OutgoingKeys, variable{Var: src, Name: key})
+			}
+			m.OutgoingDefault = m.OutgoingDefault || mapping.DefaultValue != ""
+			m.Outgoing = append(m.Outgoing, outgoingMapping{
+				Key:     key,
+				Source:  src,
+				Header:  header,
+				Default: mapping.DefaultValue,
+			})
+		}
+	}
+
+	// Unless matching is case sensitive, canonical and lowercase names match
+	// directly and other spellings after lowercasing, as in HeaderMatcher
+	for _, name := range matcherOrder {
+		names := []string{name}
+		if !config.CaseSensitive {
+			if canonical := http.CanonicalHeaderKey(name); canonical != name {
+				names = append(names, canonical)
+			}
+			m.FoldMatches = append(m.FoldMatches, matchCase{Names: []string{name}, Key: matcher[name]})
+		}
+		m.Matches = append(m.Matches, matchCase{Names: names, Key: matcher[name]})
+	}
+
+	var buf bytes.Buffer
+	if err := generatedFile.Execute(&buf, m); err != nil {
+		return nil, err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w", err)
+	}
+	return src, nil
+}
+
+// headerWrite is the data of the "set" template writing a response header
+type headerWrite struct {
+	Overwrite bool
+	Header    string
+	Value     string
+}
+
+var generatedFile = template.Must(template.New("file").Funcs(template.FuncMap{
+	"write": func(overwrite bool, header, value string) headerWrite {
+		return headerWrite{Overwrite: overwrite, Header: header, Value: value}
+	},
+	"next": func(i int) int { return i + 1 },
+}).Parse(`// Code generated by headermapper-gen from {{.Source}}; DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// {{.Prefix}}MetadataAnnotator maps incoming HTTP headers to gRPC metadata.
+// Missing required headers are skipped without logging.
+func {{.Prefix}}MetadataAnnotator(ctx context.Context, req *http.Request) metadata.MD {
+	{{- if .SkipPaths}}
+	switch req.URL.Path {
+	case {{range $i, $p := .SkipPaths}}{{if $i}}, {{end}}{{printf "%q" $p}}{{end}}:
+		return nil
+	}
+	{{- end}}
+	{{- if .Incoming}}
+
+	var {{range $i, $s := .Sources}}{{if $i}}, {{end}}{{$s.Var}}{{end}} string
+	for name, values := range req.Header {
+		if len(values) == 0 {
+			continue
+		}
+		switch name {
+		{{- range .Sources}}
+		case {{printf "%q" .Name}}:
+			{{.Var}} = values[{{if $.LastValue}}len(values)-1{{else}}0{{end}}]
+		{{- end}}
+		}
+	}
+
+	var {{range $i, $k := .Keys}}{{if $i}}, {{end}}{{$k.Var}}{{end}} string
+	{{- range .Incoming}}
+
+	// {{.Header}} -> {{.Key}}
+	{{- if .Guard}}
+	if {{.KeyVar}} == "" {
+	{{- end}}
+	if {{.Source}} != "" {
+		{{.KeyVar}} = {{.Source}}
+	}{{if .Default}} else {
+		{{.KeyVar}} = {{printf "%q" .Default}}
+	}{{end}}
+	{{- if .Guard}}
+	}
+	{{- end}}
+	{{- end}}
+
+	backing := [...]string{ {{- range $i, $k := .Keys}}{{if $i}}, {{end}}{{$k.Var}}{{end -}} }
+	n := 0
+	for _, v := range backing {
+		if v != "" {
+			n++
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	md := make(metadata.MD, n)
+	{{- range $i, $k := .Keys}}
+	if {{$k.Var}} != "" {
+		md[{{printf "%q" $k.Name}}] = backing[{{$i}}:{{next $i}}:{{next $i}}]
+	}
+	{{- end}}
+	return md
+	{{- else}}
+	return nil
+	{{- end}}
+}
+
+// {{.Prefix}}HeaderMatcher selects the HTTP headers forwarded as gRPC metadata
+func {{.Prefix}}HeaderMatcher(key string) (string, bool) {
+	{{- if .Matches}}
+	switch key {
+	{{- range .Matches}}
+	case {{range $i, $n := .Names}}{{if $i}}, {{end}}{{printf "%q" $n}}{{end}}:
+		return {{printf "%q" .Key}}, true
+	{{- end}}
+	}
+	{{- end}}
+	{{- if .FoldMatches}}
+	switch strings.ToLower(key) {
+	{{- range .FoldMatches}}
+	case {{range $i, $n := .Names}}{{if $i}}, {{end}}{{printf "%q" $n}}{{end}}:
+		return {{printf "%q" .Key}}, true
+	{{- end}}
+	}
+	{{- end}}
+
+	if grpcKey, ok := runtime.DefaultHeaderMatcher(key); ok && grpcKey != "" {
+		return grpcKey, true
+	}
+	return "grpc-metadata-" + strings.ToLower(strings.ReplaceAll(key, "_", "-")), true
+}
+
+// {{.Prefix}}ResponseModifier maps outgoing gRPC metadata to HTTP response headers
+func {{.Prefix}}ResponseModifier(ctx context.Context, w http.ResponseWriter, msg proto.Message) error {
+	{{- if .Outgoing}}
+	md, ok := runtime.ServerMetadataFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	var {{range $i, $k := .OutgoingKeys}}{{if $i}}, {{end}}{{$k.Var}}{{end}} string
+	var {{range $i, $k := .OutgoingKeys}}{{if $i}}, {{end}}{{$k.Var}}ok{{end}} bool
+	for key, values := range md.HeaderMD {
+		if len(values) == 0 {
+			continue
+		}
+		switch key {
+		{{- range .OutgoingKeys}}
+		case {{printf "%q" .Name}}:
+			{{.Var}}, {{.Var}}ok = values[0], true
+		{{- end}}
+		}
+	}
+
+	h := w.Header()
+	{{- if .OutgoingDefault}}
+	var value string
+	{{- end}}
+	{{- range .Outgoing}}
+
+	// {{.Key}} -> {{.Header}}
+	{{- if .Default}}
+	value = {{.Source}}
+	if !{{.Source}}ok {
+		value = {{printf "%q" .Default}}
+	}
+	{{- template "set" write $.Overwrite .Header "value"}}
+	{{- else}}
+	if {{.Source}}ok {
+		{{- template "set" write $.Overwrite .Header .Source}}
+	}
+	{{- end}}
+	{{- end}}
+	{{- end}}
+	return nil
+}
+{{- define "set"}}
+	{{- if .Overwrite}}
+	h[{{printf "%q" .Header}}] = []string{ {{- .Value -}} }
+	{{- else}}
+	if existing := h[{{printf "%q" .Header}}]; len(existing) == 0 || existing[0] == "" {
+		h[{{printf "%q" .Header}}] = []string{ {{- .Value -}} }
+	}
+	{{- end}}
+{{- end}}
+`))
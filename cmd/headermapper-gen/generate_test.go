@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/bhatti/grpc-header-mapper/headermapper"
+)
+
+// TestGenerate_Example fails when the checked-in example is stale; run
+// go generate ./cmd/headermapper-gen/... to update it
+func TestGenerate_Example(t *testing.T) {
+	config, err := headermapper.LoadConfigFromFile("internal/example/headers.yaml")
+	if err != nil {
+		t.Fatalf("LoadConfigFromFile() error = %v", err)
+	}
+	got, err := generate(config, options{Package: "example", Source: "headers.yaml"})
+	if err != nil {
+		t.Fatalf("generate() error = %v", err)
+	}
+	want, err := os.ReadFile("internal/example/headers_gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("internal/example/headers_gen.go is stale")
+	}
+}
+
+func TestGenerate_Variants(t *testing.T) {
+	mappings := []headermapper.HeaderMapping{
+		{HTTPHeader: "X-User-ID", GRPCMetadata: "user-id", Direction: headermapper.Incoming},
+		{HTTPHeader: "X-Request-ID", GRPCMetadata: "request-id", Direction: headermapper.Bidirectional, DefaultValue: "none"},
+	}
+
+	tests := []struct {
+		name     string
+		config   *headermapper.Config
+		opts     options
+		contains []string
+	}{
+		{
+			name:     "prefix",
+			config:   &headermapper.Config{Mappings: mappings},
+			opts:     options{Package: "gateway", Prefix: "Tenant"},
+			contains: []string{"package gateway", "func TenantMetadataAnnotator(", "func TenantHeaderMatcher(", "func TenantResponseModifier("},
+		},
+		{
+			name:     "case sensitive",
+			config:   &headermapper.Config{Mappings: mappings, CaseSensitive: true},
+			opts:     options{Package: "gateway"},
+			contains: []string{`case "X-User-ID":`},
+		},
+		{
+			name:     "overwrite",
+			config:   &headermapper.Config{Mappings: mappings, OverwriteExisting: true},
+			opts:     options{Package: "gateway"},
+			contains: []string{`h["X-Request-Id"] = []string{value}`},
+		},
+		{
+			name:     "last value",
+			config:   &headermapper.Config{Mappings: mappings, DuplicateHeaders: headermapper.DuplicateHeaderLast},
+			opts:     options{Package: "gateway"},
+			contains: []string{"values[len(values)-1]"},
+		},
+		{
+			name:     "no mappings",
+			config:   &headermapper.Config{},
+			opts:     options{Package: "gateway"},
+			contains: []string{"func MetadataAnnotator("},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := generate(tt.config, tt.opts)
+			if err != nil {
+				t.Fatalf("generate() error = %v", err)
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(string(src), s) {
+					t.Errorf("generated code does not contain %q:\n%s", s, src)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerate_Unsupported(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *headermapper.Config
+		want   string
+	}{
+		{"trusted proxies", &headermapper.Config{TrustedProxies: []string{"10.0.0.0/8"}}, "trusted_proxies"},
+		{"duplicate reject", &headermapper.Config{DuplicateHeaders: headermapper.DuplicateHeaderReject}, "duplicate_headers"},
+		{"security policy", &headermapper.Config{SharedSecret: &headermapper.SharedSecretConfig{Header: "X-Token", Secrets: []string{"s"}}}, "shared_secret"},
+		{"transform", &headermapper.Config{Mappings: []headermapper.HeaderMapping{
+			{HTTPHeader: "X-User-ID", GRPCMetadata: "user-id", Transform: headermapper.ToLower},
+		}}, "transform of X-User-ID"},
+		{"invalid", &headermapper.Config{Mappings: []headermapper.HeaderMapping{{HTTPHeader: "X User", GRPCMetadata: "user"}}}, "invalid HTTP header name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := generate(tt.config, options{Package: "gateway"})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("generate() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
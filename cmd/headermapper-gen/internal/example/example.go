@@ -0,0 +1,5 @@
+// Package example holds code generated by headermapper-gen from
+// headers.yaml, tested against a HeaderMapper built from the same file
+package example
+
+//go:generate go run github.com/bhatti/grpc-header-mapper/cmd/headermapper-gen -config headers.yaml -output headers_gen.go
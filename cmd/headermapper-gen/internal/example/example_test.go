@@ -0,0 +1,114 @@
+package example
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/bhatti/grpc-header-mapper/headermapper"
+)
+
+func loadMapper(t *testing.T) *headermapper.HeaderMapper {
+	t.Helper()
+	config, err := headermapper.LoadConfigFromFile("headers.yaml")
+	if err != nil {
+		t.Fatalf("LoadConfigFromFile() error = %v", err)
+	}
+	return headermapper.NewHeaderMapper(config)
+}
+
+func TestMetadataAnnotator_MatchesHeaderMapper(t *testing.T) {
+	annotator := loadMapper(t).MetadataAnnotator()
+
+	tests := []struct {
+		name    string
+		path    string
+		headers http.Header
+	}{
+		{"no headers", "/api", nil},
+		{"user", "/api", http.Header{"X-User-Id": {"u1"}}},
+		{"legacy user only", "/api", http.Header{"X-Legacy-User": {"legacy"}}},
+		{"user and legacy user", "/api", http.Header{"X-User-Id": {"u1"}, "X-Legacy-User": {"legacy"}}},
+		{"tenant and request", "/api", http.Header{"X-Tenant-Id": {"acme"}, "X-Request-Id": {"r1", "r2"}}},
+		{"skipped path", "/health", http.Header{"X-User-Id": {"u1"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			for name, values := range tt.headers {
+				req.Header[name] = values
+			}
+
+			want := annotator(context.Background(), req)
+			got := MetadataAnnotator(context.Background(), req)
+			if len(got) != len(want) || (len(want) > 0 && !reflect.DeepEqual(got, want)) {
+				t.Errorf("MetadataAnnotator() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestHeaderMatcher_MatchesHeaderMapper(t *testing.T) {
+	matcher := loadMapper(t).HeaderMatcher()
+
+	for _, key := range []string{"X-User-Id", "x-user-id", "X-USER-ID", "X-Legacy-User", "Accept", "Grpc-Metadata-Foo", "X-Custom_Header"} {
+		wantKey, wantOK := matcher(key)
+		gotKey, gotOK := HeaderMatcher(key)
+		if gotKey != wantKey || gotOK != wantOK {
+			t.Errorf("HeaderMatcher(%s) = %q, %v, want %q, %v", key, gotKey, gotOK, wantKey, wantOK)
+		}
+	}
+}
+
+func TestResponseModifier_MatchesHeaderMapper(t *testing.T) {
+	modifier := loadMapper(t).ResponseModifier()
+
+	tests := []struct {
+		name     string
+		md       metadata.MD
+		existing http.Header
+	}{
+		{"empty", metadata.MD{}, nil},
+		{"all keys", metadata.Pairs("request-id", "r1", "response-time", "12ms", "server-version", "v2"), nil},
+		{"existing header kept", metadata.Pairs("request-id", "r1"), http.Header{"X-Request-Id": {"gateway"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{HeaderMD: tt.md})
+
+			want, got := httptest.NewRecorder(), httptest.NewRecorder()
+			for name, values := range tt.existing {
+				want.Header()[name] = values
+				got.Header()[name] = values
+			}
+			if err := modifier(ctx, want, nil); err != nil {
+				t.Fatalf("HeaderMapper ResponseModifier() error = %v", err)
+			}
+			if err := ResponseModifier(ctx, got, nil); err != nil {
+				t.Fatalf("ResponseModifier() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.Header(), want.Header()) {
+				t.Errorf("headers = %v, want %v", got.Header(), want.Header())
+			}
+		})
+	}
+}
+
+func BenchmarkGeneratedMetadataAnnotator(b *testing.B) {
+	req := httptest.NewRequest("GET", "/api", nil)
+	req.Header.Set("X-User-ID", "u1")
+	req.Header.Set("X-Request-ID", "r1")
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = MetadataAnnotator(ctx, req)
+	}
+}
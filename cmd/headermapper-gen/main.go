@@ -0,0 +1,51 @@
+// Command headermapper-gen turns a header mapper YAML or JSON configuration
+// into Go code implementing the metadata annotator, header matcher and
+// response modifier as straight-line code, for deployments where the
+// configuration is fixed at build time. Use it with go:generate:
+//
+//	//go:generate go run github.com/bhatti/grpc-header-mapper/cmd/headermapper-gen -config headers.yaml
+//
+// The generated functions behave like those of a HeaderMapper built from
+// the same configuration. Configurations using features that need runtime
+// state, such as security policies or trusted proxies, are rejected.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/bhatti/grpc-header-mapper/headermapper"
+)
+
+func main() {
+	configFile := flag.String("config", "", "configuration file (YAML or JSON)")
+	pkg := flag.String("package", os.Getenv("GOPACKAGE"), "package of the generated file (default $GOPACKAGE)")
+	output := flag.String("output", "headermapper_gen.go", "generated file")
+	prefix := flag.String("prefix", "", "prefix of the generated function names")
+	flag.Parse()
+
+	if err := run(*configFile, *pkg, *output, *prefix); err != nil {
+		fmt.Fprintln(os.Stderr, "headermapper-gen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(configFile, pkg, output, prefix string) error {
+	if configFile == "" {
+		return fmt.Errorf("-config is required")
+	}
+	if pkg == "" {
+		return fmt.Errorf("-package is required outside go generate")
+	}
+
+	config, err := headermapper.LoadConfigFromFile(configFile)
+	if err != nil {
+		return err
+	}
+	src, err := generate(config, options{Package: pkg, Prefix: prefix, Source: configFile})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(output, src, 0o644)
+}
@@ -0,0 +1,45 @@
+// Command headermapper provides tools for working with header mapper
+// configurations. The try subcommand runs a value through a configured
+// mapping and prints each intermediate value, for debugging transforms
+// without deploying:
+//
+//	headermapper try -config headers.yaml -mapping Authorization -value "Bearer abc" \
+//		-pipeline 'trim | remove_prefix("Bearer ")'
+//
+// Without -value, values are read from standard input one per line.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+const usage = `usage: headermapper <command> [flags]
+
+commands:
+  try    run a value through a configured mapping and print each step`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "try":
+		err = try(os.Args[2:], os.Stdin, os.Stdout)
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "headermapper:", err)
+		os.Exit(1)
+	}
+}
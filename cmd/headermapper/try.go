@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bhatti/grpc-header-mapper/headermapper"
+)
+
+// try implements the try subcommand
+func try(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("try", flag.ContinueOnError)
+	configFile := flags.String("config", "", "configuration file (YAML or JSON)")
+	name := flags.String("mapping", "", "HTTP header, alias or metadata key of the mapping")
+	value := flags.String("value", "", "value to map; without it values are read from standard input")
+	spec := flags.String("pipeline", "", `transform stages, e.g. 'trim | remove_prefix("Bearer ")'`)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	valueSet := false
+	flags.Visit(func(f *flag.Flag) {
+		valueSet = valueSet || f.Name == "value"
+	})
+
+	if *configFile == "" || *name == "" {
+		return fmt.Errorf("-config and -mapping are required")
+	}
+	config, err := headermapper.LoadConfigFromFile(*configFile)
+	if err != nil {
+		return err
+	}
+	mappings := findMappings(config, *name)
+	if len(mappings) == 0 {
+		return fmt.Errorf("no mapping for %s in %s", *name, *configFile)
+	}
+	// Transforms are code and cannot be loaded from configuration files, so
+	// the pipeline under test is given on the command line
+	pipeline, err := headermapper.ParsePipeline("", *spec)
+	if err != nil {
+		return err
+	}
+
+	if valueSet {
+		return traceMappings(stdout, mappings, pipeline, *value)
+	}
+
+	scanner := bufio.NewScanner(stdin)
+	fmt.Fprint(stdout, "> ")
+	for scanner.Scan() {
+		if err := traceMappings(stdout, mappings, pipeline, scanner.Text()); err != nil {
+			return err
+		}
+		fmt.Fprint(stdout, "> ")
+	}
+	fmt.Fprintln(stdout)
+	return scanner.Err()
+}
+
+// findMappings returns the mappings reading or writing name, matched
+// against HTTP headers and aliases ignoring case, and metadata keys
+func findMappings(config *headermapper.Config, name string) []headermapper.HeaderMapping {
+	var found []headermapper.HeaderMapping
+	header := http.CanonicalHeaderKey(name)
+	for _, mapping := range config.Mappings {
+		match := http.CanonicalHeaderKey(mapping.HTTPHeader) == header ||
+			strings.EqualFold(mapping.GRPCMetadata, name)
+		for _, alias := range mapping.Aliases {
+			match = match || http.CanonicalHeaderKey(alias) == header
+		}
+		if match {
+			found = append(found, mapping)
+		}
+	}
+	return found
+}
+
+// traceMappings prints the steps mapping value through each mapping
+func traceMappings(w io.Writer, mappings []headermapper.HeaderMapping, pipeline headermapper.Pipeline, value string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, mapping := range mappings {
+		source, target := mapping.HTTPHeader, mapping.GRPCMetadata
+		if mapping.Direction == headermapper.Outgoing {
+			source, target = target, source
+		}
+		fmt.Fprintf(tw, "%s -> %s (%s)\n", source, target, mapping.Direction)
+		fmt.Fprintf(tw, "  input\t%s\n", strconv.Quote(value))
+
+		result := value
+		if result == "" && mapping.DefaultValue != "" {
+			result = mapping.DefaultValue
+			fmt.Fprintf(tw, "  default\t%s\n", strconv.Quote(result))
+		}
+		if result == "" {
+			if mapping.Required {
+				fmt.Fprintf(tw, "  result\tmissing required value\n")
+			} else {
+				fmt.Fprintf(tw, "  result\tskipped\n")
+			}
+			continue
+		}
+
+		for _, step := range pipeline.Trace(result) {
+			if step.Rejected {
+				fmt.Fprintf(tw, "  %s\trejected\n", step.Stage)
+				result = ""
+				break
+			}
+			fmt.Fprintf(tw, "  %s\t%s\n", step.Stage, strconv.Quote(step.Value))
+			result = step.Value
+		}
+		if result == "" {
+			fmt.Fprintf(tw, "  result\tskipped\n")
+			continue
+		}
+		fmt.Fprintf(tw, "  result\t%s = %s\n", target, strconv.Quote(result))
+	}
+	return tw.Flush()
+}
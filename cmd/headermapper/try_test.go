@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const tryConfig = `mappings:
+  - http_header: "Authorization"
+    grpc_metadata: "authorization"
+    direction: incoming
+    aliases: ["X-Auth"]
+  - http_header: "X-Tenant-ID"
+    grpc_metadata: "tenant-id"
+    direction: incoming
+    default_value: "public"
+  - http_header: "X-User-ID"
+    grpc_metadata: "user-id"
+    direction: incoming
+    required: true
+`
+
+func TestTry(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "headers.yaml")
+	if err := os.WriteFile(configFile, []byte(tryConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		stdin    string
+		contains []string
+		wantErr  bool
+	}{
+		{
+			name: "pipeline",
+			args: []string{"-mapping", "authorization", "-value", " Bearer ABC", "-pipeline", `trim | remove_prefix("Bearer ") | lower`},
+			contains: []string{
+				"Authorization -> authorization (incoming)",
+				`trim                      "Bearer ABC"`,
+				`remove_prefix("Bearer ")  "ABC"`,
+				`result                    authorization = "abc"`,
+			},
+		},
+		{
+			name:     "alias",
+			args:     []string{"-mapping", "x-auth", "-value", "token"},
+			contains: []string{`result  authorization = "token"`},
+		},
+		{
+			name:     "default",
+			args:     []string{"-mapping", "X-Tenant-ID", "-value", ""},
+			contains: []string{`default  "public"`, `result   tenant-id = "public"`},
+		},
+		{
+			name:     "required",
+			args:     []string{"-mapping", "user-id", "-value", ""},
+			contains: []string{"result  missing required value"},
+		},
+		{
+			name:     "repl",
+			args:     []string{"-mapping", "X-User-ID", "-pipeline", "upper"},
+			stdin:    "alice\nbob\n",
+			contains: []string{`user-id = "ALICE"`, `user-id = "BOB"`},
+		},
+		{
+			name:    "unknown mapping",
+			args:    []string{"-mapping", "X-Other", "-value", "x"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid pipeline",
+			args:    []string{"-mapping", "X-User-ID", "-value", "x", "-pipeline", "reverse"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			args := append([]string{"-config", configFile}, tt.args...)
+			err := try(args, strings.NewReader(tt.stdin), &stdout)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("try() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(stdout.String(), want) {
+					t.Errorf("output missing %q:\n%s", want, stdout.String())
+				}
+			}
+		})
+	}
+}
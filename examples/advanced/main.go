@@ -275,10 +275,13 @@ func createDefaultAdvancedMapper() *headermapper.HeaderMapper {
 		WithTransform(timestampGenerator).
 
 		// Security headers
-		AddOutgoingMapping("security-policy", "X-Content-Security-Policy").
-		WithDefault("default-src 'self'").
-		AddOutgoingMapping("frame-options", "X-Frame-Options").
-		WithDefault("DENY").
+		AddMappings(headermapper.NewSecurityPolicy().
+			ContentSecurityPolicy("default-src", "'self'").
+			StrictTransportSecurity(365*24*time.Hour, true, false).
+			FrameOptions("DENY").
+			ReferrerPolicy("strict-origin-when-cross-origin").
+			NoSniff().
+			Mappings()...).
 
 		// Custom business headers
 		AddBidirectionalMapping("X-Tenant-ID", "tenant-id").
@@ -303,9 +306,8 @@ func createAdvancedInterceptor(server *AdvancedServer) grpc.UnaryServerIntercept
 		startTime := time.Now()
 
 		// Add request context information
-		md, _ := metadata.FromIncomingContext(ctx)
-		requestID := getFirstValue(md, "request-id")
-		userID := getFirstValue(md, "user-id")
+		requestID, _ := headermapper.Get(ctx, "request-id")
+		userID, _ := headermapper.Get(ctx, "user-id")
 
 		server.logger.Info("Processing request", info.FullMethod, "for user", userID, "with request-id", requestID)
 
@@ -325,14 +327,6 @@ func createAdvancedInterceptor(server *AdvancedServer) grpc.UnaryServerIntercept
 	}
 }
 
-func getFirstValue(md metadata.MD, key string) string {
-	values := md.Get(key)
-	if len(values) > 0 {
-		return values[0]
-	}
-	return ""
-}
-
 func min(a, b int) int {
 	if a < b {
 		return a
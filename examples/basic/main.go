@@ -148,17 +148,7 @@ func createBasicMapper() *headermapper.HeaderMapper {
 	// Set custom logger
 	mapper.SetLogger(logger)
 
-	logger.Info("📋 Header Mapping Configuration:")
-	logger.Info("  Incoming: Authorization → authorization")
-	logger.Info("  Incoming: X-API-Key → api-key")
-	logger.Info("  Incoming: X-User-ID → user-id")
-	logger.Info("  Incoming: Authorization → auth-token (with Bearer extraction)")
-	logger.Info("  Bidirectional: X-Request-ID ↔ request-id")
-	logger.Info("  Bidirectional: X-Correlation-ID ↔ correlation-id")
-	logger.Info("  Bidirectional: X-Trace-ID ↔ trace-id")
-	logger.Info("  Outgoing: processing-time-ms → X-Processing-Time")
-	logger.Info("  Outgoing: server-version → X-Server-Version")
-	logger.Info("  Outgoing: rate-limit-remaining → X-RateLimit-Remaining")
+	logger.Info("📋 Header Mapping Configuration:\n" + mapper.DescribeMappings())
 
 	return mapper
 }
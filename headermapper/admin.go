@@ -0,0 +1,262 @@
+package headermapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxAdminConfigSize bounds the configuration documents AdminHandler accepts
+const maxAdminConfigSize = 1 << 20
+
+// redacted replaces secrets in configurations served by AdminHandler
+const redacted = "REDACTED"
+
+// AdminHandler returns a handler for inspecting and changing a running
+// mapper, meant for staging and internal ports as it does not authenticate
+// callers. Mount it with http.StripPrefix:
+//
+//	GET  /config    enforced configuration as JSON, or YAML with ?format=yaml
+//	PUT  /config    apply the mappings and mapping options of a YAML or JSON
+//	                configuration, which must keep the policies as served
+//	GET  /mappings  table of the active mappings
+//	GET  /stats     statistics as JSON
+//	POST /debug     set debug logging with ?enabled=true|false, or toggle it
+//
+// Secrets of signature, propagation signing and shared secret policies and
+// the Basic-Auth hash key are redacted from the served configuration.
+func (hm *HeaderMapper) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /config", hm.adminGetConfig)
+	mux.HandleFunc("PUT /config", hm.adminPutConfig)
+	mux.HandleFunc("GET /mappings", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, hm.DescribeMappings())
+	})
+	mux.HandleFunc("GET /stats", func(w http.ResponseWriter, req *http.Request) {
+		writeAdminJSON(w, hm.GetStats())
+	})
+	mux.HandleFunc("POST /debug", hm.adminDebug)
+	return mux
+}
+
+func (hm *HeaderMapper) adminGetConfig(w http.ResponseWriter, req *http.Request) {
+	config := redactConfig(hm.state().config)
+	switch format := req.URL.Query().Get("format"); format {
+	case "", "json":
+		writeAdminJSON(w, config)
+	case "yaml", "yml":
+		data, err := yaml.Marshal(config)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Write(data)
+	default:
+		http.Error(w, fmt.Sprintf("unsupported format: %s", format), http.StatusBadRequest)
+	}
+}
+
+func (hm *HeaderMapper) adminPutConfig(w http.ResponseWriter, req *http.Request) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxAdminConfigSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	config, err := parseConfig(data)
+	if err == nil {
+		err = hm.modifyConfig(func(active *Config) error { return applyAdminConfig(active, config) })
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	hm.log().Info("Applied config from admin endpoint")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// applyAdminConfig copies the mappings and mapping options of config to
+// active. Policies are served redacted and without their stores or
+// verifiers, so config must repeat them as served and the ones in effect are
+// kept. Transforms and generators set in code cannot be written in config
+// either; they are kept for the mappings config repeats.
+func applyAdminConfig(active, config *Config) error {
+	return mergeConfig(active, redactConfig(active), config)
+}
+
+// mergeConfig copies the mappings and mapping options of config, written as
+// a configuration file, to active, keeping the transforms and generators set
+// in code. The policies of config must equal those of served, active as the
+// writer of config saw it.
+func mergeConfig(active, served, config *Config) error {
+	changed, err := policyChanges(served, config)
+	if err != nil {
+		return err
+	}
+	if len(changed) > 0 {
+		return fmt.Errorf("cannot change %s of a running mapper", strings.Join(changed, ", "))
+	}
+
+	for _, mapping := range active.Mappings {
+		if mapping.Generator == nil && (mapping.Transform == nil || len(mapping.TransformNames) > 0) {
+			continue
+		}
+		i := slices.IndexFunc(config.Mappings, func(m HeaderMapping) bool { return sameMappingTarget(m, mapping) })
+		if i < 0 {
+			return fmt.Errorf("mapping %s -> %s has a transform or generator set in code and cannot be removed here", mapping.HTTPHeader, mapping.GRPCMetadata)
+		}
+		if len(config.Mappings[i].TransformNames) == 0 {
+			config.Mappings[i].Transform = mapping.Transform
+		}
+		config.Mappings[i].Generator = mapping.Generator
+	}
+
+	from, to := reflect.ValueOf(config).Elem(), reflect.ValueOf(active).Elem()
+	for i := 0; i < to.NumField(); i++ {
+		if reloadableFields[to.Type().Field(i).Name] {
+			to.Field(i).Set(from.Field(i))
+		}
+	}
+	return nil
+}
+
+// policyNames returns the configuration file names of the policy sections
+func policyNames() []string {
+	var names []string
+	fields := reflect.TypeOf(Config{})
+	for i := 0; i < fields.NumField(); i++ {
+		if !reloadableFields[fields.Field(i).Name] {
+			names = append(names, configFieldName(fields.Field(i)))
+		}
+	}
+	return names
+}
+
+// policySections returns the policy sections of config as decoded JSON,
+// without empty values, so documents written as YAML or JSON and omitting
+// empty lists compare equal
+func policySections(config *Config) (map[string]interface{}, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	var sections map[string]interface{}
+	if err := json.Unmarshal(data, &sections); err != nil {
+		return nil, err
+	}
+	for name, value := range sections {
+		sections[name] = pruneEmpty(value)
+	}
+	return sections, nil
+}
+
+// pruneEmpty removes nulls, empty lists and empty objects from decoded JSON,
+// returning nil when nothing remains
+func pruneEmpty(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if v[key] = pruneEmpty(item); v[key] == nil {
+				delete(v, key)
+			}
+		}
+		if len(v) == 0 {
+			return nil
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = pruneEmpty(item)
+		}
+		if len(v) == 0 {
+			return nil
+		}
+	}
+	return value
+}
+
+// sameMappingTarget reports whether two mappings map the same source to the
+// same metadata key in the same direction
+func sameMappingTarget(a, b HeaderMapping) bool {
+	return http.CanonicalHeaderKey(a.HTTPHeader) == http.CanonicalHeaderKey(b.HTTPHeader) &&
+		a.HTTPHeaderPattern == b.HTTPHeaderPattern &&
+		a.QueryParam == b.QueryParam &&
+		a.PathParam == b.PathParam &&
+		strings.EqualFold(a.GRPCMetadata, b.GRPCMetadata) &&
+		a.Direction == b.Direction
+}
+
+func (hm *HeaderMapper) adminDebug(w http.ResponseWriter, req *http.Request) {
+	var enabled bool
+	err := hm.modifyConfig(func(config *Config) error {
+		enabled = !config.Debug
+		if value := req.URL.Query().Get("enabled"); value != "" {
+			var err error
+			if enabled, err = strconv.ParseBool(value); err != nil {
+				return fmt.Errorf("invalid enabled value: %q", value)
+			}
+		}
+		config.Debug = enabled
+		return nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeAdminJSON(w, map[string]bool{"debug": enabled})
+}
+
+func writeAdminJSON(w http.ResponseWriter, v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// redactConfig returns a copy of config without the secrets of its policies
+func redactConfig(config *Config) *Config {
+	clone := cloneConfig(config)
+	if sc := clone.Signature; sc != nil && len(sc.Keys) > 0 {
+		copied := *sc
+		copied.Keys = redactValues(sc.Keys)
+		clone.Signature = &copied
+	}
+	if pc := clone.PropagationSigning; pc != nil && len(pc.Secrets) > 0 {
+		copied := *pc
+		copied.Secrets = redactValues(pc.Secrets)
+		clone.PropagationSigning = &copied
+	}
+	if sc := clone.SharedSecret; sc != nil && len(sc.Secrets) > 0 {
+		copied := *sc
+		copied.Secrets = make([]string, len(sc.Secrets))
+		for i := range copied.Secrets {
+			copied.Secrets[i] = redacted
+		}
+		clone.SharedSecret = &copied
+	}
+	if bc := clone.BasicAuth; bc != nil && bc.HashKey != "" {
+		copied := *bc
+		copied.HashKey = redacted
+		clone.BasicAuth = &copied
+	}
+	return clone
+}
+
+// redactValues keeps the key IDs of a key map and hides the keys
+func redactValues(keys map[string]string) map[string]string {
+	hidden := make(map[string]string, len(keys))
+	for id := range keys {
+		hidden[id] = redacted
+	}
+	return hidden
+}
@@ -0,0 +1,150 @@
+package headermapper
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAdminHandler(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").
+		RequireSharedSecret(&SharedSecretConfig{Header: "X-Gateway-Secret", Secrets: []string{"s3cret"}}).
+		MapBasicAuth(&BasicAuthConfig{PasswordKey: "password", HashKey: "hash-s3cret"}).
+		Build()
+	admin := mapper.AdminHandler()
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		body     string
+		status   int
+		contains []string
+		excludes []string
+	}{
+		{"config", "GET", "/config", "", http.StatusOK, []string{`"grpc_metadata": "user-id"`, redacted}, []string{"s3cret"}},
+		{"config yaml", "GET", "/config?format=yaml", "", http.StatusOK, []string{"grpc_metadata: user-id"}, []string{"s3cret"}},
+		{"config format", "GET", "/config?format=xml", "", http.StatusBadRequest, nil, nil},
+		{"mappings", "GET", "/mappings", "", http.StatusOK, []string{"X-User-ID", "user-id"}, nil},
+		{"stats", "GET", "/stats", "", http.StatusOK, []string{`"IncomingMappings"`}, nil},
+		{"debug on", "POST", "/debug?enabled=true", "", http.StatusOK, []string{`"debug": true`}, nil},
+		{"debug toggle", "POST", "/debug", "", http.StatusOK, []string{`"debug": false`}, nil},
+		{"debug invalid", "POST", "/debug?enabled=maybe", "", http.StatusBadRequest, nil, nil},
+		{"put invalid", "PUT", "/config", "mappings:\n  - grpc_metadata: key\n", http.StatusBadRequest, nil, nil},
+		{"method", "DELETE", "/config", "", http.StatusMethodNotAllowed, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			admin.ServeHTTP(w, httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body)))
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(w.Body.String(), s) {
+					t.Errorf("body missing %q:\n%s", s, w.Body.String())
+				}
+			}
+			for _, s := range tt.excludes {
+				if strings.Contains(w.Body.String(), s) {
+					t.Errorf("body contains %q:\n%s", s, w.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestAdminHandler_PutConfig(t *testing.T) {
+	mapper := NewBuilder().AddIncomingMapping("X-User-ID", "user-id").Build()
+	admin := mapper.AdminHandler()
+
+	body := `{"mappings": [{"http_header": "X-Tenant-ID", "grpc_metadata": "tenant-id", "direction": "incoming"}]}`
+	w := httptest.NewRecorder()
+	admin.ServeHTTP(w, httptest.NewRequest("PUT", "/config", strings.NewReader(body)))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+
+	mappings := mapper.ListMappings()
+	if len(mappings) != 1 || mappings[0].GRPCMetadata != "tenant-id" {
+		t.Errorf("mappings = %+v", mappings)
+	}
+
+	w = httptest.NewRecorder()
+	admin.ServeHTTP(w, httptest.NewRequest("GET", "/config", nil))
+	var config Config
+	if err := json.Unmarshal(w.Body.Bytes(), &config); err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Mappings) != 1 || config.Mappings[0].HTTPHeader != "X-Tenant-ID" {
+		t.Errorf("served config = %+v", config)
+	}
+}
+
+func TestAdminHandler_PutConfigPolicies(t *testing.T) {
+	tests := []struct {
+		name   string
+		edit   func(served string) string
+		status int
+		errMsg string
+	}{
+		{"served json", func(s string) string { return s }, http.StatusNoContent, ""},
+		{"new mapping", func(s string) string {
+			return strings.Replace(s, `"mappings": [`, `"mappings": [{"http_header": "X-Tenant-ID", "grpc_metadata": "tenant-id", "direction": "incoming"},`, 1)
+		}, http.StatusNoContent, ""},
+		{"rate limit changed", func(s string) string { return strings.Replace(s, `"burst": 5`, `"burst": 50`, 1) }, http.StatusBadRequest, "rate_limit"},
+		{"secret changed", func(s string) string { return strings.Replace(s, redacted, "guessed", 1) }, http.StatusBadRequest, "shared_secret"},
+		{"code transform dropped", func(s string) string { return strings.Replace(s, `"X-User-ID"`, `"X-Account-ID"`, 1) }, http.StatusBadRequest, "set in code"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper := NewBuilder().
+				AddIncomingMapping("X-User-ID", "user-id").
+				WithTransform(func(value string) string { return "user-" + value }).
+				RequireSharedSecret(&SharedSecretConfig{Header: "X-Gateway-Secret", Secrets: []string{"s3cret"}}).
+				RateLimit(&RateLimitConfig{KeyMetadata: []string{"user-id"}, Rate: 1, Burst: 5, Store: NewMemoryRateLimitStore()}).
+				Build()
+			admin := mapper.AdminHandler()
+
+			w := httptest.NewRecorder()
+			admin.ServeHTTP(w, httptest.NewRequest("GET", "/config", nil))
+			w2 := httptest.NewRecorder()
+			admin.ServeHTTP(w2, httptest.NewRequest("PUT", "/config", strings.NewReader(tt.edit(w.Body.String()))))
+			if w2.Code != tt.status || !strings.Contains(w2.Body.String(), tt.errMsg) {
+				t.Fatalf("status = %d, want %d: %s", w2.Code, tt.status, w2.Body.String())
+			}
+
+			// The policies and code transforms in effect are kept
+			config := mapper.state().config
+			if config.SharedSecret.Secrets[0] != "s3cret" || config.RateLimit.Store == nil {
+				t.Errorf("policies after PUT = %+v, %+v", config.SharedSecret, config.RateLimit)
+			}
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("X-User-ID", "42")
+			if got := firstValue(mapper.MetadataAnnotator()(req.Context(), req), "user-id"); got != "user-42" {
+				t.Errorf("user-id = %q, want the code transform applied", got)
+			}
+		})
+	}
+}
+
+func TestAdminHandler_PutConfigYAML(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").
+		EnableCORS(&CORSConfig{AllowedOrigins: []string{"https://app.example.com"}}).
+		Build()
+	admin := mapper.AdminHandler()
+
+	w := httptest.NewRecorder()
+	admin.ServeHTTP(w, httptest.NewRequest("GET", "/config?format=yaml", nil))
+	w2 := httptest.NewRecorder()
+	admin.ServeHTTP(w2, httptest.NewRequest("PUT", "/config", strings.NewReader(w.Body.String())))
+	if w2.Code != http.StatusNoContent {
+		t.Errorf("status = %d: %s", w2.Code, w2.Body.String())
+	}
+}
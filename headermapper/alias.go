@@ -0,0 +1,27 @@
+package headermapper
+
+import (
+	"net/http"
+	"slices"
+)
+
+// aliasValues returns the values of the first alias of src sent with req,
+// recording the use of deprecated aliases so client migration can be tracked
+func (hm *HeaderMapper) aliasValues(req *http.Request, src *incomingSource) []string {
+	for _, alias := range src.aliases {
+		values := req.Header[alias]
+		if len(values) == 0 {
+			continue
+		}
+		if src.deprecated {
+			for _, mapping := range src.mappings {
+				if mapping.deprecated && slices.Contains(mapping.aliases, alias) {
+					mapping.counter.deprecated.Add(1)
+				}
+			}
+			hm.log().Warn("Deprecated header alias used:", alias, "instead of", src.header)
+		}
+		return values
+	}
+	return nil
+}
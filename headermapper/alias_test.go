@@ -0,0 +1,100 @@
+package headermapper
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHeaderMapping_Aliases(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-Request-ID", "request-id").WithDeprecatedAliases("X-RequestID", "Request-Id").
+		AddIncomingMapping("X-Tenant-ID", "tenant-id").WithAliases("Tenant").
+		Build()
+
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		expected   map[string]string
+		deprecated int64
+	}{
+		{
+			name:     "header",
+			headers:  map[string]string{"X-Request-ID": "abc"},
+			expected: map[string]string{"request-id": "abc"},
+		},
+		{
+			name:       "deprecated alias",
+			headers:    map[string]string{"X-RequestID": "abc"},
+			expected:   map[string]string{"request-id": "abc"},
+			deprecated: 1,
+		},
+		{
+			name:       "second alias",
+			headers:    map[string]string{"Request-Id": "abc"},
+			expected:   map[string]string{"request-id": "abc"},
+			deprecated: 1,
+		},
+		{
+			name:     "header takes precedence",
+			headers:  map[string]string{"X-Request-ID": "abc", "X-RequestID": "old"},
+			expected: map[string]string{"request-id": "abc"},
+		},
+		{
+			name:     "alias without deprecation",
+			headers:  map[string]string{"Tenant": "acme"},
+			expected: map[string]string{"tenant-id": "acme"},
+		},
+		{
+			name:     "no mapped header",
+			headers:  map[string]string{"X-Other": "value"},
+			expected: map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := mapper.GetStats().Mappings[0].DeprecatedAliases
+
+			req := httptest.NewRequest("GET", "/api/test", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			md := mapper.MetadataAnnotator()(context.Background(), req)
+
+			if len(md) != len(tt.expected) {
+				t.Fatalf("metadata = %v, want %v", md, tt.expected)
+			}
+			for key, value := range tt.expected {
+				if got := md.Get(key); len(got) != 1 || got[0] != value {
+					t.Errorf("%s = %v, want %s", key, got, value)
+				}
+			}
+			if got := mapper.GetStats().Mappings[0].DeprecatedAliases - before; got != tt.deprecated {
+				t.Errorf("DeprecatedAliases increased by %d, want %d", got, tt.deprecated)
+			}
+		})
+	}
+}
+
+func TestHeaderMapping_AliasesMatcher(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-Request-ID", "request-id").WithAliases("Request-Id").
+		Build()
+
+	matcher := mapper.HeaderMatcher()
+	for _, name := range []string{"X-Request-Id", "Request-Id", "request-id"} {
+		if key, ok := matcher(name); !ok || key != "request-id" {
+			t.Errorf("HeaderMatcher(%s) = %s, %v, want request-id", name, key, ok)
+		}
+	}
+}
+
+func TestHeaderMapping_InvalidAlias(t *testing.T) {
+	config := &Config{Mappings: []HeaderMapping{
+		{HTTPHeader: "X-Request-ID", GRPCMetadata: "request-id", Direction: Incoming, Aliases: []string{"Bad Header"}},
+	}}
+	if err := ValidateConfig(config); err == nil {
+		t.Error("ValidateConfig() error = nil, want invalid alias")
+	}
+}
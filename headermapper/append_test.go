@@ -0,0 +1,67 @@
+package headermapper
+
+import (
+	"context"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestAppendValues_Incoming(t *testing.T) {
+	tests := []struct {
+		name      string
+		append    bool
+		overwrite bool
+		expected  []string
+	}{
+		{name: "first wins", expected: []string{"203.0.113.7"}},
+		{name: "overwrite", overwrite: true, expected: []string{"10.0.0.1"}},
+		{name: "append", append: true, expected: []string{"203.0.113.7", "10.0.0.1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper := NewBuilder().
+				AddIncomingMapping("X-Client-IP", "client-hops").
+				AddIncomingMapping("X-Proxy-IP", "client-hops").WithAppendValues(tt.append).
+				AddIncomingMapping("X-User-ID", "user-id").
+				OverwriteExisting(tt.overwrite).
+				Build()
+
+			req := httptest.NewRequest("GET", "/api/test", nil)
+			req.Header.Set("X-Client-IP", "203.0.113.7")
+			req.Header.Set("X-Proxy-IP", "10.0.0.1")
+			req.Header.Set("X-User-ID", "12345")
+			md := mapper.MetadataAnnotator()(context.Background(), req)
+
+			if got := md.Get("client-hops"); !slices.Equal(got, tt.expected) {
+				t.Errorf("client-hops = %v, want %v", got, tt.expected)
+			}
+			// Appending must not clobber neighbouring values in the shared backing slice
+			if got := md.Get("user-id"); !slices.Equal(got, []string{"12345"}) {
+				t.Errorf("user-id = %v", got)
+			}
+		})
+	}
+}
+
+func TestAppendValues_Outgoing(t *testing.T) {
+	mapper := NewBuilder().
+		AddOutgoingMapping("server-timing-db", "Server-Timing").
+		AddOutgoingMapping("server-timing-app", "Server-Timing").WithAppendValues(true).
+		Build()
+
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{
+		HeaderMD: metadata.Pairs("server-timing-db", "db;dur=12", "server-timing-app", "app;dur=30"),
+	})
+	w := httptest.NewRecorder()
+	if err := mapper.ResponseModifier()(ctx, w, nil); err != nil {
+		t.Fatalf("ResponseModifier() error = %v", err)
+	}
+	if got := w.Header().Values("Server-Timing"); !slices.Equal(got, []string{"db;dur=12", "app;dur=30"}) {
+		t.Errorf("Server-Timing = %v", got)
+	}
+}
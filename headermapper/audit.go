@@ -0,0 +1,214 @@
+package headermapper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// AuditConfig records the identity metadata of each request to a sink
+type AuditConfig struct {
+	// Keys lists the metadata keys to record (default: keys of incoming mappings)
+	Keys []string `json:"keys" yaml:"keys"`
+	// Sink receives the records
+	Sink AuditSink `json:"-" yaml:"-"`
+}
+
+// validate checks that a sink is configured
+func (ac *AuditConfig) validate() error {
+	if ac.Sink == nil {
+		return fmt.Errorf("audit: sink cannot be nil")
+	}
+	return nil
+}
+
+// AuditRecord describes the metadata mapped for a single request
+type AuditRecord struct {
+	Time time.Time `json:"time"`
+	// Protocol is "http" for gateway requests and "grpc" for server calls
+	Protocol string `json:"protocol"`
+	// Path is the HTTP path or gRPC full method
+	Path     string              `json:"path"`
+	Metadata map[string][]string `json:"metadata"`
+	// Error is set when the request was rejected
+	Error string `json:"error,omitempty"`
+	// Sequence, PrevHash and Hash are set by HashChainSink
+	Sequence uint64 `json:"sequence,omitempty"`
+	PrevHash string `json:"prev_hash,omitempty"`
+	Hash     string `json:"hash,omitempty"`
+}
+
+// AuditSink receives audit records
+type AuditSink interface {
+	Write(ctx context.Context, record AuditRecord) error
+}
+
+// AuditSinkFunc adapts a function to AuditSink
+type AuditSinkFunc func(ctx context.Context, record AuditRecord) error
+
+// Write implements AuditSink
+func (f AuditSinkFunc) Write(ctx context.Context, record AuditRecord) error {
+	return f(ctx, record)
+}
+
+// JSONAuditSink writes records as JSON lines
+type JSONAuditSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONAuditSink creates a sink writing JSON lines to w
+func NewJSONAuditSink(w io.Writer) *JSONAuditSink {
+	return &JSONAuditSink{enc: json.NewEncoder(w)}
+}
+
+// Write implements AuditSink
+func (s *JSONAuditSink) Write(ctx context.Context, record AuditRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(record)
+}
+
+// HashChainConfig configures a HashChainSink
+type HashChainConfig struct {
+	// Anchor publishes the latest hash to an external system, e.g. a
+	// transparency log or write-once storage
+	Anchor func(ctx context.Context, sequence uint64, hash string) error
+	// AnchorEvery calls Anchor after this many records (default 100)
+	AnchorEvery int
+	// Sequence and PrevHash resume an existing chain
+	Sequence uint64
+	PrevHash string
+}
+
+// HashChainSink makes an audit trail tamper-evident by including the hash of
+// the previous record in each record before passing it to the next sink
+type HashChainSink struct {
+	mu     sync.Mutex
+	next   AuditSink
+	config HashChainConfig
+	seq    uint64
+	prev   string
+}
+
+// NewHashChainSink creates a hash-chaining sink in front of next
+func NewHashChainSink(next AuditSink, config HashChainConfig) *HashChainSink {
+	if config.AnchorEvery <= 0 {
+		config.AnchorEvery = 100
+	}
+	return &HashChainSink{next: next, config: config, seq: config.Sequence, prev: config.PrevHash}
+}
+
+// Write implements AuditSink
+func (s *HashChainSink) Write(ctx context.Context, record AuditRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record.Sequence = s.seq + 1
+	record.PrevHash = s.prev
+	hash, err := hashAuditRecord(record)
+	if err != nil {
+		return err
+	}
+	record.Hash = hash
+
+	if err := s.next.Write(ctx, record); err != nil {
+		return err
+	}
+	s.seq = record.Sequence
+	s.prev = hash
+
+	if s.config.Anchor != nil && s.seq%uint64(s.config.AnchorEvery) == 0 {
+		return s.config.Anchor(ctx, s.seq, hash)
+	}
+	return nil
+}
+
+// Head returns the sequence and hash of the last record written
+func (s *HashChainSink) Head() (uint64, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.seq, s.prev
+}
+
+// VerifyAuditChain checks that records form an unbroken hash chain
+func VerifyAuditChain(records []AuditRecord) error {
+	for i, record := range records {
+		if i > 0 {
+			if record.Sequence != records[i-1].Sequence+1 {
+				return fmt.Errorf("audit chain: record %d: sequence gap", record.Sequence)
+			}
+			if record.PrevHash != records[i-1].Hash {
+				return fmt.Errorf("audit chain: record %d: previous hash mismatch", record.Sequence)
+			}
+		}
+		hash, err := hashAuditRecord(record)
+		if err != nil {
+			return err
+		}
+		if hash != record.Hash {
+			return fmt.Errorf("audit chain: record %d: hash mismatch", record.Sequence)
+		}
+	}
+	return nil
+}
+
+// hashAuditRecord hashes the JSON encoding of a record without its hash
+func hashAuditRecord(record AuditRecord) (string, error) {
+	record.Hash = ""
+	data, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// auditor emits audit records for an AuditConfig
+type auditor struct {
+	config *AuditConfig
+	keys   []string
+	hm     *HeaderMapper
+}
+
+func newAuditor(config *AuditConfig, hm *HeaderMapper) *auditor {
+	keys := config.Keys
+	if len(keys) == 0 {
+		for _, mapping := range hm.state().config.Mappings {
+			if mapping.Direction != Outgoing {
+				keys = appendUnique(keys, strings.ToLower(mapping.GRPCMetadata))
+			}
+		}
+	}
+	return &auditor{config: config, keys: keys, hm: hm}
+}
+
+// record writes an audit record, logging sink failures
+func (a *auditor) record(ctx context.Context, protocol, p string, md metadata.MD, err error) {
+	record := AuditRecord{
+		Time:     time.Now().UTC(),
+		Protocol: protocol,
+		Path:     p,
+		Metadata: make(map[string][]string),
+	}
+	for _, key := range a.keys {
+		if values := md.Get(key); len(values) > 0 {
+			record.Metadata[key] = values
+		}
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+
+	if sinkErr := a.config.Sink.Write(ctx, record); sinkErr != nil {
+		a.hm.log().Warn("Audit sink error:", sinkErr)
+	}
+}
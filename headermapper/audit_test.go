@@ -0,0 +1,102 @@
+package headermapper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestAudit_Records(t *testing.T) {
+	var records []AuditRecord
+	sink := AuditSinkFunc(func(ctx context.Context, record AuditRecord) error {
+		records = append(records, record)
+		return nil
+	})
+	mapper := NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").
+		AddIncomingMapping("X-User-Role", "user-role").
+		Authorize(&AuthorizationConfig{Rules: []AccessRule{{Paths: []string{"/v1/admin/*"}, Deny: true}}}).
+		Audit(&AuditConfig{Sink: sink}).
+		Build()
+
+	// Gateway request
+	req := httptest.NewRequest("GET", "/v1/echo", nil)
+	req.Header.Set("X-User-ID", "user-1")
+	req.Header.Set("X-Other", "ignored")
+	mapper.MetadataAnnotator()(req.Context(), req)
+
+	// Rejected gateway request
+	req = httptest.NewRequest("GET", "/v1/admin/users", nil)
+	mapper.Handler(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), req)
+
+	// gRPC call
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("user-role", "admin"))
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+	_, _ = mapper.UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/echo.v1.EchoService/Echo"}, handler)
+
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+	if records[0].Protocol != "http" || records[0].Metadata["user-id"][0] != "user-1" || len(records[0].Metadata) != 1 {
+		t.Errorf("unexpected gateway record %+v", records[0])
+	}
+	if records[1].Path != "/v1/admin/users" || records[1].Error == "" {
+		t.Errorf("unexpected rejection record %+v", records[1])
+	}
+	if records[2].Protocol != "grpc" || records[2].Metadata["user-role"][0] != "admin" {
+		t.Errorf("unexpected gRPC record %+v", records[2])
+	}
+}
+
+func TestHashChainSink(t *testing.T) {
+	var buf bytes.Buffer
+	var anchored []uint64
+	sink := NewHashChainSink(NewJSONAuditSink(&buf), HashChainConfig{
+		AnchorEvery: 2,
+		Anchor: func(ctx context.Context, sequence uint64, hash string) error {
+			anchored = append(anchored, sequence)
+			return nil
+		},
+	})
+
+	for _, user := range []string{"a", "b", "c", "d"} {
+		record := AuditRecord{Protocol: "http", Path: "/v1/echo", Metadata: map[string][]string{"user-id": {user}}}
+		if err := sink.Write(context.Background(), record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var records []AuditRecord
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var record AuditRecord
+		if err := dec.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+
+	if err := VerifyAuditChain(records); err != nil {
+		t.Errorf("VerifyAuditChain() error = %v", err)
+	}
+	if seq, hash := sink.Head(); seq != 4 || hash != records[3].Hash {
+		t.Errorf("Head() = %d, %s", seq, hash)
+	}
+	if len(anchored) != 2 || anchored[1] != 4 {
+		t.Errorf("anchored = %v, want [2 4]", anchored)
+	}
+
+	records[1].Metadata["user-id"] = []string{"mallory"}
+	if err := VerifyAuditChain(records); err == nil {
+		t.Error("VerifyAuditChain() expected error for tampered record")
+	}
+	if err := VerifyAuditChain(append(records[:1:1], records[2:]...)); err == nil {
+		t.Error("VerifyAuditChain() expected error for removed record")
+	}
+}
@@ -0,0 +1,184 @@
+package headermapper
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// BasicAuthPasswordMode determines how the password of Basic credentials is
+// forwarded
+type BasicAuthPasswordMode string
+
+const (
+	// BasicAuthPasswordHash forwards the hex SHA-256 of the password, or its
+	// HMAC-SHA256 with a hash key (default)
+	BasicAuthPasswordHash BasicAuthPasswordMode = "hash"
+	// BasicAuthPasswordMask forwards the password with every character masked
+	BasicAuthPasswordMask BasicAuthPasswordMode = "mask"
+	// BasicAuthPasswordPlain forwards the password unchanged
+	BasicAuthPasswordPlain BasicAuthPasswordMode = "plain"
+)
+
+// validate checks the mode name
+func (m BasicAuthPasswordMode) validate() error {
+	switch m {
+	case "", BasicAuthPasswordHash, BasicAuthPasswordMask, BasicAuthPasswordPlain:
+		return nil
+	}
+	return fmt.Errorf("basic auth: unknown password mode: %s", m)
+}
+
+// BasicAuthConfig configures decoding "Authorization: Basic" credentials of
+// legacy clients into gRPC metadata
+type BasicAuthConfig struct {
+	// Header carries the credentials (default Authorization)
+	Header string `json:"header,omitempty" yaml:"header,omitempty"`
+	// UsernameKey receives the username (default username)
+	UsernameKey string `json:"username_key,omitempty" yaml:"username_key,omitempty"`
+	// PasswordKey receives the password; empty drops it
+	PasswordKey string `json:"password_key,omitempty" yaml:"password_key,omitempty"`
+	// Password is hash (default), mask or plain
+	Password BasicAuthPasswordMode `json:"password,omitempty" yaml:"password,omitempty"`
+	// HashKey keys the password hash with HMAC-SHA256, so backends sharing
+	// it can compare hashes that cannot be reversed by dictionary attacks
+	HashKey string `json:"hash_key,omitempty" yaml:"hash_key,omitempty"`
+}
+
+// DecodeBasicAuth returns a Basic-Auth preset mapping the username to
+// usernameKey and dropping the password
+func DecodeBasicAuth(usernameKey string) *BasicAuthConfig {
+	return &BasicAuthConfig{UsernameKey: usernameKey}
+}
+
+func (bc *BasicAuthConfig) header() string {
+	if bc.Header == "" {
+		return "Authorization"
+	}
+	return bc.Header
+}
+
+func (bc *BasicAuthConfig) usernameKey() string {
+	if bc.UsernameKey == "" {
+		return "username"
+	}
+	return strings.ToLower(bc.UsernameKey)
+}
+
+// validate checks the header name, metadata keys and password mode
+func (bc *BasicAuthConfig) validate() error {
+	if !validHeaderName(bc.header()) {
+		return fmt.Errorf("basic auth: invalid header name: %q", bc.Header)
+	}
+	keys := []string{bc.usernameKey()}
+	if bc.PasswordKey != "" {
+		keys = append(keys, strings.ToLower(bc.PasswordKey))
+	}
+	for _, key := range keys {
+		if !validMetadataKey(key) || strings.HasSuffix(key, "-bin") {
+			return fmt.Errorf("basic auth: invalid metadata key: %q", key)
+		}
+	}
+	if len(keys) == 2 && keys[0] == keys[1] {
+		return fmt.Errorf("basic auth: username and password keys are the same: %s", keys[0])
+	}
+	return bc.Password.validate()
+}
+
+// basicAuth maps the credentials of requests into metadata
+type basicAuth struct {
+	header      string
+	usernameKey string
+	passwordKey string
+	password    TransformFunc
+	hm          *HeaderMapper
+}
+
+func newBasicAuth(config *BasicAuthConfig, hm *HeaderMapper) *basicAuth {
+	return &basicAuth{
+		header:      http.CanonicalHeaderKey(config.header()),
+		usernameKey: config.usernameKey(),
+		passwordKey: strings.ToLower(config.PasswordKey),
+		password:    ExtractBasicAuthPassword(config.Password, config.HashKey),
+		hm:          hm,
+	}
+}
+
+// annotate sets the username and password metadata of the request
+// credentials, replacing values mappings placed under the same keys
+func (ba *basicAuth) annotate(req *http.Request, md metadata.MD) {
+	value := req.Header.Get(ba.header)
+	if value == "" {
+		return
+	}
+	username, password, ok := parseBasicAuth(value)
+	if !ok {
+		ba.hm.log().Debug("Ignoring invalid Basic credentials")
+		return
+	}
+	if username != "" {
+		md.Set(ba.usernameKey, username)
+	}
+	if ba.passwordKey != "" && password != "" {
+		md.Set(ba.passwordKey, ba.password(value))
+	}
+}
+
+// ExtractBasicAuthUsername returns the username of "Basic <credentials>"
+// values, or an empty value for other values
+func ExtractBasicAuthUsername(value string) string {
+	username, _, _ := parseBasicAuth(value)
+	return username
+}
+
+// ExtractBasicAuthPassword returns a transform reading the password of
+// "Basic <credentials>" values, hashed with hashKey, masked or unchanged
+// according to mode. Other values give an empty value.
+//
+//	AddIncomingMapping("Authorization", "password-hash").
+//		WithTransform(headermapper.ExtractBasicAuthPassword(headermapper.BasicAuthPasswordHash, hashKey))
+func ExtractBasicAuthPassword(mode BasicAuthPasswordMode, hashKey string) TransformFunc {
+	return func(value string) string {
+		_, password, ok := parseBasicAuth(value)
+		if !ok || password == "" {
+			return ""
+		}
+		switch mode {
+		case BasicAuthPasswordPlain:
+			return password
+		case BasicAuthPasswordMask:
+			return strings.Repeat("*", len(password))
+		}
+		if hashKey != "" {
+			mac := hmac.New(sha256.New, []byte(hashKey))
+			mac.Write([]byte(password))
+			return hex.EncodeToString(mac.Sum(nil))
+		}
+		sum := sha256.Sum256([]byte(password))
+		return hex.EncodeToString(sum[:])
+	}
+}
+
+// parseBasicAuth decodes "Basic <base64 username:password>" credentials
+func parseBasicAuth(value string) (username, password string, ok bool) {
+	const prefix = "Basic "
+	value = strings.TrimSpace(value)
+	if len(value) < len(prefix) || !strings.EqualFold(value[:len(prefix)], prefix) {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value[len(prefix):]))
+	if err != nil {
+		return "", "", false
+	}
+	username, password, ok = strings.Cut(string(decoded), ":")
+	if !ok || strings.ContainsAny(username, "\r\n") || strings.ContainsAny(password, "\r\n") {
+		return "", "", false
+	}
+	return username, password, true
+}
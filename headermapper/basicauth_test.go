@@ -0,0 +1,152 @@
+package headermapper
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func basicCredentials(username, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+}
+
+func TestParseBasicAuth(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		username string
+		password string
+		ok       bool
+	}{
+		{"valid", basicCredentials("alice", "s3cret"), "alice", "s3cret", true},
+		{"lowercase scheme", "basic " + base64.StdEncoding.EncodeToString([]byte("alice:s3cret")), "alice", "s3cret", true},
+		{"colon in password", basicCredentials("alice", "a:b"), "alice", "a:b", true},
+		{"empty password", basicCredentials("alice", ""), "alice", "", true},
+		{"no colon", "Basic " + base64.StdEncoding.EncodeToString([]byte("alice")), "", "", false},
+		{"invalid base64", "Basic !!!", "", "", false},
+		{"bearer", "Bearer token", "", "", false},
+		{"newline", basicCredentials("alice\n", "x"), "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			username, password, ok := parseBasicAuth(tt.value)
+			if username != tt.username || password != tt.password || ok != tt.ok {
+				t.Errorf("parseBasicAuth() = %q, %q, %v, want %q, %q, %v", username, password, ok, tt.username, tt.password, tt.ok)
+			}
+		})
+	}
+}
+
+func TestExtractBasicAuthPassword(t *testing.T) {
+	value := basicCredentials("alice", "s3cret")
+	sum := sha256.Sum256([]byte("s3cret"))
+	mac := hmac.New(sha256.New, []byte("hash-key"))
+	mac.Write([]byte("s3cret"))
+
+	tests := []struct {
+		name    string
+		mode    BasicAuthPasswordMode
+		hashKey string
+		value   string
+		want    string
+	}{
+		{"hash", BasicAuthPasswordHash, "", value, hex.EncodeToString(sum[:])},
+		{"default", "", "", value, hex.EncodeToString(sum[:])},
+		{"hmac", BasicAuthPasswordHash, "hash-key", value, hex.EncodeToString(mac.Sum(nil))},
+		{"mask", BasicAuthPasswordMask, "", value, "******"},
+		{"plain", BasicAuthPasswordPlain, "", value, "s3cret"},
+		{"empty password", BasicAuthPasswordPlain, "", basicCredentials("alice", ""), ""},
+		{"not basic", BasicAuthPasswordPlain, "", "Bearer token", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractBasicAuthPassword(tt.mode, tt.hashKey)(tt.value); got != tt.want {
+				t.Errorf("ExtractBasicAuthPassword() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if got := ExtractBasicAuthUsername(value); got != "alice" {
+		t.Errorf("ExtractBasicAuthUsername() = %q, want alice", got)
+	}
+}
+
+func TestMapBasicAuth(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-User", "user-id").
+		MapBasicAuth(&BasicAuthConfig{UsernameKey: "user-id", PasswordKey: "password", Password: BasicAuthPasswordMask}).
+		Build()
+	if err := mapper.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		userID        []string
+		password      []string
+	}{
+		{"credentials", basicCredentials("alice", "s3cret"), []string{"alice"}, []string{"******"}},
+		{"invalid", "Basic !!!", []string{"spoofed"}, nil},
+		{"bearer", "Bearer token", []string{"spoofed"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", tt.authorization)
+			req.Header.Set("X-User", "spoofed")
+			md := mapper.MetadataAnnotator()(context.Background(), req)
+			if got := md.Get("user-id"); !reflect.DeepEqual(got, tt.userID) {
+				t.Errorf("user-id = %v, want %v", got, tt.userID)
+			}
+			if got := md.Get("password"); !reflect.DeepEqual(got, tt.password) {
+				t.Errorf("password = %v, want %v", got, tt.password)
+			}
+		})
+	}
+
+	if _, ok := mapper.HeaderMatcher()("Grpc-Metadata-Password"); ok {
+		t.Error("HeaderMatcher forwarded the password key")
+	}
+}
+
+func TestBasicAuthConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *BasicAuthConfig
+		wantErr string
+	}{
+		{"preset", DecodeBasicAuth("user-id"), ""},
+		{"defaults", &BasicAuthConfig{}, ""},
+		{"invalid header", &BasicAuthConfig{Header: "Bad Header"}, "invalid header name"},
+		{"invalid key", &BasicAuthConfig{UsernameKey: "user id"}, "invalid metadata key"},
+		{"binary key", &BasicAuthConfig{PasswordKey: "password-bin"}, "invalid metadata key"},
+		{"same keys", &BasicAuthConfig{UsernameKey: "creds", PasswordKey: "Creds"}, "are the same"},
+		{"unknown mode", &BasicAuthConfig{PasswordKey: "password", Password: "encrypt"}, "unknown password mode"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -2,8 +2,15 @@ package headermapper
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
 )
 
 func BenchmarkMetadataAnnotator(b *testing.B) {
@@ -25,6 +32,57 @@ func BenchmarkMetadataAnnotator(b *testing.B) {
 	annotator := mapper.MetadataAnnotator()
 	ctx := context.Background()
 
+	// The metadata map, its buckets and the shared value slice
+	if allocs := testing.AllocsPerRun(100, func() { _ = annotator(ctx, req) }); allocs > 3 {
+		b.Fatalf("annotator allocations = %v, want <= 3", allocs)
+	}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		_ = annotator(ctx, req)
+	}
+}
+
+func BenchmarkMetadataAnnotatorSkipPath(b *testing.B) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").
+		SkipPaths("/health").
+		Build()
+
+	req := httptest.NewRequest("GET", "/health", nil)
+	req.Header.Set("X-User-ID", "12345")
+
+	annotator := mapper.MetadataAnnotator()
+	ctx := context.Background()
+
+	if allocs := testing.AllocsPerRun(100, func() { _ = annotator(ctx, req) }); allocs != 0 {
+		b.Fatalf("skip path allocations = %v, want 0", allocs)
+	}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		_ = annotator(ctx, req)
+	}
+}
+
+func BenchmarkMetadataAnnotatorNoMappedHeaders(b *testing.B) {
+	mapper, _, _ := scaleMapper(scaleBenchmark{mappings: 50})
+
+	req := httptest.NewRequest("GET", "/healthz", nil)
+	req.Header.Set("User-Agent", "kube-probe/1.30")
+	req.Header.Set("Accept", "*/*")
+
+	annotator := mapper.MetadataAnnotator()
+	ctx := context.Background()
+
+	if allocs := testing.AllocsPerRun(100, func() { _ = annotator(ctx, req) }); allocs != 0 {
+		b.Fatalf("no mapped headers allocations = %v, want 0", allocs)
+	}
+
 	b.ResetTimer()
 	b.ReportAllocs()
 
@@ -50,6 +108,41 @@ func BenchmarkTransformations(b *testing.B) {
 	}
 }
 
+func BenchmarkFusedTransformations(b *testing.B) {
+	transform := FuseTransforms(
+		TrimSpaceStep(),
+		RemovePrefixStep("Bearer "),
+		ToLowerStep(),
+	)
+
+	input := "  Bearer TOKEN123  "
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		_ = transform(input)
+	}
+}
+
+func BenchmarkTransformationsWithAffixes(b *testing.B) {
+	chained := ChainTransforms(TrimSpace, RemovePrefix("Bearer "), AddPrefix("token:"), ToLower, AddSuffix(";v1"))
+	fused := FuseTransforms(TrimSpaceStep(), RemovePrefixStep("Bearer "), AddPrefixStep("token:"), ToLowerStep(), AddSuffixStep(";v1"))
+	input := "  Bearer TOKEN123  "
+
+	for _, bc := range []struct {
+		name      string
+		transform TransformFunc
+	}{{"chained", chained}, {"fused", fused}} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_ = bc.transform(input)
+			}
+		})
+	}
+}
+
 func BenchmarkHeaderMatcher(b *testing.B) {
 	mapper := NewBuilder().
 		AddIncomingMapping("X-User-ID", "user-id").
@@ -94,3 +187,166 @@ func BenchmarkBuilderPattern(b *testing.B) {
 			Build()
 	}
 }
+
+// scaleBenchmark describes one point of the performance budget
+type scaleBenchmark struct {
+	mappings   int
+	transforms bool
+	multiValue bool
+}
+
+func (sb scaleBenchmark) String() string {
+	return fmt.Sprintf("mappings=%d/transforms=%v/multivalue=%v", sb.mappings, sb.transforms, sb.multiValue)
+}
+
+var scaleBenchmarks = []scaleBenchmark{
+	{5, false, false},
+	{5, true, false},
+	{5, false, true},
+	{50, false, false},
+	{50, true, false},
+	{50, false, true},
+	{500, false, false},
+	{500, true, false},
+	{500, false, true},
+}
+
+// scaleMapper builds a mapper with n bidirectional mappings and a request
+// carrying the first 20 mapped headers plus common unmapped ones
+func scaleMapper(sb scaleBenchmark) (*HeaderMapper, *http.Request, metadata.MD) {
+	builder := NewBuilder()
+	for i := 0; i < sb.mappings; i++ {
+		builder.AddBidirectionalMapping(fmt.Sprintf("X-Header-%d", i), fmt.Sprintf("header-%d", i))
+		if sb.transforms {
+			builder.WithTransform(ChainTransforms(TrimSpace, ExtractBearerToken))
+		}
+	}
+	mapper := builder.Build()
+
+	req := httptest.NewRequest("GET", "/api/test", nil)
+	md := metadata.MD{}
+	for i := 0; i < min(sb.mappings, 20); i++ {
+		name := fmt.Sprintf("X-Header-%d", i)
+		req.Header.Add(name, "Bearer value")
+		md.Append(fmt.Sprintf("header-%d", i), "Bearer value")
+		if sb.multiValue {
+			req.Header.Add(name, "Bearer other")
+			md.Append(fmt.Sprintf("header-%d", i), "Bearer other")
+		}
+	}
+	for _, name := range []string{"Accept", "Accept-Encoding", "User-Agent", "Cookie", "Host"} {
+		req.Header.Set(name, "value")
+	}
+
+	return mapper, req, md
+}
+
+// BenchmarkMetadataAnnotatorScale measures the incoming path against the
+// budget in the README: allocations stay constant regardless of the number
+// of mappings, and time grows with the mapped headers present rather than
+// the mappings configured
+func BenchmarkMetadataAnnotatorScale(b *testing.B) {
+	for _, sb := range scaleBenchmarks {
+		b.Run(sb.String(), func(b *testing.B) {
+			mapper, req, _ := scaleMapper(sb)
+			annotator := mapper.MetadataAnnotator()
+			ctx := context.Background()
+
+			// A metadata map of more than 8 entries needs two more allocations
+			if allocs := testing.AllocsPerRun(100, func() { _ = annotator(ctx, req) }); allocs > 5 {
+				b.Fatalf("annotator allocations = %v, want <= 5", allocs)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_ = annotator(ctx, req)
+			}
+		})
+	}
+}
+
+// BenchmarkResponseModifierScale measures the outgoing path
+func BenchmarkResponseModifierScale(b *testing.B) {
+	for _, sb := range scaleBenchmarks {
+		b.Run(sb.String(), func(b *testing.B) {
+			mapper, _, md := scaleMapper(sb)
+			modifier := mapper.ResponseModifier()
+			ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{HeaderMD: md})
+			w := httptest.NewRecorder()
+
+			// The shared value slice, plus the write list above 16 headers
+			if allocs := testing.AllocsPerRun(100, func() { clear(w.Header()); _ = modifier(ctx, w, nil) }); allocs > 2 {
+				b.Fatalf("response modifier allocations = %v, want <= 2", allocs)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				clear(w.Header())
+				_ = modifier(ctx, w, nil)
+			}
+		})
+	}
+}
+
+// digestTransform stands in for a transform doing about a microsecond of work,
+// such as a claims lookup or signature check
+func digestTransform(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	for i := 0; i < 8; i++ {
+		sum = sha256.Sum256(sum[:])
+	}
+	return hex.EncodeToString(sum[:4])
+}
+
+// BenchmarkMetadataAnnotatorParallel compares sequential and parallel
+// evaluation of transformed mappings to locate the crossover documented in
+// the README
+func BenchmarkMetadataAnnotatorParallel(b *testing.B) {
+	for _, mappings := range []int{16, 64, 256, 1024} {
+		for _, workers := range []int{1, 4} {
+			b.Run(fmt.Sprintf("mappings=%d/workers=%d", mappings, workers), func(b *testing.B) {
+				builder := NewBuilder().ParallelMapping(workers, 1)
+				req := httptest.NewRequest("GET", "/api/test", nil)
+				for i := 0; i < mappings; i++ {
+					name := fmt.Sprintf("X-Header-%d", i)
+					builder.AddIncomingMapping(name, fmt.Sprintf("header-%d", i)).WithTransform(digestTransform)
+					req.Header.Set(name, "value")
+				}
+				annotator := builder.Build().MetadataAnnotator()
+				ctx := context.Background()
+
+				b.ResetTimer()
+				b.ReportAllocs()
+
+				for i := 0; i < b.N; i++ {
+					_ = annotator(ctx, req)
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkMappingOverrides measures requests suppressing a mapping, which
+// reuse an index compiled once per suppression set; only the set's key is
+// allocated beyond BenchmarkMetadataAnnotatorScale
+func BenchmarkMappingOverrides(b *testing.B) {
+	for _, mappings := range []int{5, 50, 500} {
+		b.Run(fmt.Sprintf("mappings=%d", mappings), func(b *testing.B) {
+			mapper, req, _ := scaleMapper(scaleBenchmark{mappings: mappings})
+			annotator := mapper.MetadataAnnotator()
+			ctx := SuppressMapping(context.Background(), "X-Header-0")
+
+			if allocs := testing.AllocsPerRun(100, func() { _ = annotator(ctx, req) }); allocs > 6 {
+				b.Fatalf("annotator allocations = %v, want <= 6", allocs)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_ = annotator(ctx, req)
+			}
+		})
+	}
+}
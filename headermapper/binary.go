@@ -0,0 +1,80 @@
+package headermapper
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// BinaryEncoding selects how the HTTP header of a mapping to a binary
+// metadata key, one ending in -bin, represents the bytes of the value
+type BinaryEncoding string
+
+const (
+	// BinaryBase64 uses standard base64 (default); URL-safe and unpadded
+	// values are accepted from clients
+	BinaryBase64 BinaryEncoding = "base64"
+	// BinaryHex uses hexadecimal, written in lowercase
+	BinaryHex BinaryEncoding = "hex"
+	// BinaryRaw copies the header text as the value unchanged; outgoing
+	// values must then be valid header text
+	BinaryRaw BinaryEncoding = "raw"
+)
+
+// validate checks the encoding name and that the key is binary
+func (e BinaryEncoding) validate(key string) error {
+	switch e {
+	case "":
+		return nil
+	case BinaryBase64, BinaryHex, BinaryRaw:
+		if !strings.HasSuffix(key, "-bin") {
+			return fmt.Errorf("binary encoding requires a metadata key ending in -bin")
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid binary encoding %q", e)
+}
+
+// binaryEncoding returns the encoding of the values of key, "" for keys
+// that are not binary
+func binaryEncoding(key string, encoding BinaryEncoding) BinaryEncoding {
+	if !strings.HasSuffix(key, "-bin") {
+		return ""
+	}
+	if encoding == "" {
+		return BinaryBase64
+	}
+	return encoding
+}
+
+// encode returns the header text of a binary value
+func (e BinaryEncoding) encode(value string) string {
+	switch e {
+	case BinaryHex:
+		return hex.EncodeToString([]byte(value))
+	case BinaryRaw:
+		return value
+	}
+	return base64.StdEncoding.EncodeToString([]byte(value))
+}
+
+// decode returns the binary value of header text
+func (e BinaryEncoding) decode(text string) (string, error) {
+	var value []byte
+	var err error
+	switch e {
+	case BinaryHex:
+		value, err = hex.DecodeString(text)
+	case BinaryRaw:
+		return text, nil
+	default:
+		text = strings.TrimRight(text, "=")
+		if strings.ContainsAny(text, "-_") {
+			value, err = base64.RawURLEncoding.DecodeString(text)
+		} else {
+			value, err = base64.RawStdEncoding.DecodeString(text)
+		}
+	}
+	return string(value), err
+}
@@ -0,0 +1,96 @@
+package headermapper
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBinaryEncoding_Incoming(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding BinaryEncoding
+		header   string
+		want     string
+		ok       bool
+	}{
+		{"default base64", "", "AAEC/w==", "\x00\x01\x02\xff", true},
+		{"base64 unpadded", BinaryBase64, "AAEC/w", "\x00\x01\x02\xff", true},
+		{"base64 url-safe", BinaryBase64, "AAEC_w", "\x00\x01\x02\xff", true},
+		{"invalid base64", BinaryBase64, "not base64!", "", false},
+		{"hex", BinaryHex, "000102ff", "\x00\x01\x02\xff", true},
+		{"invalid hex", BinaryHex, "0g", "", false},
+		{"raw", BinaryRaw, "opaque", "opaque", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper := NewBuilder().
+				AddIncomingMapping("X-Session", "session-bin").WithBinaryEncoding(tt.encoding).
+				Build()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("X-Session", tt.header)
+
+			md := mapper.MetadataAnnotator()(context.Background(), req)
+			values := md.Get("session-bin")
+			if !tt.ok {
+				if len(values) > 0 {
+					t.Errorf("session-bin = %q, want no value", values)
+				}
+				return
+			}
+			if len(values) != 1 || values[0] != tt.want {
+				t.Errorf("session-bin = %q, want %q", values, tt.want)
+			}
+		})
+	}
+}
+
+func TestBinaryEncoding_Outgoing(t *testing.T) {
+	tests := []struct {
+		encoding BinaryEncoding
+		want     string
+	}{
+		{"", "AAEC/w=="},
+		{BinaryHex, "000102ff"},
+		{BinaryRaw, "token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.encoding), func(t *testing.T) {
+			mapper := NewBuilder().
+				AddOutgoingMapping("token-bin", "X-Token").WithBinaryEncoding(tt.encoding).
+				Build()
+			value := "\x00\x01\x02\xff"
+			if tt.encoding == BinaryRaw {
+				value = "token"
+			}
+			header := http.Header{"Token-Bin": {value}}
+			mapper.MapResponseHeaders(context.Background(), header)
+			if got := header.Get("X-Token"); got != tt.want {
+				t.Errorf("X-Token = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBinaryEncoding_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping HeaderMapping
+	}{
+		{"not binary key", HeaderMapping{HTTPHeader: "X-Session", GRPCMetadata: "session", Direction: Incoming, BinaryEncoding: BinaryHex}},
+		{"unknown encoding", HeaderMapping{HTTPHeader: "X-Session", GRPCMetadata: "session-bin", Direction: Incoming, BinaryEncoding: "base32"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewHeaderMapper(&Config{Mappings: []HeaderMapping{tt.mapping}}).Validate()
+			if !errors.Is(err, ErrValidationFailed) {
+				t.Errorf("Validate() error = %v, want ErrValidationFailed", err)
+			}
+		})
+	}
+}
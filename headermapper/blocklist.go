@@ -0,0 +1,84 @@
+package headermapper
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// headerBlockList holds the BlockedHeaders of a configuration: exact names
+// and prefixes of names ending in "*", matched case-insensitively against
+// HTTP headers and metadata keys
+type headerBlockList struct {
+	names    map[string]bool
+	prefixes headerTrie[struct{}]
+	prefixed bool
+}
+
+// newHeaderBlockList compiles the blocked names; it returns nil when none are
+// configured, which blocks nothing
+func newHeaderBlockList(patterns []string) *headerBlockList {
+	if len(patterns) == 0 {
+		return nil
+	}
+	b := &headerBlockList{names: make(map[string]bool)}
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			b.prefixes.insert(prefix, struct{}{})
+			b.prefixed = true
+			continue
+		}
+		b.names[strings.ToLower(pattern)] = true
+	}
+	return b
+}
+
+// blocks reports whether a header or metadata key is blocked, including the
+// Grpc-Metadata- and Grpc-Trailer- forms the gateway gives forwarded metadata
+func (b *headerBlockList) blocks(name string) bool {
+	if b == nil {
+		return false
+	}
+	if b.match(name) {
+		return true
+	}
+	for _, prefix := range gatewayPrefixes {
+		if hasLowerPrefix(name, prefix) {
+			return b.match(name[len(prefix):])
+		}
+	}
+	return false
+}
+
+func (b *headerBlockList) match(name string) bool {
+	if b.prefixed {
+		if _, ok := b.prefixes.longestPrefix(name); ok {
+			return true
+		}
+	}
+	return b.names[strings.ToLower(name)]
+}
+
+// strip removes the blocked headers from h
+func (b *headerBlockList) strip(h http.Header) {
+	if b == nil {
+		return
+	}
+	for name := range h {
+		if b.blocks(name) {
+			delete(h, name)
+		}
+	}
+}
+
+// validateBlockedHeaders checks that each entry is a header name, optionally
+// ending in "*"
+func validateBlockedHeaders(patterns []string) error {
+	for _, pattern := range patterns {
+		name := strings.TrimSuffix(pattern, "*")
+		if !validHeaderName(name) {
+			return fmt.Errorf("invalid blocked header: %q", pattern)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package headermapper
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
+)
+
+func newBlockingMapper() *HeaderMapper {
+	return NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").
+		AddIncomingMapping("X-Internal-User", "internal-user").
+		AddIncomingPrefixMapping("X-Custom-", "custom-").
+		AddOutgoingMapping("session-cookie", "Set-Cookie").
+		AddOutgoingMapping("request-id", "X-Request-ID").
+		BlockHeaders("Cookie", "Set-Cookie", "X-Internal-*", "X-Custom-Secret", "session-*").
+		Build()
+}
+
+func TestBlockHeaders_HeaderMatcher(t *testing.T) {
+	matcher := newBlockingMapper().HeaderMatcher()
+
+	tests := []struct {
+		header  string
+		allowed bool
+	}{
+		{"X-User-ID", true},
+		{"Cookie", false},
+		{"cookie", false},
+		{"X-Internal-User", false},
+		{"X-Internal-Anything", false},
+		{"Grpc-Metadata-Cookie", false},
+		{"X-Custom-Secret", false},
+		{"X-Custom-Region", true},
+		{"X-Other", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if _, ok := matcher(tt.header); ok != tt.allowed {
+				t.Errorf("HeaderMatcher(%q) = %v, want %v", tt.header, ok, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestBlockHeaders_Incoming(t *testing.T) {
+	req := httptest.NewRequest("GET", "/v1/echo", nil)
+	req.Header.Set("X-User-ID", "u")
+	req.Header.Set("X-Internal-User", "admin")
+	req.Header.Set("X-Custom-Region", "eu")
+	req.Header.Set("X-Custom-Secret", "s3cret")
+
+	md := newBlockingMapper().MetadataAnnotator()(context.Background(), req)
+	expected := metadata.Pairs("user-id", "u", "custom-region", "eu")
+	if len(md) != len(expected) {
+		t.Fatalf("metadata = %v, want %v", md, expected)
+	}
+	for key, values := range expected {
+		if got := md.Get(key); len(got) != 1 || got[0] != values[0] {
+			t.Errorf("%s = %v, want %v", key, got, values)
+		}
+	}
+}
+
+func TestBlockHeaders_Outgoing(t *testing.T) {
+	mapper := newBlockingMapper()
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{
+		HeaderMD: metadata.Pairs("session-cookie", "id=1", "request-id", "r"),
+	})
+	w := httptest.NewRecorder()
+	// Forwarded by the gateway from backend metadata
+	w.Header().Set("Grpc-Metadata-Session-Token", "t")
+	w.Header().Set("Grpc-Metadata-Tenant", "acme")
+
+	if err := mapper.ResponseModifier()(ctx, w, nil); err != nil {
+		t.Fatal(err)
+	}
+	h := w.Header()
+	if h.Get("Set-Cookie") != "" || h.Get("Grpc-Metadata-Session-Token") != "" {
+		t.Errorf("blocked headers written: %v", h)
+	}
+	if h.Get("X-Request-ID") != "r" || h.Get("Grpc-Metadata-Tenant") != "acme" {
+		t.Errorf("allowed headers missing: %v", h)
+	}
+
+	matcher := mapper.OutgoingHeaderMatcher()
+	if _, ok := matcher("session-token"); ok {
+		t.Error("OutgoingHeaderMatcher forwarded a blocked key")
+	}
+	if header, ok := matcher("tenant"); !ok || header != "Grpc-Metadata-tenant" {
+		t.Errorf("OutgoingHeaderMatcher(tenant) = %q, %v", header, ok)
+	}
+}
+
+func TestBlockHeaders_Validate(t *testing.T) {
+	for _, names := range [][]string{{"Bad Header"}, {"*"}, {"X-Ok", "X-(Bad)-*"}} {
+		if err := ValidateConfig(&Config{BlockedHeaders: names}); err == nil {
+			t.Errorf("ValidateConfig(%v) expected error", names)
+		}
+	}
+	if err := ValidateConfig(&Config{BlockedHeaders: []string{"Cookie", "X-Internal-*"}}); err != nil {
+		t.Errorf("ValidateConfig() error = %v", err)
+	}
+}
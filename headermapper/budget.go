@@ -0,0 +1,41 @@
+package headermapper
+
+import (
+	"fmt"
+	"time"
+)
+
+// validateMappingBudget checks the per-request mapping budget
+func validateMappingBudget(budget time.Duration) error {
+	if budget < 0 {
+		return fmt.Errorf("mapping budget cannot be negative")
+	}
+	return nil
+}
+
+// mappingBudget bounds the time one request or response spends mapping.
+// Transforms cannot be interrupted, so the budget is checked after each
+// transformed mapping and the remaining mappings are skipped once it runs out.
+type mappingBudget struct {
+	deadline time.Time
+}
+
+func newMappingBudget(cc *compiledConfig) mappingBudget {
+	if cc.config.MappingBudget <= 0 {
+		return mappingBudget{}
+	}
+	return mappingBudget{deadline: time.Now().Add(cc.config.MappingBudget)}
+}
+
+// exceeded reports whether the budget ran out after applying mapping. Only
+// mappings with transforms are timed, as the others cannot stall.
+func (b mappingBudget) exceeded(mapping *compiledMapping) bool {
+	return !b.deadline.IsZero() && mapping.transform != nil && time.Now().After(b.deadline)
+}
+
+// budgetExceeded records an overrun after mapping
+func (hm *HeaderMapper) budgetExceeded(cc *compiledConfig, mapping *compiledMapping) {
+	hm.budgetOverruns.Add(1)
+	hm.log().Warn("Mapping budget of", cc.config.MappingBudget, "exceeded after", mapping.header,
+		"; skipping remaining mappings")
+}
@@ -0,0 +1,87 @@
+package headermapper
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
+)
+
+func slowTransform(value string) string {
+	time.Sleep(2 * time.Millisecond)
+	return value
+}
+
+func TestMappingBudget_Incoming(t *testing.T) {
+	tests := []struct {
+		name       string
+		budget     time.Duration
+		wantTenant bool
+		overruns   int64
+	}{
+		{"disabled", 0, true, 0},
+		{"within budget", time.Minute, true, 0},
+		{"exceeded", time.Millisecond, false, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper := NewBuilder().
+				AddIncomingMapping("X-User-ID", "user-id").
+				WithDefault("anonymous").
+				WithTransform(slowTransform).
+				AddIncomingMapping("X-Tenant-ID", "tenant-id").
+				WithDefault("default").
+				MappingBudget(tt.budget).
+				Build()
+
+			md := mapper.MetadataAnnotator()(context.Background(), httptest.NewRequest("GET", "/api/test", nil))
+			if len(md.Get("user-id")) != 1 {
+				t.Errorf("user-id missing from %v", md)
+			}
+			if got := len(md.Get("tenant-id")) == 1; got != tt.wantTenant {
+				t.Errorf("tenant-id mapped = %v, want %v", got, tt.wantTenant)
+			}
+			if got := mapper.GetStats().BudgetOverruns; got != tt.overruns {
+				t.Errorf("BudgetOverruns = %d, want %d", got, tt.overruns)
+			}
+		})
+	}
+}
+
+func TestMappingBudget_Outgoing(t *testing.T) {
+	mapper := NewBuilder().
+		AddOutgoingMapping("processing-time", "X-Processing-Time").
+		WithDefault("0").
+		WithTransform(slowTransform).
+		AddOutgoingMapping("request-id", "X-Request-ID").
+		WithDefault("none").
+		MappingBudget(time.Millisecond).
+		Build()
+
+	w := httptest.NewRecorder()
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{HeaderMD: metadata.MD{}})
+	if err := mapper.ResponseModifier()(ctx, w, nil); err != nil {
+		t.Fatalf("ResponseModifier() error = %v", err)
+	}
+
+	if w.Header().Get("X-Processing-Time") != "0" || w.Header().Get("X-Request-ID") != "" {
+		t.Errorf("headers = %v", w.Header())
+	}
+	if got := mapper.GetStats().BudgetOverruns; got != 1 {
+		t.Errorf("BudgetOverruns = %d, want 1", got)
+	}
+}
+
+func TestMappingBudget_Negative(t *testing.T) {
+	config := NewConfigBuilder().
+		AddMapping(HeaderMapping{HTTPHeader: "X-User-ID", GRPCMetadata: "user-id"}).
+		WithMappingBudget(-time.Millisecond).
+		Build()
+	if err := ValidateConfig(config); err == nil {
+		t.Error("ValidateConfig() error = nil, want negative budget error")
+	}
+}
@@ -0,0 +1,174 @@
+package headermapper
+
+import (
+	"container/list"
+	"fmt"
+	"hash/maphash"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TransformCacheConfig configures the cache shared by mappings with
+// CacheTransform set
+type TransformCacheConfig struct {
+	// Size bounds the number of cached results (default 1024)
+	Size int `json:"size" yaml:"size"`
+	// TTL bounds the age of cached results (default 5m)
+	TTL time.Duration `json:"ttl" yaml:"ttl"`
+}
+
+// validate checks the size and TTL
+func (tc *TransformCacheConfig) validate() error {
+	if tc.Size < 0 {
+		return fmt.Errorf("transform cache: size cannot be negative")
+	}
+	if tc.TTL < 0 {
+		return fmt.Errorf("transform cache: ttl cannot be negative")
+	}
+	return nil
+}
+
+// cacheShardSize is the number of entries per shard above which the cache is
+// split to reduce lock contention
+const cacheShardSize = 256
+
+// maxCacheShards bounds the number of shards
+const maxCacheShards = 16
+
+// transformIDs numbers wrapped transforms so they can share a cache
+var transformIDs atomic.Uint64
+
+// TransformCache is a concurrent LRU cache of transform results with a TTL.
+// It suits deterministic, expensive transforms such as user agent parsing or
+// JWT claim extraction where a few distinct values dominate the traffic.
+type TransformCache struct {
+	shards []cacheShard
+	seed   maphash.Seed
+	ttl    time.Duration
+	now    func() time.Time
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// cacheShard is an independently locked LRU segment of a TransformCache
+type cacheShard struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[cacheKey]*list.Element
+	order    list.List
+}
+
+type cacheKey struct {
+	transform uint64
+	input     string
+}
+
+type cacheEntry struct {
+	key     cacheKey
+	value   string
+	expires time.Time
+}
+
+// NewTransformCache creates a cache holding up to size results for ttl;
+// non-positive values select the defaults of TransformCacheConfig
+func NewTransformCache(size int, ttl time.Duration) *TransformCache {
+	if size <= 0 {
+		size = 1024
+	}
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+
+	n := min(maxCacheShards, max(1, size/cacheShardSize))
+	c := &TransformCache{
+		shards: make([]cacheShard, n),
+		seed:   maphash.MakeSeed(),
+		ttl:    ttl,
+		now:    time.Now,
+	}
+	for i := range c.shards {
+		c.shards[i].capacity = (size + n - 1) / n
+		c.shards[i].entries = make(map[cacheKey]*list.Element)
+	}
+	return c
+}
+
+// Wrap returns a transform that serves results of transform from the cache
+func (c *TransformCache) Wrap(transform TransformFunc) TransformFunc {
+	id := transformIDs.Add(1)
+	return func(value string) string {
+		key := cacheKey{transform: id, input: value}
+		shard := &c.shards[maphash.String(c.seed, value)%uint64(len(c.shards))]
+
+		if result, ok := shard.get(key, c.now()); ok {
+			c.hits.Add(1)
+			return result
+		}
+		c.misses.Add(1)
+
+		result := transform(value)
+		shard.put(key, result, c.now().Add(c.ttl))
+		return result
+	}
+}
+
+// Len returns the number of cached results, including expired ones not yet evicted
+func (c *TransformCache) Len() int {
+	n := 0
+	for i := range c.shards {
+		shard := &c.shards[i]
+		shard.mu.Lock()
+		n += shard.order.Len()
+		shard.mu.Unlock()
+	}
+	return n
+}
+
+// Hits returns the number of lookups served from the cache
+func (c *TransformCache) Hits() int64 {
+	return c.hits.Load()
+}
+
+// Misses returns the number of lookups that ran the transform
+func (c *TransformCache) Misses() int64 {
+	return c.misses.Load()
+}
+
+func (s *cacheShard) get(key cacheKey, now time.Time) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.entries[key]
+	if !ok {
+		return "", false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if now.After(entry.expires) {
+		s.order.Remove(elem)
+		delete(s.entries, key)
+		return "", false
+	}
+	s.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+func (s *cacheShard) put(key cacheKey, value string, expires time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.entries[key]; ok {
+		entry := elem.Value.(*cacheEntry)
+		entry.value, entry.expires = value, expires
+		s.order.MoveToFront(elem)
+		return
+	}
+
+	s.entries[key] = s.order.PushFront(&cacheEntry{key: key, value: value, expires: expires})
+	if s.order.Len() > s.capacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
@@ -0,0 +1,139 @@
+package headermapper
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTransformCache(t *testing.T) {
+	calls := 0
+	cache := NewTransformCache(2, time.Minute)
+	transform := cache.Wrap(func(value string) string {
+		calls++
+		return "t:" + value
+	})
+
+	tests := []struct {
+		input    string
+		expected string
+		calls    int
+	}{
+		{"a", "t:a", 1},
+		{"a", "t:a", 1},
+		{"b", "t:b", 2},
+		{"a", "t:a", 2},
+		// Evicts b, the least recently used
+		{"c", "t:c", 3},
+		{"a", "t:a", 3},
+		{"b", "t:b", 4},
+	}
+
+	for _, tt := range tests {
+		if got := transform(tt.input); got != tt.expected || calls != tt.calls {
+			t.Errorf("transform(%q) = %q after %d calls, want %q after %d", tt.input, got, calls, tt.expected, tt.calls)
+		}
+	}
+	if cache.Len() != 2 || cache.Hits() != 3 || cache.Misses() != 4 {
+		t.Errorf("Len = %d, Hits = %d, Misses = %d", cache.Len(), cache.Hits(), cache.Misses())
+	}
+}
+
+func TestTransformCache_TTL(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	cache := NewTransformCache(10, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	calls := 0
+	transform := cache.Wrap(func(value string) string {
+		calls++
+		return value
+	})
+
+	transform("a")
+	now = now.Add(30 * time.Second)
+	transform("a")
+	if calls != 1 {
+		t.Errorf("calls within TTL = %d, want 1", calls)
+	}
+
+	now = now.Add(2 * time.Minute)
+	transform("a")
+	if calls != 2 {
+		t.Errorf("calls after TTL = %d, want 2", calls)
+	}
+}
+
+func TestTransformCache_SeparatesTransforms(t *testing.T) {
+	cache := NewTransformCache(10, time.Minute)
+	upper := cache.Wrap(ToUpper)
+	lower := cache.Wrap(ToLower)
+
+	if upper("Mixed") != "MIXED" || lower("Mixed") != "mixed" {
+		t.Error("wrapped transforms share cached results")
+	}
+}
+
+func TestTransformCache_Concurrent(t *testing.T) {
+	cache := NewTransformCache(1024, time.Minute)
+	transform := cache.Wrap(ToUpper)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				value := fmt.Sprintf("v%d", j%50)
+				if got := transform(value); got != ToUpper(value) {
+					t.Errorf("transform(%q) = %q", value, got)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if cache.Len() != 50 {
+		t.Errorf("Len = %d, want 50", cache.Len())
+	}
+}
+
+func TestHeaderMapper_CachedTransform(t *testing.T) {
+	calls := 0
+	mapper := NewBuilder().
+		AddIncomingMapping("User-Agent", "client").
+		WithCachedTransform(func(value string) string {
+			calls++
+			return SanitizeUserAgent(value)
+		}).
+		TransformCache(100, time.Minute).
+		Build()
+
+	annotator := mapper.MetadataAnnotator()
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("GET", "/api/test", nil)
+		req.Header.Set("User-Agent", "Mozilla/5.0 Chrome/120.0.1")
+		md := annotator(context.Background(), req)
+		if got := md.Get("client"); len(got) != 1 || got[0] != "Mozilla/x.x.x Chrome/x.x.x" {
+			t.Fatalf("client = %v", got)
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("transform calls = %d, want 1", calls)
+	}
+	stats := mapper.GetStats()
+	if stats.TransformCacheHits != 2 || stats.TransformCacheMisses != 1 || stats.TransformCacheEntries != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestTransformCacheConfig_Validate(t *testing.T) {
+	if err := ValidateConfig(&Config{TransformCache: &TransformCacheConfig{Size: -1}}); err == nil {
+		t.Error("ValidateConfig() accepted a negative cache size")
+	}
+}
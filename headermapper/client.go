@@ -0,0 +1,112 @@
+package headermapper
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// credentialHeaders are the canonical HTTP headers carrying credentials
+var credentialHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"X-Api-Key":           true,
+}
+
+// credentialKeys are the metadata keys commonly carrying credentials
+var credentialKeys = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"x-api-key":           true,
+	"api-key":             true,
+	"auth-token":          true,
+}
+
+// UnaryClientInterceptor creates a gRPC unary client interceptor that
+// propagates the mapped metadata of the incoming call, such as request-id or
+// tenant-id, to calls the service makes to other services. Credentials are
+// not propagated unless listed with WithPropagatedKeys. With Timeout
+// configured, the deadline of the call is sent in the timeout header.
+//
+//	conn, err := grpc.NewClient(target, grpc.WithUnaryInterceptor(mapper.UnaryClientInterceptor()))
+func (hm *HeaderMapper) UnaryClientInterceptor(opts ...InterceptorOption) grpc.UnaryClientInterceptor {
+	o := hm.interceptorOptions(opts)
+	return func(ctx context.Context, method string, req, reply interface{}, conn *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(hm.propagate(hm.sendDeadline(ctx), method, o.propagated), method, req, reply, conn, opts...)
+	}
+}
+
+// StreamClientInterceptor creates a gRPC stream client interceptor that
+// propagates the mapped metadata of the incoming call to outgoing streams
+func (hm *HeaderMapper) StreamClientInterceptor(opts ...InterceptorOption) grpc.StreamClientInterceptor {
+	o := hm.interceptorOptions(opts)
+	return func(ctx context.Context, desc *grpc.StreamDesc, conn *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(hm.propagate(hm.sendDeadline(ctx), method, o.propagated), desc, conn, method, opts...)
+	}
+}
+
+// propagate copies the keys of the incoming mappings from the incoming to
+// the outgoing metadata of ctx: those in allowed when set, otherwise all but
+// credentials, such as the keys of Authorization mappings. Values the caller
+// already set are kept, and mappings with a Generator fill in missing values,
+// so calls started outside a request still carry e.g. a request ID. Keys of
+// pattern mappings cannot be known in advance and are not propagated.
+func (hm *HeaderMapper) propagate(ctx context.Context, method string, allowed map[string]bool) context.Context {
+	cc, release := hm.requestState(ctx)
+	defer release()
+	if cc.skipPaths[method] {
+		return ctx
+	}
+	idx := cc.index
+	in, _ := metadata.FromIncomingContext(ctx)
+	// FromOutgoingContext returns a copy, so it may be extended in place
+	out, _ := metadata.FromOutgoingContext(ctx)
+
+	added := false
+	set := func(key string, values []string) {
+		if len(values) == 0 || len(out[key]) > 0 || hm.reservedKeys[key] || idx.blocked.blocks(key) {
+			return
+		}
+		if allowed != nil && !allowed[key] || allowed == nil && credentialKeys[key] {
+			return
+		}
+		if out == nil {
+			out = metadata.MD{}
+		}
+		out[key] = values
+		added = true
+	}
+
+	for _, mappings := range [][]compiledMapping{idx.incoming, idx.incomingParams} {
+		for i := range mappings {
+			mapping := &mappings[i]
+			if allowed == nil && credentialHeaders[mapping.header] {
+				continue
+			}
+			if values := in[mapping.key]; len(values) > 0 {
+				set(mapping.key, values)
+			} else if generated := mapping.generate(); generated != "" {
+				set(mapping.key, []string{generated})
+			}
+		}
+	}
+	if len(idx.incomingPrefix) > 0 {
+		for key, values := range in {
+			for i := range idx.incomingPrefix {
+				if prefix := idx.incomingPrefix[i].key; len(key) > len(prefix) && strings.HasPrefix(key, prefix) {
+					set(key, values)
+					break
+				}
+			}
+		}
+	}
+
+	if !added {
+		return ctx
+	}
+	return metadata.NewOutgoingContext(ctx, out)
+}
@@ -0,0 +1,145 @@
+package headermapper
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestClientInterceptors(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-Request-ID", "request-id").
+		WithGenerator(func() string { return "generated" }).
+		AddIncomingMapping("X-Tenant-ID", "tenant-id").
+		AddIncomingPrefixMapping("X-Custom-", "custom-").
+		SkipPaths("/grpc.health.v1.Health/Check").
+		Build()
+
+	tests := []struct {
+		name     string
+		method   string
+		incoming metadata.MD
+		outgoing metadata.MD
+		want     map[string]string
+	}{
+		{
+			name:     "propagates mapped keys",
+			method:   "/users.v1.Users/Get",
+			incoming: metadata.Pairs("request-id", "req-1", "tenant-id", "acme", "custom-region", "eu", "authorization", "secret"),
+			want:     map[string]string{"request-id": "req-1", "tenant-id": "acme", "custom-region": "eu", "authorization": ""},
+		},
+		{
+			name:     "keeps caller values",
+			method:   "/users.v1.Users/Get",
+			incoming: metadata.Pairs("tenant-id", "acme"),
+			outgoing: metadata.Pairs("tenant-id", "other"),
+			want:     map[string]string{"tenant-id": "other", "request-id": "generated"},
+		},
+		{
+			name:   "generates outside a request",
+			method: "/users.v1.Users/Get",
+			want:   map[string]string{"request-id": "generated", "tenant-id": ""},
+		},
+		{
+			name:     "skip path",
+			method:   "/grpc.health.v1.Health/Check",
+			incoming: metadata.Pairs("request-id", "req-1"),
+			want:     map[string]string{"request-id": ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.incoming != nil {
+				ctx = metadata.NewIncomingContext(ctx, tt.incoming)
+			}
+			if tt.outgoing != nil {
+				ctx = metadata.NewOutgoingContext(ctx, tt.outgoing)
+			}
+
+			check := func(ctx context.Context) {
+				md, _ := metadata.FromOutgoingContext(ctx)
+				for key, want := range tt.want {
+					var got string
+					if values := md.Get(key); len(values) > 0 {
+						got = values[0]
+					}
+					if got != want {
+						t.Errorf("%s = %q, want %q", key, got, want)
+					}
+				}
+			}
+
+			unary := mapper.UnaryClientInterceptor()
+			err := unary(ctx, tt.method, nil, nil, nil, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				check(ctx)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			stream := mapper.StreamClientInterceptor()
+			_, err = stream(ctx, &grpc.StreamDesc{}, nil, tt.method, func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+				check(ctx)
+				return nil, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestClientInterceptors_Credentials(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-Request-ID", "request-id").
+		AddIncomingMapping("X-Tenant-ID", "tenant-id").
+		AddIncomingMapping("Authorization", "user-token").
+		AddIncomingMapping("X-Session", "auth-token").
+		Build()
+	incoming := metadata.Pairs("request-id", "req-1", "tenant-id", "acme", "user-token", "Bearer t", "auth-token", "s")
+
+	tests := []struct {
+		name string
+		opts []InterceptorOption
+		want map[string]string
+	}{
+		{
+			name: "credentials excluded by default",
+			want: map[string]string{"request-id": "req-1", "tenant-id": "acme", "user-token": "", "auth-token": ""},
+		},
+		{
+			name: "allow-list",
+			opts: []InterceptorOption{WithPropagatedKeys("Request-ID")},
+			want: map[string]string{"request-id": "req-1", "tenant-id": "", "user-token": "", "auth-token": ""},
+		},
+		{
+			name: "credentials listed",
+			opts: []InterceptorOption{WithPropagatedKeys("request-id", "user-token")},
+			want: map[string]string{"request-id": "req-1", "tenant-id": "", "user-token": "Bearer t", "auth-token": ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), incoming)
+			unary := mapper.UnaryClientInterceptor(tt.opts...)
+			err := unary(ctx, "/users.v1.Users/Get", nil, nil, nil, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				md, _ := metadata.FromOutgoingContext(ctx)
+				for key, want := range tt.want {
+					if got := firstValue(md, key); got != want {
+						t.Errorf("%s = %q, want %q", key, got, want)
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package headermapper
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// forwardedHeaders are only honored from trusted proxies when TrustedProxies
+// is set; keys are canonical header names
+var forwardedHeaders = map[string]bool{
+	"X-Forwarded-For":   true,
+	"X-Forwarded-Proto": true,
+	"X-Forwarded-Host":  true,
+	"X-Real-Ip":         true,
+	"Forwarded":         true,
+}
+
+// ClientIP returns the IP address of the client that originated the request,
+// taken from X-Forwarded-For, then X-Real-IP, then the connection's remote address.
+// It trusts the forwarded headers unconditionally; use HeaderMapper.ClientIP
+// to honor them only from trusted proxies.
+func ClientIP(req *http.Request) net.IP {
+	if forwarded := req.Header.Get("X-Forwarded-For"); forwarded != "" {
+		first, _, _ := strings.Cut(forwarded, ",")
+		if ip := net.ParseIP(strings.TrimSpace(first)); ip != nil {
+			return ip
+		}
+	}
+
+	if realIP := req.Header.Get("X-Real-IP"); realIP != "" {
+		if ip := net.ParseIP(strings.TrimSpace(realIP)); ip != nil {
+			return ip
+		}
+	}
+
+	return remoteIP(req.RemoteAddr)
+}
+
+// ClientIP returns the IP address of the client that originated the request.
+// When TrustedProxies is configured, forwarded headers are only honored if the
+// direct peer is a trusted proxy, and X-Forwarded-For is read from the right,
+// skipping trusted proxies, so clients cannot spoof their address.
+func (hm *HeaderMapper) ClientIP(req *http.Request) net.IP {
+	if len(hm.trustedProxies) == 0 {
+		return ClientIP(req)
+	}
+
+	peer := remoteIP(req.RemoteAddr)
+	if peer == nil || hm.trustedProxies.match(peer) == nil {
+		return peer
+	}
+
+	hops := req.Header.Values("X-Forwarded-For")
+	var entries []string
+	for _, hop := range hops {
+		entries = append(entries, strings.Split(hop, ",")...)
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(entries[i]))
+		if ip == nil {
+			break
+		}
+		if hm.trustedProxies.match(ip) == nil {
+			return ip
+		}
+	}
+
+	if ip := net.ParseIP(strings.TrimSpace(req.Header.Get("X-Real-IP"))); ip != nil {
+		return ip
+	}
+	return peer
+}
+
+// trustForwarded reports whether the forwarded headers of req may be honored
+func (hm *HeaderMapper) trustForwarded(req *http.Request) bool {
+	if len(hm.trustedProxies) == 0 {
+		return true
+	}
+	peer := remoteIP(req.RemoteAddr)
+	return peer != nil && hm.trustedProxies.match(peer) != nil
+}
+
+// forwardedHeaderValue returns the value of a forwarded header for mapping,
+// substituting the connection's own values when the peer is not trusted;
+// header is the canonical name
+func (hm *HeaderMapper) forwardedHeaderValue(req *http.Request, header string) string {
+	if hm.trustForwarded(req) {
+		if values := req.Header[header]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	switch header {
+	case "X-Forwarded-For", "X-Real-Ip":
+		if ip := remoteIP(req.RemoteAddr); ip != nil {
+			return ip.String()
+		}
+	case "X-Forwarded-Proto":
+		if req.TLS != nil {
+			return "https"
+		}
+		return "http"
+	case "X-Forwarded-Host":
+		return req.Host
+	}
+	return ""
+}
+
+// remoteIP parses the IP from a host:port address
+func remoteIP(addr string) net.IP {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	return net.ParseIP(host)
+}
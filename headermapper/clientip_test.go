@@ -0,0 +1,81 @@
+package headermapper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHeaderMapper_ClientIP_TrustedProxies(t *testing.T) {
+	mapper := NewBuilder().TrustedProxies("10.0.0.0/8", "192.168.1.1").Build()
+
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		remote   string
+		expected string
+	}{
+		{"untrusted peer ignores forwarded", map[string]string{"X-Forwarded-For": "203.0.113.5"}, "198.51.100.9:1234", "198.51.100.9"},
+		{"untrusted peer ignores real ip", map[string]string{"X-Real-IP": "203.0.113.5"}, "198.51.100.9:1234", "198.51.100.9"},
+		{"trusted peer", map[string]string{"X-Forwarded-For": "203.0.113.5"}, "10.0.0.2:1234", "203.0.113.5"},
+		{"spoofed leftmost entry", map[string]string{"X-Forwarded-For": "1.2.3.4, 203.0.113.5, 10.0.0.7"}, "10.0.0.2:1234", "203.0.113.5"},
+		{"trusted single ip", map[string]string{"X-Forwarded-For": "203.0.113.5"}, "192.168.1.1:80", "203.0.113.5"},
+		{"only proxies", map[string]string{"X-Forwarded-For": "10.0.0.9"}, "10.0.0.2:1234", "10.0.0.2"},
+		{"trusted real ip", map[string]string{"X-Real-IP": "203.0.113.7"}, "10.0.0.2:1234", "203.0.113.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remote
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			if got := mapper.ClientIP(req); got.String() != tt.expected {
+				t.Errorf("ClientIP() = %v, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTrustedProxies_ForwardedMappings(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-Forwarded-For", "client-addr").
+		AddIncomingMapping("X-Forwarded-Proto", "client-proto").
+		AddIncomingMapping("X-Forwarded-Host", "client-host").
+		TrustedProxies("10.0.0.0/8").
+		Build()
+
+	newRequest := func(remote string) *http.Request {
+		req := httptest.NewRequest("GET", "http://api.example.com/v1/echo", nil)
+		req.RemoteAddr = remote
+		req.Header.Set("X-Forwarded-For", "203.0.113.5")
+		req.Header.Set("X-Forwarded-Proto", "https")
+		req.Header.Set("X-Forwarded-Host", "spoofed.example.com")
+		return req
+	}
+
+	md := mapper.MetadataAnnotator()(context.Background(), newRequest("10.0.0.2:1234"))
+	if md.Get("client-addr")[0] != "203.0.113.5" || md.Get("client-host")[0] != "spoofed.example.com" {
+		t.Errorf("trusted proxy headers not honored: %v", md)
+	}
+
+	md = mapper.MetadataAnnotator()(context.Background(), newRequest("198.51.100.9:1234"))
+	expected := map[string]string{
+		"client-addr":  "198.51.100.9",
+		"client-proto": "http",
+		"client-host":  "api.example.com",
+	}
+	for key, value := range expected {
+		if got := md.Get(key); len(got) != 1 || got[0] != value {
+			t.Errorf("%s = %v, want %s", key, got, value)
+		}
+	}
+}
+
+func TestTrustedProxies_Validate(t *testing.T) {
+	if err := ValidateConfig(&Config{TrustedProxies: []string{"10.0.0.0/33"}}); err == nil {
+		t.Error("ValidateConfig() expected error for invalid CIDR")
+	}
+}
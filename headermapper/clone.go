@@ -0,0 +1,44 @@
+package headermapper
+
+import "slices"
+
+// Clone returns a new mapper with the current configuration and logger of
+// hm. Per-mapper state such as statistics, transform caches and in-memory
+// replay and rate limit stores starts empty; stores passed in the
+// configuration are shared.
+func (hm *HeaderMapper) Clone() *HeaderMapper {
+	return hm.derive(cloneConfig(hm.state().config))
+}
+
+// Extend returns a new mapper with the configuration of hm plus the extra
+// mappings, so a shared base mapper can be customized per service. The extra
+// mappings are applied after the base ones, following the usual rules for
+// mappings targeting the same key or header. hm is not modified.
+func (hm *HeaderMapper) Extend(extra ...HeaderMapping) *HeaderMapper {
+	config := cloneConfig(hm.state().config)
+	config.Mappings = append(config.Mappings, extra...)
+	return hm.derive(config)
+}
+
+// derive builds a mapper from config with the logger of hm
+func (hm *HeaderMapper) derive(config *Config) *HeaderMapper {
+	derived := NewHeaderMapper(config)
+	derived.SetLogger(hm.log())
+	return derived
+}
+
+// cloneConfig copies config so the copy's slices can be modified without
+// affecting it; policy configurations are shared since they are not
+// modified after construction
+func cloneConfig(config *Config) *Config {
+	clone := *config
+	clone.Mappings = slices.Clone(config.Mappings)
+	for i := range clone.Mappings {
+		clone.Mappings[i].Aliases = slices.Clone(clone.Mappings[i].Aliases)
+	}
+	clone.SkipPaths = slices.Clone(config.SkipPaths)
+	clone.InternalNamespaces = slices.Clone(config.InternalNamespaces)
+	clone.TrustedProxies = slices.Clone(config.TrustedProxies)
+	clone.Composites = slices.Clone(config.Composites)
+	return &clone
+}
@@ -0,0 +1,80 @@
+package headermapper
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHeaderMapper_Extend(t *testing.T) {
+	base := NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").
+		SkipPaths("/health").
+		Build()
+
+	extended := base.Extend(
+		HeaderMapping{HTTPHeader: "X-Tenant-ID", GRPCMetadata: "tenant-id", Direction: Incoming},
+		HeaderMapping{HTTPHeader: "X-Region", GRPCMetadata: "region", Direction: Incoming, DefaultValue: "us-east-1"},
+	)
+
+	req := httptest.NewRequest("GET", "/api/test", nil)
+	req.Header.Set("X-User-ID", "12345")
+	req.Header.Set("X-Tenant-ID", "acme")
+
+	tests := []struct {
+		name   string
+		mapper *HeaderMapper
+		want   map[string]string
+	}{
+		{"base", base, map[string]string{"user-id": "12345"}},
+		{"extended", extended, map[string]string{"user-id": "12345", "tenant-id": "acme", "region": "us-east-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			md := tt.mapper.MetadataAnnotator()(context.Background(), req)
+			if len(md) != len(tt.want) {
+				t.Errorf("metadata = %v, want %v", md, tt.want)
+			}
+			for key, want := range tt.want {
+				if got := md.Get(key); len(got) != 1 || got[0] != want {
+					t.Errorf("%s = %v, want %s", key, got, want)
+				}
+			}
+			if md := tt.mapper.MetadataAnnotator()(context.Background(), httptest.NewRequest("GET", "/health", nil)); md != nil {
+				t.Errorf("skip path metadata = %v", md)
+			}
+		})
+	}
+}
+
+func TestHeaderMapper_Clone(t *testing.T) {
+	logger := NoOpLogger{}
+	base := NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").
+		Build()
+	base.SetLogger(logger)
+
+	clone := base.Clone()
+	if clone == base || clone.state().config == base.state().config {
+		t.Fatal("Clone() shares the mapper or its configuration")
+	}
+	if clone.log() != logger {
+		t.Errorf("logger = %v", clone.log())
+	}
+
+	// Updating the clone leaves the base untouched
+	config := cloneConfig(clone.state().config)
+	config.Mappings[0].GRPCMetadata = "uid"
+	if err := clone.UpdateConfig(config); err != nil {
+		t.Fatalf("UpdateConfig() error = %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/test", nil)
+	req.Header.Set("X-User-ID", "12345")
+	if md := base.MetadataAnnotator()(context.Background(), req); len(md.Get("user-id")) != 1 {
+		t.Errorf("base metadata = %v", md)
+	}
+	if md := clone.MetadataAnnotator()(context.Background(), req); len(md.Get("uid")) != 1 || len(md.Get("user-id")) != 0 {
+		t.Errorf("clone metadata = %v", md)
+	}
+}
@@ -0,0 +1,182 @@
+package headermapper
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// compiledMapping is the ready-to-run form of a HeaderMapping, built once by
+// NewHeaderMapper so the hot path does no normalization or compilation
+type compiledMapping struct {
+	// header is the canonical HTTP header name
+	header string
+	// lowerHeader is the lowercase HTTP header name
+	lowerHeader string
+	// key is the lowercase metadata key
+	key string
+
+	defaultValue string
+	required     bool
+	generator    func() string
+	appendValues bool
+
+	// transform is the resolved transform chain; nil passes values through
+	transform TransformFunc
+
+	// forwarded and internal classify the header for per-request handling
+	forwarded bool
+	internal  bool
+
+	// aliases holds the canonical legacy names of the header
+	aliases    []string
+	deprecated bool
+
+	// fromHeader and fromTrailer select the response metadata an outgoing
+	// mapping reads
+	fromHeader  bool
+	fromTrailer bool
+	// binary is the encoding of the header values of binary keys, which are
+	// decoded into metadata and encoded into headers; "" for other keys
+	binary BinaryEncoding
+
+	// prefix is set for wildcard mappings, whose header and key are prefixes
+	// of the names they map
+	prefix bool
+	// pattern matches the header names of a pattern mapping, whose key is
+	// then a template expanded with the capture groups
+	pattern *regexp.Regexp
+
+	// queryParam and pathParam name the URL parameter a parameter mapping
+	// reads; stripParam removes the query parameter once mapped
+	queryParam string
+	pathParam  string
+	stripParam bool
+
+	// source is the index of the incoming header this mapping reads
+	source int
+	// position is the rank of an outgoing mapping in the configured order
+	position int
+
+	// counter records the outcomes of the mapping
+	counter *mappingCounter
+}
+
+// compileMapping validates a mapping and normalizes its names
+func compileMapping(mapping HeaderMapping) (compiledMapping, error) {
+	if mapping.HTTPHeaderPattern != "" {
+		return compilePatternMapping(mapping)
+	}
+	if isPrefixMapping(mapping) {
+		return compilePrefixMapping(mapping)
+	}
+	if isParamMapping(mapping) {
+		return compileParamMapping(mapping)
+	}
+	if !validHeaderName(mapping.HTTPHeader) {
+		return compiledMapping{}, newMappingError(mapping, ErrValidationFailed, "invalid HTTP header name")
+	}
+	key := strings.ToLower(mapping.GRPCMetadata)
+	if !validMetadataKey(key) {
+		return compiledMapping{}, newMappingError(mapping, ErrValidationFailed, "invalid gRPC metadata key")
+	}
+
+	if err := mapping.Source.validate(); err != nil {
+		return compiledMapping{}, newMappingError(mapping, ErrValidationFailed, err.Error())
+	}
+	if err := mapping.BinaryEncoding.validate(key); err != nil {
+		return compiledMapping{}, newMappingError(mapping, ErrValidationFailed, err.Error())
+	}
+	transform, err := mappingTransform(mapping)
+	if err != nil {
+		return compiledMapping{}, err
+	}
+
+	var aliases []string
+	for _, alias := range mapping.Aliases {
+		if !validHeaderName(alias) {
+			return compiledMapping{}, newMappingError(mapping, ErrValidationFailed, "invalid alias "+alias)
+		}
+		aliases = appendUnique(aliases, http.CanonicalHeaderKey(alias))
+	}
+
+	header := http.CanonicalHeaderKey(mapping.HTTPHeader)
+	return compiledMapping{
+		header:       header,
+		lowerHeader:  strings.ToLower(header),
+		key:          key,
+		defaultValue: mapping.DefaultValue,
+		generator:    mapping.Generator,
+		required:     mapping.Required,
+		appendValues: mapping.AppendValues,
+		transform:    transform,
+		forwarded:    forwardedHeaders[header],
+		aliases:      aliases,
+		deprecated:   mapping.Deprecated,
+		fromHeader:   mapping.Source != SourceTrailer,
+		fromTrailer:  mapping.Source == SourceTrailer || mapping.Source == SourceBoth,
+		binary:       binaryEncoding(key, mapping.BinaryEncoding),
+	}, nil
+}
+
+// generate returns a generated value, or "" without a generator
+func (m *compiledMapping) generate() string {
+	if m.generator == nil {
+		return ""
+	}
+	return m.generator()
+}
+
+// compileMappings compiles all mappings, reporting the first invalid one
+func compileMappings(mappings []HeaderMapping) error {
+	for i, mapping := range mappings {
+		if _, err := compileMapping(mapping); err != nil {
+			return fmt.Errorf("mapping %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// validHeaderName reports whether name is a non-empty RFC 9110 token
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// validMetadataKey reports whether key is a valid lowercase gRPC metadata key
+func validMetadataKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if !('a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// CompileRegexReplace returns a transform performing regex-based replacement,
+// or an error if pattern does not compile
+func CompileRegexReplace(pattern, replacement string) (TransformFunc, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("regex replace: %w", err)
+	}
+	return func(value string) string {
+		return re.ReplaceAllString(value, replacement)
+	}, nil
+}
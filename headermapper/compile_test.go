@@ -0,0 +1,79 @@
+package headermapper
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompileMapping(t *testing.T) {
+	tests := []struct {
+		name     string
+		mapping  HeaderMapping
+		header   string
+		key      string
+		hasError bool
+	}{
+		{"normalizes names", HeaderMapping{HTTPHeader: "x-user-id", GRPCMetadata: "User-ID"}, "X-User-Id", "user-id", false},
+		{"forwarded header", HeaderMapping{HTTPHeader: "x-forwarded-for", GRPCMetadata: "client-ip"}, "X-Forwarded-For", "client-ip", false},
+		{"empty header", HeaderMapping{GRPCMetadata: "user-id"}, "", "", true},
+		{"header with space", HeaderMapping{HTTPHeader: "X User", GRPCMetadata: "user-id"}, "", "", true},
+		{"header with colon", HeaderMapping{HTTPHeader: "X-User:", GRPCMetadata: "user-id"}, "", "", true},
+		{"empty key", HeaderMapping{HTTPHeader: "X-User-ID"}, "", "", true},
+		{"key with slash", HeaderMapping{HTTPHeader: "X-User-ID", GRPCMetadata: "user/id"}, "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compiled, err := compileMapping(tt.mapping)
+			if (err != nil) != tt.hasError {
+				t.Fatalf("compileMapping() error = %v, hasError %v", err, tt.hasError)
+			}
+			if err != nil {
+				return
+			}
+			if compiled.header != tt.header || compiled.key != tt.key {
+				t.Errorf("compiled = %q -> %q, want %q -> %q", compiled.header, compiled.key, tt.header, tt.key)
+			}
+			if compiled.forwarded != (tt.header == "X-Forwarded-For") {
+				t.Errorf("forwarded = %v", compiled.forwarded)
+			}
+		})
+	}
+}
+
+func TestBuildAndValidate_InvalidMapping(t *testing.T) {
+	_, err := NewBuilder().
+		AddIncomingMapping("X-User-ID", "user id").
+		BuildAndValidate()
+	if err == nil || !strings.Contains(err.Error(), "mapping 0") {
+		t.Errorf("BuildAndValidate() error = %v, want mapping 0 error", err)
+	}
+}
+
+func TestLoadConfigFromFile_InvalidMapping(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := "mappings:\n  - http_header: \"X User\"\n    grpc_metadata: user-id\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadConfigFromFile(path); err == nil {
+		t.Error("LoadConfigFromFile() accepted an invalid header name")
+	}
+}
+
+func TestCompileRegexReplace(t *testing.T) {
+	transform, err := CompileRegexReplace(`\d+`, "#")
+	if err != nil {
+		t.Fatalf("CompileRegexReplace() error = %v", err)
+	}
+	if got := transform("user-123"); got != "user-#" {
+		t.Errorf("transform = %q, want %q", got, "user-#")
+	}
+
+	if _, err := CompileRegexReplace(`(`, "#"); err == nil {
+		t.Error("CompileRegexReplace() accepted an invalid pattern")
+	}
+}
@@ -0,0 +1,214 @@
+package headermapper
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// CompositeMissing determines how a composite mapping handles headers of its
+// template that the request does not send and that have no default
+type CompositeMissing string
+
+const (
+	// CompositeMissingSkip sets no value (default)
+	CompositeMissingSkip CompositeMissing = "skip"
+	// CompositeMissingEmpty substitutes an empty string
+	CompositeMissingEmpty CompositeMissing = "empty"
+	// CompositeMissingReject rejects the request with InvalidArgument
+	CompositeMissingReject CompositeMissing = "reject"
+)
+
+// validate checks the policy name
+func (m CompositeMissing) validate() error {
+	switch m {
+	case "", CompositeMissingSkip, CompositeMissingEmpty, CompositeMissingReject:
+		return nil
+	}
+	return fmt.Errorf("unknown composite missing policy: %s", m)
+}
+
+// CompositeMapping joins several incoming HTTP headers into one metadata
+// value with a template naming headers in braces, e.g. "{X-Tenant-ID}/{X-Region}"
+type CompositeMapping struct {
+	// Template is literal text with {Header-Name} placeholders
+	Template string `json:"template" yaml:"template"`
+	// GRPCMetadata receives the expanded template
+	GRPCMetadata string `json:"grpc_metadata" yaml:"grpc_metadata"`
+	// Defaults maps header names to values used when they are missing
+	Defaults map[string]string `json:"defaults,omitempty" yaml:"defaults,omitempty"`
+	// Missing is skip (default), empty or reject, for missing headers
+	// without a default
+	Missing CompositeMissing `json:"missing,omitempty" yaml:"missing,omitempty"`
+}
+
+// compositePart is literal text or, with header set, a placeholder
+type compositePart struct {
+	text   string
+	header string
+}
+
+// parseCompositeTemplate splits a template into literal text and the
+// canonical names of its placeholders
+func parseCompositeTemplate(template string) ([]compositePart, error) {
+	var parts []compositePart
+	rest := template
+	for rest != "" {
+		open := strings.IndexAny(rest, "{}")
+		if open < 0 {
+			parts = append(parts, compositePart{text: rest})
+			break
+		}
+		if rest[open] == '}' {
+			return nil, fmt.Errorf("unmatched } in template %q", template)
+		}
+		if open > 0 {
+			parts = append(parts, compositePart{text: rest[:open]})
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed { in template %q", template)
+		}
+		name := rest[open+1 : open+end]
+		if !validHeaderName(name) {
+			return nil, fmt.Errorf("invalid header name %q in template %q", name, template)
+		}
+		parts = append(parts, compositePart{header: http.CanonicalHeaderKey(name)})
+		rest = rest[open+end+1:]
+	}
+	return parts, nil
+}
+
+// validate checks the template, metadata key, defaults and policy
+func (cm *CompositeMapping) validate() error {
+	parts, err := parseCompositeTemplate(cm.Template)
+	if err != nil {
+		return err
+	}
+	headers := make(map[string]bool)
+	for _, part := range parts {
+		if part.header != "" {
+			headers[part.header] = true
+		}
+	}
+	if len(headers) == 0 {
+		return fmt.Errorf("template %q names no headers", cm.Template)
+	}
+	key := strings.ToLower(cm.GRPCMetadata)
+	if !validMetadataKey(key) || strings.HasSuffix(key, "-bin") {
+		return fmt.Errorf("invalid metadata key: %q", cm.GRPCMetadata)
+	}
+	for name := range cm.Defaults {
+		if !headers[http.CanonicalHeaderKey(name)] {
+			return fmt.Errorf("default for %s, which template %q does not name", name, cm.Template)
+		}
+	}
+	return cm.Missing.validate()
+}
+
+// composite is a compiled composite mapping
+type composite struct {
+	key      string
+	parts    []compositePart
+	defaults map[string]string
+	missing  CompositeMissing
+}
+
+// composites maps the composite mappings of a configuration
+type composites struct {
+	mappings []composite
+	hm       *HeaderMapper
+}
+
+// newComposites compiles the valid composite mappings; invalid ones are
+// reported by Validate
+func newComposites(config []CompositeMapping, hm *HeaderMapper) *composites {
+	c := &composites{hm: hm}
+	for i := range config {
+		cm := &config[i]
+		if cm.validate() != nil {
+			continue
+		}
+		parts, _ := parseCompositeTemplate(cm.Template)
+		defaults := make(map[string]string, len(cm.Defaults))
+		for name, value := range cm.Defaults {
+			defaults[http.CanonicalHeaderKey(name)] = value
+		}
+		c.mappings = append(c.mappings, composite{
+			key:      strings.ToLower(cm.GRPCMetadata),
+			parts:    parts,
+			defaults: defaults,
+			missing:  cm.Missing,
+		})
+	}
+	return c
+}
+
+// expand returns the value of a composite for req, and the first missing
+// header without a default
+func (c *composites) expand(cc *compiledConfig, req *http.Request, mapping *composite) (string, string) {
+	var b strings.Builder
+	var missing string
+	for _, part := range mapping.parts {
+		if part.header == "" {
+			b.WriteString(part.text)
+			continue
+		}
+		var value string
+		if !c.hm.internalHeader(part.header) {
+			value = cc.selectValue(req.Header.Values(part.header))
+		}
+		if value == "" {
+			var ok bool
+			if value, ok = mapping.defaults[part.header]; !ok && missing == "" {
+				missing = part.header
+			}
+		}
+		b.WriteString(value)
+	}
+	return b.String(), missing
+}
+
+// check rejects requests missing a header of a composite with the reject policy
+func (c *composites) check(w http.ResponseWriter, req *http.Request) error {
+	cc := c.hm.state()
+	for i := range c.mappings {
+		mapping := &c.mappings[i]
+		if mapping.missing != CompositeMissingReject {
+			continue
+		}
+		if _, missing := c.expand(cc, req, mapping); missing != "" {
+			return rejectf(codes.InvalidArgument, "missing %s", missing)
+		}
+	}
+	return nil
+}
+
+// annotate sets the metadata of the composites of the request, replacing
+// values mappings placed under the same keys
+func (c *composites) annotate(req *http.Request, md metadata.MD) {
+	cc := c.hm.state()
+	for i := range c.mappings {
+		mapping := &c.mappings[i]
+		value, missing := c.expand(cc, req, mapping)
+		if missing != "" && mapping.missing != CompositeMissingEmpty {
+			continue
+		}
+		if value != "" {
+			md.Set(mapping.key, value)
+		}
+	}
+}
+
+// hasRejects reports whether any composite rejects requests
+func (c *composites) hasRejects() bool {
+	for _, mapping := range c.mappings {
+		if mapping.missing == CompositeMissingReject {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package headermapper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompositeMapping(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping CompositeMapping
+		headers map[string]string
+		want    []string
+	}{
+		{
+			"all present",
+			CompositeMapping{Template: "{X-Tenant-ID}/{X-Region}", GRPCMetadata: "tenant-route"},
+			map[string]string{"X-Tenant-ID": "acme", "X-Region": "eu"},
+			[]string{"acme/eu"},
+		},
+		{
+			"lowercase placeholder",
+			CompositeMapping{Template: "tenant={x-tenant-id}", GRPCMetadata: "tenant-route"},
+			map[string]string{"X-Tenant-ID": "acme"},
+			[]string{"tenant=acme"},
+		},
+		{
+			"missing skipped",
+			CompositeMapping{Template: "{X-Tenant-ID}/{X-Region}", GRPCMetadata: "tenant-route"},
+			map[string]string{"X-Tenant-ID": "acme"},
+			nil,
+		},
+		{
+			"missing empty",
+			CompositeMapping{Template: "{X-Tenant-ID}/{X-Region}", GRPCMetadata: "tenant-route", Missing: CompositeMissingEmpty},
+			map[string]string{"X-Tenant-ID": "acme"},
+			[]string{"acme/"},
+		},
+		{
+			"default",
+			CompositeMapping{Template: "{X-Tenant-ID}/{X-Region}", GRPCMetadata: "tenant-route", Defaults: map[string]string{"x-region": "us"}},
+			map[string]string{"X-Tenant-ID": "acme"},
+			[]string{"acme/us"},
+		},
+		{
+			"all missing empty",
+			CompositeMapping{Template: "{X-Tenant-ID}{X-Region}", GRPCMetadata: "tenant-route", Missing: CompositeMissingEmpty},
+			nil,
+			nil,
+		},
+		{
+			"replaces spoofed mapping",
+			CompositeMapping{Template: "{X-Tenant-ID}/{X-Region}", GRPCMetadata: "route"},
+			map[string]string{"X-Tenant-ID": "acme", "X-Region": "eu", "X-Route": "spoofed"},
+			[]string{"acme/eu"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper := NewBuilder().
+				AddIncomingMapping("X-Route", "route").
+				AddComposite(tt.mapping).
+				Build()
+			if err := mapper.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			md := mapper.MetadataAnnotator()(context.Background(), req)
+			if got := md.Get(tt.mapping.GRPCMetadata); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("%s = %v, want %v", tt.mapping.GRPCMetadata, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompositeMapping_Reject(t *testing.T) {
+	mapper := NewBuilder().
+		AddComposite(CompositeMapping{Template: "{X-Tenant-ID}/{X-Region}", GRPCMetadata: "tenant-route", Missing: CompositeMissingReject}).
+		Build()
+	handler := mapper.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		status  int
+	}{
+		{"complete", map[string]string{"X-Tenant-ID": "acme", "X-Region": "eu"}, http.StatusOK},
+		{"missing", map[string]string{"X-Tenant-ID": "acme"}, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+			if tt.status != http.StatusOK && !strings.Contains(rec.Body.String(), "X-Region") {
+				t.Errorf("body = %q, want the missing header", rec.Body.String())
+			}
+		})
+	}
+
+	if _, ok := mapper.HeaderMatcher()("Grpc-Metadata-Tenant-Route"); ok {
+		t.Error("HeaderMatcher forwarded a composite key")
+	}
+}
+
+func TestCompositeMapping_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping CompositeMapping
+		wantErr string
+	}{
+		{"valid", CompositeMapping{Template: "{X-A}:{X-B}", GRPCMetadata: "ab"}, ""},
+		{"unclosed", CompositeMapping{Template: "{X-A", GRPCMetadata: "ab"}, "unclosed {"},
+		{"unmatched", CompositeMapping{Template: "X-A}", GRPCMetadata: "ab"}, "unmatched }"},
+		{"invalid header", CompositeMapping{Template: "{X A}", GRPCMetadata: "ab"}, "invalid header name"},
+		{"empty placeholder", CompositeMapping{Template: "{}", GRPCMetadata: "ab"}, "invalid header name"},
+		{"no headers", CompositeMapping{Template: "static", GRPCMetadata: "ab"}, "names no headers"},
+		{"invalid key", CompositeMapping{Template: "{X-A}", GRPCMetadata: "a b"}, "invalid metadata key"},
+		{"binary key", CompositeMapping{Template: "{X-A}", GRPCMetadata: "ab-bin"}, "invalid metadata key"},
+		{"unknown default", CompositeMapping{Template: "{X-A}", GRPCMetadata: "ab", Defaults: map[string]string{"X-B": "b"}}, "does not name"},
+		{"unknown policy", CompositeMapping{Template: "{X-A}", GRPCMetadata: "ab", Missing: "drop"}, "unknown composite missing policy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewBuilder().AddComposite(tt.mapping).Build().Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -21,7 +22,11 @@ func LoadConfigFromFile(filename string) (*Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
+	return parseConfig(data)
+}
 
+// parseConfig parses and checks the contents of a configuration file
+func parseConfig(data []byte) (*Config, error) {
 	var config Config
 	
 	// Try YAML first, then JSON
@@ -31,6 +36,14 @@ func LoadConfigFromFile(filename string) (*Config, error) {
 		}
 	}
 
+	// Surface invalid mappings at load time rather than on the first request
+	if err := compileMappings(config.Mappings); err != nil {
+		return nil, fmt.Errorf("invalid config file: %w", err)
+	}
+	for i := range config.Mappings {
+		config.Mappings[i].Transform, _ = mappingTransform(config.Mappings[i])
+	}
+
 	return &config, nil
 }
 
@@ -81,6 +94,24 @@ func (cb *ConfigBuilder) AddMapping(mapping HeaderMapping) *ConfigBuilder {
 	return cb
 }
 
+// WithStrictAllowList sets whether only mapped headers reach the metadata
+func (cb *ConfigBuilder) WithStrictAllowList(strict bool) *ConfigBuilder {
+	cb.config.StrictAllowList = strict
+	return cb
+}
+
+// WithStatusMetadataKey sets the metadata key carrying the HTTP status
+func (cb *ConfigBuilder) WithStatusMetadataKey(key string) *ConfigBuilder {
+	cb.config.StatusMetadataKey = key
+	return cb
+}
+
+// WithBlockedHeaders sets the headers and metadata keys never propagated
+func (cb *ConfigBuilder) WithBlockedHeaders(names []string) *ConfigBuilder {
+	cb.config.BlockedHeaders = names
+	return cb
+}
+
 // WithSkipPaths sets the paths to skip
 func (cb *ConfigBuilder) WithSkipPaths(paths []string) *ConfigBuilder {
 	cb.config.SkipPaths = paths
@@ -105,6 +136,198 @@ func (cb *ConfigBuilder) WithDebug(debug bool) *ConfigBuilder {
 	return cb
 }
 
+// WithDebugEchoHeader sets whether applied mappings are echoed to clients requesting it
+func (cb *ConfigBuilder) WithDebugEchoHeader(enabled bool) *ConfigBuilder {
+	cb.config.DebugEchoHeader = enabled
+	return cb
+}
+
+// WithInternalNamespaces sets the internal header namespaces
+func (cb *ConfigBuilder) WithInternalNamespaces(prefixes []string) *ConfigBuilder {
+	cb.config.InternalNamespaces = prefixes
+	return cb
+}
+
+// WithTrustedProxies sets the trusted proxy CIDRs
+func (cb *ConfigBuilder) WithTrustedProxies(cidrs []string) *ConfigBuilder {
+	cb.config.TrustedProxies = cidrs
+	return cb
+}
+
+// WithDuplicateHeaders sets the duplicate header policy
+func (cb *ConfigBuilder) WithDuplicateHeaders(policy DuplicateHeaderPolicy) *ConfigBuilder {
+	cb.config.DuplicateHeaders = policy
+	return cb
+}
+
+// WithOutgoingOrder sets the order in which outgoing mappings are applied
+func (cb *ConfigBuilder) WithOutgoingOrder(order HeaderOrder) *ConfigBuilder {
+	cb.config.OutgoingOrder = order
+	return cb
+}
+
+// WithFIPSMode sets FIPS mode
+func (cb *ConfigBuilder) WithFIPSMode(enabled bool) *ConfigBuilder {
+	cb.config.FIPSMode = enabled
+	return cb
+}
+
+// WithStrictMode sets whether missing required headers reject requests
+func (cb *ConfigBuilder) WithStrictMode(strict bool) *ConfigBuilder {
+	cb.config.StrictMode = strict
+	return cb
+}
+
+// WithSignature sets the request signature verification configuration
+func (cb *ConfigBuilder) WithSignature(signature *SignatureConfig) *ConfigBuilder {
+	cb.config.Signature = signature
+	return cb
+}
+
+// WithSPIFFE sets the SPIFFE identity configuration
+func (cb *ConfigBuilder) WithSPIFFE(spiffe *SPIFFEConfig) *ConfigBuilder {
+	cb.config.SPIFFE = spiffe
+	return cb
+}
+
+// WithJWTClaims sets the JWT claims configuration
+func (cb *ConfigBuilder) WithJWTClaims(claims *JWTClaimsConfig) *ConfigBuilder {
+	cb.config.JWTClaims = claims
+	return cb
+}
+
+// WithBasicAuth sets the Basic-Auth decoding configuration
+func (cb *ConfigBuilder) WithBasicAuth(basic *BasicAuthConfig) *ConfigBuilder {
+	cb.config.BasicAuth = basic
+	return cb
+}
+
+// AddComposite adds a composite mapping
+func (cb *ConfigBuilder) AddComposite(mapping CompositeMapping) *ConfigBuilder {
+	cb.config.Composites = append(cb.config.Composites, mapping)
+	return cb
+}
+
+// WithAuthorization sets the access rules
+func (cb *ConfigBuilder) WithAuthorization(authorization *AuthorizationConfig) *ConfigBuilder {
+	cb.config.Authorization = authorization
+	return cb
+}
+
+// WithRateLimit sets the rate limiting configuration
+func (cb *ConfigBuilder) WithRateLimit(rateLimit *RateLimitConfig) *ConfigBuilder {
+	cb.config.RateLimit = rateLimit
+	return cb
+}
+
+// WithIPFilter sets the client IP allow and deny lists
+func (cb *ConfigBuilder) WithIPFilter(ipFilter *IPFilterConfig) *ConfigBuilder {
+	cb.config.IPFilter = ipFilter
+	return cb
+}
+
+// WithCSRF sets the CSRF validation configuration
+func (cb *ConfigBuilder) WithCSRF(csrf *CSRFConfig) *ConfigBuilder {
+	cb.config.CSRF = csrf
+	return cb
+}
+
+// WithCORS sets the CORS configuration
+func (cb *ConfigBuilder) WithCORS(cors *CORSConfig) *ConfigBuilder {
+	cb.config.CORS = cors
+	return cb
+}
+
+// WithReplayGuard sets the replay protection configuration
+func (cb *ConfigBuilder) WithReplayGuard(guard *ReplayGuardConfig) *ConfigBuilder {
+	cb.config.ReplayGuard = guard
+	return cb
+}
+
+// WithMetadataLimit sets the metadata size limit
+func (cb *ConfigBuilder) WithMetadataLimit(limit *MetadataLimitConfig) *ConfigBuilder {
+	cb.config.MetadataLimit = limit
+	return cb
+}
+
+// WithAudit sets the audit configuration
+func (cb *ConfigBuilder) WithAudit(audit *AuditConfig) *ConfigBuilder {
+	cb.config.Audit = audit
+	return cb
+}
+
+// WithEncryption sets the metadata encryption configuration
+func (cb *ConfigBuilder) WithEncryption(encryption *EncryptionConfig) *ConfigBuilder {
+	cb.config.Encryption = encryption
+	return cb
+}
+
+// WithPropagationSigning sets the propagation signing configuration
+func (cb *ConfigBuilder) WithPropagationSigning(signing *PropagationSigningConfig) *ConfigBuilder {
+	cb.config.PropagationSigning = signing
+	return cb
+}
+
+// WithSharedSecret sets the shared-secret header configuration
+func (cb *ConfigBuilder) WithSharedSecret(secret *SharedSecretConfig) *ConfigBuilder {
+	cb.config.SharedSecret = secret
+	return cb
+}
+
+// WithTransformCache sizes the cache used by mappings with CacheTransform set
+func (cb *ConfigBuilder) WithTransformCache(cache *TransformCacheConfig) *ConfigBuilder {
+	cb.config.TransformCache = cache
+	return cb
+}
+
+// WithMappingBudget bounds the time spent mapping one request or response
+func (cb *ConfigBuilder) WithMappingBudget(budget time.Duration) *ConfigBuilder {
+	cb.config.MappingBudget = budget
+	return cb
+}
+
+// WithParallelMapping sets the parallel mapping configuration
+func (cb *ConfigBuilder) WithParallelMapping(parallel *ParallelMappingConfig) *ConfigBuilder {
+	cb.config.ParallelMapping = parallel
+	return cb
+}
+
+// WithTimeout sets the client timeout configuration
+func (cb *ConfigBuilder) WithTimeout(timeout *TimeoutConfig) *ConfigBuilder {
+	cb.config.Timeout = timeout
+	return cb
+}
+
+// WithSSE sets the server-sent events configuration
+func (cb *ConfigBuilder) WithSSE(sse *SSEConfig) *ConfigBuilder {
+	cb.config.SSE = sse
+	return cb
+}
+
+// WithETag sets the conditional request configuration
+func (cb *ConfigBuilder) WithETag(etag *ETagConfig) *ConfigBuilder {
+	cb.config.ETag = etag
+	return cb
+}
+
+// WithExperiment sets the sticky experiment configuration
+func (cb *ConfigBuilder) WithExperiment(experiment *StickyExperimentConfig) *ConfigBuilder {
+	cb.config.Experiment = experiment
+	return cb
+}
+
+// WithTraceContext sets the trace context propagation configuration
+func (cb *ConfigBuilder) WithTraceContext(traceContext *TraceContextConfig) *ConfigBuilder {
+	cb.config.TraceContext = traceContext
+	return cb
+}
+
+// WithIdempotency sets the idempotency configuration
+func (cb *ConfigBuilder) WithIdempotency(idempotency *IdempotencyConfig) *ConfigBuilder {
+	cb.config.Idempotency = idempotency
+	return cb
+}
+
 // Build returns the built configuration
 func (cb *ConfigBuilder) Build() *Config {
 	return cb.config
@@ -119,20 +342,26 @@ func ValidateConfig(config *Config) error {
 	// Check for duplicate mappings
 	seen := make(map[string]HeaderMapping)
 	for i, mapping := range config.Mappings {
-		if mapping.HTTPHeader == "" {
-			return fmt.Errorf("mapping %d: HTTPHeader cannot be empty", i)
+		if mapping.HTTPHeader == "" && mapping.HTTPHeaderPattern == "" && !isParamMapping(mapping) {
+			return fmt.Errorf("mapping %d: %w", i, newMappingError(mapping, ErrValidationFailed, "HTTPHeader cannot be empty"))
 		}
 		if mapping.GRPCMetadata == "" {
-			return fmt.Errorf("mapping %d: GRPCMetadata cannot be empty", i)
+			return fmt.Errorf("mapping %d: %w", i, newMappingError(mapping, ErrValidationFailed, "GRPCMetadata cannot be empty"))
 		}
 
-		key := fmt.Sprintf("%s->%s", mapping.HTTPHeader, mapping.GRPCMetadata)
+		key := fmt.Sprintf("%s%s->%s", mapping.HTTPHeader, mapping.HTTPHeaderPattern, mapping.GRPCMetadata)
+		if isParamMapping(mapping) {
+			key = paramLabel(mapping) + "->" + mapping.GRPCMetadata
+		}
 		if existing, exists := seen[key]; exists {
-			return fmt.Errorf("duplicate mapping found: %s (directions: %d, %d)", 
-				key, existing.Direction, mapping.Direction)
+			return fmt.Errorf("mapping %d: %w", i, newMappingError(mapping, ErrDuplicateMapping,
+				fmt.Sprintf("duplicate mapping found (directions: %s, %s)", existing.Direction, mapping.Direction)))
 		}
 		seen[key] = mapping
 	}
+	if err := compileMappings(config.Mappings); err != nil {
+		return err
+	}
 
-	return nil
+	return validatePolicies(config)
 }
@@ -0,0 +1,175 @@
+package headermapper
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"connectrpc.com/connect"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ConnectInterceptor returns an interceptor applying the mappings to
+// services and clients built with connect-go, which speak the Connect, gRPC
+// and gRPC-Web protocols without grpc-gateway.
+//
+// For handlers it maps the request headers like MetadataAnnotator, setting
+// the mapped metadata as request headers named by their keys and as incoming
+// metadata of the context, then runs the incoming hooks like the server
+// interceptors; WithServerSideValidation applies as well. The response
+// headers and trailers the handler sets are read as metadata by their
+// lowercase names and mapped to response headers like HTTPMiddleware does.
+// For clients it propagates the mapped metadata of the incoming call like
+// UnaryClientInterceptor, including WithPropagatedKeys.
+//
+//	path, handler := greetv1connect.NewGreetServiceHandler(svc,
+//		connect.WithInterceptors(mapper.ConnectInterceptor()))
+func (hm *HeaderMapper) ConnectInterceptor(opts ...InterceptorOption) connect.Interceptor {
+	return &connectInterceptor{hm: hm, opts: hm.interceptorOptions(opts), annotator: hm.UpstreamAnnotator()}
+}
+
+// ConnectHandler wraps a Connect service handler with the request policies
+// of Handler, such as CORS, rate limiting and signature verification. It
+// takes and returns the path and handler pair of generated constructors:
+//
+//	mux.Handle(mapper.ConnectHandler(greetv1connect.NewGreetServiceHandler(svc,
+//		connect.WithInterceptors(mapper.ConnectInterceptor()))))
+func (hm *HeaderMapper) ConnectHandler(path string, handler http.Handler) (string, http.Handler) {
+	return path, hm.Handler(handler)
+}
+
+type connectInterceptor struct {
+	hm        *HeaderMapper
+	opts      interceptorOptions
+	annotator func(context.Context, *http.Request) metadata.MD
+}
+
+func (ci *connectInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		procedure := req.Spec().Procedure
+		if req.Spec().IsClient {
+			ci.hm.propagateHeader(ctx, procedure, ci.opts.propagated, req.Header())
+			return next(ctx, req)
+		}
+		if ci.hm.state().skipPaths[procedure] {
+			return next(ctx, req)
+		}
+
+		ctx, cancel, err := ci.incoming(ctx, req.HTTPMethod(), procedure, req.Peer(), req.Header())
+		if err != nil {
+			return nil, err
+		}
+		defer cancel()
+		resp, err := next(ctx, req)
+		if resp != nil {
+			ci.hm.mapResponseHeaders(ctx, resp.Header(), resp.Trailer())
+		} else if connectErr := new(connect.Error); errors.As(err, &connectErr) {
+			ci.hm.mapResponseHeaders(ctx, connectErr.Meta(), nil)
+		}
+		return resp, err
+	}
+}
+
+func (ci *connectInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return func(ctx context.Context, spec connect.Spec) connect.StreamingClientConn {
+		conn := next(ctx, spec)
+		ci.hm.propagateHeader(ctx, spec.Procedure, ci.opts.propagated, conn.RequestHeader())
+		return conn
+	}
+}
+
+func (ci *connectInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		procedure := conn.Spec().Procedure
+		if ci.hm.state().skipPaths[procedure] {
+			return next(ctx, conn)
+		}
+
+		ctx, cancel, err := ci.incoming(ctx, http.MethodPost, procedure, conn.Peer(), conn.RequestHeader())
+		if err != nil {
+			return err
+		}
+		defer cancel()
+		// The response header is sent with the first message, so it is
+		// mapped then, or once the handler returns without sending
+		wrapped := &connectHandlerConn{StreamingHandlerConn: conn}
+		wrapped.mapResponse = func() { ci.hm.mapResponseHeaders(ctx, conn.ResponseHeader(), nil) }
+		err = next(ctx, wrapped)
+		wrapped.once.Do(wrapped.mapResponse)
+		return err
+	}
+}
+
+// incoming maps the request headers of a handler call to metadata and runs
+// the incoming hooks, returning the context for the handler. Client headers
+// named like reserved keys and blocked headers are removed from header
+// before the mapped metadata is written into it.
+func (ci *connectInterceptor) incoming(ctx context.Context, method, procedure string, peer connect.Peer, header http.Header) (context.Context, context.CancelFunc, error) {
+	req := (&http.Request{
+		Method:     method,
+		URL:        &url.URL{Path: procedure, RawQuery: peer.Query.Encode()},
+		Header:     header,
+		RemoteAddr: peer.Addr,
+	}).WithContext(ctx)
+
+	md := ci.annotator(ctx, req)
+	ci.hm.stripSpoofedHeaders(ci.hm.state(), header)
+	for key, values := range md {
+		header[http.CanonicalHeaderKey(key)] = values
+	}
+	if md != nil {
+		ctx = metadata.NewIncomingContext(ctx, md)
+	}
+
+	ctx, err := ci.hm.processIncomingMetadata(ctx, procedure, ci.opts.validate)
+	if err != nil {
+		return ctx, nil, connectError(err)
+	}
+	if ci.hm.timeout != nil {
+		ctx, cancel := ci.hm.timeout.applyCall(ctx)
+		return ctx, cancel, nil
+	}
+	return ctx, func() {}, nil
+}
+
+// propagateHeader sets the metadata propagate adds to a call as request
+// headers, keeping headers the caller set
+func (hm *HeaderMapper) propagateHeader(ctx context.Context, procedure string, allowed map[string]bool, header http.Header) {
+	md, _ := metadata.FromOutgoingContext(hm.propagate(ctx, procedure, allowed))
+	for key, values := range md {
+		if name := http.CanonicalHeaderKey(key); len(header[name]) == 0 {
+			header[name] = values
+		}
+	}
+}
+
+// connectHandlerConn maps the response header before the first message
+type connectHandlerConn struct {
+	connect.StreamingHandlerConn
+	mapResponse func()
+	once        sync.Once
+}
+
+func (c *connectHandlerConn) Send(msg any) error {
+	c.once.Do(c.mapResponse)
+	return c.StreamingHandlerConn.Send(msg)
+}
+
+// connectError converts the status errors of the incoming hooks to Connect
+// errors with the same code, message and details
+func connectError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	connectErr := connect.NewError(connect.Code(st.Code()), errors.New(st.Message()))
+	for _, pb := range st.Proto().GetDetails() {
+		if detail, err := connect.NewErrorDetail(pb); err == nil {
+			connectErr.AddDetail(detail)
+		}
+	}
+	return connectErr
+}
@@ -0,0 +1,181 @@
+package headermapper
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"connectrpc.com/connect"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	connectCheckProcedure = "/grpc.health.v1.Health/Check"
+	connectWatchProcedure = "/grpc.health.v1.Health/Watch"
+)
+
+// startConnectServer serves the health service with connect-go, echoing the
+// user-id metadata and request header in the response headers
+func startConnectServer(t *testing.T, mapper *HeaderMapper, opts ...InterceptorOption) *httptest.Server {
+	t.Helper()
+	interceptors := connect.WithInterceptors(mapper.ConnectInterceptor(opts...))
+	mux := http.NewServeMux()
+	mux.Handle(mapper.ConnectHandler(connectCheckProcedure, connect.NewUnaryHandler(connectCheckProcedure,
+		func(ctx context.Context, req *connect.Request[grpc_health_v1.HealthCheckRequest]) (*connect.Response[grpc_health_v1.HealthCheckResponse], error) {
+			resp := connect.NewResponse(&grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING})
+			resp.Header().Set("server-version", " v1 ")
+			resp.Header()["Echo-Metadata"] = metadata.ValueFromIncomingContext(ctx, "user-id")
+			resp.Header()["Echo-Header"] = req.Header().Values("User-Id")
+			resp.Header()["Echo-Spiffe"] = req.Header().Values("Spiffe-Id")
+			resp.Header()["Echo-Blocked"] = req.Header().Values("X-Internal-Token")
+			return resp, nil
+		}, interceptors)))
+	mux.Handle(mapper.ConnectHandler(connectWatchProcedure, connect.NewServerStreamHandler(connectWatchProcedure,
+		func(ctx context.Context, req *connect.Request[grpc_health_v1.HealthCheckRequest], stream *connect.ServerStream[grpc_health_v1.HealthCheckResponse]) error {
+			stream.ResponseHeader().Set("server-version", " v2 ")
+			stream.ResponseHeader()["Echo-Metadata"] = metadata.ValueFromIncomingContext(ctx, "user-id")
+			return stream.Send(&grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING})
+		}, interceptors)))
+
+	server := httptest.NewUnstartedServer(mux)
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newConnectMapper() *HeaderMapper {
+	return NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").WithTransform(ToLower).
+		AddOutgoingMapping("server-version", "X-Server-Version").WithTransform(TrimSpace).
+		Build()
+}
+
+func TestConnectInterceptor_Unary(t *testing.T) {
+	server := startConnectServer(t, newConnectMapper())
+
+	tests := []struct {
+		name string
+		opts []connect.ClientOption
+	}{
+		{"connect", nil},
+		{"grpc", []connect.ClientOption{connect.WithGRPC()}},
+		{"grpc-web", []connect.ClientOption{connect.WithGRPCWeb()}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := connect.NewClient[grpc_health_v1.HealthCheckRequest, grpc_health_v1.HealthCheckResponse](
+				server.Client(), server.URL+connectCheckProcedure, tt.opts...)
+			req := connect.NewRequest(&grpc_health_v1.HealthCheckRequest{})
+			req.Header().Set("X-User-ID", "ALICE")
+			resp, err := client.CallUnary(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, want := range map[string]string{"X-Server-Version": "v1", "Echo-Metadata": "alice", "Echo-Header": "alice"} {
+				if got := resp.Header().Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestConnectInterceptor_Stream(t *testing.T) {
+	server := startConnectServer(t, newConnectMapper())
+	client := connect.NewClient[grpc_health_v1.HealthCheckRequest, grpc_health_v1.HealthCheckResponse](
+		server.Client(), server.URL+connectWatchProcedure, connect.WithGRPC())
+	req := connect.NewRequest(&grpc_health_v1.HealthCheckRequest{})
+	req.Header().Set("X-User-ID", "Bob")
+	stream, err := client.CallServerStream(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	if !stream.Receive() {
+		t.Fatalf("Receive() error = %v", stream.Err())
+	}
+	if got := stream.ResponseHeader().Get("X-Server-Version"); got != "v2" {
+		t.Errorf("X-Server-Version = %q, want v2", got)
+	}
+	if got := stream.ResponseHeader().Get("Echo-Metadata"); got != "bob" {
+		t.Errorf("Echo-Metadata = %q, want bob", got)
+	}
+}
+
+func TestConnectInterceptor_Validation(t *testing.T) {
+	server := startConnectServer(t, newStrictMapper(false), WithServerSideValidation())
+	client := connect.NewClient[grpc_health_v1.HealthCheckRequest, grpc_health_v1.HealthCheckResponse](
+		server.Client(), server.URL+connectCheckProcedure)
+	req := connect.NewRequest(&grpc_health_v1.HealthCheckRequest{})
+	req.Header().Set("X-User-ID", "u")
+	_, err := client.CallUnary(context.Background(), req)
+
+	var connectErr *connect.Error
+	if !errors.As(err, &connectErr) || connectErr.Code() != connect.CodeInvalidArgument {
+		t.Fatalf("CallUnary() error = %v, want invalid_argument", err)
+	}
+	var fields []string
+	for _, detail := range connectErr.Details() {
+		if msg, err := detail.Value(); err == nil {
+			if br, ok := msg.(*errdetails.BadRequest); ok {
+				for _, v := range br.GetFieldViolations() {
+					fields = append(fields, v.GetField())
+				}
+			}
+		}
+	}
+	if len(fields) != 1 || fields[0] != "tenant-id" {
+		t.Errorf("field violations = %v, want [tenant-id]", fields)
+	}
+}
+
+func TestConnectInterceptor_Client(t *testing.T) {
+	mapper := newConnectMapper()
+	server := startConnectServer(t, mapper)
+	client := connect.NewClient[grpc_health_v1.HealthCheckRequest, grpc_health_v1.HealthCheckResponse](
+		server.Client(), server.URL+connectCheckProcedure, connect.WithInterceptors(mapper.ConnectInterceptor()))
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("user-id", "carol"))
+	resp, err := client.CallUnary(ctx, connect.NewRequest(&grpc_health_v1.HealthCheckRequest{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Header().Get("Echo-Header"); got != "carol" {
+		t.Errorf("propagated User-Id = %q, want carol", got)
+	}
+}
+
+func TestConnectInterceptor_StripsSpoofedHeaders(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").
+		WithSPIFFEIdentity(SPIFFEIdentity("example.org")).
+		BlockHeaders("X-Internal-Token").
+		Build()
+	server := startConnectServer(t, mapper)
+
+	client := connect.NewClient[grpc_health_v1.HealthCheckRequest, grpc_health_v1.HealthCheckResponse](
+		server.Client(), server.URL+connectCheckProcedure)
+	req := connect.NewRequest(&grpc_health_v1.HealthCheckRequest{})
+	req.Header().Set("X-User-ID", "alice")
+	req.Header().Set("Spiffe-Id", "spiffe://example.org/admin")
+	req.Header().Set("X-Internal-Token", "forged")
+	resp, err := client.CallUnary(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Header().Get("Echo-Spiffe"); got != "" {
+		t.Errorf("handler saw Spiffe-Id %q, want it stripped", got)
+	}
+	if got := resp.Header().Get("Echo-Blocked"); got != "" {
+		t.Errorf("handler saw X-Internal-Token %q, want it stripped", got)
+	}
+	if got := resp.Header().Get("Echo-Header"); got != "alice" {
+		t.Errorf("Echo-Header = %q, want alice", got)
+	}
+}
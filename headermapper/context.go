@@ -0,0 +1,114 @@
+package headermapper
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// Get returns the first value of a mapped metadata key from the incoming
+// context of a gRPC handler; false when the key is absent
+func Get(ctx context.Context, key string) (string, bool) {
+	values := GetAll(ctx, key)
+	if len(values) == 0 {
+		return "", false
+	}
+	return values[0], true
+}
+
+// MustGet returns the first value of a mapped metadata key and panics when it
+// is absent; use it for keys of required mappings, which the interceptors
+// guarantee are present
+func MustGet(ctx context.Context, key string) string {
+	value, ok := Get(ctx, key)
+	if !ok {
+		panic(fmt.Sprintf("headermapper: metadata %q not found in context", key))
+	}
+	return value
+}
+
+// GetAll returns all values of a mapped metadata key from the incoming
+// context; keys are case-insensitive
+func GetAll(ctx context.Context, key string) []string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil
+	}
+	return md.Get(key)
+}
+
+// GetInt parses the first value of a mapped metadata key as a base 10 integer
+func GetInt(ctx context.Context, key string) (int, error) {
+	value, err := getRequired(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("metadata %q: %w", key, err)
+	}
+	return n, nil
+}
+
+// GetBool parses the first value of a mapped metadata key as a boolean,
+// accepting the forms of strconv.ParseBool
+func GetBool(ctx context.Context, key string) (bool, error) {
+	value, err := getRequired(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	b, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		return false, fmt.Errorf("metadata %q: %w", key, err)
+	}
+	return b, nil
+}
+
+// GetTime parses the first value of a mapped metadata key as an RFC 3339
+// timestamp or as Unix seconds
+func GetTime(ctx context.Context, key string) (time.Time, error) {
+	value, err := getRequired(ctx, key)
+	if err != nil {
+		return time.Time{}, err
+	}
+	value = strings.TrimSpace(value)
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("metadata %q: %w", key, err)
+	}
+	return t, nil
+}
+
+// GetDuration parses the first value of a mapped metadata key as a Go
+// duration such as 1.5s, or as whole seconds when it has no unit
+func GetDuration(ctx context.Context, key string) (time.Duration, error) {
+	value, err := getRequired(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	value = strings.TrimSpace(value)
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("metadata %q: %w", key, err)
+	}
+	return d, nil
+}
+
+// getRequired returns the first value of key, failing when it is absent
+func getRequired(ctx context.Context, key string) (string, error) {
+	value, ok := Get(ctx, key)
+	if !ok {
+		return "", fmt.Errorf("metadata %q not found", key)
+	}
+	return value, nil
+}
@@ -0,0 +1,114 @@
+package headermapper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func TestGet(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		"user-id", "12345",
+		"roles", "admin",
+		"roles", "viewer",
+	))
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		key     string
+		want    string
+		wantOK  bool
+		wantAll []string
+	}{
+		{"single value", ctx, "user-id", "12345", true, []string{"12345"}},
+		{"case insensitive", ctx, "User-ID", "12345", true, []string{"12345"}},
+		{"multiple values", ctx, "roles", "admin", true, []string{"admin", "viewer"}},
+		{"missing key", ctx, "tenant-id", "", false, nil},
+		{"no metadata", context.Background(), "user-id", "", false, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Get(tt.ctx, tt.key)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Get() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+			all := GetAll(tt.ctx, tt.key)
+			if len(all) != len(tt.wantAll) {
+				t.Fatalf("GetAll() = %v, want %v", all, tt.wantAll)
+			}
+			for i := range all {
+				if all[i] != tt.wantAll[i] {
+					t.Errorf("GetAll() = %v, want %v", all, tt.wantAll)
+				}
+			}
+		})
+	}
+}
+
+func TestMustGet(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("user-id", "12345"))
+	if got := MustGet(ctx, "user-id"); got != "12345" {
+		t.Errorf("MustGet() = %q", got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("MustGet() did not panic for a missing key")
+		}
+	}()
+	MustGet(ctx, "tenant-id")
+}
+
+func TestTypedGetters(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		"rate-limit", "100",
+		"debug", "true",
+		"created-at", "2024-01-02T03:04:05Z",
+		"expires-at", "1700000000",
+		"timeout", "1.5s",
+		"ttl", "30",
+		"invalid", "abc",
+	))
+
+	tests := []struct {
+		name    string
+		get     func(key string) (any, error)
+		key     string
+		want    any
+		wantErr bool
+	}{
+		{"int", func(k string) (any, error) { return GetInt(ctx, k) }, "rate-limit", 100, false},
+		{"int invalid", func(k string) (any, error) { return GetInt(ctx, k) }, "invalid", 0, true},
+		{"int missing", func(k string) (any, error) { return GetInt(ctx, k) }, "missing", 0, true},
+		{"bool", func(k string) (any, error) { return GetBool(ctx, k) }, "debug", true, false},
+		{"bool invalid", func(k string) (any, error) { return GetBool(ctx, k) }, "invalid", false, true},
+		{"time rfc3339", func(k string) (any, error) { return GetTime(ctx, k) }, "created-at", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), false},
+		{"time unix", func(k string) (any, error) { return GetTime(ctx, k) }, "expires-at", time.Unix(1700000000, 0), false},
+		{"time invalid", func(k string) (any, error) { return GetTime(ctx, k) }, "invalid", time.Time{}, true},
+		{"duration", func(k string) (any, error) { return GetDuration(ctx, k) }, "timeout", 1500 * time.Millisecond, false},
+		{"duration seconds", func(k string) (any, error) { return GetDuration(ctx, k) }, "ttl", 30 * time.Second, false},
+		{"duration missing", func(k string) (any, error) { return GetDuration(ctx, k) }, "missing", time.Duration(0), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.get(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if want, ok := tt.want.(time.Time); ok {
+				if !got.(time.Time).Equal(want) {
+					t.Errorf("got %v, want %v", got, want)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package headermapper
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CORSConfig configures cross-origin resource sharing. Request headers of
+// incoming mappings are allowed and response headers of outgoing mappings are
+// exposed automatically, so the CORS policy follows the mapping configuration.
+type CORSConfig struct {
+	// AllowedOrigins lists permitted origins; "*" permits any origin and
+	// "https://*.example.com" permits subdomains
+	AllowedOrigins []string `json:"allowed_origins" yaml:"allowed_origins"`
+	// AllowedMethods lists permitted methods (default GET, POST, PUT, PATCH, DELETE)
+	AllowedMethods []string `json:"allowed_methods" yaml:"allowed_methods"`
+	// AllowedHeaders lists request headers allowed in addition to mapped headers
+	AllowedHeaders []string `json:"allowed_headers" yaml:"allowed_headers"`
+	// ExposedHeaders lists response headers exposed in addition to mapped headers
+	ExposedHeaders []string `json:"exposed_headers" yaml:"exposed_headers"`
+	// AllowCredentials permits cookies and authorization headers
+	AllowCredentials bool `json:"allow_credentials" yaml:"allow_credentials"`
+	// MaxAge is the number of seconds preflight responses may be cached
+	MaxAge int `json:"max_age" yaml:"max_age"`
+}
+
+// validate rejects credentialed wildcard origins, which browsers refuse
+func (cc *CORSConfig) validate() error {
+	if len(cc.AllowedOrigins) == 0 {
+		return fmt.Errorf("cors: allowed_origins cannot be empty")
+	}
+	if cc.AllowCredentials {
+		for _, origin := range cc.AllowedOrigins {
+			if origin == "*" {
+				return fmt.Errorf("cors: wildcard origin cannot be used with allow_credentials")
+			}
+		}
+	}
+	return nil
+}
+
+// corsPolicy enforces a CORSConfig
+type corsPolicy struct {
+	config         *CORSConfig
+	anyOrigin      bool
+	methods        []string
+	allowedHeaders []string
+	allowHeaders   string
+	exposeHeaders  string
+}
+
+func newCORSPolicy(config *CORSConfig, mappings []HeaderMapping) *corsPolicy {
+	c := &corsPolicy{config: config, methods: config.AllowedMethods}
+	if len(c.methods) == 0 {
+		c.methods = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+	}
+	for _, origin := range config.AllowedOrigins {
+		if origin == "*" {
+			c.anyOrigin = true
+		}
+	}
+
+	allowed := []string{"Content-Type"}
+	var exposed []string
+	for _, mapping := range mappings {
+		// CORS has no wildcard header names, so wildcard and pattern mappings
+		// must be listed in AllowedHeaders and ExposedHeaders; parameter
+		// mappings read no header
+		if isPrefixMapping(mapping) || mapping.HTTPHeaderPattern != "" || isParamMapping(mapping) {
+			continue
+		}
+		header := http.CanonicalHeaderKey(mapping.HTTPHeader)
+		if mapping.Direction == Incoming || mapping.Direction == Bidirectional {
+			allowed = appendUnique(allowed, header)
+		}
+		if mapping.Direction == Outgoing || mapping.Direction == Bidirectional {
+			exposed = appendUnique(exposed, header)
+		}
+	}
+	for _, header := range config.AllowedHeaders {
+		allowed = appendUnique(allowed, http.CanonicalHeaderKey(header))
+	}
+	for _, header := range config.ExposedHeaders {
+		exposed = appendUnique(exposed, http.CanonicalHeaderKey(header))
+	}
+
+	c.allowedHeaders = allowed
+	c.allowHeaders = strings.Join(allowed, ", ")
+	c.exposeHeaders = strings.Join(exposed, ", ")
+	return c
+}
+
+// allowOrigin reports whether origin is permitted
+func (c *corsPolicy) allowOrigin(origin string) bool {
+	if c.anyOrigin {
+		return true
+	}
+	for _, allowed := range c.config.AllowedOrigins {
+		if strings.EqualFold(allowed, origin) {
+			return true
+		}
+		if scheme, domain, ok := strings.Cut(allowed, "*"); ok &&
+			len(origin) > len(scheme)+len(domain) &&
+			strings.HasPrefix(strings.ToLower(origin), strings.ToLower(scheme)) &&
+			strings.HasSuffix(strings.ToLower(origin), strings.ToLower(domain)) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowRequestHeaders reports whether every header in the preflight request list is permitted
+func (c *corsPolicy) allowRequestHeaders(list string) bool {
+	for _, header := range strings.Split(list, ",") {
+		header = strings.TrimSpace(header)
+		if header == "" {
+			continue
+		}
+		found := false
+		for _, allowed := range c.allowedHeaders {
+			if strings.EqualFold(allowed, header) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// setOrigin writes the origin and credentials headers
+func (c *corsPolicy) setOrigin(h http.Header, origin string) {
+	if c.anyOrigin && !c.config.AllowCredentials {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if c.config.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// handle writes the CORS response headers and reports whether the request was
+// a preflight that has been answered
+func (c *corsPolicy) handle(w http.ResponseWriter, req *http.Request) bool {
+	h := w.Header()
+	origin := req.Header.Get("Origin")
+	requestMethod := req.Header.Get("Access-Control-Request-Method")
+
+	if req.Method == http.MethodOptions && origin != "" && requestMethod != "" {
+		h.Add("Vary", "Origin")
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+
+		requestHeaders := req.Header.Get("Access-Control-Request-Headers")
+		if c.allowOrigin(origin) && containsFold(c.methods, requestMethod) && c.allowRequestHeaders(requestHeaders) {
+			c.setOrigin(h, origin)
+			h.Set("Access-Control-Allow-Methods", strings.Join(c.methods, ", "))
+			h.Set("Access-Control-Allow-Headers", c.allowHeaders)
+			if c.config.MaxAge > 0 {
+				h.Set("Access-Control-Max-Age", strconv.Itoa(c.config.MaxAge))
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return true
+	}
+
+	h.Add("Vary", "Origin")
+	if origin != "" && c.allowOrigin(origin) {
+		c.setOrigin(h, origin)
+		if c.exposeHeaders != "" {
+			h.Set("Access-Control-Expose-Headers", c.exposeHeaders)
+		}
+	}
+	return false
+}
+
+// appendUnique appends value unless it is already present
+func appendUnique(values []string, value string) []string {
+	if containsFold(values, value) {
+		return values
+	}
+	return append(values, value)
+}
+
+// containsFold reports whether values contains value, ignoring case
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
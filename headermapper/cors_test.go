@@ -0,0 +1,102 @@
+package headermapper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORS_Preflight(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").
+		AddOutgoingMapping("request-id", "X-Request-ID").
+		EnableCORS(&CORSConfig{
+			AllowedOrigins:   []string{"https://app.example.com", "https://*.example.org"},
+			AllowCredentials: true,
+			MaxAge:           600,
+		}).
+		Build()
+	called := false
+	handler := mapper.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+
+	tests := []struct {
+		name          string
+		origin        string
+		method        string
+		headers       string
+		expectAllowed bool
+	}{
+		{"exact origin", "https://app.example.com", "POST", "x-user-id, content-type", true},
+		{"wildcard subdomain", "https://api.example.org", "GET", "", true},
+		{"bare wildcard domain", "https://example.org", "GET", "", false},
+		{"unknown origin", "https://evil.com", "GET", "", false},
+		{"method not allowed", "https://app.example.com", "TRACE", "", false},
+		{"unmapped header", "https://app.example.com", "GET", "X-Secret", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodOptions, "/v1/echo", nil)
+			req.Header.Set("Origin", tt.origin)
+			req.Header.Set("Access-Control-Request-Method", tt.method)
+			if tt.headers != "" {
+				req.Header.Set("Access-Control-Request-Headers", tt.headers)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if called {
+				t.Fatal("preflight request reached the wrapped handler")
+			}
+			if w.Code != http.StatusNoContent {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusNoContent)
+			}
+			allowed := w.Header().Get("Access-Control-Allow-Origin") == tt.origin
+			if allowed != tt.expectAllowed {
+				t.Errorf("origin allowed = %v, want %v", allowed, tt.expectAllowed)
+			}
+			if tt.expectAllowed {
+				if got := w.Header().Get("Access-Control-Allow-Headers"); got != "Content-Type, X-User-Id" {
+					t.Errorf("Access-Control-Allow-Headers = %q", got)
+				}
+				if got := w.Header().Get("Access-Control-Max-Age"); got != "600" {
+					t.Errorf("Access-Control-Max-Age = %q", got)
+				}
+			}
+			if len(w.Header().Values("Vary")) != 3 {
+				t.Errorf("Vary = %v", w.Header().Values("Vary"))
+			}
+		})
+	}
+}
+
+func TestCORS_ActualRequest(t *testing.T) {
+	mapper := NewBuilder().
+		AddOutgoingMapping("request-id", "X-Request-ID").
+		EnableCORS(&CORSConfig{AllowedOrigins: []string{"*"}, ExposedHeaders: []string{"ETag"}}).
+		Build()
+	handler := mapper.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest("GET", "/v1/echo", nil)
+	req.Header.Set("Origin", "https://anywhere.test")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	expected := map[string]string{
+		"Access-Control-Allow-Origin":   "*",
+		"Access-Control-Expose-Headers": "X-Request-Id, Etag",
+		"Vary":                          "Origin",
+	}
+	for header, value := range expected {
+		if got := w.Header().Get(header); got != value {
+			t.Errorf("header %s = %q, want %q", header, got, value)
+		}
+	}
+}
+
+func TestCORSConfig_Validate(t *testing.T) {
+	err := ValidateConfig(&Config{CORS: &CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true}})
+	if err == nil {
+		t.Error("ValidateConfig() expected error for credentialed wildcard origin")
+	}
+}
@@ -0,0 +1,53 @@
+package headermapper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCSRF_Handler(t *testing.T) {
+	config := &CSRFConfig{Paths: []string{"/v1/*"}}
+	mapper := NewBuilder().ValidateCSRF(config).Build()
+	handler := mapper.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	issued := httptest.NewRecorder()
+	token, err := IssueCSRFToken(issued, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookie := issued.Result().Cookies()[0]
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		cookie   bool
+		token    string
+		expected int
+	}{
+		{"safe method", "GET", "/v1/items", false, "", http.StatusOK},
+		{"matching token", "POST", "/v1/items", true, token, http.StatusOK},
+		{"missing cookie", "POST", "/v1/items", false, token, http.StatusForbidden},
+		{"missing header", "POST", "/v1/items", true, "", http.StatusForbidden},
+		{"mismatch", "DELETE", "/v1/items", true, "forged", http.StatusForbidden},
+		{"unprotected path", "POST", "/webhooks/github", false, "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.cookie {
+				req.AddCookie(cookie)
+			}
+			if tt.token != "" {
+				req.Header.Set("X-CSRF-Token", tt.token)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.expected {
+				t.Errorf("Handler() status = %d, want %d", w.Code, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package headermapper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// TimeoutConfig applies a timeout requested by the client as the deadline
+// of the request, so it propagates to backends. Handler applies it to HTTP
+// requests, from where the gateway forwards it as the gRPC deadline; the
+// server interceptors apply it to calls carrying the header as metadata.
+// In the other direction, the client interceptors send the deadline of
+// outgoing calls in Header, as DeadlineTransport does for HTTP requests.
+type TimeoutConfig struct {
+	// Header carries the timeout (default X-Request-Timeout). Values are Go
+	// durations such as 1.5s or whole seconds; when the header is
+	// Grpc-Timeout, values use the gRPC format such as 500m or 2S.
+	Header string `json:"header" yaml:"header"`
+	// Max bounds the requested timeout (default 60s)
+	Max time.Duration `json:"max" yaml:"max"`
+}
+
+func (tc *TimeoutConfig) header() string {
+	if tc.Header == "" {
+		return "X-Request-Timeout"
+	}
+	return http.CanonicalHeaderKey(tc.Header)
+}
+
+func (tc *TimeoutConfig) max() time.Duration {
+	if tc.Max <= 0 {
+		return time.Minute
+	}
+	return tc.Max
+}
+
+// validate checks the maximum timeout
+func (tc *TimeoutConfig) validate() error {
+	if tc.Max < 0 {
+		return fmt.Errorf("timeout: max cannot be negative")
+	}
+	return nil
+}
+
+// requestTimeout enforces a TimeoutConfig
+type requestTimeout struct {
+	header     string
+	key        string
+	grpcFormat bool
+	max        time.Duration
+}
+
+func newRequestTimeout(config *TimeoutConfig) *requestTimeout {
+	header := config.header()
+	return &requestTimeout{
+		header:     header,
+		key:        strings.ToLower(header),
+		grpcFormat: header == "Grpc-Timeout",
+		max:        config.max(),
+	}
+}
+
+// outgoingTimeout returns the timeout header deadlines are sent and exposed
+// in: that of Timeout, or X-Request-Timeout without one
+func (hm *HeaderMapper) outgoingTimeout() *requestTimeout {
+	if hm.timeout != nil {
+		return hm.timeout
+	}
+	return newRequestTimeout(&TimeoutConfig{})
+}
+
+// format renders timeout in the format of the timeout header
+func (t *requestTimeout) format(timeout time.Duration) string {
+	if t.grpcFormat {
+		return formatGRPCTimeout(timeout)
+	}
+	return timeout.Round(time.Millisecond).String()
+}
+
+// deadlineHeader exposes the time left before the request deadline in a
+// response header, independently of honoring client timeouts
+type deadlineHeader struct {
+	header  string
+	timeout *requestTimeout
+}
+
+// validateDeadlineHeader checks the name of the remaining deadline header
+func validateDeadlineHeader(header string) error {
+	if header != "" && !validHeaderName(header) {
+		return fmt.Errorf("invalid deadline header %q", header)
+	}
+	return nil
+}
+
+// expose sets the header from the deadline of ctx
+func (d *deadlineHeader) expose(ctx context.Context, w http.ResponseWriter) {
+	if deadline, ok := ctx.Deadline(); ok {
+		w.Header().Set(d.header, d.timeout.format(max(time.Until(deadline), 0)))
+	}
+}
+
+// parse returns the requested timeout bounded by the maximum; false when
+// value is empty or invalid
+func (t *requestTimeout) parse(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	var timeout time.Duration
+	var err error
+	switch {
+	case t.grpcFormat:
+		timeout, err = parseGRPCTimeout(value)
+	default:
+		var seconds int64
+		if seconds, err = strconv.ParseInt(value, 10, 64); err == nil {
+			timeout = time.Duration(seconds) * time.Second
+		} else {
+			timeout, err = time.ParseDuration(value)
+		}
+	}
+	if err != nil || timeout <= 0 {
+		return 0, false
+	}
+	return min(timeout, t.max), true
+}
+
+// apply returns ctx with the deadline requested by value, unless ctx already
+// has an earlier one. The returned cancel function is never nil.
+func (t *requestTimeout) apply(ctx context.Context, value string) (context.Context, context.CancelFunc) {
+	timeout, ok := t.parse(value)
+	if !ok {
+		return ctx, func() {}
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= timeout {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// applyHTTP applies the timeout requested by req
+func (t *requestTimeout) applyHTTP(req *http.Request) (*http.Request, context.CancelFunc) {
+	ctx, cancel := t.apply(req.Context(), req.Header.Get(t.header))
+	if ctx == req.Context() {
+		return req, cancel
+	}
+	return req.WithContext(ctx), cancel
+}
+
+// applyCall applies the timeout requested by the metadata of a gRPC call
+func (t *requestTimeout) applyCall(ctx context.Context) (context.Context, context.CancelFunc) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return t.apply(ctx, firstValue(md, t.key))
+}
+
+// sendDeadline returns ctx carrying the time left before its deadline in the
+// outgoing metadata, under the key of the timeout header, so upstreams
+// reached through a proxy transcoding calls to HTTP see it. A value the
+// caller set is kept; with Grpc-Timeout gRPC sends the deadline itself.
+func (hm *HeaderMapper) sendDeadline(ctx context.Context) context.Context {
+	t := hm.timeout
+	if t == nil || t.grpcFormat || hm.state().index.blocked.blocks(t.key) {
+		return ctx
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return ctx
+	}
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		return ctx
+	}
+	if md, ok := metadata.FromOutgoingContext(ctx); ok && len(md[t.key]) > 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, t.key, t.format(remaining))
+}
+
+// DeadlineTransport returns an http.RoundTripper for calls to REST
+// upstreams that sends the time left before the request context's deadline
+// in the timeout header (default X-Request-Timeout), unless the request
+// already sets it. A nil base uses http.DefaultTransport.
+//
+//	client := &http.Client{Transport: mapper.DeadlineTransport(nil)}
+func (hm *HeaderMapper) DeadlineTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &deadlineTransport{base: base, timeout: hm.outgoingTimeout()}
+}
+
+// deadlineTransport propagates request deadlines to upstreams
+type deadlineTransport struct {
+	base    http.RoundTripper
+	timeout *requestTimeout
+}
+
+func (t *deadlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	deadline, ok := req.Context().Deadline()
+	if !ok || req.Header.Get(t.timeout.header) != "" {
+		return t.base.RoundTrip(req)
+	}
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		return nil, context.DeadlineExceeded
+	}
+
+	// RoundTrippers must not modify the caller's request
+	req = req.Clone(req.Context())
+	req.Header.Set(t.timeout.header, t.timeout.format(remaining))
+	return t.base.RoundTrip(req)
+}
+
+// grpcTimeoutUnits maps the units of the grpc-timeout format to durations
+var grpcTimeoutUnits = map[byte]time.Duration{
+	'H': time.Hour,
+	'M': time.Minute,
+	'S': time.Second,
+	'm': time.Millisecond,
+	'u': time.Microsecond,
+	'n': time.Nanosecond,
+}
+
+// parseGRPCTimeout parses a timeout in the grpc-timeout format: at most 8
+// digits followed by a unit
+func parseGRPCTimeout(value string) (time.Duration, error) {
+	if len(value) < 2 || len(value) > 9 {
+		return 0, fmt.Errorf("invalid grpc-timeout: %q", value)
+	}
+	unit, ok := grpcTimeoutUnits[value[len(value)-1]]
+	if !ok {
+		return 0, fmt.Errorf("invalid grpc-timeout unit: %q", value)
+	}
+	n, err := strconv.ParseUint(value[:len(value)-1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid grpc-timeout: %q", value)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// formatGRPCTimeout encodes timeout in the grpc-timeout format using the
+// finest unit that fits in 8 digits, rounding up
+func formatGRPCTimeout(timeout time.Duration) string {
+	if timeout <= 0 {
+		return "0n"
+	}
+	for _, unit := range []byte("numSMH") {
+		d := grpcTimeoutUnits[unit]
+		if n := (timeout + d - 1) / d; n < 1e8 {
+			return strconv.FormatInt(int64(n), 10) + string(unit)
+		}
+	}
+	return "99999999H"
+}
@@ -0,0 +1,364 @@
+package headermapper
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestRequestTimeout_Parse(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{"go duration", "", "1.5s", 1500 * time.Millisecond, true},
+		{"seconds", "", "5", 5 * time.Second, true},
+		{"bounded by max", "", "10m", 30 * time.Second, true},
+		{"invalid", "", "soon", 0, false},
+		{"negative", "", "-1s", 0, false},
+		{"empty", "", "", 0, false},
+		{"grpc milliseconds", "grpc-timeout", "500m", 500 * time.Millisecond, true},
+		{"grpc seconds", "Grpc-Timeout", "2S", 2 * time.Second, true},
+		{"grpc hours bounded", "grpc-timeout", "1H", 30 * time.Second, true},
+		{"grpc missing unit", "grpc-timeout", "500", 0, false},
+		{"grpc too many digits", "grpc-timeout", "123456789S", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeout := newRequestTimeout(&TimeoutConfig{Header: tt.header, Max: 30 * time.Second})
+			got, ok := timeout.parse(tt.value)
+			if got != tt.expected || ok != tt.ok {
+				t.Errorf("parse(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.expected, tt.ok)
+			}
+		})
+	}
+}
+
+func TestRequestTimeout_Handler(t *testing.T) {
+	mapper := NewBuilder().ApplyTimeouts("", 10*time.Second).Build()
+
+	var remaining time.Duration
+	var hasDeadline bool
+	handler := mapper.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var deadline time.Time
+		deadline, hasDeadline = r.Context().Deadline()
+		remaining = time.Until(deadline)
+	}))
+
+	tests := []struct {
+		name     string
+		value    string
+		deadline bool
+		max      time.Duration
+	}{
+		{"requested", "2s", true, 2 * time.Second},
+		{"bounded", "1h", true, 10 * time.Second},
+		{"missing", "", false, 0},
+		{"invalid", "later", false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/test", nil)
+			if tt.value != "" {
+				req.Header.Set("X-Request-Timeout", tt.value)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			if hasDeadline != tt.deadline {
+				t.Fatalf("deadline set = %v, want %v", hasDeadline, tt.deadline)
+			}
+			if tt.deadline && (remaining > tt.max || remaining < tt.max-time.Second) {
+				t.Errorf("remaining = %v, want about %v", remaining, tt.max)
+			}
+		})
+	}
+}
+
+func TestRequestTimeout_Interceptors(t *testing.T) {
+	mapper := NewBuilder().ApplyTimeouts("X-Request-Timeout", time.Minute).Build()
+
+	tests := []struct {
+		name     string
+		ctx      func() (context.Context, context.CancelFunc)
+		expected time.Duration
+	}{
+		{"requested", func() (context.Context, context.CancelFunc) {
+			return context.Background(), func() {}
+		}, 3 * time.Second},
+		{"earlier deadline kept", func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), time.Second)
+		}, time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent, cancel := tt.ctx()
+			defer cancel()
+			ctx := metadata.NewIncomingContext(parent, metadata.Pairs("x-request-timeout", "3s"))
+
+			check := func(ctx context.Context) {
+				deadline, ok := ctx.Deadline()
+				if remaining := time.Until(deadline); !ok || remaining > tt.expected || remaining < tt.expected-time.Second {
+					t.Errorf("remaining = %v, %v, want about %v", remaining, ok, tt.expected)
+				}
+			}
+
+			_, err := mapper.UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Method"},
+				func(ctx context.Context, req interface{}) (interface{}, error) {
+					check(ctx)
+					return nil, nil
+				})
+			if err != nil {
+				t.Fatalf("unary error = %v", err)
+			}
+
+			err = mapper.StreamServerInterceptor()(nil, &mockServerStream{ctx: ctx}, &grpc.StreamServerInfo{FullMethod: "/test.Service/Method"},
+				func(srv interface{}, stream grpc.ServerStream) error {
+					check(stream.Context())
+					return nil
+				})
+			if err != nil {
+				t.Fatalf("stream error = %v", err)
+			}
+		})
+	}
+}
+
+func TestFormatGRPCTimeout(t *testing.T) {
+	tests := []struct {
+		timeout  time.Duration
+		expected string
+	}{
+		{500 * time.Millisecond, "500000u"},
+		{1500 * time.Microsecond, "1500000n"},
+		{2 * time.Minute, "120000m"},
+		{10 * time.Minute, "600000m"},
+		{30 * time.Hour, "108000S"},
+		{0, "0n"},
+	}
+
+	timeout := newRequestTimeout(&TimeoutConfig{Header: "Grpc-Timeout"})
+	for _, tt := range tests {
+		t.Run(tt.timeout.String(), func(t *testing.T) {
+			got := formatGRPCTimeout(tt.timeout)
+			if got != tt.expected {
+				t.Errorf("got %q, want %q", got, tt.expected)
+			}
+			if parsed, err := parseGRPCTimeout(got); tt.timeout > 0 && (err != nil || parsed != tt.timeout) {
+				t.Errorf("parseGRPCTimeout(%q) = %v, %v", got, parsed, err)
+			}
+			if formatted := timeout.format(tt.timeout); formatted != got {
+				t.Errorf("format = %q, want %q", formatted, got)
+			}
+		})
+	}
+}
+
+func TestRequestTimeout_ExposeDeadline(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *Builder
+		header  string
+		timeout string
+		want    time.Duration
+	}{
+		{"requested timeout", NewBuilder().ApplyTimeouts("", 10*time.Second).ExposeDeadline("X-Deadline-Remaining"),
+			"X-Request-Timeout", "5s", 5 * time.Second},
+		{"configured before timeouts", NewBuilder().ExposeDeadline("X-Deadline-Remaining").ApplyTimeouts("Grpc-Timeout", 10*time.Second),
+			"Grpc-Timeout", "3S", 3 * time.Second},
+		{"no deadline", NewBuilder().ExposeDeadline("X-Deadline-Remaining"), "", "", 0},
+		{"client timeouts not honored", NewBuilder().ExposeDeadline("X-Deadline-Remaining"),
+			"X-Request-Timeout", "5s", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper := tt.builder.Build()
+			if err := mapper.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			handler := mapper.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctx := runtime.NewServerMetadataContext(r.Context(), runtime.ServerMetadata{HeaderMD: metadata.MD{}})
+				if err := mapper.ResponseModifier()(ctx, w, nil); err != nil {
+					t.Fatal(err)
+				}
+			}))
+
+			req := httptest.NewRequest("GET", "/api/test", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.timeout)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			value := w.Header().Get("X-Deadline-Remaining")
+			if tt.want == 0 {
+				if value != "" {
+					t.Errorf("X-Deadline-Remaining = %q, want none", value)
+				}
+				return
+			}
+			remaining, ok := mapper.deadline.timeout.parse(value)
+			if !ok || remaining > tt.want || remaining < tt.want-time.Second {
+				t.Errorf("X-Deadline-Remaining = %q, want about %v", value, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeadline_ClientInterceptors(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *Builder
+		ctx     func() (context.Context, context.CancelFunc)
+		want    string
+	}{
+		{"deadline", NewBuilder().ApplyTimeouts("X-Request-Timeout", time.Minute), func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 2*time.Second)
+		}, "2s"},
+		{"explicit value kept", NewBuilder().ApplyTimeouts("X-Request-Timeout", time.Minute), func() (context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			return metadata.AppendToOutgoingContext(ctx, "x-request-timeout", "1s"), cancel
+		}, "1s"},
+		{"no deadline", NewBuilder().ApplyTimeouts("X-Request-Timeout", time.Minute), func() (context.Context, context.CancelFunc) {
+			return context.Background(), func() {}
+		}, ""},
+		{"grpc-timeout left to grpc", NewBuilder().ApplyTimeouts("Grpc-Timeout", time.Minute), func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 2*time.Second)
+		}, ""},
+		{"without timeouts", NewBuilder().ExposeDeadline("X-Deadline-Remaining"), func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 2*time.Second)
+		}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper := tt.builder.Build()
+			ctx, cancel := tt.ctx()
+			defer cancel()
+
+			check := func(ctx context.Context) {
+				md, _ := metadata.FromOutgoingContext(ctx)
+				got := ""
+				if values := md.Get(mapper.outgoingTimeout().key); len(values) > 0 {
+					got = values[0]
+				}
+				if tt.want == "" || got == "" {
+					if got != tt.want {
+						t.Errorf("timeout = %q, want %q", got, tt.want)
+					}
+					return
+				}
+				timeout, _ := time.ParseDuration(got)
+				want, _ := time.ParseDuration(tt.want)
+				if timeout > want || timeout < want-time.Second {
+					t.Errorf("timeout = %q, want about %s", got, tt.want)
+				}
+			}
+
+			err := mapper.UnaryClientInterceptor()(ctx, "/test.Service/Method", nil, nil, nil,
+				func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+					check(ctx)
+					return nil
+				})
+			if err != nil {
+				t.Fatalf("unary error = %v", err)
+			}
+			_, err = mapper.StreamClientInterceptor()(ctx, &grpc.StreamDesc{}, nil, "/test.Service/Method",
+				func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+					check(ctx)
+					return nil, nil
+				})
+			if err != nil {
+				t.Fatalf("stream error = %v", err)
+			}
+		})
+	}
+}
+
+func TestDeadlineTransport(t *testing.T) {
+	var received string
+	upstream := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		received = req.Header.Get("X-Request-Timeout")
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+	})
+	transport := NewBuilder().Build().DeadlineTransport(upstream)
+
+	tests := []struct {
+		name   string
+		ctx    func() (context.Context, context.CancelFunc)
+		header string
+		want   time.Duration
+		err    error
+	}{
+		{"deadline", func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 2*time.Second)
+		}, "", 2 * time.Second, nil},
+		{"no deadline", func() (context.Context, context.CancelFunc) {
+			return context.Background(), func() {}
+		}, "", 0, nil},
+		{"explicit header kept", func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 2*time.Second)
+		}, "1s", time.Second, nil},
+		{"expired", func() (context.Context, context.CancelFunc) {
+			return context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		}, "", 0, context.DeadlineExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received = ""
+			ctx, cancel := tt.ctx()
+			defer cancel()
+			req := httptest.NewRequest("GET", "http://upstream/api", nil).WithContext(ctx)
+			if tt.header != "" {
+				req.Header.Set("X-Request-Timeout", tt.header)
+			}
+
+			_, err := transport.RoundTrip(req)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("RoundTrip() error = %v, want %v", err, tt.err)
+			}
+			if tt.want == 0 {
+				if received != "" {
+					t.Errorf("X-Request-Timeout = %q, want none", received)
+				}
+				return
+			}
+			got, err := time.ParseDuration(received)
+			if err != nil || got > tt.want || got < tt.want-time.Second {
+				t.Errorf("X-Request-Timeout = %q, want about %v", received, tt.want)
+			}
+			if tt.header == "" && req.Header.Get("X-Request-Timeout") != "" {
+				t.Error("caller's request was modified")
+			}
+		})
+	}
+}
+
+// roundTripperFunc adapts a function to http.RoundTripper
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// mockServerStream is a grpc.ServerStream carrying only a context
+type mockServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *mockServerStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,74 @@
+package headermapper
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// DebugRequestHeader requests the applied mappings when DebugEchoHeader is enabled
+	DebugRequestHeader = "X-HeaderMapper-Debug"
+	// DebugMappedHeader lists the applied mappings in the response
+	DebugMappedHeader = "X-Mapped-Headers"
+
+	// debugMappedKey carries the applied incoming mappings from the
+	// annotator to the response modifier in the gateway's outgoing context
+	debugMappedKey = "x-headermapper-mapped"
+)
+
+// debugRequested reports whether the client asked for the applied mappings
+func debugRequested(req *http.Request) bool {
+	switch req.Header.Get(DebugRequestHeader) {
+	case "1", "true":
+		return true
+	}
+	return false
+}
+
+// appliedIncoming lists the incoming mappings that produced a value in md
+// as header->key pairs in configuration order
+func (idx *mappingIndex) appliedIncoming(md metadata.MD) string {
+	var applied []string
+	for i := range idx.incoming {
+		mapping := &idx.incoming[i]
+		if len(md[mapping.key]) > 0 {
+			applied = append(applied, mapping.header+"->"+mapping.key)
+		}
+	}
+	return strings.Join(applied, ", ")
+}
+
+// appliedOutgoing lists the outgoing mappings with a value in md or a
+// default as key->header pairs in the order they are applied
+func (idx *mappingIndex) appliedOutgoing(md metadata.MD) []string {
+	var applied []string
+	for _, mapping := range idx.outgoingSequence {
+		if mapping.internal {
+			continue
+		}
+		if len(md[mapping.key]) > 0 || mapping.defaultValue != "" || mapping.generator != nil {
+			applied = append(applied, mapping.key+"->"+mapping.header)
+		}
+	}
+	return applied
+}
+
+// echoMappings writes DebugMappedHeader for requests that asked for it. The
+// gateway's context carries the metadata produced by the annotator, which
+// includes the applied incoming mappings only when debugging was requested.
+func echoMappings(ctx context.Context, cc *compiledConfig, md metadata.MD, w http.ResponseWriter) {
+	outgoing, ok := metadata.FromOutgoingContext(ctx)
+	if !ok || len(outgoing[debugMappedKey]) == 0 {
+		return
+	}
+
+	var applied []string
+	if incoming := outgoing[debugMappedKey][0]; incoming != "" {
+		applied = append(applied, incoming)
+	}
+	applied = append(applied, cc.index.appliedOutgoing(md)...)
+	w.Header().Set(DebugMappedHeader, strings.Join(applied, ", "))
+}
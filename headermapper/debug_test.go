@@ -0,0 +1,88 @@
+package headermapper
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestDebugEchoHeader(t *testing.T) {
+	build := func(enabled bool) *HeaderMapper {
+		return NewBuilder().
+			AddIncomingMapping("X-User-ID", "user-id").
+			AddIncomingMapping("X-Tenant-ID", "tenant-id").
+			AddOutgoingMapping("response-time", "X-Response-Time").
+			AddOutgoingMapping("server-version", "X-Server-Version").
+			DebugEchoHeader(enabled).
+			Build()
+	}
+
+	tests := []struct {
+		name     string
+		enabled  bool
+		debug    string
+		headers  map[string]string
+		backend  metadata.MD
+		expected string
+	}{
+		{
+			name:     "requested",
+			enabled:  true,
+			debug:    "1",
+			headers:  map[string]string{"X-User-ID": "12345"},
+			backend:  metadata.Pairs("response-time", "12ms"),
+			expected: "X-User-Id->user-id, response-time->X-Response-Time",
+		},
+		{
+			name:     "no mapped headers",
+			enabled:  true,
+			debug:    "true",
+			expected: "",
+		},
+		{
+			name:    "not requested",
+			enabled: true,
+			headers: map[string]string{"X-User-ID": "12345"},
+		},
+		{
+			name:    "disabled",
+			debug:   "1",
+			headers: map[string]string{"X-User-ID": "12345"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper := build(tt.enabled)
+			req := httptest.NewRequest("GET", "/api/test", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			if tt.debug != "" {
+				req.Header.Set(DebugRequestHeader, tt.debug)
+			}
+
+			// The gateway passes the annotated metadata to the response
+			// modifier as outgoing context metadata
+			md := mapper.MetadataAnnotator()(context.Background(), req)
+			ctx := metadata.NewOutgoingContext(context.Background(), md)
+			ctx = runtime.NewServerMetadataContext(ctx, runtime.ServerMetadata{HeaderMD: tt.backend})
+
+			w := httptest.NewRecorder()
+			if err := mapper.ResponseModifier()(ctx, w, nil); err != nil {
+				t.Fatalf("ResponseModifier() error = %v", err)
+			}
+			values, echoed := w.Header()[DebugMappedHeader]
+			wantEcho := tt.enabled && tt.debug != ""
+			if echoed != wantEcho {
+				t.Fatalf("%s present = %v, want %v", DebugMappedHeader, echoed, wantEcho)
+			}
+			if echoed && values[0] != tt.expected {
+				t.Errorf("%s = %q, want %q", DebugMappedHeader, values[0], tt.expected)
+			}
+		})
+	}
+}
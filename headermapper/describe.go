@@ -0,0 +1,73 @@
+package headermapper
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// DescribeMappings returns an aligned table of the active mappings with
+// their direction, source, target, transform, requirement and default
+func (hm *HeaderMapper) DescribeMappings() string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DIRECTION\tSOURCE\tTARGET\tTRANSFORM\tREQUIRED\tDEFAULT")
+	for _, mapping := range hm.state().config.Mappings {
+		source, target := mapping.HTTPHeader, mapping.GRPCMetadata
+		if mapping.HTTPHeaderPattern != "" {
+			source = "/" + mapping.HTTPHeaderPattern + "/"
+		}
+		if isParamMapping(mapping) {
+			source = paramLabel(mapping)
+		}
+		if mapping.Direction == Outgoing {
+			source, target = target, source
+		}
+
+		transform := "-"
+		switch {
+		case mapping.Transform != nil && mapping.CacheTransform:
+			transform = "cached"
+		case len(mapping.TransformNames) > 0:
+			transform = strings.Join(mapping.TransformNames, ",")
+		case mapping.Transform != nil:
+			transform = "yes"
+		}
+		required := "no"
+		if mapping.Required {
+			required = "yes"
+		}
+		defaultValue := "-"
+		switch {
+		case mapping.Generator != nil:
+			defaultValue = "(generated)"
+		case mapping.DefaultValue != "":
+			defaultValue = strconv.Quote(mapping.DefaultValue)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			mapping.Direction, source, target, transform, required, defaultValue)
+	}
+	w.Flush()
+	return b.String()
+}
+
+// String summarizes the active configuration followed by DescribeMappings
+func (hm *HeaderMapper) String() string {
+	config := hm.state().config
+	counts := make(map[MappingDirection]int)
+	for _, mapping := range config.Mappings {
+		counts[mapping.Direction]++
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "HeaderMapper: %d mappings (%d incoming, %d outgoing, %d bidirectional)",
+		len(config.Mappings), counts[Incoming], counts[Outgoing], counts[Bidirectional])
+	if len(config.SkipPaths) > 0 {
+		fmt.Fprintf(&b, ", skip paths: %s", strings.Join(config.SkipPaths, ", "))
+	}
+	b.WriteByte('\n')
+	b.WriteString(hm.DescribeMappings())
+	return b.String()
+}
@@ -0,0 +1,36 @@
+package headermapper
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHeaderMapper_DescribeMappings(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").WithRequired(true).
+		AddIncomingMapping("User-Agent", "client").WithCachedTransform(ToLower).
+		AddOutgoingMapping("response-time", "X-Response-Time").WithTransform(AddPrefix("Duration: ")).
+		AddBidirectionalMapping("X-Request-ID", "request-id").WithGenerator(GenerateUUID).
+		AddIncomingMapping("X-Tenant-ID", "tenant-id").WithDefault("default").
+		SkipPaths("/health").
+		Build()
+
+	expected := `DIRECTION      SOURCE         TARGET           TRANSFORM  REQUIRED  DEFAULT
+incoming       X-User-ID      user-id          -          yes       -
+incoming       User-Agent     client           cached     no        -
+outgoing       response-time  X-Response-Time  yes        no        -
+bidirectional  X-Request-ID   request-id       -          no        (generated)
+incoming       X-Tenant-ID    tenant-id        -          no        "default"
+`
+	if got := mapper.DescribeMappings(); got != expected {
+		t.Errorf("DescribeMappings() =\n%s\nwant\n%s", got, expected)
+	}
+
+	summary := "HeaderMapper: 5 mappings (3 incoming, 1 outgoing, 1 bidirectional), skip paths: /health\n"
+	if got := mapper.String(); got != summary+expected {
+		t.Errorf("String() =\n%s", got)
+	}
+	if !strings.HasPrefix(NewBuilder().Build().String(), "HeaderMapper: 0 mappings") {
+		t.Errorf("String() of empty mapper = %q", NewBuilder().Build().String())
+	}
+}
@@ -0,0 +1,186 @@
+package headermapper
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// mappingsOptionNumber is the field number of the service_mappings and
+// mappings extensions declared in proto/headermapper/options.proto
+const mappingsOptionNumber protowire.Number = 51880
+
+// MethodMappingsFromDescriptors returns the mappings declared with the
+// headermapper proto options, keyed by full method name such as
+// /pkg.Service/Method. Mappings of a service apply to each of its methods,
+// followed by those of the method.
+func MethodMappingsFromDescriptors(files ...protoreflect.FileDescriptor) (map[string][]HeaderMapping, error) {
+	methods := make(map[string][]HeaderMapping)
+	err := rangeDescriptorMappings(files, func(method string, mappings []HeaderMapping) {
+		methods[method] = mappings
+	})
+	if err != nil {
+		return nil, err
+	}
+	return methods, nil
+}
+
+// LoadMappingsFromDescriptors builds a configuration from the mappings
+// declared with the headermapper proto options of the services and methods
+// in files, for gateways registering descriptors at runtime through
+// reflection or dynamic protos. Identical mappings declared on several
+// methods are included once; conflicting ones fail validation.
+//
+//	var files []protoreflect.FileDescriptor
+//	protoregistry.GlobalFiles.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+//		files = append(files, fd)
+//		return true
+//	})
+//	config, err := headermapper.LoadMappingsFromDescriptors(files...)
+func LoadMappingsFromDescriptors(files ...protoreflect.FileDescriptor) (*Config, error) {
+	config := &Config{}
+	err := rangeDescriptorMappings(files, func(_ string, mappings []HeaderMapping) {
+	next:
+		for _, mapping := range mappings {
+			for _, existing := range config.Mappings {
+				if sameMapping(existing, mapping) {
+					continue next
+				}
+			}
+			config.Mappings = append(config.Mappings, mapping)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateConfig(config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// rangeDescriptorMappings calls fn with the mappings of each method of
+// files that declares any, in declaration order
+func rangeDescriptorMappings(files []protoreflect.FileDescriptor, fn func(method string, mappings []HeaderMapping)) error {
+	for _, file := range files {
+		services := file.Services()
+		for i := 0; i < services.Len(); i++ {
+			service := services.Get(i)
+			serviceMappings, err := optionMappings(service)
+			if err != nil {
+				return err
+			}
+
+			methods := service.Methods()
+			for j := 0; j < methods.Len(); j++ {
+				method := methods.Get(j)
+				methodMappings, err := optionMappings(method)
+				if err != nil {
+					return err
+				}
+				if len(serviceMappings)+len(methodMappings) == 0 {
+					continue
+				}
+				mappings := append(serviceMappings[:len(serviceMappings):len(serviceMappings)], methodMappings...)
+				fn(fmt.Sprintf("/%s/%s", service.FullName(), method.Name()), mappings)
+			}
+		}
+	}
+	return nil
+}
+
+// optionMappings decodes the mappings declared in the options of desc. The
+// options are read from their wire form, so the extension is found whether
+// or not Go code was generated for options.proto.
+func optionMappings(desc protoreflect.Descriptor) ([]HeaderMapping, error) {
+	options := desc.Options()
+	if options == nil {
+		return nil, nil
+	}
+	raw, err := proto.Marshal(options)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", desc.FullName(), err)
+	}
+
+	var mappings []HeaderMapping
+	for len(raw) > 0 {
+		number, typ, n := protowire.ConsumeTag(raw)
+		if n < 0 {
+			return nil, fmt.Errorf("%s: malformed options: %w", desc.FullName(), protowire.ParseError(n))
+		}
+		raw = raw[n:]
+		if number != mappingsOptionNumber || typ != protowire.BytesType {
+			n = protowire.ConsumeFieldValue(number, typ, raw)
+			if n < 0 {
+				return nil, fmt.Errorf("%s: malformed options: %w", desc.FullName(), protowire.ParseError(n))
+			}
+			raw = raw[n:]
+			continue
+		}
+
+		rule, n := protowire.ConsumeBytes(raw)
+		if n < 0 {
+			return nil, fmt.Errorf("%s: malformed mapping: %w", desc.FullName(), protowire.ParseError(n))
+		}
+		raw = raw[n:]
+		mapping, err := decodeMappingRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", desc.FullName(), err)
+		}
+		mappings = append(mappings, mapping)
+	}
+	return mappings, nil
+}
+
+// decodeMappingRule decodes a HeaderMappingRule message
+func decodeMappingRule(raw []byte) (HeaderMapping, error) {
+	var mapping HeaderMapping
+	for len(raw) > 0 {
+		number, typ, n := protowire.ConsumeTag(raw)
+		if n < 0 {
+			return HeaderMapping{}, fmt.Errorf("malformed mapping: %w", protowire.ParseError(n))
+		}
+		raw = raw[n:]
+
+		var value []byte
+		var flag uint64
+		switch typ {
+		case protowire.BytesType:
+			value, n = protowire.ConsumeBytes(raw)
+		case protowire.VarintType:
+			flag, n = protowire.ConsumeVarint(raw)
+		default:
+			n = protowire.ConsumeFieldValue(number, typ, raw)
+		}
+		if n < 0 {
+			return HeaderMapping{}, fmt.Errorf("malformed mapping: %w", protowire.ParseError(n))
+		}
+		raw = raw[n:]
+
+		switch number {
+		case 1:
+			mapping.HTTPHeader = string(value)
+		case 2:
+			mapping.GRPCMetadata = string(value)
+		case 3:
+			if len(value) > 0 {
+				direction, err := ParseMappingDirection(string(value))
+				if err != nil {
+					return HeaderMapping{}, err
+				}
+				mapping.Direction = direction
+			}
+		case 4:
+			mapping.Required = flag != 0
+		case 5:
+			mapping.DefaultValue = string(value)
+		case 6:
+			mapping.Aliases = append(mapping.Aliases, string(value))
+		case 7:
+			mapping.AppendValues = flag != 0
+		}
+	}
+	return mapping, nil
+}
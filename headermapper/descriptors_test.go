@@ -0,0 +1,140 @@
+package headermapper
+
+import (
+	"errors"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// mappingOption encodes a mappings option with one HeaderMappingRule
+func mappingOption(header, key, direction string, required bool) []byte {
+	var rule []byte
+	rule = protowire.AppendTag(rule, 1, protowire.BytesType)
+	rule = protowire.AppendString(rule, header)
+	rule = protowire.AppendTag(rule, 2, protowire.BytesType)
+	rule = protowire.AppendString(rule, key)
+	if direction != "" {
+		rule = protowire.AppendTag(rule, 3, protowire.BytesType)
+		rule = protowire.AppendString(rule, direction)
+	}
+	if required {
+		rule = protowire.AppendTag(rule, 4, protowire.VarintType)
+		rule = protowire.AppendVarint(rule, 1)
+	}
+
+	var option []byte
+	option = protowire.AppendTag(option, mappingsOptionNumber, protowire.BytesType)
+	return protowire.AppendBytes(option, rule)
+}
+
+// testDescriptor builds a file with a service declaring serviceOption and
+// methods declaring the given options
+func testDescriptor(t *testing.T, serviceOption []byte, methodOptions map[string][]byte) protoreflect.FileDescriptor {
+	t.Helper()
+	service := &descriptorpb.ServiceDescriptorProto{
+		Name:    proto.String("UserService"),
+		Options: &descriptorpb.ServiceOptions{},
+	}
+	service.Options.ProtoReflect().SetUnknown(serviceOption)
+	for _, name := range []string{"GetUser", "ListUsers", "Ping"} {
+		options := &descriptorpb.MethodOptions{}
+		options.ProtoReflect().SetUnknown(methodOptions[name])
+		service.Method = append(service.Method, &descriptorpb.MethodDescriptorProto{
+			Name:       proto.String(name),
+			InputType:  proto.String(".test.Request"),
+			OutputType: proto.String(".test.Request"),
+			Options:    options,
+		})
+	}
+
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:        proto.String("test/user.proto"),
+		Package:     proto.String("test"),
+		Syntax:      proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{Name: proto.String("Request")}},
+		Service:     []*descriptorpb.ServiceDescriptorProto{service},
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewFile() error = %v", err)
+	}
+	return file
+}
+
+func TestLoadMappingsFromDescriptors(t *testing.T) {
+	file := testDescriptor(t,
+		mappingOption("X-Tenant-ID", "tenant-id", "", true),
+		map[string][]byte{
+			"GetUser": append(
+				mappingOption("X-User-ID", "user-id", "incoming", false),
+				mappingOption("X-Request-ID", "request-id", "bidirectional", false)...),
+			"ListUsers": mappingOption("X-User-ID", "user-id", "incoming", false),
+		})
+
+	methods, err := MethodMappingsFromDescriptors(file)
+	if err != nil {
+		t.Fatalf("MethodMappingsFromDescriptors() error = %v", err)
+	}
+	get := methods["/test.UserService/GetUser"]
+	if len(get) != 3 || get[0].GRPCMetadata != "tenant-id" || !get[0].Required || get[2].Direction != Bidirectional {
+		t.Errorf("GetUser mappings = %+v", get)
+	}
+	if ping := methods["/test.UserService/Ping"]; len(ping) != 1 || ping[0].GRPCMetadata != "tenant-id" {
+		t.Errorf("Ping mappings = %+v", ping)
+	}
+
+	config, err := LoadMappingsFromDescriptors(file)
+	if err != nil {
+		t.Fatalf("LoadMappingsFromDescriptors() error = %v", err)
+	}
+	keys := []string{"tenant-id", "user-id", "request-id"}
+	if len(config.Mappings) != len(keys) {
+		t.Fatalf("mappings = %+v", config.Mappings)
+	}
+	for i, key := range keys {
+		if config.Mappings[i].GRPCMetadata != key {
+			t.Errorf("mapping %d = %s, want %s", i, config.Mappings[i].GRPCMetadata, key)
+		}
+	}
+}
+
+func TestLoadMappingsFromDescriptors_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		methods map[string][]byte
+		wantErr error
+	}{
+		{
+			name: "conflicting mappings",
+			methods: map[string][]byte{
+				"GetUser":   mappingOption("X-User-ID", "user-id", "incoming", false),
+				"ListUsers": mappingOption("X-User-ID", "user-id", "incoming", true),
+			},
+			wantErr: ErrDuplicateMapping,
+		},
+		{
+			name:    "invalid direction",
+			methods: map[string][]byte{"GetUser": mappingOption("X-User-ID", "user-id", "sideways", false)},
+		},
+		{
+			name:    "malformed option",
+			methods: map[string][]byte{"GetUser": mappingOption("X-User-ID", "user-id", "", false)[:6]},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadMappingsFromDescriptors(testDescriptor(t, nil, tt.methods))
+			if err == nil {
+				t.Fatal("LoadMappingsFromDescriptors() error = nil")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
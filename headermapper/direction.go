@@ -0,0 +1,103 @@
+package headermapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// String returns the name of the direction
+func (d MappingDirection) String() string {
+	switch d {
+	case Incoming:
+		return "incoming"
+	case Outgoing:
+		return "outgoing"
+	case Bidirectional:
+		return "bidirectional"
+	}
+	return "MappingDirection(" + strconv.Itoa(int(d)) + ")"
+}
+
+// ParseMappingDirection parses a direction name, case-insensitively, or the
+// legacy numeric value
+func ParseMappingDirection(value string) (MappingDirection, error) {
+	value = strings.TrimSpace(value)
+	switch strings.ToLower(value) {
+	case "incoming":
+		return Incoming, nil
+	case "outgoing":
+		return Outgoing, nil
+	case "bidirectional":
+		return Bidirectional, nil
+	}
+	if n, err := strconv.Atoi(value); err == nil {
+		return validDirection(n)
+	}
+	return 0, fmt.Errorf("unknown mapping direction: %q", value)
+}
+
+// validDirection converts a legacy numeric direction
+func validDirection(n int) (MappingDirection, error) {
+	d := MappingDirection(n)
+	if d < Incoming || d > Bidirectional {
+		return 0, fmt.Errorf("unknown mapping direction: %d", n)
+	}
+	return d, nil
+}
+
+// MarshalJSON encodes the direction by name
+func (d MappingDirection) MarshalJSON() ([]byte, error) {
+	if _, err := validDirection(int(d)); err != nil {
+		return nil, err
+	}
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON accepts a direction name or the legacy numeric value
+func (d *MappingDirection) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		parsed, err := ParseMappingDirection(name)
+		if err != nil {
+			return err
+		}
+		*d = parsed
+		return nil
+	}
+
+	var n int
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("mapping direction must be a name or number: %s", data)
+	}
+	parsed, err := validDirection(n)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// MarshalYAML encodes the direction by name
+func (d MappingDirection) MarshalYAML() (interface{}, error) {
+	if _, err := validDirection(int(d)); err != nil {
+		return nil, err
+	}
+	return d.String(), nil
+}
+
+// UnmarshalYAML accepts a direction name or the legacy numeric value
+func (d *MappingDirection) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: mapping direction must be a scalar", value.Line)
+	}
+	parsed, err := ParseMappingDirection(value.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", value.Line, err)
+	}
+	*d = parsed
+	return nil
+}
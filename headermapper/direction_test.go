@@ -0,0 +1,86 @@
+package headermapper
+
+import (
+	"encoding/json"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestMappingDirection_String(t *testing.T) {
+	tests := []struct {
+		direction MappingDirection
+		expected  string
+	}{
+		{Incoming, "incoming"},
+		{Outgoing, "outgoing"},
+		{Bidirectional, "bidirectional"},
+		{MappingDirection(7), "MappingDirection(7)"},
+	}
+	for _, tt := range tests {
+		if got := tt.direction.String(); got != tt.expected {
+			t.Errorf("String() = %q, want %q", got, tt.expected)
+		}
+	}
+}
+
+func TestMappingDirection_Unmarshal(t *testing.T) {
+	tests := []struct {
+		name     string
+		json     string
+		yaml     string
+		expected MappingDirection
+		wantErr  bool
+	}{
+		{"name", `"outgoing"`, `outgoing`, Outgoing, false},
+		{"mixed case", `"Bidirectional"`, `Bidirectional`, Bidirectional, false},
+		{"legacy number", `2`, `2`, Bidirectional, false},
+		{"legacy quoted number", `"1"`, `"1"`, Outgoing, false},
+		{"unknown name", `"sideways"`, `sideways`, 0, true},
+		{"out of range", `5`, `5`, 0, true},
+		{"wrong type", `true`, `[incoming]`, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fromJSON MappingDirection
+			err := json.Unmarshal([]byte(tt.json), &fromJSON)
+			if (err != nil) != tt.wantErr || fromJSON != tt.expected {
+				t.Errorf("json = %v, %v, want %v, wantErr %v", fromJSON, err, tt.expected, tt.wantErr)
+			}
+
+			var fromYAML MappingDirection
+			err = yaml.Unmarshal([]byte(tt.yaml), &fromYAML)
+			if (err != nil) != tt.wantErr || fromYAML != tt.expected {
+				t.Errorf("yaml = %v, %v, want %v, wantErr %v", fromYAML, err, tt.expected, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMappingDirection_Marshal(t *testing.T) {
+	mapping := HeaderMapping{HTTPHeader: "X-Request-ID", GRPCMetadata: "request-id", Direction: Bidirectional}
+
+	data, err := json.Marshal(mapping)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	var decoded map[string]interface{}
+	json.Unmarshal(data, &decoded)
+	if decoded["direction"] != "bidirectional" {
+		t.Errorf("json direction = %v", decoded["direction"])
+	}
+
+	out, err := yaml.Marshal(mapping)
+	if err != nil {
+		t.Fatalf("yaml.Marshal() error = %v", err)
+	}
+	var roundTrip HeaderMapping
+	if err := yaml.Unmarshal(out, &roundTrip); err != nil || roundTrip.Direction != Bidirectional {
+		t.Errorf("yaml round trip = %+v, %v\n%s", roundTrip, err, out)
+	}
+
+	if _, err := json.Marshal(MappingDirection(9)); err == nil {
+		t.Error("json.Marshal() of unknown direction succeeded")
+	}
+}
@@ -0,0 +1,139 @@
+package headermapper
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Metadata keys set by download endpoints for DownloadMappings
+const (
+	DownloadFilenameKey      = "download-filename"
+	DownloadContentTypeKey   = "download-content-type"
+	DownloadContentLengthKey = "download-content-length"
+)
+
+// defaultDownloadFilename replaces filenames that are empty once sanitized
+const defaultDownloadFilename = "download"
+
+// DownloadMappings returns outgoing mappings for methods returning
+// google.api.HttpBody: the filename becomes an attachment Content-Disposition
+// (see ContentDisposition), and the content type and length fill in
+// Content-Type and Content-Length. The gateway sets Content-Type from the
+// HttpBody's content_type, so the metadata only applies when it is empty,
+// unless OverwriteExisting is set.
+//
+//	grpc.SetHeader(ctx, metadata.Pairs(
+//		headermapper.DownloadFilenameKey, "Q3 résumé.pdf",
+//		headermapper.DownloadContentLengthKey, strconv.Itoa(len(data)),
+//	))
+//	return &httpbody.HttpBody{ContentType: "application/pdf", Data: data}, nil
+func DownloadMappings() []HeaderMapping {
+	return []HeaderMapping{
+		{
+			HTTPHeader:   "Content-Disposition",
+			GRPCMetadata: DownloadFilenameKey,
+			Direction:    Outgoing,
+			Transform:    ContentDisposition,
+		},
+		{
+			HTTPHeader:   "Content-Type",
+			GRPCMetadata: DownloadContentTypeKey,
+			Direction:    Outgoing,
+		},
+		{
+			HTTPHeader:   "Content-Length",
+			GRPCMetadata: DownloadContentLengthKey,
+			Direction:    Outgoing,
+		},
+	}
+}
+
+// ContentDisposition converts a filename into an attachment
+// Content-Disposition value. The filename is sanitized with
+// SanitizeFilename; non-ASCII names are sent in the RFC 5987 filename*
+// parameter with an ASCII fallback in filename:
+//
+//	résumé.pdf  ->  attachment; filename="r_sum_.pdf"; filename*=UTF-8''r%C3%A9sum%C3%A9.pdf
+func ContentDisposition(value string) string {
+	return contentDisposition("attachment", value)
+}
+
+// InlineContentDisposition is ContentDisposition for content displayed by
+// the browser rather than saved
+func InlineContentDisposition(value string) string {
+	return contentDisposition("inline", value)
+}
+
+func contentDisposition(dispositionType, filename string) string {
+	filename = SanitizeFilename(filename)
+
+	var b strings.Builder
+	b.WriteString(dispositionType)
+	b.WriteString(`; filename="`)
+	ascii := true
+	for _, r := range filename {
+		if r >= utf8.RuneSelf {
+			ascii = false
+			r = '_'
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('"')
+
+	if !ascii {
+		b.WriteString("; filename*=UTF-8''")
+		b.WriteString(encodeRFC5987(filename))
+	}
+	return b.String()
+}
+
+// SanitizeFilename reduces a client-facing filename to its last path
+// element and removes control characters, quotes and separators that could
+// break out of a Content-Disposition header or traverse directories
+func SanitizeFilename(value string) string {
+	if i := strings.LastIndexAny(value, `/\`); i >= 0 {
+		value = value[i+1:]
+	}
+	value = strings.Map(func(r rune) rune {
+		switch {
+		case r == utf8.RuneError, unicode.IsControl(r):
+			return -1
+		case r == '"', r == ';', r == ':', r == '*', r == '?', r == '<', r == '>', r == '|':
+			return '_'
+		}
+		return r
+	}, value)
+
+	value = strings.Trim(value, " .")
+	if value == "" {
+		return defaultDownloadFilename
+	}
+	return value
+}
+
+// encodeRFC5987 percent-encodes value except for RFC 5987 attr-chars
+func encodeRFC5987(value string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if isAttrChar(c) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0x0f])
+	}
+	return b.String()
+}
+
+// isAttrChar reports whether c may appear unencoded in an RFC 5987 value
+func isAttrChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", c) >= 0
+}
@@ -0,0 +1,117 @@
+package headermapper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/genproto/googleapis/api/httpbody"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"report.pdf", "report.pdf"},
+		{"../../etc/passwd", "passwd"},
+		{`C:\Users\me\report.pdf`, "report.pdf"},
+		{"a\"b;c.txt", "a_b_c.txt"},
+		{"line\r\nbreak.txt", "linebreak.txt"},
+		{" .hidden. ", "hidden"},
+		{"résumé.pdf", "résumé.pdf"},
+		{"..", defaultDownloadFilename},
+		{"", defaultDownloadFilename},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := SanitizeFilename(tt.input); got != tt.expected {
+				t.Errorf("got %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestContentDisposition(t *testing.T) {
+	tests := []struct {
+		name      string
+		transform TransformFunc
+		input     string
+		expected  string
+	}{
+		{"ascii", ContentDisposition, "report.pdf", `attachment; filename="report.pdf"`},
+		{"non-ascii", ContentDisposition, "résumé.pdf",
+			`attachment; filename="r_sum_.pdf"; filename*=UTF-8''r%C3%A9sum%C3%A9.pdf`},
+		{"spaces encoded", ContentDisposition, "Q3 résumé.pdf",
+			`attachment; filename="Q3 r_sum_.pdf"; filename*=UTF-8''Q3%20r%C3%A9sum%C3%A9.pdf`},
+		{"sanitized", ContentDisposition, `../a"b.txt`, `attachment; filename="a_b.txt"`},
+		{"inline", InlineContentDisposition, "chart.png", `inline; filename="chart.png"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.transform(tt.input); got != tt.expected {
+				t.Errorf("got %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDownloadMappings_HttpBody(t *testing.T) {
+	mapper := NewBuilder().AddMappings(DownloadMappings()...).Build()
+	if err := mapper.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	mux := CreateGatewayMux(mapper)
+
+	tests := []struct {
+		name        string
+		body        *httpbody.HttpBody
+		md          metadata.MD
+		contentType string
+	}{
+		{
+			name:        "body content type wins",
+			body:        &httpbody.HttpBody{ContentType: "application/pdf", Data: []byte("%PDF")},
+			md:          metadata.Pairs(DownloadFilenameKey, "résumé.pdf", DownloadContentTypeKey, "text/plain", DownloadContentLengthKey, "4"),
+			contentType: "application/pdf",
+		},
+		{
+			name:        "metadata fills in content type",
+			body:        &httpbody.HttpBody{Data: []byte("%PDF")},
+			md:          metadata.Pairs(DownloadFilenameKey, "résumé.pdf", DownloadContentTypeKey, "application/pdf", DownloadContentLengthKey, "4"),
+			contentType: "application/pdf",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/v1/files/1:download", nil)
+			ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{HeaderMD: tt.md})
+			_, outbound := runtime.MarshalerForRequest(mux, req)
+			w := httptest.NewRecorder()
+			runtime.ForwardResponseMessage(ctx, mux, outbound, w, req, tt.body, mapper.ResponseModifier())
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %q", w.Code, w.Body.String())
+			}
+			expected := map[string]string{
+				"Content-Type":        tt.contentType,
+				"Content-Length":      "4",
+				"Content-Disposition": `attachment; filename="r_sum_.pdf"; filename*=UTF-8''r%C3%A9sum%C3%A9.pdf`,
+			}
+			for header, want := range expected {
+				if got := w.Header().Get(header); got != want {
+					t.Errorf("%s = %q, want %q", header, got, want)
+				}
+			}
+			if got := w.Body.String(); got != "%PDF" {
+				t.Errorf("body = %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package headermapper
+
+import (
+	"fmt"
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+)
+
+// DuplicateHeaderPolicy determines how a header sent several times with
+// conflicting values is handled
+type DuplicateHeaderPolicy string
+
+const (
+	// DuplicateHeaderFirst uses the first value (default)
+	DuplicateHeaderFirst DuplicateHeaderPolicy = "first"
+	// DuplicateHeaderLast uses the last value
+	DuplicateHeaderLast DuplicateHeaderPolicy = "last"
+	// DuplicateHeaderReject rejects the request
+	DuplicateHeaderReject DuplicateHeaderPolicy = "reject"
+)
+
+// validate checks the policy name
+func (p DuplicateHeaderPolicy) validate() error {
+	switch p {
+	case "", DuplicateHeaderFirst, DuplicateHeaderLast, DuplicateHeaderReject:
+		return nil
+	}
+	return fmt.Errorf("unknown duplicate header policy: %s", p)
+}
+
+// selectValue returns the value of an incoming header from its values
+// according to the duplicate header policy
+func (cc *compiledConfig) selectValue(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	if cc.config.DuplicateHeaders == DuplicateHeaderLast {
+		return values[len(values)-1]
+	}
+	return values[0]
+}
+
+// duplicateHeaderCheck rejects requests repeating a mapped header or
+// Authorization with conflicting values. Go's HTTP server already rejects
+// requests with several Host headers.
+func (hm *HeaderMapper) duplicateHeaderCheck(w http.ResponseWriter, req *http.Request) error {
+	names := []string{"Authorization"}
+	for _, src := range hm.state().index.sources {
+		names = append(names, src.header)
+	}
+
+	for _, name := range names {
+		values := req.Header.Values(name)
+		for _, value := range values {
+			if value != values[0] {
+				return rejectf(codes.InvalidArgument, "conflicting values for header %s", http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package headermapper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDuplicateHeaders_Policy(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   DuplicateHeaderPolicy
+		expected string
+	}{
+		{"default", "", "user-1"},
+		{"first", DuplicateHeaderFirst, "user-1"},
+		{"last", DuplicateHeaderLast, "user-2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper := NewBuilder().
+				AddIncomingMapping("X-User-ID", "user-id").
+				DuplicateHeaders(tt.policy).
+				Build()
+
+			req := httptest.NewRequest("GET", "/v1/echo", nil)
+			req.Header.Add("X-User-ID", "user-1")
+			req.Header.Add("X-User-ID", "user-2")
+
+			md := mapper.MetadataAnnotator()(context.Background(), req)
+			if got := md.Get("user-id"); len(got) != 1 || got[0] != tt.expected {
+				t.Errorf("user-id = %v, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDuplicateHeaders_Reject(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").
+		DuplicateHeaders(DuplicateHeaderReject).
+		Build()
+	handler := mapper.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name     string
+		headers  [][2]string
+		expected int
+	}{
+		{"single value", [][2]string{{"X-User-ID", "user-1"}}, http.StatusOK},
+		{"identical duplicates", [][2]string{{"X-User-ID", "user-1"}, {"X-User-ID", "user-1"}}, http.StatusOK},
+		{"conflicting mapped header", [][2]string{{"X-User-ID", "user-1"}, {"X-User-ID", "admin"}}, http.StatusBadRequest},
+		{"conflicting authorization", [][2]string{{"Authorization", "Bearer a"}, {"Authorization", "Bearer b"}}, http.StatusBadRequest},
+		{"unmapped header", [][2]string{{"X-Other", "a"}, {"X-Other", "b"}}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/v1/echo", nil)
+			for _, h := range tt.headers {
+				req.Header.Add(h[0], h[1])
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.expected {
+				t.Errorf("Handler() status = %d, want %d", w.Code, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDuplicateHeaders_Validate(t *testing.T) {
+	if err := ValidateConfig(&Config{DuplicateHeaders: "random"}); err == nil {
+		t.Error("ValidateConfig() expected error for unknown policy")
+	}
+}
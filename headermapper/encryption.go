@@ -0,0 +1,196 @@
+package headermapper
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// encryptedPrefix marks encrypted metadata values: enc:v1:<key id>:<base64 nonce+ciphertext>
+const encryptedPrefix = "enc:v1:"
+
+// EncryptionConfig configures envelope encryption of selected metadata values.
+// The gateway encrypts the values after mapping and the server interceptors
+// decrypt them, so they are opaque to intermediate proxies and logs.
+type EncryptionConfig struct {
+	// Keys lists the metadata keys whose values are encrypted
+	Keys []string `json:"keys" yaml:"keys"`
+	// RequireEncrypted rejects calls carrying plaintext values for the keys
+	RequireEncrypted bool `json:"require_encrypted" yaml:"require_encrypted"`
+	// Provider supplies the data keys
+	Provider DataKeyProvider `json:"-" yaml:"-"`
+}
+
+// validate checks the keys and provider
+func (ec *EncryptionConfig) validate() error {
+	if len(ec.Keys) == 0 {
+		return fmt.Errorf("encryption: keys cannot be empty")
+	}
+	if ec.Provider == nil {
+		return fmt.Errorf("encryption: provider cannot be nil")
+	}
+	return nil
+}
+
+// DataKeyProvider supplies AES data keys. A KMS-backed implementation
+// typically caches data keys unwrapped by the KMS.
+type DataKeyProvider interface {
+	// CurrentKey returns the key used to encrypt new values
+	CurrentKey(ctx context.Context) (keyID string, key []byte, err error)
+	// Key returns the key with the given ID for decryption
+	Key(ctx context.Context, keyID string) ([]byte, error)
+}
+
+// StaticKeyProvider is a DataKeyProvider holding fixed keys, supporting
+// rotation by adding a new current key while keeping older ones for decryption
+type StaticKeyProvider struct {
+	current string
+	keys    map[string][]byte
+}
+
+// NewStaticKeyProvider creates a provider encrypting with keys[currentID]
+func NewStaticKeyProvider(currentID string, keys map[string][]byte) *StaticKeyProvider {
+	return &StaticKeyProvider{current: currentID, keys: keys}
+}
+
+// CurrentKey implements DataKeyProvider
+func (p *StaticKeyProvider) CurrentKey(ctx context.Context) (string, []byte, error) {
+	key, err := p.Key(ctx, p.current)
+	return p.current, key, err
+}
+
+// Key implements DataKeyProvider
+func (p *StaticKeyProvider) Key(ctx context.Context, keyID string) ([]byte, error) {
+	key, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown data key: %s", keyID)
+	}
+	return key, nil
+}
+
+// EncryptValue encrypts a metadata value with AES-GCM, binding it to the key name
+func EncryptValue(ctx context.Context, provider DataKeyProvider, key, value string) (string, error) {
+	keyID, dataKey, err := provider.CurrentKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(value), []byte(strings.ToLower(key)))
+	return encryptedPrefix + keyID + ":" + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptValue decrypts a value produced by EncryptValue for the same key name
+func DecryptValue(ctx context.Context, provider DataKeyProvider, key, value string) (string, error) {
+	rest, ok := strings.CutPrefix(value, encryptedPrefix)
+	if !ok {
+		return "", fmt.Errorf("value is not encrypted")
+	}
+	keyID, encoded, ok := strings.Cut(rest, ":")
+	if !ok {
+		return "", fmt.Errorf("malformed encrypted value")
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	dataKey, err := provider.Key(ctx, keyID)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("ciphertext too short")
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(strings.ToLower(key)))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// metadataEncryptor enforces an EncryptionConfig
+type metadataEncryptor struct {
+	config *EncryptionConfig
+	hm     *HeaderMapper
+}
+
+// encrypt replaces the configured values in md with ciphertext. Values that
+// cannot be encrypted are dropped rather than forwarded in plaintext.
+func (e *metadataEncryptor) encrypt(ctx context.Context, md metadata.MD) {
+	for _, key := range e.config.Keys {
+		values := md.Get(key)
+		if len(values) == 0 {
+			continue
+		}
+
+		encrypted := make([]string, 0, len(values))
+		for _, value := range values {
+			ciphertext, err := EncryptValue(ctx, e.config.Provider, key, value)
+			if err != nil {
+				e.hm.log().Error("Failed to encrypt metadata:", key, err)
+				continue
+			}
+			encrypted = append(encrypted, ciphertext)
+		}
+		md.Delete(key)
+		if len(encrypted) > 0 {
+			md.Set(key, encrypted...)
+		}
+	}
+}
+
+// processIncoming decrypts the configured values before other hooks see them
+func (e *metadataEncryptor) processIncoming(ctx context.Context, md metadata.MD) error {
+	for _, key := range e.config.Keys {
+		values := md.Get(key)
+		if len(values) == 0 {
+			continue
+		}
+
+		decrypted := make([]string, len(values))
+		for i, value := range values {
+			if !strings.HasPrefix(value, encryptedPrefix) {
+				if e.config.RequireEncrypted {
+					return rejectf(codes.Unauthenticated, "metadata %s must be encrypted", key)
+				}
+				decrypted[i] = value
+				continue
+			}
+			plaintext, err := DecryptValue(ctx, e.config.Provider, key, value)
+			if err != nil {
+				return rejectf(codes.Unauthenticated, "invalid encrypted metadata: %s", key)
+			}
+			decrypted[i] = plaintext
+		}
+		md.Set(key, decrypted...)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package headermapper
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestEncryption_RoundTrip(t *testing.T) {
+	provider := NewStaticKeyProvider("k2", map[string][]byte{
+		"k1": bytes.Repeat([]byte{1}, 32),
+		"k2": bytes.Repeat([]byte{2}, 32),
+	})
+	config := &EncryptionConfig{Keys: []string{"user-ssn"}, Provider: provider}
+
+	gateway := NewBuilder().
+		AddIncomingMapping("X-User-SSN", "user-ssn").
+		AddIncomingMapping("X-User-ID", "user-id").
+		EncryptMetadata(config).
+		Build()
+
+	req := httptest.NewRequest("GET", "/v1/echo", nil)
+	req.Header.Set("X-User-SSN", "123-45-6789")
+	req.Header.Set("X-User-ID", "user-1")
+	md := gateway.MetadataAnnotator()(context.Background(), req)
+
+	encrypted := md.Get("user-ssn")[0]
+	if !strings.HasPrefix(encrypted, "enc:v1:k2:") || strings.Contains(encrypted, "6789") {
+		t.Fatalf("expected encrypted value, got %q", encrypted)
+	}
+	if md.Get("user-id")[0] != "user-1" {
+		t.Errorf("unconfigured key should stay in plaintext")
+	}
+
+	backend := NewBuilder().EncryptMetadata(config).Build()
+	var got string
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		incoming, _ := metadata.FromIncomingContext(ctx)
+		got = incoming.Get("user-ssn")[0]
+		return nil, nil
+	}
+	_, err := backend.UnaryServerInterceptor()(metadata.NewIncomingContext(context.Background(), md), nil,
+		&grpc.UnaryServerInfo{FullMethod: "/echo.v1.EchoService/Echo"}, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "123-45-6789" {
+		t.Errorf("decrypted value = %q", got)
+	}
+}
+
+func TestEncryption_Rejections(t *testing.T) {
+	provider := NewStaticKeyProvider("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)})
+	backend := NewBuilder().
+		EncryptMetadata(&EncryptionConfig{Keys: []string{"user-ssn"}, Provider: provider, RequireEncrypted: true}).
+		Build()
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+
+	valid, err := EncryptValue(context.Background(), provider, "user-ssn", "123-45-6789")
+	if err != nil {
+		t.Fatal(err)
+	}
+	swapped, _ := EncryptValue(context.Background(), provider, "user-id", "123-45-6789")
+
+	tests := []struct {
+		name     string
+		value    string
+		expected codes.Code
+	}{
+		{"valid", valid, codes.OK},
+		{"plaintext", "123-45-6789", codes.Unauthenticated},
+		{"tampered", valid[:len(valid)-2] + "AA", codes.Unauthenticated},
+		{"bound to another key", swapped, codes.Unauthenticated},
+		{"unknown key id", "enc:v1:k9:" + strings.SplitN(valid, ":", 4)[3], codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("user-ssn", tt.value))
+			_, err := backend.UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/echo.v1.EchoService/Echo"}, handler)
+			if status.Code(err) != tt.expected {
+				t.Errorf("UnaryServerInterceptor() code = %v, want %v", status.Code(err), tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package headermapper
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors wrapped by MappingError, for use with errors.Is
+var (
+	// ErrValidationFailed reports an invalid mapping
+	ErrValidationFailed = errors.New("validation failed")
+	// ErrDuplicateMapping reports two mappings of the same header and metadata key
+	ErrDuplicateMapping = errors.New("duplicate mapping")
+	// ErrRequiredHeaderMissing reports a request without the header of a required mapping
+	ErrRequiredHeaderMissing = errors.New("required header missing")
+	// ErrRequiredMetadataMissing reports a response without the metadata of a required mapping
+	ErrRequiredMetadataMissing = errors.New("required metadata missing")
+	// ErrTransformFailed reports a transform that could not produce a value
+	ErrTransformFailed = errors.New("transform failed")
+	// ErrMappingNotFound reports a runtime change naming no active mapping
+	ErrMappingNotFound = errors.New("mapping not found")
+)
+
+// MappingError describes a failure of a single mapping. Err is one of the
+// sentinel errors, so callers can test with errors.Is and retrieve the
+// details with errors.As.
+type MappingError struct {
+	Header      string
+	MetadataKey string
+	Direction   MappingDirection
+	// Reason describes the failure; Err is used when it is empty
+	Reason string
+	Err    error
+}
+
+// newMappingError creates a MappingError for mapping
+func newMappingError(mapping HeaderMapping, err error, reason string) *MappingError {
+	header := mapping.HTTPHeader
+	if header == "" {
+		header = mapping.HTTPHeaderPattern
+	}
+	if header == "" && isParamMapping(mapping) {
+		header = paramLabel(mapping)
+	}
+	return &MappingError{
+		Header:      header,
+		MetadataKey: mapping.GRPCMetadata,
+		Direction:   mapping.Direction,
+		Reason:      reason,
+		Err:         err,
+	}
+}
+
+// Error implements the error interface
+func (e *MappingError) Error() string {
+	reason := e.Reason
+	if reason == "" && e.Err != nil {
+		reason = e.Err.Error()
+	}
+	if e.Header == "" || e.MetadataKey == "" {
+		return reason
+	}
+	return fmt.Sprintf("%s -> %s: %s", e.Header, e.MetadataKey, reason)
+}
+
+// Unwrap returns the sentinel error
+func (e *MappingError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,86 @@
+package headermapper
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMappingError(t *testing.T) {
+	tests := []struct {
+		name     string
+		mappings []HeaderMapping
+		sentinel error
+		header   string
+		key      string
+	}{
+		{
+			name:     "invalid header name",
+			mappings: []HeaderMapping{{HTTPHeader: "X User", GRPCMetadata: "user", Direction: Outgoing}},
+			sentinel: ErrValidationFailed,
+			header:   "X User",
+			key:      "user",
+		},
+		{
+			name:     "invalid metadata key",
+			mappings: []HeaderMapping{{HTTPHeader: "X-User", GRPCMetadata: "user id", Direction: Outgoing}},
+			sentinel: ErrValidationFailed,
+			header:   "X-User",
+			key:      "user id",
+		},
+		{
+			name:     "empty metadata key",
+			mappings: []HeaderMapping{{HTTPHeader: "X-User", Direction: Outgoing}},
+			sentinel: ErrValidationFailed,
+			header:   "X-User",
+		},
+		{
+			name: "duplicate",
+			mappings: []HeaderMapping{
+				{HTTPHeader: "X-User", GRPCMetadata: "user", Direction: Incoming},
+				{HTTPHeader: "X-User", GRPCMetadata: "user", Direction: Outgoing},
+			},
+			sentinel: ErrDuplicateMapping,
+			header:   "X-User",
+			key:      "user",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateConfig(&Config{Mappings: tt.mappings})
+			if !errors.Is(err, tt.sentinel) {
+				t.Fatalf("ValidateConfig() error = %v, want %v", err, tt.sentinel)
+			}
+			var mappingErr *MappingError
+			if !errors.As(err, &mappingErr) {
+				t.Fatalf("error %T is not a MappingError", err)
+			}
+			if mappingErr.Header != tt.header || mappingErr.MetadataKey != tt.key || mappingErr.Direction != Outgoing {
+				t.Errorf("MappingError = %+v", mappingErr)
+			}
+		})
+	}
+}
+
+func TestMappingError_Validate(t *testing.T) {
+	mapper := NewHeaderMapper(&Config{Mappings: []HeaderMapping{{HTTPHeader: "X User", GRPCMetadata: "user"}}})
+
+	err := mapper.Validate()
+	if !errors.Is(err, ErrValidationFailed) {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if got, want := err.Error(), "mapping 0: X User -> user: invalid HTTP header name"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestMappingError_DuplicateDirections(t *testing.T) {
+	err := ValidateConfig(&Config{Mappings: []HeaderMapping{
+		{HTTPHeader: "X-User", GRPCMetadata: "user", Direction: Incoming},
+		{HTTPHeader: "X-User", GRPCMetadata: "user", Direction: Bidirectional},
+	}})
+	if err == nil || !strings.Contains(err.Error(), "directions: incoming, bidirectional") {
+		t.Errorf("ValidateConfig() error = %v, want direction names", err)
+	}
+}
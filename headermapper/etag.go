@@ -0,0 +1,244 @@
+package headermapper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// Metadata keys of conditional requests and their validators
+const (
+	ETagKey            = "etag"
+	LastModifiedKey    = "last-modified"
+	IfNoneMatchKey     = "if-none-match"
+	IfModifiedSinceKey = "if-modified-since"
+	// NotModifiedKey is set to true by backends that found the client's
+	// representation current
+	NotModifiedKey = "not-modified"
+)
+
+// ConditionalMappings returns mappings forwarding If-None-Match and
+// If-Modified-Since to backends and exposing their validators as ETag and
+// Last-Modified response headers
+func ConditionalMappings() []HeaderMapping {
+	return []HeaderMapping{
+		{
+			HTTPHeader:   "If-None-Match",
+			GRPCMetadata: IfNoneMatchKey,
+			Direction:    Incoming,
+		},
+		{
+			HTTPHeader:   "If-Modified-Since",
+			GRPCMetadata: IfModifiedSinceKey,
+			Direction:    Incoming,
+		},
+		{
+			HTTPHeader:   "ETag",
+			GRPCMetadata: ETagKey,
+			Direction:    Outgoing,
+			Transform:    FormatETag,
+		},
+		{
+			HTTPHeader:   "Last-Modified",
+			GRPCMetadata: LastModifiedKey,
+			Direction:    Outgoing,
+			Transform:    FormatHTTPTime,
+		},
+	}
+}
+
+// FormatETag quotes an entity tag unless it already is a quoted, possibly
+// weak, entity tag
+func FormatETag(value string) string {
+	value = strings.TrimSpace(value)
+	if tag := weakETag(value); value == "" || len(tag) > 1 && tag[0] == '"' && tag[len(tag)-1] == '"' {
+		return value
+	}
+	return `"` + strings.ReplaceAll(value, `"`, "") + `"`
+}
+
+// FormatHTTPTime converts a Unix timestamp or an RFC 3339 time into an
+// HTTP-date; other values are returned unchanged
+func FormatHTTPTime(value string) string {
+	value = strings.TrimSpace(value)
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC().Format(http.TimeFormat)
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC().Format(http.TimeFormat)
+	}
+	return value
+}
+
+// ETagConfig configures entity tags and conditional GET handling
+type ETagConfig struct {
+	// Compute derives an ETag from the response message when the backend sends none
+	Compute bool `json:"compute,omitempty" yaml:"compute,omitempty"`
+	// Weak marks computed ETags as weak validators
+	Weak bool `json:"weak,omitempty" yaml:"weak,omitempty"`
+	// NotModified makes Handler answer GET and HEAD requests with 304 Not
+	// Modified when the response ETag matches If-None-Match, its
+	// Last-Modified is not after If-Modified-Since or the backend sets the
+	// NotModifiedKey metadata to true
+	NotModified bool `json:"not_modified,omitempty" yaml:"not_modified,omitempty"`
+	// NotModifiedKey names the metadata signalling a match (default not-modified)
+	NotModifiedKey string `json:"not_modified_key,omitempty" yaml:"not_modified_key,omitempty"`
+}
+
+// validate checks the not-modified metadata key
+func (ec *ETagConfig) validate() error {
+	if ec.NotModifiedKey != "" && !validMetadataKey(ec.NotModifiedKey) {
+		return fmt.Errorf("etag: invalid metadata key %q", ec.NotModifiedKey)
+	}
+	return nil
+}
+
+// conditionalResponses computes ETags and answers conditional requests
+type conditionalResponses struct {
+	config         *ETagConfig
+	notModifiedKey string
+}
+
+func newConditionalResponses(config *ETagConfig) *conditionalResponses {
+	key := strings.ToLower(config.NotModifiedKey)
+	if key == "" {
+		key = NotModifiedKey
+	}
+	return &conditionalResponses{config: config, notModifiedKey: key}
+}
+
+// conditionalRequest carries the backend's not-modified signal from the
+// response modifier to Handler
+type conditionalRequest struct {
+	notModified bool
+}
+
+type conditionalRequestKey struct{}
+
+// apply sets a computed ETag and records the backend's not-modified signal;
+// it runs after the outgoing mappings so a mapped ETag takes precedence
+func (c *conditionalResponses) apply(ctx context.Context, w http.ResponseWriter, md metadata.MD, msg proto.Message) {
+	if c.config.Compute && msg != nil && w.Header().Get("ETag") == "" {
+		if tag, err := computeETag(msg, c.config.Weak); err == nil {
+			w.Header().Set("ETag", tag)
+		}
+	}
+	if cr, ok := ctx.Value(conditionalRequestKey{}).(*conditionalRequest); ok {
+		if values := md.Get(c.notModifiedKey); len(values) > 0 {
+			cr.notModified, _ = strconv.ParseBool(values[0])
+		}
+	}
+}
+
+// computeETag hashes the deterministic encoding of msg
+func computeETag(msg proto.Message, weak bool) (string, error) {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	tag := `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+	if weak {
+		tag = "W/" + tag
+	}
+	return tag, nil
+}
+
+// handler replaces successful responses to conditional GET and HEAD
+// requests with 304 Not Modified when the client's representation is current
+func (c *conditionalResponses) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !c.config.NotModified || (req.Method != http.MethodGet && req.Method != http.MethodHead) {
+			next.ServeHTTP(w, req)
+			return
+		}
+		cr := &conditionalRequest{}
+		cw := &conditionalWriter{ResponseWriter: w, req: req, request: cr}
+		next.ServeHTTP(cw, req.WithContext(context.WithValue(req.Context(), conditionalRequestKey{}, cr)))
+	})
+}
+
+// conditionalWriter decides between the response and 304 Not Modified when
+// the status is written, once the response headers are final
+type conditionalWriter struct {
+	http.ResponseWriter
+	req         *http.Request
+	request     *conditionalRequest
+	wroteHeader bool
+	discard     bool
+}
+
+func (cw *conditionalWriter) WriteHeader(code int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	if code == http.StatusOK && (cw.request.notModified || notModified(cw.req, cw.Header())) {
+		h := cw.Header()
+		for _, header := range []string{"Content-Type", "Content-Length", "Transfer-Encoding"} {
+			h.Del(header)
+		}
+		cw.discard = true
+		code = http.StatusNotModified
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *conditionalWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.discard {
+		return len(b), nil
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+func (cw *conditionalWriter) Flush() {
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok && !cw.discard {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying writer for http.ResponseController
+func (cw *conditionalWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// notModified evaluates If-None-Match against the ETag of the response or,
+// without it, If-Modified-Since against Last-Modified (RFC 9110 13.2.2)
+func notModified(req *http.Request, h http.Header) bool {
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		etag := h.Get("ETag")
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || weakETag(candidate) == weakETag(etag) {
+				return true
+			}
+		}
+		return false
+	}
+
+	since, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(h.Get("Last-Modified"))
+	return err == nil && !modified.After(since)
+}
+
+// weakETag strips the weak indicator for weak comparison
+func weakETag(tag string) string {
+	return strings.TrimPrefix(tag, "W/")
+}
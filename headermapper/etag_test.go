@@ -0,0 +1,236 @@
+package headermapper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestFormatETag(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"v1", `"v1"`},
+		{`"v1"`, `"v1"`},
+		{`W/"v1"`, `W/"v1"`},
+		{`v"1`, `"v1"`},
+		{`"`, `""`},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := FormatETag(tt.input); got != tt.expected {
+				t.Errorf("got %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFormatHTTPTime(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1445412480", "Wed, 21 Oct 2015 07:28:00 GMT"},
+		{"2015-10-21T09:28:00+02:00", "Wed, 21 Oct 2015 07:28:00 GMT"},
+		{"Wed, 21 Oct 2015 07:28:00 GMT", "Wed, 21 Oct 2015 07:28:00 GMT"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := FormatHTTPTime(tt.input); got != tt.expected {
+				t.Errorf("got %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestConditionalMappings_Incoming(t *testing.T) {
+	mapper := NewBuilder().AddMappings(ConditionalMappings()...).Build()
+	if err := mapper.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/v1/items/1", nil)
+	req.Header.Set("If-None-Match", `"v1"`)
+	req.Header.Set("If-Modified-Since", "Wed, 21 Oct 2015 07:28:00 GMT")
+	md := mapper.MetadataAnnotator()(context.Background(), req)
+
+	if got := md.Get(IfNoneMatchKey); len(got) != 1 || got[0] != `"v1"` {
+		t.Errorf("%s = %v", IfNoneMatchKey, got)
+	}
+	if got := md.Get(IfModifiedSinceKey); len(got) != 1 || got[0] != "Wed, 21 Oct 2015 07:28:00 GMT" {
+		t.Errorf("%s = %v", IfModifiedSinceKey, got)
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	msg := structpb.NewStringValue("item")
+	computed, err := computeETag(msg, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		config       ETagConfig
+		method       string
+		reqHeaders   map[string]string
+		md           metadata.MD
+		expectedCode int
+		expectedETag string
+	}{
+		{
+			name:         "passthrough etag",
+			config:       ETagConfig{},
+			md:           metadata.Pairs(ETagKey, "v1"),
+			expectedCode: http.StatusOK,
+			expectedETag: `"v1"`,
+		},
+		{
+			name:         "computed etag",
+			config:       ETagConfig{Compute: true},
+			md:           metadata.MD{},
+			expectedCode: http.StatusOK,
+			expectedETag: computed,
+		},
+		{
+			name:         "computed weak etag",
+			config:       ETagConfig{Compute: true, Weak: true},
+			md:           metadata.MD{},
+			expectedCode: http.StatusOK,
+			expectedETag: "W/" + computed,
+		},
+		{
+			name:         "backend etag wins",
+			config:       ETagConfig{Compute: true},
+			md:           metadata.Pairs(ETagKey, "v1"),
+			expectedCode: http.StatusOK,
+			expectedETag: `"v1"`,
+		},
+		{
+			name:         "if-none-match hit",
+			config:       ETagConfig{NotModified: true},
+			reqHeaders:   map[string]string{"If-None-Match": `"v0", W/"v1"`},
+			md:           metadata.Pairs(ETagKey, "v1"),
+			expectedCode: http.StatusNotModified,
+			expectedETag: `"v1"`,
+		},
+		{
+			name:         "if-none-match miss",
+			config:       ETagConfig{NotModified: true},
+			reqHeaders:   map[string]string{"If-None-Match": `"v0"`},
+			md:           metadata.Pairs(ETagKey, "v1"),
+			expectedCode: http.StatusOK,
+			expectedETag: `"v1"`,
+		},
+		{
+			name:         "computed etag hit",
+			config:       ETagConfig{Compute: true, NotModified: true},
+			reqHeaders:   map[string]string{"If-None-Match": computed},
+			md:           metadata.MD{},
+			expectedCode: http.StatusNotModified,
+			expectedETag: computed,
+		},
+		{
+			name:         "if-modified-since",
+			config:       ETagConfig{NotModified: true},
+			reqHeaders:   map[string]string{"If-Modified-Since": "Wed, 21 Oct 2015 07:28:00 GMT"},
+			md:           metadata.Pairs(LastModifiedKey, "1445412000"),
+			expectedCode: http.StatusNotModified,
+		},
+		{
+			name:         "modified since",
+			config:       ETagConfig{NotModified: true},
+			reqHeaders:   map[string]string{"If-Modified-Since": "Wed, 21 Oct 2015 07:28:00 GMT"},
+			md:           metadata.Pairs(LastModifiedKey, "1445413000"),
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "backend signal",
+			config:       ETagConfig{NotModified: true},
+			md:           metadata.Pairs(NotModifiedKey, "true"),
+			expectedCode: http.StatusNotModified,
+		},
+		{
+			name:         "custom signal key",
+			config:       ETagConfig{NotModified: true, NotModifiedKey: "x-cache-hit"},
+			md:           metadata.Pairs("x-cache-hit", "true"),
+			expectedCode: http.StatusNotModified,
+		},
+		{
+			name:         "not a GET",
+			config:       ETagConfig{NotModified: true},
+			method:       "POST",
+			md:           metadata.Pairs(NotModifiedKey, "true"),
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "disabled",
+			config:       ETagConfig{},
+			reqHeaders:   map[string]string{"If-None-Match": `"v1"`},
+			md:           metadata.Pairs(ETagKey, "v1"),
+			expectedCode: http.StatusOK,
+			expectedETag: `"v1"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			mapper := NewBuilder().
+				AddMappings(ConditionalMappings()...).
+				ConditionalRequests(&config).
+				Build()
+			if err := mapper.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+
+			handler := mapper.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctx := runtime.NewServerMetadataContext(r.Context(), runtime.ServerMetadata{HeaderMD: tt.md})
+				if err := mapper.ResponseModifier()(ctx, w, msg); err != nil {
+					t.Fatal(err)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`"item"`))
+			}))
+
+			method := tt.method
+			if method == "" {
+				method = "GET"
+			}
+			req := httptest.NewRequest(method, "/v1/items/1", nil)
+			for k, v := range tt.reqHeaders {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedCode {
+				t.Errorf("status = %d, want %d", w.Code, tt.expectedCode)
+			}
+			if tt.expectedETag != "" {
+				if got := w.Header().Get("ETag"); got != tt.expectedETag {
+					t.Errorf("ETag = %q, want %q", got, tt.expectedETag)
+				}
+			}
+			if tt.expectedCode == http.StatusNotModified && w.Body.Len() > 0 {
+				t.Errorf("304 body = %q, want empty", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestETagConfig_Validate(t *testing.T) {
+	mapper := NewBuilder().ConditionalRequests(&ETagConfig{NotModifiedKey: "bad key"}).Build()
+	if err := mapper.Validate(); err == nil {
+		t.Error("Validate() = nil, want error for invalid key")
+	}
+}
@@ -0,0 +1,173 @@
+package headermapper
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Metadata keys of experiment and feature flag headers
+const (
+	ExperimentIDKey      = "experiment-id"
+	ExperimentVariantKey = "experiment-variant"
+	FeatureFlagsKey      = "feature-flags"
+)
+
+// ExperimentMappings returns mappings for experiment headers: X-Experiment-ID
+// and X-Experiment-Variant in both directions, so variants chosen by clients
+// or assigned by backends are visible on both sides, and X-Feature-Flags to
+// backends as a normalized list (see NormalizeFeatureFlags). Add the
+// mappings of an Experiment after them to assign variants at the gateway
+// to requests not carrying one.
+func ExperimentMappings() []HeaderMapping {
+	return []HeaderMapping{
+		{
+			HTTPHeader:   "X-Experiment-ID",
+			GRPCMetadata: ExperimentIDKey,
+			Direction:    Bidirectional,
+		},
+		{
+			HTTPHeader:   "X-Experiment-Variant",
+			GRPCMetadata: ExperimentVariantKey,
+			Direction:    Bidirectional,
+		},
+		{
+			HTTPHeader:   "X-Feature-Flags",
+			GRPCMetadata: FeatureFlagsKey,
+			Direction:    Incoming,
+			Transform:    NormalizeFeatureFlags,
+		},
+	}
+}
+
+// NormalizeFeatureFlags lowercases, deduplicates and sorts a comma
+// separated list of feature flags
+func NormalizeFeatureFlags(value string) string {
+	var flags []string
+	for _, flag := range strings.Split(value, ",") {
+		if flag = Normalize(flag); flag != "" {
+			flags = appendUnique(flags, flag)
+		}
+	}
+	sort.Strings(flags)
+	return strings.Join(flags, ",")
+}
+
+// Bucket deterministically hashes id, typically a user or request ID, into
+// a percentage bucket from 0 to 99 for experiment. The bucket is the 32-bit
+// FNV-1a hash of "experiment:id" modulo 100, so backends and analytics
+// pipelines can reproduce assignments.
+func Bucket(experiment, id string) int {
+	h := fnv.New32a()
+	h.Write([]byte(experiment))
+	h.Write([]byte{':'})
+	h.Write([]byte(id))
+	return int(h.Sum32() % 100)
+}
+
+// BucketTransform returns a TransformFunc replacing an ID with its
+// percentage bucket for experiment
+func BucketTransform(experiment string) TransformFunc {
+	return func(value string) string {
+		return strconv.Itoa(Bucket(experiment, strings.TrimSpace(value)))
+	}
+}
+
+// ExperimentVariant is a variant of an Experiment receiving Weight percent
+// of the buckets
+type ExperimentVariant struct {
+	Name   string `json:"name" yaml:"name"`
+	Weight int    `json:"weight" yaml:"weight"`
+}
+
+// Experiment assigns requests to variants by bucket, so the same ID always
+// receives the same variant
+type Experiment struct {
+	name string
+	// bounds holds the exclusive upper bucket of each variant
+	bounds   []int
+	variants []string
+}
+
+// NewExperiment creates an experiment assigning consecutive bucket ranges
+// to variants in order. Weights beyond 100 percent in total are ignored and
+// buckets not covered by a variant are not enrolled.
+//
+//	checkout := headermapper.NewExperiment("checkout-v2",
+//		headermapper.ExperimentVariant{Name: "control", Weight: 50},
+//		headermapper.ExperimentVariant{Name: "treatment", Weight: 50},
+//	)
+func NewExperiment(name string, variants ...ExperimentVariant) *Experiment {
+	e := &Experiment{name: name}
+	upper := 0
+	for _, variant := range variants {
+		if variant.Weight <= 0 || upper >= 100 {
+			continue
+		}
+		upper = min(upper+variant.Weight, 100)
+		e.bounds = append(e.bounds, upper)
+		e.variants = append(e.variants, variant.Name)
+	}
+	return e
+}
+
+// Name returns the experiment name
+func (e *Experiment) Name() string {
+	return e.name
+}
+
+// hasVariant reports whether variant is a variant of the experiment
+func (e *Experiment) hasVariant(variant string) bool {
+	for _, name := range e.variants {
+		if name == variant {
+			return true
+		}
+	}
+	return false
+}
+
+// Assign returns the variant of id; empty when id is empty or its bucket is
+// not enrolled
+func (e *Experiment) Assign(id string) string {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return ""
+	}
+	bucket := Bucket(e.name, id)
+	for i, upper := range e.bounds {
+		if bucket < upper {
+			return e.variants[i]
+		}
+	}
+	return ""
+}
+
+// Mappings returns incoming mappings stamping the experiment name as
+// ExperimentIDKey and the assigned variant as ExperimentVariantKey on
+// enrolled requests identified by idHeader, or the first present fallback header,
+// e.g. X-User-ID falling back to X-Request-ID
+func (e *Experiment) Mappings(idHeader string, fallbacks ...string) []HeaderMapping {
+	return []HeaderMapping{
+		{
+			HTTPHeader:   idHeader,
+			GRPCMetadata: ExperimentIDKey,
+			Direction:    Incoming,
+			Aliases:      fallbacks,
+			// Requests not enrolled carry neither key
+			Transform: func(value string) string {
+				if e.Assign(value) == "" {
+					return ""
+				}
+				return e.name
+			},
+		},
+		{
+			HTTPHeader:   idHeader,
+			GRPCMetadata: ExperimentVariantKey,
+			Direction:    Incoming,
+			Aliases:      fallbacks,
+			Transform:    e.Assign,
+		},
+	}
+}
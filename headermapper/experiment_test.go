@@ -0,0 +1,160 @@
+package headermapper
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNormalizeFeatureFlags(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"dark-mode", "dark-mode"},
+		{" New-Checkout , dark-mode,new-checkout,, ", "dark-mode,new-checkout"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := NormalizeFeatureFlags(tt.input); got != tt.expected {
+				t.Errorf("got %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBucket(t *testing.T) {
+	// Fixed values guard the documented algorithm other systems reproduce
+	tests := []struct {
+		experiment string
+		id         string
+		expected   int
+	}{
+		{"checkout-v2", "user-1", int(fnv32a("checkout-v2:user-1") % 100)},
+		{"search", "user-1", int(fnv32a("search:user-1") % 100)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.experiment+"/"+tt.id, func(t *testing.T) {
+			if got := Bucket(tt.experiment, tt.id); got != tt.expected {
+				t.Errorf("got %d, want %d", got, tt.expected)
+			}
+		})
+	}
+
+	if got := BucketTransform("search")(" user-1 "); got != fmt.Sprint(Bucket("search", "user-1")) {
+		t.Errorf("BucketTransform = %q", got)
+	}
+
+	// Buckets are roughly uniform
+	counts := make([]int, 10)
+	for i := 0; i < 10000; i++ {
+		counts[Bucket("uniform", fmt.Sprintf("user-%d", i))/10]++
+	}
+	for decile, count := range counts {
+		if count < 850 || count > 1150 {
+			t.Errorf("decile %d has %d of 10000 ids", decile, count)
+		}
+	}
+}
+
+// fnv32a is a reference FNV-1a implementation
+func fnv32a(s string) uint32 {
+	h := uint32(2166136261)
+	for i := 0; i < len(s); i++ {
+		h ^= uint32(s[i])
+		h *= 16777619
+	}
+	return h
+}
+
+func TestExperiment_Assign(t *testing.T) {
+	tests := []struct {
+		name     string
+		variants []ExperimentVariant
+		expected func(bucket int) string
+	}{
+		{"split", []ExperimentVariant{{"control", 50}, {"treatment", 50}}, func(b int) string {
+			if b < 50 {
+				return "control"
+			}
+			return "treatment"
+		}},
+		{"partial enrollment", []ExperimentVariant{{"treatment", 10}}, func(b int) string {
+			if b < 10 {
+				return "treatment"
+			}
+			return ""
+		}},
+		{"over 100 percent", []ExperimentVariant{{"a", 80}, {"b", 80}, {"c", 10}}, func(b int) string {
+			if b < 80 {
+				return "a"
+			}
+			return "b"
+		}},
+		{"zero weight skipped", []ExperimentVariant{{"off", 0}, {"on", 100}}, func(int) string { return "on" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			experiment := NewExperiment("exp", tt.variants...)
+			for i := 0; i < 200; i++ {
+				id := fmt.Sprintf("user-%d", i)
+				if got, want := experiment.Assign(id), tt.expected(Bucket("exp", id)); got != want {
+					t.Fatalf("Assign(%q) = %q, want %q", id, got, want)
+				}
+			}
+			if got := experiment.Assign(""); got != "" {
+				t.Errorf("Assign(\"\") = %q, want empty", got)
+			}
+		})
+	}
+}
+
+func TestExperiment_Mappings(t *testing.T) {
+	experiment := NewExperiment("checkout-v2", ExperimentVariant{Name: "treatment", Weight: 100})
+	mapper := NewBuilder().
+		AddMappings(ExperimentMappings()...).
+		AddMappings(experiment.Mappings("X-User-ID", "X-Request-ID")...).
+		Build()
+	if err := mapper.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		expected map[string]string
+	}{
+		{"assigned by user", map[string]string{"X-User-ID": "user-1"},
+			map[string]string{ExperimentIDKey: "checkout-v2", ExperimentVariantKey: "treatment"}},
+		{"assigned by request id", map[string]string{"X-Request-ID": "req-1"},
+			map[string]string{ExperimentIDKey: "checkout-v2", ExperimentVariantKey: "treatment"}},
+		{"chosen by client", map[string]string{"X-User-ID": "user-1", "X-Experiment-ID": "checkout-v2", "X-Experiment-Variant": "control"},
+			map[string]string{ExperimentIDKey: "checkout-v2", ExperimentVariantKey: "control"}},
+		{"flags", map[string]string{"X-Feature-Flags": "B,a"},
+			map[string]string{FeatureFlagsKey: "a,b", ExperimentVariantKey: ""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/v1/cart", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			md := mapper.MetadataAnnotator()(context.Background(), req)
+			for key, want := range tt.expected {
+				got := ""
+				if values := md.Get(key); len(values) > 0 {
+					got = values[0]
+				}
+				if got != want {
+					t.Errorf("%s = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package extproc
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Check implements the ext_authz service. Requests the policies reject are
+// denied with their response; others are allowed with the mapped metadata
+// added as request headers.
+func (s *Server) Check(ctx context.Context, check *authv3.CheckRequest) (*authv3.CheckResponse, error) {
+	attrs := check.GetAttributes().GetRequest().GetHttp()
+	header := http.Header{}
+	if headerMap := attrs.GetHeaderMap(); headerMap != nil {
+		header, _ = headerMapToHTTP(headerMap)
+	} else {
+		for name, value := range attrs.GetHeaders() {
+			if name != "" && name[0] != ':' {
+				header.Set(name, value)
+			}
+		}
+	}
+
+	var remoteAddr string
+	if addr := check.GetAttributes().GetSource().GetAddress().GetSocketAddress(); addr != nil {
+		remoteAddr = net.JoinHostPort(addr.GetAddress(), strconv.FormatUint(uint64(addr.GetPortValue()), 10))
+	}
+	req, err := newRequest(ctx, attrs.GetMethod(), attrs.GetPath(), attrs.GetHost(), remoteAddr, header)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	o := s.process(req)
+	if o.rejected {
+		return &authv3.CheckResponse{
+			Status: &rpcstatus.Status{Code: int32(codes.PermissionDenied)},
+			HttpResponse: &authv3.CheckResponse_DeniedResponse{DeniedResponse: &authv3.DeniedHttpResponse{
+				Status:  &typev3.HttpStatus{Code: typev3.StatusCode(o.response.statusCode())},
+				Headers: headerValueOptions(o.response.Header()),
+				Body:    o.response.body.String(),
+			}},
+		}, nil
+	}
+
+	return &authv3.CheckResponse{
+		Status: &rpcstatus.Status{Code: int32(codes.OK)},
+		HttpResponse: &authv3.CheckResponse_OkResponse{OkResponse: &authv3.OkHttpResponse{
+			Headers:              headerValueOptions(o.set),
+			HeadersToRemove:      o.remove,
+			ResponseHeadersToAdd: headerValueOptions(o.response.Header()),
+		}},
+	}, nil
+}
@@ -0,0 +1,276 @@
+// Package extproc exposes a HeaderMapper as Envoy External Processing
+// (ext_proc) and External Authorization (ext_authz) gRPC services, so the
+// mappings and policies can be deployed as a sidecar for gateways not
+// written in Go.
+//
+//	server := grpc.NewServer()
+//	extproc.NewServer(mapper).Register(server)
+//	server.Serve(listener)
+//
+// For each request the policies of HeaderMapper.Handler run first; a
+// request they reject is answered directly with their response. Otherwise
+// the mapped metadata is set on the upstream request as headers named by
+// their keys, with values of -bin keys base64 encoded, and headers in
+// internal namespaces, blocked headers and client headers named like keys
+// only the mapper sets are removed. With ext_proc the outgoing mappings are
+// applied to the response headers as well; ext_authz never sees the
+// response, so only headers the policies add to it are returned.
+//
+// Requests reach the services from Envoy, so set TrustedProxies to Envoy's
+// address for client IP based policies to read X-Forwarded-For. ext_authz
+// reports the downstream address itself.
+package extproc
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/bhatti/grpc-header-mapper/headermapper"
+)
+
+// Server implements the ext_proc and ext_authz services for a HeaderMapper
+type Server struct {
+	extprocv3.UnimplementedExternalProcessorServer
+	authv3.UnimplementedAuthorizationServer
+
+	mapper    *headermapper.HeaderMapper
+	annotator func(context.Context, *http.Request) metadata.MD
+	handler   http.Handler
+}
+
+// NewServer creates the services for mapper
+func NewServer(mapper *headermapper.HeaderMapper) *Server {
+	s := &Server{mapper: mapper, annotator: mapper.UpstreamAnnotator()}
+	s.handler = mapper.Handler(http.HandlerFunc(s.mapRequest))
+	return s
+}
+
+// Register registers both services with server
+func (s *Server) Register(server *grpc.Server) {
+	extprocv3.RegisterExternalProcessorServer(server, s)
+	authv3.RegisterAuthorizationServer(server, s)
+}
+
+// outcomeKey is the context key of the outcome of a request
+type outcomeKey struct{}
+
+// outcome is the result of running the mapper on a request
+type outcome struct {
+	// ctx is the request context after the policies, used to map the
+	// response
+	ctx context.Context
+	// rejected is set when the policies answered the request themselves,
+	// with the response recorded in response
+	rejected bool
+	response *recorder
+	// set, remove and path describe the changes to the upstream request;
+	// path is empty when unchanged
+	set    metadata.MD
+	remove []string
+	path   string
+
+	header http.Header
+	uri    string
+}
+
+// process runs the policies and incoming mappings on req
+func (s *Server) process(req *http.Request) *outcome {
+	o := &outcome{
+		ctx:      req.Context(),
+		rejected: true,
+		response: newRecorder(),
+		header:   req.Header.Clone(),
+		uri:      req.URL.RequestURI(),
+	}
+	s.handler.ServeHTTP(o.response, req.WithContext(context.WithValue(req.Context(), outcomeKey{}, o)))
+	return o
+}
+
+// mapRequest runs behind the policies and records the request changes
+func (s *Server) mapRequest(w http.ResponseWriter, req *http.Request) {
+	o := req.Context().Value(outcomeKey{}).(*outcome)
+	o.rejected = false
+	o.ctx = req.Context()
+
+	md := s.annotator(req.Context(), req)
+	o.set = make(metadata.MD, len(md))
+	for key, values := range md {
+		o.set[key] = encodeBinaryValues(key, values)
+	}
+	for name := range o.header {
+		if _, ok := req.Header[name]; !ok {
+			o.remove = append(o.remove, strings.ToLower(name))
+		}
+	}
+	// Client headers named like keys only the mapper sets are removed
+	// unless the mapped metadata replaces them
+	for _, name := range s.mapper.SpoofedHeaders(o.header) {
+		if _, ok := o.set[name]; !ok {
+			o.remove = append(o.remove, name)
+		}
+	}
+	slices.Sort(o.remove)
+	o.remove = slices.Compact(o.remove)
+	if uri := req.URL.RequestURI(); uri != o.uri {
+		o.path = uri
+	}
+}
+
+// mapResponse returns the response header changes for the outcome of the
+// request; o is nil when Envoy did not send the request headers
+func (s *Server) mapResponse(ctx context.Context, o *outcome, header http.Header) (set metadata.MD, remove []string) {
+	before := header.Clone()
+	if o != nil {
+		ctx = o.ctx
+		// Headers the policies set on the response, e.g. CORS or rate limits
+		for name, values := range o.response.Header() {
+			header[name] = append(header[name], values...)
+		}
+	}
+	s.mapper.MapResponseHeaders(ctx, header)
+
+	set = metadata.MD{}
+	for name, values := range header {
+		if !slices.Equal(before[name], values) {
+			set[strings.ToLower(name)] = values
+		}
+	}
+	for name := range before {
+		if _, ok := header[name]; !ok {
+			remove = append(remove, strings.ToLower(name))
+		}
+	}
+	slices.Sort(remove)
+	return set, remove
+}
+
+// newRequest builds the request the mapper sees from the attributes Envoy
+// reports, with pseudo-headers already removed from header
+func newRequest(ctx context.Context, method, path, host, remoteAddr string, header http.Header) (*http.Request, error) {
+	if path == "" {
+		path = "/"
+	}
+	u, err := url.ParseRequestURI(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request path %q: %w", path, err)
+	}
+	if method == "" {
+		method = http.MethodGet
+	}
+	req := (&http.Request{
+		Method:     method,
+		URL:        u,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header,
+		Host:       host,
+		RemoteAddr: remoteAddr,
+		RequestURI: path,
+	}).WithContext(ctx)
+	return req, nil
+}
+
+// headerValueOptions converts headers to Envoy header options replacing
+// existing values, in a deterministic order
+func headerValueOptions(headers map[string][]string) []*corev3.HeaderValueOption {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var options []*corev3.HeaderValueOption
+	for _, name := range names {
+		for i, value := range headers[name] {
+			action := corev3.HeaderValueOption_APPEND_IF_EXISTS_OR_ADD
+			if i == 0 {
+				action = corev3.HeaderValueOption_OVERWRITE_IF_EXISTS_OR_ADD
+			}
+			options = append(options, &corev3.HeaderValueOption{
+				Header:       &corev3.HeaderValue{Key: strings.ToLower(name), RawValue: []byte(value)},
+				AppendAction: action,
+			})
+		}
+	}
+	return options
+}
+
+// headerMapToHTTP converts an Envoy header map, returning the pseudo-headers
+// separately
+func headerMapToHTTP(headers *corev3.HeaderMap) (header http.Header, pseudo map[string]string) {
+	header = make(http.Header, len(headers.GetHeaders()))
+	pseudo = make(map[string]string)
+	for _, h := range headers.GetHeaders() {
+		value := h.GetValue()
+		if len(h.GetRawValue()) > 0 {
+			value = string(h.GetRawValue())
+		}
+		if strings.HasPrefix(h.GetKey(), ":") {
+			pseudo[h.GetKey()] = value
+			continue
+		}
+		name := http.CanonicalHeaderKey(h.GetKey())
+		header[name] = append(header[name], value)
+	}
+	return header, pseudo
+}
+
+// encodeBinaryValues base64 encodes the values of -bin keys, which gRPC
+// carries encoded in HTTP headers
+func encodeBinaryValues(key string, values []string) []string {
+	if !strings.HasSuffix(key, "-bin") {
+		return values
+	}
+	encoded := make([]string, len(values))
+	for i, value := range values {
+		encoded[i] = base64.StdEncoding.EncodeToString([]byte(value))
+	}
+	return encoded
+}
+
+// recorder records the response of the policies
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newRecorder() *recorder {
+	return &recorder{header: http.Header{}}
+}
+
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	return r.body.Write(b)
+}
+
+// statusCode returns the recorded status, 200 when none was written
+func (r *recorder) statusCode() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
@@ -0,0 +1,260 @@
+package extproc
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"reflect"
+	"slices"
+	"testing"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/bhatti/grpc-header-mapper/headermapper"
+)
+
+func newTestMapper() *headermapper.HeaderMapper {
+	return headermapper.NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").WithTransform(headermapper.ToLower).WithRequired(true).
+		AddIncomingMapping("X-Trace", "trace-bin").
+		AddOutgoingMapping("server-version", "X-Server-Version").WithTransform(headermapper.TrimSpace).
+		InternalNamespaces("x-internal-").
+		StrictMode(true).
+		Build()
+}
+
+func startServer(t *testing.T, mapper *headermapper.HeaderMapper) *grpc.ClientConn {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	NewServer(mapper).Register(server)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func headerMap(pairs ...string) *corev3.HeaderMap {
+	headers := &corev3.HeaderMap{}
+	for i := 0; i < len(pairs); i += 2 {
+		headers.Headers = append(headers.Headers, &corev3.HeaderValue{Key: pairs[i], RawValue: []byte(pairs[i+1])})
+	}
+	return headers
+}
+
+// mutations flattens header options to name=value pairs
+func mutations(options []*corev3.HeaderValueOption) map[string]string {
+	set := make(map[string]string, len(options))
+	for _, option := range options {
+		set[option.GetHeader().GetKey()] = string(option.GetHeader().GetRawValue())
+	}
+	return set
+}
+
+func TestProcess(t *testing.T) {
+	stream, err := extprocv3.NewExternalProcessorClient(startServer(t, newTestMapper())).Process(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = stream.Send(&extprocv3.ProcessingRequest{Request: &extprocv3.ProcessingRequest_RequestHeaders{
+		RequestHeaders: &extprocv3.HttpHeaders{Headers: headerMap(
+			":method", "GET", ":path", "/v1/users", ":authority", "api.example.com",
+			"x-user-id", "ALICE", "x-trace", "_3RyYWNl", "x-internal-debug", "1",
+		)},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mutation := resp.GetRequestHeaders().GetResponse().GetHeaderMutation()
+	set := mutations(mutation.GetSetHeaders())
+	if set["user-id"] != "alice" {
+		t.Errorf("user-id = %q, want alice", set["user-id"])
+	}
+	if want := base64.StdEncoding.EncodeToString([]byte("\xfftrace")); set["trace-bin"] != want {
+		t.Errorf("trace-bin = %q, want %q", set["trace-bin"], want)
+	}
+	if remove := mutation.GetRemoveHeaders(); len(remove) != 1 || remove[0] != "x-internal-debug" {
+		t.Errorf("removed headers = %v, want [x-internal-debug]", remove)
+	}
+
+	err = stream.Send(&extprocv3.ProcessingRequest{Request: &extprocv3.ProcessingRequest_ResponseHeaders{
+		ResponseHeaders: &extprocv3.HttpHeaders{Headers: headerMap(":status", "200", "server-version", " 2.1 ", "x-internal-host", "node-3")},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err = stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+	mutation = resp.GetResponseHeaders().GetResponse().GetHeaderMutation()
+	if set := mutations(mutation.GetSetHeaders()); len(set) != 1 || set["x-server-version"] != "2.1" {
+		t.Errorf("response headers set = %v, want x-server-version: 2.1", set)
+	}
+	if remove := mutation.GetRemoveHeaders(); len(remove) != 1 || remove[0] != "x-internal-host" {
+		t.Errorf("removed response headers = %v, want [x-internal-host]", remove)
+	}
+}
+
+func TestProcess_Rejected(t *testing.T) {
+	stream, err := extprocv3.NewExternalProcessorClient(startServer(t, newTestMapper())).Process(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = stream.Send(&extprocv3.ProcessingRequest{Request: &extprocv3.ProcessingRequest_RequestHeaders{
+		RequestHeaders: &extprocv3.HttpHeaders{Headers: headerMap(":method", "GET", ":path", "/v1/users")},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	immediate := resp.GetImmediateResponse()
+	if immediate == nil || immediate.GetStatus().GetCode() != 400 {
+		t.Fatalf("response = %v, want immediate 400", resp)
+	}
+	if len(immediate.GetBody()) == 0 {
+		t.Error("immediate response has no body")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	client := authv3.NewAuthorizationClient(startServer(t, newTestMapper()))
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		code    codes.Code
+		status  int
+		userID  string
+	}{
+		{"allowed", map[string]string{"x-user-id": "Bob", "x-internal-debug": "1"}, codes.OK, 0, "bob"},
+		{"denied", map[string]string{}, codes.PermissionDenied, 400, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.Check(context.Background(), &authv3.CheckRequest{Attributes: &authv3.AttributeContext{
+				Request: &authv3.AttributeContext_Request{Http: &authv3.AttributeContext_HttpRequest{
+					Method: "GET", Path: "/v1/users?page=2", Host: "api.example.com", Headers: tt.headers,
+				}},
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := codes.Code(resp.GetStatus().GetCode()); got != tt.code {
+				t.Fatalf("status = %v, want %v", got, tt.code)
+			}
+			if tt.code != codes.OK {
+				if got := resp.GetDeniedResponse().GetStatus().GetCode(); int(got) != tt.status {
+					t.Errorf("denied status = %d, want %d", got, tt.status)
+				}
+				return
+			}
+			ok := resp.GetOkResponse()
+			if got := mutations(ok.GetHeaders())["user-id"]; got != tt.userID {
+				t.Errorf("user-id = %q, want %q", got, tt.userID)
+			}
+			if remove := ok.GetHeadersToRemove(); len(remove) != 1 || remove[0] != "x-internal-debug" {
+				t.Errorf("headers to remove = %v, want [x-internal-debug]", remove)
+			}
+		})
+	}
+}
+
+// newSpoofingMapper maps the sub claim of verified tokens to user-sub, which
+// clients must not set themselves, and blocks X-Internal-Token
+func newSpoofingMapper() *headermapper.HeaderMapper {
+	return headermapper.NewBuilder().
+		AddIncomingMapping("X-API-Key", "api-key").
+		MapJWTClaims(headermapper.ExtractJWTClaims(map[string]string{"sub": "user-sub"})).
+		BlockHeaders("X-Internal-Token").
+		RateLimit(&headermapper.RateLimitConfig{KeyMetadata: []string{"api-key"}, Rate: 100, Burst: 100}).
+		Build()
+}
+
+// spoofedHeaders are client headers the upstream must not receive
+var spoofedHeaders = []string{"user-sub", "x-internal-token", "x-headermapper-checked"}
+
+func TestProcess_SpoofedHeaders(t *testing.T) {
+	stream, err := extprocv3.NewExternalProcessorClient(startServer(t, newSpoofingMapper())).Process(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = stream.Send(&extprocv3.ProcessingRequest{Request: &extprocv3.ProcessingRequest_RequestHeaders{
+		RequestHeaders: &extprocv3.HttpHeaders{Headers: headerMap(
+			":method", "GET", ":path", "/v1/users", "x-api-key", "k1",
+			"user-sub", "admin", "x-internal-token", "forged", "x-headermapper-checked", "forged",
+		)},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mutation := resp.GetRequestHeaders().GetResponse().GetHeaderMutation()
+	if remove := mutation.GetRemoveHeaders(); !reflect.DeepEqual(remove, sortedSpoofedHeaders()) {
+		t.Errorf("removed headers = %v, want %v", remove, sortedSpoofedHeaders())
+	}
+	set := mutations(mutation.GetSetHeaders())
+	for _, name := range spoofedHeaders {
+		if value, ok := set[name]; ok {
+			t.Errorf("set %s = %q, want unset", name, value)
+		}
+	}
+}
+
+func TestCheck_SpoofedHeaders(t *testing.T) {
+	client := authv3.NewAuthorizationClient(startServer(t, newSpoofingMapper()))
+	resp, err := client.Check(context.Background(), &authv3.CheckRequest{Attributes: &authv3.AttributeContext{
+		Request: &authv3.AttributeContext_Request{Http: &authv3.AttributeContext_HttpRequest{
+			Method: "GET", Path: "/v1/users", Headers: map[string]string{
+				"x-api-key": "k1", "user-sub": "admin", "x-internal-token": "forged", "x-headermapper-checked": "forged",
+			},
+		}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := codes.Code(resp.GetStatus().GetCode()); got != codes.OK {
+		t.Fatalf("status = %v, want %v", got, codes.OK)
+	}
+	ok := resp.GetOkResponse()
+	if remove := ok.GetHeadersToRemove(); !reflect.DeepEqual(remove, sortedSpoofedHeaders()) {
+		t.Errorf("headers to remove = %v, want %v", remove, sortedSpoofedHeaders())
+	}
+	set := mutations(ok.GetHeaders())
+	for _, name := range spoofedHeaders {
+		if value, ok := set[name]; ok {
+			t.Errorf("set %s = %q, want unset", name, value)
+		}
+	}
+}
+
+func sortedSpoofedHeaders() []string {
+	names := slices.Clone(spoofedHeaders)
+	slices.Sort(names)
+	return names
+}
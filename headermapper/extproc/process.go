@@ -0,0 +1,113 @@
+package extproc
+
+import (
+	"errors"
+	"io"
+
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Process implements the ext_proc service. Request and response headers are
+// mapped; bodies and trailers, if Envoy is configured to send them, are
+// passed through unchanged.
+func (s *Server) Process(stream extprocv3.ExternalProcessor_ProcessServer) error {
+	ctx := stream.Context()
+	var remoteAddr string
+	if p, ok := peer.FromContext(ctx); ok {
+		remoteAddr = p.Addr.String()
+	}
+
+	var o *outcome
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) || status.Code(err) == codes.Canceled {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var resp *extprocv3.ProcessingResponse
+		switch r := req.GetRequest().(type) {
+		case *extprocv3.ProcessingRequest_RequestHeaders:
+			header, pseudo := headerMapToHTTP(r.RequestHeaders.GetHeaders())
+			httpReq, err := newRequest(ctx, pseudo[":method"], pseudo[":path"], pseudo[":authority"], remoteAddr, header)
+			if err != nil {
+				return status.Error(codes.InvalidArgument, err.Error())
+			}
+			o = s.process(httpReq)
+			if o.rejected {
+				resp = immediateResponse(o.response)
+				break
+			}
+			set := o.set
+			if o.path != "" {
+				set = metadata.Join(set, metadata.Pairs(":path", o.path))
+			}
+			resp = &extprocv3.ProcessingResponse{Response: &extprocv3.ProcessingResponse_RequestHeaders{
+				RequestHeaders: headersResponse(set, o.remove),
+			}}
+		case *extprocv3.ProcessingRequest_ResponseHeaders:
+			header, _ := headerMapToHTTP(r.ResponseHeaders.GetHeaders())
+			set, remove := s.mapResponse(ctx, o, header)
+			resp = &extprocv3.ProcessingResponse{Response: &extprocv3.ProcessingResponse_ResponseHeaders{
+				ResponseHeaders: headersResponse(set, remove),
+			}}
+		case *extprocv3.ProcessingRequest_RequestBody:
+			resp = &extprocv3.ProcessingResponse{Response: &extprocv3.ProcessingResponse_RequestBody{
+				RequestBody: &extprocv3.BodyResponse{},
+			}}
+		case *extprocv3.ProcessingRequest_ResponseBody:
+			resp = &extprocv3.ProcessingResponse{Response: &extprocv3.ProcessingResponse_ResponseBody{
+				ResponseBody: &extprocv3.BodyResponse{},
+			}}
+		case *extprocv3.ProcessingRequest_RequestTrailers:
+			resp = &extprocv3.ProcessingResponse{Response: &extprocv3.ProcessingResponse_RequestTrailers{
+				RequestTrailers: &extprocv3.TrailersResponse{},
+			}}
+		case *extprocv3.ProcessingRequest_ResponseTrailers:
+			resp = &extprocv3.ProcessingResponse{Response: &extprocv3.ProcessingResponse_ResponseTrailers{
+				ResponseTrailers: &extprocv3.TrailersResponse{},
+			}}
+		default:
+			return status.Errorf(codes.Unimplemented, "unsupported processing request %T", r)
+		}
+
+		// Envoy expects no responses in observability mode
+		if req.GetObservabilityMode() {
+			continue
+		}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+// headersResponse continues processing with the header changes applied
+func headersResponse(set metadata.MD, remove []string) *extprocv3.HeadersResponse {
+	if len(set) == 0 && len(remove) == 0 {
+		return &extprocv3.HeadersResponse{}
+	}
+	return &extprocv3.HeadersResponse{Response: &extprocv3.CommonResponse{
+		HeaderMutation: &extprocv3.HeaderMutation{
+			SetHeaders:    headerValueOptions(set),
+			RemoveHeaders: remove,
+		},
+	}}
+}
+
+// immediateResponse answers the request with the response of the policies
+func immediateResponse(r *recorder) *extprocv3.ProcessingResponse {
+	return &extprocv3.ProcessingResponse{Response: &extprocv3.ProcessingResponse_ImmediateResponse{
+		ImmediateResponse: &extprocv3.ImmediateResponse{
+			Status:  &typev3.HttpStatus{Code: typev3.StatusCode(r.statusCode())},
+			Headers: &extprocv3.HeaderMutation{SetHeaders: headerValueOptions(r.Header())},
+			Body:    r.body.Bytes(),
+		},
+	}}
+}
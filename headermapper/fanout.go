@@ -0,0 +1,37 @@
+package headermapper
+
+import "strings"
+
+// FanOutTarget is a metadata key an incoming header fans out to, with the
+// transform producing its value
+type FanOutTarget struct {
+	GRPCMetadata string
+	Transform    TransformFunc
+}
+
+// FanOut adds an incoming mapping of httpHeader to each target. The mapper
+// reads the header once per request for all of them and applies each
+// transform to the same value; HeaderMatcher uses the first target.
+//
+//	NewBuilder().FanOut("Authorization",
+//		FanOutTarget{GRPCMetadata: "authorization"},
+//		FanOutTarget{GRPCMetadata: "auth-token", Transform: ExtractBearerToken},
+//		FanOutTarget{GRPCMetadata: "auth-scheme", Transform: ExtractAuthScheme},
+//	)
+func (b *Builder) FanOut(httpHeader string, targets ...FanOutTarget) *Builder {
+	for _, target := range targets {
+		b.AddIncomingMapping(httpHeader, target.GRPCMetadata)
+		b.config.Mappings[len(b.config.Mappings)-1].Transform = target.Transform
+	}
+	return b
+}
+
+// ExtractAuthScheme returns the lowercase scheme of an Authorization value,
+// such as bearer or basic, or an empty value when there is none
+func ExtractAuthScheme(value string) string {
+	scheme, credentials, ok := strings.Cut(strings.TrimSpace(value), " ")
+	if !ok || strings.TrimSpace(credentials) == "" {
+		return ""
+	}
+	return strings.ToLower(scheme)
+}
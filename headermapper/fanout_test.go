@@ -0,0 +1,93 @@
+package headermapper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestFanOut(t *testing.T) {
+	mapper := NewBuilder().
+		FanOut("Authorization",
+			FanOutTarget{GRPCMetadata: "authorization"},
+			FanOutTarget{GRPCMetadata: "auth-token", Transform: ExtractBearerToken},
+			FanOutTarget{GRPCMetadata: "auth-scheme", Transform: ExtractAuthScheme},
+		).
+		Build()
+	if err := mapper.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The header is a single source read once for all targets
+	if idx := mapper.state().index; len(idx.sources) != 1 || len(idx.sources[0].mappings) != 3 {
+		t.Fatalf("sources = %d, want one source with 3 mappings", len(idx.sources))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer abc123")
+	md := mapper.MetadataAnnotator()(context.Background(), req)
+	want := map[string][]string{"authorization": {"Bearer abc123"}, "auth-token": {"abc123"}, "auth-scheme": {"bearer"}}
+	for key, values := range want {
+		if got := md.Get(key); !reflect.DeepEqual(got, values) {
+			t.Errorf("%s = %v, want %v", key, got, values)
+		}
+	}
+
+	// The raw header is matched to the first target only
+	if key, ok := mapper.HeaderMatcher()("Authorization"); !ok || key != "authorization" {
+		t.Errorf("HeaderMatcher(Authorization) = %q, %v, want authorization", key, ok)
+	}
+}
+
+func TestFanOut_Config(t *testing.T) {
+	config, err := parseConfig([]byte(`
+mappings:
+  - http_header: Authorization
+    grpc_metadata: authorization
+    direction: incoming
+  - http_header: Authorization
+    grpc_metadata: auth-token
+    direction: incoming
+    transform: [bearer_extract]
+  - http_header: Authorization
+    grpc_metadata: auth-scheme
+    direction: incoming
+    transform: [auth_scheme]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+	md := NewHeaderMapper(config).MetadataAnnotator()(context.Background(), req)
+	if got := md.Get("auth-scheme"); len(got) != 1 || got[0] != "basic" {
+		t.Errorf("auth-scheme = %v, want basic", got)
+	}
+	if got := md.Get("auth-token"); len(got) != 1 || got[0] != "Basic dXNlcjpwYXNz" {
+		t.Errorf("auth-token = %v, want the unchanged value", got)
+	}
+}
+
+func TestExtractAuthScheme(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"Bearer abc", "bearer"},
+		{"  Basic dXNlcjpwYXNz", "basic"},
+		{"DPoP token", "dpop"},
+		{"abc", ""},
+		{"Bearer ", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := ExtractAuthScheme(tt.value); got != tt.want {
+				t.Errorf("ExtractAuthScheme(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package headermapper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// fipsMinHMACKeyLength is the minimum HMAC key length in bytes (112 bits,
+// NIST SP 800-131A)
+const fipsMinHMACKeyLength = 14
+
+// fipsSignatureAlgorithms lists the FIPS-approved request signature algorithms
+var fipsSignatureAlgorithms = map[string]bool{
+	SignatureHMACSHA256:      true,
+	SignatureECDSAP256SHA256: true,
+}
+
+// validateFIPS checks that the configuration only uses FIPS-approved
+// algorithms and key sizes. It does not enable the Go FIPS 140-3 module,
+// which is selected with GODEBUG=fips140=on.
+func validateFIPS(config *Config) error {
+	if sc := config.Signature; sc != nil {
+		if !fipsSignatureAlgorithms[sc.algorithm()] {
+			return fmt.Errorf("fips: signature algorithm %s is not approved", sc.Algorithm)
+		}
+		for id, key := range sc.Keys {
+			if sc.algorithm() == SignatureHMACSHA256 && len(key) < fipsMinHMACKeyLength {
+				return fmt.Errorf("fips: signature key %s is shorter than %d bytes", id, fipsMinHMACKeyLength)
+			}
+			if sc.algorithm() == SignatureECDSAP256SHA256 {
+				pub, err := parseECDSAPublicKey(key)
+				if err == nil && pub.Curve.Params().BitSize < 256 {
+					return fmt.Errorf("fips: signature key %s uses a curve smaller than P-256", id)
+				}
+			}
+		}
+	}
+
+	if pc := config.PropagationSigning; pc != nil {
+		for id, secret := range pc.Secrets {
+			if len(secret) < fipsMinHMACKeyLength {
+				return fmt.Errorf("fips: propagation secret %s is shorter than %d bytes", id, fipsMinHMACKeyLength)
+			}
+		}
+	}
+
+	if bc := config.BasicAuth; bc != nil && bc.HashKey != "" && len(bc.HashKey) < fipsMinHMACKeyLength {
+		return fmt.Errorf("fips: basic auth hash key is shorter than %d bytes", fipsMinHMACKeyLength)
+	}
+
+	if ec := config.Encryption; ec != nil {
+		if provider, ok := ec.Provider.(*StaticKeyProvider); ok {
+			for id, key := range provider.keys {
+				switch len(key) {
+				case 16, 24, 32:
+				default:
+					return fmt.Errorf("fips: data key %s is not a valid AES key size", id)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// fipsViolation rejects all traffic when the configuration violates FIPS
+// mode, so a misconfigured mapper fails closed
+type fipsViolation struct {
+	err error
+}
+
+// The details are reported by Validate rather than to clients
+func (v *fipsViolation) check(w http.ResponseWriter, req *http.Request) error {
+	return rejectf(codes.Internal, "server configuration violates FIPS mode")
+}
+
+func (v *fipsViolation) checkCall(ctx context.Context, fullMethod string, md metadata.MD) error {
+	return rejectf(codes.Internal, "server configuration violates FIPS mode")
+}
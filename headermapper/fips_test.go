@@ -0,0 +1,86 @@
+package headermapper
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestFIPSMode_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *Builder
+		wantErr bool
+	}{
+		{
+			"approved hmac",
+			NewBuilder().VerifySignatures(&SignatureConfig{Keys: map[string]string{"k": "0123456789abcdef"}}),
+			false,
+		},
+		{
+			"short hmac key",
+			NewBuilder().VerifySignatures(&SignatureConfig{Keys: map[string]string{"k": "short"}}),
+			true,
+		},
+		{
+			"short propagation secret",
+			NewBuilder().SignPropagation(&PropagationSigningConfig{
+				Keys: []string{"user-id"}, KeyID: "k", Secrets: map[string]string{"k": "short"},
+			}),
+			true,
+		},
+		{
+			"short basic auth hash key",
+			NewBuilder().MapBasicAuth(&BasicAuthConfig{PasswordKey: "password", HashKey: "short"}),
+			true,
+		},
+		{
+			"invalid aes key",
+			NewBuilder().EncryptMetadata(&EncryptionConfig{
+				Keys: []string{"ssn"}, Provider: NewStaticKeyProvider("k", map[string][]byte{"k": bytes.Repeat([]byte{1}, 20)}),
+			}),
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.builder.FIPSMode(true).BuildAndValidate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("BuildAndValidate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			_, err = tt.builder.FIPSMode(false).BuildAndValidate()
+			if err != nil {
+				t.Errorf("BuildAndValidate() without FIPS mode error = %v", err)
+			}
+		})
+	}
+}
+
+func TestFIPSMode_FailsClosed(t *testing.T) {
+	mapper := NewBuilder().
+		VerifySignatures(&SignatureConfig{Keys: map[string]string{"k": "short"}}).
+		FIPSMode(true).
+		Build()
+
+	w := httptest.NewRecorder()
+	mapper.Handler(http.NotFoundHandler()).ServeHTTP(w, httptest.NewRequest("GET", "/v1/echo", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Handler() status = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+	_, err := mapper.UnaryServerInterceptor()(metadata.NewIncomingContext(context.Background(), metadata.MD{}), nil,
+		&grpc.UnaryServerInfo{FullMethod: "/echo.v1.EchoService/Echo"}, handler)
+	if status.Code(err) != codes.Internal {
+		t.Errorf("UnaryServerInterceptor() code = %v, want %v", status.Code(err), codes.Internal)
+	}
+}
@@ -0,0 +1,32 @@
+package headermapper
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// maxFoldKey bounds the keys lowercased on the stack by lookupFold
+const maxFoldKey = 128
+
+// lookupFold looks up key in a map keyed by lowercase names. ASCII keys are
+// lowercased into a stack buffer, so the lookup does not allocate; other keys
+// fall back to strings.ToLower.
+func lookupFold[V any](m map[string]V, key string) (V, bool) {
+	if len(key) > maxFoldKey {
+		v, ok := m[strings.ToLower(key)]
+		return v, ok
+	}
+
+	var buf [maxFoldKey]byte
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if c >= utf8.RuneSelf {
+			v, ok := m[strings.ToLower(key)]
+			return v, ok
+		}
+		buf[i] = lowerASCII(c)
+	}
+	// The conversion in the index expression does not allocate
+	v, ok := m[string(buf[:len(key)])]
+	return v, ok
+}
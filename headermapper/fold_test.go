@@ -0,0 +1,47 @@
+package headermapper
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLookupFold(t *testing.T) {
+	m := map[string]string{
+		"x-user-id":                       "user-id",
+		"x-ünicode":                       "unicode",
+		strings.Repeat("a", maxFoldKey+1): "long",
+	}
+
+	tests := []struct {
+		key  string
+		want string
+		ok   bool
+	}{
+		{"x-user-id", "user-id", true},
+		{"X-User-Id", "user-id", true},
+		{"X-USER-ID", "user-id", true},
+		{"X-ÜNICODE", "unicode", true},
+		{strings.Repeat("A", maxFoldKey+1), "long", true},
+		{"x-unknown", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, ok := lookupFold(m, tt.key)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("lookupFold(%q) = %q, %v, want %q, %v", tt.key, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestLookupFold_Allocs(t *testing.T) {
+	m := map[string]bool{"x-user-id": true}
+	allocs := testing.AllocsPerRun(100, func() {
+		lookupFold(m, "X-User-ID")
+	})
+	if allocs != 0 {
+		t.Errorf("lookupFold allocs = %v, want 0", allocs)
+	}
+}
@@ -0,0 +1,145 @@
+package headermapper
+
+import (
+	"container/list"
+	"time"
+	"unsafe"
+)
+
+// Footprint approximates the memory held by a mapper, for sizing gateways
+// that run many per-tenant mappers. Sizes count the structures and string
+// bytes owned by the mapper but not allocator overhead, so they are lower
+// bounds. The scratch buffer pool is shared by all mappers in the process and
+// emptied by the garbage collector, so it is not included; Stats reports its
+// use.
+type Footprint struct {
+	// Mappings is the number of compiled mappings
+	Mappings int
+	// MappingBytes covers the compiled mappings, their indexes and counters
+	MappingBytes int64
+
+	// TransformCacheEntries and TransformCacheBytes describe the transform cache
+	TransformCacheEntries int
+	TransformCacheBytes   int64
+
+	// StoreEntries and StoreBytes describe the in-memory nonce and rate limit
+	// stores; external stores are not counted
+	StoreEntries int
+	StoreBytes   int64
+}
+
+// TotalBytes returns the sum of the approximated sizes
+func (f *Footprint) TotalBytes() int64 {
+	return f.MappingBytes + f.TransformCacheBytes + f.StoreBytes
+}
+
+// Footprint reports the approximate memory held by the mapper
+func (hm *HeaderMapper) Footprint() *Footprint {
+	cc := hm.state()
+	f := &Footprint{
+		Mappings:     len(cc.index.incoming) + len(cc.index.outgoing),
+		MappingBytes: cc.index.footprint(),
+	}
+	if cc.cache != nil {
+		f.TransformCacheEntries, f.TransformCacheBytes = cc.cache.footprint()
+	}
+	for _, store := range hm.stores {
+		entries, bytes := store.footprint()
+		f.StoreEntries += entries
+		f.StoreBytes += bytes
+	}
+	return f
+}
+
+// footprinter is implemented by in-memory stores that report their size
+type footprinter interface {
+	footprint() (entries int, bytes int64)
+}
+
+// mapBytes approximates a map of entries slots of slotSize bytes, with one
+// control byte per slot and the maximum load factor of 7/8
+func mapBytes(entries int, slotSize uintptr) int64 {
+	return int64(entries) * int64(slotSize+1) * 8 / 7
+}
+
+const (
+	stringSize = unsafe.Sizeof("")
+	sliceSize  = unsafe.Sizeof([]string(nil))
+	ptrSize    = unsafe.Sizeof(uintptr(0))
+)
+
+// footprint approximates the memory of the compiled mappings and indexes
+func (idx *mappingIndex) footprint() int64 {
+	var n int64
+	for _, mappings := range [][]compiledMapping{idx.incoming, idx.outgoing} {
+		for i := range mappings {
+			m := &mappings[i]
+			n += int64(unsafe.Sizeof(*m)) + int64(unsafe.Sizeof(mappingCounter{}))
+			n += int64(len(m.header) + len(m.lowerHeader) + len(m.key) + len(m.defaultValue))
+			for _, alias := range m.aliases {
+				n += int64(stringSize) + int64(len(alias))
+			}
+		}
+	}
+
+	for i := range idx.sources {
+		n += int64(unsafe.Sizeof(idx.sources[i])) + int64(len(idx.sources[i].mappings))*int64(ptrSize)
+	}
+	n += mapBytes(len(idx.sourceByHeader)+len(idx.sourceByAlias), stringSize+ptrSize)
+	n += int64(len(idx.sourcesAlways)+len(idx.outgoingAlways)) * int64(ptrSize)
+
+	n += mapBytes(len(idx.outgoingByKey), stringSize+sliceSize)
+	for _, mappings := range idx.outgoingByKey {
+		n += int64(len(mappings)) * int64(ptrSize)
+	}
+
+	n += mapBytes(len(idx.matcher), 2*stringSize)
+	for name, key := range idx.matcher {
+		n += int64(len(name) + len(key))
+	}
+	return n
+}
+
+// footprint approximates the memory of the cached results
+func (c *TransformCache) footprint() (int, int64) {
+	perEntry := int64(unsafe.Sizeof(cacheEntry{})+unsafe.Sizeof(list.Element{})) +
+		mapBytes(1, unsafe.Sizeof(cacheKey{})+ptrSize)
+
+	var entries int
+	var n int64
+	for i := range c.shards {
+		shard := &c.shards[i]
+		shard.mu.Lock()
+		for e := shard.order.Front(); e != nil; e = e.Next() {
+			entry := e.Value.(*cacheEntry)
+			n += perEntry + int64(len(entry.key.input)+len(entry.value))
+		}
+		entries += shard.order.Len()
+		shard.mu.Unlock()
+	}
+	return entries, n
+}
+
+// footprint approximates the memory of the remembered nonces
+func (s *MemoryNonceStore) footprint() (int, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := mapBytes(len(s.expires), stringSize+unsafe.Sizeof(time.Time{}))
+	for nonce := range s.expires {
+		n += int64(len(nonce))
+	}
+	return len(s.expires), n
+}
+
+// footprint approximates the memory of the token buckets
+func (s *MemoryRateLimitStore) footprint() (int, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := mapBytes(len(s.buckets), stringSize+ptrSize) + int64(len(s.buckets))*int64(unsafe.Sizeof(tokenBucket{}))
+	for key := range s.buckets {
+		n += int64(len(key))
+	}
+	return len(s.buckets), n
+}
@@ -0,0 +1,76 @@
+package headermapper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestFootprint_Mappings(t *testing.T) {
+	build := func(n int) *HeaderMapper {
+		builder := NewBuilder()
+		for i := 0; i < n; i++ {
+			builder.AddBidirectionalMapping(fmt.Sprintf("X-Header-%d", i), fmt.Sprintf("header-%d", i))
+		}
+		return builder.Build()
+	}
+
+	small, large := build(5).Footprint(), build(50).Footprint()
+	if small.Mappings != 10 || large.Mappings != 100 {
+		t.Errorf("Mappings = %d, %d, want 10, 100", small.Mappings, large.Mappings)
+	}
+	if small.MappingBytes <= 0 || large.MappingBytes < 9*small.MappingBytes {
+		t.Errorf("MappingBytes = %d, %d, want growth with mappings", small.MappingBytes, large.MappingBytes)
+	}
+	if small.TotalBytes() != small.MappingBytes {
+		t.Errorf("TotalBytes() = %d, want %d", small.TotalBytes(), small.MappingBytes)
+	}
+}
+
+func TestFootprint_TransformCache(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("User-Agent", "client").
+		WithCachedTransform(ToLower).
+		Build()
+	annotator := mapper.MetadataAnnotator()
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("GET", "/api/test", nil)
+		req.Header.Set("User-Agent", fmt.Sprintf("Client/%d", i))
+		annotator(context.Background(), req)
+	}
+
+	f := mapper.Footprint()
+	if f.TransformCacheEntries != 3 || f.TransformCacheBytes <= 0 {
+		t.Errorf("transform cache = %d entries, %d bytes", f.TransformCacheEntries, f.TransformCacheBytes)
+	}
+	if f.TotalBytes() != f.MappingBytes+f.TransformCacheBytes {
+		t.Errorf("TotalBytes() = %d", f.TotalBytes())
+	}
+}
+
+func TestFootprint_Stores(t *testing.T) {
+	mapper := NewBuilder().
+		GuardReplays(&ReplayGuardConfig{MaxSkew: time.Minute}).
+		AddIncomingMapping("X-API-Key", "api-key").
+		RateLimit(&RateLimitConfig{KeyMetadata: []string{"api-key"}, Rate: 1, Burst: 10}).
+		Build()
+	handler := mapper.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("POST", "/v1/orders", nil)
+		req.Header.Set("X-Nonce", fmt.Sprintf("nonce-%d", i))
+		req.Header.Set("X-Timestamp", strconv.FormatInt(time.Now().Unix(), 10))
+		req.Header.Set("X-API-Key", "key")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// Two nonces and one rate limit bucket
+	if f := mapper.Footprint(); f.StoreEntries != 3 || f.StoreBytes <= 0 {
+		t.Errorf("stores = %d entries, %d bytes", f.StoreEntries, f.StoreBytes)
+	}
+}
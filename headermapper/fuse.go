@@ -0,0 +1,202 @@
+package headermapper
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// stepOp identifies the operation of a TransformStep
+type stepOp int
+
+const (
+	stepFunc stepOp = iota
+	stepTrimSpace
+	stepRemovePrefix
+	stepRemoveSuffix
+	stepAddPrefix
+	stepAddSuffix
+	stepToLower
+	stepToUpper
+)
+
+// TransformStep is one step of a fused transform chain
+type TransformStep struct {
+	op  stepOp
+	arg string
+	fn  TransformFunc
+}
+
+// TrimSpaceStep trims surrounding whitespace
+func TrimSpaceStep() TransformStep { return TransformStep{op: stepTrimSpace} }
+
+// RemovePrefixStep removes prefix if present
+func RemovePrefixStep(prefix string) TransformStep {
+	return TransformStep{op: stepRemovePrefix, arg: prefix}
+}
+
+// RemoveSuffixStep removes suffix if present
+func RemoveSuffixStep(suffix string) TransformStep {
+	return TransformStep{op: stepRemoveSuffix, arg: suffix}
+}
+
+// AddPrefixStep prepends prefix
+func AddPrefixStep(prefix string) TransformStep { return TransformStep{op: stepAddPrefix, arg: prefix} }
+
+// AddSuffixStep appends suffix
+func AddSuffixStep(suffix string) TransformStep { return TransformStep{op: stepAddSuffix, arg: suffix} }
+
+// ToLowerStep converts to lowercase
+func ToLowerStep() TransformStep { return TransformStep{op: stepToLower} }
+
+// ToUpperStep converts to uppercase
+func ToUpperStep() TransformStep { return TransformStep{op: stepToUpper} }
+
+// FuncStep runs an arbitrary transform; it ends the fused segment before it
+func FuncStep(transform TransformFunc) TransformStep {
+	return TransformStep{op: stepFunc, fn: transform}
+}
+
+// fusedSegment applies a run of steps in one pass: slicing steps narrow the
+// input without copying, then prefixes, suffixes and a case conversion are
+// written into a single output buffer
+type fusedSegment struct {
+	view []TransformStep
+	// pre and post are the constant prefix and suffix, already case converted
+	pre, post string
+	// convert is stepToLower, stepToUpper or 0 for no case conversion
+	convert stepOp
+	build   bool
+	fn      TransformFunc
+}
+
+// FuseTransforms compiles steps into one transform. Adjacent trim, prefix,
+// suffix and case steps are fused into a single pass over the value with at
+// most one allocation, where ChainTransforms allocates per stage.
+//
+//	transform := headermapper.FuseTransforms(
+//		headermapper.TrimSpaceStep(),
+//		headermapper.RemovePrefixStep("Bearer "),
+//		headermapper.AddPrefixStep("token:"),
+//		headermapper.ToLowerStep(),
+//	)
+func FuseTransforms(steps ...TransformStep) TransformFunc {
+	var segments []*fusedSegment
+	var cur *fusedSegment
+	next := func() *fusedSegment {
+		cur = &fusedSegment{}
+		segments = append(segments, cur)
+		return cur
+	}
+
+	for _, step := range steps {
+		switch step.op {
+		case stepFunc:
+			if step.fn != nil {
+				segments = append(segments, &fusedSegment{fn: step.fn})
+				cur = nil
+			}
+		case stepTrimSpace, stepRemovePrefix, stepRemoveSuffix:
+			// Slicing after output has been built starts a new pass
+			if cur == nil || cur.fn != nil || cur.build {
+				next()
+			}
+			cur.view = append(cur.view, step)
+		case stepAddPrefix, stepAddSuffix, stepToLower, stepToUpper:
+			if cur == nil || cur.fn != nil {
+				next()
+			}
+			// Composing different case conversions is not exact for all of
+			// Unicode, so each gets its own pass
+			if (step.op == stepToLower || step.op == stepToUpper) && cur.convert != 0 && cur.convert != step.op {
+				next()
+			}
+			cur.build = true
+			switch step.op {
+			case stepAddPrefix:
+				cur.pre = step.arg + cur.pre
+			case stepAddSuffix:
+				cur.post += step.arg
+			case stepToLower:
+				cur.pre, cur.post, cur.convert = strings.ToLower(cur.pre), strings.ToLower(cur.post), stepToLower
+			case stepToUpper:
+				cur.pre, cur.post, cur.convert = strings.ToUpper(cur.pre), strings.ToUpper(cur.post), stepToUpper
+			}
+		}
+	}
+
+	if len(segments) == 1 {
+		return segments[0].apply
+	}
+	return func(value string) string {
+		for _, segment := range segments {
+			value = segment.apply(value)
+		}
+		return value
+	}
+}
+
+// apply runs the segment on value
+func (s *fusedSegment) apply(value string) string {
+	if s.fn != nil {
+		return s.fn(value)
+	}
+
+	for _, step := range s.view {
+		switch step.op {
+		case stepTrimSpace:
+			value = strings.TrimSpace(value)
+		case stepRemovePrefix:
+			value = strings.TrimPrefix(value, step.arg)
+		case stepRemoveSuffix:
+			value = strings.TrimSuffix(value, step.arg)
+		}
+	}
+	if !s.build {
+		return value
+	}
+
+	if s.pre == "" && s.post == "" {
+		return convertCase(value, s.convert)
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(s.pre) + len(value) + len(s.post))
+	sb.WriteString(s.pre)
+	if s.convert == 0 || !isASCII(value) {
+		sb.WriteString(convertCase(value, s.convert))
+	} else {
+		for i := 0; i < len(value); i++ {
+			c := value[i]
+			if s.convert == stepToLower {
+				c = lowerASCII(c)
+			} else if 'a' <= c && c <= 'z' {
+				c -= 'a' - 'A'
+			}
+			sb.WriteByte(c)
+		}
+	}
+	sb.WriteString(s.post)
+	return sb.String()
+}
+
+// convertCase applies a case conversion, returning value unchanged when it
+// already has the requested case
+func convertCase(value string, convert stepOp) string {
+	switch convert {
+	case stepToLower:
+		return strings.ToLower(value)
+	case stepToUpper:
+		return strings.ToUpper(value)
+	}
+	return value
+}
+
+// isASCII reports whether s contains only ASCII characters
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
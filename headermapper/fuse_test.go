@@ -0,0 +1,70 @@
+package headermapper
+
+import (
+	"testing"
+)
+
+func TestFuseTransforms(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   []TransformStep
+		chained TransformFunc
+		inputs  []string
+	}{
+		{
+			name:    "trim, remove prefix, lower",
+			steps:   []TransformStep{TrimSpaceStep(), RemovePrefixStep("Bearer "), ToLowerStep()},
+			chained: ChainTransforms(TrimSpace, RemovePrefix("Bearer "), ToLower),
+			inputs:  []string{"  Bearer TOKEN123  ", "token", "", "Bearer ÄBC"},
+		},
+		{
+			name:    "prefix, suffix and upper",
+			steps:   []TransformStep{AddPrefixStep("id:"), ToUpperStep(), AddSuffixStep("-x")},
+			chained: ChainTransforms(AddPrefix("id:"), ToUpper, AddSuffix("-x")),
+			inputs:  []string{"abc", "", "straße"},
+		},
+		{
+			name:    "slicing after building starts a new pass",
+			steps:   []TransformStep{AddPrefixStep("  "), TrimSpaceStep(), RemoveSuffixStep(".com")},
+			chained: ChainTransforms(AddPrefix("  "), TrimSpace, RemoveSuffix(".com")),
+			inputs:  []string{"example.com ", "x"},
+		},
+		{
+			name:    "mixed case conversions",
+			steps:   []TransformStep{ToLowerStep(), AddPrefixStep("Key-"), ToUpperStep()},
+			chained: ChainTransforms(ToLower, AddPrefix("Key-"), ToUpper),
+			inputs:  []string{"Value", "ǅ"},
+		},
+		{
+			name:    "custom step",
+			steps:   []TransformStep{TrimSpaceStep(), FuncStep(ExtractBearerToken), AddSuffixStep("!")},
+			chained: ChainTransforms(TrimSpace, ExtractBearerToken, AddSuffix("!")),
+			inputs:  []string{" Bearer abc ", "abc"},
+		},
+		{
+			name:    "no steps",
+			chained: ChainTransforms(),
+			inputs:  []string{"unchanged"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fused := FuseTransforms(tt.steps...)
+			for _, input := range tt.inputs {
+				if got, want := fused(input), tt.chained(input); got != want {
+					t.Errorf("fused(%q) = %q, want %q", input, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFuseTransforms_SingleAllocation(t *testing.T) {
+	fused := FuseTransforms(TrimSpaceStep(), RemovePrefixStep("Bearer "), AddPrefixStep("token:"), ToLowerStep(), AddSuffixStep(";v1"))
+
+	allocs := testing.AllocsPerRun(100, func() { _ = fused("  Bearer ABC123  ") })
+	if allocs > 1 {
+		t.Errorf("fused allocations = %v, want <= 1", allocs)
+	}
+}
@@ -0,0 +1,193 @@
+package headermapper
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// gatewayCheckedKey carries the checks Handler enforced for a request from
+// the gateway to a gRPC server sharing the mapper, which then skips the
+// checks that must not run twice, such as taking a rate limit token or
+// recording a nonce. The value is a single-use marker issued by the mapper,
+// so copying it from one call to another proves nothing. It is only added
+// by MetadataAnnotator, never to requests forwarded over HTTP.
+const gatewayCheckedKey = "x-headermapper-checked"
+
+const (
+	// gatewayMarkTTL bounds the time between the gateway issuing a marker
+	// and the gRPC server consuming it
+	gatewayMarkTTL = 30 * time.Second
+	// maxGatewayMarks bounds the markers awaiting consumption, such as
+	// those of gateways calling servers that do not share the mapper;
+	// beyond it the server enforces the checks again
+	maxGatewayMarks = 4096
+)
+
+// Names of the checks a gRPC server skips once Handler enforced them
+const (
+	replayCheck    = "replay"
+	rateLimitCheck = "rate-limit"
+	// ipFilterCheck is never skipped, but lets the server keep the client
+	// IP and decision the gateway recorded
+	ipFilterCheck = "ip-filter"
+)
+
+// gatewayCheckedContextKey is the context key of the checks a call proved
+// Handler enforced
+type gatewayCheckedContextKey struct{}
+
+// gatewayMarks holds the markers issued for gateway requests until a gRPC
+// server sharing the mapper consumes them
+type gatewayMarks struct {
+	mu      sync.Mutex
+	pending map[string]gatewayMark
+}
+
+// gatewayMark is the checks Handler enforced for one request
+type gatewayMark struct {
+	checks  []string
+	expires time.Time
+}
+
+// issue returns a new marker for checks; false when too many are pending
+func (g *gatewayMarks) issue(checks []string) (string, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	if len(g.pending) >= maxGatewayMarks {
+		for marker, mark := range g.pending {
+			if now.After(mark.expires) {
+				delete(g.pending, marker)
+			}
+		}
+		if len(g.pending) >= maxGatewayMarks {
+			return "", false
+		}
+	}
+	if g.pending == nil {
+		g.pending = make(map[string]gatewayMark)
+	}
+
+	var b [16]byte
+	rand.Read(b[:])
+	marker := hex.EncodeToString(b[:])
+	g.pending[marker] = gatewayMark{checks: checks, expires: now.Add(gatewayMarkTTL)}
+	return marker, true
+}
+
+// consume returns the checks of marker and forgets it; nil when marker was
+// not issued, has expired or was already consumed
+func (g *gatewayMarks) consume(marker string) []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	mark, ok := g.pending[marker]
+	if !ok {
+		return nil
+	}
+	delete(g.pending, marker)
+	if time.Now().After(mark.expires) {
+		return nil
+	}
+	return mark.checks
+}
+
+// markChecked records in the request memo that check was enforced
+func markChecked(ctx context.Context, check string) {
+	if memo, ok := ctx.Value(requestMemoKey{}).(*requestMemo); ok {
+		memo.mu.Lock()
+		memo.checked = append(memo.checked, check)
+		memo.mu.Unlock()
+	}
+}
+
+// handlerChecked reports whether Handler enforced check for the request of
+// ctx, for hooks running in the same process such as the Connect
+// interceptor
+func handlerChecked(ctx context.Context, check string) bool {
+	memo, ok := ctx.Value(requestMemoKey{}).(*requestMemo)
+	if !ok {
+		return false
+	}
+	memo.mu.Lock()
+	defer memo.mu.Unlock()
+	for _, checked := range memo.checked {
+		if checked == check {
+			return true
+		}
+	}
+	return false
+}
+
+// anyChecked reports whether Handler enforced a check for ctx
+func anyChecked(ctx context.Context) bool {
+	memo, ok := ctx.Value(requestMemoKey{}).(*requestMemo)
+	if !ok {
+		return false
+	}
+	memo.mu.Lock()
+	defer memo.mu.Unlock()
+	return len(memo.checked) > 0
+}
+
+// setGatewayChecked adds a marker of the checks Handler enforced for ctx
+// to md
+func (hm *HeaderMapper) setGatewayChecked(ctx context.Context, md metadata.MD) {
+	memo, ok := ctx.Value(requestMemoKey{}).(*requestMemo)
+	if !ok {
+		return
+	}
+	memo.mu.Lock()
+	checks := append([]string(nil), memo.checked...)
+	memo.mu.Unlock()
+	if len(checks) == 0 {
+		return
+	}
+	if marker, ok := hm.gatewayMarks.issue(checks); ok {
+		md.Set(gatewayCheckedKey, marker)
+	}
+}
+
+// gatewayChecked removes gatewayCheckedKey from md and returns ctx carrying
+// the checks of the markers this mapper issued, consuming them
+func (hm *HeaderMapper) gatewayChecked(ctx context.Context, md metadata.MD) context.Context {
+	values := md[gatewayCheckedKey]
+	if len(values) == 0 {
+		return ctx
+	}
+	delete(md, gatewayCheckedKey)
+
+	checked := make(map[string]bool)
+	for _, marker := range values {
+		for _, check := range hm.gatewayMarks.consume(marker) {
+			checked[check] = true
+		}
+	}
+	return context.WithValue(ctx, gatewayCheckedContextKey{}, checked)
+}
+
+// gatewayEnforced reports whether Handler enforced check for the call of
+// ctx, in this process or at the gateway
+func gatewayEnforced(ctx context.Context, check string) bool {
+	if checked, _ := ctx.Value(gatewayCheckedContextKey{}).(map[string]bool); checked[check] {
+		return true
+	}
+	return handlerChecked(ctx, check)
+}
+
+// unlessGatewayChecked wraps a call check to skip calls for which Handler
+// already enforced it, in this process or at the gateway
+func unlessGatewayChecked(name string, check func(ctx context.Context, fullMethod string, md metadata.MD) error) func(ctx context.Context, fullMethod string, md metadata.MD) error {
+	return func(ctx context.Context, fullMethod string, md metadata.MD) error {
+		if gatewayEnforced(ctx, name) {
+			return nil
+		}
+		return check(ctx, fullMethod, md)
+	}
+}
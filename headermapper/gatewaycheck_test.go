@@ -0,0 +1,221 @@
+package headermapper
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// startSharedGateway serves the health Check method through a gateway whose
+// Handler and backend gRPC server share mapper, returning the gateway, a
+// client calling the backend directly and the gatewayCheckedKey values the
+// backend received
+func startSharedGateway(t *testing.T, mapper *HeaderMapper) (*httptest.Server, grpc_health_v1.HealthClient, *[]string) {
+	t.Helper()
+	var markers []string
+	capture := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		markers = append(markers, metadata.ValueFromIncomingContext(ctx, gatewayCheckedKey)...)
+		return handler(ctx, req)
+	}
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(capture, mapper.UnaryServerInterceptor()))
+	grpc_health_v1.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	client := grpc_health_v1.NewHealthClient(conn)
+
+	mux := CreateGatewayMux(mapper)
+	err = mux.HandlePath("GET", "/v1/health", func(w http.ResponseWriter, req *http.Request, _ map[string]string) {
+		_, outbound := runtime.MarshalerForRequest(mux, req)
+		ctx, err := runtime.AnnotateContext(req.Context(), mux, req, "/grpc.health.v1.Health/Check")
+		if err == nil {
+			_, err = client.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+		}
+		if err != nil {
+			runtime.HTTPError(req.Context(), mux, outbound, w, req, err)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gateway := httptest.NewServer(mapper.Handler(mux))
+	t.Cleanup(gateway.Close)
+	return gateway, client, &markers
+}
+
+func TestReplayGuard_SharedGateway(t *testing.T) {
+	tests := []struct {
+		name   string
+		mapped bool
+	}{
+		{"nonce mapped", true},
+		{"nonce not mapped", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := NewBuilder().GuardReplays(&ReplayGuardConfig{MaxSkew: time.Minute})
+			if tt.mapped {
+				builder.AddIncomingMapping("X-Nonce", "x-nonce").AddIncomingMapping("X-Timestamp", "x-timestamp")
+			}
+			gateway, client, _ := startSharedGateway(t, builder.Build())
+
+			send := func(nonce string) int {
+				req, _ := http.NewRequest("GET", gateway.URL+"/v1/health", nil)
+				req.Header.Set("X-Nonce", nonce)
+				req.Header.Set("X-Timestamp", strconv.FormatInt(time.Now().Unix(), 10))
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+				return resp.StatusCode
+			}
+			if code := send("n-1"); code != http.StatusOK {
+				t.Errorf("fresh request status = %d, want %d", code, http.StatusOK)
+			}
+			if code := send("n-1"); code != http.StatusUnauthorized {
+				t.Errorf("replayed request status = %d, want %d", code, http.StatusUnauthorized)
+			}
+
+			// Direct calls cannot claim the gateway checked them
+			ctx := metadata.AppendToOutgoingContext(context.Background(), gatewayCheckedKey, "forged "+replayCheck)
+			_, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+			if status.Code(err) != codes.Unauthenticated {
+				t.Errorf("forged call code = %v, want %v", status.Code(err), codes.Unauthenticated)
+			}
+		})
+	}
+}
+
+func TestRateLimit_SharedGateway(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-API-Key", "api-key").
+		RateLimit(&RateLimitConfig{KeyMetadata: []string{"api-key"}, Rate: 0.001, Burst: 2}).
+		Build()
+	gateway, client, markers := startSharedGateway(t, mapper)
+
+	tests := []struct {
+		name     string
+		expected int
+	}{
+		{"first request", http.StatusOK},
+		{"second request", http.StatusOK},
+		{"third request", http.StatusTooManyRequests},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", gateway.URL+"/v1/health", nil)
+			req.Header.Set("X-API-Key", "key-1")
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.expected {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.expected)
+			}
+		})
+	}
+
+	// Direct calls are charged by the server, whether they forge a marker or
+	// copy one the gateway sent
+	if len(*markers) == 0 {
+		t.Fatal("gateway sent no marker")
+	}
+	for _, marker := range []string{"forged", (*markers)[0]} {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "api-key", "key-"+marker, gatewayCheckedKey, marker)
+		for i, expected := range []codes.Code{codes.OK, codes.OK, codes.ResourceExhausted} {
+			if _, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{}); status.Code(err) != expected {
+				t.Errorf("marker %q: direct call %d code = %v, want %v", marker, i, status.Code(err), expected)
+			}
+		}
+	}
+}
+
+func TestGatewayChecked_NotForwardedUpstream(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-API-Key", "api-key").
+		RateLimit(&RateLimitConfig{KeyMetadata: []string{"api-key"}, Rate: 100, Burst: 100}).
+		Build()
+
+	var seen []string
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		seen = append(seen, req.Header.Values(gatewayCheckedKey)...)
+		seen = append(seen, metadata.ValueFromIncomingContext(req.Context(), gatewayCheckedKey)...)
+	})
+
+	tests := []struct {
+		name        string
+		handler     http.Handler
+		contentType string
+	}{
+		{"HTTPMiddleware", mapper.HTTPMiddleware(upstream), ""},
+		{"GRPCWebHandler", mapper.GRPCWebHandler(upstream, nil), "application/grpc-web+proto"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen = nil
+			req := httptest.NewRequest(http.MethodPost, "/grpc.health.v1.Health/Check", nil)
+			req.Header.Set("X-API-Key", "key-1")
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			tt.handler.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d", w.Code)
+			}
+			if len(seen) > 0 {
+				t.Errorf("upstream saw %s = %q", gatewayCheckedKey, seen)
+			}
+		})
+	}
+}
+
+func TestGatewayChecked_Connect(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").
+		RateLimit(&RateLimitConfig{KeyMetadata: []string{"user-id"}, Rate: 0.001, Burst: 1}).
+		Build()
+	server := startConnectServer(t, mapper)
+	client := connect.NewClient[grpc_health_v1.HealthCheckRequest, grpc_health_v1.HealthCheckResponse](
+		server.Client(), server.URL+connectCheckProcedure)
+
+	// The interceptor sees the check ConnectHandler enforced in-process, so
+	// the call is charged once
+	for i, allowed := range []bool{true, false} {
+		req := connect.NewRequest(&grpc_health_v1.HealthCheckRequest{})
+		req.Header().Set("X-User-ID", "alice")
+		if _, err := client.CallUnary(context.Background(), req); (err == nil) != allowed {
+			t.Errorf("call %d error = %v, want allowed %v", i, err, allowed)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package headermapper
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// GenerateUUID returns a random (version 4) UUID, for use with WithGenerator
+func GenerateUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	var buf [36]byte
+	hex.Encode(buf[0:8], b[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], b[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], b[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], b[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], b[10:])
+	return string(buf[:])
+}
+
+// GenerateTimestamp returns a generator formatting the current time with
+// layout, e.g. time.RFC3339
+func GenerateTimestamp(layout string) func() string {
+	return func() string {
+		return time.Now().UTC().Format(layout)
+	}
+}
@@ -0,0 +1,87 @@
+package headermapper
+
+import (
+	"context"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestGenerateUUID(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		id := GenerateUUID()
+		if !uuidPattern.MatchString(id) {
+			t.Fatalf("GenerateUUID() = %q, not a version 4 UUID", id)
+		}
+		if seen[id] {
+			t.Fatalf("GenerateUUID() repeated %q", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestGenerateTimestamp(t *testing.T) {
+	value := GenerateTimestamp(time.RFC3339)()
+	if _, err := time.Parse(time.RFC3339, value); err != nil {
+		t.Errorf("GenerateTimestamp() = %q: %v", value, err)
+	}
+}
+
+func TestWithGenerator_Incoming(t *testing.T) {
+	calls := 0
+	mapper := NewBuilder().
+		AddIncomingMapping("X-Request-ID", "request-id").
+		WithGenerator(func() string {
+			calls++
+			return "generated-" + string(rune('0'+calls))
+		}).
+		WithDefault("default").
+		Build()
+	annotator := mapper.MetadataAnnotator()
+
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{"missing header generates", "", "generated-1"},
+		{"generated per request", "", "generated-2"},
+		{"header wins", "req-1", "req-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/test", nil)
+			if tt.header != "" {
+				req.Header.Set("X-Request-ID", tt.header)
+			}
+			md := annotator(context.Background(), req)
+			if got := md.Get("request-id"); len(got) != 1 || got[0] != tt.want {
+				t.Errorf("request-id = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithGenerator_Outgoing(t *testing.T) {
+	mapper := NewBuilder().
+		AddOutgoingMapping("request-id", "X-Request-ID").
+		WithGenerator(GenerateUUID).
+		Build()
+
+	w := httptest.NewRecorder()
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{HeaderMD: metadata.MD{}})
+	if err := mapper.ResponseModifier()(ctx, w, nil); err != nil {
+		t.Fatalf("ResponseModifier() error = %v", err)
+	}
+	if got := w.Header().Get("X-Request-ID"); !uuidPattern.MatchString(got) {
+		t.Errorf("X-Request-ID = %q, want a UUID", got)
+	}
+}
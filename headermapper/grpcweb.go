@@ -0,0 +1,102 @@
+package headermapper
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// GRPCWebHandler serves gRPC-Web requests with grpcWeb, such as a server
+// wrapped with improbable-eng/grpc-web or a connect-go handler, applying the
+// same mappings as the gateway, and passes other requests to gateway
+// unchanged. A nil gateway answers other requests with 404.
+//
+// gRPC-Web requests are recognized by their application/grpc-web content
+// types or the X-Grpc-Web header browser clients send, along with their
+// CORS preflights. They run the Handler policies; the mapped metadata is
+// set as request headers named by their keys, with values of -bin keys
+// base64 encoded as the protocol requires, after removing client headers
+// named like reserved keys and blocked headers. The outgoing mappings are
+// applied to the response headers, decoding -bin values first. Trailers
+// are sent in the response body, so only those of trailers-only responses,
+// which carry them as headers, are mapped.
+//
+//	mux := mapper.CreateGatewayMux()
+//	wrapped := grpcweb.WrapServer(grpcServer)
+//	http.ListenAndServe(":8080", mapper.GRPCWebHandler(wrapped, mux))
+func (hm *HeaderMapper) GRPCWebHandler(grpcWeb, gateway http.Handler) http.Handler {
+	annotator := hm.UpstreamAnnotator()
+	mapped := hm.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		cc, release := hm.requestState(req.Context())
+		defer release()
+		if cc.skipPaths[req.URL.Path] || req.Method == http.MethodOptions {
+			grpcWeb.ServeHTTP(w, req)
+			return
+		}
+
+		md := annotator(req.Context(), req)
+		req = req.Clone(metadata.NewIncomingContext(req.Context(), md))
+		hm.stripSpoofedHeaders(cc, req.Header)
+		for key, values := range md {
+			req.Header[http.CanonicalHeaderKey(key)] = encodeBinaryValues(key, values)
+		}
+
+		mw := &middlewareWriter{ResponseWriter: w, hm: hm, cc: cc, req: req, grpcWeb: true}
+		grpcWeb.ServeHTTP(mw, req)
+		if !mw.wroteHeader {
+			mw.mapResponse()
+		}
+	}))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case isGRPCWebRequest(req):
+			mapped.ServeHTTP(w, req)
+		case gateway != nil:
+			gateway.ServeHTTP(w, req)
+		default:
+			http.NotFound(w, req)
+		}
+	})
+}
+
+// isGRPCWebRequest reports whether req is a gRPC-Web call or its preflight
+func isGRPCWebRequest(req *http.Request) bool {
+	if req.Method == http.MethodOptions {
+		return containsFold(strings.Split(strings.ReplaceAll(req.Header.Get("Access-Control-Request-Headers"), " ", ""), ","), "x-grpc-web")
+	}
+	return strings.HasPrefix(req.Header.Get("Content-Type"), "application/grpc-web") || req.Header.Get("X-Grpc-Web") != ""
+}
+
+// encodeBinaryValues base64 encodes the values of -bin keys for transports
+// carrying metadata as HTTP headers
+func encodeBinaryValues(key string, values []string) []string {
+	if !strings.HasSuffix(key, "-bin") {
+		return values
+	}
+	encoded := make([]string, len(values))
+	for i, value := range values {
+		encoded[i] = base64.StdEncoding.EncodeToString([]byte(value))
+	}
+	return encoded
+}
+
+// decodeBinaryValues reverses encodeBinaryValues, keeping values that are
+// not valid base64; padding is optional as in gRPC
+func decodeBinaryValues(key string, values []string) []string {
+	if !strings.HasSuffix(key, "-bin") {
+		return values
+	}
+	decoded := make([]string, len(values))
+	for i, value := range values {
+		raw, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(value, "="))
+		if err != nil {
+			decoded[i] = value
+			continue
+		}
+		decoded[i] = string(raw)
+	}
+	return decoded
+}
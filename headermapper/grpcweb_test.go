@@ -0,0 +1,162 @@
+package headermapper
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"connectrpc.com/connect"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// startGRPCWebServer serves the health service over gRPC-Web behind
+// GRPCWebHandler, echoing request headers in the response headers
+func startGRPCWebServer(t *testing.T, gateway http.Handler) *httptest.Server {
+	t.Helper()
+	mapper := NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").
+		AddIncomingMapping("X-Trace", "trace-bin").
+		AddOutgoingMapping("server-version", "X-Server-Version").WithTransform(TrimSpace).
+		AddOutgoingMapping("token-bin", "X-Token").
+		AddOutgoingMapping("error-reason", "X-Error-Reason").WithSource(SourceTrailer).
+		Build()
+
+	grpcWeb := connect.NewUnaryHandler(connectCheckProcedure,
+		func(ctx context.Context, req *connect.Request[grpc_health_v1.HealthCheckRequest]) (*connect.Response[grpc_health_v1.HealthCheckResponse], error) {
+			if req.Msg.GetService() == "fail" {
+				err := connect.NewError(connect.CodeResourceExhausted, errors.New("quota"))
+				err.Meta().Set("error-reason", "quota-exceeded")
+				return nil, err
+			}
+			trace, _ := connect.DecodeBinaryHeader(req.Header().Get("Trace-Bin"))
+			resp := connect.NewResponse(&grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING})
+			resp.Header().Set("Echo-User", req.Header().Get("User-Id"))
+			resp.Header().Set("Echo-Trace", string(trace))
+			resp.Header().Set("server-version", " v3 ")
+			resp.Header().Set("token-bin", connect.EncodeBinaryHeader([]byte("secret")))
+			return resp, nil
+		})
+
+	server := httptest.NewServer(mapper.GRPCWebHandler(grpcWeb, gateway))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGRPCWebHandler(t *testing.T) {
+	server := startGRPCWebServer(t, nil)
+	client := connect.NewClient[grpc_health_v1.HealthCheckRequest, grpc_health_v1.HealthCheckResponse](
+		server.Client(), server.URL+connectCheckProcedure, connect.WithGRPCWeb())
+
+	req := connect.NewRequest(&grpc_health_v1.HealthCheckRequest{})
+	req.Header().Set("X-User-ID", "alice")
+	req.Header().Set("X-Trace", base64.StdEncoding.EncodeToString([]byte("trace\xff")))
+	resp, err := client.CallUnary(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"Echo-User", "alice"},
+		{"Echo-Trace", "trace\xff"},
+		{"X-Server-Version", "v3"},
+		{"X-Token", base64.StdEncoding.EncodeToString([]byte("secret"))},
+	}
+	for _, tt := range tests {
+		if got := resp.Header().Get(tt.header); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestGRPCWebHandler_TrailersOnly(t *testing.T) {
+	server := startGRPCWebServer(t, nil)
+	client := connect.NewClient[grpc_health_v1.HealthCheckRequest, grpc_health_v1.HealthCheckResponse](
+		server.Client(), server.URL+connectCheckProcedure, connect.WithGRPCWeb())
+
+	_, err := client.CallUnary(context.Background(), connect.NewRequest(&grpc_health_v1.HealthCheckRequest{Service: "fail"}))
+	var connectErr *connect.Error
+	if !errors.As(err, &connectErr) || connectErr.Code() != connect.CodeResourceExhausted {
+		t.Fatalf("CallUnary() error = %v, want resource_exhausted", err)
+	}
+	if got := connectErr.Meta().Get("X-Error-Reason"); got != "quota-exceeded" {
+		t.Errorf("X-Error-Reason = %q, want quota-exceeded", got)
+	}
+}
+
+func TestGRPCWebHandler_Routing(t *testing.T) {
+	gateway := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		io.WriteString(w, "gateway")
+	})
+
+	tests := []struct {
+		name    string
+		gateway http.Handler
+		method  string
+		headers map[string]string
+		status  int
+		served  bool
+	}{
+		{"rest", gateway, http.MethodGet, nil, http.StatusOK, true},
+		{"rest without gateway", nil, http.MethodGet, nil, http.StatusNotFound, false},
+		{"grpc-web preflight", gateway, http.MethodOptions, map[string]string{
+			"Origin": "https://app.example.com", "Access-Control-Request-Method": "POST",
+			"Access-Control-Request-Headers": "content-type, x-grpc-web"}, http.StatusMethodNotAllowed, false},
+		// Connect rejects the JSON body, proving the call reached it
+		{"x-grpc-web", gateway, http.MethodPost, map[string]string{"X-Grpc-Web": "1", "Content-Type": "application/json"}, http.StatusBadRequest, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := startGRPCWebServer(t, tt.gateway)
+			req, _ := http.NewRequest(tt.method, server.URL+connectCheckProcedure, nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			resp, err := server.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if served := string(body) == "gateway"; served != tt.served {
+				t.Errorf("served by gateway = %v, want %v", served, tt.served)
+			}
+		})
+	}
+}
+
+func TestGRPCWebHandler_StripsSpoofedHeaders(t *testing.T) {
+	mapper := NewBuilder().
+		MapJWTClaims(ExtractJWTClaims(map[string]string{"sub": "user-id"})).
+		BlockHeaders("X-Internal-Token").
+		Build()
+	grpcWeb := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-Seen", req.Header.Get("User-Id")+"|"+req.Header.Get("X-Internal-Token"))
+	})
+	handler := mapper.GRPCWebHandler(grpcWeb, nil)
+
+	for _, token := range []string{"", "Bearer not-a-jwt"} {
+		req := httptest.NewRequest(http.MethodPost, connectCheckProcedure, nil)
+		req.Header.Set("Content-Type", "application/grpc-web+proto")
+		req.Header.Set("User-Id", "admin")
+		req.Header.Set("X-Internal-Token", "forged")
+		if token != "" {
+			req.Header.Set("Authorization", token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if got := w.Header().Get("X-Seen"); got != "|" {
+			t.Errorf("Authorization %q: server saw %q, want no spoofed headers", token, got)
+		}
+	}
+}
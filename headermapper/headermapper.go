@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
@@ -34,22 +36,73 @@ type TransformFunc func(value string) string
 type HeaderMapping struct {
 	// HTTPHeader is the HTTP header name (case-insensitive)
 	HTTPHeader string `json:"http_header" yaml:"http_header"`
+	// HTTPHeaderPattern is a regular expression matching whole header names
+	// (case-insensitive) in place of HTTPHeader. GRPCMetadata is then a
+	// template expanded with the capture groups, e.g. "$1-id" or "${kind}-id".
+	// Pattern mappings are incoming only.
+	HTTPHeaderPattern string `json:"http_header_pattern,omitempty" yaml:"http_header_pattern,omitempty"`
+	// QueryParam reads the URL query parameter of that name in place of
+	// HTTPHeader, e.g. api_key, for clients that cannot set headers
+	QueryParam string `json:"query_param,omitempty" yaml:"query_param,omitempty"`
+	// PathParam reads the grpc-gateway path parameter of that name in place
+	// of HTTPHeader, e.g. the tenant of /v1/tenants/{tenant}/users
+	PathParam string `json:"path_param,omitempty" yaml:"path_param,omitempty"`
+	// StripParam removes the QueryParam from the URL once mapped, so it is
+	// not decoded into the request message
+	StripParam bool `json:"strip_param,omitempty" yaml:"strip_param,omitempty"`
 	// GRPCMetadata is the gRPC metadata key (case-sensitive)
 	GRPCMetadata string `json:"grpc_metadata" yaml:"grpc_metadata"`
 	// Direction specifies mapping direction
 	Direction MappingDirection `json:"direction" yaml:"direction"`
 	// Transform is an optional transformation function
 	Transform TransformFunc `json:"-" yaml:"-"`
+	// TransformNames names registered transforms or pipeline stages chained
+	// in order when Transform is not set, so configuration files can
+	// express transformations
+	TransformNames []string `json:"transform,omitempty" yaml:"transform,omitempty"`
 	// Required indicates if this header is required
 	Required bool `json:"required" yaml:"required"`
 	// DefaultValue is used when header is missing and Required is false
 	DefaultValue string `json:"default_value" yaml:"default_value"`
+	// Generator produces a value per request or response when the header is
+	// missing, e.g. a request ID; it takes precedence over DefaultValue and
+	// must be safe for concurrent use
+	Generator func() string `json:"-" yaml:"-"`
+	// CacheTransform serves Transform results from the shared transform cache;
+	// only set it for deterministic transforms
+	CacheTransform bool `json:"cache_transform,omitempty" yaml:"cache_transform,omitempty"`
+	// Aliases lists legacy names of an incoming HTTPHeader, read in order
+	// when the header itself is missing
+	Aliases []string `json:"aliases,omitempty" yaml:"aliases,omitempty"`
+	// Deprecated logs and counts requests using an alias, to track client migration
+	Deprecated bool `json:"deprecated,omitempty" yaml:"deprecated,omitempty"`
+	// Source selects the response metadata an outgoing mapping reads:
+	// header (default), trailer, or both with headers taking precedence
+	Source MetadataSource `json:"source,omitempty" yaml:"source,omitempty"`
+	// AppendValues adds the value to those other mappings already placed
+	// under the metadata key or response header instead of replacing or
+	// skipping them
+	AppendValues bool `json:"append_values,omitempty" yaml:"append_values,omitempty"`
+	// BinaryEncoding sets how the header values of a mapping to a binary
+	// metadata key, ending in -bin, encode its bytes: base64 (default), hex
+	// or raw. Incoming values are decoded and outgoing values encoded.
+	BinaryEncoding BinaryEncoding `json:"binary_encoding,omitempty" yaml:"binary_encoding,omitempty"`
 }
 
 // Config holds the configuration for header mapping
 type Config struct {
 	// Mappings defines the header mappings
 	Mappings []HeaderMapping `json:"mappings" yaml:"mappings"`
+	// StrictAllowList disables the default matcher fallback of HeaderMatcher,
+	// so only headers read by a mapping reach the metadata
+	StrictAllowList bool `json:"strict_allow_list,omitempty" yaml:"strict_allow_list,omitempty"`
+	// BlockedHeaders lists headers never forwarded to metadata, even by the
+	// default matcher, and metadata keys never written to response headers.
+	// Names ending in "*" block every name with that prefix.
+	BlockedHeaders []string `json:"blocked_headers,omitempty" yaml:"blocked_headers,omitempty"`
+	// StatusMetadataKey names the response metadata a backend sets to choose
+	// the HTTP status of a successful response, such as 201 or 202
+	StatusMetadataKey string `json:"status_metadata_key,omitempty" yaml:"status_metadata_key,omitempty"`
 	// SkipPaths defines paths to skip header mapping
 	SkipPaths []string `json:"skip_paths" yaml:"skip_paths"`
 	// CaseSensitive determines if HTTP header matching is case-sensitive
@@ -58,13 +111,117 @@ type Config struct {
 	OverwriteExisting bool `json:"overwrite_existing" yaml:"overwrite_existing"`
 	// Debug enables debug logging
 	Debug bool `json:"debug" yaml:"debug"`
+	// InternalNamespaces lists header prefixes, e.g. x-internal- or x-envoy-, that
+	// are always removed from external requests and from responses
+	InternalNamespaces []string `json:"internal_namespaces" yaml:"internal_namespaces"`
+	// TrustedProxies lists CIDRs of proxies whose X-Forwarded-* headers are honored;
+	// when empty the headers are always honored
+	TrustedProxies []string `json:"trusted_proxies" yaml:"trusted_proxies"`
+	// DuplicateHeaders handles headers sent several times with conflicting values
+	DuplicateHeaders DuplicateHeaderPolicy `json:"duplicate_headers" yaml:"duplicate_headers"`
+	// OutgoingOrder sets the order in which outgoing mappings are applied,
+	// so responses are deterministic
+	OutgoingOrder HeaderOrder `json:"outgoing_order,omitempty" yaml:"outgoing_order,omitempty"`
+	// FIPSMode restricts signing, encryption and hashing to FIPS-approved algorithms
+	FIPSMode bool `json:"fips_mode" yaml:"fips_mode"`
+	// StrictMode rejects requests and calls missing the headers of required
+	// mappings with InvalidArgument instead of logging a warning
+	StrictMode bool `json:"strict_mode,omitempty" yaml:"strict_mode,omitempty"`
+	// Signature enables verification of request signatures
+	Signature *SignatureConfig `json:"signature,omitempty" yaml:"signature,omitempty"`
+	// SPIFFE maps the client's mTLS SPIFFE ID into metadata
+	SPIFFE *SPIFFEConfig `json:"spiffe,omitempty" yaml:"spiffe,omitempty"`
+	// JWTClaims maps the claims of a bearer token into metadata
+	JWTClaims *JWTClaimsConfig `json:"jwt_claims,omitempty" yaml:"jwt_claims,omitempty"`
+	// BasicAuth maps the username and password of Basic credentials into metadata
+	BasicAuth *BasicAuthConfig `json:"basic_auth,omitempty" yaml:"basic_auth,omitempty"`
+	// Composites join several incoming headers into single metadata values
+	Composites []CompositeMapping `json:"composites,omitempty" yaml:"composites,omitempty"`
+	// Authorization enforces access rules on mapped metadata
+	Authorization *AuthorizationConfig `json:"authorization,omitempty" yaml:"authorization,omitempty"`
+	// RateLimit limits requests per mapped metadata value
+	RateLimit *RateLimitConfig `json:"rate_limit,omitempty" yaml:"rate_limit,omitempty"`
+	// IPFilter rejects requests by client IP
+	IPFilter *IPFilterConfig `json:"ip_filter,omitempty" yaml:"ip_filter,omitempty"`
+	// CSRF validates double-submit CSRF tokens on unsafe requests
+	CSRF *CSRFConfig `json:"csrf,omitempty" yaml:"csrf,omitempty"`
+	// ReplayGuard rejects requests with reused nonces or stale timestamps
+	ReplayGuard *ReplayGuardConfig `json:"replay_guard,omitempty" yaml:"replay_guard,omitempty"`
+	// CORS handles cross-origin requests in sync with the mappings
+	CORS *CORSConfig `json:"cors,omitempty" yaml:"cors,omitempty"`
+	// MetadataLimit caps the metadata size contributed by mappings
+	MetadataLimit *MetadataLimitConfig `json:"metadata_limit,omitempty" yaml:"metadata_limit,omitempty"`
+	// Audit records the identity metadata of each request
+	Audit *AuditConfig `json:"audit,omitempty" yaml:"audit,omitempty"`
+	// Encryption encrypts selected metadata between gateway and backend
+	Encryption *EncryptionConfig `json:"encryption,omitempty" yaml:"encryption,omitempty"`
+	// PropagationSigning signs selected metadata at the gateway for backends to verify
+	PropagationSigning *PropagationSigningConfig `json:"propagation_signing,omitempty" yaml:"propagation_signing,omitempty"`
+	// SharedSecret requires a shared-secret header such as an internal service token
+	SharedSecret *SharedSecretConfig `json:"shared_secret,omitempty" yaml:"shared_secret,omitempty"`
+	// TransformCache sizes the cache used by mappings with CacheTransform set
+	TransformCache *TransformCacheConfig `json:"transform_cache,omitempty" yaml:"transform_cache,omitempty"`
+	// MappingBudget bounds the time spent mapping one request or response;
+	// once exceeded, the remaining mappings are skipped. Zero disables it.
+	MappingBudget time.Duration `json:"mapping_budget,omitempty" yaml:"mapping_budget,omitempty"`
+	// ParallelMapping evaluates large sets of incoming mappings across goroutines
+	ParallelMapping *ParallelMappingConfig `json:"parallel_mapping,omitempty" yaml:"parallel_mapping,omitempty"`
+	// DebugEchoHeader lists the applied mappings in the X-Mapped-Headers
+	// response header of requests sending X-HeaderMapper-Debug: 1
+	DebugEchoHeader bool `json:"debug_echo_header,omitempty" yaml:"debug_echo_header,omitempty"`
+	// Idempotency deduplicates retried requests carrying an Idempotency-Key
+	Idempotency *IdempotencyConfig `json:"idempotency,omitempty" yaml:"idempotency,omitempty"`
+	// Timeout applies client-requested timeouts as request deadlines
+	Timeout *TimeoutConfig `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	// DeadlineHeader names a response header, e.g. X-Deadline-Remaining,
+	// exposing the time left before the request deadline in the format of
+	// the timeout header. It does not honor timeouts requested by clients.
+	DeadlineHeader string `json:"deadline_header,omitempty" yaml:"deadline_header,omitempty"`
+	// SSE delivers server streams as server-sent events to clients asking for them
+	SSE *SSEConfig `json:"sse,omitempty" yaml:"sse,omitempty"`
+	// ETag computes entity tags and answers conditional GET requests
+	ETag *ETagConfig `json:"etag,omitempty" yaml:"etag,omitempty"`
+	// Experiment assigns requests to sticky experiment variants in Handler
+	Experiment *StickyExperimentConfig `json:"experiment,omitempty" yaml:"experiment,omitempty"`
+	// TraceContext propagates the trace context of requests to backends
+	TraceContext *TraceContextConfig `json:"trace_context,omitempty" yaml:"trace_context,omitempty"`
 }
 
 // HeaderMapper provides header mapping functionality
 type HeaderMapper struct {
-	config    *Config
-	skipPaths map[string]bool
-	logger    Logger
+	// active holds the mapping state, replaced atomically by UpdateConfig
+	active atomic.Pointer[compiledConfig]
+	// updates serializes configuration changes so concurrent runtime
+	// mapping changes are not lost
+	updates        sync.Mutex
+	retired        mappingTotals
+	budgetOverruns atomic.Int64
+	// logger is replaced by SetLogger while requests read it
+	logger             atomic.Pointer[Logger]
+	requestChecks      []requestCheck
+	annotators         []func(req *http.Request, md metadata.MD)
+	incomingProcessors []func(ctx context.Context, md metadata.MD) error
+	callChecks         []func(ctx context.Context, fullMethod string, md metadata.MD) error
+	reservedKeys       map[string]bool
+	// strict is set when StrictMode registered the required metadata check
+	strict             bool
+	metadataLimit      *metadataLimit
+	auditor            *auditor
+	idempotency        *idempotencyGuard
+	timeout            *requestTimeout
+	deadline           *deadlineHeader
+	sse                *sseStreams
+	conditional        *conditionalResponses
+	experiment         *stickyExperiment
+	traceContext       *traceContext
+	encryptor          *metadataEncryptor
+	propagationSigner  *propagationSigner
+	internalNamespaces *headerTrie[struct{}]
+	trustedProxies     ipList
+	// stores lists the in-memory stores reported by Footprint
+	stores []footprinter
+	// gatewayMarks holds the gatewayCheckedKey markers awaiting consumption
+	gatewayMarks gatewayMarks
 }
 
 // Logger interface for logging (can be implemented by any logger)
@@ -89,66 +246,359 @@ func NewHeaderMapper(config *Config) *HeaderMapper {
 		config = &Config{}
 	}
 
-	skipPaths := make(map[string]bool)
-	for _, path := range config.SkipPaths {
-		skipPaths[path] = true
+	hm := &HeaderMapper{
+		reservedKeys: map[string]bool{gatewayCheckedKey: true},
 	}
+	hm.SetLogger(NoOpLogger{})
 
-	return &HeaderMapper{
-		config:    config,
-		skipPaths: skipPaths,
-		logger:    NoOpLogger{},
+	if len(config.InternalNamespaces) > 0 {
+		hm.internalNamespaces = &headerTrie[struct{}]{}
+		for _, prefix := range config.InternalNamespaces {
+			hm.internalNamespaces.insert(prefix, struct{}{})
+		}
+	}
+
+	hm.active.Store(hm.compile(config))
+
+	// Invalid entries are reported by Validate
+	hm.trustedProxies, _ = parseCIDRs(config.TrustedProxies)
+
+	if config.FIPSMode {
+		if err := validateFIPS(config); err != nil {
+			violation := &fipsViolation{err: err}
+			hm.requestChecks = append(hm.requestChecks, violation.check)
+			hm.callChecks = append(hm.callChecks, violation.checkCall)
+		}
 	}
+
+	if config.DuplicateHeaders == DuplicateHeaderReject {
+		hm.requestChecks = append(hm.requestChecks, hm.duplicateHeaderCheck)
+	}
+
+	if config.StrictMode {
+		hm.requestChecks = append(hm.requestChecks, hm.strictRequiredCheck)
+		hm.callChecks = append(hm.callChecks, hm.strictRequiredCall)
+		hm.strict = true
+	}
+
+	if config.MetadataLimit != nil {
+		hm.metadataLimit = &metadataLimit{config: config.MetadataLimit, hm: hm}
+		if !config.MetadataLimit.Truncate {
+			hm.requestChecks = append(hm.requestChecks, hm.metadataLimit.check)
+		}
+	}
+
+	if config.Encryption != nil {
+		// Decryption runs before any other hook inspects the metadata
+		hm.encryptor = &metadataEncryptor{config: config.Encryption, hm: hm}
+		hm.incomingProcessors = append(hm.incomingProcessors, hm.encryptor.processIncoming)
+	}
+
+	if config.PropagationSigning != nil {
+		hm.propagationSigner = newPropagationSigner(config.PropagationSigning)
+		hm.incomingProcessors = append(hm.incomingProcessors, hm.propagationSigner.processIncoming)
+		hm.reservedKeys[HeadersSignatureKey] = true
+	}
+
+	if config.Signature != nil {
+		hm.requestChecks = append(hm.requestChecks, newSignatureVerifier(config.Signature).check)
+	}
+
+	if config.ReplayGuard != nil {
+		guard := newReplayGuard(config.ReplayGuard)
+		if store, ok := guard.store.(footprinter); ok {
+			hm.stores = append(hm.stores, store)
+		}
+		hm.requestChecks = append(hm.requestChecks, guard.check)
+		hm.callChecks = append(hm.callChecks, unlessGatewayChecked(replayCheck, guard.checkCall))
+	}
+
+	if config.SharedSecret != nil {
+		secret := newSharedSecret(config.SharedSecret)
+		hm.requestChecks = append(hm.requestChecks, secret.check)
+		hm.callChecks = append(hm.callChecks, secret.checkCall)
+	}
+
+	if config.SPIFFE != nil {
+		spiffe := newSPIFFEIdentity(config.SPIFFE)
+		hm.requestChecks = append(hm.requestChecks, spiffe.check)
+		hm.annotators = append(hm.annotators, spiffe.annotate)
+		hm.incomingProcessors = append(hm.incomingProcessors, spiffe.processIncoming)
+		hm.reservedKeys[spiffe.key] = true
+	}
+
+	if config.JWTClaims != nil {
+		claims := newJWTClaims(config.JWTClaims, hm)
+		if config.JWTClaims.OnFailure == JWTFailureReject {
+			hm.requestChecks = append(hm.requestChecks, claims.check)
+		}
+		hm.annotators = append(hm.annotators, claims.annotate)
+		// Claim keys are only set from the token, never forwarded from
+		// client headers
+		for _, c := range claims.claims {
+			hm.reservedKeys[c.key] = true
+		}
+	}
+
+	if config.BasicAuth != nil {
+		basic := newBasicAuth(config.BasicAuth, hm)
+		hm.annotators = append(hm.annotators, basic.annotate)
+		hm.reservedKeys[basic.usernameKey] = true
+		if basic.passwordKey != "" {
+			hm.reservedKeys[basic.passwordKey] = true
+		}
+	}
+
+	if len(config.Composites) > 0 {
+		composites := newComposites(config.Composites, hm)
+		if composites.hasRejects() {
+			hm.requestChecks = append(hm.requestChecks, composites.check)
+		}
+		hm.annotators = append(hm.annotators, composites.annotate)
+		for _, mapping := range composites.mappings {
+			hm.reservedKeys[mapping.key] = true
+		}
+	}
+
+	if config.IPFilter != nil {
+		filter := newIPFilter(config.IPFilter, hm.ClientIP)
+		hm.requestChecks = append(hm.requestChecks, filter.check)
+		hm.annotators = append(hm.annotators, filter.annotate)
+		hm.callChecks = append(hm.callChecks, filter.checkCall)
+		hm.reservedKeys[filter.ipKey] = true
+		hm.reservedKeys[filter.decisionKey] = true
+	}
+
+	if config.CSRF != nil {
+		hm.requestChecks = append(hm.requestChecks, csrfCheck(config.CSRF))
+	}
+
+	if config.Authorization != nil {
+		authz := newAuthorizer(config.Authorization)
+		hm.requestChecks = append(hm.requestChecks, func(w http.ResponseWriter, req *http.Request) error {
+			return authz.authorize(req.URL.Path, req.Method, hm.annotateOnce(hm.state(), req))
+		})
+		hm.callChecks = append(hm.callChecks, func(ctx context.Context, fullMethod string, md metadata.MD) error {
+			return authz.authorize(fullMethod, "", md)
+		})
+	}
+
+	if config.RateLimit != nil {
+		limiter := newRateLimiter(config.RateLimit, hm)
+		if store, ok := limiter.store.(footprinter); ok {
+			hm.stores = append(hm.stores, store)
+		}
+		hm.requestChecks = append(hm.requestChecks, limiter.check)
+		hm.callChecks = append(hm.callChecks, unlessGatewayChecked(rateLimitCheck, limiter.checkCall))
+	}
+
+	if config.Audit != nil {
+		hm.auditor = newAuditor(config.Audit, hm)
+	}
+
+	if config.Timeout != nil {
+		hm.timeout = newRequestTimeout(config.Timeout)
+	}
+	if config.DeadlineHeader != "" {
+		hm.deadline = &deadlineHeader{header: http.CanonicalHeaderKey(config.DeadlineHeader), timeout: hm.outgoingTimeout()}
+	}
+
+	if config.SSE != nil {
+		hm.sse = newSSEStreams(config.SSE, hm)
+	}
+
+	if config.ETag != nil {
+		hm.conditional = newConditionalResponses(config.ETag)
+	}
+
+	if config.TraceContext != nil {
+		hm.traceContext = newTraceContext(config.TraceContext)
+	}
+
+	if config.Experiment != nil {
+		hm.experiment = newStickyExperiment(config.Experiment, hm)
+		hm.annotators = append(hm.annotators, hm.experiment.annotate)
+		if store, ok := config.Experiment.Store.(footprinter); ok {
+			hm.stores = append(hm.stores, store)
+		}
+	}
+
+	if config.Idempotency != nil {
+		hm.idempotency = newIdempotencyGuard(config.Idempotency, hm)
+		if store, ok := hm.idempotency.store.(footprinter); ok {
+			hm.stores = append(hm.stores, store)
+		}
+	}
+
+	return hm
 }
 
-// SetLogger sets a custom logger
+// SetLogger sets a custom logger; it is safe to call while requests are
+// being mapped
 func (hm *HeaderMapper) SetLogger(logger Logger) {
-	hm.logger = logger
+	hm.logger.Store(&logger)
+}
+
+// log returns the current logger
+func (hm *HeaderMapper) log() Logger {
+	return *hm.logger.Load()
 }
 
 // MetadataAnnotator creates a metadata annotator for incoming requests
 func (hm *HeaderMapper) MetadataAnnotator() func(context.Context, *http.Request) metadata.MD {
+	return hm.metadataAnnotator(true)
+}
+
+// UpstreamAnnotator creates a metadata annotator for requests forwarded over
+// HTTP, such as by a proxy or Envoy, rather than by the gateway to a gRPC
+// server. It leaves out the metadata only a gRPC server sharing the mapper
+// reads, which must not reach other upstreams.
+func (hm *HeaderMapper) UpstreamAnnotator() func(context.Context, *http.Request) metadata.MD {
+	return hm.metadataAnnotator(false)
+}
+
+// metadataAnnotator creates the annotator of MetadataAnnotator; gateway is
+// set when the metadata reaches a gRPC server sharing the mapper
+func (hm *HeaderMapper) metadataAnnotator(gateway bool) func(context.Context, *http.Request) metadata.MD {
 	return func(ctx context.Context, req *http.Request) metadata.MD {
-		if hm.skipPaths[req.URL.Path] {
-			return metadata.New(map[string]string{})
+		cc, release := hm.requestState(ctx)
+		defer release()
+
+		// gRPC-Gateway joins the result with other metadata, so nil is safe
+		if cc.skipPaths[req.URL.Path] {
+			return nil
 		}
 
-		md := metadata.New(map[string]string{})
+		// Requests without mapped headers, such as health probes, need no
+		// metadata unless hooks add their own or Handler enforced checks
+		echo := cc.config.DebugEchoHeader && debugRequested(req)
+		if !echo && !hm.hasAnnotateHooks() && cc.index.mapsNothing(req) && !(gateway && anyChecked(req.Context())) {
+			return nil
+		}
+
+		var traceCtx context.Context
+		if hm.traceContext != nil {
+			var end func()
+			traceCtx, end = hm.traceContext.start(req)
+			defer end()
+		}
 
-		for _, mapping := range hm.config.Mappings {
-			if mapping.Direction == Outgoing {
-				continue
+		// Path parameters are matched against the path pattern the gateway
+		// only stores in the annotation context; otherwise the metadata the
+		// checks of Handler mapped is reused
+		var md metadata.MD
+		if len(cc.index.incomingParams) > 0 {
+			annotated := req
+			if _, ok := runtime.HTTPPathPattern(ctx); ok {
+				annotated = req.WithContext(ctx)
 			}
+			md = hm.annotate(cc, annotated)
+		} else {
+			md = hm.annotateOwned(cc, req)
+		}
+		cc.index.stripParams(req)
+		if traceCtx != nil {
+			hm.traceContext.inject(traceCtx, md)
+		}
+		if echo {
+			md[debugMappedKey] = []string{cc.index.appliedIncoming(md)}
+		}
+
+		if hm.propagationSigner != nil {
+			hm.propagationSigner.sign(md)
+		}
+
+		if hm.encryptor != nil {
+			hm.encryptor.encrypt(req.Context(), md)
+		}
 
-			hm.mapIncomingHeader(req, md, mapping)
+		if hm.auditor != nil {
+			hm.auditor.record(req.Context(), "http", req.URL.Path, md, nil)
 		}
 
-		if hm.config.Debug {
-			hm.logger.Debug("Mapped incoming headers:", md)
+		if cc.config.Debug {
+			hm.log().Debug("Mapped incoming headers:", md)
+		}
+
+		// Added last so the marker is neither audited nor logged
+		if gateway {
+			hm.setGatewayChecked(req.Context(), md)
 		}
 
 		return md
 	}
 }
 
+// annotate maps the incoming headers of a request to gRPC metadata
+func (hm *HeaderMapper) annotate(cc *compiledConfig, req *http.Request) metadata.MD {
+	md := make(metadata.MD, cc.index.incomingCapacity(req))
+
+	hm.mapIncoming(cc, req, md)
+
+	for _, annotate := range hm.annotators {
+		annotate(req, md)
+	}
+
+	if hm.metadataLimit != nil && hm.metadataLimit.config.Truncate {
+		hm.metadataLimit.truncate(md)
+	}
+
+	return md
+}
+
+// hasAnnotateHooks reports whether incoming metadata is extended, traced,
+// signed, encrypted or audited beyond the mappings
+func (hm *HeaderMapper) hasAnnotateHooks() bool {
+	return len(hm.annotators) > 0 || hm.traceContext != nil || hm.propagationSigner != nil || hm.encryptor != nil || hm.auditor != nil
+}
+
 // ResponseModifier creates a response modifier for outgoing responses
 func (hm *HeaderMapper) ResponseModifier() func(context.Context, http.ResponseWriter, proto.Message) error {
 	return func(ctx context.Context, w http.ResponseWriter, msg proto.Message) error {
-		md, ok := runtime.ServerMetadataFromContext(ctx)
-		if !ok {
+		// Headers of a stream are mapped once before its first message
+		if streamMessage(w, msg) {
 			return nil
 		}
 
-		for _, mapping := range hm.config.Mappings {
-			if mapping.Direction == Incoming {
-				continue
+		// Remove internal and blocked headers the gateway forwarded from
+		// backend metadata
+		hm.stripInternalHeaders(w.Header())
+		cc, release := hm.requestState(ctx)
+		defer release()
+		cc.index.blocked.strip(w.Header())
+		if cc.config.StatusMetadataKey != "" {
+			w.Header().Del(runtime.MetadataHeaderPrefix + cc.config.StatusMetadataKey)
+		}
+		md, ok := runtime.ServerMetadataFromContext(ctx)
+		headerMD := md.HeaderMD
+
+		// Metadata produced at the gateway, e.g. by rate limiting, fills in
+		// values the backend did not send
+		if gatewayMD, found := responseMetadataFromContext(ctx); found {
+			headerMD = metadata.Join(headerMD, gatewayMD)
+		} else if !ok {
+			if cc.config.DebugEchoHeader {
+				echoMappings(ctx, cc, nil, w)
 			}
+			return nil
+		}
 
-			hm.mapOutgoingHeader(md.HeaderMD, w, mapping)
+		hm.applyResponse(cc, headerMD, md.TrailerMD, w, trailersAccepted(ctx))
+		if cc.config.DebugEchoHeader {
+			echoMappings(ctx, cc, headerMD, w)
+		}
+		if hm.conditional != nil {
+			hm.conditional.apply(ctx, w, headerMD, msg)
+		}
+		if hm.deadline != nil {
+			hm.deadline.expose(ctx, w)
+		}
+		if hm.sse != nil && msg == nil && sseStreamFromContext(ctx) != nil {
+			hm.sse.start(w, headerMD)
 		}
 
-		if hm.config.Debug {
-			hm.logger.Debug("Mapped outgoing headers to response")
+		if cc.config.Debug {
+			hm.log().Debug("Mapped outgoing headers to response")
 		}
 
 		return nil
@@ -157,26 +607,31 @@ func (hm *HeaderMapper) ResponseModifier() func(context.Context, http.ResponseWr
 
 // HeaderMatcher creates a header matcher for grpc-gateway
 func (hm *HeaderMapper) HeaderMatcher() func(string) (string, bool) {
-	// Create a map for quick lookup
-	headerMap := make(map[string]string)
-	for _, mapping := range hm.config.Mappings {
-		if mapping.Direction != Outgoing {
-			key := mapping.HTTPHeader
-			if !hm.config.CaseSensitive {
-				key = strings.ToLower(key)
-			}
-			headerMap[key] = mapping.GRPCMetadata
-		}
-	}
-
+	// The state is loaded per call so configuration updates reach matchers
+	// already registered with the gateway
 	return func(key string) (string, bool) {
-		searchKey := key
-		if !hm.config.CaseSensitive {
-			searchKey = strings.ToLower(key)
+		cc := hm.state()
+		headerMap := cc.index.matcher
+
+		if hm.internalHeader(key) || cc.index.blocked.blocks(key) {
+			return "", false
 		}
 
-		if grpcKey, exists := headerMap[searchKey]; exists {
-			return grpcKey, true
+		grpcKey, exists := headerMap[key]
+		if !exists && !cc.config.CaseSensitive {
+			grpcKey, exists = lookupFold(headerMap, key)
+		}
+		if !exists {
+			_, grpcKey, exists = cc.index.matchPattern(key)
+		}
+		if !exists {
+			grpcKey, exists = cc.index.matchPrefix(key)
+		}
+		if exists {
+			return grpcKey, !hm.reservedKeys[grpcKey]
+		}
+		if cc.config.StrictAllowList {
+			return "", false
 		}
 
 		// Fallback to default behavior
@@ -185,125 +640,274 @@ func (hm *HeaderMapper) HeaderMatcher() func(string) (string, bool) {
 			// Manual fallback - convert to grpc-metadata format
 			defaultKey = "grpc-metadata-" + strings.ToLower(strings.ReplaceAll(key, "_", "-"))
 		}
+		// Reserved keys are only set by the mapper itself
+		if reserved, _ := lookupFold(hm.reservedKeys, defaultKey); reserved {
+			return "", false
+		}
 		return defaultKey, true
 	}
 }
 
+// OutgoingHeaderMatcher creates an outgoing header matcher for grpc-gateway
+// that forwards backend metadata as Grpc-Metadata- headers like the default,
+// except for blocked keys and internal namespaces. With StrictAllowList only
+// the outgoing mappings write response headers.
+func (hm *HeaderMapper) OutgoingHeaderMatcher() func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		cc := hm.state()
+		if cc.config.StrictAllowList || hm.internalHeader(key) || cc.index.blocked.blocks(key) || cc.statusKey(key) {
+			return "", false
+		}
+		return runtime.MetadataHeaderPrefix + key, true
+	}
+}
+
 // UnaryServerInterceptor creates a gRPC unary server interceptor
-func (hm *HeaderMapper) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+func (hm *HeaderMapper) UnaryServerInterceptor(opts ...InterceptorOption) grpc.UnaryServerInterceptor {
+	o := hm.interceptorOptions(opts)
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-		if hm.skipPaths[info.FullMethod] {
+		cc := hm.state()
+		if cc.skipPaths[info.FullMethod] {
 			return handler(ctx, req)
 		}
 
 		// Process metadata
-		newCtx := hm.processIncomingMetadata(ctx)
+		newCtx, err := hm.processIncomingMetadata(ctx, info.FullMethod, o.validate)
+		if err != nil {
+			return nil, err
+		}
+		if hm.timeout != nil {
+			var cancel context.CancelFunc
+			newCtx, cancel = hm.timeout.applyCall(newCtx)
+			defer cancel()
+		}
+
+		if o.outgoing && len(cc.index.outgoing) > 0 {
+			headers := newServerHeaders(cc)
+			var stream grpc.ServerTransportStream
+			newCtx, stream = withServerHeaders(newCtx, headers)
+			// The response and its header are sent once the interceptor returns
+			if stream != nil {
+				defer headers.finish(stream.SetHeader)
+			}
+		}
 
 		return handler(newCtx, req)
 	}
 }
 
 // StreamServerInterceptor creates a gRPC stream server interceptor
-func (hm *HeaderMapper) StreamServerInterceptor() grpc.StreamServerInterceptor {
+func (hm *HeaderMapper) StreamServerInterceptor(opts ...InterceptorOption) grpc.StreamServerInterceptor {
+	o := hm.interceptorOptions(opts)
 	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
-		if hm.skipPaths[info.FullMethod] {
+		cc := hm.state()
+		if cc.skipPaths[info.FullMethod] {
 			return handler(srv, ss)
 		}
 
 		// Wrap the server stream to process metadata
-		wrappedStream := &wrappedServerStream{
-			ServerStream: ss,
-			ctx:          hm.processIncomingMetadata(ss.Context()),
+		ctx, err := hm.processIncomingMetadata(ss.Context(), info.FullMethod, o.validate)
+		if err != nil {
+			return err
+		}
+		if hm.timeout != nil {
+			var cancel context.CancelFunc
+			ctx, cancel = hm.timeout.applyCall(ctx)
+			defer cancel()
+		}
+		var stream grpc.ServerStream = ss
+		if ctx != ss.Context() {
+			stream = &wrappedServerStream{
+				ServerStream: ss,
+				ctx:          ctx,
+			}
+		}
+		var headers *serverHeaders
+		if o.outgoing && len(cc.index.outgoing) > 0 {
+			headers = newServerHeaders(cc)
+			ctx, _ = withServerHeaders(ctx, headers)
+			stream = &headerServerStream{ServerStream: ss, ctx: ctx, headers: headers}
+		}
+		if o.streamHooks == nil && headers == nil {
+			return handler(srv, stream)
 		}
 
-		return handler(srv, wrappedStream)
+		var hooked *hookedServerStream
+		if o.streamHooks != nil {
+			hooked = newHookedServerStream(stream, info.FullMethod, o.streamHooks)
+			stream = hooked
+		}
+		err = handler(srv, stream)
+		if hooked != nil {
+			hooked.finish(err)
+		}
+		if headers != nil {
+			headers.finish(ss.SetHeader)
+		}
+		return err
 	}
 }
 
-// mapIncomingHeader maps a single incoming HTTP header to gRPC metadata
-func (hm *HeaderMapper) mapIncomingHeader(req *http.Request, md metadata.MD, mapping HeaderMapping) {
-	headerValue := req.Header.Get(mapping.HTTPHeader)
+// mapIncomingHeader maps the value of an incoming HTTP header to gRPC metadata
+func (hm *HeaderMapper) mapIncomingHeader(cc *compiledConfig, md metadata.MD, mapping *compiledMapping, headerValue string, backing *[]string) {
+	if value, ok := hm.incomingValue(mapping, headerValue); ok {
+		hm.setIncoming(cc, md, mapping, value, backing)
+	}
+}
 
-	if headerValue == "" && mapping.DefaultValue != "" {
-		headerValue = mapping.DefaultValue
+// incomingValue computes the metadata value of a single incoming mapping,
+// reporting false when the mapping produces no value
+func (hm *HeaderMapper) incomingValue(mapping *compiledMapping, headerValue string) (string, bool) {
+	if headerValue == "" {
+		headerValue = mapping.generate()
+	}
+	if headerValue == "" && mapping.defaultValue != "" {
+		headerValue = mapping.defaultValue
 	}
 
-	if headerValue == "" && mapping.Required {
-		hm.logger.Warn("Required header missing:", mapping.HTTPHeader)
-		return
+	if headerValue == "" && mapping.required {
+		mapping.counter.missing.Add(1)
+		hm.log().Warn("Required header missing:", mapping.header)
+		return "", false
 	}
 
 	if headerValue == "" {
-		return
+		return "", false
 	}
 
-	// Apply transformation if provided
-	if mapping.Transform != nil {
-		headerValue = mapping.Transform(headerValue)
+	// Apply transformation if provided; transforms reject a value by
+	// returning an empty string
+	if mapping.transform != nil {
+		headerValue = mapping.transform(headerValue)
 	}
+	if mapping.binary != "" && headerValue != "" {
+		value, err := mapping.binary.decode(headerValue)
+		if err != nil {
+			hm.log().Warn("Invalid binary header value:", mapping.header, err)
+			return "", false
+		}
+		headerValue = value
+	}
+	return headerValue, headerValue != ""
+}
 
+// setIncoming stores the value of an incoming mapping in md. Values are
+// carved from the shared backing slice so each entry does not allocate its
+// own slice.
+func (hm *HeaderMapper) setIncoming(cc *compiledConfig, md metadata.MD, mapping *compiledMapping, headerValue string, backing *[]string) {
+	existing := md[mapping.key]
+	if mapping.appendValues && len(existing) > 0 {
+		// Capping the capacity makes append copy rather than overwrite the
+		// shared backing slice
+		md[mapping.key] = append(existing[:len(existing):len(existing)], headerValue)
+		mapping.counter.applied.Add(1)
+		return
+	}
 	// Check if we should overwrite existing metadata
-	if !hm.config.OverwriteExisting && len(md.Get(mapping.GRPCMetadata)) > 0 {
+	if !cc.config.OverwriteExisting && len(existing) > 0 {
 		return
 	}
 
-	md.Set(mapping.GRPCMetadata, headerValue)
+	*backing = append(*backing, headerValue)
+	n := len(*backing)
+	md[mapping.key] = (*backing)[n-1 : n : n]
+	mapping.counter.applied.Add(1)
 }
 
-// mapOutgoingHeader maps a single outgoing gRPC metadata to HTTP header
-func (hm *HeaderMapper) mapOutgoingHeader(md metadata.MD, w http.ResponseWriter, mapping HeaderMapping) {
-	values := md.Get(mapping.GRPCMetadata)
-	if len(values) == 0 {
-		if mapping.DefaultValue != "" {
-			values = []string{mapping.DefaultValue}
-		} else if mapping.Required {
-			hm.logger.Warn("Required metadata missing:", mapping.GRPCMetadata)
-			return
-		} else {
-			return
-		}
+// mapOutgoingHeader computes the response header value of a single outgoing
+// mapping from the header or trailer metadata it reads, reporting whether
+// the value came from trailers and false when the mapping produces no header
+func (hm *HeaderMapper) mapOutgoingHeader(md, trailers metadata.MD, mapping *compiledMapping) (value string, trailer bool, ok bool) {
+	if mapping.internal {
+		return "", false, false
 	}
 
-	headerValue := values[0] // Use first value
+	var values []string
+	if mapping.fromHeader {
+		values = md[mapping.key]
+	}
+	if len(values) == 0 && mapping.fromTrailer {
+		values = trailers[mapping.key]
+		trailer = len(values) > 0
+	}
 
-	// Apply transformation if provided
-	if mapping.Transform != nil {
-		headerValue = mapping.Transform(headerValue)
+	var headerValue string
+	if len(values) > 0 {
+		headerValue = values[0] // Use first value
+		if mapping.binary != "" {
+			headerValue = mapping.binary.encode(headerValue)
+		}
+	} else if generated := mapping.generate(); generated != "" {
+		headerValue = generated
+	} else if mapping.defaultValue != "" {
+		headerValue = mapping.defaultValue
+	} else {
+		if mapping.required {
+			mapping.counter.missing.Add(1)
+			hm.log().Warn("Required metadata missing:", mapping.key)
+		}
+		return "", false, false
 	}
 
-	// Check if we should overwrite existing headers
-	if !hm.config.OverwriteExisting && w.Header().Get(mapping.HTTPHeader) != "" {
-		return
+	// Apply transformation if provided
+	if mapping.transform != nil {
+		headerValue = mapping.transform(headerValue)
 	}
 
-	w.Header().Set(mapping.HTTPHeader, headerValue)
+	mapping.counter.applied.Add(1)
+	return headerValue, trailer, true
 }
 
-// processIncomingMetadata processes incoming metadata based on mappings
-func (hm *HeaderMapper) processIncomingMetadata(ctx context.Context) context.Context {
+// processIncomingMetadata runs the incoming hooks on the metadata of a call.
+// Mappings are applied by MetadataAnnotator at the gateway, so when no hook
+// can modify the metadata the original context is returned unchanged. With
+// validate set, calls missing required metadata are rejected after the hooks.
+func (hm *HeaderMapper) processIncomingMetadata(ctx context.Context, fullMethod string, validate bool) (context.Context, error) {
+	if len(hm.incomingProcessors) == 0 && len(hm.callChecks) == 0 && hm.auditor == nil && !validate {
+		return ctx, nil
+	}
+
+	// FromIncomingContext returns a copy, so hooks may rewrite or remove
+	// entries without affecting the caller's metadata
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
-		return ctx
+		md = metadata.MD{}
 	}
 
-	newMD := metadata.New(map[string]string{})
-
-	// Copy existing metadata
-	for k, v := range md {
-		newMD.Set(k, v...)
+	err := hm.runIncomingHooks(ctx, fullMethod, md)
+	if err == nil && validate {
+		err = hm.strictRequiredCall(ctx, fullMethod, md)
 	}
+	if hm.auditor != nil {
+		hm.auditor.record(ctx, "grpc", fullMethod, md, err)
+	}
+	if err != nil {
+		return ctx, err
+	}
+	if len(hm.incomingProcessors) == 0 && len(hm.callChecks) == 0 {
+		return ctx, nil
+	}
+
+	return metadata.NewIncomingContext(ctx, md), nil
+}
+
+// runIncomingHooks applies the incoming processors and call checks to md
+func (hm *HeaderMapper) runIncomingHooks(ctx context.Context, fullMethod string, md metadata.MD) error {
+	ctx = hm.gatewayChecked(ctx, md)
 
-	// Apply mappings that might transform metadata keys/values
-	for _, mapping := range hm.config.Mappings {
-		if mapping.Direction == Outgoing {
-			continue
+	for _, process := range hm.incomingProcessors {
+		if err := process(ctx, md); err != nil {
+			return err
 		}
+	}
 
-		// This could include additional processing logic
-		// For now, metadata is already processed by MetadataAnnotator
+	for _, check := range hm.callChecks {
+		if err := check(ctx, fullMethod, md); err != nil {
+			return err
+		}
 	}
 
-	return metadata.NewIncomingContext(ctx, newMD)
+	return nil
 }
 
 // wrappedServerStream wraps a grpc.ServerStream to provide custom context
@@ -386,6 +990,12 @@ func (b *Builder) AddMapping(httpHeader, grpcMetadata string, direction MappingD
 	return b
 }
 
+// AddMappings adds predefined header mappings such as CommonMappings()
+func (b *Builder) AddMappings(mappings ...HeaderMapping) *Builder {
+	b.config.Mappings = append(b.config.Mappings, mappings...)
+	return b
+}
+
 // AddIncomingMapping adds an incoming header mapping (HTTP -> gRPC)
 func (b *Builder) AddIncomingMapping(httpHeader, grpcMetadata string) *Builder {
 	return b.AddMapping(httpHeader, grpcMetadata, Incoming)
@@ -396,6 +1006,54 @@ func (b *Builder) AddOutgoingMapping(grpcMetadata, httpHeader string) *Builder {
 	return b.AddMapping(httpHeader, grpcMetadata, Outgoing)
 }
 
+// AddIncomingPatternMapping forwards every HTTP header whose whole name
+// matches pattern to gRPC metadata, deriving the key from keyTemplate with
+// the capture groups, e.g. `X-(Tenant|Org)-(.*)` and "$1-$2"
+func (b *Builder) AddIncomingPatternMapping(pattern, keyTemplate string) *Builder {
+	b.config.Mappings = append(b.config.Mappings, HeaderMapping{
+		HTTPHeaderPattern: pattern,
+		GRPCMetadata:      keyTemplate,
+		Direction:         Incoming,
+	})
+	return b
+}
+
+// AddQueryParamMapping forwards the URL query parameter param to gRPC
+// metadata, e.g. ?api_key= to api-key
+func (b *Builder) AddQueryParamMapping(param, grpcMetadata string) *Builder {
+	b.config.Mappings = append(b.config.Mappings, HeaderMapping{
+		QueryParam:   param,
+		GRPCMetadata: grpcMetadata,
+		Direction:    Incoming,
+	})
+	return b
+}
+
+// AddPathParamMapping forwards the grpc-gateway path parameter param, a
+// variable of the method's path template such as {tenant}, to gRPC metadata
+func (b *Builder) AddPathParamMapping(param, grpcMetadata string) *Builder {
+	b.config.Mappings = append(b.config.Mappings, HeaderMapping{
+		PathParam:    param,
+		GRPCMetadata: grpcMetadata,
+		Direction:    Incoming,
+	})
+	return b
+}
+
+// AddIncomingPrefixMapping forwards every HTTP header starting with
+// headerPrefix to gRPC metadata, replacing the prefix with keyPrefix. The
+// trailing "*" of the header pattern is optional, e.g. "X-Custom-*" and
+// "custom-" map X-Custom-Region to custom-region.
+func (b *Builder) AddIncomingPrefixMapping(headerPrefix, keyPrefix string) *Builder {
+	return b.AddMapping(prefixPattern(headerPrefix), keyPrefix, Incoming)
+}
+
+// AddOutgoingPrefixMapping maps every gRPC metadata key starting with
+// keyPrefix to a response header, replacing the prefix with headerPrefix
+func (b *Builder) AddOutgoingPrefixMapping(keyPrefix, headerPrefix string) *Builder {
+	return b.AddMapping(prefixPattern(headerPrefix), keyPrefix, Outgoing)
+}
+
 // AddBidirectionalMapping adds a bidirectional header mapping
 func (b *Builder) AddBidirectionalMapping(httpHeader, grpcMetadata string) *Builder {
 	return b.AddMapping(httpHeader, grpcMetadata, Bidirectional)
@@ -417,6 +1075,25 @@ func (b *Builder) WithRequired(required bool) *Builder {
 	return b
 }
 
+// WithCachedTransform sets a deterministic transformation function for the
+// last added mapping whose results are served from the shared transform cache
+func (b *Builder) WithCachedTransform(transform TransformFunc) *Builder {
+	if len(b.config.Mappings) > 0 {
+		b.config.Mappings[len(b.config.Mappings)-1].Transform = transform
+		b.config.Mappings[len(b.config.Mappings)-1].CacheTransform = true
+	}
+	return b
+}
+
+// WithGenerator sets a function producing a value for the last added mapping
+// on each request or response where it is missing
+func (b *Builder) WithGenerator(generator func() string) *Builder {
+	if len(b.config.Mappings) > 0 {
+		b.config.Mappings[len(b.config.Mappings)-1].Generator = generator
+	}
+	return b
+}
+
 // WithDefault sets a default value for the last added mapping
 func (b *Builder) WithDefault(defaultValue string) *Builder {
 	if len(b.config.Mappings) > 0 {
@@ -425,6 +1102,98 @@ func (b *Builder) WithDefault(defaultValue string) *Builder {
 	return b
 }
 
+// WithAliases sets legacy header names read by the last added mapping when
+// its header is missing
+func (b *Builder) WithAliases(aliases ...string) *Builder {
+	if len(b.config.Mappings) > 0 {
+		b.config.Mappings[len(b.config.Mappings)-1].Aliases = aliases
+	}
+	return b
+}
+
+// WithDeprecatedAliases sets legacy header names of the last added mapping
+// whose use is logged and counted
+func (b *Builder) WithDeprecatedAliases(aliases ...string) *Builder {
+	if len(b.config.Mappings) > 0 {
+		b.config.Mappings[len(b.config.Mappings)-1].Aliases = aliases
+		b.config.Mappings[len(b.config.Mappings)-1].Deprecated = true
+	}
+	return b
+}
+
+// WithAppendValues makes the last added mapping add its value to those of
+// earlier mappings of the same metadata key or header
+func (b *Builder) WithAppendValues(appendValues bool) *Builder {
+	if len(b.config.Mappings) > 0 {
+		b.config.Mappings[len(b.config.Mappings)-1].AppendValues = appendValues
+	}
+	return b
+}
+
+// WithNamedTransforms sets the registered transforms the last added mapping
+// chains, as a configuration file would
+func (b *Builder) WithNamedTransforms(names ...string) *Builder {
+	if len(b.config.Mappings) > 0 {
+		b.config.Mappings[len(b.config.Mappings)-1].TransformNames = names
+	}
+	return b
+}
+
+// WithStripParam removes the query parameter of the last added mapping from
+// the URL once mapped, so it is not decoded into the request message
+func (b *Builder) WithStripParam(strip bool) *Builder {
+	if len(b.config.Mappings) > 0 {
+		b.config.Mappings[len(b.config.Mappings)-1].StripParam = strip
+	}
+	return b
+}
+
+// WithSource selects the response metadata the last added mapping reads
+func (b *Builder) WithSource(source MetadataSource) *Builder {
+	if len(b.config.Mappings) > 0 {
+		b.config.Mappings[len(b.config.Mappings)-1].Source = source
+	}
+	return b
+}
+
+// WithBinaryEncoding sets the encoding of the header values of the last
+// added mapping, whose metadata key must end in -bin
+func (b *Builder) WithBinaryEncoding(encoding BinaryEncoding) *Builder {
+	if len(b.config.Mappings) > 0 {
+		b.config.Mappings[len(b.config.Mappings)-1].BinaryEncoding = encoding
+	}
+	return b
+}
+
+// BlockHeaders keeps headers from being forwarded to metadata, even by the
+// default matcher, and metadata keys from being written to response headers;
+// names ending in "*" block a prefix
+//
+//	NewBuilder().BlockHeaders("Cookie", "X-Internal-*")
+func (b *Builder) BlockHeaders(names ...string) *Builder {
+	b.config.BlockedHeaders = append(b.config.BlockedHeaders, names...)
+	return b
+}
+
+// StrictAllowList only lets headers read by a mapping reach the metadata,
+// disabling the Grpc-Metadata- fallback of HeaderMatcher
+func (b *Builder) StrictAllowList(strict bool) *Builder {
+	b.config.StrictAllowList = strict
+	return b
+}
+
+// MapHTTPStatus lets backends set the HTTP status of successful responses
+// through the metadata key, HTTPStatusKey when empty
+//
+//	grpc.SetHeader(ctx, metadata.Pairs("http-status", "201"))
+func (b *Builder) MapHTTPStatus(key string) *Builder {
+	if key == "" {
+		key = HTTPStatusKey
+	}
+	b.config.StatusMetadataKey = key
+	return b
+}
+
 // SkipPaths sets paths to skip header mapping
 func (b *Builder) SkipPaths(paths ...string) *Builder {
 	b.config.SkipPaths = paths
@@ -449,11 +1218,251 @@ func (b *Builder) Debug(debug bool) *Builder {
 	return b
 }
 
+// DebugEchoHeader lists the applied mappings in responses to clients
+// requesting it; see Config.DebugEchoHeader
+func (b *Builder) DebugEchoHeader(enabled bool) *Builder {
+	b.config.DebugEchoHeader = enabled
+	return b
+}
+
+// FIPSMode restricts cryptography to FIPS-approved algorithms. A mapper whose
+// configuration violates FIPS mode rejects all traffic; use BuildAndValidate
+// to detect this at startup.
+func (b *Builder) FIPSMode(enabled bool) *Builder {
+	b.config.FIPSMode = enabled
+	return b
+}
+
+// StrictMode rejects requests missing required headers with HTTP 400 from
+// Handler and calls missing their metadata with InvalidArgument from the
+// server interceptors, listing every missing header
+func (b *Builder) StrictMode(strict bool) *Builder {
+	b.config.StrictMode = strict
+	return b
+}
+
+// DuplicateHeaders sets the policy for headers repeated with conflicting values
+func (b *Builder) DuplicateHeaders(policy DuplicateHeaderPolicy) *Builder {
+	b.config.DuplicateHeaders = policy
+	return b
+}
+
+// OutgoingOrder sets the order in which outgoing mappings are applied
+func (b *Builder) OutgoingOrder(order HeaderOrder) *Builder {
+	b.config.OutgoingOrder = order
+	return b
+}
+
+// TrustedProxies sets the proxies whose forwarded headers are honored
+func (b *Builder) TrustedProxies(cidrs ...string) *Builder {
+	b.config.TrustedProxies = append(b.config.TrustedProxies, cidrs...)
+	return b
+}
+
+// InternalNamespaces declares header prefixes removed from external traffic
+func (b *Builder) InternalNamespaces(prefixes ...string) *Builder {
+	b.config.InternalNamespaces = append(b.config.InternalNamespaces, prefixes...)
+	return b
+}
+
+// VerifySignatures enables request signature verification
+func (b *Builder) VerifySignatures(config *SignatureConfig) *Builder {
+	b.config.Signature = config
+	return b
+}
+
+// MapJWTClaims maps the claims of the bearer token of requests into metadata
+//
+//	NewBuilder().MapJWTClaims(ExtractJWTClaims(map[string]string{"sub": "user-id", "tenant": "tenant-id"}))
+func (b *Builder) MapJWTClaims(config *JWTClaimsConfig) *Builder {
+	b.config.JWTClaims = config
+	return b
+}
+
+// MapBasicAuth maps the username and password of Basic credentials into metadata
+//
+//	NewBuilder().MapBasicAuth(DecodeBasicAuth("user-id"))
+func (b *Builder) MapBasicAuth(config *BasicAuthConfig) *Builder {
+	b.config.BasicAuth = config
+	return b
+}
+
+// AddComposite adds a mapping joining several incoming headers into one
+// metadata value
+//
+//	NewBuilder().AddComposite(CompositeMapping{Template: "{X-Tenant-ID}/{X-Region}", GRPCMetadata: "tenant-route"})
+func (b *Builder) AddComposite(mapping CompositeMapping) *Builder {
+	b.config.Composites = append(b.config.Composites, mapping)
+	return b
+}
+
+// WithSPIFFEIdentity maps the client's mTLS SPIFFE ID into metadata
+func (b *Builder) WithSPIFFEIdentity(config *SPIFFEConfig) *Builder {
+	b.config.SPIFFE = config
+	return b
+}
+
+// Authorize sets the access rules enforced on mapped metadata
+func (b *Builder) Authorize(config *AuthorizationConfig) *Builder {
+	b.config.Authorization = config
+	return b
+}
+
+// RateLimit limits requests per mapped metadata value
+func (b *Builder) RateLimit(config *RateLimitConfig) *Builder {
+	b.config.RateLimit = config
+	return b
+}
+
+// FilterIPs rejects requests by client IP
+func (b *Builder) FilterIPs(config *IPFilterConfig) *Builder {
+	b.config.IPFilter = config
+	return b
+}
+
+// ValidateCSRF enables double-submit CSRF token validation
+func (b *Builder) ValidateCSRF(config *CSRFConfig) *Builder {
+	b.config.CSRF = config
+	return b
+}
+
+// GuardReplays rejects requests with reused nonces or stale timestamps
+func (b *Builder) GuardReplays(config *ReplayGuardConfig) *Builder {
+	b.config.ReplayGuard = config
+	return b
+}
+
+// EnableCORS handles cross-origin requests for the mapped headers
+func (b *Builder) EnableCORS(config *CORSConfig) *Builder {
+	b.config.CORS = config
+	return b
+}
+
+// LimitMetadata caps the metadata size contributed by mappings
+func (b *Builder) LimitMetadata(config *MetadataLimitConfig) *Builder {
+	b.config.MetadataLimit = config
+	return b
+}
+
+// Audit records the identity metadata of each request to a sink
+func (b *Builder) Audit(config *AuditConfig) *Builder {
+	b.config.Audit = config
+	return b
+}
+
+// EncryptMetadata encrypts selected metadata at the gateway and decrypts it
+// in the server interceptors
+func (b *Builder) EncryptMetadata(config *EncryptionConfig) *Builder {
+	b.config.Encryption = config
+	return b
+}
+
+// SignPropagation signs selected metadata at the gateway and verifies the
+// signature in the server interceptors
+func (b *Builder) SignPropagation(config *PropagationSigningConfig) *Builder {
+	b.config.PropagationSigning = config
+	return b
+}
+
+// RequireSharedSecret requires a shared-secret header, compared in constant time
+func (b *Builder) RequireSharedSecret(config *SharedSecretConfig) *Builder {
+	b.config.SharedSecret = config
+	return b
+}
+
+// TransformCache sizes the cache used by mappings added with WithCachedTransform
+func (b *Builder) TransformCache(size int, ttl time.Duration) *Builder {
+	b.config.TransformCache = &TransformCacheConfig{Size: size, TTL: ttl}
+	return b
+}
+
+// MappingBudget bounds the time spent mapping one request or response
+func (b *Builder) MappingBudget(budget time.Duration) *Builder {
+	b.config.MappingBudget = budget
+	return b
+}
+
+// ParallelMapping evaluates incoming mappings across workers goroutines per
+// request once there are at least minMappings of them; zero selects the defaults
+func (b *Builder) ParallelMapping(workers, minMappings int) *Builder {
+	b.config.ParallelMapping = &ParallelMappingConfig{Workers: workers, MinMappings: minMappings}
+	return b
+}
+
+// ApplyTimeouts applies the timeout requested in header, bounded by max, as
+// the request deadline; see TimeoutConfig
+func (b *Builder) ApplyTimeouts(header string, max time.Duration) *Builder {
+	timeout := b.timeoutConfig()
+	timeout.Header, timeout.Max = header, max
+	return b
+}
+
+// ExposeDeadline sets header, e.g. X-Deadline-Remaining, on responses to the
+// time left before the request deadline; see Config.DeadlineHeader
+func (b *Builder) ExposeDeadline(header string) *Builder {
+	b.config.DeadlineHeader = header
+	return b
+}
+
+// timeoutConfig returns a copy of the timeout configuration owned by the
+// builder, creating it when unset
+func (b *Builder) timeoutConfig() *TimeoutConfig {
+	timeout := &TimeoutConfig{}
+	if b.config.Timeout != nil {
+		*timeout = *b.config.Timeout
+	}
+	b.config.Timeout = timeout
+	return timeout
+}
+
+// ServerSentEvents delivers server streams as server-sent events; see SSEConfig
+func (b *Builder) ServerSentEvents(config *SSEConfig) *Builder {
+	b.config.SSE = config
+	return b
+}
+
+// ConditionalRequests computes ETags and answers conditional GET requests
+// with 304 Not Modified in Handler; see ETagConfig
+func (b *Builder) ConditionalRequests(config *ETagConfig) *Builder {
+	b.config.ETag = config
+	return b
+}
+
+// StickyExperiment assigns requests to experiment variants in Handler and
+// keeps clients on them; see StickyExperimentConfig
+func (b *Builder) StickyExperiment(config *StickyExperimentConfig) *Builder {
+	b.config.Experiment = config
+	return b
+}
+
+// PropagateTraceContext extracts the trace context of requests with an
+// OpenTelemetry propagator and injects it into the metadata; see
+// TraceContextConfig
+func (b *Builder) PropagateTraceContext(config *TraceContextConfig) *Builder {
+	b.config.TraceContext = config
+	return b
+}
+
+// DeduplicateRequests enables Idempotency-Key deduplication in Handler
+func (b *Builder) DeduplicateRequests(config *IdempotencyConfig) *Builder {
+	b.config.Idempotency = config
+	return b
+}
+
 // Build creates the HeaderMapper
 func (b *Builder) Build() *HeaderMapper {
 	return NewHeaderMapper(b.config)
 }
 
+// BuildAndValidate creates the HeaderMapper and validates its configuration
+func (b *Builder) BuildAndValidate() (*HeaderMapper, error) {
+	hm := NewHeaderMapper(b.config)
+	if err := hm.Validate(); err != nil {
+		return nil, err
+	}
+	return hm, nil
+}
+
 // Predefined common mappings
 
 // CommonMappings returns commonly used header mappings
@@ -547,12 +1556,19 @@ func CreateGatewayMux(mapper *HeaderMapper, opts ...runtime.ServeMuxOption) *run
 	// Prepend our options
 	allOpts := []runtime.ServeMuxOption{
 		runtime.WithIncomingHeaderMatcher(mapper.HeaderMatcher()),
+		runtime.WithOutgoingHeaderMatcher(mapper.OutgoingHeaderMatcher()),
 		runtime.WithMetadata(mapper.MetadataAnnotator()),
 		runtime.WithForwardResponseOption(mapper.ResponseModifier()),
+		runtime.WithErrorHandler(mapper.ErrorHandler(nil)),
+	}
+	if mapper.sse != nil {
+		allOpts = append(allOpts, runtime.WithMarshalerOption(EventStreamContentType, &SSEMarshaler{}))
 	}
 
-	// Add user-provided options
+	// Add user-provided options; the status is written after all of them
+	// have set their headers
 	allOpts = append(allOpts, opts...)
+	allOpts = append(allOpts, runtime.WithForwardResponseOption(mapper.StatusModifier()))
 
 	return runtime.NewServeMux(allOpts...)
 }
@@ -561,33 +1577,78 @@ func CreateGatewayMux(mapper *HeaderMapper, opts ...runtime.ServeMuxOption) *run
 
 // Validate validates the header mapper configuration
 func (hm *HeaderMapper) Validate() error {
-	if hm.config == nil {
+	cc := hm.state()
+	if cc == nil || cc.config == nil {
 		return fmt.Errorf("configuration is nil")
 	}
 
-	for i, mapping := range hm.config.Mappings {
-		if mapping.HTTPHeader == "" {
-			return fmt.Errorf("mapping %d: HTTPHeader cannot be empty", i)
+	for i, mapping := range cc.config.Mappings {
+		if mapping.HTTPHeader == "" && mapping.HTTPHeaderPattern == "" && !isParamMapping(mapping) {
+			return fmt.Errorf("mapping %d: %w", i, newMappingError(mapping, ErrValidationFailed, "HTTPHeader cannot be empty"))
 		}
 		if mapping.GRPCMetadata == "" {
-			return fmt.Errorf("mapping %d: GRPCMetadata cannot be empty", i)
+			return fmt.Errorf("mapping %d: %w", i, newMappingError(mapping, ErrValidationFailed, "GRPCMetadata cannot be empty"))
 		}
 	}
+	if cc.index.err != nil {
+		return cc.index.err
+	}
 
-	return nil
+	return validatePolicies(cc.config)
 }
 
 // Stats provides statistics about header mapping operations
 type Stats struct {
+	// IncomingMappings and OutgoingMappings count values mapped in each direction
 	IncomingMappings int64
 	OutgoingMappings int64
-	FailedMappings   int64
-	LastUpdated      time.Time
+	// FailedMappings counts required values that were missing
+	FailedMappings int64
+	LastUpdated    time.Time
+
+	// Mappings reports the counters of each mapping of the active configuration
+	Mappings []MappingStats
+
+	// PoolGets and PoolMisses count scratch buffer requests and those that
+	// had to allocate; the pool is shared by all mappers in the process
+	PoolGets   int64
+	PoolMisses int64
+
+	// TransformCacheHits, TransformCacheMisses and TransformCacheEntries
+	// describe the transform cache of the active configuration
+	TransformCacheHits    int64
+	TransformCacheMisses  int64
+	TransformCacheEntries int
+
+	// BudgetOverruns counts requests and responses whose mapping exceeded
+	// MappingBudget
+	BudgetOverruns int64
 }
 
-// GetStats returns statistics about the header mapper (placeholder for future implementation)
+// GetStats returns statistics about the header mapper. Counters are updated
+// without locks, so a snapshot taken under load is approximate.
 func (hm *HeaderMapper) GetStats() *Stats {
-	return &Stats{
-		LastUpdated: time.Now(),
+	cc := hm.state()
+	incoming, outgoing, failed := cc.index.totals()
+	cc.overrides.each(func(state *compiledConfig) {
+		in, out, fail := state.index.totals()
+		incoming, outgoing, failed = incoming+in, outgoing+out, failed+fail
+	})
+
+	stats := &Stats{
+		IncomingMappings: incoming + hm.retired.incoming.Load(),
+		OutgoingMappings: outgoing + hm.retired.outgoing.Load(),
+		FailedMappings:   failed + hm.retired.failed.Load(),
+		LastUpdated:      time.Now(),
+		Mappings:         cc.index.mappingStats(),
+		PoolGets:         scratchGets.Load(),
+		PoolMisses:       scratchMisses.Load(),
+		BudgetOverruns:   hm.budgetOverruns.Load(),
+	}
+	if cache := cc.cache; cache != nil {
+		stats.TransformCacheHits = cache.Hits()
+		stats.TransformCacheMisses = cache.Misses()
+		stats.TransformCacheEntries = cache.Len()
 	}
+	return stats
 }
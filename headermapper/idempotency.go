@@ -0,0 +1,448 @@
+package headermapper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"google.golang.org/grpc/codes"
+)
+
+// IdempotencyKeyMetadata is the metadata key of IdempotencyMappings
+const IdempotencyKeyMetadata = "idempotency-key"
+
+// IdempotencyMappings returns an incoming mapping forwarding the
+// Idempotency-Key header to backends; keys that are not UUIDs are dropped
+func IdempotencyMappings() []HeaderMapping {
+	return []HeaderMapping{
+		{
+			HTTPHeader:   "Idempotency-Key",
+			GRPCMetadata: IdempotencyKeyMetadata,
+			Direction:    Incoming,
+			Transform:    ValidateUUID,
+		},
+	}
+}
+
+// ValidateUUID returns value if it is a UUID and an empty string otherwise,
+// so the mapping is skipped
+func ValidateUUID(value string) string {
+	if !IsUUID(value) {
+		return ""
+	}
+	return value
+}
+
+// IsUUID reports whether value is a UUID in canonical 8-4-4-4-12 form
+func IsUUID(value string) bool {
+	if len(value) != 36 {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// IdempotencyConfig configures deduplication of retried requests by Handler.
+// The first request with a key is forwarded and its response status and
+// headers stored; later requests from the same caller with the same key,
+// method and path receive the stored status and headers, without a body
+// and Set-Cookie, and an Idempotent-Replayed header. Requests arriving while
+// the first is in flight are rejected with 409 Conflict. Responses with 5xx
+// status are not stored, so they can be retried.
+type IdempotencyConfig struct {
+	// Header carries the key (default Idempotency-Key)
+	Header string `json:"header" yaml:"header"`
+	// Methods lists the deduplicated methods (default POST)
+	Methods []string `json:"methods" yaml:"methods"`
+	// Paths restricts deduplication to matching paths; empty covers all
+	Paths []string `json:"paths" yaml:"paths"`
+	// TTL is how long keys are remembered (default 24h)
+	TTL time.Duration `json:"ttl" yaml:"ttl"`
+	// Required rejects deduplicated requests without a key
+	Required bool `json:"required" yaml:"required"`
+	// ScopeMetadata lists metadata keys identifying the caller, e.g. user-id
+	// or tenant-id, so callers sending the same key never share a response;
+	// the first present key is used. Without one, keys are scoped by the
+	// Authorization header, and shared by anonymous callers.
+	ScopeMetadata []string `json:"scope_metadata,omitempty" yaml:"scope_metadata,omitempty"`
+	// Store holds reservations and responses (default in-memory)
+	Store IdempotencyStore `json:"-" yaml:"-"`
+}
+
+func (ic *IdempotencyConfig) header() string {
+	if ic.Header == "" {
+		return "Idempotency-Key"
+	}
+	return ic.Header
+}
+
+func (ic *IdempotencyConfig) methods() []string {
+	if len(ic.Methods) == 0 {
+		return []string{http.MethodPost}
+	}
+	return ic.Methods
+}
+
+func (ic *IdempotencyConfig) ttl() time.Duration {
+	if ic.TTL <= 0 {
+		return 24 * time.Hour
+	}
+	return ic.TTL
+}
+
+// validate checks the key lifetime
+func (ic *IdempotencyConfig) validate() error {
+	if ic.TTL < 0 {
+		return fmt.Errorf("idempotency: ttl cannot be negative")
+	}
+	return nil
+}
+
+// IdempotentResponse is the stored response of an idempotent request
+type IdempotentResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+}
+
+// IdempotencyStore records idempotency keys. Implementations backed by shared
+// storage such as Redis deduplicate requests across gateway replicas.
+type IdempotencyStore interface {
+	// Reserve claims key for ttl and reports true if it was free. Otherwise
+	// it returns the stored response, or nil while the request holding the
+	// reservation is in flight.
+	Reserve(ctx context.Context, key string, ttl time.Duration) (bool, *IdempotentResponse, error)
+	// Complete stores the response of a reserved key
+	Complete(ctx context.Context, key string, response *IdempotentResponse, ttl time.Duration) error
+	// Release frees a reserved key so the request can be retried
+	Release(ctx context.Context, key string) error
+}
+
+// MemoryIdempotencyStore is an in-process IdempotencyStore with TTL expiry
+type MemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	entries   map[string]*idempotencyEntry
+	sweepSize int
+	now       func() time.Time
+}
+
+type idempotencyEntry struct {
+	expires  time.Time
+	response *IdempotentResponse
+}
+
+// NewMemoryIdempotencyStore creates an in-memory idempotency store
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		entries:   make(map[string]*idempotencyEntry),
+		sweepSize: 1024,
+		now:       time.Now,
+	}
+}
+
+// Reserve implements IdempotencyStore
+func (s *MemoryIdempotencyStore) Reserve(ctx context.Context, key string, ttl time.Duration) (bool, *IdempotentResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if entry, ok := s.entries[key]; ok && now.Before(entry.expires) {
+		return false, entry.response, nil
+	}
+
+	if len(s.entries) >= s.sweepSize {
+		for k, entry := range s.entries {
+			if !now.Before(entry.expires) {
+				delete(s.entries, k)
+			}
+		}
+		if len(s.entries) >= s.sweepSize {
+			s.sweepSize *= 2
+		}
+	}
+	s.entries[key] = &idempotencyEntry{expires: now.Add(ttl)}
+	return true, nil, nil
+}
+
+// Complete implements IdempotencyStore
+func (s *MemoryIdempotencyStore) Complete(ctx context.Context, key string, response *IdempotentResponse, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = &idempotencyEntry{expires: s.now().Add(ttl), response: response}
+	return nil
+}
+
+// Release implements IdempotencyStore
+func (s *MemoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+// footprint approximates the memory of the remembered keys and responses
+func (s *MemoryIdempotencyStore) footprint() (int, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := mapBytes(len(s.entries), stringSize+ptrSize) + int64(len(s.entries))*int64(unsafe.Sizeof(idempotencyEntry{}))
+	for key, entry := range s.entries {
+		n += int64(len(key))
+		if entry.response != nil {
+			for name, values := range entry.response.Header {
+				n += int64(len(name))
+				for _, value := range values {
+					n += int64(stringSize) + int64(len(value))
+				}
+			}
+		}
+	}
+	return len(s.entries), n
+}
+
+// RedisClient is the subset of a Redis client used by RedisIdempotencyStore,
+// so any client library can be adapted, e.g. go-redis:
+//
+//	type redisAdapter struct{ rdb *redis.Client }
+//
+//	func (a redisAdapter) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+//		return a.rdb.SetNX(ctx, key, value, ttl).Result()
+//	}
+//	func (a redisAdapter) Get(ctx context.Context, key string) (string, bool, error) {
+//		value, err := a.rdb.Get(ctx, key).Result()
+//		if err == redis.Nil {
+//			return "", false, nil
+//		}
+//		return value, err == nil, err
+//	}
+//	func (a redisAdapter) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+//		return a.rdb.Set(ctx, key, value, ttl).Err()
+//	}
+//	func (a redisAdapter) Del(ctx context.Context, key string) error {
+//		return a.rdb.Del(ctx, key).Err()
+//	}
+type RedisClient interface {
+	// SetNX sets key to value for ttl if it does not exist and reports whether it was set
+	SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+	// Get returns the value of key; false if it does not exist
+	Get(ctx context.Context, key string) (string, bool, error)
+	// Set sets key to value for ttl
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	// Del deletes key
+	Del(ctx context.Context, key string) error
+}
+
+// redisPending marks a reservation whose request is in flight
+const redisPending = "pending"
+
+// RedisIdempotencyStore is an IdempotencyStore shared by gateway replicas
+// through Redis. Reservations are taken with SET NX and responses stored as JSON.
+type RedisIdempotencyStore struct {
+	client RedisClient
+	prefix string
+}
+
+// NewRedisIdempotencyStore creates a Redis idempotency store namespacing its
+// keys with prefix, e.g. "idempotency:"
+func NewRedisIdempotencyStore(client RedisClient, prefix string) *RedisIdempotencyStore {
+	return &RedisIdempotencyStore{client: client, prefix: prefix}
+}
+
+// Reserve implements IdempotencyStore
+func (s *RedisIdempotencyStore) Reserve(ctx context.Context, key string, ttl time.Duration) (bool, *IdempotentResponse, error) {
+	reserved, err := s.client.SetNX(ctx, s.prefix+key, redisPending, ttl)
+	if err != nil || reserved {
+		return reserved, nil, err
+	}
+
+	value, ok, err := s.client.Get(ctx, s.prefix+key)
+	if err != nil || !ok || value == redisPending {
+		// A key expiring between the two calls is reported as in flight
+		return false, nil, err
+	}
+	var response IdempotentResponse
+	if err := json.Unmarshal([]byte(value), &response); err != nil {
+		return false, nil, fmt.Errorf("idempotency: decoding stored response: %w", err)
+	}
+	return false, &response, nil
+}
+
+// Complete implements IdempotencyStore
+func (s *RedisIdempotencyStore) Complete(ctx context.Context, key string, response *IdempotentResponse, ttl time.Duration) error {
+	value, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, s.prefix+key, string(value), ttl)
+}
+
+// Release implements IdempotencyStore
+func (s *RedisIdempotencyStore) Release(ctx context.Context, key string) error {
+	return s.client.Del(ctx, s.prefix+key)
+}
+
+// idempotencyGuard enforces an IdempotencyConfig
+type idempotencyGuard struct {
+	config  *IdempotencyConfig
+	store   IdempotencyStore
+	header  string
+	methods []string
+	ttl     time.Duration
+	hm      *HeaderMapper
+}
+
+func newIdempotencyGuard(config *IdempotencyConfig, hm *HeaderMapper) *idempotencyGuard {
+	store := config.Store
+	if store == nil {
+		store = NewMemoryIdempotencyStore()
+	}
+	return &idempotencyGuard{
+		config:  config,
+		store:   store,
+		header:  config.header(),
+		methods: config.methods(),
+		ttl:     config.ttl(),
+		hm:      hm,
+	}
+}
+
+// serve forwards req to next unless it repeats an earlier request
+func (g *idempotencyGuard) serve(w http.ResponseWriter, req *http.Request, next http.Handler) {
+	if !slices.Contains(g.methods, req.Method) || len(g.config.Paths) > 0 && !matchAnyPath(g.config.Paths, req.URL.Path) {
+		next.ServeHTTP(w, req)
+		return
+	}
+
+	idempotencyKey := strings.TrimSpace(req.Header.Get(g.header))
+	if idempotencyKey == "" {
+		if g.config.Required {
+			writeError(w, req, next, rejectf(codes.InvalidArgument, "missing %s", strings.ToLower(g.header)))
+			return
+		}
+		next.ServeHTTP(w, req)
+		return
+	}
+
+	ctx := req.Context()
+	key := req.Method + " " + req.URL.Path + " " + g.scope(req) + " " + idempotencyKey
+	reserved, stored, err := g.store.Reserve(ctx, key, g.ttl)
+	switch {
+	case err != nil:
+		// Fail closed since forwarding a duplicate may repeat a side effect
+		writeError(w, req, next, rejectf(codes.Unavailable, "idempotency store unavailable"))
+		return
+	case stored != nil:
+		h := w.Header()
+		for name, values := range stored.Header {
+			h[name] = values
+		}
+		h.Set("Idempotent-Replayed", "true")
+		w.WriteHeader(stored.Status)
+		return
+	case !reserved:
+		writeError(w, req, next, rejectf(codes.Aborted, "a request with this %s is in progress", strings.ToLower(g.header)))
+		return
+	}
+
+	recorder := &statusRecorder{ResponseWriter: w}
+	completed := false
+	defer func() {
+		// Release the key if the handler panicked or failed, so clients can retry
+		if !completed {
+			if err := g.store.Release(context.WithoutCancel(ctx), key); err != nil {
+				g.hm.log().Warn("Releasing idempotency key failed:", err)
+			}
+		}
+	}()
+	next.ServeHTTP(recorder, req)
+
+	status := recorder.statusCode()
+	if status >= http.StatusInternalServerError {
+		return
+	}
+	// Cookies belong to the caller's session and are not replayed
+	response := &IdempotentResponse{Status: status, Header: w.Header().Clone()}
+	response.Header.Del("Set-Cookie")
+	if err := g.store.Complete(context.WithoutCancel(ctx), key, response, g.ttl); err != nil {
+		g.hm.log().Warn("Storing idempotent response failed:", err)
+		return
+	}
+	completed = true
+}
+
+// scope identifies the caller of req by the first ScopeMetadata key mapped
+// for it, or else its Authorization header, as a hash so keys stay short
+// and free of separators; empty for anonymous callers
+func (g *idempotencyGuard) scope(req *http.Request) string {
+	var caller string
+	if len(g.config.ScopeMetadata) > 0 {
+		md := g.hm.annotateOnce(g.hm.state(), req)
+		for _, k := range g.config.ScopeMetadata {
+			if values := md.Get(k); len(values) > 0 && values[0] != "" {
+				caller = k + "=" + values[0]
+				break
+			}
+		}
+	}
+	if caller == "" {
+		if auth := req.Header.Get("Authorization"); auth != "" {
+			caller = "authorization=" + auth
+		}
+	}
+	if caller == "" {
+		return "-"
+	}
+	sum := sha256.Sum256([]byte(caller))
+	return hex.EncodeToString(sum[:16])
+}
+
+// statusRecorder records the status written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (r *statusRecorder) statusCode() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
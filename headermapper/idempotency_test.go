@@ -0,0 +1,299 @@
+package headermapper
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestValidateUUID(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"123e4567-e89b-12d3-a456-426614174000", "123e4567-e89b-12d3-a456-426614174000"},
+		{"123E4567-E89B-12D3-A456-426614174000", "123E4567-E89B-12D3-A456-426614174000"},
+		{"123e4567e89b12d3a456426614174000", ""},
+		{"123e4567-e89b-12d3-a456-42661417400g", ""},
+		{"123e4567+e89b-12d3-a456-426614174000", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := ValidateUUID(tt.input); got != tt.expected {
+				t.Errorf("ValidateUUID() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+	if got := ValidateUUID(GenerateUUID()); got == "" {
+		t.Error("generated UUID rejected")
+	}
+}
+
+func TestIdempotencyMappings(t *testing.T) {
+	mapper := NewBuilder().AddMappings(IdempotencyMappings()...).Build()
+	annotator := mapper.MetadataAnnotator()
+
+	tests := []struct {
+		name     string
+		key      string
+		expected []string
+	}{
+		{"uuid", "123e4567-e89b-12d3-a456-426614174000", []string{"123e4567-e89b-12d3-a456-426614174000"}},
+		{"not a uuid", "retry-1", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/v1/orders", nil)
+			req.Header.Set("Idempotency-Key", tt.key)
+			md := annotator(context.Background(), req)
+			if got := md.Get(IdempotencyKeyMetadata); len(got) != len(tt.expected) || len(got) > 0 && got[0] != tt.expected[0] {
+				t.Errorf("metadata = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIdempotency_Handler(t *testing.T) {
+	var calls int
+	mapper := NewBuilder().
+		DeduplicateRequests(&IdempotencyConfig{Required: true, Paths: []string{"/v1/*"}}).
+		Build()
+	handler := mapper.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path == "/v1/fail" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Location", "/v1/orders/1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":1}`))
+	}))
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		key       string
+		expected  int
+		wantCalls int
+		replayed  bool
+	}{
+		{"first request", "POST", "/v1/orders", "key-1", http.StatusCreated, 1, false},
+		{"retry replayed", "POST", "/v1/orders", "key-1", http.StatusCreated, 1, true},
+		{"other path same key", "POST", "/v1/invoices", "key-1", http.StatusCreated, 2, false},
+		{"new key", "POST", "/v1/orders", "key-2", http.StatusCreated, 3, false},
+		{"missing key", "POST", "/v1/orders", "", http.StatusBadRequest, 3, false},
+		{"method not covered", "GET", "/v1/orders", "", http.StatusCreated, 4, false},
+		{"path not covered", "POST", "/health", "", http.StatusCreated, 5, false},
+		{"server error", "POST", "/v1/fail", "key-3", http.StatusServiceUnavailable, 6, false},
+		{"server error retried", "POST", "/v1/fail", "key-3", http.StatusServiceUnavailable, 7, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.key != "" {
+				req.Header.Set("Idempotency-Key", tt.key)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.expected || calls != tt.wantCalls {
+				t.Errorf("status = %d, calls = %d, want %d, %d", w.Code, calls, tt.expected, tt.wantCalls)
+			}
+			if replayed := w.Header().Get("Idempotent-Replayed") == "true"; replayed != tt.replayed {
+				t.Errorf("replayed = %v, want %v", replayed, tt.replayed)
+			}
+			if tt.replayed && (w.Header().Get("Location") != "/v1/orders/1" || w.Body.Len() != 0) {
+				t.Errorf("replayed response = %v %q", w.Header(), w.Body.String())
+			}
+		})
+	}
+}
+
+func TestIdempotency_CallerScope(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *IdempotencyConfig
+		header string
+	}{
+		{"scope metadata", &IdempotencyConfig{ScopeMetadata: []string{"tenant-id", "user-id"}}, "X-User-ID"},
+		{"authorization", &IdempotencyConfig{}, "Authorization"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			mapper := NewBuilder().
+				AddIncomingMapping("X-User-ID", "user-id").
+				DeduplicateRequests(tt.config).
+				Build()
+			handler := mapper.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				http.SetCookie(w, &http.Cookie{Name: "session", Value: r.Header.Get(tt.header)})
+				w.Header().Set("Location", "/v1/orders/"+r.Header.Get(tt.header))
+				w.WriteHeader(http.StatusCreated)
+			}))
+			send := func(caller string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest("POST", "/v1/orders", nil)
+				req.Header.Set("Idempotency-Key", "key-1")
+				req.Header.Set(tt.header, caller)
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, req)
+				return w
+			}
+
+			send("alice")
+			if w := send("bob"); calls != 2 || w.Header().Get("Location") != "/v1/orders/bob" {
+				t.Errorf("second caller: calls = %d, Location = %q, want its own response", calls, w.Header().Get("Location"))
+			}
+			w := send("alice")
+			if calls != 2 || w.Header().Get("Idempotent-Replayed") != "true" || w.Header().Get("Location") != "/v1/orders/alice" {
+				t.Errorf("retry: calls = %d, headers = %v, want the stored response", calls, w.Header())
+			}
+			if cookies := w.Header().Values("Set-Cookie"); len(cookies) > 0 {
+				t.Errorf("replayed Set-Cookie = %q, want none", cookies)
+			}
+		})
+	}
+}
+
+func TestIdempotency_InFlight(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	mapper := NewBuilder().DeduplicateRequests(&IdempotencyConfig{}).Build()
+	handler := mapper.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest("POST", "/v1/orders", nil)
+		req.Header.Set("Idempotency-Key", "key-1")
+		return req
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		handler.ServeHTTP(httptest.NewRecorder(), newRequest())
+	}()
+	<-started
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, newRequest())
+	if w.Code != http.StatusConflict {
+		t.Errorf("in-flight duplicate status = %d, want %d", w.Code, http.StatusConflict)
+	}
+	close(release)
+	wg.Wait()
+}
+
+func TestIdempotency_StoreError(t *testing.T) {
+	mapper := NewBuilder().
+		DeduplicateRequests(&IdempotencyConfig{Store: NewRedisIdempotencyStore(&fakeRedis{err: errors.New("down")}, "")}).
+		Build()
+	handler := mapper.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request forwarded without a reservation")
+	}))
+
+	req := httptest.NewRequest("POST", "/v1/orders", nil)
+	req.Header.Set("Idempotency-Key", "key-1")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
+
+// fakeRedis is an in-memory RedisClient ignoring expiry
+type fakeRedis struct {
+	values map[string]string
+	err    error
+}
+
+func (f *fakeRedis) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	if f.err != nil {
+		return false, f.err
+	}
+	if _, ok := f.values[key]; ok {
+		return false, nil
+	}
+	f.values[key] = value
+	return true, nil
+}
+
+func (f *fakeRedis) Get(ctx context.Context, key string) (string, bool, error) {
+	value, ok := f.values[key]
+	return value, ok, f.err
+}
+
+func (f *fakeRedis) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	f.values[key] = value
+	return f.err
+}
+
+func (f *fakeRedis) Del(ctx context.Context, key string) error {
+	delete(f.values, key)
+	return f.err
+}
+
+func TestIdempotencyStores(t *testing.T) {
+	redis := &fakeRedis{values: make(map[string]string)}
+	stores := map[string]IdempotencyStore{
+		"memory": NewMemoryIdempotencyStore(),
+		"redis":  NewRedisIdempotencyStore(redis, "idempotency:"),
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			reserved, response, err := store.Reserve(ctx, "k", time.Minute)
+			if err != nil || !reserved || response != nil {
+				t.Fatalf("Reserve() = %v, %v, %v", reserved, response, err)
+			}
+			if reserved, response, _ := store.Reserve(ctx, "k", time.Minute); reserved || response != nil {
+				t.Errorf("Reserve() in flight = %v, %v", reserved, response)
+			}
+
+			stored := &IdempotentResponse{Status: http.StatusCreated, Header: http.Header{"Location": {"/orders/1"}}}
+			if err := store.Complete(ctx, "k", stored, time.Minute); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			reserved, response, _ = store.Reserve(ctx, "k", time.Minute)
+			if reserved || response == nil || response.Status != http.StatusCreated || response.Header.Get("Location") != "/orders/1" {
+				t.Errorf("Reserve() completed = %v, %+v", reserved, response)
+			}
+
+			if err := store.Release(ctx, "k"); err != nil {
+				t.Fatalf("Release() error = %v", err)
+			}
+			if reserved, _, _ := store.Reserve(ctx, "k", time.Minute); !reserved {
+				t.Error("Reserve() after Release = false")
+			}
+		})
+	}
+
+	if _, ok := redis.values["idempotency:k"]; !ok {
+		t.Errorf("redis keys = %v", redis.values)
+	}
+}
+
+func TestMemoryIdempotencyStore_Expiry(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryIdempotencyStore()
+	store.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	store.Reserve(ctx, "k", time.Minute)
+	now = now.Add(2 * time.Minute)
+	if reserved, _, _ := store.Reserve(ctx, "k", time.Minute); !reserved {
+		t.Error("expired key not reserved again")
+	}
+}
@@ -0,0 +1,504 @@
+package headermapper
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// mappingIndex holds the compiled mappings by direction, built once at
+// construction so per-request work is a map lookup per header instead of a
+// scan of all mappings
+type mappingIndex struct {
+	// incoming and outgoing hold the mappings of each direction in configuration order
+	incoming []compiledMapping
+	outgoing []compiledMapping
+
+	// sources holds the distinct headers read by incoming mappings in order
+	// of first use, so each header is read once per request however many
+	// mappings fan out from it
+	sources []incomingSource
+	// sourceByHeader indexes sources driven only by the request header,
+	// keyed by canonical HTTP header name
+	sourceByHeader map[string]*incomingSource
+	// sourcesAlways holds sources evaluated on every request, such as those
+	// with defaults, aliases or values derived from the connection
+	sourcesAlways []*incomingSource
+	// sourceByAlias indexes sources with aliases by canonical header and
+	// alias names, so mapsNothing detects requests sending only an alias
+	sourceByAlias map[string]*incomingSource
+	// alwaysMappings counts the mappings of sourcesAlways
+	alwaysMappings int
+	// sourceFilter is a one-word Bloom filter over the names in
+	// sourceByHeader, so requests carrying no mapped header are detected
+	// without a map lookup per header
+	sourceFilter uint64
+	// incomingOrdered is set when several incoming mappings target the same
+	// metadata key, so they must be applied in configuration order
+	incomingOrdered bool
+
+	// outgoingSequence holds the outgoing mappings in the configured order
+	outgoingSequence []*compiledMapping
+	// outgoingByKey indexes outgoing mappings by lowercase metadata key
+	outgoingByKey map[string][]*compiledMapping
+	// outgoingAlways holds outgoing mappings with defaults or requirements
+	outgoingAlways []*compiledMapping
+	// outgoingOrdered is set when several outgoing mappings target the same header
+	outgoingOrdered bool
+	// outgoingTrailers is set when any outgoing mapping reads trailer metadata
+	outgoingTrailers bool
+
+	// incomingPrefix and outgoingPrefix hold the wildcard mappings of each
+	// direction in configuration order
+	incomingPrefix []compiledMapping
+	outgoingPrefix []compiledMapping
+	// incomingParams holds the URL parameter mappings in configuration
+	// order; stripsParams is set when any strips its query parameter
+	incomingParams []compiledMapping
+	stripsParams   bool
+	// incomingPattern holds the pattern mappings in configuration order
+	incomingPattern []compiledMapping
+	// incomingPrefixes indexes incoming wildcard mappings by header prefix
+	// and outgoingPrefixes indexes outgoing ones by metadata key prefix; the
+	// first mapping configured for a prefix wins
+	incomingPrefixes headerTrie[*compiledMapping]
+	outgoingPrefixes headerTrie[*compiledMapping]
+	// mapped holds the canonical headers and aliases read by exact incoming
+	// mappings and mappedKeys the metadata keys read by exact outgoing
+	// ones, which wildcard mappings leave alone
+	mapped     map[string]bool
+	mappedKeys map[string]bool
+
+	// matcher maps HTTP header names to metadata keys for HeaderMatcher.
+	// Unless matching is case sensitive, names are indexed in both canonical
+	// and lowercase form, so the canonical names the gateway passes match
+	// without normalization.
+	matcher map[string]string
+
+	// blocked holds the headers and metadata keys never propagated
+	blocked *headerBlockList
+
+	// err reports the first mapping that failed to compile; such mappings are skipped
+	err error
+}
+
+// incomingSource is a distinct HTTP header read by incoming mappings
+type incomingSource struct {
+	header    string
+	forwarded bool
+	internal  bool
+	always    bool
+	// aliases lists the legacy names of the header, read in order when it
+	// is missing; deprecated is set when any mapping deprecates them
+	aliases    []string
+	deprecated bool
+	// mappings lists the mappings reading the header in configuration order
+	mappings []*compiledMapping
+}
+
+func newMappingIndex(hm *HeaderMapper, config *Config, cache *TransformCache) *mappingIndex {
+	idx := &mappingIndex{
+		sourceByHeader: make(map[string]*incomingSource),
+		sourceByAlias:  make(map[string]*incomingSource),
+		outgoingByKey:  make(map[string][]*compiledMapping),
+		mapped:         make(map[string]bool),
+		mappedKeys:     make(map[string]bool),
+		matcher:        make(map[string]string),
+		blocked:        newHeaderBlockList(config.BlockedHeaders),
+	}
+
+	for i, mapping := range config.Mappings {
+		compiled, err := compileMapping(mapping)
+		if err != nil {
+			if idx.err == nil {
+				idx.err = fmt.Errorf("mapping %d: %w", i, err)
+			}
+			continue
+		}
+		compiled.internal = hm.internalHeader(compiled.header)
+		if mapping.CacheTransform && compiled.transform != nil && cache != nil {
+			compiled.transform = cache.Wrap(compiled.transform)
+		}
+		if compiled.pattern != nil {
+			idx.incomingPattern = append(idx.incomingPattern, compiled)
+			continue
+		}
+		if isParamMapping(mapping) {
+			idx.incomingParams = append(idx.incomingParams, compiled)
+			idx.stripsParams = idx.stripsParams || compiled.stripParam
+			continue
+		}
+		if compiled.prefix {
+			if mapping.Direction != Outgoing {
+				idx.incomingPrefix = append(idx.incomingPrefix, compiled)
+			}
+			if mapping.Direction != Incoming {
+				idx.outgoingPrefix = append(idx.outgoingPrefix, compiled)
+			}
+			continue
+		}
+
+		// Blocked headers are neither read nor written, and blocked keys
+		// are not written
+		blockedIn := idx.blocked.blocks(compiled.header)
+		blockedOut := blockedIn || idx.blocked.blocks(compiled.key)
+		compiled.aliases = slices.DeleteFunc(compiled.aliases, idx.blocked.blocks)
+
+		if mapping.Direction != Outgoing && !blockedIn {
+			idx.incoming = append(idx.incoming, compiled)
+
+			// Headers fanning out to several keys match the key of their
+			// first mapping
+			names := []string{mapping.HTTPHeader}
+			if !config.CaseSensitive {
+				names = []string{compiled.header, compiled.lowerHeader}
+			}
+			for _, alias := range compiled.aliases {
+				names = append(names, alias)
+				if !config.CaseSensitive {
+					names = append(names, strings.ToLower(alias))
+				}
+			}
+			for _, name := range names {
+				if _, ok := idx.matcher[name]; !ok {
+					idx.matcher[name] = mapping.GRPCMetadata
+				}
+			}
+		}
+		if mapping.Direction != Incoming && !blockedOut {
+			idx.outgoing = append(idx.outgoing, compiled)
+		}
+	}
+
+	counters := make([]mappingCounter, len(idx.incoming)+len(idx.outgoing)+len(idx.incomingParams)+len(idx.incomingPattern)+len(idx.incomingPrefix)+len(idx.outgoingPrefix))
+	next := 0
+	for _, mappings := range [][]compiledMapping{idx.incoming, idx.outgoing, idx.incomingParams, idx.incomingPattern, idx.incomingPrefix, idx.outgoingPrefix} {
+		for i := range mappings {
+			mappings[i].counter = &counters[next]
+			next++
+		}
+	}
+	idx.indexPrefixes()
+
+	targets := make(map[string]bool)
+	sourceIDs := make(map[string]int)
+	for i := range idx.incoming {
+		mapping := &idx.incoming[i]
+
+		if targets[mapping.key] {
+			idx.incomingOrdered = true
+		}
+		targets[mapping.key] = true
+
+		id, ok := sourceIDs[mapping.header]
+		if !ok {
+			id = len(idx.sources)
+			sourceIDs[mapping.header] = id
+			idx.sources = append(idx.sources, incomingSource{
+				header:    mapping.header,
+				forwarded: mapping.forwarded,
+				internal:  mapping.internal,
+			})
+		}
+		mapping.source = id
+		idx.mapped[mapping.header] = true
+		for _, alias := range mapping.aliases {
+			idx.mapped[alias] = true
+		}
+
+		src := &idx.sources[id]
+		src.mappings = append(src.mappings, mapping)
+		for _, alias := range mapping.aliases {
+			src.aliases = appendUnique(src.aliases, alias)
+		}
+		src.deprecated = src.deprecated || mapping.deprecated && len(mapping.aliases) > 0
+		if mapping.defaultValue != "" || mapping.generator != nil || mapping.required || mapping.forwarded || mapping.internal {
+			src.always = true
+		}
+	}
+
+	for i := range idx.sources {
+		src := &idx.sources[i]
+		if src.always {
+			idx.sourcesAlways = append(idx.sourcesAlways, src)
+			idx.alwaysMappings += len(src.mappings)
+			continue
+		}
+		if len(src.aliases) > 0 {
+			// Aliased sources are read on every request so an alias is found
+			// when the header is missing, but only requests sending one of
+			// the names need mapping
+			idx.sourcesAlways = append(idx.sourcesAlways, src)
+			for _, name := range append([]string{src.header}, src.aliases...) {
+				idx.sourceByAlias[name] = src
+				idx.sourceFilter |= headerBit(name)
+			}
+			continue
+		}
+		idx.sourceByHeader[src.header] = src
+		idx.sourceFilter |= headerBit(src.header)
+	}
+
+	idx.outgoingSequence = config.OutgoingOrder.sequence(idx.outgoing)
+	headers := make(map[string]bool)
+	for i := range idx.outgoing {
+		mapping := &idx.outgoing[i]
+
+		if headers[mapping.header] {
+			idx.outgoingOrdered = true
+		}
+		headers[mapping.header] = true
+		idx.mappedKeys[mapping.key] = true
+
+		idx.outgoingTrailers = idx.outgoingTrailers || mapping.fromTrailer
+		if mapping.defaultValue != "" || mapping.generator != nil || mapping.required {
+			idx.outgoingAlways = append(idx.outgoingAlways, mapping)
+			continue
+		}
+		if !mapping.fromHeader {
+			// Trailer mappings are selected through the full sequence
+			continue
+		}
+		idx.outgoingByKey[mapping.key] = append(idx.outgoingByKey[mapping.key], mapping)
+	}
+
+	return idx
+}
+
+// incomingCapacity bounds the number of metadata entries mapping req can
+// produce, so large configurations do not size per-request state by the
+// number of mappings
+func (idx *mappingIndex) incomingCapacity(req *http.Request) int {
+	return min(len(idx.incoming), len(req.Header)+idx.alwaysMappings)
+}
+
+// headerBit returns the bit of name in sourceFilter
+func headerBit(name string) uint64 {
+	if name == "" {
+		return 0
+	}
+	return 1 << ((uint(len(name))*31 + uint(name[len(name)-1])) & 63)
+}
+
+// mapsNothing reports whether incoming mapping of req would produce no
+// metadata because it carries none of the mapped headers
+func (idx *mappingIndex) mapsNothing(req *http.Request) bool {
+	// Matching patterns costs as much as applying them
+	if idx.alwaysMappings > 0 || len(idx.incomingPattern) > 0 || len(idx.incomingParams) > 0 {
+		return false
+	}
+	for name := range req.Header {
+		if idx.sourceFilter&headerBit(name) != 0 && (idx.sourceByHeader[name] != nil || idx.sourceByAlias[name] != nil) {
+			return false
+		}
+		if len(idx.incomingPrefix) > 0 {
+			if _, ok := idx.incomingPrefixes.longestPrefix(name); ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// maxMemoSources bounds the sources whose values are memoized on the stack
+// when mappings are applied in configuration order
+const maxMemoSources = 64
+
+// mapIncoming applies the incoming mappings to the headers of req. Exact
+// mappings are applied first, so pattern and prefix mappings only fill in
+// the rest.
+func (hm *HeaderMapper) mapIncoming(cc *compiledConfig, req *http.Request, md metadata.MD) {
+	budget := newMappingBudget(cc)
+	var ok bool
+	if cc.parallel() {
+		ok = hm.mapIncomingParallel(cc, req, md, budget)
+	} else {
+		ok = hm.mapIncomingSequential(cc, req, md, budget)
+	}
+	if ok && len(cc.index.incomingParams) > 0 {
+		ok = hm.mapIncomingParams(cc, req, md, budget)
+	}
+	if ok && len(cc.index.incomingPattern) > 0 {
+		ok = hm.mapIncomingPatterns(cc, req, md, budget)
+	}
+	if ok && len(cc.index.incomingPrefix) > 0 {
+		hm.mapIncomingPrefixes(cc, req, md, budget)
+	}
+}
+
+// mapIncomingSequential applies the exact incoming mappings on the calling
+// goroutine. It reports false once budget runs out.
+func (hm *HeaderMapper) mapIncomingSequential(cc *compiledConfig, req *http.Request, md metadata.MD, budget mappingBudget) bool {
+	idx := cc.index
+	backing := make([]string, 0, idx.incomingCapacity(req))
+
+	if idx.incomingOrdered || len(req.Header) > len(idx.sources) {
+		var memo [maxMemoSources]string
+		var known uint64
+		for i := range idx.incoming {
+			mapping := &idx.incoming[i]
+			src := &idx.sources[mapping.source]
+
+			var value string
+			switch {
+			case mapping.source >= maxMemoSources:
+				value = hm.sourceValue(cc, req, src, req.Header[src.header])
+			case known&(1<<mapping.source) != 0:
+				value = memo[mapping.source]
+			default:
+				value = hm.sourceValue(cc, req, src, req.Header[src.header])
+				memo[mapping.source] = value
+				known |= 1 << mapping.source
+			}
+			hm.mapIncomingHeader(cc, md, mapping, value, &backing)
+			if budget.exceeded(mapping) {
+				hm.budgetExceeded(cc, mapping)
+				return false
+			}
+		}
+		return true
+	}
+
+	for name, values := range req.Header {
+		if src := idx.sourceByHeader[name]; src != nil {
+			value := hm.sourceValue(cc, req, src, values)
+			for _, mapping := range src.mappings {
+				hm.mapIncomingHeader(cc, md, mapping, value, &backing)
+				if budget.exceeded(mapping) {
+					hm.budgetExceeded(cc, mapping)
+					return false
+				}
+			}
+		}
+	}
+	for _, src := range idx.sourcesAlways {
+		value := hm.sourceValue(cc, req, src, req.Header[src.header])
+		for _, mapping := range src.mappings {
+			hm.mapIncomingHeader(cc, md, mapping, value, &backing)
+			if budget.exceeded(mapping) {
+				hm.budgetExceeded(cc, mapping)
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// sourceValue resolves the value of an incoming header once for all the
+// mappings reading it; values are the header's values in req
+func (hm *HeaderMapper) sourceValue(cc *compiledConfig, req *http.Request, src *incomingSource, values []string) string {
+	switch {
+	case src.forwarded:
+		return hm.forwardedHeaderValue(req, src.header)
+	case src.internal:
+		// Internal headers are never accepted from external clients
+		return ""
+	}
+	if len(values) == 0 && len(src.aliases) > 0 {
+		values = hm.aliasValues(req, src)
+	}
+	return cc.selectValue(values)
+}
+
+// headerWrite is a response header computed by an outgoing mapping
+type headerWrite struct {
+	header string
+	value  string
+	// append adds value to the values already set
+	append bool
+	// trailer is set for values read from trailer metadata
+	trailer bool
+}
+
+// maxStackWrites bounds the header writes prepared without allocating
+const maxStackWrites = 16
+
+// applyOutgoing maps outgoing metadata to the response headers. Mappings are
+// applied in the configured order whichever metadata is present, so
+// responses are deterministic. Values are computed first and then written in
+// one pass with a shared backing slice, so each header does not allocate its
+// own slice.
+func (hm *HeaderMapper) applyOutgoing(cc *compiledConfig, md metadata.MD, w http.ResponseWriter) {
+	hm.applyResponse(cc, md, nil, w, false)
+}
+
+// applyResponse maps the header and trailer metadata of a response. Values
+// mappings read from trailers become HTTP trailers when asTrailers is set
+// and response headers otherwise.
+func (hm *HeaderMapper) applyResponse(cc *compiledConfig, md, trailers metadata.MD, w http.ResponseWriter, asTrailers bool) {
+	idx := cc.index
+
+	var buf [maxStackWrites]headerWrite
+	writes := buf[:0]
+	budget := newMappingBudget(cc)
+	exhausted := false
+	// add reports false once the budget is exceeded
+	add := func(mapping *compiledMapping) bool {
+		if value, trailer, ok := hm.mapOutgoingHeader(md, trailers, mapping); ok {
+			writes = append(writes, headerWrite{header: mapping.header, value: value, append: mapping.appendValues, trailer: trailer})
+		}
+		if budget.exceeded(mapping) {
+			hm.budgetExceeded(cc, mapping)
+			exhausted = true
+		}
+		return !exhausted
+	}
+
+	sequence := idx.outgoingSequence
+	if !idx.outgoingOrdered && len(md) <= len(idx.outgoing) && (len(trailers) == 0 || !idx.outgoingTrailers) {
+		// Only the mappings of the metadata present are applied, restored
+		// to the configured order since map iteration order is random.
+		// Selections too large for the stack apply the full sequence instead.
+		selectedCount := len(idx.outgoingAlways)
+		for key := range md {
+			selectedCount += len(idx.outgoingByKey[key])
+		}
+		if selectedCount <= maxStackWrites {
+			var selected [maxStackWrites]*compiledMapping
+			sequence = selected[:0]
+			for key := range md {
+				sequence = append(sequence, idx.outgoingByKey[key]...)
+			}
+			sequence = append(sequence, idx.outgoingAlways...)
+			if len(sequence) > 1 {
+				slices.SortFunc(sequence, byPosition)
+			}
+		}
+	}
+	for _, mapping := range sequence {
+		if !add(mapping) {
+			break
+		}
+	}
+	if !exhausted && len(idx.outgoingPrefix) > 0 {
+		writes = hm.mapOutgoingPrefixes(cc, md, writes, budget)
+	}
+	if len(writes) == 0 {
+		return
+	}
+
+	// Header names are canonical, so the map is accessed directly. Writes
+	// are applied in order, so without OverwriteExisting the first mapping
+	// targeting a header wins unless later ones append.
+	h := w.Header()
+	backing := make([]string, len(writes))
+	for i, write := range writes {
+		if write.trailer && asTrailers {
+			setTrailer(h, write)
+			continue
+		}
+		existing := h[write.header]
+		if write.append && len(existing) > 0 {
+			h[write.header] = append(existing[:len(existing):len(existing)], write.value)
+			continue
+		}
+		if !cc.config.OverwriteExisting && len(existing) > 0 && existing[0] != "" {
+			continue
+		}
+		backing[i] = write.value
+		h[write.header] = backing[i : i+1 : i+1]
+	}
+}
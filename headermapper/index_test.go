@@ -0,0 +1,220 @@
+package headermapper
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestMappingIndex(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").
+		AddIncomingMapping("X-Tenant-ID", "tenant-id").
+		WithDefault("default").
+		AddOutgoingMapping("request-id", "X-Request-ID").
+		AddBidirectionalMapping("X-Trace-ID", "trace-id").
+		Build()
+
+	idx := mapper.state().index
+	if len(idx.incoming) != 3 || len(idx.outgoing) != 2 {
+		t.Fatalf("incoming = %d, outgoing = %d", len(idx.incoming), len(idx.outgoing))
+	}
+	if idx.sourceByHeader["X-User-Id"] == nil || len(idx.sourcesAlways) != 1 || idx.alwaysMappings != 1 {
+		t.Errorf("unexpected incoming index %+v", idx)
+	}
+	if len(idx.outgoingByKey["trace-id"]) != 1 || idx.incomingOrdered || idx.outgoingOrdered {
+		t.Errorf("unexpected outgoing index %+v", idx)
+	}
+	if idx.matcher["x-trace-id"] != "trace-id" || idx.matcher["X-Trace-Id"] != "trace-id" {
+		t.Errorf("matcher = %v", idx.matcher)
+	}
+}
+
+func TestHeaderMatcher_PreCanonicalized(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("x-user-id", "user-id").
+		Build()
+	matcher := mapper.HeaderMatcher()
+
+	for _, header := range []string{"X-User-Id", "x-user-id", "X-USER-ID"} {
+		if key, ok := matcher(header); !ok || key != "user-id" {
+			t.Errorf("matcher(%s) = %q, %v", header, key, ok)
+		}
+	}
+
+	// Canonical and lowercase names hit the index directly; other ASCII
+	// spellings are lowercased on the stack
+	allocs := testing.AllocsPerRun(100, func() {
+		matcher("X-User-Id")
+		matcher("x-user-id")
+		matcher("X-USER-ID")
+	})
+	if allocs != 0 {
+		t.Errorf("matcher allocs = %v, want 0", allocs)
+	}
+}
+
+func TestMappingIndex_SharedSource(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("Authorization", "authorization").
+		AddIncomingMapping("authorization", "auth-token").
+		WithTransform(ExtractBearerToken).
+		AddIncomingMapping("X-User-ID", "user-id").
+		AddIncomingMapping("X-Legacy-User", "user-id").
+		Build()
+
+	idx := mapper.state().index
+	if len(idx.sources) != 3 || len(idx.sources[0].mappings) != 2 {
+		t.Fatalf("sources = %+v", idx.sources)
+	}
+
+	// Both the indexed and the ordered path fan out from one read
+	for _, extra := range []int{0, 10} {
+		req := httptest.NewRequest("GET", "/v1/echo", nil)
+		req.Header.Set("Authorization", "Bearer abc")
+		for i := 0; i < extra; i++ {
+			req.Header.Set(fmt.Sprintf("X-Extra-%d", i), "value")
+		}
+
+		md := mapper.MetadataAnnotator()(context.Background(), req)
+		if got := md.Get("authorization"); len(got) != 1 || got[0] != "Bearer abc" {
+			t.Errorf("authorization = %v", got)
+		}
+		if got := md.Get("auth-token"); len(got) != 1 || got[0] != "abc" {
+			t.Errorf("auth-token = %v", got)
+		}
+	}
+}
+
+func TestMappingIndex_OrderedConflicts(t *testing.T) {
+	// Both mappings target user-id; without OverwriteExisting the first wins
+	mapper := NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").
+		AddIncomingMapping("X-Legacy-User", "user-id").
+		AddOutgoingMapping("new-id", "X-ID").
+		AddOutgoingMapping("old-id", "X-ID").
+		Build()
+
+	if !mapper.state().index.incomingOrdered || !mapper.state().index.outgoingOrdered {
+		t.Fatal("expected conflicting mappings to be applied in order")
+	}
+
+	for i := 0; i < 20; i++ {
+		req := httptest.NewRequest("GET", "/v1/echo", nil)
+		req.Header.Set("X-Legacy-User", "legacy")
+		req.Header.Set("X-User-ID", "current")
+		md := mapper.MetadataAnnotator()(context.Background(), req)
+		if got := md.Get("user-id"); len(got) != 1 || got[0] != "current" {
+			t.Fatalf("user-id = %v, want current", got)
+		}
+
+		w := httptest.NewRecorder()
+		ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{
+			HeaderMD: metadata.Pairs("old-id", "old", "new-id", "new"),
+		})
+		if err := mapper.ResponseModifier()(ctx, w, nil); err != nil {
+			t.Fatal(err)
+		}
+		if got := w.Header().Get("X-ID"); got != "new" {
+			t.Fatalf("X-ID = %s, want new", got)
+		}
+	}
+}
+
+func TestApplyOutgoing_Overwrite(t *testing.T) {
+	tests := []struct {
+		name      string
+		overwrite bool
+		existing  string
+		expected  string
+	}{
+		{"keeps existing header", false, "upstream", "upstream"},
+		{"fills empty header", false, "", "mapped"},
+		{"overwrites existing header", true, "upstream", "mapped"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper := NewBuilder().
+				AddOutgoingMapping("request-id", "X-Request-ID").
+				OverwriteExisting(tt.overwrite).
+				Build()
+
+			w := httptest.NewRecorder()
+			if tt.existing != "" {
+				w.Header().Set("X-Request-ID", tt.existing)
+			}
+			mapper.applyOutgoing(mapper.state(), metadata.Pairs("request-id", "mapped"), w)
+
+			if got := w.Header().Values("X-Request-ID"); len(got) != 1 || got[0] != tt.expected {
+				t.Errorf("X-Request-ID = %v, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMappingIndex_MapsNothing(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapper  *HeaderMapper
+		headers map[string]string
+		want    bool
+	}{
+		{
+			name:    "no headers",
+			mapper:  NewBuilder().AddIncomingMapping("X-User-ID", "user-id").Build(),
+			headers: nil,
+			want:    true,
+		},
+		{
+			name:    "unmapped headers",
+			mapper:  NewBuilder().AddIncomingMapping("X-User-ID", "user-id").Build(),
+			headers: map[string]string{"Accept": "*/*", "User-Agent": "probe"},
+			want:    true,
+		},
+		{
+			name:    "mapped header",
+			mapper:  NewBuilder().AddIncomingMapping("X-User-ID", "user-id").Build(),
+			headers: map[string]string{"Accept": "*/*", "x-user-id": "12345"},
+			want:    false,
+		},
+		{
+			name:    "default value",
+			mapper:  NewBuilder().AddIncomingMapping("X-Tenant-ID", "tenant-id").WithDefault("default").Build(),
+			headers: map[string]string{"Accept": "*/*"},
+			want:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/test", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			if got := tt.mapper.state().index.mapsNothing(req); got != tt.want {
+				t.Errorf("mapsNothing() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetadataAnnotator_NoMappedHeadersWithHooks(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").
+		SignPropagation(&PropagationSigningConfig{
+			Keys:    []string{"user-id"},
+			KeyID:   "k1",
+			Secrets: map[string]string{"k1": "secret"},
+		}).
+		Build()
+
+	md := mapper.MetadataAnnotator()(context.Background(), httptest.NewRequest("GET", "/healthz", nil))
+	if len(md.Get(HeadersSignatureKey)) != 1 {
+		t.Errorf("metadata = %v, want signature", md)
+	}
+}
@@ -0,0 +1,67 @@
+package headermapper
+
+import "strings"
+
+// InterceptorOption configures the interceptors
+type InterceptorOption func(*interceptorOptions)
+
+type interceptorOptions struct {
+	validate    bool
+	outgoing    bool
+	streamHooks func(*StreamInfo) StreamHooks
+	// propagated lists the lowercase keys the client interceptors
+	// propagate; nil propagates all mapped keys but credentials
+	propagated map[string]bool
+}
+
+// WithServerSideValidation makes the server interceptors reject calls
+// missing the metadata of required incoming mappings with
+// codes.InvalidArgument and a BadRequest detail listing the missing keys.
+// Unlike StrictMode it leaves gateway requests alone, protecting services
+// that gRPC clients call directly.
+//
+//	grpc.NewServer(grpc.UnaryInterceptor(mapper.UnaryServerInterceptor(headermapper.WithServerSideValidation())))
+func WithServerSideValidation() InterceptorOption {
+	return func(o *interceptorOptions) {
+		o.validate = true
+	}
+}
+
+// WithOutgoingMappings makes the server interceptors apply the outgoing
+// mappings to the header metadata handlers send with grpc.SetHeader and
+// grpc.SendHeader: values are transformed, and the keys of mappings with a
+// Generator or DefaultValue the handler did not set are filled in before the
+// header is sent. Native gRPC clients then receive the same values as
+// gateway clients. The gateway's ResponseModifier transforms the values
+// again, so mappings used on both sides should have idempotent transforms.
+func WithOutgoingMappings() InterceptorOption {
+	return func(o *interceptorOptions) {
+		o.outgoing = true
+	}
+}
+
+// WithPropagatedKeys limits the metadata the client interceptors propagate
+// to keys, which may include credential keys such as authorization that are
+// not propagated otherwise. Reserved keys set by the mapper's policies are
+// never propagated.
+//
+//	mapper.UnaryClientInterceptor(headermapper.WithPropagatedKeys("request-id", "tenant-id"))
+func WithPropagatedKeys(keys ...string) InterceptorOption {
+	return func(o *interceptorOptions) {
+		o.propagated = make(map[string]bool, len(keys))
+		for _, key := range keys {
+			o.propagated[strings.ToLower(key)] = true
+		}
+	}
+}
+
+// interceptorOptions applies opts; with StrictMode the call checks already
+// validate required metadata, so the interceptors do not repeat it
+func (hm *HeaderMapper) interceptorOptions(opts []InterceptorOption) interceptorOptions {
+	var o interceptorOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	o.validate = o.validate && !hm.strict
+	return o
+}
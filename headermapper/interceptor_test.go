@@ -0,0 +1,96 @@
+package headermapper
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestWithServerSideValidation(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/echo.v1.EchoService/Echo"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return "ok", nil }
+
+	tests := []struct {
+		name    string
+		md      metadata.MD
+		opts    []InterceptorOption
+		missing []string
+	}{
+		{"missing", metadata.Pairs("user-id", "u"), []InterceptorOption{WithServerSideValidation()}, []string{"tenant-id", "request-id"}},
+		{"no metadata", nil, []InterceptorOption{WithServerSideValidation()}, []string{"user-id", "tenant-id", "request-id"}},
+		{"present", metadata.Pairs("user-id", "u", "tenant-id", "t", "request-id", "r"), []InterceptorOption{WithServerSideValidation()}, nil},
+		{"disabled", metadata.Pairs("user-id", "u"), nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tt.md)
+			}
+			_, err := newStrictMapper(false).UnaryServerInterceptor(tt.opts...)(ctx, nil, info, handler)
+			if len(tt.missing) == 0 {
+				if err != nil {
+					t.Fatalf("UnaryServerInterceptor() error = %v", err)
+				}
+				return
+			}
+
+			st := status.Convert(err)
+			if st.Code() != codes.InvalidArgument {
+				t.Fatalf("UnaryServerInterceptor() error = %v, want InvalidArgument", err)
+			}
+			var fields []string
+			for _, detail := range st.Details() {
+				if br, ok := detail.(*errdetails.BadRequest); ok {
+					for _, v := range br.GetFieldViolations() {
+						fields = append(fields, v.GetField())
+					}
+				}
+			}
+			if len(fields) != len(tt.missing) {
+				t.Fatalf("field violations = %v, want %v", fields, tt.missing)
+			}
+			for i, key := range tt.missing {
+				if fields[i] != key {
+					t.Errorf("field violation %d = %s, want %s", i, fields[i], key)
+				}
+			}
+		})
+	}
+}
+
+func TestWithServerSideValidation_Stream(t *testing.T) {
+	mapper := newStrictMapper(false)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("user-id", "u"))
+	called := false
+	err := mapper.StreamServerInterceptor(WithServerSideValidation())(nil, &mockServerStream{ctx: ctx},
+		&grpc.StreamServerInfo{FullMethod: "/echo.v1.EchoService/Chat"},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			called = true
+			return nil
+		})
+	if status.Code(err) != codes.InvalidArgument || called {
+		t.Errorf("StreamServerInterceptor() error = %v, handler called = %v", err, called)
+	}
+}
+
+func TestWithServerSideValidation_StrictMode(t *testing.T) {
+	// StrictMode already checks calls, so the option must not count twice
+	mapper := newStrictMapper(true)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("user-id", "u", "request-id", "r"))
+	_, err := mapper.UnaryServerInterceptor(WithServerSideValidation())(ctx, nil,
+		&grpc.UnaryServerInfo{FullMethod: "/echo.v1.EchoService/Echo"},
+		func(ctx context.Context, req interface{}) (interface{}, error) { return "ok", nil })
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("UnaryServerInterceptor() error = %v, want InvalidArgument", err)
+	}
+	if got := mapper.GetStats().FailedMappings; got != 1 {
+		t.Errorf("FailedMappings = %d, want 1", got)
+	}
+}
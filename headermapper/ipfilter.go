@@ -0,0 +1,219 @@
+package headermapper
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// IPFilterConfig configures CIDR-based allow and deny lists applied to the
+// client IP before requests reach backends
+type IPFilterConfig struct {
+	// Allow lists permitted CIDRs or IPs; empty permits any address not denied
+	Allow []string `json:"allow" yaml:"allow"`
+	// Deny lists rejected CIDRs or IPs; deny takes precedence over allow
+	Deny []string `json:"deny" yaml:"deny"`
+	// Paths adds lists for specific HTTP paths or gRPC methods; the first
+	// matching entry is applied in addition to the global lists
+	Paths []PathIPFilter `json:"paths" yaml:"paths"`
+	// ClientIPMetadata receives the client IP (default client-ip)
+	ClientIPMetadata string `json:"client_ip_metadata" yaml:"client_ip_metadata"`
+	// DecisionMetadata receives the filter decision for auditing (default ip-filter-decision)
+	DecisionMetadata string `json:"decision_metadata" yaml:"decision_metadata"`
+}
+
+// PathIPFilter holds allow and deny lists for matching paths
+type PathIPFilter struct {
+	// Paths uses the same patterns as AccessRule.Paths
+	Paths []string `json:"paths" yaml:"paths"`
+	Allow []string `json:"allow" yaml:"allow"`
+	Deny  []string `json:"deny" yaml:"deny"`
+}
+
+// validate checks that every list entry parses
+func (fc *IPFilterConfig) validate() error {
+	lists := [][]string{fc.Allow, fc.Deny}
+	for _, p := range fc.Paths {
+		lists = append(lists, p.Allow, p.Deny)
+	}
+	for _, list := range lists {
+		if _, err := parseCIDRs(list); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ipList is a parsed allow or deny list
+type ipList []*net.IPNet
+
+// parseCIDRs parses CIDRs and bare IPs into networks
+func parseCIDRs(entries []string) (ipList, error) {
+	list := make(ipList, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address: %q", entry)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			list = append(list, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR: %q", entry)
+		}
+		list = append(list, network)
+	}
+	return list, nil
+}
+
+// match returns the first network containing ip
+func (l ipList) match(ip net.IP) *net.IPNet {
+	for _, network := range l {
+		if network.Contains(ip) {
+			return network
+		}
+	}
+	return nil
+}
+
+// ipRules is a compiled pair of allow and deny lists
+type ipRules struct {
+	allow ipList
+	deny  ipList
+}
+
+// decide returns the decision for ip, or an error if it is rejected
+func (r *ipRules) decide(ip net.IP) (string, bool) {
+	if network := r.deny.match(ip); network != nil {
+		return "deny:" + network.String(), false
+	}
+	if len(r.allow) == 0 {
+		return "allow", true
+	}
+	if network := r.allow.match(ip); network != nil {
+		return "allow:" + network.String(), true
+	}
+	return "deny", false
+}
+
+type pathIPRules struct {
+	paths []string
+	ipRules
+}
+
+// ipFilter enforces an IPFilterConfig
+type ipFilter struct {
+	global      ipRules
+	paths       []pathIPRules
+	ipKey       string
+	decisionKey string
+	clientIP    func(req *http.Request) net.IP
+}
+
+func newIPFilter(config *IPFilterConfig, clientIP func(req *http.Request) net.IP) *ipFilter {
+	// Invalid entries are reported by Validate
+	f := &ipFilter{
+		ipKey:       config.ClientIPMetadata,
+		decisionKey: config.DecisionMetadata,
+		clientIP:    clientIP,
+	}
+	if f.ipKey == "" {
+		f.ipKey = "client-ip"
+	}
+	if f.decisionKey == "" {
+		f.decisionKey = "ip-filter-decision"
+	}
+	f.global.allow, _ = parseCIDRs(config.Allow)
+	f.global.deny, _ = parseCIDRs(config.Deny)
+	for _, p := range config.Paths {
+		rules := pathIPRules{paths: p.Paths}
+		rules.allow, _ = parseCIDRs(p.Allow)
+		rules.deny, _ = parseCIDRs(p.Deny)
+		f.paths = append(f.paths, rules)
+	}
+	return f
+}
+
+// decide applies the global and path lists to ip
+func (f *ipFilter) decide(p string, ip net.IP) (string, error) {
+	if ip == nil {
+		return "deny", rejectf(codes.PermissionDenied, "client IP unknown")
+	}
+
+	decision, ok := f.global.decide(ip)
+	if ok {
+		for i := range f.paths {
+			if matchAnyPath(f.paths[i].paths, p) {
+				decision, ok = f.paths[i].decide(ip)
+				break
+			}
+		}
+	}
+	if !ok {
+		return decision, rejectf(codes.PermissionDenied, "client IP not allowed: %s", ip)
+	}
+	return decision, nil
+}
+
+// check rejects HTTP requests from disallowed addresses
+func (f *ipFilter) check(w http.ResponseWriter, req *http.Request) error {
+	if _, err := f.decide(req.URL.Path, f.clientIP(req)); err != nil {
+		return err
+	}
+	markChecked(req.Context(), ipFilterCheck)
+	return nil
+}
+
+// annotate records the client IP and decision in the metadata
+func (f *ipFilter) annotate(req *http.Request, md metadata.MD) {
+	ip := f.clientIP(req)
+	decision, _ := f.decide(req.URL.Path, ip)
+	if ip != nil {
+		md.Set(f.ipKey, ip.String())
+	}
+	md.Set(f.decisionKey, decision)
+}
+
+// checkCall rejects gRPC calls from disallowed peers and records the decision
+func (f *ipFilter) checkCall(ctx context.Context, fullMethod string, md metadata.MD) error {
+	var ip net.IP
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		ip = remoteIP(p.Addr.String())
+	}
+	decision, err := f.decide(fullMethod, ip)
+	if err != nil {
+		return err
+	}
+
+	// Keep the values the gateway recorded for requests it filtered; those
+	// sent by any other client are replaced
+	if !gatewayEnforced(ctx, ipFilterCheck) || len(md.Get(f.decisionKey)) == 0 {
+		md.Set(f.ipKey, ip.String())
+		md.Set(f.decisionKey, decision)
+	}
+	return nil
+}
+
+// matchAnyPath reports whether p matches any of the patterns
+func matchAnyPath(patterns []string, p string) bool {
+	for _, pattern := range patterns {
+		if matchPath(pattern, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package headermapper
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		remote   string
+		expected string
+	}{
+		{"remote address", nil, "192.0.2.1:1234", "192.0.2.1"},
+		{"forwarded for", map[string]string{"X-Forwarded-For": "203.0.113.5, 10.0.0.1"}, "10.0.0.2:1234", "203.0.113.5"},
+		{"real ip", map[string]string{"X-Real-IP": "203.0.113.7"}, "10.0.0.2:1234", "203.0.113.7"},
+		{"invalid forwarded", map[string]string{"X-Forwarded-For": "garbage"}, "192.0.2.1:1234", "192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remote
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			if got := ClientIP(req); got.String() != tt.expected {
+				t.Errorf("ClientIP() = %v, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIPFilter_Handler(t *testing.T) {
+	mapper := NewBuilder().
+		FilterIPs(&IPFilterConfig{
+			Deny: []string{"192.0.2.66"},
+			Paths: []PathIPFilter{
+				{Paths: []string{"/v1/admin/*"}, Allow: []string{"10.0.0.0/8"}},
+			},
+		}).
+		Build()
+
+	var decision string
+	handler := mapper.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		md := mapper.MetadataAnnotator()(r.Context(), r)
+		decision = md.Get("ip-filter-decision")[0]
+	}))
+
+	tests := []struct {
+		name     string
+		path     string
+		remote   string
+		expected int
+		decision string
+	}{
+		{"global allow", "/v1/echo", "192.0.2.1:1", http.StatusOK, "allow"},
+		{"global deny", "/v1/echo", "192.0.2.66:1", http.StatusForbidden, ""},
+		{"path allow", "/v1/admin/users", "10.1.2.3:1", http.StatusOK, "allow:10.0.0.0/8"},
+		{"path deny", "/v1/admin/users", "192.0.2.1:1", http.StatusForbidden, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision = ""
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req.RemoteAddr = tt.remote
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.expected {
+				t.Errorf("Handler() status = %d, want %d", w.Code, tt.expected)
+			}
+			if decision != tt.decision {
+				t.Errorf("decision = %q, want %q", decision, tt.decision)
+			}
+		})
+	}
+}
+
+func TestIPFilter_UnaryServerInterceptor(t *testing.T) {
+	mapper := NewBuilder().
+		FilterIPs(&IPFilterConfig{Allow: []string{"10.0.0.0/8"}}).
+		Build()
+	interceptor := mapper.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Method"}
+
+	call := func(addr string) (metadata.MD, error) {
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(addr), Port: 1}})
+		ctx = metadata.NewIncomingContext(ctx, metadata.MD{})
+		var got metadata.MD
+		_, err := interceptor(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			got, _ = metadata.FromIncomingContext(ctx)
+			return nil, nil
+		})
+		return got, err
+	}
+
+	md, err := call("10.0.0.5")
+	if err != nil {
+		t.Fatalf("allowed peer error = %v", err)
+	}
+	if got := md.Get("client-ip"); len(got) != 1 || got[0] != "10.0.0.5" {
+		t.Errorf("client-ip = %v", got)
+	}
+
+	if _, err := call("192.0.2.1"); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("denied peer code = %v, want PermissionDenied", status.Code(err))
+	}
+}
+
+func TestIPFilterConfig_Validate(t *testing.T) {
+	err := ValidateConfig(&Config{IPFilter: &IPFilterConfig{Allow: []string{"10.0.0.0/33"}}})
+	if err == nil {
+		t.Error("ValidateConfig() expected error for invalid CIDR")
+	}
+}
+
+func TestIPFilter_SpoofedMetadata(t *testing.T) {
+	mapper := NewBuilder().
+		FilterIPs(&IPFilterConfig{Allow: []string{"10.0.0.0/8"}}).
+		Build()
+	interceptor := mapper.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Method"}
+	marker, _ := mapper.gatewayMarks.issue([]string{ipFilterCheck})
+
+	tests := []struct {
+		name     string
+		marker   string
+		clientIP string
+	}{
+		{"direct client", "", "10.0.0.5"},
+		{"forged marker", "forged", "10.0.0.5"},
+		{"gateway", marker, "203.0.113.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			md := metadata.Pairs("client-ip", "203.0.113.7", "ip-filter-decision", "allow")
+			if tt.marker != "" {
+				md.Set(gatewayCheckedKey, tt.marker)
+			}
+			ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.5"), Port: 1}})
+			ctx = metadata.NewIncomingContext(ctx, md)
+
+			var got metadata.MD
+			_, err := interceptor(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				got, _ = metadata.FromIncomingContext(ctx)
+				return nil, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ip := got.Get("client-ip"); len(ip) != 1 || ip[0] != tt.clientIP {
+				t.Errorf("client-ip = %v, want %s", ip, tt.clientIP)
+			}
+		})
+	}
+}
@@ -0,0 +1,290 @@
+package headermapper
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// JWTClaimsConfig configures mapping the claims of a JWT bearer token to
+// gRPC metadata, decoding the token once for all claims. Without a verifier,
+// JWKS URL or keys, signatures are not verified, so use it behind a gateway
+// or identity proxy that validates tokens.
+type JWTClaimsConfig struct {
+	// Header carries the token, with or without a Bearer prefix (default
+	// Authorization)
+	Header string `json:"header,omitempty" yaml:"header,omitempty"`
+	// Claims maps claim names to metadata keys; a name with dots reaches
+	// into nested objects unless a claim has the full name, e.g.
+	// realm_access.roles. Array claims become several metadata values.
+	Claims map[string]string `json:"claims" yaml:"claims"`
+	// JWKSURL verifies tokens with the keys of a JSON Web Key Set
+	JWKSURL string `json:"jwks_url,omitempty" yaml:"jwks_url,omitempty"`
+	// Keys verifies tokens with PEM-encoded public keys by key ID
+	Keys map[string]string `json:"keys,omitempty" yaml:"keys,omitempty"`
+	// OnFailure is strip (default) or reject, for tokens failing verification
+	OnFailure JWTFailurePolicy `json:"on_failure,omitempty" yaml:"on_failure,omitempty"`
+	// Verifier verifies tokens, taking precedence over JWKSURL and Keys
+	Verifier JWTVerifier `json:"-" yaml:"-"`
+}
+
+// ExtractJWTClaims returns a JWT claims preset mapping the given claims,
+// keyed by claim name, to metadata keys
+//
+//	headermapper.ExtractJWTClaims(map[string]string{"sub": "user-id", "tenant": "tenant-id", "scope": "scopes"})
+func ExtractJWTClaims(claims map[string]string) *JWTClaimsConfig {
+	return &JWTClaimsConfig{Claims: claims}
+}
+
+func (jc *JWTClaimsConfig) header() string {
+	if jc.Header == "" {
+		return "Authorization"
+	}
+	return jc.Header
+}
+
+// verifier returns the configured verifier, or nil when tokens are not
+// verified
+func (jc *JWTClaimsConfig) verifier() (JWTVerifier, error) {
+	switch {
+	case jc.Verifier != nil:
+		return jc.Verifier, nil
+	case jc.JWKSURL != "":
+		return NewJWKSVerifier(jc.JWKSURL, 0), nil
+	case len(jc.Keys) > 0:
+		return ParseJWTKeys(jc.Keys)
+	}
+	return nil, nil
+}
+
+// validate checks the header name, metadata keys and verification settings
+func (jc *JWTClaimsConfig) validate() error {
+	if !validHeaderName(jc.header()) {
+		return fmt.Errorf("jwt claims: invalid header name: %q", jc.Header)
+	}
+	if len(jc.Claims) == 0 {
+		return fmt.Errorf("jwt claims: claims cannot be empty")
+	}
+	for claim, key := range jc.Claims {
+		if claim == "" {
+			return fmt.Errorf("jwt claims: empty claim name")
+		}
+		key = strings.ToLower(key)
+		if !validMetadataKey(key) || strings.HasSuffix(key, "-bin") {
+			return fmt.Errorf("jwt claims: invalid metadata key for claim %s: %q", claim, key)
+		}
+	}
+
+	if err := jc.OnFailure.validate(); err != nil {
+		return err
+	}
+	if jc.JWKSURL != "" {
+		if len(jc.Keys) > 0 {
+			return fmt.Errorf("jwt claims: jwks_url and keys cannot both be set")
+		}
+		if err := validateJWKSURL(jc.JWKSURL); err != nil {
+			return err
+		}
+	}
+	if _, err := ParseJWTKeys(jc.Keys); err != nil {
+		return fmt.Errorf("jwt claims: %w", err)
+	}
+	if jc.OnFailure == JWTFailureReject && jc.Verifier == nil && jc.JWKSURL == "" && len(jc.Keys) == 0 {
+		return fmt.Errorf("jwt claims: on_failure reject requires a verifier, jwks_url or keys")
+	}
+	return nil
+}
+
+// jwtClaimKey pairs a claim name with its metadata key
+type jwtClaimKey struct {
+	claim string
+	key   string
+}
+
+// jwtClaims maps the claims of request tokens into metadata
+type jwtClaims struct {
+	header   string
+	claims   []jwtClaimKey
+	verifier JWTVerifier
+	hm       *HeaderMapper
+}
+
+func newJWTClaims(config *JWTClaimsConfig, hm *HeaderMapper) *jwtClaims {
+	verifier, err := config.verifier()
+	if err != nil {
+		// Invalid keys are reported by Validate; tokens fail verification
+		verifier = invalidJWTVerifier{err}
+	}
+	jc := &jwtClaims{header: http.CanonicalHeaderKey(config.header()), verifier: verifier, hm: hm}
+	for claim, key := range config.Claims {
+		jc.claims = append(jc.claims, jwtClaimKey{claim: claim, key: strings.ToLower(key)})
+	}
+	// Claims are applied in a stable order, as map iteration is random
+	sort.Slice(jc.claims, func(i, j int) bool { return jc.claims[i].claim < jc.claims[j].claim })
+	return jc
+}
+
+// parse returns the claims of a token, verified when a verifier is set. The
+// checks and annotator of a request share one verification.
+func (jc *jwtClaims) parse(ctx context.Context, token string) (map[string]interface{}, error) {
+	if jc.verifier == nil {
+		return parseJWTClaims(token)
+	}
+	verify := func() (map[string]interface{}, error) { return jc.verifier.Verify(ctx, token) }
+	if memo, ok := ctx.Value(requestMemoKey{}).(*requestMemo); ok {
+		return memo.verifyToken(token, verify)
+	}
+	return verify()
+}
+
+// check rejects requests whose token fails verification
+func (jc *jwtClaims) check(w http.ResponseWriter, req *http.Request) error {
+	token := req.Header.Get(jc.header)
+	if token == "" {
+		return nil
+	}
+	if _, err := jc.parse(req.Context(), token); err != nil {
+		jc.hm.log().Debug("Rejecting invalid JWT:", err)
+		return rejectf(codes.Unauthenticated, "invalid token")
+	}
+	return nil
+}
+
+// annotate sets the metadata of the mapped claims of the request token,
+// replacing values mappings placed under the same keys. With a verifier,
+// those values are removed when the token fails verification.
+func (jc *jwtClaims) annotate(req *http.Request, md metadata.MD) {
+	token := req.Header.Get(jc.header)
+	if token == "" {
+		return
+	}
+	claims, err := jc.parse(req.Context(), token)
+	if err != nil {
+		jc.hm.log().Debug("Ignoring invalid JWT:", err)
+		if jc.verifier != nil {
+			for _, c := range jc.claims {
+				md.Delete(c.key)
+			}
+		}
+		return
+	}
+	for _, c := range jc.claims {
+		if values := jwtClaim(claims, c.claim); len(values) > 0 {
+			md[c.key] = values
+		}
+	}
+}
+
+// ExtractJWTClaim returns a transform reading a claim from a JWT, with or
+// without a Bearer prefix, without verifying its signature. Array claims are
+// joined with commas; invalid tokens and missing claims give an empty value,
+// which skips an incoming mapping.
+//
+//	AddIncomingMapping("Authorization", "user-id").WithTransform(headermapper.ExtractJWTClaim("sub"))
+func ExtractJWTClaim(claim string) TransformFunc {
+	return func(value string) string {
+		claims, err := parseJWTClaims(value)
+		if err != nil {
+			return ""
+		}
+		return joinClaim(claims, claim)
+	}
+}
+
+// joinClaim returns the values of a claim joined with commas
+func joinClaim(claims map[string]interface{}, claim string) string {
+	return strings.Join(jwtClaim(claims, claim), ",")
+}
+
+// bearerToken returns a token without its Bearer prefix
+func bearerToken(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) > 7 && strings.EqualFold(value[:7], "bearer ") {
+		value = strings.TrimSpace(value[7:])
+	}
+	return value
+}
+
+// parseJWTClaims decodes the claims of a compact JWT without verifying it
+func parseJWTClaims(token string) (map[string]interface{}, error) {
+	parts := strings.Split(bearerToken(token), ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, fmt.Errorf("malformed JWT payload: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var claims map[string]interface{}
+	if err := decoder.Decode(&claims); err != nil {
+		return nil, fmt.Errorf("malformed JWT claims: %w", err)
+	}
+	return claims, nil
+}
+
+// jwtClaim returns the values of a claim: strings, numbers and booleans as
+// text, arrays as one value per element and objects as JSON
+func jwtClaim(claims map[string]interface{}, name string) []string {
+	value, ok := claims[name]
+	if !ok {
+		// Namespaced claims such as https://example.com/tenant contain
+		// dots, so nested paths are only tried without an exact match
+		var current interface{} = claims
+		for _, part := range strings.Split(name, ".") {
+			object, isObject := current.(map[string]interface{})
+			if !isObject {
+				return nil
+			}
+			if current, ok = object[part]; !ok {
+				return nil
+			}
+		}
+		value = current
+	}
+
+	if array, ok := value.([]interface{}); ok {
+		values := make([]string, 0, len(array))
+		for _, element := range array {
+			if text, ok := claimText(element); ok {
+				values = append(values, text)
+			}
+		}
+		return values
+	}
+	if text, ok := claimText(value); ok {
+		return []string{text}
+	}
+	return nil
+}
+
+// claimText formats a single claim value, reporting false for null and
+// empty values
+func claimText(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, v != ""
+	case json.Number:
+		return v.String(), true
+	case bool:
+		if v {
+			return "true", true
+		}
+		return "false", true
+	default:
+		data, err := json.Marshal(v)
+		return string(data), err == nil
+	}
+}
@@ -0,0 +1,132 @@
+package headermapper
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// unsignedJWT builds a compact JWT with the given claims and a fake signature
+func unsignedJWT(t *testing.T, claims map[string]interface{}) string {
+	t.Helper()
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`))
+	return header + "." + base64.RawURLEncoding.EncodeToString(payload) + ".sig"
+}
+
+func TestExtractJWTClaim(t *testing.T) {
+	token := unsignedJWT(t, map[string]interface{}{
+		"sub":                        "user-42",
+		"exp":                        1700000000,
+		"admin":                      true,
+		"scp":                        []string{"read", "write"},
+		"realm_access":               map[string]interface{}{"roles": []string{"ops"}},
+		"https://example.com/tenant": "acme",
+		"empty":                      "",
+	})
+
+	tests := []struct {
+		claim string
+		value string
+		want  string
+	}{
+		{"sub", "Bearer " + token, "user-42"},
+		{"sub", token, "user-42"},
+		{"exp", token, "1700000000"},
+		{"admin", token, "true"},
+		{"scp", token, "read,write"},
+		{"realm_access.roles", token, "ops"},
+		{"realm_access", token, `{"roles":["ops"]}`},
+		{"https://example.com/tenant", token, "acme"},
+		{"empty", token, ""},
+		{"missing", token, ""},
+		{"sub", "Bearer not-a-jwt", ""},
+		{"sub", "a.!!!.c", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.claim, func(t *testing.T) {
+			if got := ExtractJWTClaim(tt.claim)(tt.value); got != tt.want {
+				t.Errorf("ExtractJWTClaim(%q) = %q, want %q", tt.claim, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMapJWTClaims(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-Tenant", "tenant-id").
+		MapJWTClaims(ExtractJWTClaims(map[string]string{"sub": "user-id", "tenant": "tenant-id", "scp": "scopes"})).
+		Build()
+	if err := mapper.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	token := unsignedJWT(t, map[string]interface{}{"sub": "user-42", "tenant": "acme", "scp": []string{"read", "write"}})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("X-Tenant", "spoofed")
+
+	md := mapper.MetadataAnnotator()(context.Background(), req)
+	want := map[string][]string{"user-id": {"user-42"}, "tenant-id": {"acme"}, "scopes": {"read", "write"}}
+	for key, values := range want {
+		if got := md.Get(key); !reflect.DeepEqual(got, values) {
+			t.Errorf("%s = %v, want %v", key, got, values)
+		}
+	}
+
+	// Claim keys cannot be forwarded from client headers
+	if _, ok := mapper.HeaderMatcher()("Grpc-Metadata-User-Id"); ok {
+		t.Error("HeaderMatcher forwarded a claim key")
+	}
+}
+
+func TestMapJWTClaims_NoToken(t *testing.T) {
+	mapper := NewBuilder().MapJWTClaims(ExtractJWTClaims(map[string]string{"sub": "user-id"})).Build()
+	for _, auth := range []string{"", "Basic dXNlcjpwYXNz"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		if md := mapper.MetadataAnnotator()(context.Background(), req); len(md.Get("user-id")) > 0 {
+			t.Errorf("Authorization %q: user-id = %v, want none", auth, md.Get("user-id"))
+		}
+	}
+}
+
+func TestJWTClaimsConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *JWTClaimsConfig
+		wantErr string
+	}{
+		{"valid", &JWTClaimsConfig{Header: "X-Id-Token", Claims: map[string]string{"sub": "user-id"}}, ""},
+		{"no claims", &JWTClaimsConfig{}, "claims cannot be empty"},
+		{"invalid key", &JWTClaimsConfig{Claims: map[string]string{"sub": "User Id"}}, "invalid metadata key"},
+		{"binary key", &JWTClaimsConfig{Claims: map[string]string{"sub": "user-bin"}}, "invalid metadata key"},
+		{"invalid header", &JWTClaimsConfig{Header: "Bad Header", Claims: map[string]string{"sub": "user-id"}}, "invalid header name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,384 @@
+package headermapper
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/sync/singleflight"
+)
+
+// JWTFailurePolicy determines how requests whose token fails verification
+// are handled
+type JWTFailurePolicy string
+
+const (
+	// JWTFailureStrip forwards the request without the claim metadata (default)
+	JWTFailureStrip JWTFailurePolicy = "strip"
+	// JWTFailureReject rejects the request as unauthenticated
+	JWTFailureReject JWTFailurePolicy = "reject"
+)
+
+// validate checks the policy name
+func (p JWTFailurePolicy) validate() error {
+	switch p {
+	case "", JWTFailureStrip, JWTFailureReject:
+		return nil
+	}
+	return fmt.Errorf("jwt claims: unknown failure policy: %s", p)
+}
+
+// JWTVerifier verifies the signature and time claims of a JWT and returns
+// its claims. Numbers are returned as json.Number.
+type JWTVerifier interface {
+	Verify(ctx context.Context, token string) (map[string]interface{}, error)
+}
+
+// jwtAlgorithms lists the accepted signing algorithms; "none" is never
+// accepted, and each algorithm only verifies with a key of its type
+var jwtAlgorithms = []string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+	"EdDSA",
+	"HS256", "HS384", "HS512",
+}
+
+// verifyJWT verifies a token, with or without a Bearer prefix, with the key
+// of its key ID, or any key of keys when it has none
+func verifyJWT(token string, keys func(kid string) (map[string]interface{}, error)) (map[string]interface{}, error) {
+	// Parsers set their validator on first use, so one is created per token
+	parser := jwt.NewParser(jwt.WithValidMethods(jwtAlgorithms), jwt.WithJSONNumber())
+	parsed, err := parser.Parse(bearerToken(token), func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		found, err := keys(kid)
+		if err != nil {
+			return nil, err
+		}
+		if kid != "" {
+			key, ok := found[kid]
+			if !ok {
+				return nil, fmt.Errorf("unknown key ID: %s", kid)
+			}
+			return key, nil
+		}
+		set := jwt.VerificationKeySet{}
+		for _, key := range found {
+			set.Keys = append(set.Keys, key)
+		}
+		return set, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parsed.Claims.(jwt.MapClaims), nil
+}
+
+// invalidJWTVerifier fails all tokens when the configured keys are invalid,
+// so claims are not mapped unverified
+type invalidJWTVerifier struct {
+	err error
+}
+
+func (v invalidJWTVerifier) Verify(ctx context.Context, token string) (map[string]interface{}, error) {
+	return nil, v.err
+}
+
+// StaticJWTVerifier is a JWTVerifier holding fixed keys by key ID. Tokens
+// naming a key ID are verified with that key, others with each key.
+type StaticJWTVerifier struct {
+	keys map[string]interface{}
+}
+
+// NewStaticJWTVerifier creates a verifier from keys mapping key IDs to
+// *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey values, or []byte
+// HMAC secrets
+func NewStaticJWTVerifier(keys map[string]interface{}) *StaticJWTVerifier {
+	return &StaticJWTVerifier{keys: keys}
+}
+
+// ParseJWTKeys creates a verifier from PEM-encoded PKIX public keys by key ID
+func ParseJWTKeys(keys map[string]string) (*StaticJWTVerifier, error) {
+	parsed := make(map[string]interface{}, len(keys))
+	for id, key := range keys {
+		block, _ := pem.Decode([]byte(key))
+		if block == nil {
+			return nil, fmt.Errorf("jwt key %s: invalid PEM", id)
+		}
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("jwt key %s: %w", id, err)
+		}
+		parsed[id] = pub
+	}
+	return NewStaticJWTVerifier(parsed), nil
+}
+
+// Verify implements JWTVerifier
+func (v *StaticJWTVerifier) Verify(ctx context.Context, token string) (map[string]interface{}, error) {
+	return verifyJWT(token, func(string) (map[string]interface{}, error) { return v.keys, nil })
+}
+
+const (
+	// defaultJWKSRefresh is how long fetched key sets are used
+	defaultJWKSRefresh = time.Hour
+	// jwksMinRefetch bounds how often unknown key IDs refetch the key set
+	jwksMinRefetch = time.Minute
+	// maxJWKSSize bounds the key set documents read
+	maxJWKSSize = 1 << 20
+)
+
+// JWKSVerifier is a JWTVerifier fetching keys from a JSON Web Key Set URL.
+// Keys are refetched after the refresh interval, and when a token names an
+// unknown key ID at most once a minute, so rotated keys are picked up.
+// Concurrent requests share a single refetch.
+type JWKSVerifier struct {
+	url     string
+	refresh time.Duration
+	client  *http.Client
+	group   singleflight.Group
+
+	mu      sync.RWMutex
+	keys    map[string]interface{}
+	fetched time.Time
+	failed  time.Time
+	failure error
+}
+
+// NewJWKSVerifier creates a verifier for the key set at url, refetched after
+// refresh (default 1h)
+func NewJWKSVerifier(url string, refresh time.Duration) *JWKSVerifier {
+	if refresh <= 0 {
+		refresh = defaultJWKSRefresh
+	}
+	return &JWKSVerifier{url: url, refresh: refresh, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Verify implements JWTVerifier
+func (v *JWKSVerifier) Verify(ctx context.Context, token string) (map[string]interface{}, error) {
+	return verifyJWT(token, func(kid string) (map[string]interface{}, error) { return v.keySet(ctx, kid) })
+}
+
+// keySet returns the cached keys, fetching them when stale or missing kid.
+// A failed fetch keeps the previous keys and is retried at most once per
+// minimum refetch interval.
+func (v *JWKSVerifier) keySet(ctx context.Context, kid string) (map[string]interface{}, error) {
+	if keys, ok := v.cached(kid); ok {
+		return keys, nil
+	}
+	if keys, err := v.lastFailure(); err != nil {
+		if keys != nil {
+			return keys, nil
+		}
+		return nil, err
+	}
+
+	// The fetch is shared by the waiting requests, so it is not bound to
+	// the context of the one starting it
+	result := v.group.DoChan("", func() (interface{}, error) {
+		keys, err := v.fetch(context.Background())
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		if err != nil {
+			v.failed, v.failure = time.Now(), err
+			return nil, err
+		}
+		v.keys, v.fetched, v.failure = keys, time.Now(), nil
+		return keys, nil
+	})
+
+	var err error
+	select {
+	case r := <-result:
+		if r.Err == nil {
+			return r.Val.(map[string]interface{}), nil
+		}
+		err = r.Err
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if v.keys != nil {
+		return v.keys, nil
+	}
+	return nil, err
+}
+
+// cached returns the keys and whether they can be used for kid without a
+// refetch
+func (v *JWKSVerifier) cached(kid string) (map[string]interface{}, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	age := time.Since(v.fetched)
+	_, known := v.keys[kid]
+	return v.keys, v.keys != nil && age < v.refresh && (kid == "" || known || age < jwksMinRefetch)
+}
+
+// lastFailure returns the keys and the error of a fetch that failed within
+// the minimum refetch interval
+func (v *JWKSVerifier) lastFailure() (map[string]interface{}, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if v.failure == nil || time.Since(v.failed) >= jwksMinRefetch {
+		return nil, nil
+	}
+	return v.keys, v.failure
+}
+
+func (v *JWKSVerifier) fetch(ctx context.Context) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch JWKS: status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxJWKSSize))
+	if err != nil {
+		return nil, fmt.Errorf("fetch JWKS: %w", err)
+	}
+	return ParseJWKS(data)
+}
+
+// jsonWebKey holds the members of a JSON Web Key used for verification
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// ParseJWKS parses the RSA, EC and Ed25519 signing keys of a JSON Web Key
+// Set by key ID, skipping encryption and unsupported keys
+func ParseJWKS(data []byte) (map[string]interface{}, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWKS key %s: %w", jwk.Kid, err)
+		}
+		if key != nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+// publicKey returns the key, or nil for unsupported key types
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := jwkInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := jwkInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, nil
+		}
+		x, err := jwkInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := jwkInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point not on curve %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, nil
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, nil
+}
+
+// jwkInt decodes a base64url big-endian integer member
+func jwkInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(data) == 0 {
+		return nil, fmt.Errorf("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// ExtractVerifiedJWTClaim is ExtractJWTClaim for tokens verified by
+// verifier; tokens failing verification give an empty value
+func ExtractVerifiedJWTClaim(claim string, verifier JWTVerifier) TransformFunc {
+	return func(value string) string {
+		claims, err := verifier.Verify(context.Background(), value)
+		if err != nil {
+			return ""
+		}
+		return joinClaim(claims, claim)
+	}
+}
+
+// validateJWKSURL checks that a key set URL is absolute HTTP or HTTPS
+func validateJWKSURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("jwt claims: invalid JWKS URL: %q", raw)
+	}
+	return nil
+}
@@ -0,0 +1,396 @@
+package headermapper
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func signJWT(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA", "kid": kid, "use": "sig",
+		"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func TestStaticJWTVerifier(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	secret := []byte("0123456789abcdef")
+	verifier := NewStaticJWTVerifier(map[string]interface{}{"rsa": &rsaKey.PublicKey, "ec": &ecKey.PublicKey, "hmac": secret})
+
+	claims := jwt.MapClaims{"sub": "user-42"}
+	tests := []struct {
+		name  string
+		token string
+		want  string
+	}{
+		{"rsa", signJWT(t, jwt.SigningMethodRS256, "rsa", rsaKey, claims), "user-42"},
+		{"bearer", "Bearer " + signJWT(t, jwt.SigningMethodRS256, "rsa", rsaKey, claims), "user-42"},
+		{"ecdsa", signJWT(t, jwt.SigningMethodES256, "ec", ecKey, claims), "user-42"},
+		{"hmac", signJWT(t, jwt.SigningMethodHS256, "hmac", secret, claims), "user-42"},
+		{"no key id", signJWT(t, jwt.SigningMethodRS256, "", rsaKey, claims), "user-42"},
+		{"wrong key", signJWT(t, jwt.SigningMethodRS256, "rsa", otherKey, claims), ""},
+		{"unknown key id", signJWT(t, jwt.SigningMethodRS256, "other", rsaKey, claims), ""},
+		{"key type mismatch", signJWT(t, jwt.SigningMethodHS256, "rsa", secret, claims), ""},
+		{"expired", signJWT(t, jwt.SigningMethodRS256, "rsa", rsaKey, jwt.MapClaims{"sub": "user-42", "exp": time.Now().Add(-time.Minute).Unix()}), ""},
+		{"unsigned", unsignedJWT(t, map[string]interface{}{"sub": "user-42"}), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractVerifiedJWTClaim("sub", verifier)(tt.token); got != tt.want {
+				t.Errorf("ExtractVerifiedJWTClaim() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseJWTKeys(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	pemKey := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+
+	verifier, err := ParseJWTKeys(map[string]string{"k1": pemKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := verifier.Verify(context.Background(), signJWT(t, jwt.SigningMethodES256, "k1", key, jwt.MapClaims{"n": 7}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := claims["n"].(json.Number); !ok || got != "7" {
+		t.Errorf("claim n = %#v, want json.Number 7", claims["n"])
+	}
+
+	if _, err := ParseJWTKeys(map[string]string{"k1": "not pem"}); err == nil {
+		t.Error("ParseJWTKeys() accepted an invalid key")
+	}
+}
+
+func TestJWKSVerifier_Rotation(t *testing.T) {
+	oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	var jwks atomic.Value
+	var fetches atomic.Int32
+	jwks.Store([]map[string]string{rsaJWK("old", &oldKey.PublicKey)})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fetches.Add(1)
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": jwks.Load()})
+	}))
+	defer server.Close()
+
+	verifier := NewJWKSVerifier(server.URL, 0)
+	ctx := context.Background()
+	if _, err := verifier.Verify(ctx, signJWT(t, jwt.SigningMethodRS256, "old", oldKey, jwt.MapClaims{})); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if _, err := verifier.Verify(ctx, signJWT(t, jwt.SigningMethodRS256, "old", oldKey, jwt.MapClaims{})); err != nil || fetches.Load() != 1 {
+		t.Fatalf("Verify() error = %v, fetches = %d, want cached key set", err, fetches.Load())
+	}
+
+	// A new key ID is fetched once the minimum refetch interval has passed
+	jwks.Store([]map[string]string{rsaJWK("old", &oldKey.PublicKey), rsaJWK("new", &newKey.PublicKey)})
+	rotated := signJWT(t, jwt.SigningMethodRS256, "new", newKey, jwt.MapClaims{})
+	if _, err := verifier.Verify(ctx, rotated); err == nil {
+		t.Fatal("Verify() refetched the key set within the minimum interval")
+	}
+	verifier.mu.Lock()
+	verifier.fetched = time.Now().Add(-jwksMinRefetch)
+	verifier.mu.Unlock()
+	if _, err := verifier.Verify(ctx, rotated); err != nil || fetches.Load() != 2 {
+		t.Errorf("Verify() error = %v, fetches = %d, want rotated key", err, fetches.Load())
+	}
+}
+
+func TestParseJWKS(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecJWK := map[string]string{
+		"kty": "EC", "kid": "ec", "crv": "P-256",
+		"x": base64.RawURLEncoding.EncodeToString(ecKey.X.FillBytes(make([]byte, 32))),
+		"y": base64.RawURLEncoding.EncodeToString(ecKey.Y.FillBytes(make([]byte, 32))),
+	}
+	encJWK := rsaJWK("enc", &rsaKey.PublicKey)
+	encJWK["use"] = "enc"
+	data, _ := json.Marshal(map[string]interface{}{"keys": []interface{}{
+		rsaJWK("rsa", &rsaKey.PublicKey), ecJWK, encJWK, map[string]string{"kty": "oct", "kid": "oct", "k": "c2VjcmV0"},
+	}})
+
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys["rsa"] == nil || keys["ec"] == nil {
+		t.Errorf("ParseJWKS() keys = %v, want rsa and ec", keys)
+	}
+
+	ecJWK["y"] = ecJWK["x"]
+	data, _ = json.Marshal(map[string]interface{}{"keys": []interface{}{ecJWK}})
+	if _, err := ParseJWKS(data); err == nil {
+		t.Error("ParseJWKS() accepted a point off the curve")
+	}
+}
+
+func TestJWKSVerifier_FailedFetchBackoff(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	var failing atomic.Bool
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fetches.Add(1)
+		if failing.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{rsaJWK("k1", &key.PublicKey)}})
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	token := signJWT(t, jwt.SigningMethodRS256, "k1", key, jwt.MapClaims{})
+
+	// Without keys, requests fail without refetching until the interval passes
+	failing.Store(true)
+	verifier := NewJWKSVerifier(server.URL, time.Hour)
+	for i := 0; i < 5; i++ {
+		if _, err := verifier.Verify(ctx, token); err == nil {
+			t.Fatal("Verify() succeeded without a key set")
+		}
+	}
+	if got := fetches.Load(); got != 1 {
+		t.Fatalf("fetches = %d, want 1 while backing off", got)
+	}
+	verifier.mu.Lock()
+	verifier.failed = time.Now().Add(-jwksMinRefetch)
+	verifier.mu.Unlock()
+	failing.Store(false)
+	if _, err := verifier.Verify(ctx, token); err != nil || fetches.Load() != 2 {
+		t.Fatalf("Verify() error = %v, fetches = %d, want a retry after the interval", err, fetches.Load())
+	}
+
+	// Stale keys are used without refetching while the endpoint is down
+	failing.Store(true)
+	verifier.mu.Lock()
+	verifier.fetched = time.Now().Add(-2 * time.Hour)
+	verifier.mu.Unlock()
+	for i := 0; i < 5; i++ {
+		if _, err := verifier.Verify(ctx, token); err != nil {
+			t.Fatalf("Verify() error = %v, want stale keys", err)
+		}
+	}
+	if got := fetches.Load(); got != 3 {
+		t.Errorf("fetches = %d, want 3 while backing off", got)
+	}
+}
+
+func TestJWKSVerifier_SharedFetch(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	release := make(chan struct{})
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fetches.Add(1)
+		<-release
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{rsaJWK("k1", &key.PublicKey)}})
+	}))
+	defer server.Close()
+
+	verifier := NewJWKSVerifier(server.URL, 0)
+	token := signJWT(t, jwt.SigningMethodRS256, "k1", key, jwt.MapClaims{})
+
+	// A canceled request gives up waiting without failing the fetch
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := verifier.Verify(canceled, token); err == nil {
+		t.Error("Verify() with a canceled context should fail before the keys arrive")
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := verifier.Verify(context.Background(), token)
+			errs <- err
+		}()
+	}
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Verify() error = %v", err)
+		}
+	}
+	if got := fetches.Load(); got != 1 {
+		t.Errorf("fetches = %d, want 1", got)
+	}
+}
+
+// countingJWTVerifier counts the tokens it verifies
+type countingJWTVerifier struct {
+	JWTVerifier
+	calls atomic.Int32
+}
+
+func (v *countingJWTVerifier) Verify(ctx context.Context, token string) (map[string]interface{}, error) {
+	v.calls.Add(1)
+	return v.JWTVerifier.Verify(ctx, token)
+}
+
+func TestMapJWTClaims_VerifiedOnce(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	verifier := &countingJWTVerifier{JWTVerifier: NewStaticJWTVerifier(map[string]interface{}{"k1": &key.PublicKey})}
+	config := ExtractJWTClaims(map[string]string{"sub": "user-id"})
+	config.Verifier = verifier
+	config.OnFailure = JWTFailureReject
+	mapper := NewBuilder().
+		MapJWTClaims(config).
+		Authorize(&AuthorizationConfig{
+			Rules: []AccessRule{{Paths: []string{"/v1/*"}, Require: map[string][]string{"user-id": {"user-42"}}}},
+		}).
+		RateLimit(&RateLimitConfig{KeyMetadata: []string{"user-id"}, Rate: 1, Burst: 10}).
+		Build()
+
+	var userID string
+	handler := mapper.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		userID = firstValue(mapper.MetadataAnnotator()(req.Context(), req), "user-id")
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/v1/orders", nil)
+	req.Header.Set("Authorization", "Bearer "+signJWT(t, jwt.SigningMethodRS256, "k1", key, jwt.MapClaims{"sub": "user-42"}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || userID != "user-42" {
+		t.Fatalf("status = %d, user-id = %q", rec.Code, userID)
+	}
+	if got := verifier.calls.Load(); got != 1 {
+		t.Errorf("Verify() calls = %d, want 1", got)
+	}
+}
+
+func TestMapJWTClaims_Verified(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	forged, _ := rsa.GenerateKey(rand.Reader, 2048)
+	valid := signJWT(t, jwt.SigningMethodRS256, "k1", key, jwt.MapClaims{"sub": "user-42"})
+	invalid := signJWT(t, jwt.SigningMethodRS256, "k1", forged, jwt.MapClaims{"sub": "admin"})
+
+	tests := []struct {
+		name      string
+		onFailure JWTFailurePolicy
+		token     string
+		status    int
+		userID    []string
+	}{
+		{"valid", JWTFailureReject, valid, http.StatusOK, []string{"user-42"}},
+		{"strip", JWTFailureStrip, invalid, http.StatusOK, nil},
+		{"strip default", "", invalid, http.StatusOK, nil},
+		{"reject", JWTFailureReject, invalid, http.StatusUnauthorized, nil},
+		{"no token", JWTFailureReject, "", http.StatusOK, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := ExtractJWTClaims(map[string]string{"sub": "user-id"})
+			config.Verifier = NewStaticJWTVerifier(map[string]interface{}{"k1": &key.PublicKey})
+			config.OnFailure = tt.onFailure
+			mapper := NewBuilder().
+				AddIncomingMapping("X-User", "user-id").
+				MapJWTClaims(config).
+				Build()
+			if err := mapper.Validate(); err != nil {
+				t.Fatal(err)
+			}
+
+			var userID []string
+			handler := mapper.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				userID = mapper.MetadataAnnotator()(req.Context(), req).Get("user-id")
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("X-User", "spoofed")
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			if tt.token == "" {
+				// Without a token the mapping applies as usual
+				tt.userID = []string{"spoofed"}
+			}
+			if strings.Join(userID, ",") != strings.Join(tt.userID, ",") {
+				t.Errorf("user-id = %v, want %v", userID, tt.userID)
+			}
+		})
+	}
+}
+
+func TestJWTClaimsConfig_ValidateVerification(t *testing.T) {
+	claims := map[string]string{"sub": "user-id"}
+	tests := []struct {
+		name    string
+		config  *JWTClaimsConfig
+		wantErr string
+	}{
+		{"jwks", &JWTClaimsConfig{Claims: claims, JWKSURL: "https://idp.example.com/.well-known/jwks.json", OnFailure: JWTFailureReject}, ""},
+		{"invalid jwks url", &JWTClaimsConfig{Claims: claims, JWKSURL: "/jwks.json"}, "invalid JWKS URL"},
+		{"jwks and keys", &JWTClaimsConfig{Claims: claims, JWKSURL: "https://idp.example.com/jwks", Keys: map[string]string{"k": "x"}}, "cannot both be set"},
+		{"invalid key", &JWTClaimsConfig{Claims: claims, Keys: map[string]string{"k": "x"}}, "invalid PEM"},
+		{"unknown policy", &JWTClaimsConfig{Claims: claims, OnFailure: "drop"}, "unknown failure policy"},
+		{"reject unverified", &JWTClaimsConfig{Claims: claims, OnFailure: JWTFailureReject}, "requires a verifier"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package headermapper
+
+import (
+	"golang.org/x/text/language"
+)
+
+// Metadata keys of LanguageMatcher.Mappings
+const (
+	// LocaleKey carries the negotiated language tag to backends
+	LocaleKey = "locale"
+	// ContentLanguageKey is set by backends to the language of the response
+	ContentLanguageKey = "content-language"
+)
+
+// LanguageMatcher negotiates the language of requests from their
+// Accept-Language header against the languages a service supports
+type LanguageMatcher struct {
+	supported []language.Tag
+	matcher   language.Matcher
+}
+
+// NewLanguageMatcher creates a matcher for the supported languages; the first
+// is used when nothing else matches
+func NewLanguageMatcher(supported ...language.Tag) *LanguageMatcher {
+	if len(supported) == 0 {
+		supported = []language.Tag{language.English}
+	}
+	return &LanguageMatcher{
+		supported: supported,
+		matcher:   language.NewMatcher(supported),
+	}
+}
+
+// Negotiate returns the supported language best matching an Accept-Language
+// header value; malformed values yield the default language
+func (m *LanguageMatcher) Negotiate(acceptLanguage string) language.Tag {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return m.supported[0]
+	}
+	_, index, _ := m.matcher.Match(tags...)
+	return m.supported[index]
+}
+
+// Transform returns a TransformFunc replacing an Accept-Language value with
+// the negotiated language tag
+func (m *LanguageMatcher) Transform() TransformFunc {
+	return func(value string) string {
+		return m.Negotiate(value).String()
+	}
+}
+
+// Mappings returns an incoming mapping of Accept-Language to the negotiated
+// tag in LocaleKey, defaulting to the first supported language, and an
+// outgoing mapping of ContentLanguageKey to Content-Language for backends to
+// report the language they responded in:
+//
+//	locale, _ := headermapper.Get(ctx, headermapper.LocaleKey)
+//	grpc.SetHeader(ctx, metadata.Pairs(headermapper.ContentLanguageKey, locale))
+func (m *LanguageMatcher) Mappings() []HeaderMapping {
+	return []HeaderMapping{
+		{
+			HTTPHeader:     "Accept-Language",
+			GRPCMetadata:   LocaleKey,
+			Direction:      Incoming,
+			Transform:      m.Transform(),
+			DefaultValue:   m.supported[0].String(),
+			CacheTransform: true,
+		},
+		{
+			HTTPHeader:   "Content-Language",
+			GRPCMetadata: ContentLanguageKey,
+			Direction:    Outgoing,
+		},
+	}
+}
@@ -0,0 +1,76 @@
+package headermapper
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"golang.org/x/text/language"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestLanguageMatcher_Negotiate(t *testing.T) {
+	matcher := NewLanguageMatcher(language.English, language.French, language.BrazilianPortuguese)
+
+	tests := []struct {
+		name     string
+		header   string
+		expected string
+	}{
+		{"exact", "fr", "fr"},
+		{"regional variant", "fr-CA", "fr"},
+		{"quality order", "de;q=0.9, pt-BR;q=0.8, fr;q=0.5", "pt-BR"},
+		{"unsupported", "ja", "en"},
+		{"malformed", "!!", "en"},
+		{"empty", "", "en"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matcher.Negotiate(tt.header).String(); got != tt.expected {
+				t.Errorf("Negotiate(%q) = %s, want %s", tt.header, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLanguageMatcher_Mappings(t *testing.T) {
+	matcher := NewLanguageMatcher(language.English, language.German)
+	mapper := NewBuilder().AddMappings(matcher.Mappings()...).Build()
+	if err := mapper.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		header   string
+		expected string
+	}{
+		{"negotiated", "de-AT, en;q=0.5", "de"},
+		{"missing header", "", "en"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/test", nil)
+			if tt.header != "" {
+				req.Header.Set("Accept-Language", tt.header)
+			}
+			md := mapper.MetadataAnnotator()(context.Background(), req)
+			if got := md.Get(LocaleKey); len(got) != 1 || got[0] != tt.expected {
+				t.Errorf("locale = %v, want %s", got, tt.expected)
+			}
+		})
+	}
+
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{
+		HeaderMD: metadata.Pairs(ContentLanguageKey, "de"),
+	})
+	w := httptest.NewRecorder()
+	if err := mapper.ResponseModifier()(ctx, w, nil); err != nil {
+		t.Fatalf("ResponseModifier() error = %v", err)
+	}
+	if got := w.Header().Get("Content-Language"); got != "de" {
+		t.Errorf("Content-Language = %q", got)
+	}
+}
@@ -0,0 +1,80 @@
+package headermapper
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Metadata keys set by long-running operation endpoints for LROMappings
+const (
+	OperationNameKey       = "operation-name"
+	OperationStatusKey     = "operation-status"
+	OperationRetryAfterKey = "operation-retry-after"
+)
+
+// LROMappings returns outgoing mappings exposing long-running operation
+// state following the Azure and Google LRO conventions: the operation
+// resource name becomes an Operation-Location polling URL built from
+// pollingTemplate (see OperationURL), the suggested polling interval a
+// Retry-After header and the operation status X-Operation-Status. An empty
+// pollingTemplate forwards the operation name unchanged.
+//
+//	grpc.SetHeader(ctx, metadata.Pairs(
+//		headermapper.OperationNameKey, op.GetName(),
+//		headermapper.OperationStatusKey, "Running",
+//		headermapper.OperationRetryAfterKey, "5s",
+//	))
+func LROMappings(pollingTemplate string) []HeaderMapping {
+	location := HeaderMapping{
+		HTTPHeader:   "Operation-Location",
+		GRPCMetadata: OperationNameKey,
+		Direction:    Outgoing,
+	}
+	if pollingTemplate != "" {
+		location.Transform = OperationURL(pollingTemplate)
+	}
+
+	return []HeaderMapping{
+		location,
+		{
+			HTTPHeader:   "Retry-After",
+			GRPCMetadata: OperationRetryAfterKey,
+			Direction:    Outgoing,
+			Transform:    DeltaSeconds,
+		},
+		{
+			HTTPHeader:   "X-Operation-Status",
+			GRPCMetadata: OperationStatusKey,
+			Direction:    Outgoing,
+		},
+	}
+}
+
+// OperationURL converts an operation resource name such as
+// "projects/p1/operations/op-42" into a polling URL. The template may
+// reference {name}, the full resource name, and {id}, its last segment:
+//
+//	OperationURL("https://api.example.com/v1/{name}")
+//	OperationURL("/operations/{id}?api-version=2024-01-01")
+//
+// Segments are path escaped; names that already are URLs are returned unchanged.
+func OperationURL(template string) TransformFunc {
+	return func(value string) string {
+		name := strings.Trim(strings.TrimSpace(value), "/")
+		if name == "" {
+			return ""
+		}
+		if strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://") {
+			return name
+		}
+
+		segments := strings.Split(name, "/")
+		for i, segment := range segments {
+			segments[i] = url.PathEscape(segment)
+		}
+		return strings.NewReplacer(
+			"{name}", strings.Join(segments, "/"),
+			"{id}", segments[len(segments)-1],
+		).Replace(template)
+	}
+}
@@ -0,0 +1,78 @@
+package headermapper
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestOperationURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		input    string
+		expected string
+	}{
+		{"full name", "https://api.example.com/v1/{name}", "projects/p1/operations/op-42",
+			"https://api.example.com/v1/projects/p1/operations/op-42"},
+		{"id only", "/operations/{id}?api-version=2024-01-01", "projects/p1/operations/op-42",
+			"/operations/op-42?api-version=2024-01-01"},
+		{"escaped segments", "/v1/{name}", "operations/a b?c", "/v1/operations/a%20b%3Fc"},
+		{"surrounding slashes", "/v1/{name}", " /operations/op-1/ ", "/v1/operations/op-1"},
+		{"already a URL", "/v1/{name}", "https://lro.example.com/op-1", "https://lro.example.com/op-1"},
+		{"empty", "/v1/{name}", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := OperationURL(tt.template)(tt.input); got != tt.expected {
+				t.Errorf("got %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLROMappings(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		location string
+	}{
+		{"polling template", "https://api.example.com/v1/{name}", "https://api.example.com/v1/operations/op-42"},
+		{"no template", "", "operations/op-42"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper := NewBuilder().AddMappings(LROMappings(tt.template)...).Build()
+			if err := mapper.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+
+			md := metadata.Pairs(
+				OperationNameKey, "operations/op-42",
+				OperationStatusKey, "Running",
+				OperationRetryAfterKey, "2500ms",
+			)
+			ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{HeaderMD: md})
+			w := httptest.NewRecorder()
+			if err := mapper.ResponseModifier()(ctx, w, nil); err != nil {
+				t.Fatalf("ResponseModifier() error = %v", err)
+			}
+
+			expected := map[string]string{
+				"Operation-Location": tt.location,
+				"Retry-After":        "3",
+				"X-Operation-Status": "Running",
+			}
+			for header, want := range expected {
+				if got := w.Header().Get(header); got != want {
+					t.Errorf("%s = %q, want %q", header, got, want)
+				}
+			}
+		})
+	}
+}
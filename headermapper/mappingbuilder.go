@@ -0,0 +1,91 @@
+package headermapper
+
+// MappingBuilder configures a single mapping added with Builder.Mapping.
+// Unlike the Builder's With* methods, which modify the last added mapping,
+// its options always apply to its own mapping.
+type MappingBuilder struct {
+	parent *Builder
+	index  int
+}
+
+// Mapping adds a mapping and returns a builder for its options; call Done to
+// return to the parent builder
+//
+//	NewBuilder().
+//		Mapping("X-User-ID", "user-id", Incoming).WithRequired(true).Done().
+//		Mapping("X-Request-ID", "request-id", Bidirectional).WithGenerator(GenerateUUID).Done().
+//		Build()
+func (b *Builder) Mapping(httpHeader, grpcMetadata string, direction MappingDirection) *MappingBuilder {
+	b.AddMapping(httpHeader, grpcMetadata, direction)
+	return &MappingBuilder{parent: b, index: len(b.config.Mappings) - 1}
+}
+
+// mapping returns the mapping being configured; it is looked up on each call
+// since adding mappings may move the parent's slice
+func (mb *MappingBuilder) mapping() *HeaderMapping {
+	return &mb.parent.config.Mappings[mb.index]
+}
+
+// WithTransform sets the transformation function
+func (mb *MappingBuilder) WithTransform(transform TransformFunc) *MappingBuilder {
+	mb.mapping().Transform = transform
+	return mb
+}
+
+// WithCachedTransform sets a deterministic transformation function whose
+// results are served from the shared transform cache
+func (mb *MappingBuilder) WithCachedTransform(transform TransformFunc) *MappingBuilder {
+	mb.mapping().Transform = transform
+	mb.mapping().CacheTransform = true
+	return mb
+}
+
+// WithRequired marks the mapping as required
+func (mb *MappingBuilder) WithRequired(required bool) *MappingBuilder {
+	mb.mapping().Required = required
+	return mb
+}
+
+// WithDefault sets the default value
+func (mb *MappingBuilder) WithDefault(defaultValue string) *MappingBuilder {
+	mb.mapping().DefaultValue = defaultValue
+	return mb
+}
+
+// WithGenerator sets a function producing a value on each request or
+// response where it is missing
+func (mb *MappingBuilder) WithGenerator(generator func() string) *MappingBuilder {
+	mb.mapping().Generator = generator
+	return mb
+}
+
+// WithAliases sets legacy header names read when the header is missing
+func (mb *MappingBuilder) WithAliases(aliases ...string) *MappingBuilder {
+	mb.mapping().Aliases = aliases
+	return mb
+}
+
+// WithDeprecated logs and counts requests using an alias
+func (mb *MappingBuilder) WithDeprecated(deprecated bool) *MappingBuilder {
+	mb.mapping().Deprecated = deprecated
+	return mb
+}
+
+// WithAppendValues adds the value to those of earlier mappings of the same
+// metadata key or header
+func (mb *MappingBuilder) WithAppendValues(appendValues bool) *MappingBuilder {
+	mb.mapping().AppendValues = appendValues
+	return mb
+}
+
+// WithBinaryEncoding sets the encoding of the header values of a binary
+// metadata key
+func (mb *MappingBuilder) WithBinaryEncoding(encoding BinaryEncoding) *MappingBuilder {
+	mb.mapping().BinaryEncoding = encoding
+	return mb
+}
+
+// Done returns the parent builder
+func (mb *MappingBuilder) Done() *Builder {
+	return mb.parent
+}
@@ -0,0 +1,69 @@
+package headermapper
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMappingBuilder(t *testing.T) {
+	builder := NewBuilder()
+	user := builder.Mapping("X-User-ID", "user-id", Incoming)
+	builder.
+		Mapping("Authorization", "auth-token", Incoming).WithTransform(ExtractBearerToken).Done().
+		Mapping("X-Tenant-ID", "tenant-id", Incoming).WithDefault("default").Done().
+		Mapping("X-Request-ID", "request-id", Bidirectional).WithGenerator(func() string { return "generated" }).Done()
+	// Options apply to their own mapping even after others were added
+	user.WithRequired(true).WithDefault("anonymous")
+
+	mapper := builder.Build()
+	mappings := mapper.state().config.Mappings
+	if len(mappings) != 4 {
+		t.Fatalf("mappings = %+v", mappings)
+	}
+
+	tests := []struct {
+		name      string
+		mapping   HeaderMapping
+		required  bool
+		defValue  string
+		transform bool
+		generator bool
+	}{
+		{"user", mappings[0], true, "anonymous", false, false},
+		{"auth", mappings[1], false, "", true, false},
+		{"tenant", mappings[2], false, "default", false, false},
+		{"request", mappings[3], false, "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := tt.mapping
+			if m.Required != tt.required || m.DefaultValue != tt.defValue ||
+				(m.Transform != nil) != tt.transform || (m.Generator != nil) != tt.generator {
+				t.Errorf("mapping = %+v", m)
+			}
+		})
+	}
+
+	req := httptest.NewRequest("GET", "/api/test", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	md := mapper.MetadataAnnotator()(context.Background(), req)
+	for key, want := range map[string]string{"user-id": "anonymous", "auth-token": "token", "tenant-id": "default", "request-id": "generated"} {
+		if got := md.Get(key); len(got) != 1 || got[0] != want {
+			t.Errorf("%s = %v, want %s", key, got, want)
+		}
+	}
+}
+
+func TestMappingBuilder_CachedTransform(t *testing.T) {
+	mapper := NewBuilder().
+		Mapping("User-Agent", "client", Incoming).WithCachedTransform(ToLower).Done().
+		Build()
+
+	if m := mapper.state().config.Mappings[0]; !m.CacheTransform || m.Transform == nil {
+		t.Errorf("mapping = %+v", m)
+	}
+	if mapper.state().cache == nil {
+		t.Error("transform cache not created")
+	}
+}
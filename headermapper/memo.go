@@ -0,0 +1,90 @@
+package headermapper
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// requestMemoKey is the context key of the per-request memo
+type requestMemoKey struct{}
+
+// requestMemo holds the metadata mapped for a request, so the policy checks
+// of Handler and the annotator of the gateway share a single mapping pass,
+// and the checks Handler enforced
+type requestMemo struct {
+	mu      sync.Mutex
+	cc      *compiledConfig
+	md      metadata.MD
+	checked []string
+
+	// tokens holds the verified JWTs of the request, guarded by tokensMu
+	// since tokens are verified while mu is held for mapping
+	tokensMu sync.Mutex
+	tokens   map[string]verifiedToken
+}
+
+// verifiedToken is the outcome of verifying a JWT
+type verifiedToken struct {
+	claims map[string]interface{}
+	err    error
+}
+
+// withRequestMemo returns ctx carrying an empty memo
+func withRequestMemo(ctx context.Context) context.Context {
+	return context.WithValue(ctx, requestMemoKey{}, &requestMemo{})
+}
+
+// forgetMappedMetadata clears the metadata memoized for the request of ctx
+func forgetMappedMetadata(ctx context.Context) {
+	if memo, ok := ctx.Value(requestMemoKey{}).(*requestMemo); ok {
+		memo.mu.Lock()
+		memo.md, memo.cc = nil, nil
+		memo.mu.Unlock()
+	}
+}
+
+// verifyToken returns the outcome of verifying token, calling verify the
+// first time
+func (m *requestMemo) verifyToken(token string, verify func() (map[string]interface{}, error)) (map[string]interface{}, error) {
+	m.tokensMu.Lock()
+	defer m.tokensMu.Unlock()
+
+	result, ok := m.tokens[token]
+	if !ok {
+		result.claims, result.err = verify()
+		if m.tokens == nil {
+			m.tokens = make(map[string]verifiedToken)
+		}
+		m.tokens[token] = result
+	}
+	return result.claims, result.err
+}
+
+// annotateOnce returns the metadata mapped for req with cc, mapping it once
+// per request when its context carries a memo. Callers must not modify the
+// result, which other checks read.
+func (hm *HeaderMapper) annotateOnce(cc *compiledConfig, req *http.Request) metadata.MD {
+	memo, ok := req.Context().Value(requestMemoKey{}).(*requestMemo)
+	if !ok {
+		return hm.annotate(cc, req)
+	}
+	memo.mu.Lock()
+	defer memo.mu.Unlock()
+	if memo.md == nil || memo.cc != cc {
+		memo.md, memo.cc = hm.annotate(cc, req), cc
+	}
+	return memo.md
+}
+
+// annotateOwned is annotateOnce for callers modifying the result: metadata
+// shared through the memo is copied, and requests without a memo pay for
+// neither the memo nor the copy
+func (hm *HeaderMapper) annotateOwned(cc *compiledConfig, req *http.Request) metadata.MD {
+	if _, ok := req.Context().Value(requestMemoKey{}).(*requestMemo); !ok {
+		return hm.annotate(cc, req)
+	}
+	return hm.annotateOnce(cc, req).Copy()
+}
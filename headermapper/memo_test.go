@@ -0,0 +1,59 @@
+package headermapper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestHandler_MapsMetadataOnce(t *testing.T) {
+	var calls atomic.Int32
+	mapper := NewBuilder().
+		AddIncomingMapping("X-User-Role", "user-role").
+		WithTransform(func(value string) string {
+			calls.Add(1)
+			return value
+		}).
+		Authorize(&AuthorizationConfig{
+			Rules: []AccessRule{{Paths: []string{"/v1/admin/*"}, Require: map[string][]string{"user-role": {"admin"}}}},
+		}).
+		LimitMetadata(&MetadataLimitConfig{MaxBytes: 1024}).
+		Build()
+	annotator := mapper.MetadataAnnotator()
+
+	tests := []struct {
+		name      string
+		role      string
+		expected  int
+		forwarded string
+	}{
+		{"allowed", "admin", http.StatusOK, "admin"},
+		{"denied", "guest", http.StatusForbidden, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls.Store(0)
+			var forwarded string
+			handler := mapper.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				forwarded = firstValue(annotator(context.Background(), r), "user-role")
+			}))
+			req := httptest.NewRequest("GET", "/v1/admin/users", nil)
+			req.Header.Set("X-User-Role", tt.role)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("Handler() status = %d, want %d", w.Code, tt.expected)
+			}
+			if forwarded != tt.forwarded {
+				t.Errorf("forwarded user-role = %q, want %q", forwarded, tt.forwarded)
+			}
+			if got := calls.Load(); got != 1 {
+				t.Errorf("transform calls = %d, want 1", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package headermapper
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// MergePolicy determines how MergeMappers resolves conflicting mappings and options
+type MergePolicy string
+
+const (
+	// MergeError fails the merge on any conflict (default)
+	MergeError MergePolicy = "error"
+	// MergeFirstWins keeps the mapping or option of the earliest mapper
+	MergeFirstWins MergePolicy = "first"
+	// MergeLastWins keeps the mapping or option of the latest mapper
+	MergeLastWins MergePolicy = "last"
+)
+
+// validate checks the policy name
+func (p MergePolicy) validate() error {
+	switch p {
+	case "", MergeError, MergeFirstWins, MergeLastWins:
+		return nil
+	}
+	return fmt.Errorf("unknown merge policy: %s", p)
+}
+
+// MergeMappers combines the configurations of several mappers, e.g. a
+// tracing, an auth and a service-specific mapper, into a new mapper with the
+// logger of the first. It fails if two mappers set conflicting mappings or
+// options; use MergeMappersWithPolicy to resolve conflicts instead.
+func MergeMappers(mappers ...*HeaderMapper) (*HeaderMapper, error) {
+	return MergeMappersWithPolicy(MergeError, mappers...)
+}
+
+// MergeMappersWithPolicy combines the configurations of several mappers,
+// resolving conflicts according to policy.
+//
+// Two mappings conflict when they write the same target: the same metadata
+// key for incoming mappings or the same HTTP header for outgoing ones. Only
+// the conflicting direction of a bidirectional mapping is dropped. Identical
+// mappings without transforms or generators are merged silently. Skip paths,
+// internal namespaces and trusted proxies are combined; other options
+// conflict when two mappers set them to different values.
+func MergeMappersWithPolicy(policy MergePolicy, mappers ...*HeaderMapper) (*HeaderMapper, error) {
+	if err := policy.validate(); err != nil {
+		return nil, err
+	}
+	if len(mappers) == 0 {
+		return nil, fmt.Errorf("merge: no mappers")
+	}
+
+	merger := &configMerger{
+		policy:   policy,
+		config:   &Config{},
+		incoming: make(map[string]int),
+		outgoing: make(map[string]int),
+	}
+	for _, mapper := range mappers {
+		if mapper == nil {
+			return nil, fmt.Errorf("merge: mapper is nil")
+		}
+		if err := merger.merge(mapper.state().config); err != nil {
+			return nil, err
+		}
+	}
+
+	config := merger.result()
+	if err := ValidateConfig(config); err != nil {
+		return nil, fmt.Errorf("merge: %w", err)
+	}
+	return mappers[0].derive(config), nil
+}
+
+// Directions of a mapping as bits, so the conflicting direction of a
+// bidirectional mapping can be removed on its own
+const (
+	incomingSide = 1 << iota
+	outgoingSide
+)
+
+func mappingSides(direction MappingDirection) int {
+	switch direction {
+	case Incoming:
+		return incomingSide
+	case Outgoing:
+		return outgoingSide
+	}
+	return incomingSide | outgoingSide
+}
+
+// sidesDirection converts sides back to a direction; false when none is left
+func sidesDirection(sides int) (MappingDirection, bool) {
+	switch sides {
+	case incomingSide:
+		return Incoming, true
+	case outgoingSide:
+		return Outgoing, true
+	case incomingSide | outgoingSide:
+		return Bidirectional, true
+	}
+	return 0, false
+}
+
+// configMerger accumulates the merged configuration
+type configMerger struct {
+	policy MergePolicy
+	config *Config
+	// mappings holds the merged mappings with their remaining sides
+	mappings []HeaderMapping
+	sides    []int
+	// incoming and outgoing index mappings by the metadata key and HTTP
+	// header they write
+	incoming map[string]int
+	outgoing map[string]int
+}
+
+// merge adds the mappings and options of config
+func (m *configMerger) merge(config *Config) error {
+	for _, mapping := range config.Mappings {
+		if err := m.addMapping(mapping); err != nil {
+			return err
+		}
+	}
+	return m.mergeOptions(config)
+}
+
+func (m *configMerger) addMapping(mapping HeaderMapping) error {
+	sides := mappingSides(mapping.Direction)
+	index := len(m.mappings)
+
+	targets := []struct {
+		side   int
+		byName map[string]int
+		name   string
+	}{
+		{incomingSide, m.incoming, strings.ToLower(mapping.GRPCMetadata)},
+		{outgoingSide, m.outgoing, http.CanonicalHeaderKey(mapping.HTTPHeader)},
+	}
+	for _, target := range targets {
+		if sides&target.side == 0 {
+			continue
+		}
+		existing, ok := target.byName[target.name]
+		if !ok {
+			target.byName[target.name] = index
+			continue
+		}
+		if sameMapping(m.mappings[existing], mapping) {
+			// Already mapped in this direction
+			sides &^= target.side
+			continue
+		}
+
+		switch m.policy {
+		case MergeFirstWins:
+			sides &^= target.side
+		case MergeLastWins:
+			m.sides[existing] &^= target.side
+			target.byName[target.name] = index
+		default:
+			return fmt.Errorf("merge: mapping %s -> %s conflicts with %s -> %s",
+				mapping.HTTPHeader, mapping.GRPCMetadata,
+				m.mappings[existing].HTTPHeader, m.mappings[existing].GRPCMetadata)
+		}
+	}
+
+	m.mappings = append(m.mappings, mapping)
+	m.sides = append(m.sides, sides)
+	return nil
+}
+
+// sameMapping reports whether two mappings are interchangeable; functions
+// cannot be compared, so mappings with transforms or generators never are
+func sameMapping(a, b HeaderMapping) bool {
+	if a.Transform != nil || b.Transform != nil || a.Generator != nil || b.Generator != nil {
+		return false
+	}
+	return http.CanonicalHeaderKey(a.HTTPHeader) == http.CanonicalHeaderKey(b.HTTPHeader) &&
+		a.HTTPHeaderPattern == b.HTTPHeaderPattern &&
+		a.QueryParam == b.QueryParam &&
+		a.PathParam == b.PathParam &&
+		a.StripParam == b.StripParam &&
+		strings.EqualFold(a.GRPCMetadata, b.GRPCMetadata) &&
+		a.Required == b.Required &&
+		a.DefaultValue == b.DefaultValue &&
+		a.CacheTransform == b.CacheTransform &&
+		slices.Equal(a.Aliases, b.Aliases) &&
+		a.Deprecated == b.Deprecated &&
+		a.AppendValues == b.AppendValues &&
+		a.BinaryEncoding == b.BinaryEncoding
+}
+
+// mergeOptions merges the options of config other than mappings. String
+// lists are combined; any other option set in both configurations must
+// agree unless the policy picks one.
+func (m *configMerger) mergeOptions(config *Config) error {
+	dst := reflect.ValueOf(m.config).Elem()
+	src := reflect.ValueOf(config).Elem()
+	for i := 0; i < dst.NumField(); i++ {
+		field := dst.Type().Field(i)
+		if field.Name == "Mappings" {
+			continue
+		}
+		to, from := dst.Field(i), src.Field(i)
+		if from.IsZero() {
+			continue
+		}
+
+		if values, ok := from.Interface().([]string); ok {
+			merged := to.Interface().([]string)
+			for _, value := range values {
+				if !slices.Contains(merged, value) {
+					merged = append(merged, value)
+				}
+			}
+			to.Set(reflect.ValueOf(merged))
+			continue
+		}
+
+		if to.IsZero() || reflect.DeepEqual(to.Interface(), from.Interface()) {
+			to.Set(from)
+			continue
+		}
+		switch m.policy {
+		case MergeFirstWins:
+		case MergeLastWins:
+			to.Set(from)
+		default:
+			return fmt.Errorf("merge: conflicting values for %s", field.Name)
+		}
+	}
+	return nil
+}
+
+// result returns the merged configuration
+func (m *configMerger) result() *Config {
+	config := m.config
+	for i, mapping := range m.mappings {
+		direction, ok := sidesDirection(m.sides[i])
+		if !ok {
+			continue
+		}
+		mapping.Direction = direction
+		config.Mappings = append(config.Mappings, mapping)
+	}
+	return config
+}
@@ -0,0 +1,130 @@
+package headermapper
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMergeMappers(t *testing.T) {
+	tracing := NewBuilder().
+		AddBidirectionalMapping("X-Request-ID", "request-id").
+		AddIncomingMapping("X-Trace-ID", "trace-id").
+		SkipPaths("/health").
+		Build()
+	auth := NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").
+		AddBidirectionalMapping("X-Request-ID", "request-id").
+		SkipPaths("/health", "/metrics").
+		MappingBudget(time.Millisecond).
+		Build()
+
+	merged, err := MergeMappers(tracing, auth)
+	if err != nil {
+		t.Fatalf("MergeMappers() error = %v", err)
+	}
+
+	config := merged.state().config
+	if len(config.Mappings) != 3 {
+		t.Errorf("mappings = %+v", config.Mappings)
+	}
+	if len(config.SkipPaths) != 2 || config.MappingBudget != time.Millisecond {
+		t.Errorf("options = %+v", config)
+	}
+
+	req := httptest.NewRequest("GET", "/api/test", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	req.Header.Set("X-Trace-ID", "trace-1")
+	req.Header.Set("X-User-ID", "12345")
+	md := merged.MetadataAnnotator()(context.Background(), req)
+	for key, want := range map[string]string{"request-id": "req-1", "trace-id": "trace-1", "user-id": "12345"} {
+		if got := md.Get(key); len(got) != 1 || got[0] != want {
+			t.Errorf("%s = %v, want %s", key, got, want)
+		}
+	}
+}
+
+func TestMergeMappersWithPolicy(t *testing.T) {
+	base := NewBuilder().
+		AddBidirectionalMapping("X-Request-ID", "request-id").
+		DuplicateHeaders(DuplicateHeaderFirst).
+		Build()
+	service := NewBuilder().
+		AddIncomingMapping("X-Correlation-ID", "request-id").
+		DuplicateHeaders(DuplicateHeaderLast).
+		Build()
+
+	tests := []struct {
+		name       string
+		policy     MergePolicy
+		wantErr    bool
+		mappings   []HeaderMapping
+		duplicates DuplicateHeaderPolicy
+	}{
+		{name: "error", policy: MergeError, wantErr: true},
+		{name: "default is error", wantErr: true},
+		{
+			name:   "first wins",
+			policy: MergeFirstWins,
+			mappings: []HeaderMapping{
+				{HTTPHeader: "X-Request-ID", GRPCMetadata: "request-id", Direction: Bidirectional},
+			},
+			duplicates: DuplicateHeaderFirst,
+		},
+		{
+			// Only the incoming direction of the base mapping is replaced
+			name:   "last wins",
+			policy: MergeLastWins,
+			mappings: []HeaderMapping{
+				{HTTPHeader: "X-Request-ID", GRPCMetadata: "request-id", Direction: Outgoing},
+				{HTTPHeader: "X-Correlation-ID", GRPCMetadata: "request-id", Direction: Incoming},
+			},
+			duplicates: DuplicateHeaderLast,
+		},
+		{name: "unknown policy", policy: "random", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, err := MergeMappersWithPolicy(tt.policy, base, service)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MergeMappersWithPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			config := merged.state().config
+			if len(config.Mappings) != len(tt.mappings) {
+				t.Fatalf("mappings = %+v, want %+v", config.Mappings, tt.mappings)
+			}
+			for i, want := range tt.mappings {
+				if got := config.Mappings[i]; got.HTTPHeader != want.HTTPHeader || got.GRPCMetadata != want.GRPCMetadata || got.Direction != want.Direction {
+					t.Errorf("mapping %d = %+v, want %+v", i, got, want)
+				}
+			}
+			if config.DuplicateHeaders != tt.duplicates {
+				t.Errorf("DuplicateHeaders = %s, want %s", config.DuplicateHeaders, tt.duplicates)
+			}
+		})
+	}
+}
+
+func TestMergeMappers_Invalid(t *testing.T) {
+	mapper := NewBuilder().AddIncomingMapping("X-User-ID", "user-id").Build()
+
+	tests := []struct {
+		name    string
+		mappers []*HeaderMapper
+	}{
+		{"no mappers", nil},
+		{"nil mapper", []*HeaderMapper{mapper, nil}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := MergeMappers(tt.mappers...); err == nil {
+				t.Error("MergeMappers() error = nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package headermapper
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// HTTPMiddleware applies the mappings to plain net/http traffic, for
+// services fronted by the mapper without grpc-gateway such as reverse
+// proxies or REST services. After the policies of Handler, the mapped
+// metadata is set as request headers named by their keys and as incoming
+// metadata of the request context, so Get works in next. Client headers
+// named like keys only the mapper sets, such as SPIFFE IDs or JWT claims,
+// and blocked headers are removed first. The outgoing
+// mappings are applied when next writes its headers, reading the response
+// headers as metadata by their lowercase names.
+//
+//	http.ListenAndServe(":8080", mapper.HTTPMiddleware(proxy))
+func (hm *HeaderMapper) HTTPMiddleware(next http.Handler) http.Handler {
+	annotator := hm.UpstreamAnnotator()
+	return hm.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		cc, release := hm.requestState(req.Context())
+		defer release()
+		if cc.skipPaths[req.URL.Path] {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		md := annotator(req.Context(), req)
+		req = req.Clone(metadata.NewIncomingContext(req.Context(), md))
+		hm.stripSpoofedHeaders(cc, req.Header)
+		for key, values := range md {
+			req.Header[http.CanonicalHeaderKey(key)] = values
+		}
+
+		mw := &middlewareWriter{ResponseWriter: w, hm: hm, cc: cc, req: req}
+		next.ServeHTTP(mw, req)
+		if !mw.wroteHeader {
+			mw.mapResponse()
+		}
+	}))
+}
+
+// stripSpoofedHeaders removes from h, before mapped metadata is written into
+// it, the blocked headers and those named like reserved metadata keys, which
+// only the mapper sets
+func (hm *HeaderMapper) stripSpoofedHeaders(cc *compiledConfig, h http.Header) {
+	cc.index.blocked.strip(h)
+	if len(hm.reservedKeys) == 0 {
+		return
+	}
+	for name := range h {
+		if hm.reservedKeys[strings.ToLower(name)] {
+			delete(h, name)
+		}
+	}
+}
+
+// SpoofedHeaders returns the lowercase names, sorted, of the headers in h
+// that must not reach upstreams as sent by the client: blocked headers and
+// those named like metadata keys only the mapper sets, such as SPIFFE IDs
+// or JWT claims. Integrations forwarding requests themselves, like the
+// ext_proc server, remove them before adding the mapped metadata.
+func (hm *HeaderMapper) SpoofedHeaders(h http.Header) []string {
+	blocked := hm.state().index.blocked
+	var names []string
+	for name := range h {
+		if key := strings.ToLower(name); hm.reservedKeys[key] || blocked.blocks(name) {
+			names = append(names, key)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// MapResponseHeaders applies the outgoing mappings to response headers
+// produced outside the gateway, such as by a proxy, reading them as metadata
+// by their lowercase names like HTTPMiddleware. Headers in internal
+// namespaces are removed, and mapped headers are written into header, along
+// with metadata the policies of Handler produced for the request of ctx.
+func (hm *HeaderMapper) MapResponseHeaders(ctx context.Context, header http.Header) {
+	hm.stripInternalHeaders(header)
+	hm.mapResponseHeaders(ctx, header, nil)
+}
+
+// mapResponseHeaders maps the response headers and trailers of a handler,
+// joined with metadata produced by the policies, to response headers
+func (hm *HeaderMapper) mapResponseHeaders(ctx context.Context, header, trailer http.Header) {
+	cc, release := hm.requestState(ctx)
+	defer release()
+	md := headerMetadata(header)
+	if gatewayMD, found := responseMetadataFromContext(ctx); found {
+		md = metadata.Join(md, gatewayMD)
+	}
+	hm.applyResponse(cc, md, headerMetadata(trailer), headerWriter(header), false)
+}
+
+// headerMetadata returns h as metadata keyed by lowercase header names
+func headerMetadata(h http.Header) metadata.MD {
+	md := make(metadata.MD, len(h))
+	for name, values := range h {
+		md[strings.ToLower(name)] = values
+	}
+	return md
+}
+
+// headerWriter exposes a header map to applyResponse as a response writer
+type headerWriter http.Header
+
+func (h headerWriter) Header() http.Header         { return http.Header(h) }
+func (h headerWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (h headerWriter) WriteHeader(int)             {}
+
+// middlewareWriter applies the outgoing mappings before the response
+// headers are sent
+type middlewareWriter struct {
+	http.ResponseWriter
+	hm          *HeaderMapper
+	cc          *compiledConfig
+	req         *http.Request
+	wroteHeader bool
+	// grpcWeb decodes -bin headers and maps the headers of trailers-only
+	// responses as trailers
+	grpcWeb bool
+}
+
+// mapResponse maps the response headers next set, joined with metadata
+// produced by the policies, to the mapped response headers
+func (mw *middlewareWriter) mapResponse() {
+	mw.wroteHeader = true
+	h := mw.Header()
+	md := make(metadata.MD, len(h))
+	for name, values := range h {
+		key := strings.ToLower(name)
+		if mw.grpcWeb {
+			values = decodeBinaryValues(key, values)
+		}
+		md[key] = values
+	}
+	if gatewayMD, found := responseMetadataFromContext(mw.req.Context()); found {
+		md = metadata.Join(md, gatewayMD)
+	}
+	var trailers metadata.MD
+	if mw.grpcWeb && len(md["grpc-status"]) > 0 {
+		trailers = md
+	}
+	mw.hm.applyResponse(mw.cc, md, trailers, mw.ResponseWriter, false)
+}
+
+func (mw *middlewareWriter) WriteHeader(code int) {
+	if !mw.wroteHeader {
+		mw.mapResponse()
+	}
+	mw.ResponseWriter.WriteHeader(code)
+}
+
+func (mw *middlewareWriter) Write(b []byte) (int, error) {
+	if !mw.wroteHeader {
+		mw.WriteHeader(http.StatusOK)
+	}
+	return mw.ResponseWriter.Write(b)
+}
+
+func (mw *middlewareWriter) Flush() {
+	if !mw.wroteHeader {
+		mw.WriteHeader(http.StatusOK)
+	}
+	if f, ok := mw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying writer for http.ResponseController
+func (mw *middlewareWriter) Unwrap() http.ResponseWriter {
+	return mw.ResponseWriter
+}
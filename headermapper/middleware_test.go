@@ -0,0 +1,128 @@
+package headermapper
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPMiddleware(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").
+		WithTransform(ToLower).
+		AddIncomingMapping("X-Tenant", "tenant-id").
+		WithDefault("public").
+		AddOutgoingMapping("request-id", "X-Request-ID").
+		SkipPaths("/healthz").
+		Build()
+
+	backend := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		userID, _ := Get(req.Context(), "user-id")
+		w.Header().Set("Request-Id", "req-1")
+		w.Header().Set("X-Seen-User", userID+"|"+req.Header.Get("User-Id")+"|"+req.Header.Get("Tenant-Id"))
+		io.WriteString(w, "ok")
+	})
+	handler := mapper.HTTPMiddleware(backend)
+
+	tests := []struct {
+		name      string
+		path      string
+		wantSeen  string
+		wantReqID string
+	}{
+		{"mapped", "/v1/users", "alice|alice|public", "req-1"},
+		{"skip path", "/healthz", "||", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req.Header.Set("X-User-ID", "ALICE")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if got := w.Header().Get("X-Seen-User"); got != tt.wantSeen {
+				t.Errorf("backend saw %q, want %q", got, tt.wantSeen)
+			}
+			if got := w.Header().Get("X-Request-ID"); got != tt.wantReqID {
+				t.Errorf("X-Request-ID = %q, want %q", got, tt.wantReqID)
+			}
+			if w.Body.String() != "ok" {
+				t.Errorf("body = %q", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHTTPMiddleware_NoBody(t *testing.T) {
+	mapper := NewBuilder().
+		AddOutgoingMapping("request-id", "X-Request-ID").
+		Build()
+
+	handler := mapper.HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Request-Id", "req-2")
+	}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/users", nil))
+
+	if got := w.Header().Get("X-Request-ID"); got != "req-2" {
+		t.Errorf("X-Request-ID = %q, want req-2", got)
+	}
+}
+
+func TestMapResponseHeaders(t *testing.T) {
+	mapper := NewBuilder().
+		AddOutgoingMapping("request-id", "X-Request-ID").
+		AddOutgoingMapping("region", "X-Region").WithDefault("us-east-1").
+		InternalNamespaces("x-internal-").
+		Build()
+
+	header := http.Header{"Request-Id": {"r1"}, "X-Internal-Host": {"node-3"}}
+	mapper.MapResponseHeaders(context.Background(), header)
+
+	for name, want := range map[string]string{"X-Request-Id": "r1", "X-Region": "us-east-1", "X-Internal-Host": ""} {
+		if got := header.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestHTTPMiddleware_StripsSpoofedHeaders(t *testing.T) {
+	mapper := NewBuilder().
+		MapJWTClaims(ExtractJWTClaims(map[string]string{"sub": "user-id"})).
+		BlockHeaders("X-Internal-Token").
+		Build()
+
+	backend := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-Seen", req.Header.Get("User-Id")+"|"+req.Header.Get("X-Internal-Token"))
+	})
+	handler := mapper.HTTPMiddleware(backend)
+
+	tests := []struct {
+		name     string
+		token    string
+		wantSeen string
+	}{
+		{"no token", "", "|"},
+		{"invalid token", "Bearer not-a-jwt", "|"},
+		{"token", "Bearer " + unsignedJWT(t, map[string]interface{}{"sub": "alice"}), "alice|"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/v1/users", nil)
+			req.Header.Set("User-Id", "admin")
+			req.Header.Set("X-Internal-Token", "forged")
+			if tt.token != "" {
+				req.Header.Set("Authorization", tt.token)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if got := w.Header().Get("X-Seen"); got != tt.wantSeen {
+				t.Errorf("backend saw %q, want %q", got, tt.wantSeen)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package headermapper
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// matchMapping returns a predicate matching the mappings of an HTTP header
+// and metadata key; names are compared ignoring case and an empty name
+// matches any
+func matchMapping(httpHeader, grpcKey string) func(HeaderMapping) bool {
+	header := http.CanonicalHeaderKey(httpHeader)
+	return func(mapping HeaderMapping) bool {
+		return (httpHeader == "" || http.CanonicalHeaderKey(mapping.HTTPHeader) == header) &&
+			(grpcKey == "" || strings.EqualFold(mapping.GRPCMetadata, grpcKey))
+	}
+}
+
+// removeMappings returns the mappings not matching remove. The result never
+// shares its backing array with mappings, which may belong to a base
+// configuration used elsewhere.
+func removeMappings(mappings []HeaderMapping, remove func(HeaderMapping) bool) []HeaderMapping {
+	kept := make([]HeaderMapping, 0, len(mappings))
+	for _, mapping := range mappings {
+		if !remove(mapping) {
+			kept = append(kept, mapping)
+		}
+	}
+	return kept
+}
+
+// replaceMappings returns a copy of mappings with those matching replace
+// swapped for replacement in place
+func replaceMappings(mappings []HeaderMapping, replace func(HeaderMapping) bool, replacement HeaderMapping) []HeaderMapping {
+	replaced := slices.Clone(mappings)
+	for i, mapping := range replaced {
+		if replace(mapping) {
+			replaced[i] = replacement
+		}
+	}
+	return replaced
+}
+
+// ListMappings returns a copy of the active mappings
+func (hm *HeaderMapper) ListMappings() []HeaderMapping {
+	return cloneConfig(hm.state().config).Mappings
+}
+
+// AddMapping adds a mapping to a running mapper. Like all runtime changes it
+// validates and installs a copy of the configuration through UpdateConfig,
+// so requests in flight finish with the mappings they started with.
+func (hm *HeaderMapper) AddMapping(mapping HeaderMapping) error {
+	return hm.modifyMappings(func(mappings []HeaderMapping) ([]HeaderMapping, error) {
+		return append(mappings, mapping), nil
+	})
+}
+
+// RemoveMapping removes the mappings of httpHeader to grpcKey from a running
+// mapper; either may be empty to match any. It returns ErrMappingNotFound
+// when none match.
+func (hm *HeaderMapper) RemoveMapping(httpHeader, grpcKey string) error {
+	match := matchMapping(httpHeader, grpcKey)
+	return hm.modifyMappings(func(mappings []HeaderMapping) ([]HeaderMapping, error) {
+		kept := removeMappings(mappings, match)
+		if len(kept) == len(mappings) {
+			return nil, fmt.Errorf("%s -> %s: %w", httpHeader, grpcKey, ErrMappingNotFound)
+		}
+		return kept, nil
+	})
+}
+
+// UpdateMapping replaces the mappings of httpHeader to grpcKey of a running
+// mapper with mapping, keeping their position. It returns
+// ErrMappingNotFound when none match.
+func (hm *HeaderMapper) UpdateMapping(httpHeader, grpcKey string, mapping HeaderMapping) error {
+	match := matchMapping(httpHeader, grpcKey)
+	return hm.modifyMappings(func(mappings []HeaderMapping) ([]HeaderMapping, error) {
+		if !slices.ContainsFunc(mappings, match) {
+			return nil, fmt.Errorf("%s -> %s: %w", httpHeader, grpcKey, ErrMappingNotFound)
+		}
+		return replaceMappings(mappings, match, mapping), nil
+	})
+}
+
+// modifyMappings installs the mappings modify derives from a copy of the
+// active ones
+func (hm *HeaderMapper) modifyMappings(modify func([]HeaderMapping) ([]HeaderMapping, error)) error {
+	return hm.modifyConfig(func(config *Config) error {
+		mappings, err := modify(config.Mappings)
+		if err != nil {
+			return err
+		}
+		config.Mappings = mappings
+		return nil
+	})
+}
+
+// RemoveMapping removes the mappings of httpHeader to grpcKey; either may be
+// empty to match any, so RemoveMapping("X-Debug", "") drops every mapping of
+// X-Debug
+func (b *Builder) RemoveMapping(httpHeader, grpcKey string) *Builder {
+	b.config.Mappings = removeMappings(b.config.Mappings, matchMapping(httpHeader, grpcKey))
+	return b
+}
+
+// ReplaceMapping replaces the mappings of httpHeader to grpcKey with mapping,
+// keeping their position
+func (b *Builder) ReplaceMapping(httpHeader, grpcKey string, mapping HeaderMapping) *Builder {
+	b.config.Mappings = replaceMappings(b.config.Mappings, matchMapping(httpHeader, grpcKey), mapping)
+	return b
+}
+
+// RemoveWhere removes the mappings for which remove returns true
+func (b *Builder) RemoveWhere(remove func(HeaderMapping) bool) *Builder {
+	b.config.Mappings = removeMappings(b.config.Mappings, remove)
+	return b
+}
+
+// RemoveMapping removes the mappings of httpHeader to grpcKey; either may be
+// empty to match any
+func (cb *ConfigBuilder) RemoveMapping(httpHeader, grpcKey string) *ConfigBuilder {
+	cb.config.Mappings = removeMappings(cb.config.Mappings, matchMapping(httpHeader, grpcKey))
+	return cb
+}
+
+// ReplaceMapping replaces the mappings of httpHeader to grpcKey with mapping,
+// keeping their position
+func (cb *ConfigBuilder) ReplaceMapping(httpHeader, grpcKey string, mapping HeaderMapping) *ConfigBuilder {
+	cb.config.Mappings = replaceMappings(cb.config.Mappings, matchMapping(httpHeader, grpcKey), mapping)
+	return cb
+}
+
+// RemoveWhere removes the mappings for which remove returns true
+func (cb *ConfigBuilder) RemoveWhere(remove func(HeaderMapping) bool) *ConfigBuilder {
+	cb.config.Mappings = removeMappings(cb.config.Mappings, remove)
+	return cb
+}
@@ -0,0 +1,176 @@
+package headermapper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestBuilder_RemoveMapping(t *testing.T) {
+	base := CommonMappings()
+	replacement := HeaderMapping{HTTPHeader: "Authorization", GRPCMetadata: "auth-token", Direction: Incoming}
+
+	tests := []struct {
+		name     string
+		build    func(b *Builder) *Builder
+		expected []string
+	}{
+		{
+			name:     "remove by header and key",
+			build:    func(b *Builder) *Builder { return b.RemoveMapping("user-agent", "User-Agent") },
+			expected: []string{"authorization", "content-type", "accept", "x-request-id", "x-correlation-id"},
+		},
+		{
+			name:     "remove by header",
+			build:    func(b *Builder) *Builder { return b.RemoveMapping("Accept", "") },
+			expected: []string{"user-agent", "authorization", "content-type", "x-request-id", "x-correlation-id"},
+		},
+		{
+			name:     "no match",
+			build:    func(b *Builder) *Builder { return b.RemoveMapping("Accept", "user-agent") },
+			expected: []string{"user-agent", "authorization", "content-type", "accept", "x-request-id", "x-correlation-id"},
+		},
+		{
+			name:     "replace",
+			build:    func(b *Builder) *Builder { return b.ReplaceMapping("Authorization", "authorization", replacement) },
+			expected: []string{"user-agent", "auth-token", "content-type", "accept", "x-request-id", "x-correlation-id"},
+		},
+		{
+			name: "remove where",
+			build: func(b *Builder) *Builder {
+				return b.RemoveWhere(func(m HeaderMapping) bool { return strings.HasPrefix(m.GRPCMetadata, "x-") })
+			},
+			expected: []string{"user-agent", "authorization", "content-type", "accept"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.build(NewBuilder().AddMappings(base...)).Build().state().config.Mappings
+			if len(got) != len(tt.expected) {
+				t.Fatalf("mappings = %+v, want keys %v", got, tt.expected)
+			}
+			for i, key := range tt.expected {
+				if got[i].GRPCMetadata != key {
+					t.Errorf("mapping %d = %s, want %s", i, got[i].GRPCMetadata, key)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigBuilder_RemoveMapping(t *testing.T) {
+	base := CommonMappings()
+	config := NewConfigBuilder().
+		WithMappings(base).
+		RemoveMapping("Authorization", "").
+		ReplaceMapping("", "accept", HeaderMapping{HTTPHeader: "Accept", GRPCMetadata: "accept-types", Direction: Incoming}).
+		RemoveWhere(func(m HeaderMapping) bool { return m.HTTPHeader == "X-Correlation-ID" }).
+		Build()
+
+	if len(config.Mappings) != 4 || config.Mappings[2].GRPCMetadata != "accept-types" {
+		t.Errorf("mappings = %+v", config.Mappings)
+	}
+	// The base configuration is shared and must not change
+	if len(base) != 6 || base[1].GRPCMetadata != "authorization" || base[3].GRPCMetadata != "accept" {
+		t.Errorf("base mappings modified: %+v", base)
+	}
+}
+
+func TestHeaderMapper_RuntimeMappings(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").
+		Build()
+	annotate := mapper.MetadataAnnotator()
+	mapped := func() map[string]string {
+		req := httptest.NewRequest("GET", "/v1/echo", nil)
+		req.Header.Set("X-User-ID", "u")
+		req.Header.Set("X-Tenant-ID", "t")
+		got := make(map[string]string)
+		for key, values := range annotate(context.Background(), req) {
+			got[key] = values[0]
+		}
+		return got
+	}
+
+	if err := mapper.AddMapping(HeaderMapping{HTTPHeader: "X-Tenant-ID", GRPCMetadata: "tenant-id", Direction: Incoming}); err != nil {
+		t.Fatal(err)
+	}
+	if got := mapped(); got["tenant-id"] != "t" || got["user-id"] != "u" {
+		t.Errorf("after AddMapping metadata = %v", got)
+	}
+
+	if err := mapper.UpdateMapping("X-User-ID", "", HeaderMapping{HTTPHeader: "X-User-ID", GRPCMetadata: "uid", Direction: Incoming}); err != nil {
+		t.Fatal(err)
+	}
+	if got := mapped(); got["uid"] != "u" || got["user-id"] != "" {
+		t.Errorf("after UpdateMapping metadata = %v", got)
+	}
+
+	if err := mapper.RemoveMapping("x-tenant-id", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := mapped(); got["tenant-id"] != "" {
+		t.Errorf("after RemoveMapping metadata = %v", got)
+	}
+
+	list := mapper.ListMappings()
+	if len(list) != 1 || list[0].GRPCMetadata != "uid" {
+		t.Fatalf("ListMappings() = %+v", list)
+	}
+	list[0].GRPCMetadata = "changed"
+	if mapper.ListMappings()[0].GRPCMetadata != "uid" {
+		t.Error("ListMappings() shares the active mappings")
+	}
+}
+
+func TestHeaderMapper_RuntimeMappingErrors(t *testing.T) {
+	mapper := NewBuilder().AddIncomingMapping("X-User-ID", "user-id").Build()
+
+	tests := []struct {
+		name   string
+		change func() error
+		want   error
+	}{
+		{"remove missing", func() error { return mapper.RemoveMapping("X-Other", "") }, ErrMappingNotFound},
+		{"update missing", func() error { return mapper.UpdateMapping("X-Other", "", HeaderMapping{}) }, ErrMappingNotFound},
+		{"add invalid", func() error { return mapper.AddMapping(HeaderMapping{GRPCMetadata: "key"}) }, ErrValidationFailed},
+		{"add duplicate", func() error {
+			return mapper.AddMapping(HeaderMapping{HTTPHeader: "X-User-ID", GRPCMetadata: "user-id", Direction: Incoming})
+		}, ErrDuplicateMapping},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.change(); !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+			if got := mapper.ListMappings(); len(got) != 1 {
+				t.Errorf("failed change modified mappings: %+v", got)
+			}
+		})
+	}
+}
+
+func TestHeaderMapper_ConcurrentAddMapping(t *testing.T) {
+	mapper := NewBuilder().Build()
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			header := fmt.Sprintf("X-Header-%d", i)
+			if err := mapper.AddMapping(HeaderMapping{HTTPHeader: header, GRPCMetadata: strings.ToLower(header), Direction: Incoming}); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if got := len(mapper.ListMappings()); got != 20 {
+		t.Errorf("len(ListMappings()) = %d, want 20", got)
+	}
+}
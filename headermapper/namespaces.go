@@ -0,0 +1,42 @@
+package headermapper
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+// gatewayPrefixes are the lowercase prefixes gRPC-Gateway adds to forwarded
+// metadata in response headers
+var gatewayPrefixes = []string{
+	strings.ToLower(runtime.MetadataHeaderPrefix),
+	strings.ToLower(runtime.MetadataTrailerPrefix),
+}
+
+// internalHeader reports whether a header or metadata key belongs to one of
+// the configured internal namespaces, including its Grpc-Metadata- and
+// Grpc-Trailer- forms
+func (hm *HeaderMapper) internalHeader(name string) bool {
+	if hm.internalNamespaces == nil {
+		return false
+	}
+
+	for _, prefix := range gatewayPrefixes {
+		if hasLowerPrefix(name, prefix) {
+			name = name[len(prefix):]
+			break
+		}
+	}
+	_, ok := hm.internalNamespaces.longestPrefix(name)
+	return ok
+}
+
+// stripInternalHeaders removes all headers in internal namespaces
+func (hm *HeaderMapper) stripInternalHeaders(h http.Header) {
+	for name := range h {
+		if hm.internalHeader(name) {
+			h.Del(name)
+		}
+	}
+}
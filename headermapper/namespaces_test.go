@@ -0,0 +1,79 @@
+package headermapper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestInternalNamespaces_Incoming(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-Internal-User", "internal-user").
+		AddIncomingMapping("X-Internal-Tier", "tier").
+		WithDefault("free").
+		AddIncomingMapping("X-User-ID", "user-id").
+		InternalNamespaces("x-internal-", "X-Envoy-").
+		Build()
+
+	req := httptest.NewRequest("GET", "/v1/echo", nil)
+	req.Header.Set("X-Internal-User", "admin")
+	req.Header.Set("X-Internal-Tier", "enterprise")
+	req.Header.Set("X-User-ID", "user-1")
+
+	md := mapper.MetadataAnnotator()(context.Background(), req)
+	if len(md.Get("internal-user")) != 0 {
+		t.Errorf("internal header should not be mapped, got %v", md.Get("internal-user"))
+	}
+	if got := md.Get("tier"); len(got) != 1 || got[0] != "free" {
+		t.Errorf("tier = %v, want default value", got)
+	}
+	if got := md.Get("user-id"); len(got) != 1 || got[0] != "user-1" {
+		t.Errorf("user-id = %v", got)
+	}
+
+	matcher := mapper.HeaderMatcher()
+	for _, header := range []string{"X-Internal-User", "X-Envoy-Original-Path", "Grpc-Metadata-X-Internal-Role"} {
+		if _, ok := matcher(header); ok {
+			t.Errorf("HeaderMatcher(%s) should not forward internal headers", header)
+		}
+	}
+
+	var seen http.Header
+	handler := mapper.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { seen = r.Header }))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if seen.Get("X-Internal-User") != "" || seen.Get("X-User-ID") != "user-1" {
+		t.Errorf("Handler() forwarded headers = %v", seen)
+	}
+}
+
+func TestInternalNamespaces_Outgoing(t *testing.T) {
+	mapper := NewBuilder().
+		AddOutgoingMapping("x-internal-route", "X-Internal-Route").
+		AddOutgoingMapping("request-id", "X-Request-ID").
+		InternalNamespaces("x-internal-").
+		Build()
+
+	w := httptest.NewRecorder()
+	w.Header().Set("Grpc-Metadata-X-Internal-Shard", "7")
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{
+		HeaderMD: metadata.Pairs("x-internal-route", "blue", "request-id", "req-1"),
+	})
+	if err := mapper.ResponseModifier()(ctx, w, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, header := range []string{"X-Internal-Route", "Grpc-Metadata-X-Internal-Shard"} {
+		if w.Header().Get(header) != "" {
+			t.Errorf("internal header %s leaked to response", header)
+		}
+	}
+	if w.Header().Get("X-Request-ID") != "req-1" || w.Header().Get("Content-Type") == "" {
+		t.Errorf("unexpected response headers %v", w.Header())
+	}
+}
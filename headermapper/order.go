@@ -0,0 +1,52 @@
+package headermapper
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// HeaderOrder determines the order in which outgoing mappings are applied,
+// which decides the mapping that wins when several target the same header
+// and the mappings dropped when the mapping budget is exceeded
+type HeaderOrder string
+
+const (
+	// HeaderOrderConfig applies outgoing mappings in configuration order (default)
+	HeaderOrderConfig HeaderOrder = "config"
+	// HeaderOrderAlphabetical applies outgoing mappings sorted by header
+	// name, then in configuration order
+	HeaderOrderAlphabetical HeaderOrder = "alphabetical"
+)
+
+// validate checks the order name
+func (o HeaderOrder) validate() error {
+	switch o {
+	case "", HeaderOrderConfig, HeaderOrderAlphabetical:
+		return nil
+	}
+	return fmt.Errorf("unknown outgoing header order: %s", o)
+}
+
+// sequence returns the outgoing mappings in order and numbers them, so
+// mappings selected per response can be put back in order
+func (o HeaderOrder) sequence(outgoing []compiledMapping) []*compiledMapping {
+	sequence := make([]*compiledMapping, len(outgoing))
+	for i := range outgoing {
+		sequence[i] = &outgoing[i]
+	}
+	if o == HeaderOrderAlphabetical {
+		slices.SortStableFunc(sequence, func(a, b *compiledMapping) int {
+			return strings.Compare(a.header, b.header)
+		})
+	}
+	for i, mapping := range sequence {
+		mapping.position = i
+	}
+	return sequence
+}
+
+// byPosition orders compiled mappings by their position in the sequence
+func byPosition(a, b *compiledMapping) int {
+	return a.position - b.position
+}
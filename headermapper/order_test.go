@@ -0,0 +1,62 @@
+package headermapper
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestOutgoingOrder(t *testing.T) {
+	slow := func(value string) string {
+		time.Sleep(time.Millisecond)
+		return value
+	}
+
+	tests := []struct {
+		name     string
+		order    HeaderOrder
+		expected string
+	}{
+		{"default", "", "X-Zone"},
+		{"config", HeaderOrderConfig, "X-Zone"},
+		{"alphabetical", HeaderOrderAlphabetical, "X-Alpha"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The budget runs out after the first transformed mapping, so
+			// only the first mapping in order writes its header
+			mapper := NewBuilder().
+				AddOutgoingMapping("zone", "X-Zone").WithTransform(slow).
+				AddOutgoingMapping("middle", "X-Middle").WithTransform(slow).
+				AddOutgoingMapping("alpha", "X-Alpha").WithTransform(slow).
+				MappingBudget(time.Nanosecond).
+				OutgoingOrder(tt.order).
+				Build()
+
+			for i := 0; i < 20; i++ {
+				ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{
+					HeaderMD: metadata.Pairs("alpha", "a", "middle", "m", "zone", "z"),
+				})
+				w := httptest.NewRecorder()
+				if err := mapper.ResponseModifier()(ctx, w, nil); err != nil {
+					t.Fatalf("ResponseModifier() error = %v", err)
+				}
+				if len(w.Header()) != 1 || w.Header().Get(tt.expected) == "" {
+					t.Fatalf("headers = %v, want only %s", w.Header(), tt.expected)
+				}
+			}
+		})
+	}
+}
+
+func TestOutgoingOrder_Invalid(t *testing.T) {
+	config := &Config{OutgoingOrder: "random"}
+	if err := ValidateConfig(config); err == nil {
+		t.Error("ValidateConfig() error = nil, want unknown order")
+	}
+}
@@ -0,0 +1,149 @@
+package headermapper
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// maxCachedOverrides bounds the suppression sets whose state is kept per
+// configuration; others are compiled for each request
+const maxCachedOverrides = 64
+
+// mappingOverridesKey is the context key of the per-request mapping overrides
+type mappingOverridesKey struct{}
+
+// mappingOverrides holds the mappings added and the names suppressed for a
+// single request or call
+type mappingOverrides struct {
+	extra    []HeaderMapping
+	suppress []string
+}
+
+// WithExtraMappings returns a context adding mappings to the requests, responses
+// and client calls mapped with it. Middleware running before the gateway sets
+// them on the request context; handlers set them on the context of
+// downstream calls.
+//
+//	ctx = headermapper.WithExtraMappings(ctx, headermapper.HeaderMapping{
+//		HTTPHeader: "X-Debug-Session", GRPCMetadata: "debug-session", Direction: headermapper.Incoming,
+//	})
+func WithExtraMappings(ctx context.Context, mappings ...HeaderMapping) context.Context {
+	ov := overridesFromContext(ctx)
+	ov.extra = append(slices.Clip(ov.extra), mappings...)
+	return context.WithValue(ctx, mappingOverridesKey{}, &ov)
+}
+
+// SuppressMapping returns a context disabling, for the requests, responses
+// and client calls mapped with it, the mappings whose HTTP header, header
+// pattern or metadata key equals one of names, compared case-insensitively.
+// Extra mappings are suppressed as well.
+func SuppressMapping(ctx context.Context, names ...string) context.Context {
+	ov := overridesFromContext(ctx)
+	ov.suppress = append(slices.Clip(ov.suppress), names...)
+	return context.WithValue(ctx, mappingOverridesKey{}, &ov)
+}
+
+// overridesFromContext returns a copy of the overrides of ctx
+func overridesFromContext(ctx context.Context) mappingOverrides {
+	if ov, ok := ctx.Value(mappingOverridesKey{}).(*mappingOverrides); ok {
+		return *ov
+	}
+	return mappingOverrides{}
+}
+
+// suppresses reports whether the overrides disable a mapping
+func (ov *mappingOverrides) suppresses(mapping HeaderMapping) bool {
+	for _, name := range ov.suppress {
+		if strings.EqualFold(name, mapping.HTTPHeader) || strings.EqualFold(name, mapping.GRPCMetadata) ||
+			(mapping.HTTPHeaderPattern != "" && strings.EqualFold(name, mapping.HTTPHeaderPattern)) {
+			return true
+		}
+	}
+	return false
+}
+
+// overrideStates caches the states of a configuration compiled for sets of
+// suppressed mappings, which are typically few and repeated per request
+type overrideStates struct {
+	states sync.Map
+	count  atomic.Int32
+}
+
+// each calls fn with each cached state
+func (o *overrideStates) each(fn func(*compiledConfig)) {
+	o.states.Range(func(_, state any) bool {
+		fn(state.(*compiledConfig))
+		return true
+	})
+}
+
+// reserve claims one of the maxCachedOverrides slots, reporting false once
+// all are taken
+func (o *overrideStates) reserve() bool {
+	for {
+		n := o.count.Load()
+		if n >= maxCachedOverrides {
+			return false
+		}
+		if o.count.CompareAndSwap(n, n+1) {
+			return true
+		}
+	}
+}
+
+// suppressKey identifies the set of suppressed names. Names are matched
+// case-insensitively, so they are lowercased to share the state of sets
+// differing only in case.
+func (ov *mappingOverrides) suppressKey() string {
+	names := make([]string, len(ov.suppress))
+	for i, name := range ov.suppress {
+		names[i] = strings.ToLower(name)
+	}
+	slices.Sort(names)
+	return strings.Join(slices.Compact(names), "\n")
+}
+
+// requestState returns the mapping state for ctx and a function to call once
+// it is no longer used. Overrides that only suppress mappings reuse a state
+// cached with the configuration; with extra mappings the state is compiled
+// for the request, skipping invalid extra mappings, and its counters are
+// added to the totals on release.
+func (hm *HeaderMapper) requestState(ctx context.Context) (*compiledConfig, func()) {
+	cc := hm.state()
+	ov, ok := ctx.Value(mappingOverridesKey{}).(*mappingOverrides)
+	if !ok {
+		return cc, func() {}
+	}
+
+	if len(ov.extra) == 0 {
+		key := ov.suppressKey()
+		if state, ok := cc.overrides.states.Load(key); ok {
+			return state.(*compiledConfig), func() {}
+		}
+		if cc.overrides.reserve() {
+			state, _ := cc.overrides.states.LoadOrStore(key, hm.compileOverrides(cc, ov))
+			return state.(*compiledConfig), func() {}
+		}
+	}
+
+	overridden := hm.compileOverrides(cc, ov)
+	return overridden, func() { hm.retired.retire(overridden.index) }
+}
+
+// compileOverrides compiles the state of cc with the overrides applied. It
+// goes through the same index construction as the configuration and shares
+// its transform cache.
+func (hm *HeaderMapper) compileOverrides(cc *compiledConfig, ov *mappingOverrides) *compiledConfig {
+	config := *cc.config
+	config.Mappings = make([]HeaderMapping, 0, len(cc.config.Mappings)+len(ov.extra))
+	for _, mapping := range append(slices.Clip(cc.config.Mappings), ov.extra...) {
+		if !ov.suppresses(mapping) {
+			config.Mappings = append(config.Mappings, mapping)
+		}
+	}
+
+	return hm.compileWithCache(&config, cc.cache)
+}
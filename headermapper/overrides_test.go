@@ -0,0 +1,176 @@
+package headermapper
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestMappingOverrides_Incoming(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").
+		AddIncomingMapping("X-Tenant-ID", "tenant-id").
+		Build()
+	debug := HeaderMapping{HTTPHeader: "X-Debug-Session", GRPCMetadata: "debug-session", Direction: Incoming}
+
+	tests := []struct {
+		name     string
+		ctx      func(context.Context) context.Context
+		expected metadata.MD
+	}{
+		{"none", func(ctx context.Context) context.Context { return ctx },
+			metadata.Pairs("user-id", "u", "tenant-id", "t")},
+		{"extra", func(ctx context.Context) context.Context { return WithExtraMappings(ctx, debug) },
+			metadata.Pairs("user-id", "u", "tenant-id", "t", "debug-session", "d")},
+		{"suppress header", func(ctx context.Context) context.Context { return SuppressMapping(ctx, "x-tenant-id") },
+			metadata.Pairs("user-id", "u")},
+		{"suppress key", func(ctx context.Context) context.Context { return SuppressMapping(ctx, "user-id") },
+			metadata.Pairs("tenant-id", "t")},
+		{"suppress extra", func(ctx context.Context) context.Context {
+			return SuppressMapping(WithExtraMappings(ctx, debug), "X-Debug-Session")
+		}, metadata.Pairs("user-id", "u", "tenant-id", "t")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/v1/echo", nil)
+			req.Header.Set("X-User-ID", "u")
+			req.Header.Set("X-Tenant-ID", "t")
+			req.Header.Set("X-Debug-Session", "d")
+			ctx := tt.ctx(req.Context())
+
+			md := mapper.MetadataAnnotator()(ctx, req.WithContext(ctx))
+			if len(md) != len(tt.expected) {
+				t.Fatalf("metadata = %v, want %v", md, tt.expected)
+			}
+			for key, values := range tt.expected {
+				if got := md.Get(key); len(got) != 1 || got[0] != values[0] {
+					t.Errorf("%s = %v, want %v", key, got, values)
+				}
+			}
+		})
+	}
+
+	// Overrides apply to a single request
+	req := httptest.NewRequest("GET", "/v1/echo", nil)
+	req.Header.Set("X-Debug-Session", "d")
+	if md := mapper.MetadataAnnotator()(req.Context(), req); len(md.Get("debug-session")) > 0 {
+		t.Errorf("extra mapping applied without overrides: %v", md)
+	}
+}
+
+func TestMappingOverrides_Outgoing(t *testing.T) {
+	mapper := NewBuilder().
+		AddOutgoingMapping("request-id", "X-Request-ID").
+		Build()
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{
+		HeaderMD: metadata.Pairs("request-id", "r", "cache-status", "hit"),
+	})
+	ctx = SuppressMapping(ctx, "X-Request-ID")
+	ctx = WithExtraMappings(ctx, HeaderMapping{GRPCMetadata: "cache-status", HTTPHeader: "X-Cache", Direction: Outgoing})
+
+	w := httptest.NewRecorder()
+	if err := mapper.ResponseModifier()(ctx, w, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.Header().Get("X-Request-ID"); got != "" {
+		t.Errorf("X-Request-ID = %q, want suppressed", got)
+	}
+	if got := w.Header().Get("X-Cache"); got != "hit" {
+		t.Errorf("X-Cache = %q, want %q", got, "hit")
+	}
+}
+
+func TestMappingOverrides_Client(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").
+		Build()
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("user-id", "u", "locale", "en"))
+	ctx = WithExtraMappings(ctx, HeaderMapping{HTTPHeader: "X-Locale", GRPCMetadata: "locale", Direction: Incoming})
+	ctx = SuppressMapping(ctx, "user-id")
+
+	var out metadata.MD
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		out, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	}
+	if err := mapper.UnaryClientInterceptor()(ctx, "/test.Service/Method", nil, nil, nil, invoker); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Get("user-id")) > 0 || len(out.Get("locale")) != 1 {
+		t.Errorf("outgoing metadata = %v", out)
+	}
+}
+
+func TestMappingOverrides_CachedState(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").
+		AddIncomingMapping("X-Tenant-ID", "tenant-id").
+		Build()
+	annotate := func(names ...string) {
+		req := httptest.NewRequest("GET", "/v1/echo", nil)
+		req.Header.Set("X-User-ID", "u")
+		req.Header.Set("X-Tenant-ID", "t")
+		mapper.MetadataAnnotator()(SuppressMapping(req.Context(), names...), req)
+	}
+
+	annotate("X-Tenant-ID")
+	annotate("x-tenant-id")
+	annotate("X-Tenant-ID")
+	if got := mapper.GetStats().IncomingMappings; got != 3 {
+		t.Errorf("IncomingMappings = %d, want 3", got)
+	}
+
+	// Cached states are retired with the configuration they were built from
+	if err := mapper.AddMapping(HeaderMapping{HTTPHeader: "X-Locale", GRPCMetadata: "locale", Direction: Incoming}); err != nil {
+		t.Fatal(err)
+	}
+	annotate("X-Tenant-ID")
+	if got := mapper.GetStats().IncomingMappings; got != 4 {
+		t.Errorf("IncomingMappings after update = %d, want 4", got)
+	}
+}
+
+func TestMappingOverrides_CacheKeys(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").
+		AddIncomingMapping("X-Tenant-ID", "tenant-id").
+		Build()
+	annotate := func(names ...string) metadata.MD {
+		req := httptest.NewRequest("GET", "/v1/echo", nil)
+		req.Header.Set("X-User-ID", "u")
+		req.Header.Set("X-Tenant-ID", "t")
+		return mapper.MetadataAnnotator()(SuppressMapping(req.Context(), names...), req)
+	}
+	cached := func() int {
+		n := 0
+		mapper.state().overrides.each(func(*compiledConfig) { n++ })
+		return n
+	}
+
+	// Names differing only in case share a state
+	annotate("X-Tenant-ID")
+	annotate("x-tenant-id", "X-TENANT-ID")
+	if got := cached(); got != 1 {
+		t.Errorf("cached states = %d, want 1", got)
+	}
+
+	// Sets beyond the limit are compiled per request and not kept
+	for i := 0; i < maxCachedOverrides+8; i++ {
+		if md := annotate("X-Tenant-ID", fmt.Sprintf("x-unused-%d", i)); !reflect.DeepEqual(md, metadata.Pairs("user-id", "u")) {
+			t.Fatalf("metadata = %v", md)
+		}
+	}
+	if got := cached(); got != maxCachedOverrides {
+		t.Errorf("cached states = %d, want %d", got, maxCachedOverrides)
+	}
+	if got := mapper.state().overrides.count.Load(); got != maxCachedOverrides {
+		t.Errorf("reserved states = %d, want %d", got, maxCachedOverrides)
+	}
+}
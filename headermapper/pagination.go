@@ -0,0 +1,110 @@
+package headermapper
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Metadata keys set by list endpoints for PaginationMappings
+const (
+	PaginationTotalCountKey = "pagination-total-count"
+	PaginationPageKey       = "pagination-page"
+	PaginationPerPageKey    = "pagination-per-page"
+	PaginationLinksKey      = "pagination-links"
+)
+
+// PaginationMappings returns outgoing mappings exposing pagination state as
+// X-Total-Count, X-Page, X-Per-Page and RFC 5988 Link response headers.
+// Backends set PaginationLinksKey to relation=URL pairs, which FormatLinks
+// turns into a Link header:
+//
+//	grpc.SetHeader(ctx, metadata.Pairs(
+//		headermapper.PaginationTotalCountKey, "120",
+//		headermapper.PaginationLinksKey, headermapper.Links("next", "/items?page=3", "prev", "/items?page=1"),
+//	))
+func PaginationMappings() []HeaderMapping {
+	return []HeaderMapping{
+		{
+			HTTPHeader:   "X-Total-Count",
+			GRPCMetadata: PaginationTotalCountKey,
+			Direction:    Outgoing,
+		},
+		{
+			HTTPHeader:   "X-Page",
+			GRPCMetadata: PaginationPageKey,
+			Direction:    Outgoing,
+		},
+		{
+			HTTPHeader:   "X-Per-Page",
+			GRPCMetadata: PaginationPerPageKey,
+			Direction:    Outgoing,
+		},
+		{
+			HTTPHeader:   "Link",
+			GRPCMetadata: PaginationLinksKey,
+			Direction:    Outgoing,
+			Transform:    FormatLinks,
+		},
+	}
+}
+
+// Links encodes relation and URL pairs, e.g. "next", "/items?page=3", as
+// the value of PaginationLinksKey; a trailing relation without URL is ignored
+func Links(pairs ...string) string {
+	var b strings.Builder
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if b.Len() > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(pairs[i])
+		b.WriteByte('=')
+		b.WriteString(pairs[i+1])
+	}
+	return b.String()
+}
+
+// FormatLinks converts comma separated relation=URL pairs, as built by
+// Links, into an RFC 5988 Link header value. Values already in Link format
+// are returned unchanged.
+func FormatLinks(value string) string {
+	return formatLinks(value, nil)
+}
+
+// FormatLinksWithBase is FormatLinks resolving relative URLs against base,
+// so backends can emit paths while clients receive absolute links
+func FormatLinksWithBase(base string) TransformFunc {
+	// An invalid base leaves URLs unresolved
+	baseURL, _ := url.Parse(base)
+	return func(value string) string {
+		return formatLinks(value, baseURL)
+	}
+}
+
+func formatLinks(value string, base *url.URL) string {
+	if strings.HasPrefix(strings.TrimSpace(value), "<") {
+		return value
+	}
+
+	var b strings.Builder
+	for _, pair := range strings.Split(value, ",") {
+		rel, target, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		rel, target = strings.TrimSpace(rel), strings.TrimSpace(target)
+		if !ok || rel == "" || target == "" {
+			continue
+		}
+		if base != nil {
+			if ref, err := url.Parse(target); err == nil {
+				target = base.ResolveReference(ref).String()
+			}
+		}
+		if b.Len() > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteByte('<')
+		b.WriteString(target)
+		b.WriteString(`>; rel="`)
+		b.WriteString(rel)
+		b.WriteByte('"')
+	}
+	return b.String()
+}
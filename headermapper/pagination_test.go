@@ -0,0 +1,69 @@
+package headermapper
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestFormatLinks(t *testing.T) {
+	tests := []struct {
+		name      string
+		transform TransformFunc
+		input     string
+		expected  string
+	}{
+		{"single", FormatLinks, "next=/items?page=3", `</items?page=3>; rel="next"`},
+		{"several", FormatLinks, Links("next", "/items?page=3", "prev", "/items?page=1"),
+			`</items?page=3>; rel="next", </items?page=1>; rel="prev"`},
+		{"already formatted", FormatLinks, `</items?page=3>; rel="next"`, `</items?page=3>; rel="next"`},
+		{"malformed pairs skipped", FormatLinks, "next, =/x, last=/items?page=9", `</items?page=9>; rel="last"`},
+		{"empty", FormatLinks, "", ""},
+		{"with base", FormatLinksWithBase("https://api.example.com/v1/"), "next=items?page=2",
+			`<https://api.example.com/v1/items?page=2>; rel="next"`},
+		{"absolute with base", FormatLinksWithBase("https://api.example.com/"), "next=https://cdn.example.com/a",
+			`<https://cdn.example.com/a>; rel="next"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.transform(tt.input); got != tt.expected {
+				t.Errorf("got %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPaginationMappings(t *testing.T) {
+	mapper := NewBuilder().AddMappings(PaginationMappings()...).Build()
+	if err := mapper.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	md := metadata.Pairs(
+		PaginationTotalCountKey, "120",
+		PaginationPageKey, "2",
+		PaginationPerPageKey, "50",
+		PaginationLinksKey, Links("next", "/items?page=3", "prev", "/items?page=1"),
+	)
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{HeaderMD: md})
+	w := httptest.NewRecorder()
+	if err := mapper.ResponseModifier()(ctx, w, nil); err != nil {
+		t.Fatalf("ResponseModifier() error = %v", err)
+	}
+
+	expected := map[string]string{
+		"X-Total-Count": "120",
+		"X-Page":        "2",
+		"X-Per-Page":    "50",
+		"Link":          `</items?page=3>; rel="next", </items?page=1>; rel="prev"`,
+	}
+	for header, want := range expected {
+		if got := w.Header().Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package headermapper
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// ParallelMappingConfig shards the evaluation of incoming mappings across
+// goroutines, for generated configurations with hundreds of transformed
+// mappings. Smaller configurations map faster sequentially.
+type ParallelMappingConfig struct {
+	// Workers is the number of goroutines evaluating mappings per request (default 4)
+	Workers int `json:"workers" yaml:"workers"`
+	// MinMappings is the number of incoming mappings from which mapping runs
+	// in parallel (default 128)
+	MinMappings int `json:"min_mappings" yaml:"min_mappings"`
+}
+
+// validate checks the worker count and threshold
+func (pc *ParallelMappingConfig) validate() error {
+	if pc.Workers < 0 {
+		return fmt.Errorf("parallel mapping: workers cannot be negative")
+	}
+	if pc.MinMappings < 0 {
+		return fmt.Errorf("parallel mapping: min mappings cannot be negative")
+	}
+	return nil
+}
+
+func (pc *ParallelMappingConfig) workers() int {
+	if pc.Workers == 0 {
+		return 4
+	}
+	return pc.Workers
+}
+
+func (pc *ParallelMappingConfig) minMappings() int {
+	if pc.MinMappings == 0 {
+		return 128
+	}
+	return pc.MinMappings
+}
+
+// parallel reports whether the incoming mappings of cc are evaluated in parallel
+func (cc *compiledConfig) parallel() bool {
+	pc := cc.config.ParallelMapping
+	return pc != nil && pc.workers() > 1 && len(cc.index.incoming) >= pc.minMappings()
+}
+
+// shardResult is the value computed for one mapping by a shard
+type shardResult struct {
+	value string
+	ok    bool
+}
+
+// mapIncomingParallel evaluates the incoming mappings in contiguous shards,
+// one per worker, then stores the values in configuration order so the
+// metadata matches sequential mapping. It reports false once budget runs out.
+func (hm *HeaderMapper) mapIncomingParallel(cc *compiledConfig, req *http.Request, md metadata.MD, budget mappingBudget) bool {
+	idx := cc.index
+	results := make([]shardResult, len(idx.incoming))
+	workers := min(cc.config.ParallelMapping.workers(), len(idx.incoming))
+	size := (len(idx.incoming) + workers - 1) / workers
+	// stopped records, per shard, the mapping after which the budget ran out
+	stopped := make([]*compiledMapping, workers)
+
+	shard := func(w int) {
+		for i := w * size; i < min((w+1)*size, len(idx.incoming)); i++ {
+			mapping := &idx.incoming[i]
+			src := &idx.sources[mapping.source]
+			value := hm.sourceValue(cc, req, src, req.Header[src.header])
+			results[i].value, results[i].ok = hm.incomingValue(mapping, value)
+			if budget.exceeded(mapping) {
+				stopped[w] = mapping
+				return
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	for w := 1; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			shard(w)
+		}()
+	}
+	shard(0)
+	wg.Wait()
+
+	backing := make([]string, 0, idx.incomingCapacity(req))
+	for i := range results {
+		if results[i].ok {
+			hm.setIncoming(cc, md, &idx.incoming[i], results[i].value, &backing)
+		}
+	}
+	for _, mapping := range stopped {
+		if mapping != nil {
+			hm.budgetExceeded(cc, mapping)
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,91 @@
+package headermapper
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMapIncomingParallel_MatchesSequential(t *testing.T) {
+	build := func(parallel bool, overwrite bool) *HeaderMapper {
+		builder := NewBuilder().OverwriteExisting(overwrite)
+		for i := 0; i < 40; i++ {
+			builder.AddIncomingMapping(fmt.Sprintf("X-Header-%d", i), fmt.Sprintf("header-%d", i%30)).
+				WithTransform(AddPrefix(fmt.Sprintf("%d:", i)))
+		}
+		builder.AddIncomingMapping("X-Tenant-ID", "tenant-id").WithDefault("default")
+		builder.AddIncomingMapping("X-Missing", "missing").WithRequired(true)
+		if parallel {
+			builder.ParallelMapping(3, 10)
+		}
+		return builder.Build()
+	}
+
+	req := httptest.NewRequest("GET", "/api/test", nil)
+	for i := 0; i < 40; i += 2 {
+		req.Header.Set(fmt.Sprintf("X-Header-%d", i), "value")
+	}
+	req.Header.Set("X-Header-35", "value")
+
+	for _, overwrite := range []bool{false, true} {
+		t.Run(fmt.Sprintf("overwrite=%v", overwrite), func(t *testing.T) {
+			sequential := build(false, overwrite)
+			parallel := build(true, overwrite)
+			if !parallel.state().parallel() || sequential.state().parallel() {
+				t.Fatal("unexpected parallel selection")
+			}
+
+			want := sequential.MetadataAnnotator()(context.Background(), req)
+			got := parallel.MetadataAnnotator()(context.Background(), req)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("parallel metadata = %v, want %v", got, want)
+			}
+			if got, want := parallel.GetStats(), sequential.GetStats(); got.IncomingMappings != want.IncomingMappings || got.FailedMappings != want.FailedMappings {
+				t.Errorf("parallel stats = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestMapIncomingParallel_Budget(t *testing.T) {
+	builder := NewBuilder().ParallelMapping(2, 1).MappingBudget(time.Millisecond)
+	req := httptest.NewRequest("GET", "/api/test", nil)
+	for i := 0; i < 8; i++ {
+		name := fmt.Sprintf("X-Header-%d", i)
+		builder.AddIncomingMapping(name, fmt.Sprintf("header-%d", i)).WithTransform(slowTransform)
+		req.Header.Set(name, "value")
+	}
+	mapper := builder.Build()
+
+	md := mapper.MetadataAnnotator()(context.Background(), req)
+	if len(md) == 0 || len(md) == 8 {
+		t.Errorf("mapped %d of 8 headers, want a partial result", len(md))
+	}
+	if got := mapper.GetStats().BudgetOverruns; got != 1 {
+		t.Errorf("BudgetOverruns = %d, want 1", got)
+	}
+}
+
+func TestParallelMappingConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  ParallelMappingConfig
+		wantErr bool
+	}{
+		{"defaults", ParallelMappingConfig{}, false},
+		{"explicit", ParallelMappingConfig{Workers: 8, MinMappings: 64}, false},
+		{"negative workers", ParallelMappingConfig{Workers: -1}, true},
+		{"negative min mappings", ParallelMappingConfig{MinMappings: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package headermapper
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
+)
+
+// isParamMapping reports whether a mapping reads a URL parameter instead of
+// a header
+func isParamMapping(mapping HeaderMapping) bool {
+	return mapping.QueryParam != "" || mapping.PathParam != ""
+}
+
+// compileParamMapping validates a mapping reading a query or path
+// parameter. Its header is the display form of the parameter, "?name" or
+// "{name}".
+func compileParamMapping(mapping HeaderMapping) (compiledMapping, error) {
+	switch {
+	case mapping.HTTPHeader != "" || mapping.HTTPHeaderPattern != "":
+		return compiledMapping{}, newMappingError(mapping, ErrValidationFailed, "HTTPHeader and URL parameters are exclusive")
+	case mapping.QueryParam != "" && mapping.PathParam != "":
+		return compiledMapping{}, newMappingError(mapping, ErrValidationFailed, "QueryParam and PathParam are exclusive")
+	case mapping.Direction != Incoming:
+		return compiledMapping{}, newMappingError(mapping, ErrValidationFailed, "parameter mappings are incoming only")
+	case mapping.StripParam && mapping.PathParam != "":
+		return compiledMapping{}, newMappingError(mapping, ErrValidationFailed, "path parameters cannot be stripped")
+	case len(mapping.Aliases) > 0:
+		return compiledMapping{}, newMappingError(mapping, ErrValidationFailed, "parameter mappings cannot have aliases")
+	}
+	key := strings.ToLower(mapping.GRPCMetadata)
+	if !validMetadataKey(key) || strings.HasSuffix(key, "-bin") {
+		return compiledMapping{}, newMappingError(mapping, ErrValidationFailed, "invalid gRPC metadata key")
+	}
+	transform, err := mappingTransform(mapping)
+	if err != nil {
+		return compiledMapping{}, err
+	}
+
+	header := "?" + mapping.QueryParam
+	if mapping.PathParam != "" {
+		header = "{" + mapping.PathParam + "}"
+	}
+	return compiledMapping{
+		header:       header,
+		lowerHeader:  header,
+		key:          key,
+		defaultValue: mapping.DefaultValue,
+		generator:    mapping.Generator,
+		required:     mapping.Required,
+		appendValues: mapping.AppendValues,
+		transform:    transform,
+		queryParam:   mapping.QueryParam,
+		pathParam:    mapping.PathParam,
+		stripParam:   mapping.StripParam,
+	}, nil
+}
+
+// paramLabel returns the display name of a parameter mapping
+func paramLabel(mapping HeaderMapping) string {
+	if mapping.PathParam != "" {
+		return "{" + mapping.PathParam + "}"
+	}
+	return "?" + mapping.QueryParam
+}
+
+// mapIncomingParams applies the parameter mappings to the URL of req. Path
+// parameters are read through the path pattern grpc-gateway stores in the
+// request context. It reports false once budget runs out.
+func (hm *HeaderMapper) mapIncomingParams(cc *compiledConfig, req *http.Request, md metadata.MD, budget mappingBudget) bool {
+	var query url.Values
+	var pathValues []string
+	var pathNames map[string]int
+	for i := range cc.index.incomingParams {
+		mapping := &cc.index.incomingParams[i]
+
+		var value string
+		if mapping.queryParam != "" {
+			if query == nil {
+				query = req.URL.Query()
+			}
+			value = cc.selectValue(query[mapping.queryParam])
+		} else {
+			if pathNames == nil {
+				pathNames, pathValues = matchPathPattern(req)
+			}
+			if n, ok := pathNames[mapping.pathParam]; ok {
+				value = pathValues[n]
+			}
+		}
+
+		if value, ok := hm.incomingValue(mapping, value); ok {
+			setMatched(cc, md, mapping, mapping.key, value)
+		}
+		if budget.exceeded(mapping) {
+			hm.budgetExceeded(cc, mapping)
+			return false
+		}
+	}
+	return true
+}
+
+// stripParams removes the query parameters of mappings with StripParam set
+// from req, so the gateway does not decode them into the request message
+func (idx *mappingIndex) stripParams(req *http.Request) {
+	if !idx.stripsParams || req.URL.RawQuery == "" {
+		return
+	}
+	query := req.URL.Query()
+	stripped := false
+	for i := range idx.incomingParams {
+		mapping := &idx.incomingParams[i]
+		if mapping.stripParam && query.Has(mapping.queryParam) {
+			query.Del(mapping.queryParam)
+			if req.Form != nil {
+				req.Form.Del(mapping.queryParam)
+			}
+			stripped = true
+		}
+	}
+	if stripped {
+		req.URL.RawQuery = query.Encode()
+	}
+}
+
+// pathTemplates caches the expressions compiled from path patterns
+var pathTemplates sync.Map
+
+// pathTemplate is a compiled google.api.http path template; names maps the
+// variable field paths to their submatch index
+type pathTemplate struct {
+	re    *regexp.Regexp
+	names map[string]int
+}
+
+// matchPathPattern returns the path parameters of req under the path
+// pattern of the gateway handler serving it, or none outside the gateway
+func matchPathPattern(req *http.Request) (names map[string]int, values []string) {
+	pattern, ok := runtime.HTTPPathPattern(req.Context())
+	if !ok {
+		return map[string]int{}, nil
+	}
+	var tmpl *pathTemplate
+	if cached, ok := pathTemplates.Load(pattern); ok {
+		tmpl = cached.(*pathTemplate)
+	} else {
+		var err error
+		if tmpl, err = compilePathTemplate(pattern); err != nil {
+			tmpl = &pathTemplate{names: map[string]int{}}
+		}
+		pathTemplates.Store(pattern, tmpl)
+	}
+	if tmpl.re == nil {
+		return tmpl.names, nil
+	}
+
+	match := tmpl.re.FindStringSubmatch(req.URL.EscapedPath())
+	if match == nil {
+		return map[string]int{}, nil
+	}
+	for i, value := range match {
+		if unescaped, err := url.PathUnescape(value); err == nil {
+			match[i] = unescaped
+		}
+	}
+	return tmpl.names, match
+}
+
+// compilePathTemplate compiles a path template such as
+// "/v1/{name=shelves/*/books/*}:get" into an anchored expression capturing
+// each variable
+func compilePathTemplate(template string) (*pathTemplate, error) {
+	path, verb := template, ""
+	if i := strings.LastIndexByte(template, ':'); i > strings.LastIndexAny(template, "/}") {
+		path, verb = template[:i], template[i:]
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("path template %q does not start with /", template)
+	}
+
+	tmpl := &pathTemplate{names: make(map[string]int)}
+	var expr strings.Builder
+	expr.WriteString("^")
+	for _, segment := range splitPathTemplate(path[1:]) {
+		expr.WriteString("/")
+		variable, ok := strings.CutPrefix(segment, "{")
+		if !ok {
+			expr.WriteString(pathSegmentExpr(segment))
+			continue
+		}
+		variable, ok = strings.CutSuffix(variable, "}")
+		if !ok {
+			return nil, fmt.Errorf("unterminated variable in path template %q", template)
+		}
+		name, segments, found := strings.Cut(variable, "=")
+		if !found {
+			segments = "*"
+		}
+		tmpl.names[name] = len(tmpl.names) + 1
+		expr.WriteString("(")
+		for i, s := range strings.Split(segments, "/") {
+			if i > 0 {
+				expr.WriteString("/")
+			}
+			expr.WriteString(pathSegmentExpr(s))
+		}
+		expr.WriteString(")")
+	}
+	expr.WriteString(regexp.QuoteMeta(verb) + "$")
+
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, err
+	}
+	tmpl.re = re
+	return tmpl, nil
+}
+
+// splitPathTemplate splits a path template at the slashes outside variables
+func splitPathTemplate(path string) []string {
+	var segments []string
+	start, depth := 0, 0
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case '/':
+			if depth == 0 {
+				segments = append(segments, path[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(segments, path[start:])
+}
+
+// pathSegmentExpr returns the expression of one template segment
+func pathSegmentExpr(segment string) string {
+	switch segment {
+	case "*":
+		return "[^/]+"
+	case "**":
+		return ".*"
+	}
+	return regexp.QuoteMeta(segment)
+}
@@ -0,0 +1,151 @@
+package headermapper
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestParamMapping_Query(t *testing.T) {
+	mapper := NewBuilder().
+		AddQueryParamMapping("api_key", "api-key").WithStripParam(true).
+		AddQueryParamMapping("locale", "locale").WithTransform(ToLower).
+		AddIncomingMapping("X-API-Key", "api-key").
+		Build()
+
+	tests := []struct {
+		name      string
+		target    string
+		header    string
+		expected  metadata.MD
+		remaining string
+	}{
+		{"query", "/v1/echo?api_key=k1&locale=EN&page=2", "", metadata.Pairs("api-key", "k1", "locale", "en"), "locale=EN&page=2"},
+		{"header wins", "/v1/echo?api_key=k1", "k2", metadata.Pairs("api-key", "k2"), ""},
+		{"escaped", "/v1/echo?api_key=a%2Bb", "", metadata.Pairs("api-key", "a+b"), ""},
+		{"absent", "/v1/echo?page=2", "", metadata.MD{}, "page=2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.target, nil)
+			if tt.header != "" {
+				req.Header.Set("X-API-Key", tt.header)
+			}
+			md := mapper.MetadataAnnotator()(context.Background(), req)
+			if len(md) != len(tt.expected) {
+				t.Fatalf("metadata = %v, want %v", md, tt.expected)
+			}
+			for key, values := range tt.expected {
+				if got := md.Get(key); len(got) != 1 || got[0] != values[0] {
+					t.Errorf("%s = %v, want %v", key, got, values)
+				}
+			}
+			if req.URL.RawQuery != tt.remaining {
+				t.Errorf("query = %q, want %q", req.URL.RawQuery, tt.remaining)
+			}
+		})
+	}
+}
+
+func TestParamMapping_Path(t *testing.T) {
+	mapper := NewBuilder().
+		AddPathParamMapping("tenant", "tenant-id").
+		AddPathParamMapping("book.name", "book").
+		Build()
+	mux := CreateGatewayMux(mapper)
+
+	tests := []struct {
+		name     string
+		pattern  string
+		target   string
+		expected metadata.MD
+	}{
+		{"simple", "/v1/tenants/{tenant}/users", "/v1/tenants/acme/users", metadata.Pairs("tenant-id", "acme")},
+		{"escaped", "/v1/tenants/{tenant}/users", "/v1/tenants/a%20b/users", metadata.Pairs("tenant-id", "a b")},
+		{"segments", "/v1/tenants/{tenant}/{book.name=shelves/*/books/*}:get", "/v1/tenants/acme/shelves/1/books/2:get",
+			metadata.Pairs("tenant-id", "acme", "book", "shelves/1/books/2")},
+		{"no variable", "/v1/users/{id}", "/v1/users/7", metadata.MD{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.target, nil)
+			ctx, err := runtime.AnnotateContext(context.Background(), mux, req, "/test.Service/Method",
+				runtime.WithHTTPPathPattern(tt.pattern))
+			if err != nil {
+				t.Fatal(err)
+			}
+			md, _ := metadata.FromOutgoingContext(ctx)
+			for key, values := range tt.expected {
+				if got := md.Get(key); len(got) != 1 || got[0] != values[0] {
+					t.Errorf("%s = %v, want %v", key, got, values)
+				}
+			}
+			if len(tt.expected) == 0 && len(md.Get("tenant-id")) > 0 {
+				t.Errorf("unexpected tenant-id in %v", md)
+			}
+		})
+	}
+}
+
+func TestCompilePathTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		path     string
+		match    bool
+	}{
+		{"/v1/users/{id}", "/v1/users/7", true},
+		{"/v1/users/{id}", "/v1/users/7/posts", false},
+		{"/v1/{name=users/*}", "/v1/users/7", true},
+		{"/v1/files/{path=**}", "/v1/files/a/b/c.txt", true},
+		{"/v1/users/*/posts", "/v1/users/7/posts", true},
+		{"/v1/users/{id}:undelete", "/v1/users/7:undelete", true},
+		{"/v1/users/{id}:undelete", "/v1/users/7", false},
+	}
+
+	for _, tt := range tests {
+		tmpl, err := compilePathTemplate(tt.template)
+		if err != nil {
+			t.Fatalf("compilePathTemplate(%q) error = %v", tt.template, err)
+		}
+		if got := tmpl.re.MatchString(tt.path); got != tt.match {
+			t.Errorf("%q matches %q = %v, want %v", tt.template, tt.path, got, tt.match)
+		}
+	}
+
+	if _, err := compilePathTemplate("v1/{id"); err == nil {
+		t.Error("compilePathTemplate() expected error for an invalid template")
+	}
+}
+
+func TestParamMapping_Validation(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping HeaderMapping
+	}{
+		{"header and param", HeaderMapping{HTTPHeader: "X-Key", QueryParam: "key", GRPCMetadata: "key"}},
+		{"query and path", HeaderMapping{QueryParam: "key", PathParam: "key", GRPCMetadata: "key"}},
+		{"outgoing", HeaderMapping{QueryParam: "key", GRPCMetadata: "key", Direction: Outgoing}},
+		{"strip path", HeaderMapping{PathParam: "key", GRPCMetadata: "key", StripParam: true}},
+		{"binary key", HeaderMapping{QueryParam: "key", GRPCMetadata: "key-bin"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateConfig(&Config{Mappings: []HeaderMapping{tt.mapping}})
+			if !errors.Is(err, ErrValidationFailed) {
+				t.Errorf("ValidateConfig() error = %v, want ErrValidationFailed", err)
+			}
+		})
+	}
+
+	valid := &Config{Mappings: []HeaderMapping{{QueryParam: "api_key", GRPCMetadata: "api-key", StripParam: true}}}
+	if err := ValidateConfig(valid); err != nil {
+		t.Errorf("ValidateConfig() error = %v", err)
+	}
+}
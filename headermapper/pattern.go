@@ -0,0 +1,96 @@
+package headermapper
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// compilePatternMapping validates a mapping matching header names with a
+// regular expression. The pattern is anchored and case-insensitive, so it
+// matches whole header names whatever the client's casing.
+func compilePatternMapping(mapping HeaderMapping) (compiledMapping, error) {
+	if mapping.HTTPHeader != "" {
+		return compiledMapping{}, newMappingError(mapping, ErrValidationFailed, "HTTPHeader and HTTPHeaderPattern are exclusive")
+	}
+	if mapping.Direction != Incoming {
+		return compiledMapping{}, newMappingError(mapping, ErrValidationFailed, "pattern mappings are incoming only")
+	}
+	re, err := regexp.Compile("(?i)^(?:" + mapping.HTTPHeaderPattern + ")$")
+	if err != nil {
+		return compiledMapping{}, newMappingError(mapping, ErrValidationFailed, "invalid HTTP header pattern: "+err.Error())
+	}
+	if strings.TrimSpace(mapping.GRPCMetadata) == "" {
+		return compiledMapping{}, newMappingError(mapping, ErrValidationFailed, "invalid gRPC metadata key template")
+	}
+	if err := validateOpenMapping(mapping, "pattern"); err != nil {
+		return compiledMapping{}, err
+	}
+	transform, err := mappingTransform(mapping)
+	if err != nil {
+		return compiledMapping{}, err
+	}
+
+	return compiledMapping{
+		header:       mapping.HTTPHeaderPattern,
+		lowerHeader:  strings.ToLower(mapping.HTTPHeaderPattern),
+		key:          mapping.GRPCMetadata,
+		appendValues: mapping.AppendValues,
+		transform:    transform,
+		deprecated:   mapping.Deprecated,
+		pattern:      re,
+	}, nil
+}
+
+// patternKey returns the metadata key of header under a pattern mapping,
+// reporting false when the pattern does not match or the expanded key is
+// invalid. Binary keys are never produced, as header values are text.
+func (m *compiledMapping) patternKey(header string) (string, bool) {
+	match := m.pattern.FindStringSubmatchIndex(header)
+	if match == nil {
+		return "", false
+	}
+	key := strings.ToLower(string(m.pattern.ExpandString(nil, m.key, header, match)))
+	return key, validMetadataKey(key) && !strings.HasSuffix(key, "-bin")
+}
+
+// matchPattern returns the first pattern mapping matching header in
+// configuration order and the metadata key it produces
+func (idx *mappingIndex) matchPattern(header string) (*compiledMapping, string, bool) {
+	for i := range idx.incomingPattern {
+		mapping := &idx.incomingPattern[i]
+		if key, ok := mapping.patternKey(header); ok {
+			return mapping, key, true
+		}
+	}
+	return nil, "", false
+}
+
+// mapIncomingPatterns applies the pattern mappings to the headers of req not
+// read by an exact mapping. It reports false once budget runs out.
+func (hm *HeaderMapper) mapIncomingPatterns(cc *compiledConfig, req *http.Request, md metadata.MD, budget mappingBudget) bool {
+	idx := cc.index
+	for name, values := range req.Header {
+		if idx.mapped[name] || hm.internalHeader(name) || idx.blocked.blocks(name) {
+			continue
+		}
+		if forwardedHeaders[name] && !hm.trustForwarded(req) {
+			continue
+		}
+		mapping, key, ok := idx.matchPattern(name)
+		if !ok || hm.reservedKeys[key] {
+			continue
+		}
+
+		if value, ok := hm.incomingValue(mapping, cc.selectValue(values)); ok {
+			setMatched(cc, md, mapping, key, value)
+		}
+		if budget.exceeded(mapping) {
+			hm.budgetExceeded(cc, mapping)
+			return false
+		}
+	}
+	return true
+}
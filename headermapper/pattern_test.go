@@ -0,0 +1,88 @@
+package headermapper
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPatternMapping_Incoming(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-Tenant-Plan", "plan").
+		AddIncomingPatternMapping(`X-(Tenant|Org)-(?P<field>.*)`, "$1-${field}").
+		AddIncomingPatternMapping(`X-Device-(\w+)`, "device.$1").
+		InternalNamespaces("X-Org-Internal-").
+		Build()
+	if err := mapper.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		header string
+		key    string
+		want   string
+	}{
+		{"X-Tenant-ID", "tenant-id", "v"},
+		{"x-org-name", "org-name", "v"},
+		{"X-Device-Model", "device.model", "v"},
+		{"X-Tenant-Plan", "plan", "v"},
+		{"X-Tenant-Plan", "tenant-plan", ""},
+		{"X-Org-Internal-Shard", "org-internal-shard", ""},
+		{"X-Tenant-Key-Bin", "tenant-key-bin", ""},
+		{"X-Device-Model-Name", "device.model-name", ""},
+		{"X-Team-ID", "team-id", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header+"->"+tt.key, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/v1/echo", nil)
+			req.Header.Set(tt.header, "v")
+
+			md := mapper.MetadataAnnotator()(context.Background(), req)
+			var got string
+			if values := md.Get(tt.key); len(values) > 0 {
+				got = values[0]
+			}
+			if got != tt.want {
+				t.Errorf("%s = %q, want %q (md %v)", tt.key, got, tt.want, md)
+			}
+		})
+	}
+}
+
+func TestPatternMapping_HeaderMatcher(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingPatternMapping(`X-(Tenant|Org)-ID`, "$1-id").
+		Build()
+
+	matcher := mapper.HeaderMatcher()
+	if key, ok := matcher("X-Org-Id"); !ok || key != "org-id" {
+		t.Errorf("HeaderMatcher(X-Org-Id) = %q, %v", key, ok)
+	}
+	if key, ok := matcher("X-Team-Id"); !ok || key != "grpc-metadata-x-team-id" {
+		t.Errorf("HeaderMatcher(X-Team-Id) = %q, %v, want default", key, ok)
+	}
+}
+
+func TestPatternMapping_Validation(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping HeaderMapping
+	}{
+		{"invalid regexp", HeaderMapping{HTTPHeaderPattern: `X-(Tenant`, GRPCMetadata: "$1"}},
+		{"with header", HeaderMapping{HTTPHeader: "X-Tenant", HTTPHeaderPattern: `X-(\w+)`, GRPCMetadata: "$1"}},
+		{"outgoing", HeaderMapping{HTTPHeaderPattern: `X-(\w+)`, GRPCMetadata: "$1", Direction: Outgoing}},
+		{"empty template", HeaderMapping{HTTPHeaderPattern: `X-(\w+)`, GRPCMetadata: " "}},
+		{"required", HeaderMapping{HTTPHeaderPattern: `X-(\w+)`, GRPCMetadata: "$1", Required: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateConfig(&Config{Mappings: []HeaderMapping{tt.mapping}})
+			if !errors.Is(err, ErrValidationFailed) {
+				t.Errorf("ValidateConfig() error = %v, want ErrValidationFailed", err)
+			}
+		})
+	}
+}
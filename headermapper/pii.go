@@ -0,0 +1,105 @@
+package headermapper
+
+import (
+	"regexp"
+	"strings"
+)
+
+// PII detection and masking transforms. They mask matches found anywhere in
+// a header value, so they are safe to apply to free-form values.
+
+var (
+	emailPattern      = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	panPattern        = regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`)
+	phonePattern      = regexp.MustCompile(`\+?\(?\d[\d\s().\-]{7,}\d`)
+	nationalIDPattern = regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)
+)
+
+// Redacted replaces values removed by RedactPattern
+const Redacted = "[REDACTED]"
+
+// MaskEmail masks the local part of email addresses, keeping its first character
+func MaskEmail(value string) string {
+	return emailPattern.ReplaceAllStringFunc(value, func(email string) string {
+		at := strings.LastIndexByte(email, '@')
+		return email[:1] + strings.Repeat("*", at-1) + email[at:]
+	})
+}
+
+// MaskPAN masks credit card numbers passing the Luhn check, keeping the last four digits
+func MaskPAN(value string) string {
+	return panPattern.ReplaceAllStringFunc(value, func(match string) string {
+		if !luhnValid(match) {
+			return match
+		}
+		return maskDigits(match, 4)
+	})
+}
+
+// MaskPhone masks phone numbers, keeping the last four digits
+func MaskPhone(value string) string {
+	return phonePattern.ReplaceAllStringFunc(value, func(match string) string {
+		return maskDigits(match, 4)
+	})
+}
+
+// MaskNationalID masks national identifiers in the US SSN format, keeping the last four digits
+func MaskNationalID(value string) string {
+	return nationalIDPattern.ReplaceAllStringFunc(value, func(match string) string {
+		return maskDigits(match, 4)
+	})
+}
+
+// RedactPattern replaces matches of a regular expression with [REDACTED]
+func RedactPattern(pattern string) TransformFunc {
+	return RegexReplace(pattern, Redacted)
+}
+
+// MaskPII applies MaskEmail, MaskPAN, MaskNationalID and MaskPhone
+func MaskPII(value string) string {
+	// Card numbers and national IDs are masked before the broader phone pattern
+	return MaskPhone(MaskNationalID(MaskPAN(MaskEmail(value))))
+}
+
+// maskDigits replaces all but the last keep digits with '*', preserving separators
+func maskDigits(value string, keep int) string {
+	digits := 0
+	for i := 0; i < len(value); i++ {
+		if value[i] >= '0' && value[i] <= '9' {
+			digits++
+		}
+	}
+
+	b := []byte(value)
+	for i := range b {
+		if b[i] >= '0' && b[i] <= '9' {
+			if digits > keep {
+				b[i] = '*'
+			}
+			digits--
+		}
+	}
+	return string(b)
+}
+
+// luhnValid reports whether the digits in value pass the Luhn checksum
+func luhnValid(value string) bool {
+	sum := 0
+	double := false
+	for i := len(value) - 1; i >= 0; i-- {
+		c := value[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
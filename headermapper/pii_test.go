@@ -0,0 +1,37 @@
+package headermapper
+
+import "testing"
+
+func TestPIITransforms(t *testing.T) {
+	tests := []struct {
+		name      string
+		transform TransformFunc
+		input     string
+		expected  string
+	}{
+		{"MaskEmail", MaskEmail, "jane.doe@example.com", "j*******@example.com"},
+		{"MaskEmail embedded", MaskEmail, "user=bob@corp.io;tier=gold", "user=b**@corp.io;tier=gold"},
+		{"MaskEmail no match", MaskEmail, "not-an-email", "not-an-email"},
+		{"MaskPAN", MaskPAN, "4111 1111 1111 1111", "**** **** **** 1111"},
+		{"MaskPAN dashes", MaskPAN, "card:5500-0000-0000-0004", "card:****-****-****-0004"},
+		{"MaskPAN fails Luhn", MaskPAN, "1234567812345678", "1234567812345678"},
+		{"MaskPhone", MaskPhone, "+1 (555) 123-4567", "+* (***) ***-4567"},
+		{"MaskNationalID", MaskNationalID, "ssn 123-45-6789", "ssn ***-**-6789"},
+		{"RedactPattern", RedactPattern(`secret=\w+`), "a=1&secret=xyz", "a=1&[REDACTED]"},
+		{
+			"MaskPII",
+			MaskPII,
+			"a@b.co 4111111111111111 123-45-6789 +44 20 7946 0958",
+			"a@b.co ************1111 ***-**-6789 +** ** **** 0958",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.transform(tt.input)
+			if got != tt.expected {
+				t.Errorf("Transform(%s) = %s, want %s", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package headermapper
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Pipeline is a typed, immutable transform chain compiled by Build into one
+// fused TransformFunc. Each method returns a new pipeline, so a shared base
+// can be extended safely:
+//
+//	token := headermapper.NewPipeline("bearer-uuid").
+//		Trim().
+//		RemovePrefix("Bearer ").
+//		Validate(headermapper.IsUUID).
+//		Lower().
+//		Build()
+type Pipeline struct {
+	name   string
+	stages []pipelineStage
+}
+
+// pipelineStage is a transform step or a validation ending the pipeline
+// with an empty value when it fails
+type pipelineStage struct {
+	step  TransformStep
+	check func(string) bool
+	// label describes the stage for String
+	label string
+}
+
+// NewPipeline starts an empty pipeline; name identifies it in descriptions
+// and registries and may be empty
+func NewPipeline(name string) Pipeline {
+	return Pipeline{name: name}
+}
+
+// Name returns the name of the pipeline
+func (p Pipeline) Name() string {
+	return p.name
+}
+
+// with returns a copy of p with stage appended, never sharing the backing
+// array with p
+func (p Pipeline) with(stage pipelineStage) Pipeline {
+	p.stages = append(p.stages[:len(p.stages):len(p.stages)], stage)
+	return p
+}
+
+// Trim trims surrounding whitespace
+func (p Pipeline) Trim() Pipeline {
+	return p.with(pipelineStage{step: TrimSpaceStep(), label: "trim"})
+}
+
+// RemovePrefix removes prefix if present
+func (p Pipeline) RemovePrefix(prefix string) Pipeline {
+	return p.with(pipelineStage{step: RemovePrefixStep(prefix), label: "remove_prefix(" + strconv.Quote(prefix) + ")"})
+}
+
+// RemoveSuffix removes suffix if present
+func (p Pipeline) RemoveSuffix(suffix string) Pipeline {
+	return p.with(pipelineStage{step: RemoveSuffixStep(suffix), label: "remove_suffix(" + strconv.Quote(suffix) + ")"})
+}
+
+// AddPrefix prepends prefix
+func (p Pipeline) AddPrefix(prefix string) Pipeline {
+	return p.with(pipelineStage{step: AddPrefixStep(prefix), label: "add_prefix(" + strconv.Quote(prefix) + ")"})
+}
+
+// AddSuffix appends suffix
+func (p Pipeline) AddSuffix(suffix string) Pipeline {
+	return p.with(pipelineStage{step: AddSuffixStep(suffix), label: "add_suffix(" + strconv.Quote(suffix) + ")"})
+}
+
+// Lower converts to lowercase
+func (p Pipeline) Lower() Pipeline {
+	return p.with(pipelineStage{step: ToLowerStep(), label: "lower"})
+}
+
+// Upper converts to uppercase
+func (p Pipeline) Upper() Pipeline {
+	return p.with(pipelineStage{step: ToUpperStep(), label: "upper"})
+}
+
+// Validate rejects values failing valid: the pipeline stops and returns an
+// empty string, which skips an incoming mapping
+func (p Pipeline) Validate(valid func(string) bool) Pipeline {
+	return p.with(pipelineStage{check: valid, label: "validate"})
+}
+
+// Then runs an arbitrary transform
+func (p Pipeline) Then(transform TransformFunc) Pipeline {
+	return p.with(pipelineStage{step: FuncStep(transform), label: "func"})
+}
+
+// String describes the stages of the pipeline, e.g.
+// bearer-uuid: trim | remove_prefix("Bearer ") | validate | lower
+func (p Pipeline) String() string {
+	labels := make([]string, len(p.stages))
+	for i, stage := range p.stages {
+		labels[i] = stage.label
+	}
+	description := strings.Join(labels, " | ")
+	if p.name == "" {
+		return description
+	}
+	return p.name + ": " + description
+}
+
+// PipelineStep reports the value after one stage of a traced pipeline
+type PipelineStep struct {
+	// Stage describes the stage as in String
+	Stage string
+	Value string
+	// Rejected is set when a validation failed, ending the pipeline
+	Rejected bool
+}
+
+// Trace runs the stages of p one at a time on value and reports each
+// intermediate value, for debugging; Build produces the same result faster
+func (p Pipeline) Trace(value string) []PipelineStep {
+	steps := make([]PipelineStep, 0, len(p.stages))
+	for _, stage := range p.stages {
+		if stage.check != nil {
+			if !stage.check(value) {
+				return append(steps, PipelineStep{Stage: stage.label, Rejected: true})
+			}
+			steps = append(steps, PipelineStep{Stage: stage.label, Value: value})
+			continue
+		}
+		value = FuseTransforms(stage.step)(value)
+		steps = append(steps, PipelineStep{Stage: stage.label, Value: value})
+	}
+	return steps
+}
+
+// pipelineStages maps the labels of built-in stages to their methods;
+// stages without an argument ignore it
+var pipelineStages = map[string]struct {
+	arg bool
+	add func(p Pipeline, arg string) Pipeline
+}{
+	"trim":          {add: func(p Pipeline, _ string) Pipeline { return p.Trim() }},
+	"lower":         {add: func(p Pipeline, _ string) Pipeline { return p.Lower() }},
+	"upper":         {add: func(p Pipeline, _ string) Pipeline { return p.Upper() }},
+	"remove_prefix": {arg: true, add: Pipeline.RemovePrefix},
+	"remove_suffix": {arg: true, add: Pipeline.RemoveSuffix},
+	"add_prefix":    {arg: true, add: Pipeline.AddPrefix},
+	"add_suffix":    {arg: true, add: Pipeline.AddSuffix},
+}
+
+// ParsePipeline parses a list of built-in stages in the form printed by
+// String, e.g. trim | remove_prefix("Bearer ") | lower, so pipelines can be
+// given on the command line or in configuration files
+func ParsePipeline(name, spec string) (Pipeline, error) {
+	p := NewPipeline(name)
+	rest := strings.TrimSpace(spec)
+	for rest != "" {
+		label := rest
+		if i := strings.IndexAny(rest, "(|"); i >= 0 {
+			label = rest[:i]
+		}
+		rest = rest[len(label):]
+		label = strings.TrimSpace(label)
+
+		stage, ok := pipelineStages[label]
+		if !ok {
+			return Pipeline{}, fmt.Errorf("pipeline: unknown stage %q", label)
+		}
+		var arg string
+		if strings.HasPrefix(rest, "(") {
+			quoted, err := strconv.QuotedPrefix(rest[1:])
+			if err != nil || !strings.HasPrefix(rest[1+len(quoted):], ")") {
+				return Pipeline{}, fmt.Errorf("pipeline: stage %s needs a quoted argument", label)
+			}
+			arg, _ = strconv.Unquote(quoted)
+			rest = rest[len(quoted)+2:]
+			if !stage.arg {
+				return Pipeline{}, fmt.Errorf("pipeline: stage %s takes no argument", label)
+			}
+		} else if stage.arg {
+			return Pipeline{}, fmt.Errorf("pipeline: stage %s needs a quoted argument", label)
+		}
+		p = stage.add(p, arg)
+
+		rest = strings.TrimSpace(rest)
+		if rest == "" {
+			break
+		}
+		if rest[0] != '|' || strings.TrimSpace(rest[1:]) == "" {
+			return Pipeline{}, fmt.Errorf("pipeline: expected | before %q", rest)
+		}
+		rest = strings.TrimSpace(rest[1:])
+	}
+	return p, nil
+}
+
+// Build compiles the pipeline into one TransformFunc. Steps between
+// validations are fused with FuseTransforms.
+func (p Pipeline) Build() TransformFunc {
+	type segment struct {
+		transform TransformFunc
+		check     func(string) bool
+	}
+
+	var segments []segment
+	var steps []TransformStep
+	flush := func() {
+		if len(steps) > 0 {
+			segments = append(segments, segment{transform: FuseTransforms(steps...)})
+			steps = nil
+		}
+	}
+	for _, stage := range p.stages {
+		if stage.check != nil {
+			flush()
+			segments = append(segments, segment{check: stage.check})
+			continue
+		}
+		steps = append(steps, stage.step)
+	}
+	flush()
+
+	switch {
+	case len(segments) == 0:
+		return func(value string) string { return value }
+	case len(segments) == 1 && segments[0].transform != nil:
+		return segments[0].transform
+	}
+	return func(value string) string {
+		for _, segment := range segments {
+			if segment.check != nil {
+				if !segment.check(value) {
+					return ""
+				}
+				continue
+			}
+			value = segment.transform(value)
+		}
+		return value
+	}
+}
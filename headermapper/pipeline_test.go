@@ -0,0 +1,123 @@
+package headermapper
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPipeline(t *testing.T) {
+	const id = "123E4567-E89B-12D3-A456-426614174000"
+
+	tests := []struct {
+		name     string
+		pipeline Pipeline
+		input    string
+		expected string
+	}{
+		{"empty", NewPipeline(""), " x ", " x "},
+		{"trim and lower", NewPipeline("").Trim().Lower(), "  ABC  ", "abc"},
+		{"validated token", NewPipeline("").Trim().RemovePrefix("Bearer ").Validate(IsUUID).Lower(), " Bearer " + id, strings.ToLower(id)},
+		{"validation failure", NewPipeline("").Trim().RemovePrefix("Bearer ").Validate(IsUUID).AddPrefix("id:"), "Bearer nope", ""},
+		{"prefix and suffix", NewPipeline("").RemoveSuffix(".com").AddPrefix("host:").AddSuffix("!").Upper(), "example.com", "HOST:EXAMPLE!"},
+		{"custom transform", NewPipeline("").Then(ExtractBearerToken).Validate(func(v string) bool { return v != "" }), "Bearer t", "t"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pipeline.Build()(tt.input); got != tt.expected {
+				t.Errorf("Build()(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPipeline_Immutable(t *testing.T) {
+	base := NewPipeline("base").Trim()
+	lower := base.Lower()
+	upper := base.Upper()
+
+	if got := lower.Build()(" Ab "); got != "ab" {
+		t.Errorf("lower = %q", got)
+	}
+	if got := upper.Build()(" Ab "); got != "AB" {
+		t.Errorf("upper = %q", got)
+	}
+	if got := base.Build()(" Ab "); got != "Ab" {
+		t.Errorf("base = %q", got)
+	}
+}
+
+func TestPipeline_String(t *testing.T) {
+	p := NewPipeline("bearer-uuid").Trim().RemovePrefix("Bearer ").Validate(IsUUID).Lower()
+	if got, want := p.String(), `bearer-uuid: trim | remove_prefix("Bearer ") | validate | lower`; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if p.Name() != "bearer-uuid" {
+		t.Errorf("Name() = %q", p.Name())
+	}
+}
+
+func TestPipeline_Trace(t *testing.T) {
+	p := NewPipeline("bearer").Trim().RemovePrefix("Bearer ").Validate(IsUUID).Lower()
+
+	steps := p.Trace("  Bearer 550E8400-E29B-41D4-A716-446655440000 ")
+	want := []PipelineStep{
+		{Stage: "trim", Value: "Bearer 550E8400-E29B-41D4-A716-446655440000"},
+		{Stage: `remove_prefix("Bearer ")`, Value: "550E8400-E29B-41D4-A716-446655440000"},
+		{Stage: "validate", Value: "550E8400-E29B-41D4-A716-446655440000"},
+		{Stage: "lower", Value: "550e8400-e29b-41d4-a716-446655440000"},
+	}
+	if len(steps) != len(want) {
+		t.Fatalf("Trace() = %+v", steps)
+	}
+	for i := range want {
+		if steps[i] != want[i] {
+			t.Errorf("step %d = %+v, want %+v", i, steps[i], want[i])
+		}
+	}
+
+	steps = p.Trace("Bearer abc")
+	if last := steps[len(steps)-1]; len(steps) != 3 || !last.Rejected {
+		t.Errorf("Trace() of invalid value = %+v, want rejection at validate", steps)
+	}
+}
+
+func TestParsePipeline(t *testing.T) {
+	tests := []struct {
+		spec     string
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{spec: `trim | remove_prefix("Bearer ") | lower`, input: " Bearer ABC ", expected: "abc"},
+		{spec: `add_prefix("v") |add_suffix("|x")`, input: "1", expected: "v1|x"},
+		{spec: "", input: "Value", expected: "Value"},
+		{spec: "upper", input: "abc", expected: "ABC"},
+		{spec: "reverse", wantErr: true},
+		{spec: "remove_prefix", wantErr: true},
+		{spec: `trim("x")`, wantErr: true},
+		{spec: `remove_prefix("x"`, wantErr: true},
+		{spec: "trim lower", wantErr: true},
+		{spec: "trim |", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			p, err := ParsePipeline("test", tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePipeline() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := p.Build()(tt.input); got != tt.expected {
+				t.Errorf("pipeline(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+			if tt.spec != "" {
+				if round, err := ParsePipeline("test", strings.TrimPrefix(p.String(), "test: ")); err != nil || round.String() != p.String() {
+					t.Errorf("String() %q does not parse back: %v", p.String(), err)
+				}
+			}
+		})
+	}
+}
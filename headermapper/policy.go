@@ -0,0 +1,294 @@
+package headermapper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// RequestError is returned when a request is rejected by a policy check.
+// Code determines both the gRPC status and the HTTP status written by Handler.
+type RequestError struct {
+	Code    codes.Code
+	Message string
+	// details are attached to the gRPC status
+	details []protoadapt.MessageV1
+}
+
+// Error implements the error interface
+func (e *RequestError) Error() string {
+	return e.Message
+}
+
+// GRPCStatus converts the error to a gRPC status
+func (e *RequestError) GRPCStatus() *status.Status {
+	st := status.New(e.Code, e.Message)
+	if len(e.details) > 0 {
+		if detailed, err := st.WithDetails(e.details...); err == nil {
+			return detailed
+		}
+	}
+	return st
+}
+
+// HTTPStatus returns the HTTP status code for the error
+func (e *RequestError) HTTPStatus() int {
+	return runtime.HTTPStatusFromCode(e.Code)
+}
+
+// rejectf creates a RequestError with a formatted message
+func rejectf(code codes.Code, format string, args ...interface{}) error {
+	return &RequestError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// validatePolicies validates the policy sections of a configuration
+func validatePolicies(config *Config) error {
+	if err := config.DuplicateHeaders.validate(); err != nil {
+		return err
+	}
+	if err := config.OutgoingOrder.validate(); err != nil {
+		return err
+	}
+	if err := validateBlockedHeaders(config.BlockedHeaders); err != nil {
+		return err
+	}
+	if err := validateStatusKey(config.StatusMetadataKey); err != nil {
+		return err
+	}
+	if err := validateMappingBudget(config.MappingBudget); err != nil {
+		return err
+	}
+	if config.ParallelMapping != nil {
+		if err := config.ParallelMapping.validate(); err != nil {
+			return err
+		}
+	}
+	if config.FIPSMode {
+		if err := validateFIPS(config); err != nil {
+			return err
+		}
+	}
+	if _, err := parseCIDRs(config.TrustedProxies); err != nil {
+		return fmt.Errorf("trusted proxies: %w", err)
+	}
+	if config.Signature != nil {
+		if err := config.Signature.validate(); err != nil {
+			return err
+		}
+	}
+	if config.SPIFFE != nil {
+		if err := config.SPIFFE.validate(); err != nil {
+			return err
+		}
+	}
+	if config.JWTClaims != nil {
+		if err := config.JWTClaims.validate(); err != nil {
+			return err
+		}
+	}
+	if config.BasicAuth != nil {
+		if err := config.BasicAuth.validate(); err != nil {
+			return err
+		}
+	}
+	for i := range config.Composites {
+		if err := config.Composites[i].validate(); err != nil {
+			return fmt.Errorf("composite %d: %w", i, err)
+		}
+	}
+	if config.Authorization != nil {
+		if err := config.Authorization.validate(); err != nil {
+			return err
+		}
+	}
+	if config.RateLimit != nil {
+		if err := config.RateLimit.validate(); err != nil {
+			return err
+		}
+	}
+	if config.IPFilter != nil {
+		if err := config.IPFilter.validate(); err != nil {
+			return err
+		}
+	}
+	if config.ReplayGuard != nil {
+		if err := config.ReplayGuard.validate(); err != nil {
+			return err
+		}
+	}
+	if config.MetadataLimit != nil {
+		if err := config.MetadataLimit.validate(); err != nil {
+			return err
+		}
+	}
+	if config.Audit != nil {
+		if err := config.Audit.validate(); err != nil {
+			return err
+		}
+	}
+	if config.Encryption != nil {
+		if err := config.Encryption.validate(); err != nil {
+			return err
+		}
+	}
+	if config.PropagationSigning != nil {
+		if err := config.PropagationSigning.validate(); err != nil {
+			return err
+		}
+	}
+	if config.SharedSecret != nil {
+		if err := config.SharedSecret.validate(); err != nil {
+			return err
+		}
+	}
+	if config.TransformCache != nil {
+		if err := config.TransformCache.validate(); err != nil {
+			return err
+		}
+	}
+	if config.CORS != nil {
+		if err := config.CORS.validate(); err != nil {
+			return err
+		}
+	}
+	if config.Idempotency != nil {
+		if err := config.Idempotency.validate(); err != nil {
+			return err
+		}
+	}
+	if config.Timeout != nil {
+		if err := config.Timeout.validate(); err != nil {
+			return err
+		}
+	}
+	if err := validateDeadlineHeader(config.DeadlineHeader); err != nil {
+		return err
+	}
+	if config.SSE != nil {
+		if err := config.SSE.validate(); err != nil {
+			return err
+		}
+	}
+	if config.ETag != nil {
+		if err := config.ETag.validate(); err != nil {
+			return err
+		}
+	}
+	if config.Experiment != nil {
+		if err := config.Experiment.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// requestCheck inspects an incoming HTTP request before it reaches the gateway.
+// A non-nil error rejects the request.
+type requestCheck func(w http.ResponseWriter, req *http.Request) error
+
+// Handler wraps an HTTP handler, typically the gateway ServeMux, and rejects
+// requests failing the configured policy checks before they are forwarded.
+// It also answers CORS preflight requests when CORS is configured, applies
+// client-requested timeouts when Timeout is configured, deduplicates
+// retried requests when Idempotency is configured, ends event streams
+// with their trailers when SSE is configured, answers conditional GET
+// requests with 304 Not Modified when ETag is configured and assigns
+// sticky experiment variants when Experiment is configured. Clients sending
+// "TE: trailers" receive the values of trailer mappings as HTTP trailers.
+//
+//	mux := headermapper.CreateGatewayMux(mapper)
+//	http.ListenAndServe(":8080", mapper.Handler(mux))
+func (hm *HeaderMapper) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if hm.internalNamespaces != nil {
+			req = req.Clone(req.Context())
+			hm.stripInternalHeaders(req.Header)
+		}
+
+		// Preflight requests are answered before any checks run
+		cc := hm.state()
+		if cc.cors != nil && cc.cors.handle(w, req) {
+			return
+		}
+
+		if hm.timeout != nil {
+			var cancel context.CancelFunc
+			req, cancel = hm.timeout.applyHTTP(req)
+			defer cancel()
+		}
+
+		if cc.index.outgoingTrailers {
+			req = withTrailers(req)
+		}
+		if !cc.skipPaths[req.URL.Path] && len(hm.requestChecks) > 0 {
+			responseMD := metadata.MD{}
+			req = req.WithContext(withRequestMemo(context.WithValue(req.Context(), responseMetadataKey{}, responseMD)))
+
+			for _, check := range hm.requestChecks {
+				if err := check(w, req); err != nil {
+					if cc.config.Debug {
+						hm.log().Debug("Request rejected:", req.URL.Path, err)
+					}
+					if hm.auditor != nil {
+						hm.auditor.record(req.Context(), "http", req.URL.Path, hm.annotateOnce(cc, req), err)
+					}
+					hm.applyOutgoing(cc, responseMD, w)
+					writeError(w, req, next, err)
+					return
+				}
+			}
+		}
+
+		if hm.experiment != nil && !cc.skipPaths[req.URL.Path] {
+			// The assigned variant is mapped as well, so metadata the checks
+			// mapped without it must not be reused
+			if assigned := hm.experiment.assign(w, req); assigned != req {
+				forgetMappedMetadata(assigned.Context())
+				req = assigned
+			}
+		}
+		if hm.sse != nil {
+			next = hm.sse.handler(next)
+		}
+		if hm.conditional != nil {
+			next = hm.conditional.handler(next)
+		}
+		if hm.idempotency != nil && !cc.skipPaths[req.URL.Path] {
+			hm.idempotency.serve(w, req, next)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// responseMetadataKey is the context key for metadata produced by request
+// checks that is mapped to the response alongside the backend's metadata
+type responseMetadataKey struct{}
+
+// responseMetadataFromContext returns the gateway-produced response metadata
+func responseMetadataFromContext(ctx context.Context) (metadata.MD, bool) {
+	md, ok := ctx.Value(responseMetadataKey{}).(metadata.MD)
+	return md, ok
+}
+
+// writeError writes err to the response, using the gateway's error handler
+// when next is a grpc-gateway ServeMux
+func writeError(w http.ResponseWriter, req *http.Request, next http.Handler, err error) {
+	if mux, ok := next.(*runtime.ServeMux); ok {
+		_, outbound := runtime.MarshalerForRequest(mux, req)
+		runtime.HTTPError(req.Context(), mux, outbound, w, req, err)
+		return
+	}
+
+	code := http.StatusInternalServerError
+	if st, ok := status.FromError(err); ok {
+		code = runtime.HTTPStatusFromCode(st.Code())
+	}
+	http.Error(w, err.Error(), code)
+}
@@ -0,0 +1,56 @@
+package headermapper
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// maxPooledScratch bounds the capacity of buffers returned to the pool so a
+// single large request does not pin memory
+const maxPooledScratch = 64 << 10
+
+// scratchBuffer is per-request scratch space for building canonical strings,
+// joined values and transform output without intermediate allocations
+type scratchBuffer struct {
+	buf []byte
+}
+
+var (
+	scratchGets   atomic.Int64
+	scratchMisses atomic.Int64
+
+	scratchPool = sync.Pool{
+		New: func() any {
+			scratchMisses.Add(1)
+			return &scratchBuffer{buf: make([]byte, 0, 256)}
+		},
+	}
+)
+
+// getScratch returns an empty scratch buffer from the pool
+func getScratch() *scratchBuffer {
+	scratchGets.Add(1)
+	s := scratchPool.Get().(*scratchBuffer)
+	s.buf = s.buf[:0]
+	return s
+}
+
+// putScratch returns a scratch buffer to the pool; its contents must no
+// longer be referenced
+func putScratch(s *scratchBuffer) {
+	if cap(s.buf) > maxPooledScratch {
+		return
+	}
+	scratchPool.Put(s)
+}
+
+// appendJoined appends values separated by sep to buf
+func appendJoined(buf []byte, values []string, sep byte) []byte {
+	for i, value := range values {
+		if i > 0 {
+			buf = append(buf, sep)
+		}
+		buf = append(buf, value...)
+	}
+	return buf
+}
@@ -0,0 +1,68 @@
+package headermapper
+
+import (
+	"testing"
+)
+
+func TestAppendJoined(t *testing.T) {
+	tests := []struct {
+		values   []string
+		expected string
+	}{
+		{nil, ""},
+		{[]string{"a"}, "a"},
+		{[]string{"a", "b", "c"}, "a,b,c"},
+		{[]string{"", "b"}, ",b"},
+	}
+
+	for _, tt := range tests {
+		if got := string(appendJoined(nil, tt.values, ',')); got != tt.expected {
+			t.Errorf("appendJoined(%v) = %q, want %q", tt.values, got, tt.expected)
+		}
+	}
+}
+
+func TestScratchPool(t *testing.T) {
+	s := getScratch()
+	s.buf = append(s.buf, "leftover"...)
+	putScratch(s)
+
+	if got := getScratch(); len(got.buf) != 0 {
+		t.Errorf("scratch buffer not reset: %q", got.buf)
+	}
+
+	large := &scratchBuffer{buf: make([]byte, 0, maxPooledScratch+1)}
+	putScratch(large)
+}
+
+func TestGetStats_Pool(t *testing.T) {
+	mapper := NewBuilder().Build()
+	before := mapper.GetStats()
+
+	MaskSensitive(2)("secret-value")
+
+	after := mapper.GetStats()
+	if after.PoolGets != before.PoolGets+1 {
+		t.Errorf("PoolGets = %d, want %d", after.PoolGets, before.PoolGets+1)
+	}
+	if after.PoolMisses < before.PoolMisses || after.PoolMisses > after.PoolGets {
+		t.Errorf("PoolMisses = %d out of range", after.PoolMisses)
+	}
+}
+
+func TestMaskSensitive(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"abcd", "****"},
+		{"abcdef", "ab**ef"},
+		{"secret-value", "se********ue"},
+	}
+
+	for _, tt := range tests {
+		if got := MaskSensitive(2)(tt.input); got != tt.expected {
+			t.Errorf("MaskSensitive(2)(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
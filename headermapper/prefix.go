@@ -0,0 +1,197 @@
+package headermapper
+
+import (
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// isPrefixMapping reports whether mapping is a wildcard mapping such as
+// X-Custom-* -> custom-
+func isPrefixMapping(mapping HeaderMapping) bool {
+	return strings.HasSuffix(mapping.HTTPHeader, "*")
+}
+
+// compilePrefixMapping validates a wildcard mapping. The header and key are
+// stored as prefixes without the trailing "*"; a "*" on the metadata key is
+// optional.
+func compilePrefixMapping(mapping HeaderMapping) (compiledMapping, error) {
+	header := strings.TrimSuffix(mapping.HTTPHeader, "*")
+	if !validHeaderName(header) || strings.Contains(header, "*") {
+		return compiledMapping{}, newMappingError(mapping, ErrValidationFailed, "invalid HTTP header prefix")
+	}
+	key := strings.TrimSuffix(strings.ToLower(mapping.GRPCMetadata), "*")
+	if !validMetadataKey(key) {
+		return compiledMapping{}, newMappingError(mapping, ErrValidationFailed, "invalid gRPC metadata key prefix")
+	}
+	if err := validateOpenMapping(mapping, "wildcard"); err != nil {
+		return compiledMapping{}, err
+	}
+	if mapping.Source != "" && mapping.Source != SourceHeader {
+		return compiledMapping{}, newMappingError(mapping, ErrValidationFailed, "wildcard mappings only read response headers")
+	}
+	transform, err := mappingTransform(mapping)
+	if err != nil {
+		return compiledMapping{}, err
+	}
+
+	header = http.CanonicalHeaderKey(header)
+	return compiledMapping{
+		header:       header,
+		lowerHeader:  strings.ToLower(header),
+		key:          key,
+		appendValues: mapping.AppendValues,
+		transform:    transform,
+		deprecated:   mapping.Deprecated,
+		fromHeader:   true,
+		prefix:       true,
+	}, nil
+}
+
+// validateOpenMapping rejects the options of a mapping matching many
+// headers that only make sense for a single named header
+func validateOpenMapping(mapping HeaderMapping, kind string) error {
+	if mapping.Required || mapping.DefaultValue != "" || mapping.Generator != nil || len(mapping.Aliases) > 0 {
+		return newMappingError(mapping, ErrValidationFailed,
+			kind+" mappings cannot be required, defaulted, generated or aliased")
+	}
+	return nil
+}
+
+// indexPrefixes builds the prefix tries of the wildcard mappings
+func (idx *mappingIndex) indexPrefixes() {
+	seen := make(map[string]bool)
+	for i := range idx.incomingPrefix {
+		mapping := &idx.incomingPrefix[i]
+		if !seen[mapping.lowerHeader] {
+			seen[mapping.lowerHeader] = true
+			idx.incomingPrefixes.insert(mapping.header, mapping)
+		}
+	}
+
+	clear(seen)
+	for i := range idx.outgoingPrefix {
+		mapping := &idx.outgoingPrefix[i]
+		if !seen[mapping.key] {
+			seen[mapping.key] = true
+			idx.outgoingPrefixes.insert(mapping.key, mapping)
+		}
+	}
+}
+
+// prefixedKey returns the metadata key of header under a wildcard mapping.
+// Binary keys are never produced, as header values are text.
+func (m *compiledMapping) prefixedKey(header string) (string, bool) {
+	rest := header[len(m.header):]
+	if rest == "" {
+		return "", false
+	}
+	key := m.key + strings.ToLower(rest)
+	return key, validMetadataKey(key) && !strings.HasSuffix(key, "-bin")
+}
+
+// prefixedHeader returns the response header of a metadata key under a
+// wildcard mapping
+func (m *compiledMapping) prefixedHeader(key string) (string, bool) {
+	rest := key[len(m.key):]
+	if rest == "" {
+		return "", false
+	}
+	header := http.CanonicalHeaderKey(m.header + rest)
+	return header, validHeaderName(header)
+}
+
+// mapIncomingPrefixes applies the incoming wildcard mappings to the headers
+// of req not read by an exact mapping
+func (hm *HeaderMapper) mapIncomingPrefixes(cc *compiledConfig, req *http.Request, md metadata.MD, budget mappingBudget) {
+	idx := cc.index
+	for name, values := range req.Header {
+		mapping, ok := idx.incomingPrefixes.longestPrefix(name)
+		if !ok || idx.mapped[name] || hm.internalHeader(name) || idx.blocked.blocks(name) {
+			continue
+		}
+		// Forwarded headers from untrusted peers are never passed through
+		if forwardedHeaders[name] && !hm.trustForwarded(req) {
+			continue
+		}
+		key, ok := mapping.prefixedKey(name)
+		if !ok || hm.reservedKeys[key] {
+			continue
+		}
+
+		if value, ok := hm.incomingValue(mapping, cc.selectValue(values)); ok {
+			setMatched(cc, md, mapping, key, value)
+		}
+		if budget.exceeded(mapping) {
+			hm.budgetExceeded(cc, mapping)
+			return
+		}
+	}
+}
+
+// setMatched stores the value of a wildcard or pattern mapping under key,
+// following the same rules as setIncoming
+func setMatched(cc *compiledConfig, md metadata.MD, mapping *compiledMapping, key, value string) {
+	existing := md[key]
+	switch {
+	case mapping.appendValues && len(existing) > 0:
+		md[key] = append(existing[:len(existing):len(existing)], value)
+	case !cc.config.OverwriteExisting && len(existing) > 0:
+		return
+	default:
+		md[key] = []string{value}
+	}
+	mapping.counter.applied.Add(1)
+}
+
+// mapOutgoingPrefixes appends the response headers of the outgoing wildcard
+// mappings to writes for the metadata keys not read by an exact mapping
+func (hm *HeaderMapper) mapOutgoingPrefixes(cc *compiledConfig, md metadata.MD, writes []headerWrite, budget mappingBudget) []headerWrite {
+	idx := cc.index
+	for key, values := range md {
+		if len(values) == 0 || idx.mappedKeys[key] || idx.blocked.blocks(key) {
+			continue
+		}
+		mapping, ok := idx.outgoingPrefixes.longestPrefix(key)
+		if !ok {
+			continue
+		}
+		header, ok := mapping.prefixedHeader(key)
+		if !ok || hm.internalHeader(header) || idx.blocked.blocks(header) {
+			continue
+		}
+
+		value := values[0]
+		if mapping.transform != nil {
+			value = mapping.transform(value)
+		}
+		if value != "" {
+			writes = append(writes, headerWrite{header: header, value: value, append: mapping.appendValues})
+			mapping.counter.applied.Add(1)
+		}
+		if budget.exceeded(mapping) {
+			hm.budgetExceeded(cc, mapping)
+			break
+		}
+	}
+	return writes
+}
+
+// matchPrefix returns the metadata key of header under the incoming
+// wildcard mappings, for HeaderMatcher
+func (idx *mappingIndex) matchPrefix(header string) (string, bool) {
+	mapping, ok := idx.incomingPrefixes.longestPrefix(header)
+	if !ok {
+		return "", false
+	}
+	return mapping.prefixedKey(header)
+}
+
+// prefixPattern appends the wildcard to a header prefix if missing
+func prefixPattern(headerPrefix string) string {
+	if strings.HasSuffix(headerPrefix, "*") {
+		return headerPrefix
+	}
+	return headerPrefix + "*"
+}
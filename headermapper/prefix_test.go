@@ -0,0 +1,166 @@
+package headermapper
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestPrefixMapping_Incoming(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-Custom-Tenant", "tenant-id").
+		AddIncomingPrefixMapping("X-Custom-*", "custom-").
+		AddIncomingPrefixMapping("X-Custom-Feature-", "feature-").
+		WithTransform(strings.ToUpper).
+		AddIncomingPrefixMapping("X-Forwarded-*", "fwd-").
+		InternalNamespaces("X-Custom-Internal-").
+		Build()
+	if err := mapper.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		header string
+		value  string
+		key    string
+		want   string
+	}{
+		{"X-Custom-Region", "eu", "custom-region", "eu"},
+		{"x-custom-build-number", "42", "custom-build-number", "42"},
+		{"X-Custom-Feature-Search", "on", "feature-search", "ON"},
+		{"X-Custom-Tenant", "acme", "tenant-id", "acme"},
+		{"X-Custom-Tenant", "acme", "custom-tenant", ""},
+		{"X-Custom-Internal-Shard", "7", "custom-internal-shard", ""},
+		{"X-Custom-", "empty", "custom-", ""},
+		{"X-Custom-Key-Bin", "AAEC", "custom-key-bin", ""},
+		{"X-Forwarded-Host", "api.example.com", "fwd-host", "api.example.com"},
+		{"X-Other", "1", "custom-other", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header+"->"+tt.key, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/v1/echo", nil)
+			req.Header.Set(tt.header, tt.value)
+
+			md := mapper.MetadataAnnotator()(context.Background(), req)
+			var got string
+			if values := md.Get(tt.key); len(values) > 0 {
+				got = values[0]
+			}
+			if got != tt.want {
+				t.Errorf("%s = %q, want %q (md %v)", tt.key, got, tt.want, md)
+			}
+		})
+	}
+}
+
+func TestPrefixMapping_UntrustedForwarded(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingPrefixMapping("X-Forwarded-", "fwd-").
+		TrustedProxies("10.0.0.0/8").
+		Build()
+
+	req := httptest.NewRequest("GET", "/v1/echo", nil)
+	req.RemoteAddr = "203.0.113.7:1234"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	req.Header.Set("X-Forwarded-Tenant", "acme")
+
+	md := mapper.MetadataAnnotator()(context.Background(), req)
+	if got := md.Get("fwd-for"); len(got) != 0 {
+		t.Errorf("fwd-for = %v, want untrusted header dropped", got)
+	}
+	if got := md.Get("fwd-tenant"); len(got) != 1 || got[0] != "acme" {
+		t.Errorf("fwd-tenant = %v", got)
+	}
+}
+
+func TestPrefixMapping_Outgoing(t *testing.T) {
+	mapper := NewBuilder().
+		AddOutgoingMapping("custom-region", "X-Region").
+		AddOutgoingPrefixMapping("custom-", "X-Custom-*").
+		Build()
+
+	w := httptest.NewRecorder()
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{
+		HeaderMD: metadata.Pairs("custom-region", "eu", "custom-shard-id", "7", "other", "x"),
+	})
+	if err := mapper.ResponseModifier()(ctx, w, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	h := w.Header()
+	if got := h.Get("X-Custom-Shard-Id"); got != "7" {
+		t.Errorf("X-Custom-Shard-Id = %q", got)
+	}
+	if got := h.Get("X-Region"); got != "eu" {
+		t.Errorf("X-Region = %q", got)
+	}
+	if got := h.Get("X-Custom-Region"); got != "" {
+		t.Errorf("X-Custom-Region = %q, want exactly mapped key skipped", got)
+	}
+	if got := h.Get("X-Custom-Other"); got != "" {
+		t.Errorf("X-Custom-Other = %q", got)
+	}
+}
+
+func TestPrefixMapping_HeaderMatcher(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").
+		AddIncomingPrefixMapping("X-Custom-", "custom-").
+		Build()
+
+	matcher := mapper.HeaderMatcher()
+	if key, ok := matcher("X-Custom-Region"); !ok || key != "custom-region" {
+		t.Errorf("HeaderMatcher(X-Custom-Region) = %q, %v", key, ok)
+	}
+	if key, ok := matcher("X-User-ID"); !ok || key != "user-id" {
+		t.Errorf("HeaderMatcher(X-User-ID) = %q, %v", key, ok)
+	}
+}
+
+func TestPrefixMapping_Validation(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping HeaderMapping
+	}{
+		{"empty prefix", HeaderMapping{HTTPHeader: "*", GRPCMetadata: "custom-"}},
+		{"inner wildcard", HeaderMapping{HTTPHeader: "X-*-Custom-*", GRPCMetadata: "custom-"}},
+		{"invalid key", HeaderMapping{HTTPHeader: "X-Custom-*", GRPCMetadata: "custom prefix"}},
+		{"required", HeaderMapping{HTTPHeader: "X-Custom-*", GRPCMetadata: "custom-", Required: true}},
+		{"default", HeaderMapping{HTTPHeader: "X-Custom-*", GRPCMetadata: "custom-", DefaultValue: "x"}},
+		{"aliases", HeaderMapping{HTTPHeader: "X-Custom-*", GRPCMetadata: "custom-", Aliases: []string{"X-Old-*"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateConfig(&Config{Mappings: []HeaderMapping{tt.mapping}})
+			if !errors.Is(err, ErrValidationFailed) {
+				t.Errorf("ValidateConfig() error = %v, want ErrValidationFailed", err)
+			}
+		})
+	}
+}
+
+func TestPrefixMapping_Stats(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingPrefixMapping("X-Custom-", "custom-").
+		Build()
+
+	req := httptest.NewRequest("GET", "/v1/echo", nil)
+	req.Header.Set("X-Custom-A", "1")
+	req.Header.Set("X-Custom-B", "2")
+	mapper.MetadataAnnotator()(context.Background(), req)
+
+	stats := mapper.GetStats()
+	if stats.IncomingMappings != 2 {
+		t.Errorf("IncomingMappings = %d, want 2", stats.IncomingMappings)
+	}
+	if len(stats.Mappings) != 1 || stats.Mappings[0].HTTPHeader != "X-Custom-*" || stats.Mappings[0].GRPCMetadata != "custom-*" {
+		t.Errorf("Mappings = %+v", stats.Mappings)
+	}
+}
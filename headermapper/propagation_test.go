@@ -0,0 +1,85 @@
+package headermapper
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestPropagationSigning(t *testing.T) {
+	config := &PropagationSigningConfig{
+		Keys:    []string{"user-id", "tenant-id"},
+		KeyID:   "v2",
+		Secrets: map[string]string{"v1": "old-secret", "v2": "new-secret"},
+	}
+	gateway := NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").
+		AddIncomingMapping("X-Tenant-ID", "tenant-id").
+		SignPropagation(config).
+		Build()
+
+	req := httptest.NewRequest("GET", "/v1/echo", nil)
+	req.Header.Set("X-User-ID", "user-1")
+	req.Header.Set("X-Tenant-ID", "tenant-1")
+	signed := gateway.MetadataAnnotator()(context.Background(), req)
+	if len(signed.Get(HeadersSignatureKey)) != 1 {
+		t.Fatalf("expected %s in metadata", HeadersSignatureKey)
+	}
+
+	forged := signed.Copy()
+	forged.Set("user-id", "admin")
+	stripped := signed.Copy()
+	stripped.Delete("tenant-id")
+	oldKey := signed.Copy()
+	oldKey.Set(HeadersSignatureKey, "keyid=v0,t=0,sig=AAAA")
+
+	expired := NewHeaderMapper(&Config{Mappings: gateway.state().config.Mappings, PropagationSigning: config})
+	expired.propagationSigner.now = func() time.Time { return time.Now().Add(-time.Hour) }
+
+	tests := []struct {
+		name       string
+		md         metadata.MD
+		required   bool
+		expected   codes.Code
+		expectUser string
+	}{
+		{"valid signature", signed, false, codes.OK, "user-1"},
+		{"forged value", forged, false, codes.Unauthenticated, ""},
+		{"stripped value", stripped, false, codes.Unauthenticated, ""},
+		{"unknown key id", oldKey, false, codes.Unauthenticated, ""},
+		{"unsigned values removed", metadata.Pairs("user-id", "admin"), false, codes.OK, ""},
+		{"unsigned required", metadata.Pairs("user-id", "admin"), true, codes.Unauthenticated, ""},
+		{"expired", expired.MetadataAnnotator()(context.Background(), req), false, codes.Unauthenticated, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backendConfig := *config
+			backendConfig.Required = tt.required
+			backend := NewBuilder().SignPropagation(&backendConfig).Build()
+
+			var user string
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				md, _ := metadata.FromIncomingContext(ctx)
+				if values := md.Get("user-id"); len(values) > 0 {
+					user = values[0]
+				}
+				return nil, nil
+			}
+			ctx := metadata.NewIncomingContext(context.Background(), tt.md)
+			_, err := backend.UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/echo.v1.EchoService/Echo"}, handler)
+			if status.Code(err) != tt.expected {
+				t.Errorf("UnaryServerInterceptor() code = %v, want %v", status.Code(err), tt.expected)
+			}
+			if user != tt.expectUser {
+				t.Errorf("user-id = %q, want %q", user, tt.expectUser)
+			}
+		})
+	}
+}
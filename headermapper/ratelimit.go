@@ -0,0 +1,234 @@
+package headermapper
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// Metadata keys set by the rate limiter
+const (
+	RateLimitLimitKey     = "ratelimit-limit"
+	RateLimitRemainingKey = "ratelimit-remaining"
+	RateLimitResetKey     = "ratelimit-reset"
+)
+
+// RateLimitConfig configures token-bucket rate limiting keyed by a mapped value
+type RateLimitConfig struct {
+	// KeyMetadata lists metadata keys identifying the caller, e.g. api-key,
+	// user-id or tenant-id; the first present key is used and requests carrying
+	// none of them are not limited
+	KeyMetadata []string `json:"key_metadata" yaml:"key_metadata"`
+	// Rate is the number of requests replenished per second
+	Rate float64 `json:"rate" yaml:"rate"`
+	// Burst is the bucket capacity
+	Burst int `json:"burst" yaml:"burst"`
+	// Store holds the buckets (default in-memory)
+	Store RateLimitStore `json:"-" yaml:"-"`
+	// FailClosed rejects requests with Unavailable when the store fails,
+	// instead of allowing them
+	FailClosed bool `json:"fail_closed,omitempty" yaml:"fail_closed,omitempty"`
+}
+
+// validate checks the rate and burst
+func (rc *RateLimitConfig) validate() error {
+	if len(rc.KeyMetadata) == 0 {
+		return fmt.Errorf("rate limit: key_metadata cannot be empty")
+	}
+	if rc.Rate <= 0 || rc.Burst <= 0 {
+		return fmt.Errorf("rate limit: rate and burst must be positive")
+	}
+	return nil
+}
+
+// RateLimitResult is the outcome of taking a token from a bucket
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is the time until a token is available when not allowed
+	RetryAfter time.Duration
+}
+
+// RateLimitStore stores token buckets. Implementations backed by shared
+// storage such as Redis allow limits to be enforced across gateway replicas.
+type RateLimitStore interface {
+	// Take removes a token from the bucket for key
+	Take(ctx context.Context, key string, rate float64, burst int) (RateLimitResult, error)
+}
+
+// MemoryRateLimitStore is an in-process RateLimitStore
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	sweepSize int
+	now       func() time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewMemoryRateLimitStore creates an in-memory rate limit store
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets:   make(map[string]*tokenBucket),
+		sweepSize: 1024,
+		now:       time.Now,
+	}
+}
+
+// Take implements RateLimitStore
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, rate float64, burst int) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	capacity := float64(burst)
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		if len(s.buckets) >= s.sweepSize {
+			s.sweep(now, rate, capacity)
+		}
+		bucket = &tokenBucket{tokens: capacity, last: now}
+		s.buckets[key] = bucket
+	} else {
+		bucket.tokens = math.Min(capacity, bucket.tokens+now.Sub(bucket.last).Seconds()*rate)
+		bucket.last = now
+	}
+
+	result := RateLimitResult{Limit: burst}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - bucket.tokens) / rate * float64(time.Second))
+	}
+	result.Remaining = int(bucket.tokens)
+	result.Reset = time.Duration((capacity - bucket.tokens) / rate * float64(time.Second))
+
+	return result, nil
+}
+
+// sweep removes buckets that have refilled completely
+func (s *MemoryRateLimitStore) sweep(now time.Time, rate, capacity float64) {
+	for key, bucket := range s.buckets {
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*rate >= capacity {
+			delete(s.buckets, key)
+		}
+	}
+	if len(s.buckets) >= s.sweepSize {
+		s.sweepSize *= 2
+	}
+}
+
+// rateLimiter enforces a RateLimitConfig
+type rateLimiter struct {
+	config *RateLimitConfig
+	store  RateLimitStore
+	hm     *HeaderMapper
+}
+
+func newRateLimiter(config *RateLimitConfig, hm *HeaderMapper) *rateLimiter {
+	store := config.Store
+	if store == nil {
+		store = NewMemoryRateLimitStore()
+	}
+	return &rateLimiter{config: config, store: store, hm: hm}
+}
+
+// take consumes a token for the caller identified in md and records the
+// rate limit state in out. It reports whether a token was taken, so the
+// caller was limited.
+func (rl *rateLimiter) take(ctx context.Context, md, out metadata.MD) (bool, error) {
+	var key string
+	for _, k := range rl.config.KeyMetadata {
+		if values := md.Get(k); len(values) > 0 && values[0] != "" {
+			key = k + "=" + values[0]
+			break
+		}
+	}
+	if key == "" {
+		return false, nil
+	}
+
+	result, err := rl.store.Take(ctx, key, rl.config.Rate, rl.config.Burst)
+	if err != nil {
+		rl.hm.log().Warn("Rate limit store error:", err)
+		if rl.config.FailClosed {
+			return false, rejectf(codes.Unavailable, "rate limit store unavailable")
+		}
+		// Fail open so an unavailable store does not take down the gateway
+		return false, nil
+	}
+
+	out.Set(RateLimitLimitKey, strconv.Itoa(result.Limit))
+	out.Set(RateLimitRemainingKey, strconv.Itoa(result.Remaining))
+	out.Set(RateLimitResetKey, strconv.FormatInt(int64(math.Ceil(result.Reset.Seconds())), 10))
+
+	if !result.Allowed {
+		out.Set(RetryAfterKey, strconv.FormatInt(int64(math.Ceil(result.RetryAfter.Seconds())), 10))
+		return true, rejectf(codes.ResourceExhausted, "rate limit exceeded")
+	}
+	return true, nil
+}
+
+// check limits HTTP requests, exposing the state through the response metadata
+func (rl *rateLimiter) check(w http.ResponseWriter, req *http.Request) error {
+	out, ok := responseMetadataFromContext(req.Context())
+	if !ok {
+		out = metadata.MD{}
+	}
+	taken, err := rl.take(req.Context(), rl.hm.annotateOnce(rl.hm.state(), req), out)
+	if taken && err == nil {
+		markChecked(req.Context(), rateLimitCheck)
+	}
+	return err
+}
+
+// checkCall limits gRPC calls, sending the state as response header metadata.
+// Calls forwarded by a gateway whose Handler shares the mapper were already
+// charged and are skipped.
+func (rl *rateLimiter) checkCall(ctx context.Context, fullMethod string, md metadata.MD) error {
+	out := metadata.MD{}
+	_, err := rl.take(ctx, md, out)
+	if len(out) > 0 {
+		// Fails outside a gRPC server stream, e.g. in tests
+		_ = grpc.SetHeader(ctx, out)
+	}
+	return err
+}
+
+// RateLimitMappings returns outgoing mappings exposing rate limit state as
+// X-RateLimit-* response headers
+func RateLimitMappings() []HeaderMapping {
+	return []HeaderMapping{
+		{
+			HTTPHeader:   "X-RateLimit-Limit",
+			GRPCMetadata: RateLimitLimitKey,
+			Direction:    Outgoing,
+		},
+		{
+			HTTPHeader:   "X-RateLimit-Remaining",
+			GRPCMetadata: RateLimitRemainingKey,
+			Direction:    Outgoing,
+		},
+		{
+			HTTPHeader:   "X-RateLimit-Reset",
+			GRPCMetadata: RateLimitResetKey,
+			Direction:    Outgoing,
+		},
+	}
+}
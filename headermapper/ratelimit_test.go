@@ -0,0 +1,149 @@
+package headermapper
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestRateLimit_Handler(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-API-Key", "api-key").
+		AddMappings(RateLimitMappings()...).
+		RateLimit(&RateLimitConfig{KeyMetadata: []string{"api-key"}, Rate: 0.001, Burst: 2}).
+		Build()
+
+	var modifierHeaders http.Header
+	handler := mapper.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := runtime.NewServerMetadataContext(r.Context(), runtime.ServerMetadata{HeaderMD: metadata.MD{}})
+		if err := mapper.ResponseModifier()(ctx, w, nil); err != nil {
+			t.Fatal(err)
+		}
+		modifierHeaders = w.Header()
+	}))
+
+	send := func(apiKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/v1/echo", nil)
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("key-1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("first request status = %d", w.Code)
+	}
+	if got := modifierHeaders.Get("X-RateLimit-Remaining"); got != "1" {
+		t.Errorf("X-RateLimit-Remaining = %q, want 1", got)
+	}
+	if got := modifierHeaders.Get("X-RateLimit-Limit"); got != "2" {
+		t.Errorf("X-RateLimit-Limit = %q, want 2", got)
+	}
+
+	send("key-1")
+	w = send("key-1")
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("third request status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if got := w.Header().Get("X-RateLimit-Remaining"); got != "0" {
+		t.Errorf("rejected X-RateLimit-Remaining = %q, want 0", got)
+	}
+
+	// Other keys and unidentified callers have their own budget
+	if w := send("key-2"); w.Code != http.StatusOK {
+		t.Errorf("other key status = %d", w.Code)
+	}
+	if w := send(""); w.Code != http.StatusOK {
+		t.Errorf("anonymous status = %d", w.Code)
+	}
+}
+
+func TestRateLimit_UnaryServerInterceptor(t *testing.T) {
+	mapper := NewBuilder().
+		RateLimit(&RateLimitConfig{KeyMetadata: []string{"tenant-id"}, Rate: 0.001, Burst: 1}).
+		Build()
+	interceptor := mapper.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Method"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("tenant-id", "acme"))
+
+	if _, err := interceptor(ctx, nil, info, handler); err != nil {
+		t.Fatalf("first call error = %v", err)
+	}
+	if _, err := interceptor(ctx, nil, info, handler); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("second call code = %v, want ResourceExhausted", status.Code(err))
+	}
+}
+
+// failingRateLimitStore is a RateLimitStore that is unavailable
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Take(ctx context.Context, key string, rate float64, burst int) (RateLimitResult, error) {
+	return RateLimitResult{}, errors.New("connection refused")
+}
+
+func TestRateLimit_StoreFailure(t *testing.T) {
+	tests := []struct {
+		name       string
+		failClosed bool
+		expected   int
+	}{
+		{"fail open", false, http.StatusOK},
+		{"fail closed", true, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper := NewBuilder().
+				AddIncomingMapping("X-API-Key", "api-key").
+				RateLimit(&RateLimitConfig{KeyMetadata: []string{"api-key"}, Rate: 1, Burst: 1, Store: failingRateLimitStore{}, FailClosed: tt.failClosed}).
+				Build()
+			handler := mapper.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			req := httptest.NewRequest("GET", "/v1/echo", nil)
+			req.Header.Set("X-API-Key", "key-1")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.expected {
+				t.Errorf("Handler() status = %d, want %d", w.Code, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMemoryRateLimitStore_Refill(t *testing.T) {
+	now := time.Unix(0, 0)
+	store := NewMemoryRateLimitStore()
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if r, _ := store.Take(ctx, "k", 1, 2); !r.Allowed {
+			t.Fatalf("take %d not allowed", i)
+		}
+	}
+	if r, _ := store.Take(ctx, "k", 1, 2); r.Allowed {
+		t.Error("expected bucket to be empty")
+	}
+
+	now = now.Add(time.Second)
+	r, _ := store.Take(ctx, "k", 1, 2)
+	if !r.Allowed || r.Remaining != 0 {
+		t.Errorf("after refill: %+v", r)
+	}
+	if r.Reset != 2*time.Second {
+		t.Errorf("Reset = %v, want 2s", r.Reset)
+	}
+}
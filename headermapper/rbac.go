@@ -0,0 +1,148 @@
+package headermapper
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// AuthorizationConfig configures access rules evaluated against mapped metadata
+type AuthorizationConfig struct {
+	// Rules are evaluated in order; the first rule matching the path and method applies
+	Rules []AccessRule `json:"rules" yaml:"rules"`
+	// DefaultDeny rejects requests that match no rule
+	DefaultDeny bool `json:"default_deny" yaml:"default_deny"`
+	// DenialCode is the gRPC code returned on denial, e.g. "PERMISSION_DENIED" (default)
+	// or "NOT_FOUND"; the HTTP status is derived from it
+	DenialCode string `json:"denial_code" yaml:"denial_code"`
+}
+
+// AccessRule grants or denies access to matching paths
+type AccessRule struct {
+	// Paths are HTTP paths or gRPC full method names; a trailing * matches any
+	// suffix and other patterns use path.Match syntax
+	Paths []string `json:"paths" yaml:"paths"`
+	// Methods restricts the rule to HTTP methods; rules with methods never match gRPC calls
+	Methods []string `json:"methods" yaml:"methods"`
+	// Require maps metadata keys to their allowed values; every key must match
+	Require map[string][]string `json:"require" yaml:"require"`
+	// Deny rejects all matching requests
+	Deny bool `json:"deny" yaml:"deny"`
+}
+
+// matches reports whether the rule applies to the path and method. An empty
+// method denotes a gRPC call.
+func (r *AccessRule) matches(p, method string) bool {
+	if len(r.Methods) > 0 {
+		if method == "" {
+			return false
+		}
+		found := false
+		for _, m := range r.Methods {
+			if strings.EqualFold(m, method) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return len(r.Paths) == 0 || matchAnyPath(r.Paths, p)
+}
+
+// satisfied reports whether the metadata meets every requirement of the rule
+func (r *AccessRule) satisfied(md metadata.MD) bool {
+	for key, allowed := range r.Require {
+		if !containsAny(md.Get(key), allowed) {
+			return false
+		}
+	}
+	return true
+}
+
+// validate checks the denial code and rule patterns
+func (ac *AuthorizationConfig) validate() error {
+	if ac.DenialCode != "" {
+		if _, err := parseCode(ac.DenialCode); err != nil {
+			return err
+		}
+	}
+	for i, rule := range ac.Rules {
+		for _, pattern := range rule.Paths {
+			if _, err := path.Match(strings.TrimSuffix(pattern, "*"), ""); err != nil {
+				return fmt.Errorf("access rule %d: invalid path pattern %q: %w", i, pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// authorizer enforces an AuthorizationConfig
+type authorizer struct {
+	config *AuthorizationConfig
+	code   codes.Code
+}
+
+func newAuthorizer(config *AuthorizationConfig) *authorizer {
+	code, err := parseCode(config.DenialCode)
+	if err != nil || config.DenialCode == "" {
+		code = codes.PermissionDenied
+	}
+	return &authorizer{config: config, code: code}
+}
+
+// authorize returns an error if the metadata is not allowed to access the path
+func (a *authorizer) authorize(p, method string, md metadata.MD) error {
+	for i := range a.config.Rules {
+		rule := &a.config.Rules[i]
+		if !rule.matches(p, method) {
+			continue
+		}
+		if rule.Deny || !rule.satisfied(md) {
+			return rejectf(a.code, "access denied: %s", p)
+		}
+		return nil
+	}
+
+	if a.config.DefaultDeny {
+		return rejectf(a.code, "access denied: %s", p)
+	}
+	return nil
+}
+
+// matchPath matches a path against a pattern with an optional trailing wildcard
+func matchPath(pattern, p string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok && !strings.ContainsAny(prefix, "*?[") {
+		return strings.HasPrefix(p, prefix)
+	}
+	matched, err := path.Match(pattern, p)
+	return err == nil && matched
+}
+
+// containsAny reports whether any value is in the allowed list
+func containsAny(values, allowed []string) bool {
+	for _, v := range values {
+		for _, a := range allowed {
+			if v == a {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseCode parses a gRPC code name such as "PERMISSION_DENIED" or "PermissionDenied"
+func parseCode(name string) (codes.Code, error) {
+	normalized := strings.ToLower(strings.ReplaceAll(name, "_", ""))
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		if strings.ToLower(c.String()) == normalized {
+			return c, nil
+		}
+	}
+	return codes.Unknown, fmt.Errorf("unknown status code: %s", name)
+}
@@ -0,0 +1,100 @@
+package headermapper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthorization_Handler(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-User-Role", "user-role").
+		Authorize(&AuthorizationConfig{
+			Rules: []AccessRule{
+				{Paths: []string{"/v1/admin/*"}, Require: map[string][]string{"user-role": {"admin", "operator"}}},
+				{Paths: []string{"/v1/internal/*"}, Deny: true},
+				{Paths: []string{"/v1/reports"}, Methods: []string{"DELETE"}, Require: map[string][]string{"user-role": {"admin"}}},
+			},
+		}).
+		Build()
+	handler := mapper.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		role     string
+		expected int
+	}{
+		{"admin allowed", "GET", "/v1/admin/users", "admin", http.StatusOK},
+		{"operator allowed", "POST", "/v1/admin/users", "operator", http.StatusOK},
+		{"guest denied", "GET", "/v1/admin/users", "guest", http.StatusForbidden},
+		{"missing role denied", "GET", "/v1/admin/users", "", http.StatusForbidden},
+		{"deny rule", "GET", "/v1/internal/debug", "admin", http.StatusForbidden},
+		{"method mismatch allowed", "GET", "/v1/reports", "guest", http.StatusOK},
+		{"method match denied", "DELETE", "/v1/reports", "guest", http.StatusForbidden},
+		{"unmatched path allowed", "GET", "/v1/echo", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.role != "" {
+				req.Header.Set("X-User-Role", tt.role)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.expected {
+				t.Errorf("Handler() status = %d, want %d", w.Code, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAuthorization_UnaryServerInterceptor(t *testing.T) {
+	mapper := NewBuilder().
+		Authorize(&AuthorizationConfig{
+			Rules: []AccessRule{
+				{Paths: []string{"/admin.v1.AdminService/*"}, Require: map[string][]string{"user-role": {"admin"}}},
+			},
+			DefaultDeny: true,
+			DenialCode:  "NOT_FOUND",
+		}).
+		Build()
+	interceptor := mapper.UnaryServerInterceptor()
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return "ok", nil }
+
+	tests := []struct {
+		name     string
+		method   string
+		role     string
+		expected codes.Code
+	}{
+		{"admin allowed", "/admin.v1.AdminService/Delete", "admin", codes.OK},
+		{"guest denied", "/admin.v1.AdminService/Delete", "guest", codes.NotFound},
+		{"default deny", "/echo.v1.EchoService/Echo", "admin", codes.NotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("user-role", tt.role))
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+			if status.Code(err) != tt.expected {
+				t.Errorf("UnaryServerInterceptor() code = %v, want %v", status.Code(err), tt.expected)
+			}
+		})
+	}
+}
+
+func TestAuthorizationConfig_Validate(t *testing.T) {
+	err := ValidateConfig(&Config{Authorization: &AuthorizationConfig{DenialCode: "NOPE"}})
+	if err == nil {
+		t.Error("ValidateConfig() expected error for unknown denial code")
+	}
+}
@@ -0,0 +1,108 @@
+package headermapper
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// transformRegistry holds the named transforms configuration files refer
+// to, seeded with the built-in transforms of the package
+var transformRegistry = struct {
+	sync.RWMutex
+	transforms map[string]TransformFunc
+}{transforms: map[string]TransformFunc{
+	"to_lower":                ToLower,
+	"to_upper":                ToUpper,
+	"trim_space":              TrimSpace,
+	"normalize":               Normalize,
+	"bearer_extract":          ExtractBearerToken,
+	"auth_scheme":             ExtractAuthScheme,
+	"basic_auth_username":     ExtractBasicAuthUsername,
+	"basic_auth_password":     ExtractBasicAuthPassword(BasicAuthPasswordHash, ""),
+	"sanitize_user_agent":     SanitizeUserAgent,
+	"format_timestamp":        FormatTimestamp,
+	"parse_timestamp":         ParseTimestamp,
+	"delta_seconds":           DeltaSeconds,
+	"http_date":               HTTPDate,
+	"validate_uuid":           ValidateUUID,
+	"mask_email":              MaskEmail,
+	"mask_pan":                MaskPAN,
+	"mask_phone":              MaskPhone,
+	"mask_national_id":        MaskNationalID,
+	"mask_pii":                MaskPII,
+	"normalize_api_version":   NormalizeAPIVersion,
+	"normalize_feature_flags": NormalizeFeatureFlags,
+	"format_links":            FormatLinks,
+	"format_etag":             FormatETag,
+	"format_http_time":        FormatHTTPTime,
+	"content_disposition":     ContentDisposition,
+	"sanitize_filename":       SanitizeFilename,
+}}
+
+// RegisterTransform makes transform available to configuration files under
+// name, replacing any transform registered before. Register transforms
+// before loading the configurations using them.
+//
+//	headermapper.RegisterTransform("tenant_slug", tenantSlug)
+func RegisterTransform(name string, transform TransformFunc) {
+	transformRegistry.Lock()
+	defer transformRegistry.Unlock()
+	transformRegistry.transforms[name] = transform
+}
+
+// LookupTransform returns the transform registered under name
+func LookupTransform(name string) (TransformFunc, bool) {
+	transformRegistry.RLock()
+	defer transformRegistry.RUnlock()
+	transform, ok := transformRegistry.transforms[name]
+	return transform, ok && transform != nil
+}
+
+// RegisteredTransforms returns the names of the registered transforms in
+// sorted order
+func RegisteredTransforms() []string {
+	transformRegistry.RLock()
+	defer transformRegistry.RUnlock()
+	names := make([]string, 0, len(transformRegistry.transforms))
+	for name := range transformRegistry.transforms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ResolveTransforms chains the named transforms in order. Names are
+// registered transforms or pipeline specs accepted by ParsePipeline, such
+// as remove_prefix("Bearer ").
+func ResolveTransforms(names []string) (TransformFunc, error) {
+	transforms := make([]TransformFunc, 0, len(names))
+	for _, name := range names {
+		if transform, ok := LookupTransform(name); ok {
+			transforms = append(transforms, transform)
+			continue
+		}
+		pipeline, err := ParsePipeline(name, name)
+		if err != nil {
+			return nil, fmt.Errorf("unknown transform %q", name)
+		}
+		transforms = append(transforms, pipeline.Build())
+	}
+	if len(transforms) == 1 {
+		return transforms[0], nil
+	}
+	return ChainTransforms(transforms...), nil
+}
+
+// mappingTransform returns the transform of mapping, resolving its named
+// transforms when Transform is not set
+func mappingTransform(mapping HeaderMapping) (TransformFunc, error) {
+	if mapping.Transform != nil || len(mapping.TransformNames) == 0 {
+		return mapping.Transform, nil
+	}
+	transform, err := ResolveTransforms(mapping.TransformNames)
+	if err != nil {
+		return nil, newMappingError(mapping, ErrValidationFailed, err.Error())
+	}
+	return transform, nil
+}
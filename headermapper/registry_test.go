@@ -0,0 +1,99 @@
+package headermapper
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestResolveTransforms(t *testing.T) {
+	RegisterTransform("test_reverse", func(value string) string {
+		runes := []rune(value)
+		slices.Reverse(runes)
+		return string(runes)
+	})
+
+	tests := []struct {
+		name    string
+		names   []string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"built-in", []string{"trim_space", "bearer_extract"}, "  Bearer abc ", "abc", false},
+		{"registered", []string{"to_upper", "test_reverse"}, "abc", "CBA", false},
+		{"pipeline stage", []string{`remove_prefix("v")`, "to_upper"}, "v1beta", "1BETA", false},
+		{"unknown", []string{"trim_space", "rot13"}, "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transform, err := ResolveTransforms(tt.names)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveTransforms() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if got := transform(tt.input); got != tt.want {
+					t.Errorf("transform(%q) = %q, want %q", tt.input, got, tt.want)
+				}
+			}
+		})
+	}
+
+	if !slices.Contains(RegisteredTransforms(), "test_reverse") {
+		t.Error("RegisteredTransforms() is missing test_reverse")
+	}
+}
+
+func TestNamedTransforms_ConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mapper.yaml")
+	err := os.WriteFile(path, []byte(`
+mappings:
+  - http_header: "Authorization"
+    grpc_metadata: "token"
+    direction: incoming
+    transform: [trim_space, bearer_extract]
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadConfigFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Mappings[0].Transform == nil {
+		t.Fatal("Transform not resolved at load time")
+	}
+
+	req := httptest.NewRequest("GET", "/v1/echo", nil)
+	req.Header.Set("Authorization", " Bearer abc")
+	md := NewHeaderMapper(config).MetadataAnnotator()(context.Background(), req)
+	if got := md.Get("token"); len(got) != 1 || got[0] != "abc" {
+		t.Errorf("token = %v, want [abc]", got)
+	}
+}
+
+func TestNamedTransforms_Unknown(t *testing.T) {
+	err := ValidateConfig(&Config{Mappings: []HeaderMapping{
+		{HTTPHeader: "X-User", GRPCMetadata: "user", TransformNames: []string{"rot13"}},
+	}})
+	if !errors.Is(err, ErrValidationFailed) || !strings.Contains(err.Error(), "rot13") {
+		t.Errorf("ValidateConfig() error = %v", err)
+	}
+
+	mapper := NewBuilder().
+		AddIncomingMapping("X-User", "user").
+		WithNamedTransforms("normalize").
+		Build()
+	req := httptest.NewRequest("GET", "/v1/echo", nil)
+	req.Header.Set("X-User", " Alice ")
+	if got := mapper.MetadataAnnotator()(context.Background(), req).Get("user"); len(got) != 1 || got[0] != "alice" {
+		t.Errorf("user = %v, want [alice]", got)
+	}
+}
@@ -0,0 +1,217 @@
+package headermapper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// ReplayGuardConfig configures replay protection: requests must carry a unique
+// nonce and a fresh timestamp. Nonces are remembered for twice the allowed skew
+// so that a replayed request is either stale or a duplicate.
+type ReplayGuardConfig struct {
+	// NonceHeader carries the nonce (default X-Nonce)
+	NonceHeader string `json:"nonce_header" yaml:"nonce_header"`
+	// TimestampHeader carries the request time as Unix seconds or RFC 3339 (default X-Timestamp)
+	TimestampHeader string `json:"timestamp_header" yaml:"timestamp_header"`
+	// MaxSkew is the accepted difference between the timestamp and the current time (default 5m)
+	MaxSkew time.Duration `json:"max_skew" yaml:"max_skew"`
+	// Paths restricts protection to matching paths or gRPC methods; empty protects all
+	Paths []string `json:"paths" yaml:"paths"`
+	// Store remembers seen nonces (default in-memory)
+	Store NonceStore `json:"-" yaml:"-"`
+}
+
+func (rc *ReplayGuardConfig) nonceHeader() string {
+	if rc.NonceHeader == "" {
+		return "X-Nonce"
+	}
+	return rc.NonceHeader
+}
+
+func (rc *ReplayGuardConfig) timestampHeader() string {
+	if rc.TimestampHeader == "" {
+		return "X-Timestamp"
+	}
+	return rc.TimestampHeader
+}
+
+func (rc *ReplayGuardConfig) maxSkew() time.Duration {
+	if rc.MaxSkew <= 0 {
+		return 5 * time.Minute
+	}
+	return rc.MaxSkew
+}
+
+// validate checks the allowed skew
+func (rc *ReplayGuardConfig) validate() error {
+	if rc.MaxSkew < 0 {
+		return fmt.Errorf("replay guard: max_skew cannot be negative")
+	}
+	return nil
+}
+
+// NonceStore records nonces. Implementations backed by shared storage such as
+// Redis detect replays across gateway replicas.
+type NonceStore interface {
+	// Remember stores nonce for ttl and reports false if it was already present
+	Remember(ctx context.Context, nonce string, ttl time.Duration) (bool, error)
+}
+
+// NonceStoreFunc adapts a function to NonceStore, e.g. a Redis SET NX:
+//
+//	headermapper.NonceStoreFunc(func(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+//		return rdb.SetNX(ctx, "nonce:"+nonce, 1, ttl).Result()
+//	})
+type NonceStoreFunc func(ctx context.Context, nonce string, ttl time.Duration) (bool, error)
+
+// Remember implements NonceStore
+func (f NonceStoreFunc) Remember(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+	return f(ctx, nonce, ttl)
+}
+
+// MemoryNonceStore is an in-process NonceStore with TTL expiry
+type MemoryNonceStore struct {
+	mu        sync.Mutex
+	expires   map[string]time.Time
+	sweepSize int
+	now       func() time.Time
+}
+
+// NewMemoryNonceStore creates an in-memory nonce store
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{
+		expires:   make(map[string]time.Time),
+		sweepSize: 1024,
+		now:       time.Now,
+	}
+}
+
+// Remember implements NonceStore
+func (s *MemoryNonceStore) Remember(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if expiry, ok := s.expires[nonce]; ok && now.Before(expiry) {
+		return false, nil
+	}
+
+	if len(s.expires) >= s.sweepSize {
+		for key, expiry := range s.expires {
+			if !now.Before(expiry) {
+				delete(s.expires, key)
+			}
+		}
+		if len(s.expires) >= s.sweepSize {
+			s.sweepSize *= 2
+		}
+	}
+	s.expires[nonce] = now.Add(ttl)
+	return true, nil
+}
+
+// replayGuard enforces a ReplayGuardConfig
+type replayGuard struct {
+	config          *ReplayGuardConfig
+	store           NonceStore
+	nonceHeader     string
+	timestampHeader string
+	nonceKey        string
+	timestampKey    string
+	maxSkew         time.Duration
+	now             func() time.Time
+}
+
+func newReplayGuard(config *ReplayGuardConfig) *replayGuard {
+	store := config.Store
+	if store == nil {
+		store = NewMemoryNonceStore()
+	}
+	return &replayGuard{
+		config:          config,
+		store:           store,
+		nonceHeader:     config.nonceHeader(),
+		timestampHeader: config.timestampHeader(),
+		nonceKey:        strings.ToLower(config.nonceHeader()),
+		timestampKey:    strings.ToLower(config.timestampHeader()),
+		maxSkew:         config.maxSkew(),
+		now:             time.Now,
+	}
+}
+
+// protects reports whether a path or gRPC method is protected
+func (g *replayGuard) protects(p string) bool {
+	return len(g.config.Paths) == 0 || matchAnyPath(g.config.Paths, p)
+}
+
+// verify validates the timestamp and records the nonce
+func (g *replayGuard) verify(ctx context.Context, p, nonce, timestamp string) error {
+	if !g.protects(p) {
+		return nil
+	}
+	if nonce == "" || timestamp == "" {
+		return rejectf(codes.Unauthenticated, "missing %s or %s", g.nonceKey, g.timestampKey)
+	}
+
+	ts, err := parseTimestamp(timestamp)
+	if err != nil {
+		return rejectf(codes.Unauthenticated, "invalid %s", g.timestampKey)
+	}
+	skew := g.now().Sub(ts)
+	if skew > g.maxSkew || skew < -g.maxSkew {
+		return rejectf(codes.Unauthenticated, "request timestamp outside allowed window")
+	}
+
+	fresh, err := g.store.Remember(ctx, nonce, 2*g.maxSkew)
+	if err != nil {
+		// Fail closed since accepting a replay may repeat a side effect
+		return rejectf(codes.Unavailable, "nonce store unavailable")
+	}
+	if !fresh {
+		return rejectf(codes.Unauthenticated, "replayed request")
+	}
+	return nil
+}
+
+// check validates HTTP requests
+func (g *replayGuard) check(w http.ResponseWriter, req *http.Request) error {
+	if !g.protects(req.URL.Path) {
+		return nil
+	}
+	if err := g.verify(req.Context(), req.URL.Path, req.Header.Get(g.nonceHeader), req.Header.Get(g.timestampHeader)); err != nil {
+		return err
+	}
+	markChecked(req.Context(), replayCheck)
+	return nil
+}
+
+// checkCall validates gRPC calls carrying the nonce and timestamp as metadata.
+// Calls forwarded by a gateway whose Handler shares the mapper were already
+// validated and are skipped, since the nonce would be seen twice.
+func (g *replayGuard) checkCall(ctx context.Context, fullMethod string, md metadata.MD) error {
+	return g.verify(ctx, fullMethod, firstValue(md, g.nonceKey), firstValue(md, g.timestampKey))
+}
+
+// parseTimestamp parses Unix seconds or an RFC 3339 time
+func parseTimestamp(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// firstValue returns the first value for key or an empty string
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
@@ -0,0 +1,108 @@
+package headermapper
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestReplayGuard_Handler(t *testing.T) {
+	mapper := NewBuilder().
+		GuardReplays(&ReplayGuardConfig{MaxSkew: time.Minute, Paths: []string{"/v1/*"}}).
+		Build()
+	handler := mapper.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	now := time.Now()
+	tests := []struct {
+		name      string
+		path      string
+		nonce     string
+		timestamp string
+		expected  int
+	}{
+		{"fresh request", "/v1/orders", "n-1", strconv.FormatInt(now.Unix(), 10), http.StatusOK},
+		{"replayed nonce", "/v1/orders", "n-1", strconv.FormatInt(now.Unix(), 10), http.StatusUnauthorized},
+		{"rfc3339 timestamp", "/v1/orders", "n-2", now.UTC().Format(time.RFC3339), http.StatusOK},
+		{"stale timestamp", "/v1/orders", "n-3", strconv.FormatInt(now.Add(-2*time.Minute).Unix(), 10), http.StatusUnauthorized},
+		{"future timestamp", "/v1/orders", "n-4", strconv.FormatInt(now.Add(2*time.Minute).Unix(), 10), http.StatusUnauthorized},
+		{"invalid timestamp", "/v1/orders", "n-5", "yesterday", http.StatusUnauthorized},
+		{"missing nonce", "/v1/orders", "", strconv.FormatInt(now.Unix(), 10), http.StatusUnauthorized},
+		{"unprotected path", "/health", "", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.path, nil)
+			if tt.nonce != "" {
+				req.Header.Set("X-Nonce", tt.nonce)
+			}
+			if tt.timestamp != "" {
+				req.Header.Set("X-Timestamp", tt.timestamp)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.expected {
+				t.Errorf("Handler() status = %d, want %d", w.Code, tt.expected)
+			}
+		})
+	}
+}
+
+func TestReplayGuard_UnaryServerInterceptor(t *testing.T) {
+	store := NonceStoreFunc(func(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+		if nonce == "broken" {
+			return false, errors.New("connection refused")
+		}
+		return nonce != "seen", nil
+	})
+	mapper := NewBuilder().GuardReplays(&ReplayGuardConfig{Store: store}).Build()
+	interceptor := mapper.UnaryServerInterceptor()
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return "ok", nil }
+	info := &grpc.UnaryServerInfo{FullMethod: "/echo.v1.EchoService/Echo"}
+
+	tests := []struct {
+		nonce    string
+		expected codes.Code
+	}{
+		{"new", codes.OK},
+		{"seen", codes.Unauthenticated},
+		{"broken", codes.Unavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.nonce, func(t *testing.T) {
+			md := metadata.Pairs("x-nonce", tt.nonce, "x-timestamp", strconv.FormatInt(time.Now().Unix(), 10))
+			_, err := interceptor(metadata.NewIncomingContext(context.Background(), md), nil, info, handler)
+			if status.Code(err) != tt.expected {
+				t.Errorf("UnaryServerInterceptor() code = %v, want %v", status.Code(err), tt.expected)
+			}
+		})
+	}
+}
+
+func TestMemoryNonceStore_Expiry(t *testing.T) {
+	store := NewMemoryNonceStore()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	if fresh, _ := store.Remember(context.Background(), "n", time.Minute); !fresh {
+		t.Fatal("first Remember() should be fresh")
+	}
+	if fresh, _ := store.Remember(context.Background(), "n", time.Minute); fresh {
+		t.Error("second Remember() should detect the replay")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if fresh, _ := store.Remember(context.Background(), "n", time.Minute); !fresh {
+		t.Error("Remember() after expiry should be fresh")
+	}
+}
@@ -0,0 +1,149 @@
+package headermapper
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RetryAfterKey is the metadata key carrying the delay before a client may
+// retry, in seconds or as a Go duration. The error handler sets it from the
+// retry delay of a google.rpc.RetryInfo error detail.
+const RetryAfterKey = "retry-after"
+
+// RetryAfterMappings returns outgoing mappings exposing retry and rate limit
+// state as the standard Retry-After header and the RateLimit-Limit,
+// RateLimit-Remaining and RateLimit-Reset fields of the IETF RateLimit
+// header draft, with delays converted to delta-seconds. Install
+// ErrorHandler, as CreateGatewayMux does, to set them on error responses.
+func RetryAfterMappings() []HeaderMapping {
+	return []HeaderMapping{
+		{
+			HTTPHeader:   "Retry-After",
+			GRPCMetadata: RetryAfterKey,
+			Direction:    Outgoing,
+			Transform:    DeltaSeconds,
+		},
+		{
+			HTTPHeader:   "RateLimit-Limit",
+			GRPCMetadata: RateLimitLimitKey,
+			Direction:    Outgoing,
+		},
+		{
+			HTTPHeader:   "RateLimit-Remaining",
+			GRPCMetadata: RateLimitRemainingKey,
+			Direction:    Outgoing,
+		},
+		{
+			HTTPHeader:   "RateLimit-Reset",
+			GRPCMetadata: RateLimitResetKey,
+			Direction:    Outgoing,
+			Transform:    DeltaSeconds,
+		},
+	}
+}
+
+// DeltaSeconds converts a delay given in seconds, as a Go duration such as
+// "1500ms" or as an HTTP-date into delta-seconds, rounding up; other values
+// are returned unchanged
+func DeltaSeconds(value string) string {
+	d, ok := parseDelay(value)
+	if !ok {
+		return value
+	}
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+// HTTPDate converts a delay given in seconds or as a Go duration into the
+// HTTP-date it elapses at; other values are returned unchanged
+func HTTPDate(value string) string {
+	value = strings.TrimSpace(value)
+	if _, err := http.ParseTime(value); err == nil {
+		return value
+	}
+	d, ok := parseDelay(value)
+	if !ok {
+		return value
+	}
+	return time.Now().Add(d).UTC().Format(http.TimeFormat)
+}
+
+// parseDelay parses delta-seconds, a Go duration or an HTTP-date relative
+// to now; negative delays are clamped to zero
+func parseDelay(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+
+	var d time.Duration
+	if seconds, err := strconv.ParseUint(value, 10, 32); err == nil {
+		d = time.Duration(seconds) * time.Second
+	} else if parsed, err := time.ParseDuration(value); err == nil {
+		d = parsed
+	} else if at, err := http.ParseTime(value); err == nil {
+		d = time.Until(at)
+	} else {
+		return 0, false
+	}
+
+	if d < 0 {
+		d = 0
+	}
+	return d, true
+}
+
+// ErrorHandler returns a grpc-gateway error handler that applies the
+// outgoing mappings to error responses before delegating to next, or
+// runtime.DefaultHTTPErrorHandler when next is nil. Both the header and
+// trailer metadata of the failed call are mapped, and the retry delay of a
+// google.rpc.RetryInfo detail, typically sent with RESOURCE_EXHAUSTED or
+// UNAVAILABLE, is exposed as RetryAfterKey.
+//
+//	mux := runtime.NewServeMux(runtime.WithErrorHandler(mapper.ErrorHandler(nil)))
+func (hm *HeaderMapper) ErrorHandler(next runtime.ErrorHandlerFunc) runtime.ErrorHandlerFunc {
+	if next == nil {
+		next = runtime.DefaultHTTPErrorHandler
+	}
+	return func(ctx context.Context, mux *runtime.ServeMux, marshaler runtime.Marshaler, w http.ResponseWriter, req *http.Request, err error) {
+		var md, trailers metadata.MD
+		// Requests rejected by Handler carry no server metadata and have
+		// already been mapped
+		if sm, ok := runtime.ServerMetadataFromContext(ctx); ok {
+			md, trailers = metadata.Join(sm.HeaderMD, sm.TrailerMD), sm.TrailerMD
+			if gatewayMD, found := responseMetadataFromContext(ctx); found {
+				md = metadata.Join(md, gatewayMD)
+			}
+		}
+		if delay, ok := retryDelay(err); ok && len(md.Get(RetryAfterKey)) == 0 {
+			if md == nil {
+				md = metadata.MD{}
+			}
+			md.Set(RetryAfterKey, delay.String())
+		}
+
+		if len(md) > 0 {
+			hm.applyResponse(hm.state(), md, trailers, w, trailersAccepted(ctx))
+		}
+		next(ctx, mux, marshaler, w, req, err)
+	}
+}
+
+// retryDelay returns the delay of the RetryInfo detail of err
+func retryDelay(err error) (time.Duration, bool) {
+	st, ok := status.FromError(err)
+	if !ok {
+		return 0, false
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok && info.GetRetryDelay() != nil {
+			return info.GetRetryDelay().AsDuration(), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,206 @@
+package headermapper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestDeltaSeconds(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"seconds", "5", "5"},
+		{"duration", "5s", "5"},
+		{"rounded up", "1500ms", "2"},
+		{"minutes", "2m", "120"},
+		{"negative", "-1s", "0"},
+		{"past date", "Wed, 21 Oct 2015 07:28:00 GMT", "0"},
+		{"invalid", "soon", "soon"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DeltaSeconds(tt.input); got != tt.expected {
+				t.Errorf("got %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHTTPDate(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		delay time.Duration
+	}{
+		{"seconds", "120", 120 * time.Second},
+		{"duration", "1h", time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at, err := http.ParseTime(HTTPDate(tt.input))
+			if err != nil {
+				t.Fatalf("HTTPDate(%q) is not an HTTP-date: %v", tt.input, err)
+			}
+			if diff := time.Until(at) - tt.delay; diff < -2*time.Second || diff > time.Second {
+				t.Errorf("HTTPDate(%q) = %v, want about now+%v", tt.input, at, tt.delay)
+			}
+		})
+	}
+
+	date := "Wed, 21 Oct 2015 07:28:00 GMT"
+	if got := HTTPDate(date); got != date {
+		t.Errorf("HTTPDate(%q) = %q, want unchanged", date, got)
+	}
+	if got := HTTPDate("soon"); got != "soon" {
+		t.Errorf("HTTPDate(soon) = %q, want unchanged", got)
+	}
+}
+
+func TestErrorHandler(t *testing.T) {
+	mapper := NewBuilder().
+		AddOutgoingMapping("X-Request-ID", "x-request-id").
+		AddMappings(RetryAfterMappings()...).
+		Build()
+	mux := CreateGatewayMux(mapper)
+
+	withRetry, err := status.New(codes.ResourceExhausted, "quota exceeded").
+		WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(2500 * time.Millisecond)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		err      error
+		md       *runtime.ServerMetadata
+		expected map[string]string
+	}{
+		{
+			name: "retry info and trailers",
+			err:  withRetry.Err(),
+			md: &runtime.ServerMetadata{
+				HeaderMD:  metadata.Pairs("x-request-id", "req-1"),
+				TrailerMD: metadata.Pairs(RateLimitLimitKey, "100", RateLimitRemainingKey, "0", RateLimitResetKey, "30s"),
+			},
+			expected: map[string]string{
+				"X-Request-ID":        "req-1",
+				"Retry-After":         "3",
+				"RateLimit-Limit":     "100",
+				"RateLimit-Remaining": "0",
+				"RateLimit-Reset":     "30",
+			},
+		},
+		{
+			name: "backend retry-after wins",
+			err:  withRetry.Err(),
+			md:   &runtime.ServerMetadata{HeaderMD: metadata.Pairs(RetryAfterKey, "60")},
+			expected: map[string]string{
+				"Retry-After": "60",
+			},
+		},
+		{
+			name: "no server metadata",
+			err:  withRetry.Err(),
+			expected: map[string]string{
+				"Retry-After":  "3",
+				"X-Request-ID": "",
+			},
+		},
+		{
+			name: "plain error",
+			err:  status.Error(codes.Unavailable, "down"),
+			md:   &runtime.ServerMetadata{HeaderMD: metadata.Pairs("x-request-id", "req-2")},
+			expected: map[string]string{
+				"X-Request-ID": "req-2",
+				"Retry-After":  "",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/v1/echo", nil)
+			ctx := req.Context()
+			if tt.md != nil {
+				ctx = runtime.NewServerMetadataContext(ctx, *tt.md)
+			}
+			w := httptest.NewRecorder()
+			runtime.HTTPError(ctx, mux, &runtime.JSONPb{}, w, req, tt.err)
+
+			if w.Code != runtime.HTTPStatusFromCode(status.Code(tt.err)) {
+				t.Errorf("status = %d", w.Code)
+			}
+			for header, want := range tt.expected {
+				if got := w.Header().Get(header); got != want {
+					t.Errorf("%s = %q, want %q", header, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestErrorHandler_Next(t *testing.T) {
+	mapper := NewBuilder().AddOutgoingMapping("X-Request-ID", "x-request-id").Build()
+
+	called := false
+	next := func(ctx context.Context, mux *runtime.ServeMux, m runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+		called = true
+		w.WriteHeader(http.StatusTeapot)
+	}
+	mux := runtime.NewServeMux(runtime.WithErrorHandler(mapper.ErrorHandler(next)))
+
+	req := httptest.NewRequest("GET", "/v1/echo", nil)
+	ctx := runtime.NewServerMetadataContext(req.Context(), runtime.ServerMetadata{HeaderMD: metadata.Pairs("x-request-id", "req-1")})
+	w := httptest.NewRecorder()
+	runtime.HTTPError(ctx, mux, &runtime.JSONPb{}, w, req, status.Error(codes.Internal, "boom"))
+
+	if !called || w.Code != http.StatusTeapot {
+		t.Errorf("next not called, status = %d", w.Code)
+	}
+	if got := w.Header().Get("X-Request-ID"); got != "req-1" {
+		t.Errorf("X-Request-ID = %q, want req-1", got)
+	}
+}
+
+func TestRateLimit_RetryAfter(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-API-Key", "api-key").
+		AddMappings(RetryAfterMappings()...).
+		RateLimit(&RateLimitConfig{KeyMetadata: []string{"api-key"}, Rate: 0.1, Burst: 1}).
+		Build()
+	handler := mapper.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/v1/echo", nil)
+		req.Header.Set("X-API-Key", "key-1")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	send()
+	w := send()
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if got := w.Header().Get("Retry-After"); got != "10" {
+		t.Errorf("Retry-After = %q, want 10", got)
+	}
+	if got := w.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("RateLimit-Remaining = %q, want 0", got)
+	}
+}
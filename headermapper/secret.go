@@ -0,0 +1,97 @@
+package headermapper
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// CompareSecret reports whether provided equals expected in constant time.
+// Both values are hashed first so the comparison does not leak their lengths.
+// Use it in custom validators instead of ==.
+func CompareSecret(provided, expected string) bool {
+	p := sha256.Sum256([]byte(provided))
+	e := sha256.Sum256([]byte(expected))
+	return subtle.ConstantTimeCompare(p[:], e[:]) == 1
+}
+
+// CompareAnySecret reports whether provided matches any of the secrets,
+// comparing against all of them so the position of a match is not leaked
+func CompareAnySecret(provided string, secrets ...string) bool {
+	matched := 0
+	for _, secret := range secrets {
+		if CompareSecret(provided, secret) {
+			matched = 1
+		}
+	}
+	return matched == 1
+}
+
+// SharedSecretConfig requires a shared-secret header, e.g. an internal
+// service token, on matching requests and calls
+type SharedSecretConfig struct {
+	// Header carries the secret; gRPC calls use its lowercase form as the metadata key
+	Header string `json:"header" yaml:"header"`
+	// Secrets lists accepted values; several may be active during rotation
+	Secrets []string `json:"secrets" yaml:"secrets"`
+	// Paths restricts the check to matching paths or gRPC methods; empty checks all
+	Paths []string `json:"paths" yaml:"paths"`
+}
+
+// validate checks the header and secrets
+func (sc *SharedSecretConfig) validate() error {
+	if sc.Header == "" {
+		return fmt.Errorf("shared secret: header cannot be empty")
+	}
+	if len(sc.Secrets) == 0 {
+		return fmt.Errorf("shared secret: secrets cannot be empty")
+	}
+	for _, secret := range sc.Secrets {
+		if secret == "" {
+			return fmt.Errorf("shared secret: secrets cannot be empty")
+		}
+	}
+	return nil
+}
+
+// sharedSecret enforces a SharedSecretConfig
+type sharedSecret struct {
+	config *SharedSecretConfig
+	key    string
+}
+
+func newSharedSecret(config *SharedSecretConfig) *sharedSecret {
+	return &sharedSecret{config: config, key: strings.ToLower(config.Header)}
+}
+
+// verify rejects a missing or incorrect secret
+func (s *sharedSecret) verify(p, provided string) error {
+	if len(s.config.Paths) > 0 && !matchAnyPath(s.config.Paths, p) {
+		return nil
+	}
+	if provided == "" || !CompareAnySecret(provided, s.config.Secrets...) {
+		return rejectf(codes.Unauthenticated, "invalid %s", s.config.Header)
+	}
+	return nil
+}
+
+// check validates HTTP requests
+func (s *sharedSecret) check(w http.ResponseWriter, req *http.Request) error {
+	return s.verify(req.URL.Path, req.Header.Get(s.config.Header))
+}
+
+// checkCall validates gRPC calls and removes the secret from the metadata
+// passed to handlers
+func (s *sharedSecret) checkCall(ctx context.Context, fullMethod string, md metadata.MD) error {
+	if err := s.verify(fullMethod, firstValue(md, s.key)); err != nil {
+		return err
+	}
+	md.Delete(s.key)
+	return nil
+}
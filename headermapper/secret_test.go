@@ -0,0 +1,94 @@
+package headermapper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestCompareSecret(t *testing.T) {
+	tests := []struct {
+		provided string
+		secrets  []string
+		expected bool
+	}{
+		{"s3cret", []string{"s3cret"}, true},
+		{"s3cret", []string{"other", "s3cret"}, true},
+		{"s3cre", []string{"s3cret"}, false},
+		{"", []string{"s3cret"}, false},
+		{"s3cret", nil, false},
+	}
+
+	for _, tt := range tests {
+		if got := CompareAnySecret(tt.provided, tt.secrets...); got != tt.expected {
+			t.Errorf("CompareAnySecret(%q, %v) = %v, want %v", tt.provided, tt.secrets, got, tt.expected)
+		}
+	}
+}
+
+func TestSharedSecret_Handler(t *testing.T) {
+	mapper := NewBuilder().
+		RequireSharedSecret(&SharedSecretConfig{Header: "X-Internal-Token", Secrets: []string{"new", "old"}, Paths: []string{"/internal/*"}}).
+		Build()
+	handler := mapper.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name     string
+		path     string
+		token    string
+		expected int
+	}{
+		{"current secret", "/internal/sync", "new", http.StatusOK},
+		{"rotated secret", "/internal/sync", "old", http.StatusOK},
+		{"wrong secret", "/internal/sync", "guess", http.StatusUnauthorized},
+		{"missing secret", "/internal/sync", "", http.StatusUnauthorized},
+		{"public path", "/v1/echo", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("X-Internal-Token", tt.token)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.expected {
+				t.Errorf("Handler() status = %d, want %d", w.Code, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSharedSecret_UnaryServerInterceptor(t *testing.T) {
+	mapper := NewBuilder().
+		RequireSharedSecret(&SharedSecretConfig{Header: "X-Internal-Token", Secrets: []string{"s3cret"}}).
+		Build()
+
+	var leaked bool
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		leaked = len(md.Get("x-internal-token")) > 0
+		return nil, nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/internal.v1.Sync/Run"}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-internal-token", "s3cret"))
+	if _, err := mapper.UnaryServerInterceptor()(ctx, nil, info, handler); err != nil {
+		t.Fatalf("UnaryServerInterceptor() error = %v", err)
+	}
+	if leaked {
+		t.Error("secret should be removed from handler metadata")
+	}
+
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-internal-token", "guess"))
+	if _, err := mapper.UnaryServerInterceptor()(ctx, nil, info, handler); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("UnaryServerInterceptor() code = %v, want %v", status.Code(err), codes.Unauthenticated)
+	}
+}
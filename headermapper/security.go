@@ -0,0 +1,98 @@
+package headermapper
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SecurityPolicy builds values for the standard security response headers
+type SecurityPolicy struct {
+	csp                []string
+	hsts               string
+	frameOptions       string
+	referrerPolicy     string
+	contentTypeOptions bool
+}
+
+// NewSecurityPolicy creates an empty security policy
+func NewSecurityPolicy() *SecurityPolicy {
+	return &SecurityPolicy{}
+}
+
+// ContentSecurityPolicy adds a Content-Security-Policy directive, e.g.
+// ContentSecurityPolicy("script-src", "'self'", "cdn.example.com")
+func (p *SecurityPolicy) ContentSecurityPolicy(directive string, sources ...string) *SecurityPolicy {
+	p.csp = append(p.csp, strings.TrimSpace(directive+" "+strings.Join(sources, " ")))
+	return p
+}
+
+// StrictTransportSecurity sets the Strict-Transport-Security header
+func (p *SecurityPolicy) StrictTransportSecurity(maxAge time.Duration, includeSubDomains, preload bool) *SecurityPolicy {
+	value := "max-age=" + strconv.FormatInt(int64(maxAge.Seconds()), 10)
+	if includeSubDomains {
+		value += "; includeSubDomains"
+	}
+	if preload {
+		value += "; preload"
+	}
+	p.hsts = value
+	return p
+}
+
+// FrameOptions sets the X-Frame-Options header, e.g. DENY or SAMEORIGIN
+func (p *SecurityPolicy) FrameOptions(option string) *SecurityPolicy {
+	p.frameOptions = option
+	return p
+}
+
+// ReferrerPolicy sets the Referrer-Policy header
+func (p *SecurityPolicy) ReferrerPolicy(policy string) *SecurityPolicy {
+	p.referrerPolicy = policy
+	return p
+}
+
+// NoSniff sets X-Content-Type-Options: nosniff
+func (p *SecurityPolicy) NoSniff() *SecurityPolicy {
+	p.contentTypeOptions = true
+	return p
+}
+
+// Mappings returns outgoing mappings emitting the policy as default values.
+// Backends can override a header by sending the corresponding metadata key.
+func (p *SecurityPolicy) Mappings() []HeaderMapping {
+	var mappings []HeaderMapping
+	add := func(header, key, value string) {
+		if value != "" {
+			mappings = append(mappings, HeaderMapping{
+				HTTPHeader:   header,
+				GRPCMetadata: key,
+				Direction:    Outgoing,
+				DefaultValue: value,
+			})
+		}
+	}
+
+	add("Content-Security-Policy", "content-security-policy", strings.Join(p.csp, "; "))
+	add("Strict-Transport-Security", "strict-transport-security", p.hsts)
+	add("X-Frame-Options", "x-frame-options", p.frameOptions)
+	add("Referrer-Policy", "referrer-policy", p.referrerPolicy)
+	if p.contentTypeOptions {
+		add("X-Content-Type-Options", "x-content-type-options", "nosniff")
+	}
+
+	return mappings
+}
+
+// SecurityHeaderMappings returns outgoing mappings for a strict default
+// security policy suitable for JSON APIs
+func SecurityHeaderMappings() []HeaderMapping {
+	return NewSecurityPolicy().
+		ContentSecurityPolicy("default-src", "'none'").
+		ContentSecurityPolicy("frame-ancestors", "'none'").
+		StrictTransportSecurity(365*24*time.Hour, true, false).
+		FrameOptions("DENY").
+		ReferrerPolicy("no-referrer").
+		NoSniff().
+		Mappings()
+}
@@ -0,0 +1,57 @@
+package headermapper
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestSecurityPolicy_Mappings(t *testing.T) {
+	mapper := NewBuilder().
+		AddMappings(NewSecurityPolicy().
+			ContentSecurityPolicy("default-src", "'self'").
+			ContentSecurityPolicy("img-src", "'self'", "data:").
+			StrictTransportSecurity(24*time.Hour, true, true).
+			FrameOptions("SAMEORIGIN").
+			ReferrerPolicy("strict-origin").
+			NoSniff().
+			Mappings()...).
+		Build()
+
+	w := httptest.NewRecorder()
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{
+		HeaderMD: metadata.Pairs("x-frame-options", "DENY"),
+	})
+	if err := mapper.ResponseModifier()(ctx, w, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"Content-Security-Policy":   "default-src 'self'; img-src 'self' data:",
+		"Strict-Transport-Security": "max-age=86400; includeSubDomains; preload",
+		"X-Frame-Options":           "DENY", // overridden by the backend
+		"Referrer-Policy":           "strict-origin",
+		"X-Content-Type-Options":    "nosniff",
+	}
+	for header, value := range expected {
+		if got := w.Header().Get(header); got != value {
+			t.Errorf("header %s = %q, want %q", header, got, value)
+		}
+	}
+}
+
+func TestSecurityHeaderMappings(t *testing.T) {
+	mappings := SecurityHeaderMappings()
+	if len(mappings) != 5 {
+		t.Fatalf("expected 5 mappings, got %d", len(mappings))
+	}
+	for _, m := range mappings {
+		if m.Direction != Outgoing || m.DefaultValue == "" {
+			t.Errorf("unexpected mapping %+v", m)
+		}
+	}
+}
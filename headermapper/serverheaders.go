@@ -0,0 +1,166 @@
+package headermapper
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// serverHeaders applies the outgoing mappings to the header metadata a
+// handler sets, and fills in the defaults of keys it did not set before the
+// header is sent
+type serverHeaders struct {
+	cc   *compiledConfig
+	mu   sync.Mutex
+	set  map[string]bool
+	sent bool
+}
+
+func newServerHeaders(cc *compiledConfig) *serverHeaders {
+	return &serverHeaders{cc: cc, set: make(map[string]bool)}
+}
+
+// transform returns md with the transforms of the outgoing mappings of its
+// keys applied, recording the keys as set
+func (h *serverHeaders) transform(md metadata.MD) metadata.MD {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.transformLocked(md)
+}
+
+func (h *serverHeaders) transformLocked(md metadata.MD) metadata.MD {
+	out := make(metadata.MD, len(md))
+	for key, values := range md {
+		key = strings.ToLower(key)
+		h.set[key] = true
+		mapping := h.cc.index.outgoingMapping(key)
+		if mapping == nil || mapping.transform == nil {
+			out[key] = append(out[key], values...)
+			continue
+		}
+		for _, value := range values {
+			if value = mapping.transform(value); value != "" {
+				out[key] = append(out[key], value)
+			}
+		}
+	}
+	return out
+}
+
+// complete returns md transformed and joined with the defaults of the keys
+// not set, for a header about to be sent
+func (h *serverHeaders) complete(md metadata.MD) metadata.MD {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := h.transformLocked(md)
+	for key, values := range h.defaultsLocked() {
+		out[key] = values
+	}
+	h.sent = true
+	return out
+}
+
+// finish sets the defaults of the keys not set through setHeader unless the
+// header was already sent
+func (h *serverHeaders) finish(setHeader func(metadata.MD) error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.sent {
+		return
+	}
+	h.sent = true
+	if md := h.defaultsLocked(); len(md) > 0 {
+		setHeader(md)
+	}
+}
+
+// defaultsLocked returns the generated or default values of the outgoing
+// mappings whose keys were not set, transformed like values handlers set
+func (h *serverHeaders) defaultsLocked() metadata.MD {
+	var md metadata.MD
+	for i := range h.cc.index.outgoing {
+		mapping := &h.cc.index.outgoing[i]
+		if h.set[mapping.key] || len(md[mapping.key]) > 0 {
+			continue
+		}
+		value := mapping.generate()
+		if value == "" {
+			value = mapping.defaultValue
+		}
+		if value != "" && mapping.transform != nil {
+			value = mapping.transform(value)
+		}
+		if value == "" {
+			continue
+		}
+		if md == nil {
+			md = metadata.MD{}
+		}
+		md[mapping.key] = []string{value}
+	}
+	return md
+}
+
+// outgoingMapping returns the first outgoing mapping of a metadata key
+func (idx *mappingIndex) outgoingMapping(key string) *compiledMapping {
+	for i := range idx.outgoing {
+		if idx.outgoing[i].key == key {
+			return &idx.outgoing[i]
+		}
+	}
+	return nil
+}
+
+// headerTransportStream routes grpc.SetHeader and grpc.SendHeader calls of a
+// handler through serverHeaders
+type headerTransportStream struct {
+	grpc.ServerTransportStream
+	headers *serverHeaders
+}
+
+func (s *headerTransportStream) SetHeader(md metadata.MD) error {
+	return s.ServerTransportStream.SetHeader(s.headers.transform(md))
+}
+
+func (s *headerTransportStream) SendHeader(md metadata.MD) error {
+	return s.ServerTransportStream.SendHeader(s.headers.complete(md))
+}
+
+// withServerHeaders returns ctx with its transport stream routed through
+// headers, and the original stream; contexts without one are returned
+// unchanged with a nil stream
+func withServerHeaders(ctx context.Context, headers *serverHeaders) (context.Context, grpc.ServerTransportStream) {
+	stream := grpc.ServerTransportStreamFromContext(ctx)
+	if stream == nil {
+		return ctx, nil
+	}
+	return grpc.NewContextWithServerTransportStream(ctx, &headerTransportStream{ServerTransportStream: stream, headers: headers}), stream
+}
+
+// headerServerStream routes the header methods of a server stream through
+// serverHeaders, setting defaults before the first message sends the header
+type headerServerStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	headers *serverHeaders
+}
+
+func (s *headerServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *headerServerStream) SetHeader(md metadata.MD) error {
+	return s.ServerStream.SetHeader(s.headers.transform(md))
+}
+
+func (s *headerServerStream) SendHeader(md metadata.MD) error {
+	return s.ServerStream.SendHeader(s.headers.complete(md))
+}
+
+func (s *headerServerStream) SendMsg(m interface{}) error {
+	s.headers.finish(s.ServerStream.SetHeader)
+	return s.ServerStream.SendMsg(m)
+}
@@ -0,0 +1,122 @@
+package headermapper
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// headerServer sets the header metadata named in the request service
+type headerServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+}
+
+func (headerServer) Check(ctx context.Context, req *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	switch req.GetService() {
+	case "set":
+		grpc.SetHeader(ctx, metadata.Pairs("server-version", " V1.2 "))
+	case "send":
+		grpc.SendHeader(ctx, metadata.Pairs("server-version", " V1.2 ", "region", "eu-west-1"))
+	}
+	return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
+}
+
+func (headerServer) Watch(req *grpc_health_v1.HealthCheckRequest, stream grpc_health_v1.Health_WatchServer) error {
+	stream.SetHeader(metadata.Pairs("server-version", " V2 "))
+	return stream.Send(&grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING})
+}
+
+func startHeaderServer(t *testing.T, opts ...InterceptorOption) grpc_health_v1.HealthClient {
+	t.Helper()
+	mapper := NewBuilder().
+		AddOutgoingMapping("server-version", "X-Server-Version").WithTransform(ChainTransforms(TrimSpace, ToLower)).
+		AddOutgoingMapping("region", "X-Region").WithDefault("us-east-1").
+		Build()
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(mapper.UnaryServerInterceptor(opts...)),
+		grpc.StreamInterceptor(mapper.StreamServerInterceptor(opts...)),
+	)
+	grpc_health_v1.RegisterHealthServer(server, headerServer{})
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return grpc_health_v1.NewHealthClient(conn)
+}
+
+func TestWithOutgoingMappings_Unary(t *testing.T) {
+	client := startHeaderServer(t, WithOutgoingMappings())
+
+	tests := []struct {
+		service string
+		version string
+		region  string
+	}{
+		{"set", "v1.2", "us-east-1"},
+		{"send", "v1.2", "eu-west-1"},
+		{"none", "", "us-east-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.service, func(t *testing.T) {
+			var header metadata.MD
+			_, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: tt.service}, grpc.Header(&header))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := header.Get("server-version"); (tt.version == "" && len(got) > 0) || (tt.version != "" && (len(got) != 1 || got[0] != tt.version)) {
+				t.Errorf("server-version = %v, want %q", got, tt.version)
+			}
+			if got := header.Get("region"); len(got) != 1 || got[0] != tt.region {
+				t.Errorf("region = %v, want %q", got, tt.region)
+			}
+		})
+	}
+}
+
+func TestWithOutgoingMappings_Stream(t *testing.T) {
+	client := startHeaderServer(t, WithOutgoingMappings())
+	stream, err := client.Watch(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := stream.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := header.Get("server-version"); len(got) != 1 || got[0] != "v2" {
+		t.Errorf("server-version = %v, want v2", got)
+	}
+	if got := header.Get("region"); len(got) != 1 || got[0] != "us-east-1" {
+		t.Errorf("region = %v, want us-east-1", got)
+	}
+}
+
+func TestWithOutgoingMappings_Disabled(t *testing.T) {
+	client := startHeaderServer(t)
+	var header metadata.MD
+	if _, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "set"}, grpc.Header(&header)); err != nil {
+		t.Fatal(err)
+	}
+	if got := header.Get("server-version"); len(got) != 1 || got[0] != " V1.2 " {
+		t.Errorf("server-version = %v, want untransformed", got)
+	}
+	if got := header.Get("region"); len(got) > 0 {
+		t.Errorf("region = %v, want no default", got)
+	}
+}
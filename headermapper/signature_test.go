@@ -0,0 +1,169 @@
+package headermapper
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSignatureVerification_HMAC(t *testing.T) {
+	config := &SignatureConfig{
+		SignedHeaders: []string{"Host", "X-User-ID"},
+		Keys:          map[string]string{"client-1": "s3cret"},
+	}
+	mapper := NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").
+		VerifySignatures(config).
+		SkipPaths("/health").
+		Build()
+
+	handler := mapper.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest("POST", "/v1/echo?b=2&a=1", nil)
+		req.Header.Set("X-User-ID", "user-123")
+		return req
+	}
+
+	tests := []struct {
+		name     string
+		request  func() *http.Request
+		expected int
+	}{
+		{
+			name: "valid signature",
+			request: func() *http.Request {
+				req := newRequest()
+				SignHMAC(req, config, "client-1", "s3cret")
+				return req
+			},
+			expected: http.StatusOK,
+		},
+		{
+			name:     "missing signature",
+			request:  newRequest,
+			expected: http.StatusUnauthorized,
+		},
+		{
+			name: "tampered header",
+			request: func() *http.Request {
+				req := newRequest()
+				SignHMAC(req, config, "client-1", "s3cret")
+				req.Header.Set("X-User-ID", "admin")
+				return req
+			},
+			expected: http.StatusUnauthorized,
+		},
+		{
+			name: "tampered method",
+			request: func() *http.Request {
+				req := newRequest()
+				SignHMAC(req, config, "client-1", "s3cret")
+				req.Method = "DELETE"
+				return req
+			},
+			expected: http.StatusUnauthorized,
+		},
+		{
+			name: "unknown key",
+			request: func() *http.Request {
+				req := newRequest()
+				SignHMAC(req, config, "client-2", "s3cret")
+				return req
+			},
+			expected: http.StatusUnauthorized,
+		},
+		{
+			name: "skip path",
+			request: func() *http.Request {
+				return httptest.NewRequest("GET", "/health", nil)
+			},
+			expected: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, tt.request())
+			if w.Code != tt.expected {
+				t.Errorf("Handler() status = %d, want %d", w.Code, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSignatureVerification_ECDSA(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+
+	config := &SignatureConfig{
+		Algorithm:     SignatureECDSAP256SHA256,
+		SignedHeaders: []string{"X-Tenant-ID"},
+		Keys:          map[string]string{"k1": pubPEM},
+	}
+	mapper := NewBuilder().VerifySignatures(config).Build()
+	if err := mapper.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/v1/items", nil)
+	req.Header.Set("X-Tenant-ID", "acme")
+	hash := sha256.Sum256([]byte(CanonicalRequest(req, config.SignedHeaders)))
+	sig, err := ecdsa.SignASN1(rand.Reader, priv, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Key-ID", "k1")
+	req.Header.Set("X-Signature", base64.StdEncoding.EncodeToString(sig))
+
+	handler := mapper.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Handler() status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	req.Header.Set("X-Tenant-ID", "other")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Handler() tampered status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestSignatureConfig_Validate(t *testing.T) {
+	mapper := NewBuilder().
+		VerifySignatures(&SignatureConfig{Algorithm: "md5"}).
+		Build()
+	if err := mapper.Validate(); err == nil {
+		t.Error("Validate() expected error for unsupported algorithm")
+	}
+
+	mapper = NewBuilder().
+		VerifySignatures(&SignatureConfig{
+			Algorithm: SignatureECDSAP256SHA256,
+			Keys:      map[string]string{"bad": "not a key"},
+		}).
+		Build()
+	if err := mapper.Validate(); err == nil {
+		t.Error("Validate() expected error for invalid public key")
+	}
+}
@@ -0,0 +1,89 @@
+package headermapper
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// metadataEntryOverhead is the per-entry overhead counted by HTTP/2 header
+// size limits (RFC 7540 section 6.5.2), which gRPC servers enforce
+const metadataEntryOverhead = 32
+
+// MetadataLimitConfig caps the metadata a single request contributes through
+// mappings, including default and generated values
+type MetadataLimitConfig struct {
+	// MaxBytes is the limit, counting each value as len(key)+len(value)+32
+	MaxBytes int `json:"max_bytes" yaml:"max_bytes"`
+	// Truncate drops entries that do not fit instead of rejecting the request
+	Truncate bool `json:"truncate" yaml:"truncate"`
+}
+
+// validate checks the limit
+func (lc *MetadataLimitConfig) validate() error {
+	if lc.MaxBytes <= 0 {
+		return fmt.Errorf("metadata limit: max_bytes must be positive")
+	}
+	return nil
+}
+
+// MetadataSize returns the size of md as counted by MetadataLimitConfig
+func MetadataSize(md metadata.MD) int {
+	size := 0
+	for key, values := range md {
+		for _, value := range values {
+			size += len(key) + len(value) + metadataEntryOverhead
+		}
+	}
+	return size
+}
+
+// metadataLimit enforces a MetadataLimitConfig
+type metadataLimit struct {
+	config *MetadataLimitConfig
+	hm     *HeaderMapper
+}
+
+// truncate removes entries beyond the limit, keeping keys in sorted order so
+// the result is deterministic
+func (l *metadataLimit) truncate(md metadata.MD) {
+	if MetadataSize(md) <= l.config.MaxBytes {
+		return
+	}
+
+	keys := make([]string, 0, len(md))
+	for key := range md {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	size := 0
+	for _, key := range keys {
+		kept := md[key][:0]
+		for _, value := range md[key] {
+			entry := len(key) + len(value) + metadataEntryOverhead
+			if size+entry > l.config.MaxBytes {
+				l.hm.log().Warn("Dropping metadata exceeding size limit:", key)
+				continue
+			}
+			size += entry
+			kept = append(kept, value)
+		}
+		if len(kept) == 0 {
+			delete(md, key)
+		} else {
+			md[key] = kept
+		}
+	}
+}
+
+// check rejects requests whose mapped metadata exceeds the limit
+func (l *metadataLimit) check(w http.ResponseWriter, req *http.Request) error {
+	if size := MetadataSize(l.hm.annotateOnce(l.hm.state(), req)); size > l.config.MaxBytes {
+		return rejectf(codes.InvalidArgument, "mapped metadata too large: %d bytes exceeds %d", size, l.config.MaxBytes)
+	}
+	return nil
+}
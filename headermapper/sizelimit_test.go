@@ -0,0 +1,67 @@
+package headermapper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetadataLimit_Reject(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").
+		AddIncomingMapping("X-Tenant-ID", "tenant-id").
+		WithDefault("default-tenant").
+		LimitMetadata(&MetadataLimitConfig{MaxBytes: 98}).
+		Build()
+	handler := mapper.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name     string
+		userID   string
+		expected int
+	}{
+		// user-id (7+4+32) + tenant-id default (9+14+32) = 98
+		{"within limit", "1234", http.StatusOK},
+		{"exceeds limit", "12345", http.StatusBadRequest},
+		{"large value", strings.Repeat("x", 4096), http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/v1/echo", nil)
+			req.Header.Set("X-User-ID", tt.userID)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.expected {
+				t.Errorf("Handler() status = %d, want %d", w.Code, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMetadataLimit_Truncate(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-A", "a").
+		AddIncomingMapping("X-B", "b").
+		AddIncomingMapping("X-C", "c").
+		LimitMetadata(&MetadataLimitConfig{MaxBytes: 80, Truncate: true}).
+		Build()
+
+	req := httptest.NewRequest("GET", "/v1/echo", nil)
+	req.Header.Set("X-A", "1")
+	req.Header.Set("X-B", strings.Repeat("x", 100))
+	req.Header.Set("X-C", "3")
+
+	md := mapper.MetadataAnnotator()(context.Background(), req)
+	if MetadataSize(md) > 80 {
+		t.Errorf("MetadataSize() = %d, want <= 80", MetadataSize(md))
+	}
+	if len(md.Get("a")) != 1 || len(md.Get("c")) != 1 {
+		t.Errorf("expected small entries to be kept, got %v", md)
+	}
+	if len(md.Get("b")) != 0 {
+		t.Errorf("expected oversized entry to be dropped, got %v", md.Get("b"))
+	}
+}
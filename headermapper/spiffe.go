@@ -0,0 +1,189 @@
+package headermapper
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// SPIFFEConfig configures extraction of the client's SPIFFE ID from the URI SAN
+// of its mTLS certificate into gRPC metadata
+type SPIFFEConfig struct {
+	// TrustDomains lists the accepted trust domains; empty accepts any domain
+	TrustDomains []string `json:"trust_domains" yaml:"trust_domains"`
+	// MetadataKey receives the SPIFFE ID (default spiffe-id)
+	MetadataKey string `json:"metadata_key" yaml:"metadata_key"`
+	// Required rejects requests without a SPIFFE ID from a trusted domain
+	Required bool `json:"required" yaml:"required"`
+	// TrustedForwarders lists the SPIFFE IDs of peers (such as the gateway) whose
+	// forwarded SPIFFE ID metadata is kept by the server interceptors
+	TrustedForwarders []string `json:"trusted_forwarders" yaml:"trusted_forwarders"`
+}
+
+// SPIFFEIdentity returns a SPIFFE preset accepting IDs from the given trust domains
+func SPIFFEIdentity(trustDomains ...string) *SPIFFEConfig {
+	return &SPIFFEConfig{
+		TrustDomains: trustDomains,
+		MetadataKey:  "spiffe-id",
+	}
+}
+
+func (sc *SPIFFEConfig) metadataKey() string {
+	if sc.MetadataKey == "" {
+		return "spiffe-id"
+	}
+	return strings.ToLower(sc.MetadataKey)
+}
+
+// validate checks the trust domains and forwarder IDs
+func (sc *SPIFFEConfig) validate() error {
+	for _, domain := range sc.TrustDomains {
+		if domain == "" || strings.ContainsAny(domain, "/:") {
+			return fmt.Errorf("invalid SPIFFE trust domain: %q", domain)
+		}
+	}
+	for _, id := range sc.TrustedForwarders {
+		if _, err := ParseSPIFFEID(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseSPIFFEID parses a SPIFFE ID of the form spiffe://trust-domain/path and
+// returns its trust domain
+func ParseSPIFFEID(id string) (string, error) {
+	u, err := url.Parse(id)
+	if err != nil {
+		return "", fmt.Errorf("invalid SPIFFE ID: %w", err)
+	}
+	if u.Scheme != "spiffe" || u.Host == "" {
+		return "", fmt.Errorf("invalid SPIFFE ID: %s", id)
+	}
+	if u.User != nil || u.RawQuery != "" || u.Fragment != "" || u.Port() != "" {
+		return "", fmt.Errorf("invalid SPIFFE ID: %s", id)
+	}
+	return strings.ToLower(u.Host), nil
+}
+
+// spiffeIdentity maps verified SPIFFE IDs into metadata
+type spiffeIdentity struct {
+	config       *SPIFFEConfig
+	key          string
+	trustDomains map[string]bool
+	forwarders   map[string]bool
+}
+
+func newSPIFFEIdentity(config *SPIFFEConfig) *spiffeIdentity {
+	si := &spiffeIdentity{
+		config:       config,
+		key:          config.metadataKey(),
+		trustDomains: make(map[string]bool),
+		forwarders:   make(map[string]bool),
+	}
+	for _, domain := range config.TrustDomains {
+		si.trustDomains[strings.ToLower(domain)] = true
+	}
+	for _, id := range config.TrustedForwarders {
+		si.forwarders[id] = true
+	}
+	return si
+}
+
+// fromCertificates returns the SPIFFE ID of the leaf certificate
+func (si *spiffeIdentity) fromCertificates(certs []*x509.Certificate) (string, error) {
+	if len(certs) == 0 {
+		return "", rejectf(codes.Unauthenticated, "client certificate required")
+	}
+
+	var id string
+	for _, uri := range certs[0].URIs {
+		if uri.Scheme == "spiffe" {
+			if id != "" {
+				return "", rejectf(codes.Unauthenticated, "client certificate has multiple SPIFFE IDs")
+			}
+			id = uri.String()
+		}
+	}
+	if id == "" {
+		return "", rejectf(codes.Unauthenticated, "client certificate has no SPIFFE ID")
+	}
+
+	return id, si.verify(id)
+}
+
+// verify checks that the ID is well formed and belongs to a trusted domain
+func (si *spiffeIdentity) verify(id string) error {
+	domain, err := ParseSPIFFEID(id)
+	if err != nil {
+		return rejectf(codes.Unauthenticated, "%v", err)
+	}
+	if len(si.trustDomains) > 0 && !si.trustDomains[domain] {
+		return rejectf(codes.PermissionDenied, "untrusted SPIFFE trust domain: %s", domain)
+	}
+	return nil
+}
+
+func (si *spiffeIdentity) fromTLS(state *tls.ConnectionState) (string, error) {
+	if state == nil {
+		return "", rejectf(codes.Unauthenticated, "client certificate required")
+	}
+	return si.fromCertificates(state.PeerCertificates)
+}
+
+// check rejects HTTP requests without a trusted SPIFFE ID when required
+func (si *spiffeIdentity) check(w http.ResponseWriter, req *http.Request) error {
+	if !si.config.Required {
+		return nil
+	}
+	_, err := si.fromTLS(req.TLS)
+	return err
+}
+
+// annotate adds the SPIFFE ID of the HTTP client to the metadata
+func (si *spiffeIdentity) annotate(req *http.Request, md metadata.MD) {
+	if id, err := si.fromTLS(req.TLS); err == nil {
+		md.Set(si.key, id)
+	}
+}
+
+// processIncoming replaces the SPIFFE ID metadata with the gRPC peer's identity,
+// keeping forwarded values only when the peer is a trusted forwarder
+func (si *spiffeIdentity) processIncoming(ctx context.Context, md metadata.MD) error {
+	var peerID string
+	var peerErr error = rejectf(codes.Unauthenticated, "client certificate required")
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			peerID, peerErr = si.fromCertificates(info.State.PeerCertificates)
+		}
+	}
+
+	if peerErr == nil && si.forwarders[peerID] {
+		if forwarded := md.Get(si.key); len(forwarded) == 1 && si.verify(forwarded[0]) == nil {
+			return nil
+		}
+		md.Delete(si.key)
+		if si.config.Required {
+			return rejectf(codes.Unauthenticated, "missing forwarded SPIFFE ID")
+		}
+		return nil
+	}
+
+	md.Delete(si.key)
+	if peerErr == nil {
+		md.Set(si.key, peerID)
+	} else if si.config.Required {
+		return peerErr
+	}
+
+	return nil
+}
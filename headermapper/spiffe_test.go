@@ -0,0 +1,171 @@
+package headermapper
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func newSPIFFECert(t *testing.T, id string) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uri, err := url.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		URIs:         []*url.URL{uri},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestSPIFFEIdentity_MetadataAnnotator(t *testing.T) {
+	mapper := NewBuilder().
+		WithSPIFFEIdentity(SPIFFEIdentity("example.org")).
+		Build()
+	annotator := mapper.MetadataAnnotator()
+
+	tests := []struct {
+		name     string
+		id       string
+		expected string
+	}{
+		{"trusted domain", "spiffe://example.org/ns/default/sa/web", "spiffe://example.org/ns/default/sa/web"},
+		{"untrusted domain", "spiffe://evil.org/ns/default/sa/web", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/test", nil)
+			req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{newSPIFFECert(t, tt.id)}}
+
+			md := annotator(context.Background(), req)
+			got := ""
+			if values := md.Get("spiffe-id"); len(values) > 0 {
+				got = values[0]
+			}
+			if got != tt.expected {
+				t.Errorf("MetadataAnnotator() spiffe-id = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSPIFFEIdentity_Required(t *testing.T) {
+	config := SPIFFEIdentity("example.org")
+	config.Required = true
+	mapper := NewBuilder().WithSPIFFEIdentity(config).Build()
+	handler := mapper.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name     string
+		id       string
+		expected int
+	}{
+		{"no certificate", "", http.StatusUnauthorized},
+		{"untrusted domain", "spiffe://evil.org/web", http.StatusForbidden},
+		{"trusted domain", "spiffe://example.org/web", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/test", nil)
+			if tt.id != "" {
+				req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{newSPIFFECert(t, tt.id)}}
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.expected {
+				t.Errorf("Handler() status = %d, want %d", w.Code, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSPIFFEIdentity_UnaryServerInterceptor(t *testing.T) {
+	config := SPIFFEIdentity("example.org")
+	config.Required = true
+	config.TrustedForwarders = []string{"spiffe://example.org/gateway"}
+	mapper := NewBuilder().WithSPIFFEIdentity(config).Build()
+	interceptor := mapper.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Method"}
+
+	call := func(peerID, forwarded string) (string, error) {
+		ctx := context.Background()
+		if peerID != "" {
+			ctx = peer.NewContext(ctx, &peer.Peer{AuthInfo: credentials.TLSInfo{
+				State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{newSPIFFECert(t, peerID)}},
+			}})
+		}
+		ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("spiffe-id", forwarded))
+
+		var got string
+		_, err := interceptor(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			md, _ := metadata.FromIncomingContext(ctx)
+			if values := md.Get("spiffe-id"); len(values) > 0 {
+				got = values[0]
+			}
+			return nil, nil
+		})
+		return got, err
+	}
+
+	// Direct clients cannot spoof their identity
+	got, err := call("spiffe://example.org/billing", "spiffe://example.org/admin")
+	if err != nil || got != "spiffe://example.org/billing" {
+		t.Errorf("direct peer: got %q, %v", got, err)
+	}
+
+	// The gateway may forward the end client's identity
+	got, err = call("spiffe://example.org/gateway", "spiffe://example.org/web")
+	if err != nil || got != "spiffe://example.org/web" {
+		t.Errorf("trusted forwarder: got %q, %v", got, err)
+	}
+
+	// Missing certificate is rejected when required
+	_, err = call("", "spiffe://example.org/admin")
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("no peer: error = %v, want Unauthenticated", err)
+	}
+}
+
+func TestSPIFFEIdentity_HeaderMatcherReserved(t *testing.T) {
+	mapper := NewBuilder().
+		WithSPIFFEIdentity(SPIFFEIdentity("example.org")).
+		Build()
+	matcher := mapper.HeaderMatcher()
+
+	if _, ok := matcher("Grpc-Metadata-Spiffe-Id"); ok {
+		t.Error("HeaderMatcher() should not forward the reserved spiffe-id key")
+	}
+}
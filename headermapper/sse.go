@@ -0,0 +1,265 @@
+package headermapper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// EventStreamContentType is the content type of server-sent events
+const EventStreamContentType = "text/event-stream"
+
+// SSEConfig configures server-streaming responses delivered as server-sent
+// events to clients sending Accept: text/event-stream. Streams are rendered
+// by SSEMarshaler; outgoing mappings still set the response headers, which
+// are flushed before the first event.
+type SSEConfig struct {
+	// RetryKey names header metadata whose value, in milliseconds, is sent
+	// as the retry field before the first event
+	RetryKey string `json:"retry_key,omitempty" yaml:"retry_key,omitempty"`
+	// CommentKeys lists header metadata sent as comment lines before the first event
+	CommentKeys []string `json:"comment_keys,omitempty" yaml:"comment_keys,omitempty"`
+	// TrailerEvent names the final event carrying the mapped trailer
+	// metadata of the stream (default trailers). Trailers are captured by
+	// SSETrailerInterceptor on the gateway's client connection.
+	TrailerEvent string `json:"trailer_event,omitempty" yaml:"trailer_event,omitempty"`
+}
+
+// validate checks the metadata keys and event name
+func (sc *SSEConfig) validate() error {
+	keys := append([]string{sc.RetryKey}, sc.CommentKeys...)
+	for i, key := range keys {
+		if (i > 0 || key != "") && !validMetadataKey(key) {
+			return fmt.Errorf("sse: invalid metadata key %q", key)
+		}
+	}
+	if strings.ContainsAny(sc.TrailerEvent, "\r\n") {
+		return fmt.Errorf("sse: invalid trailer event %q", sc.TrailerEvent)
+	}
+	return nil
+}
+
+// sseStreams renders the preamble and trailer event of event streams
+type sseStreams struct {
+	config       *SSEConfig
+	trailerEvent string
+	hm           *HeaderMapper
+}
+
+func newSSEStreams(config *SSEConfig, hm *HeaderMapper) *sseStreams {
+	trailerEvent := config.TrailerEvent
+	if trailerEvent == "" {
+		trailerEvent = "trailers"
+	}
+	return &sseStreams{config: config, trailerEvent: trailerEvent, hm: hm}
+}
+
+// sseStream carries the state of one event stream through the request context
+type sseStream struct {
+	mu      sync.Mutex
+	trailer metadata.MD
+}
+
+type sseStreamKey struct{}
+
+// sseStreamFromContext returns the event stream of a request, if any
+func sseStreamFromContext(ctx context.Context) *sseStream {
+	stream, _ := ctx.Value(sseStreamKey{}).(*sseStream)
+	return stream
+}
+
+// acceptsEventStream reports whether req asks for server-sent events
+func acceptsEventStream(req *http.Request) bool {
+	for _, accept := range req.Header.Values("Accept") {
+		if strings.Contains(accept, EventStreamContentType) {
+			return true
+		}
+	}
+	return false
+}
+
+// handler tracks the event stream of each request asking for one and
+// writes its trailer event once the gateway has forwarded the stream
+func (s *sseStreams) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !acceptsEventStream(req) {
+			next.ServeHTTP(w, req)
+			return
+		}
+		stream := &sseStream{}
+		next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), sseStreamKey{}, stream)))
+		s.writeTrailerEvent(w, stream)
+	})
+}
+
+// start sets the event stream headers and writes the comment and retry
+// fields taken from md before the first event. Writing the preamble sends
+// the headers, so it runs after the outgoing mappings.
+func (s *sseStreams) start(w http.ResponseWriter, md metadata.MD) {
+	h := w.Header()
+	h.Set("Content-Type", EventStreamContentType)
+	h.Set("Cache-Control", "no-cache")
+
+	var buf bytes.Buffer
+	for _, key := range s.config.CommentKeys {
+		for _, value := range md.Get(key) {
+			fmt.Fprintf(&buf, ": %s: %s\n", key, eventLine(value))
+		}
+	}
+	if values := md.Get(s.config.RetryKey); s.config.RetryKey != "" && len(values) > 0 {
+		if retry, err := strconv.ParseUint(strings.TrimSpace(values[0]), 10, 32); err == nil {
+			fmt.Fprintf(&buf, "retry: %d\n", retry)
+		}
+	}
+	if buf.Len() == 0 {
+		return
+	}
+	buf.WriteByte('\n')
+	w.Write(buf.Bytes())
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// writeTrailerEvent writes the trailer metadata of stream mapped to header
+// names as a final event. Only values with outgoing mappings are sent;
+// defaults and generators do not apply to trailers.
+func (s *sseStreams) writeTrailerEvent(w http.ResponseWriter, stream *sseStream) {
+	stream.mu.Lock()
+	trailer := stream.trailer
+	stream.mu.Unlock()
+	if len(trailer) == 0 {
+		return
+	}
+
+	fields := make(map[string]string)
+	for _, mapping := range s.hm.state().index.outgoingSequence {
+		values := trailer[mapping.key]
+		if mapping.internal || len(values) == 0 {
+			continue
+		}
+		value := values[0]
+		if mapping.transform != nil {
+			value = mapping.transform(value)
+		}
+		if _, found := fields[mapping.header]; !found && value != "" {
+			fields[mapping.header] = value
+		}
+	}
+	if len(fields) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return
+	}
+	w.Write(append(formatEvent(s.trailerEvent, data), '\n'))
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// SSETrailerInterceptor returns a client stream interceptor for the
+// gateway's connection to backends that captures the trailer metadata of
+// streams delivered as server-sent events, so Handler can send them as a
+// final event; the gateway itself drops the trailers of streams
+func (hm *HeaderMapper) SSETrailerInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		stream := sseStreamFromContext(ctx)
+		if err != nil || stream == nil {
+			return cs, err
+		}
+		return &trailerCapturingStream{ClientStream: cs, stream: stream}, nil
+	}
+}
+
+// trailerCapturingStream records the trailer metadata once the stream ends
+type trailerCapturingStream struct {
+	grpc.ClientStream
+	stream *sseStream
+}
+
+func (s *trailerCapturingStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		s.stream.mu.Lock()
+		s.stream.trailer = s.ClientStream.Trailer()
+		s.stream.mu.Unlock()
+	}
+	return err
+}
+
+// SSEMarshaler renders the messages of server streams as server-sent events,
+// each message as a data field in JSON and errors as error events. Register
+// it for clients asking for event streams:
+//
+//	runtime.WithMarshalerOption(headermapper.EventStreamContentType, &headermapper.SSEMarshaler{})
+type SSEMarshaler struct {
+	runtime.JSONPb
+}
+
+// ContentType returns text/event-stream
+func (m *SSEMarshaler) ContentType(_ interface{}) string {
+	return EventStreamContentType
+}
+
+// Marshal renders v as an event. The gateway wraps stream messages as
+// {"result": message} and errors as {"error": status}; the message is sent
+// unwrapped and errors as error events.
+func (m *SSEMarshaler) Marshal(v interface{}) ([]byte, error) {
+	event := ""
+	switch chunk := v.(type) {
+	case map[string]interface{}:
+		if result, ok := chunk["result"]; ok && len(chunk) == 1 {
+			v = result
+		}
+	case map[string]proto.Message:
+		if status, ok := chunk["error"]; ok && len(chunk) == 1 {
+			v, event = status, "error"
+		}
+	}
+
+	data, err := m.JSONPb.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return formatEvent(event, data), nil
+}
+
+// Delimiter ends each event with a blank line
+func (m *SSEMarshaler) Delimiter() []byte {
+	return []byte("\n")
+}
+
+// formatEvent renders an event with one data field per line of data,
+// without the blank line ending it
+func formatEvent(event string, data []byte) []byte {
+	var buf bytes.Buffer
+	if event != "" {
+		buf.WriteString("event: " + event + "\n")
+	}
+	for _, line := range bytes.Split(bytes.TrimRight(data, "\n"), []byte("\n")) {
+		buf.WriteString("data: ")
+		buf.Write(bytes.TrimRight(line, "\r"))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// eventLine removes line breaks from a value, which would otherwise end the
+// field and inject fields of the client's choosing
+func eventLine(value string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+}
@@ -0,0 +1,205 @@
+package headermapper
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+// watchServer streams two health statuses with header and trailer metadata
+type watchServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+}
+
+func (watchServer) Watch(req *grpc_health_v1.HealthCheckRequest, stream grpc_health_v1.Health_WatchServer) error {
+	stream.SetHeader(metadata.Pairs("retry-ms", "3000", "server-version", "1.2", "cache-status", "miss"))
+	for _, status := range []grpc_health_v1.HealthCheckResponse_ServingStatus{
+		grpc_health_v1.HealthCheckResponse_SERVING,
+		grpc_health_v1.HealthCheckResponse_NOT_SERVING,
+	} {
+		if err := stream.Send(&grpc_health_v1.HealthCheckResponse{Status: status}); err != nil {
+			return err
+		}
+	}
+	stream.SetTrailer(metadata.Pairs("checksum", "abc", "unmapped", "x"))
+	return nil
+}
+
+// startWatchGateway serves the Watch stream of a streaming test service
+// through a gateway handler built like generated ones
+func startWatchGateway(t *testing.T, mapper *HeaderMapper) *httptest.Server {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	grpc_health_v1.RegisterHealthServer(server, watchServer{})
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStreamInterceptor(mapper.SSETrailerInterceptor()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	client := grpc_health_v1.NewHealthClient(conn)
+
+	mux := CreateGatewayMux(mapper)
+	err = mux.HandlePath("GET", "/v1/watch", func(w http.ResponseWriter, req *http.Request, _ map[string]string) {
+		_, outbound := runtime.MarshalerForRequest(mux, req)
+		ctx, err := runtime.AnnotateContext(req.Context(), mux, req, "/grpc.health.v1.Health/Watch")
+		if err != nil {
+			runtime.HTTPError(req.Context(), mux, outbound, w, req, err)
+			return
+		}
+		stream, err := client.Watch(ctx, &grpc_health_v1.HealthCheckRequest{})
+		if err != nil {
+			runtime.HTTPError(ctx, mux, outbound, w, req, err)
+			return
+		}
+		header, err := stream.Header()
+		if err != nil {
+			runtime.HTTPError(ctx, mux, outbound, w, req, err)
+			return
+		}
+		ctx = runtime.NewServerMetadataContext(ctx, runtime.ServerMetadata{HeaderMD: header})
+		runtime.ForwardResponseStream(ctx, mux, outbound, w, req, func() (proto.Message, error) { return stream.Recv() },
+			mux.GetForwardResponseOptions()...)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gateway := httptest.NewServer(mapper.Handler(mux))
+	t.Cleanup(gateway.Close)
+	return gateway
+}
+
+func TestSSE_Stream(t *testing.T) {
+	mapper := NewBuilder().
+		AddOutgoingMapping("cache-status", "X-Cache-Status").
+		AddOutgoingMapping("checksum", "X-Checksum").
+		ServerSentEvents(&SSEConfig{RetryKey: "retry-ms", CommentKeys: []string{"server-version"}}).
+		Build()
+	gateway := startWatchGateway(t, mapper)
+
+	tests := []struct {
+		name        string
+		accept      string
+		contentType string
+		body        string
+	}{
+		{
+			name:        "event stream",
+			accept:      EventStreamContentType,
+			contentType: EventStreamContentType,
+			body: ": server-version: 1.2\nretry: 3000\n\n" +
+				"data: {\"status\":\"SERVING\"}\n\n" +
+				"data: {\"status\":\"NOT_SERVING\"}\n\n" +
+				"event: trailers\ndata: {\"X-Checksum\":\"abc\"}\n\n",
+		},
+		{
+			name:        "chunked JSON",
+			accept:      "application/json",
+			contentType: "application/json",
+			body:        "{\"result\":{\"status\":\"SERVING\"}}\n{\"result\":{\"status\":\"NOT_SERVING\"}}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", gateway.URL+"/v1/watch", nil)
+			req.Header.Set("Accept", tt.accept)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+
+			if got := resp.Header.Get("X-Cache-Status"); got != "miss" {
+				t.Errorf("X-Cache-Status = %q, want miss", got)
+			}
+			if got := resp.Header.Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Content-Type = %q, want %s", got, tt.contentType)
+			}
+			if string(body) != tt.body {
+				t.Errorf("body = %q, want %q", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestSSEMarshaler(t *testing.T) {
+	tests := []struct {
+		name     string
+		indent   string
+		value    interface{}
+		expected string
+	}{
+		{
+			name:     "result",
+			value:    map[string]interface{}{"result": &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}},
+			expected: "data: {\"status\":\"SERVING\"}\n",
+		},
+		{
+			name:     "error",
+			value:    map[string]proto.Message{"error": &grpc_health_v1.HealthCheckRequest{Service: "down"}},
+			expected: "event: error\ndata: {\"service\":\"down\"}\n",
+		},
+		{
+			name:     "multiline",
+			indent:   "  ",
+			value:    map[string]string{"a": "1"},
+			expected: "data: {\ndata:   \"a\": \"1\"\ndata: }\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &SSEMarshaler{}
+			m.Indent = tt.indent
+			got, err := m.Marshal(tt.value)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if string(got) != tt.expected {
+				t.Errorf("Marshal() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSSEConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  SSEConfig
+		wantErr bool
+	}{
+		{"empty", SSEConfig{}, false},
+		{"keys", SSEConfig{RetryKey: "retry-ms", CommentKeys: []string{"server-version"}}, false},
+		{"invalid retry key", SSEConfig{RetryKey: "Retry MS"}, true},
+		{"empty comment key", SSEConfig{CommentKeys: []string{""}}, true},
+		{"invalid event", SSEConfig{TrailerEvent: "end\ndata: x"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
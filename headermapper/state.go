@@ -0,0 +1,175 @@
+package headermapper
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// compiledConfig is the mapping state derived from a Config. It is never
+// modified once published: updates compile a new one and swap it in
+// atomically, so request paths read it without locks.
+type compiledConfig struct {
+	config    *Config
+	skipPaths map[string]bool
+	index     *mappingIndex
+	// cache serves mappings with CacheTransform set; nil when none is
+	cache *TransformCache
+	// cors lists the mapped headers in preflight responses; nil without CORS
+	cors *corsPolicy
+	// overrides caches the states compiled for requests suppressing
+	// mappings; it is the only part filled in after publishing
+	overrides overrideStates
+}
+
+// compile builds the mapping state for config
+func (hm *HeaderMapper) compile(config *Config) *compiledConfig {
+	var cache *TransformCache
+	for _, mapping := range config.Mappings {
+		if mapping.CacheTransform && mapping.Transform != nil {
+			tc := config.TransformCache
+			if tc == nil {
+				tc = &TransformCacheConfig{}
+			}
+			cache = NewTransformCache(tc.Size, tc.TTL)
+			break
+		}
+	}
+	return hm.compileWithCache(config, cache)
+}
+
+// compileWithCache builds the mapping state for config serving cached
+// transforms from cache, which states derived from another share with it
+func (hm *HeaderMapper) compileWithCache(config *Config, cache *TransformCache) *compiledConfig {
+	skipPaths := make(map[string]bool)
+	for _, path := range config.SkipPaths {
+		skipPaths[path] = true
+	}
+
+	cc := &compiledConfig{
+		config:    config,
+		skipPaths: skipPaths,
+		index:     newMappingIndex(hm, config, cache),
+		cache:     cache,
+	}
+	if config.CORS != nil {
+		cc.cors = newCORSPolicy(config.CORS, config.Mappings)
+	}
+	return cc
+}
+
+// state returns the active mapping state. Callers load it once per request so
+// they see a consistent configuration.
+func (hm *HeaderMapper) state() *compiledConfig {
+	return hm.active.Load()
+}
+
+// reloadableFields are the Config fields compiled into each mapping state.
+// The other sections configure policies whose hooks are built when the mapper
+// is constructed.
+var reloadableFields = map[string]bool{
+	"Mappings":          true,
+	"StrictAllowList":   true,
+	"BlockedHeaders":    true,
+	"StatusMetadataKey": true,
+	"SkipPaths":         true,
+	"CaseSensitive":     true,
+	"OverwriteExisting": true,
+	"Debug":             true,
+	"OutgoingOrder":     true,
+	"TransformCache":    true,
+	"MappingBudget":     true,
+	"ParallelMapping":   true,
+	"DebugEchoHeader":   true,
+}
+
+// policyChanges returns the names of the policy sections config changes
+// from active. Sections are compared as written in configuration files, so
+// fields only set in code, such as stores and verifiers, are ignored.
+func policyChanges(active, config *Config) ([]string, error) {
+	from, err := policySections(active)
+	if err != nil {
+		return nil, err
+	}
+	to, err := policySections(config)
+	if err != nil {
+		return nil, err
+	}
+	var changed []string
+	for _, name := range policyNames() {
+		if !reflect.DeepEqual(from[name], to[name]) {
+			changed = append(changed, name)
+		}
+	}
+	return changed, nil
+}
+
+// withPolicies returns a copy of config with the policy sections of active,
+// which keep the stores and verifiers set in code
+func withPolicies(config, active *Config) *Config {
+	merged := *config
+	from, to := reflect.ValueOf(active).Elem(), reflect.ValueOf(&merged).Elem()
+	for i := 0; i < to.NumField(); i++ {
+		if !reloadableFields[to.Type().Field(i).Name] {
+			to.Field(i).Set(from.Field(i))
+		}
+	}
+	return &merged
+}
+
+// configFieldName returns the name of a Config field in configuration files
+func configFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	return name
+}
+
+// UpdateConfig validates config and atomically replaces the mappings, skip
+// paths and mapping options of a running mapper; requests in flight finish
+// with the previous configuration. Security policies such as signatures, rate
+// limits and internal namespaces are fixed when the mapper is constructed, so
+// configurations changing them are rejected; stores and verifiers set in
+// code, which configuration files cannot hold, are kept.
+func (hm *HeaderMapper) UpdateConfig(config *Config) error {
+	if config == nil {
+		return fmt.Errorf("configuration is nil")
+	}
+	hm.updates.Lock()
+	defer hm.updates.Unlock()
+	return hm.install(config)
+}
+
+// modifyConfig installs the changes modify makes to a copy of the active
+// configuration. Changes are serialized with UpdateConfig, so concurrent
+// changes are not lost.
+func (hm *HeaderMapper) modifyConfig(modify func(*Config) error) error {
+	hm.updates.Lock()
+	defer hm.updates.Unlock()
+
+	config := cloneConfig(hm.state().config)
+	if err := modify(config); err != nil {
+		return err
+	}
+	return hm.install(config)
+}
+
+// install validates config and swaps it in; hm.updates must be held
+func (hm *HeaderMapper) install(config *Config) error {
+	if err := ValidateConfig(config); err != nil {
+		return err
+	}
+	active := hm.state().config
+	changed, err := policyChanges(active, config)
+	if err != nil {
+		return err
+	}
+	if len(changed) > 0 {
+		return fmt.Errorf("cannot change %s of a running mapper", strings.Join(changed, ", "))
+	}
+
+	previous := hm.active.Swap(hm.compile(withPolicies(config, active)))
+	hm.retired.retire(previous.index)
+	previous.overrides.each(func(state *compiledConfig) {
+		hm.retired.retire(state.index)
+	})
+	return nil
+}
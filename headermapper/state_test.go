@@ -0,0 +1,235 @@
+package headermapper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+func TestHeaderMapper_UpdateConfig(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").
+		Build()
+
+	annotator := mapper.MetadataAnnotator()
+	matcher := mapper.HeaderMatcher()
+
+	req := httptest.NewRequest("GET", "/api/test", nil)
+	req.Header.Set("X-User-ID", "12345")
+	req.Header.Set("X-Tenant-ID", "acme")
+
+	if md := annotator(context.Background(), req); len(md.Get("user-id")) != 1 || len(md.Get("tenant-id")) != 0 {
+		t.Fatalf("metadata before update = %v", md)
+	}
+
+	err := mapper.UpdateConfig(NewConfigBuilder().
+		AddMapping(HeaderMapping{HTTPHeader: "X-Tenant-ID", GRPCMetadata: "tenant-id", Direction: Incoming}).
+		WithSkipPaths([]string{"/health"}).
+		Build())
+	if err != nil {
+		t.Fatalf("UpdateConfig() error = %v", err)
+	}
+
+	md := annotator(context.Background(), req)
+	if len(md.Get("user-id")) != 0 || len(md.Get("tenant-id")) != 1 {
+		t.Errorf("metadata after update = %v", md)
+	}
+	if key, ok := matcher("X-Tenant-ID"); !ok || key != "tenant-id" {
+		t.Errorf("matcher(X-Tenant-ID) = %q, %v", key, ok)
+	}
+	if md := annotator(context.Background(), httptest.NewRequest("GET", "/health", nil)); md != nil {
+		t.Errorf("skip path metadata = %v", md)
+	}
+}
+
+func TestHeaderMapper_UpdateConfig_Invalid(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").
+		Build()
+
+	tests := []struct {
+		name   string
+		config *Config
+	}{
+		{"nil config", nil},
+		{"invalid mapping", &Config{Mappings: []HeaderMapping{{HTTPHeader: "X User", GRPCMetadata: "user"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := mapper.UpdateConfig(tt.config); err == nil {
+				t.Error("UpdateConfig() accepted an invalid configuration")
+			}
+			if len(mapper.state().config.Mappings) != 1 {
+				t.Error("UpdateConfig() replaced the configuration after an error")
+			}
+		})
+	}
+}
+
+func TestHeaderMapper_UpdateConfig_Policies(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").
+		RateLimit(&RateLimitConfig{KeyMetadata: []string{"user-id"}, Rate: 1, Burst: 1}).
+		EnableCORS(&CORSConfig{AllowedOrigins: []string{"https://app.example.com"}}).
+		Build()
+
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		changed string
+	}{
+		{"rate limit removed", func(c *Config) { c.RateLimit = nil }, "rate_limit"},
+		{"rate limit changed", func(c *Config) { c.RateLimit = &RateLimitConfig{KeyMetadata: []string{"user-id"}, Rate: 5, Burst: 5} }, "rate_limit"},
+		{"strict mode enabled", func(c *Config) { c.StrictMode = true }, "strict_mode"},
+		{"several sections", func(c *Config) { c.CORS, c.ReplayGuard = nil, &ReplayGuardConfig{} }, "replay_guard, cors"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := cloneConfig(mapper.state().config)
+			tt.modify(config)
+			err := mapper.UpdateConfig(config)
+			if err == nil || !strings.Contains(err.Error(), tt.changed) {
+				t.Errorf("UpdateConfig() error = %v, want a change of %s", err, tt.changed)
+			}
+			if mapper.state().config.RateLimit == nil || mapper.state().config.RateLimit.Rate != 1 {
+				t.Error("UpdateConfig() replaced the configuration after an error")
+			}
+		})
+	}
+
+	// Mapping updates keep the policies and are listed in CORS preflights
+	config := cloneConfig(mapper.state().config)
+	config.Mappings = append(config.Mappings, HeaderMapping{HTTPHeader: "X-Tenant-ID", GRPCMetadata: "tenant-id", Direction: Incoming})
+	if err := mapper.UpdateConfig(config); err != nil {
+		t.Fatalf("UpdateConfig() error = %v", err)
+	}
+	req := httptest.NewRequest(http.MethodOptions, "/v1/echo", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	req.Header.Set("Access-Control-Request-Headers", "x-tenant-id")
+	w := httptest.NewRecorder()
+	mapper.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(w, req)
+	if got := w.Header().Get("Access-Control-Allow-Headers"); !strings.Contains(got, "X-Tenant-Id") {
+		t.Errorf("Access-Control-Allow-Headers = %q, want the updated mappings", got)
+	}
+}
+
+func TestHeaderMapper_UpdateConfig_Concurrent(t *testing.T) {
+	mapper := NewBuilder().
+		AddBidirectionalMapping("X-User-ID", "user-id").
+		Build()
+	annotator := mapper.MetadataAnnotator()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest("GET", "/api/test", nil)
+			req.Header.Set("X-User-ID", "12345")
+			for j := 0; j < 200; j++ {
+				_ = annotator(context.Background(), req)
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		config := NewConfigBuilder().
+			AddMapping(HeaderMapping{HTTPHeader: "X-User-ID", GRPCMetadata: "user-id", Direction: Bidirectional}).
+			WithOverwriteExisting(i%2 == 0).
+			Build()
+		if err := mapper.UpdateConfig(config); err != nil {
+			t.Fatalf("UpdateConfig() error = %v", err)
+		}
+	}
+	wg.Wait()
+}
+
+func TestHeaderMapper_ConcurrentMutation(t *testing.T) {
+	mapper := NewBuilder().
+		AddBidirectionalMapping("X-User-ID", "user-id").
+		Debug(true).
+		Build()
+	annotator := mapper.MetadataAnnotator()
+	modifier := mapper.ResponseModifier()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest("GET", "/api/test", nil)
+			req.Header.Set("X-User-ID", "12345")
+			for j := 0; j < 200; j++ {
+				md := annotator(context.Background(), req)
+				ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{HeaderMD: md})
+				_ = modifier(ctx, httptest.NewRecorder(), nil)
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		mapper.SetLogger(NoOpLogger{})
+		if err := mapper.AddMapping(HeaderMapping{HTTPHeader: "X-Tenant-ID", GRPCMetadata: "tenant-id", Direction: Incoming}); err != nil {
+			t.Fatalf("AddMapping() error = %v", err)
+		}
+		if err := mapper.RemoveMapping("X-Tenant-ID", ""); err != nil {
+			t.Fatalf("RemoveMapping() error = %v", err)
+		}
+	}
+	wg.Wait()
+}
+
+func TestHeaderMapper_UpdateConfig_CodeSetPolicies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mapper.yaml")
+	content := `
+mappings:
+  - http_header: "X-User-ID"
+    grpc_metadata: "user-id"
+    direction: incoming
+rate_limit:
+  key_metadata: ["user-id"]
+  rate: 1
+  burst: 1
+replay_guard:
+  max_skew: 1m
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfigFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewMemoryRateLimitStore()
+	config.RateLimit.Store = store
+	config.ReplayGuard.Store = NewMemoryNonceStore()
+	mapper := NewHeaderMapper(config)
+
+	// Files cannot hold stores, so reloading the same file changes nothing
+	reloaded, err := LoadConfigFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded.Mappings = append(reloaded.Mappings, HeaderMapping{HTTPHeader: "X-Tenant-ID", GRPCMetadata: "tenant-id", Direction: Incoming})
+	if err := mapper.UpdateConfig(reloaded); err != nil {
+		t.Fatalf("UpdateConfig() error = %v", err)
+	}
+	active := mapper.state().config
+	if len(active.Mappings) != 2 {
+		t.Errorf("mappings = %d, want 2", len(active.Mappings))
+	}
+	if active.RateLimit.Store != store || active.ReplayGuard.Store == nil {
+		t.Error("UpdateConfig() dropped the stores set in code")
+	}
+	if reloaded.RateLimit.Store != nil {
+		t.Error("UpdateConfig() modified the caller's configuration")
+	}
+}
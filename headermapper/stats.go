@@ -0,0 +1,111 @@
+package headermapper
+
+import (
+	"sync/atomic"
+)
+
+// cacheLineSize is the padding unit that keeps counters of different
+// mappings on separate cache lines
+const cacheLineSize = 64
+
+// mappingCounter counts the outcomes of one compiled mapping. It is padded to
+// a cache line so concurrent requests updating different mappings do not
+// contend through false sharing.
+type mappingCounter struct {
+	applied atomic.Int64
+	missing atomic.Int64
+	// deprecated counts values read from a deprecated alias
+	deprecated atomic.Int64
+	_          [cacheLineSize - 24]byte
+}
+
+// MappingStats reports the counters of a single mapping
+type MappingStats struct {
+	HTTPHeader   string
+	GRPCMetadata string
+	Direction    MappingDirection
+	// Applied counts values mapped
+	Applied int64
+	// Missing counts requests or responses lacking a required value
+	Missing int64
+	// DeprecatedAliases counts requests sending a deprecated alias instead
+	// of the header
+	DeprecatedAliases int64
+}
+
+// mappingTotals accumulates counters of configurations replaced by UpdateConfig
+type mappingTotals struct {
+	incoming atomic.Int64
+	outgoing atomic.Int64
+	failed   atomic.Int64
+}
+
+// totals sums the counters of a compiled configuration
+func (idx *mappingIndex) totals() (incoming, outgoing, failed int64) {
+	for i := range idx.incoming {
+		incoming += idx.incoming[i].counter.applied.Load()
+		failed += idx.incoming[i].counter.missing.Load()
+	}
+	for i := range idx.outgoing {
+		outgoing += idx.outgoing[i].counter.applied.Load()
+		failed += idx.outgoing[i].counter.missing.Load()
+	}
+	for _, mappings := range [][]compiledMapping{idx.incomingParams, idx.incomingPattern, idx.incomingPrefix} {
+		for i := range mappings {
+			incoming += mappings[i].counter.applied.Load()
+		}
+	}
+	for i := range idx.outgoingPrefix {
+		outgoing += idx.outgoingPrefix[i].counter.applied.Load()
+	}
+	return incoming, outgoing, failed
+}
+
+// mappingStats reports the counters of each compiled mapping in
+// configuration order, incoming mappings first and pattern and wildcard
+// mappings last
+func (idx *mappingIndex) mappingStats() []MappingStats {
+	stats := make([]MappingStats, 0, len(idx.incoming)+len(idx.outgoing)+len(idx.incomingParams)+len(idx.incomingPattern)+len(idx.incomingPrefix)+len(idx.outgoingPrefix))
+	add := func(mapping *compiledMapping, direction MappingDirection) {
+		header, key := mapping.header, mapping.key
+		if mapping.prefix {
+			header, key = header+"*", key+"*"
+		}
+		stats = append(stats, MappingStats{
+			HTTPHeader:   header,
+			GRPCMetadata: key,
+			Direction:    direction,
+			Applied:      mapping.counter.applied.Load(),
+			Missing:      mapping.counter.missing.Load(),
+
+			DeprecatedAliases: mapping.counter.deprecated.Load(),
+		})
+	}
+	for i := range idx.incoming {
+		add(&idx.incoming[i], Incoming)
+	}
+	for i := range idx.outgoing {
+		add(&idx.outgoing[i], Outgoing)
+	}
+	for i := range idx.incomingParams {
+		add(&idx.incomingParams[i], Incoming)
+	}
+	for i := range idx.incomingPattern {
+		add(&idx.incomingPattern[i], Incoming)
+	}
+	for i := range idx.incomingPrefix {
+		add(&idx.incomingPrefix[i], Incoming)
+	}
+	for i := range idx.outgoingPrefix {
+		add(&idx.outgoingPrefix[i], Outgoing)
+	}
+	return stats
+}
+
+// retire folds the counters of a replaced configuration into the totals
+func (t *mappingTotals) retire(idx *mappingIndex) {
+	incoming, outgoing, failed := idx.totals()
+	t.incoming.Add(incoming)
+	t.outgoing.Add(outgoing)
+	t.failed.Add(failed)
+}
@@ -0,0 +1,110 @@
+package headermapper
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"unsafe"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestMappingCounter_Padded(t *testing.T) {
+	if size := unsafe.Sizeof(mappingCounter{}); size != cacheLineSize {
+		t.Errorf("sizeof(mappingCounter) = %d, want %d", size, cacheLineSize)
+	}
+}
+
+func TestGetStats_Mappings(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").
+		AddIncomingMapping("X-Tenant-ID", "tenant-id").WithRequired(true).
+		AddOutgoingMapping("request-id", "X-Request-ID").
+		Build()
+
+	annotator := mapper.MetadataAnnotator()
+	req := httptest.NewRequest("GET", "/api/test", nil)
+	req.Header.Set("X-User-ID", "12345")
+	annotator(context.Background(), req)
+	annotator(context.Background(), req)
+
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{
+		HeaderMD: metadata.Pairs("request-id", "abc"),
+	})
+	if err := mapper.ResponseModifier()(ctx, httptest.NewRecorder(), nil); err != nil {
+		t.Fatalf("ResponseModifier() error = %v", err)
+	}
+
+	stats := mapper.GetStats()
+	if stats.IncomingMappings != 2 || stats.OutgoingMappings != 1 || stats.FailedMappings != 2 {
+		t.Errorf("totals = %d/%d/%d, want 2/1/2", stats.IncomingMappings, stats.OutgoingMappings, stats.FailedMappings)
+	}
+
+	want := []MappingStats{
+		{HTTPHeader: "X-User-Id", GRPCMetadata: "user-id", Direction: Incoming, Applied: 2},
+		{HTTPHeader: "X-Tenant-Id", GRPCMetadata: "tenant-id", Direction: Incoming, Missing: 2},
+		{HTTPHeader: "X-Request-Id", GRPCMetadata: "request-id", Direction: Outgoing, Applied: 1},
+	}
+	if len(stats.Mappings) != len(want) {
+		t.Fatalf("Mappings = %+v", stats.Mappings)
+	}
+	for i, got := range stats.Mappings {
+		if got != want[i] {
+			t.Errorf("Mappings[%d] = %+v, want %+v", i, got, want[i])
+		}
+	}
+}
+
+func TestGetStats_SurvivesUpdateConfig(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").
+		Build()
+
+	req := httptest.NewRequest("GET", "/api/test", nil)
+	req.Header.Set("X-User-ID", "12345")
+	mapper.MetadataAnnotator()(context.Background(), req)
+
+	err := mapper.UpdateConfig(NewConfigBuilder().
+		AddMapping(HeaderMapping{HTTPHeader: "X-User-ID", GRPCMetadata: "user", Direction: Incoming}).
+		Build())
+	if err != nil {
+		t.Fatalf("UpdateConfig() error = %v", err)
+	}
+	mapper.MetadataAnnotator()(context.Background(), req)
+
+	stats := mapper.GetStats()
+	if stats.IncomingMappings != 2 {
+		t.Errorf("IncomingMappings = %d, want 2", stats.IncomingMappings)
+	}
+	if len(stats.Mappings) != 1 || stats.Mappings[0].Applied != 1 {
+		t.Errorf("Mappings = %+v", stats.Mappings)
+	}
+}
+
+func TestGetStats_Concurrent(t *testing.T) {
+	mapper := NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").
+		Build()
+	annotator := mapper.MetadataAnnotator()
+
+	const workers, requests = 8, 100
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest("GET", "/api/test", nil)
+			req.Header.Set("X-User-ID", "12345")
+			for range requests {
+				annotator(context.Background(), req)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := mapper.GetStats().IncomingMappings; got != workers*requests {
+		t.Errorf("IncomingMappings = %d, want %d", got, workers*requests)
+	}
+}
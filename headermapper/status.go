@@ -0,0 +1,70 @@
+package headermapper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/protobuf/proto"
+)
+
+// HTTPStatusKey is the conventional metadata key backends set to choose the
+// HTTP status of a successful response
+const HTTPStatusKey = "http-status"
+
+// validateStatusKey checks the StatusMetadataKey of a configuration
+func validateStatusKey(key string) error {
+	if key != "" && (!validMetadataKey(key) || strings.HasSuffix(key, "-bin")) {
+		return fmt.Errorf("invalid status metadata key: %q", key)
+	}
+	return nil
+}
+
+// parseStatusCode parses a status set by a backend; informational and
+// out-of-range codes are rejected as the body follows the status
+func parseStatusCode(value string) (int, bool) {
+	code, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || code < 200 || code > 599 {
+		return 0, false
+	}
+	return code, true
+}
+
+// statusKey reports whether a metadata key carries the response status, which
+// is not forwarded as a response header
+func (cc *compiledConfig) statusKey(key string) bool {
+	return cc.config.StatusMetadataKey != "" && strings.EqualFold(key, cc.config.StatusMetadataKey)
+}
+
+// StatusModifier creates a forward response option writing the HTTP status
+// a backend set under StatusMetadataKey. It writes the status, which sends
+// the response headers, so it must be the last forward response option;
+// CreateGatewayMux registers it after the user-provided options. Streamed
+// responses keep the gateway's status.
+func (hm *HeaderMapper) StatusModifier() func(context.Context, http.ResponseWriter, proto.Message) error {
+	return func(ctx context.Context, w http.ResponseWriter, msg proto.Message) error {
+		cc := hm.state()
+		key := cc.config.StatusMetadataKey
+		if key == "" || msg == nil || streamMessage(w, msg) {
+			return nil
+		}
+		md, ok := runtime.ServerMetadataFromContext(ctx)
+		if !ok {
+			return nil
+		}
+		values := md.HeaderMD.Get(key)
+		if len(values) == 0 {
+			return nil
+		}
+		code, ok := parseStatusCode(values[0])
+		if !ok {
+			hm.log().Warn("Ignoring invalid HTTP status in metadata: ", values[0])
+			return nil
+		}
+		w.WriteHeader(code)
+		return nil
+	}
+}
@@ -0,0 +1,93 @@
+package headermapper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestStatusModifier(t *testing.T) {
+	mapper := NewBuilder().
+		AddOutgoingMapping("request-id", "X-Request-ID").
+		MapHTTPStatus("").
+		Build()
+	// A user option running after the mapper's own still sets its header
+	mux := CreateGatewayMux(mapper, runtime.WithForwardResponseOption(
+		func(_ context.Context, w http.ResponseWriter, _ proto.Message) error {
+			w.Header().Set("X-Served-By", "gateway")
+			return nil
+		}))
+
+	tests := []struct {
+		name   string
+		status string
+		want   int
+	}{
+		{"created", "201", http.StatusCreated},
+		{"too many requests", " 429 ", http.StatusTooManyRequests},
+		{"absent", "", http.StatusOK},
+		{"not a number", "created", http.StatusOK},
+		{"informational", "100", http.StatusOK},
+		{"out of range", "600", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			md := metadata.Pairs("request-id", "r")
+			if tt.status != "" {
+				md.Set(HTTPStatusKey, tt.status)
+			}
+			req := httptest.NewRequest("POST", "/v1/users", nil)
+			ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{HeaderMD: md})
+			_, outbound := runtime.MarshalerForRequest(mux, req)
+			w := httptest.NewRecorder()
+			runtime.ForwardResponseMessage(ctx, mux, outbound, w, req, wrapperspb.String("ok"),
+				mux.GetForwardResponseOptions()...)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+			h := w.Result().Header
+			if h.Get("X-Request-ID") != "r" || h.Get("X-Served-By") != "gateway" {
+				t.Errorf("headers written after the status: %v", h)
+			}
+			if h.Get("Grpc-Metadata-Http-Status") != "" {
+				t.Errorf("status metadata forwarded as a header: %v", h)
+			}
+		})
+	}
+}
+
+func TestStatusModifier_Disabled(t *testing.T) {
+	mapper := NewBuilder().Build()
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{
+		HeaderMD: metadata.Pairs(HTTPStatusKey, "201"),
+	})
+	w := httptest.NewRecorder()
+	if err := mapper.StatusModifier()(ctx, w, wrapperspb.String("ok")); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if _, ok := mapper.OutgoingHeaderMatcher()(HTTPStatusKey); !ok {
+		t.Error("OutgoingHeaderMatcher dropped http-status without StatusMetadataKey")
+	}
+}
+
+func TestStatusMetadataKey_Validate(t *testing.T) {
+	for _, key := range []string{"Bad Key", "http-status-bin"} {
+		if err := ValidateConfig(&Config{StatusMetadataKey: key}); err == nil {
+			t.Errorf("ValidateConfig(%q) expected error", key)
+		}
+	}
+	if err := ValidateConfig(&Config{StatusMetadataKey: HTTPStatusKey}); err != nil {
+		t.Errorf("ValidateConfig() error = %v", err)
+	}
+}
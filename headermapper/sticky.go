@@ -0,0 +1,247 @@
+package headermapper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+	"unsafe"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// StickyExperimentConfig assigns requests to the variants of an experiment
+// in Handler and keeps clients on their variant: the variant is echoed in
+// a response header and optionally a cookie, and honored when sent back.
+// Requests without one are assigned by Bucket from the first present ID
+// header, or by a previous assignment kept in Store. The variant reaches
+// backends as ExperimentIDKey and ExperimentVariantKey metadata.
+type StickyExperimentConfig struct {
+	// Name identifies the experiment and salts its buckets
+	Name string `json:"name" yaml:"name"`
+	// Variants are assigned consecutive bucket ranges by weight; see NewExperiment
+	Variants []ExperimentVariant `json:"variants" yaml:"variants"`
+	// IDHeaders identify the client for assignment, e.g. X-User-ID then X-Request-ID
+	IDHeaders []string `json:"id_headers" yaml:"id_headers"`
+	// Header carries the variant in requests and responses (default X-Experiment-Variant)
+	Header string `json:"header,omitempty" yaml:"header,omitempty"`
+	// Cookie, when set, also carries the variant, for browsers
+	Cookie string `json:"cookie,omitempty" yaml:"cookie,omitempty"`
+	// CookieMaxAge is the lifetime of the cookie (default 30 days)
+	CookieMaxAge time.Duration `json:"cookie_max_age,omitempty" yaml:"cookie_max_age,omitempty"`
+	// Store keeps assignments by ID so they survive changes of the weights;
+	// without it assignments are recomputed from the buckets
+	Store VariantStore `json:"-" yaml:"-"`
+	// StoreTTL is how long assignments are kept in Store (default 30 days)
+	StoreTTL time.Duration `json:"store_ttl,omitempty" yaml:"store_ttl,omitempty"`
+}
+
+// validate checks the experiment and header names
+func (sc *StickyExperimentConfig) validate() error {
+	if sc.Name == "" {
+		return fmt.Errorf("experiment: name cannot be empty")
+	}
+	if len(NewExperiment(sc.Name, sc.Variants...).variants) == 0 {
+		return fmt.Errorf("experiment %s: no variant has a positive weight", sc.Name)
+	}
+	if len(sc.IDHeaders) == 0 {
+		return fmt.Errorf("experiment %s: id_headers cannot be empty", sc.Name)
+	}
+	headers := append([]string{sc.Header}, sc.IDHeaders...)
+	for i, header := range headers {
+		if (i > 0 || header != "") && !validHeaderName(header) {
+			return fmt.Errorf("experiment %s: invalid header %q", sc.Name, header)
+		}
+	}
+	if sc.Cookie != "" && !validHeaderName(sc.Cookie) {
+		return fmt.Errorf("experiment %s: invalid cookie name %q", sc.Name, sc.Cookie)
+	}
+	return nil
+}
+
+// VariantStore keeps experiment assignments. Implementations backed by
+// shared storage keep clients on their variant across gateway replicas.
+type VariantStore interface {
+	// Get returns the variant assigned to key, or an empty string
+	Get(ctx context.Context, key string) (string, error)
+	// Set records the variant assigned to key for ttl
+	Set(ctx context.Context, key, variant string, ttl time.Duration) error
+}
+
+// MemoryVariantStore is an in-process VariantStore with TTL expiry
+type MemoryVariantStore struct {
+	mu        sync.Mutex
+	entries   map[string]variantEntry
+	sweepSize int
+	now       func() time.Time
+}
+
+type variantEntry struct {
+	variant string
+	expires time.Time
+}
+
+// NewMemoryVariantStore creates an in-memory variant store
+func NewMemoryVariantStore() *MemoryVariantStore {
+	return &MemoryVariantStore{
+		entries:   make(map[string]variantEntry),
+		sweepSize: 1024,
+		now:       time.Now,
+	}
+}
+
+// Get implements VariantStore
+func (s *MemoryVariantStore) Get(ctx context.Context, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.entries[key]; ok && s.now().Before(entry.expires) {
+		return entry.variant, nil
+	}
+	return "", nil
+}
+
+// Set implements VariantStore
+func (s *MemoryVariantStore) Set(ctx context.Context, key, variant string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if _, ok := s.entries[key]; !ok && len(s.entries) >= s.sweepSize {
+		for k, entry := range s.entries {
+			if !now.Before(entry.expires) {
+				delete(s.entries, k)
+			}
+		}
+		if len(s.entries) >= s.sweepSize {
+			s.sweepSize *= 2
+		}
+	}
+	s.entries[key] = variantEntry{variant: variant, expires: now.Add(ttl)}
+	return nil
+}
+
+// footprint approximates the memory of the assignments
+func (s *MemoryVariantStore) footprint() (int, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := mapBytes(len(s.entries), stringSize+unsafe.Sizeof(variantEntry{}))
+	for key, entry := range s.entries {
+		n += int64(len(key) + len(entry.variant))
+	}
+	return len(s.entries), n
+}
+
+// stickyExperiment enforces a StickyExperimentConfig
+type stickyExperiment struct {
+	config       *StickyExperimentConfig
+	experiment   *Experiment
+	header       string
+	cookieMaxAge time.Duration
+	storeTTL     time.Duration
+	hm           *HeaderMapper
+}
+
+func newStickyExperiment(config *StickyExperimentConfig, hm *HeaderMapper) *stickyExperiment {
+	header := "X-Experiment-Variant"
+	if config.Header != "" {
+		header = http.CanonicalHeaderKey(config.Header)
+	}
+	cookieMaxAge := config.CookieMaxAge
+	if cookieMaxAge <= 0 {
+		cookieMaxAge = 30 * 24 * time.Hour
+	}
+	storeTTL := config.StoreTTL
+	if storeTTL <= 0 {
+		storeTTL = 30 * 24 * time.Hour
+	}
+	return &stickyExperiment{
+		config:       config,
+		experiment:   NewExperiment(config.Name, config.Variants...),
+		header:       header,
+		cookieMaxAge: cookieMaxAge,
+		storeTTL:     storeTTL,
+		hm:           hm,
+	}
+}
+
+type stickyVariantKey struct{}
+
+// variant returns the variant of req: the one sent by the client, the
+// stored assignment of its ID or a new assignment
+func (s *stickyExperiment) variant(req *http.Request) string {
+	if variant := req.Header.Get(s.header); s.experiment.hasVariant(variant) {
+		return variant
+	}
+	if s.config.Cookie != "" {
+		if cookie, err := req.Cookie(s.config.Cookie); err == nil && s.experiment.hasVariant(cookie.Value) {
+			return cookie.Value
+		}
+	}
+
+	var id string
+	for _, header := range s.config.IDHeaders {
+		if id = req.Header.Get(header); id != "" {
+			break
+		}
+	}
+	if id == "" {
+		return ""
+	}
+
+	store := s.config.Store
+	if store == nil {
+		return s.experiment.Assign(id)
+	}
+	key := s.experiment.Name() + ":" + id
+	variant, err := store.Get(req.Context(), key)
+	if err != nil {
+		// Fall back to the buckets so an unavailable store does not take down the gateway
+		s.hm.log().Warn("Variant store error:", err)
+		return s.experiment.Assign(id)
+	}
+	if s.experiment.hasVariant(variant) {
+		return variant
+	}
+	variant = s.experiment.Assign(id)
+	if variant != "" {
+		if err := store.Set(req.Context(), key, variant, s.storeTTL); err != nil {
+			s.hm.log().Warn("Variant store error:", err)
+		}
+	}
+	return variant
+}
+
+// assign echoes the variant of req in the response and records it in the
+// request context for annotate
+func (s *stickyExperiment) assign(w http.ResponseWriter, req *http.Request) *http.Request {
+	variant := s.variant(req)
+	if variant == "" {
+		return req
+	}
+
+	w.Header().Set(s.header, variant)
+	if s.config.Cookie != "" {
+		if cookie, err := req.Cookie(s.config.Cookie); err != nil || cookie.Value != variant {
+			http.SetCookie(w, &http.Cookie{
+				Name:     s.config.Cookie,
+				Value:    variant,
+				Path:     "/",
+				MaxAge:   int(s.cookieMaxAge.Seconds()),
+				Secure:   req.TLS != nil,
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+	}
+	return req.WithContext(context.WithValue(req.Context(), stickyVariantKey{}, variant))
+}
+
+// annotate forwards the variant assigned by Handler to backends
+func (s *stickyExperiment) annotate(req *http.Request, md metadata.MD) {
+	if variant, ok := req.Context().Value(stickyVariantKey{}).(string); ok {
+		md.Set(ExperimentIDKey, s.experiment.Name())
+		md.Set(ExperimentVariantKey, variant)
+	}
+}
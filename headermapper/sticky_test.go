@@ -0,0 +1,173 @@
+package headermapper
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func TestStickyExperiment_Handler(t *testing.T) {
+	config := &StickyExperimentConfig{
+		Name:      "checkout-v2",
+		Variants:  []ExperimentVariant{{Name: "control", Weight: 50}, {Name: "treatment", Weight: 50}},
+		IDHeaders: []string{"X-User-ID", "X-Request-ID"},
+		Cookie:    "exp_checkout",
+	}
+	mapper := NewBuilder().StickyExperiment(config).Build()
+	if err := mapper.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	var md metadata.MD
+	handler := mapper.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		md = mapper.MetadataAnnotator()(r.Context(), r)
+	}))
+
+	experiment := NewExperiment(config.Name, config.Variants...)
+	other := map[string]string{"control": "treatment", "treatment": "control"}
+	assigned := experiment.Assign("user-1")
+
+	tests := []struct {
+		name      string
+		headers   map[string]string
+		cookie    string
+		variant   string
+		setCookie bool
+	}{
+		{"assigned by user", map[string]string{"X-User-ID": "user-1"}, "", assigned, true},
+		{"assigned by fallback", map[string]string{"X-Request-ID": "user-1"}, "", assigned, true},
+		{"header honored", map[string]string{"X-User-ID": "user-1", "X-Experiment-Variant": other[assigned]}, "", other[assigned], true},
+		{"cookie honored", map[string]string{"X-User-ID": "user-1"}, other[assigned], other[assigned], false},
+		{"unknown variant reassigned", map[string]string{"X-User-ID": "user-1", "X-Experiment-Variant": "bogus"}, "", assigned, true},
+		{"no id", nil, "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			md = nil
+			req := httptest.NewRequest("GET", "/v1/cart", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "exp_checkout", Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if got := w.Header().Get("X-Experiment-Variant"); got != tt.variant {
+				t.Errorf("X-Experiment-Variant = %q, want %q", got, tt.variant)
+			}
+			cookies := w.Result().Cookies()
+			if tt.setCookie != (len(cookies) == 1) {
+				t.Fatalf("cookies = %v, want set %v", cookies, tt.setCookie)
+			}
+			if tt.setCookie && (cookies[0].Value != tt.variant || !cookies[0].HttpOnly || cookies[0].MaxAge != 30*24*3600) {
+				t.Errorf("cookie = %+v", cookies[0])
+			}
+
+			wantID := ""
+			if tt.variant != "" {
+				wantID = config.Name
+			}
+			if got := firstValue(md, ExperimentVariantKey); got != tt.variant {
+				t.Errorf("%s = %q, want %q", ExperimentVariantKey, got, tt.variant)
+			}
+			if got := firstValue(md, ExperimentIDKey); got != wantID {
+				t.Errorf("%s = %q, want %q", ExperimentIDKey, got, wantID)
+			}
+		})
+	}
+}
+
+func TestStickyExperiment_Store(t *testing.T) {
+	store := NewMemoryVariantStore()
+	config := &StickyExperimentConfig{
+		Name:      "search",
+		Variants:  []ExperimentVariant{{Name: "a", Weight: 100}},
+		IDHeaders: []string{"X-User-ID"},
+		Store:     store,
+	}
+
+	send := func(config *StickyExperimentConfig) string {
+		mapper := NewBuilder().StickyExperiment(config).Build()
+		handler := mapper.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		req := httptest.NewRequest("GET", "/v1/search", nil)
+		req.Header.Set("X-User-ID", "user-1")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Header().Get("X-Experiment-Variant")
+	}
+
+	if got := send(config); got != "a" {
+		t.Fatalf("first assignment = %q, want a", got)
+	}
+	if got, _ := store.Get(context.Background(), "search:user-1"); got != "a" {
+		t.Errorf("stored variant = %q, want a", got)
+	}
+
+	// The stored assignment outlives a change of weights
+	reweighted := *config
+	reweighted.Variants = []ExperimentVariant{{Name: "b", Weight: 99}, {Name: "a", Weight: 1}}
+	if got := send(&reweighted); got != "a" {
+		t.Errorf("reweighted assignment = %q, want stored a", got)
+	}
+
+	failing := *config
+	failing.Store = failingVariantStore{}
+	if got := send(&failing); got != "a" {
+		t.Errorf("assignment with failing store = %q, want a", got)
+	}
+
+	store.now = func() time.Time { return time.Now().Add(31 * 24 * time.Hour) }
+	if got, _ := store.Get(context.Background(), "search:user-1"); got != "" {
+		t.Errorf("expired variant = %q, want empty", got)
+	}
+}
+
+type failingVariantStore struct{}
+
+func (failingVariantStore) Get(ctx context.Context, key string) (string, error) {
+	return "", errors.New("unavailable")
+}
+
+func (failingVariantStore) Set(ctx context.Context, key, variant string, ttl time.Duration) error {
+	return errors.New("unavailable")
+}
+
+func TestStickyExperimentConfig_Validate(t *testing.T) {
+	valid := StickyExperimentConfig{
+		Name:      "exp",
+		Variants:  []ExperimentVariant{{Name: "a", Weight: 100}},
+		IDHeaders: []string{"X-User-ID"},
+	}
+
+	tests := []struct {
+		name   string
+		modify func(c *StickyExperimentConfig)
+	}{
+		{"missing name", func(c *StickyExperimentConfig) { c.Name = "" }},
+		{"no weighted variant", func(c *StickyExperimentConfig) { c.Variants = []ExperimentVariant{{Name: "a"}} }},
+		{"no id headers", func(c *StickyExperimentConfig) { c.IDHeaders = nil }},
+		{"invalid header", func(c *StickyExperimentConfig) { c.Header = "X Variant" }},
+		{"invalid cookie", func(c *StickyExperimentConfig) { c.Cookie = "a;b" }},
+	}
+
+	if err := valid.validate(); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := valid
+			tt.modify(&config)
+			if err := config.validate(); err == nil {
+				t.Error("validate() = nil, want error")
+			}
+		})
+	}
+}
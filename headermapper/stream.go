@@ -0,0 +1,18 @@
+package headermapper
+
+import (
+	"net/http"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// streamMessage reports whether a forward response option call is for a
+// message of a server stream. For streams the gateway captures the header
+// metadata once the backend sends it, marks the response chunked and calls
+// the options with a nil message before the first message, which is where
+// the headers are mapped. The calls for each message that follow come too
+// late, as the headers are flushed with the first message, and applying
+// the mappings again would only inflate statistics and repeat warnings.
+func streamMessage(w http.ResponseWriter, msg proto.Message) bool {
+	return msg != nil && w.Header().Get("Transfer-Encoding") == "chunked"
+}
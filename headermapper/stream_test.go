@@ -0,0 +1,56 @@
+package headermapper
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestResponseModifier_ServerStream(t *testing.T) {
+	mapper := NewBuilder().
+		AddOutgoingMapping("response-time", "X-Response-Time").
+		AddOutgoingMapping("request-id", "X-Request-ID").WithGenerator(GenerateUUID).
+		Build()
+	mux := runtime.NewServeMux(runtime.WithForwardResponseOption(mapper.ResponseModifier()))
+
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{
+		HeaderMD: metadata.Pairs("response-time", "12ms"),
+	})
+	messages := []proto.Message{wrapperspb.String("a"), wrapperspb.String("b"), wrapperspb.String("c")}
+	recv := func() (proto.Message, error) {
+		if len(messages) == 0 {
+			return nil, io.EOF
+		}
+		msg := messages[0]
+		messages = messages[1:]
+		return msg, nil
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/v1/stream", nil)
+	runtime.ForwardResponseStream(ctx, mux, &runtime.JSONPb{}, w, req, recv, mux.GetForwardResponseOptions()...)
+
+	if got := w.Header().Get("X-Response-Time"); got != "12ms" {
+		t.Errorf("X-Response-Time = %q", got)
+	}
+	requestID := w.Header().Get("X-Request-Id")
+	if requestID == "" {
+		t.Error("X-Request-Id not generated")
+	}
+
+	// The headers are mapped once for the whole stream
+	for _, stats := range mapper.GetStats().Mappings {
+		if stats.Applied != 1 {
+			t.Errorf("%s applied %d times, want 1", stats.HTTPHeader, stats.Applied)
+		}
+	}
+	if got := w.Result().Header.Get("X-Request-Id"); got != requestID {
+		t.Errorf("flushed X-Request-Id = %q, want %q", got, requestID)
+	}
+}
@@ -0,0 +1,109 @@
+package headermapper
+
+import (
+	"context"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// StreamHooks observe the messages of a single server stream. Any hook may
+// be nil.
+type StreamHooks struct {
+	// AfterRecv is called with each message received; an error fails the
+	// RecvMsg call
+	AfterRecv func(msg interface{}) error
+	// BeforeSend is called with each message before it is sent; an error
+	// fails the SendMsg call without sending the message
+	BeforeSend func(msg interface{}) error
+	// OnFinish is called when the handler returns, before the status and
+	// trailers are sent
+	OnFinish func(err error)
+}
+
+// StreamInfo describes a server stream to the function creating its hooks
+type StreamInfo struct {
+	FullMethod string
+	stream     grpc.ServerStream
+	sent       atomic.Int64
+	received   atomic.Int64
+}
+
+// Context returns the context of the stream, with the processed metadata
+func (si *StreamInfo) Context() context.Context {
+	return si.stream.Context()
+}
+
+// SetHeader sets header metadata, sent with the first message
+func (si *StreamInfo) SetHeader(md metadata.MD) error {
+	return si.stream.SetHeader(md)
+}
+
+// SetTrailer sets trailer metadata, sent with the status when the stream ends
+func (si *StreamInfo) SetTrailer(md metadata.MD) {
+	si.stream.SetTrailer(md)
+}
+
+// Sent and Received return the number of messages sent and received so far
+func (si *StreamInfo) Sent() int64     { return si.sent.Load() }
+func (si *StreamInfo) Received() int64 { return si.received.Load() }
+
+// WithStreamHooks makes StreamServerInterceptor call newHooks for each
+// stream and run the returned hooks around its messages. Hooks are created
+// per stream, so their closures can accumulate per-stream state, for example
+// to set trailers when the stream completes:
+//
+//	headermapper.WithStreamHooks(func(info *headermapper.StreamInfo) headermapper.StreamHooks {
+//		return headermapper.StreamHooks{OnFinish: func(error) {
+//			info.SetTrailer(metadata.Pairs("messages-sent", strconv.FormatInt(info.Sent(), 10)))
+//		}}
+//	})
+func WithStreamHooks(newHooks func(info *StreamInfo) StreamHooks) InterceptorOption {
+	return func(o *interceptorOptions) {
+		o.streamHooks = newHooks
+	}
+}
+
+// hookedServerStream runs StreamHooks around the messages of a stream
+type hookedServerStream struct {
+	grpc.ServerStream
+	info  *StreamInfo
+	hooks StreamHooks
+}
+
+func newHookedServerStream(ss grpc.ServerStream, fullMethod string, newHooks func(*StreamInfo) StreamHooks) *hookedServerStream {
+	info := &StreamInfo{FullMethod: fullMethod, stream: ss}
+	return &hookedServerStream{ServerStream: ss, info: info, hooks: newHooks(info)}
+}
+
+func (s *hookedServerStream) SendMsg(m interface{}) error {
+	if s.hooks.BeforeSend != nil {
+		if err := s.hooks.BeforeSend(m); err != nil {
+			return err
+		}
+	}
+	if err := s.ServerStream.SendMsg(m); err != nil {
+		return err
+	}
+	s.info.sent.Add(1)
+	return nil
+}
+
+func (s *hookedServerStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	s.info.received.Add(1)
+	if s.hooks.AfterRecv != nil {
+		return s.hooks.AfterRecv(m)
+	}
+	return nil
+}
+
+// finish runs OnFinish with the result of the handler
+func (s *hookedServerStream) finish(err error) {
+	if s.hooks.OnFinish != nil {
+		s.hooks.OnFinish(err)
+	}
+}
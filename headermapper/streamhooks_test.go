@@ -0,0 +1,104 @@
+package headermapper
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestWithStreamHooks(t *testing.T) {
+	var finished error = errors.New("not finished")
+	client := startHeaderServer(t, WithOutgoingMappings(), WithStreamHooks(func(info *StreamInfo) StreamHooks {
+		return StreamHooks{
+			BeforeSend: func(msg interface{}) error {
+				return info.SetHeader(metadata.Pairs("first-status", msg.(*grpc_health_v1.HealthCheckResponse).GetStatus().String()))
+			},
+			OnFinish: func(err error) {
+				finished = err
+				info.SetTrailer(metadata.Pairs("messages-sent", strconv.FormatInt(info.Sent(), 10), "method", info.FullMethod))
+			},
+		}
+	}))
+
+	stream, err := client.Watch(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := stream.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{"first-status": "SERVING", "server-version": "v2", "region": "us-east-1"} {
+		if got := header.Get(key); len(got) != 1 || got[0] != want {
+			t.Errorf("header %s = %v, want %q", key, got, want)
+		}
+	}
+	for {
+		if _, err := stream.Recv(); err != nil {
+			break
+		}
+	}
+
+	trailer := stream.Trailer()
+	if got := trailer.Get("messages-sent"); len(got) != 1 || got[0] != "1" {
+		t.Errorf("messages-sent = %v, want 1", got)
+	}
+	if got := trailer.Get("method"); len(got) != 1 || got[0] != "/grpc.health.v1.Health/Watch" {
+		t.Errorf("method = %v", got)
+	}
+	if finished != nil {
+		t.Errorf("OnFinish error = %v, want nil", finished)
+	}
+}
+
+// messageServerStream is a mockServerStream whose messages always succeed
+type messageServerStream struct {
+	mockServerStream
+}
+
+func (s *messageServerStream) SendMsg(m interface{}) error { return nil }
+func (s *messageServerStream) RecvMsg(m interface{}) error { return nil }
+
+func TestWithStreamHooks_Errors(t *testing.T) {
+	errRejected := errors.New("rejected")
+	mapper := NewBuilder().Build()
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("user-id", "u"))
+
+	tests := []struct {
+		name  string
+		hooks StreamHooks
+		call  func(grpc.ServerStream) error
+	}{
+		{"send", StreamHooks{BeforeSend: func(interface{}) error { return errRejected }},
+			func(s grpc.ServerStream) error { return s.SendMsg("msg") }},
+		{"recv", StreamHooks{AfterRecv: func(interface{}) error { return errRejected }},
+			func(s grpc.ServerStream) error { return s.RecvMsg(new(string)) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var info *StreamInfo
+			err := mapper.StreamServerInterceptor(WithStreamHooks(func(si *StreamInfo) StreamHooks {
+				info = si
+				return tt.hooks
+			}))(nil, &messageServerStream{mockServerStream{ctx: ctx}}, &grpc.StreamServerInfo{FullMethod: "/echo.v1.EchoService/Chat"},
+				func(srv interface{}, stream grpc.ServerStream) error {
+					if got := metadata.ValueFromIncomingContext(stream.Context(), "user-id"); len(got) != 1 {
+						t.Errorf("stream context metadata = %v", got)
+					}
+					return tt.call(stream)
+				})
+			if !errors.Is(err, errRejected) {
+				t.Errorf("StreamServerInterceptor() error = %v, want %v", err, errRejected)
+			}
+			if tt.name == "send" && info.Sent() != 0 {
+				t.Errorf("Sent() = %d, want 0 after rejected message", info.Sent())
+			}
+		})
+	}
+}
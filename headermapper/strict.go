@@ -0,0 +1,71 @@
+package headermapper
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// strictRequiredCheck rejects requests missing the headers of required
+// incoming mappings, listing all of them. Headers filled in by a generator
+// or default value are not missing.
+func (hm *HeaderMapper) strictRequiredCheck(w http.ResponseWriter, req *http.Request) error {
+	cc, release := hm.requestState(req.Context())
+	defer release()
+	var missing []string
+	for i := range cc.index.incoming {
+		mapping := &cc.index.incoming[i]
+		if !mapping.required || mapping.generator != nil || mapping.defaultValue != "" {
+			continue
+		}
+		src := &cc.index.sources[mapping.source]
+		if hm.sourceValue(cc, req, src, req.Header[src.header]) == "" {
+			mapping.counter.missing.Add(1)
+			missing = appendUnique(missing, mapping.header)
+		}
+	}
+	if len(missing) > 0 {
+		return missingError("missing required headers", missing)
+	}
+	return nil
+}
+
+// strictRequiredCall rejects calls whose metadata lacks the keys of required
+// incoming mappings, listing all of them
+func (hm *HeaderMapper) strictRequiredCall(ctx context.Context, fullMethod string, md metadata.MD) error {
+	cc, release := hm.requestState(ctx)
+	defer release()
+	var missing []string
+	for _, mappings := range [][]compiledMapping{cc.index.incoming, cc.index.incomingParams} {
+		for i := range mappings {
+			mapping := &mappings[i]
+			if mapping.required && len(md.Get(mapping.key)) == 0 {
+				mapping.counter.missing.Add(1)
+				missing = appendUnique(missing, mapping.key)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		return missingError("missing required metadata", missing)
+	}
+	return nil
+}
+
+// missingError rejects a request or call with InvalidArgument, listing the
+// missing headers or keys in the message and as BadRequest field violations
+func missingError(message string, missing []string) error {
+	violations := make([]*errdetails.BadRequest_FieldViolation, len(missing))
+	for i, name := range missing {
+		violations[i] = &errdetails.BadRequest_FieldViolation{Field: name, Description: "required"}
+	}
+	return &RequestError{
+		Code:    codes.InvalidArgument,
+		Message: message + ": " + strings.Join(missing, ", "),
+		details: []protoadapt.MessageV1{&errdetails.BadRequest{FieldViolations: violations}},
+	}
+}
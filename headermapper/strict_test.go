@@ -0,0 +1,81 @@
+package headermapper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func newStrictMapper(strict bool) *HeaderMapper {
+	return NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").WithRequired(true).
+		AddIncomingMapping("X-Tenant-ID", "tenant-id").WithRequired(true).
+		AddIncomingMapping("X-Request-ID", "request-id").WithRequired(true).WithGenerator(func() string { return "generated" }).
+		AddIncomingMapping("X-Client", "client").
+		StrictMode(strict).
+		Build()
+}
+
+func TestStrictMode_Handler(t *testing.T) {
+	tests := []struct {
+		name     string
+		strict   bool
+		headers  map[string]string
+		expected int
+		missing  []string
+	}{
+		{"all present", true, map[string]string{"X-User-ID": "u", "X-Tenant-ID": "t"}, http.StatusOK, nil},
+		{"one missing", true, map[string]string{"X-User-ID": "u"}, http.StatusBadRequest, []string{"X-Tenant-Id"}},
+		{"all missing", true, map[string]string{"X-Client": "c"}, http.StatusBadRequest, []string{"X-User-Id", "X-Tenant-Id"}},
+		{"not strict", false, nil, http.StatusOK, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newStrictMapper(tt.strict).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			req := httptest.NewRequest("GET", "/v1/echo", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("Handler() status = %d, want %d", w.Code, tt.expected)
+			}
+			for _, header := range tt.missing {
+				if !strings.Contains(w.Body.String(), header) {
+					t.Errorf("body %q does not list %s", w.Body.String(), header)
+				}
+			}
+		})
+	}
+}
+
+func TestStrictMode_UnaryServerInterceptor(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/echo.v1.EchoService/Echo"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return "ok", nil }
+
+	mapper := newStrictMapper(true)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("user-id", "u"))
+	_, err := mapper.UnaryServerInterceptor()(ctx, nil, info, handler)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("UnaryServerInterceptor() error = %v, want InvalidArgument", err)
+	}
+	if msg := status.Convert(err).Message(); !strings.Contains(msg, "tenant-id") || !strings.Contains(msg, "request-id") {
+		t.Errorf("message %q does not list the missing keys", msg)
+	}
+
+	ctx = metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs("user-id", "u", "tenant-id", "t", "request-id", "r"))
+	if _, err := mapper.UnaryServerInterceptor()(ctx, nil, info, handler); err != nil {
+		t.Errorf("UnaryServerInterceptor() error = %v", err)
+	}
+}
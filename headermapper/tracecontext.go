@@ -0,0 +1,156 @@
+package headermapper
+
+import (
+	"context"
+	"crypto/rand"
+	"net/http"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+)
+
+// W3C Trace Context header names, which are also their metadata keys
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+// W3CTraceMappings returns incoming mappings forwarding the W3C Trace Context
+// headers to backends; malformed traceparent values are dropped. Configure
+// TraceContext as well to start traces and gateway spans.
+func W3CTraceMappings() []HeaderMapping {
+	return []HeaderMapping{
+		{
+			HTTPHeader:   TraceparentHeader,
+			GRPCMetadata: TraceparentHeader,
+			Direction:    Incoming,
+			Transform:    ValidateTraceparent,
+		},
+		{
+			HTTPHeader:   TracestateHeader,
+			GRPCMetadata: TracestateHeader,
+			Direction:    Incoming,
+		},
+	}
+}
+
+// TraceContextConfig propagates the trace context of requests to backends
+// through an OpenTelemetry propagator
+type TraceContextConfig struct {
+	// Propagator extracts the trace context from request headers and
+	// injects it into metadata; defaults to W3C Trace Context
+	Propagator propagation.TextMapPropagator `json:"-" yaml:"-"`
+	// Generate starts a new sampled trace for requests without one
+	Generate bool `json:"generate" yaml:"generate"`
+	// TracerProvider, when set, starts a span around the mapping of each
+	// request, which becomes the parent of the backend call
+	TracerProvider trace.TracerProvider `json:"-" yaml:"-"`
+	// SpanName names the span; defaults to "headermapper.MapHeaders"
+	SpanName string `json:"span_name,omitempty" yaml:"span_name,omitempty"`
+}
+
+// traceContext applies a TraceContextConfig in MetadataAnnotator
+type traceContext struct {
+	propagator propagation.TextMapPropagator
+	generate   bool
+	tracer     trace.Tracer
+	spanName   string
+}
+
+func newTraceContext(config *TraceContextConfig) *traceContext {
+	tc := &traceContext{
+		propagator: config.Propagator,
+		generate:   config.Generate,
+		spanName:   config.SpanName,
+	}
+	if tc.propagator == nil {
+		tc.propagator = propagation.TraceContext{}
+	}
+	if config.TracerProvider != nil {
+		tc.tracer = config.TracerProvider.Tracer("github.com/bhatti/grpc-header-mapper/headermapper")
+	}
+	if tc.spanName == "" {
+		tc.spanName = "headermapper.MapHeaders"
+	}
+	return tc
+}
+
+// start extracts the trace context of req, starting a trace or span when
+// configured. end finishes the span; it is a no-op without a tracer.
+func (tc *traceContext) start(req *http.Request) (ctx context.Context, end func()) {
+	ctx = tc.propagator.Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+	if !trace.SpanContextFromContext(ctx).IsValid() && tc.generate && tc.tracer == nil {
+		ctx = trace.ContextWithRemoteSpanContext(ctx, newSpanContext())
+	}
+	if tc.tracer == nil {
+		return ctx, func() {}
+	}
+	ctx, span := tc.tracer.Start(ctx, tc.spanName,
+		trace.WithSpanKind(trace.SpanKindServer))
+	return ctx, func() { span.End() }
+}
+
+// inject writes the trace context of ctx into md, replacing the values
+// mapped from the request
+func (tc *traceContext) inject(ctx context.Context, md metadata.MD) {
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		tc.propagator.Inject(ctx, metadataCarrier(md))
+	}
+}
+
+// metadataCarrier adapts metadata to propagation.TextMapCarrier
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if values := metadata.MD(c).Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// newSpanContext returns a random sampled span context starting a trace
+func newSpanContext() trace.SpanContext {
+	var traceID trace.TraceID
+	var spanID trace.SpanID
+	rand.Read(traceID[:])
+	rand.Read(spanID[:])
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+}
+
+// GenerateTraceparent returns a traceparent starting a new sampled trace,
+// for use with WithGenerator
+func GenerateTraceparent() string {
+	carrier := propagation.MapCarrier{}
+	ctx := trace.ContextWithRemoteSpanContext(context.Background(), newSpanContext())
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	return carrier[TraceparentHeader]
+}
+
+// ValidateTraceparent returns value if it is a valid W3C traceparent and an
+// empty string otherwise, so the mapping is skipped
+func ValidateTraceparent(value string) string {
+	ctx := propagation.TraceContext{}.Extract(context.Background(),
+		propagation.MapCarrier{TraceparentHeader: value})
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ""
+	}
+	return value
+}
@@ -0,0 +1,109 @@
+package headermapper
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc/metadata"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestValidateTraceparent(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{testTraceparent, testTraceparent},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", ""},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", ""},
+		{"not-a-traceparent", ""},
+		{GenerateTraceparent(), ""},
+	}
+	// Generated values are random, so compare them to themselves
+	tests[len(tests)-1].expected = tests[len(tests)-1].value
+
+	for _, tt := range tests {
+		if got := ValidateTraceparent(tt.value); got != tt.expected {
+			t.Errorf("ValidateTraceparent(%q) = %q, want %q", tt.value, got, tt.expected)
+		}
+	}
+}
+
+func TestW3CTraceMappings(t *testing.T) {
+	mapper := NewBuilder().AddMappings(W3CTraceMappings()...).Build()
+	annotate := func(traceparent string) metadata.MD {
+		req := httptest.NewRequest("GET", "/v1/echo", nil)
+		req.Header.Set("Traceparent", traceparent)
+		req.Header.Set("Tracestate", "vendor=value")
+		return mapper.MetadataAnnotator()(context.Background(), req)
+	}
+
+	md := annotate(testTraceparent)
+	if got := md.Get(TraceparentHeader); len(got) != 1 || got[0] != testTraceparent {
+		t.Errorf("traceparent = %v, want %s", got, testTraceparent)
+	}
+	if got := md.Get(TracestateHeader); len(got) != 1 || got[0] != "vendor=value" {
+		t.Errorf("tracestate = %v", got)
+	}
+	if got := annotate("garbage").Get(TraceparentHeader); len(got) != 0 {
+		t.Errorf("malformed traceparent mapped: %v", got)
+	}
+}
+
+func TestTraceContext(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	tests := []struct {
+		name        string
+		config      *TraceContextConfig
+		traceparent string
+		wantTrace   bool
+		wantSpan    bool
+	}{
+		{"propagated", &TraceContextConfig{}, testTraceparent, true, false},
+		{"no trace", &TraceContextConfig{}, "", false, false},
+		{"generated", &TraceContextConfig{Generate: true}, "", true, false},
+		{"span", &TraceContextConfig{TracerProvider: provider}, testTraceparent, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper := NewBuilder().PropagateTraceContext(tt.config).Build()
+			req := httptest.NewRequest("GET", "/v1/echo", nil)
+			if tt.traceparent != "" {
+				req.Header.Set("Traceparent", tt.traceparent)
+			}
+			md := mapper.MetadataAnnotator()(context.Background(), req)
+
+			got := md.Get(TraceparentHeader)
+			if (len(got) == 1) != tt.wantTrace {
+				t.Fatalf("traceparent = %v, want trace %v", got, tt.wantTrace)
+			}
+			if !tt.wantTrace {
+				return
+			}
+			if ValidateTraceparent(got[0]) == "" {
+				t.Errorf("invalid traceparent %q", got[0])
+			}
+			if tt.traceparent != "" && got[0][3:35] != tt.traceparent[3:35] {
+				t.Errorf("trace ID of %q not propagated from %q", got[0], tt.traceparent)
+			}
+
+			if !tt.wantSpan {
+				return
+			}
+			spans := recorder.Ended()
+			if len(spans) != 1 || spans[0].Name() != "headermapper.MapHeaders" {
+				t.Fatalf("ended spans = %v", spans)
+			}
+			if spanID := spans[0].SpanContext().SpanID().String(); got[0][36:52] != spanID {
+				t.Errorf("traceparent %q does not carry the gateway span %s", got[0], spanID)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package headermapper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// MetadataSource selects the response metadata an outgoing mapping reads
+type MetadataSource string
+
+const (
+	// SourceHeader reads header metadata (default)
+	SourceHeader MetadataSource = "header"
+	// SourceTrailer reads trailer metadata, such as grpc-status-details-bin
+	SourceTrailer MetadataSource = "trailer"
+	// SourceBoth reads header metadata, then trailer metadata when absent
+	SourceBoth MetadataSource = "both"
+)
+
+// validate checks the source name
+func (s MetadataSource) validate() error {
+	switch s {
+	case "", SourceHeader, SourceTrailer, SourceBoth:
+		return nil
+	}
+	return fmt.Errorf("invalid metadata source %q", s)
+}
+
+// acceptsTrailersKey marks requests whose client accepts HTTP trailers
+type acceptsTrailersKey struct{}
+
+// withTrailers records on req whether its client sent "TE: trailers", so
+// mapped trailer values are sent as HTTP trailers rather than headers
+func withTrailers(req *http.Request) *http.Request {
+	for _, te := range req.Header.Values("TE") {
+		for _, coding := range strings.Split(te, ",") {
+			name, _, _ := strings.Cut(coding, ";")
+			if strings.EqualFold(strings.TrimSpace(name), "trailers") {
+				return req.WithContext(context.WithValue(req.Context(), acceptsTrailersKey{}, true))
+			}
+		}
+	}
+	return req
+}
+
+// trailersAccepted reports whether the client of the request of ctx accepts
+// HTTP trailers; it is only known when Handler wraps the gateway
+func trailersAccepted(ctx context.Context) bool {
+	accepted, _ := ctx.Value(acceptsTrailersKey{}).(bool)
+	return accepted
+}
+
+// setTrailer declares a response trailer and sets its value. The
+// declaration must precede the response headers, so the trailer is sent
+// even when the body is small enough to be given a Content-Length.
+func setTrailer(h http.Header, write headerWrite) {
+	if !containsFold(h.Values("Trailer"), write.header) {
+		h.Add("Trailer", write.header)
+	}
+	key := http.TrailerPrefix + write.header
+	if write.append {
+		h[key] = append(h[key], write.value)
+		return
+	}
+	h[key] = []string{write.value}
+}
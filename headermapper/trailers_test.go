@@ -0,0 +1,118 @@
+package headermapper
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestTrailerMapping(t *testing.T) {
+	mapper := NewBuilder().
+		AddOutgoingMapping("request-id", "X-Request-ID").
+		AddOutgoingMapping("server-timing", "Server-Timing").WithSource(SourceTrailer).
+		AddOutgoingMapping("grpc-status-details-bin", "X-Status-Details").WithSource(SourceTrailer).
+		AddOutgoingMapping("region", "X-Region").WithSource(SourceBoth).
+		AddOutgoingMapping("shard", "X-Shard").WithSource(SourceBoth).
+		Build()
+	if err := mapper.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		headers  metadata.MD
+		trailers metadata.MD
+		want     map[string]string
+	}{
+		{
+			name:     "trailer only",
+			headers:  metadata.Pairs("server-timing", "db;dur=1"),
+			trailers: metadata.Pairs("server-timing", "db;dur=53", "request-id", "from-trailer"),
+			want:     map[string]string{"Server-Timing": "db;dur=53", "X-Request-ID": ""},
+		},
+		{
+			name:     "both prefers headers",
+			headers:  metadata.Pairs("region", "eu"),
+			trailers: metadata.Pairs("region", "us", "shard", "7"),
+			want:     map[string]string{"X-Region": "eu", "X-Shard": "7"},
+		},
+		{
+			name:     "binary trailer",
+			trailers: metadata.Pairs("grpc-status-details-bin", "\x08\x05"),
+			want:     map[string]string{"X-Status-Details": base64.StdEncoding.EncodeToString([]byte("\x08\x05"))},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{
+				HeaderMD:  tt.headers,
+				TrailerMD: tt.trailers,
+			})
+			if err := mapper.ResponseModifier()(ctx, w, nil); err != nil {
+				t.Fatal(err)
+			}
+			for header, want := range tt.want {
+				if got := w.Header().Get(header); got != want {
+					t.Errorf("%s = %q, want %q", header, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestTrailerMapping_HTTPTrailers(t *testing.T) {
+	mapper := NewBuilder().
+		AddOutgoingMapping("server-timing", "Server-Timing").WithSource(SourceTrailer).
+		Build()
+
+	gateway := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx := runtime.NewServerMetadataContext(req.Context(), runtime.ServerMetadata{
+			TrailerMD: metadata.Pairs("server-timing", "db;dur=53"),
+		})
+		if err := mapper.ResponseModifier()(ctx, w, nil); err != nil {
+			t.Error(err)
+		}
+		io.WriteString(w, "{}")
+	})
+	server := httptest.NewServer(mapper.Handler(gateway))
+	defer server.Close()
+
+	for _, te := range []string{"", "trailers"} {
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		if te != "" {
+			req.Header.Set("TE", te)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		header, trailer := resp.Header.Get("Server-Timing"), resp.Trailer.Get("Server-Timing")
+		if te == "" && (header != "db;dur=53" || trailer != "") {
+			t.Errorf("without TE: header %q, trailer %q, want header", header, trailer)
+		}
+		if te != "" && (header != "" || trailer != "db;dur=53") {
+			t.Errorf("with TE: header %q, trailer %q, want trailer", header, trailer)
+		}
+	}
+}
+
+func TestTrailerMapping_Validation(t *testing.T) {
+	err := ValidateConfig(&Config{Mappings: []HeaderMapping{
+		{HTTPHeader: "X-Status", GRPCMetadata: "status", Direction: Outgoing, Source: "footer"},
+	}})
+	if !errors.Is(err, ErrValidationFailed) {
+		t.Errorf("ValidateConfig() error = %v, want ErrValidationFailed", err)
+	}
+}
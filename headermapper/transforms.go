@@ -14,11 +14,13 @@ func Normalize(value string) string {
 	return strings.ToLower(strings.TrimSpace(value))
 }
 
+// versionPattern matches version numbers in user agent strings
+var versionPattern = regexp.MustCompile(`\d+\.\d+(\.\d+)*`)
+
 // SanitizeUserAgent sanitizes user agent strings by removing sensitive information
 func SanitizeUserAgent(value string) string {
 	// Remove version numbers and specific system information
-	re := regexp.MustCompile(`\d+\.\d+(\.\d+)*`)
-	return re.ReplaceAllString(value, "x.x.x")
+	return versionPattern.ReplaceAllString(value, "x.x.x")
 }
 
 // FormatTimestamp formats Unix timestamp to ISO 8601
@@ -52,7 +54,17 @@ func MaskSensitive(showChars int) TransformFunc {
 		if len(value) <= showChars*2 {
 			return strings.Repeat("*", len(value))
 		}
-		return value[:showChars] + strings.Repeat("*", len(value)-showChars*2) + value[len(value)-showChars:]
+
+		scratch := getScratch()
+		defer putScratch(scratch)
+
+		buf := append(scratch.buf, value[:showChars]...)
+		for i := showChars * 2; i < len(value); i++ {
+			buf = append(buf, '*')
+		}
+		buf = append(buf, value[len(value)-showChars:]...)
+		scratch.buf = buf
+		return string(buf)
 	}
 }
 
@@ -66,12 +78,14 @@ func ConditionalTransform(condition func(string) bool, transform TransformFunc)
 	}
 }
 
-// RegexReplace performs regex-based replacement
+// RegexReplace performs regex-based replacement. It panics if pattern does
+// not compile; use CompileRegexReplace for patterns from configuration.
 func RegexReplace(pattern, replacement string) TransformFunc {
-	re := regexp.MustCompile(pattern)
-	return func(value string) string {
-		return re.ReplaceAllString(value, replacement)
+	transform, err := CompileRegexReplace(pattern, replacement)
+	if err != nil {
+		panic(err)
 	}
+	return transform
 }
 
 // Truncate truncates the value to a maximum length
@@ -0,0 +1,115 @@
+package headermapper
+
+import "strings"
+
+// headerTrie is a radix trie over lowercase header name prefixes. Lookups
+// compare case-insensitively without allocating and cost O(len(name))
+// however many prefixes are stored.
+type headerTrie[V any] struct {
+	root trieNode[V]
+}
+
+// trieNode is a node of a headerTrie; label is the lowercase edge leading to it
+type trieNode[V any] struct {
+	label    string
+	children []*trieNode[V]
+	value    V
+	ok       bool
+}
+
+// insert stores value for prefix, replacing any previous value
+func (t *headerTrie[V]) insert(prefix string, value V) {
+	key := strings.ToLower(prefix)
+	n := &t.root
+	for {
+		if key == "" {
+			n.value, n.ok = value, true
+			return
+		}
+
+		i := n.childIndex(key[0])
+		if i < 0 {
+			n.children = append(n.children, &trieNode[V]{label: key, value: value, ok: true})
+			return
+		}
+
+		child := n.children[i]
+		common := commonPrefixLen(child.label, key)
+		if common < len(child.label) {
+			// Split the edge at the end of the common prefix
+			split := &trieNode[V]{label: child.label[:common], children: []*trieNode[V]{child}}
+			child.label = child.label[common:]
+			n.children[i] = split
+			child = split
+		}
+		key = key[common:]
+		n = child
+	}
+}
+
+// longestPrefix returns the value of the longest stored prefix of name
+func (t *headerTrie[V]) longestPrefix(name string) (value V, ok bool) {
+	n := &t.root
+	if n.ok {
+		value, ok = n.value, true
+	}
+	for name != "" {
+		i := n.childIndex(lowerASCII(name[0]))
+		if i < 0 {
+			return value, ok
+		}
+		child := n.children[i]
+		if !hasLowerPrefix(name, child.label) {
+			return value, ok
+		}
+		name = name[len(child.label):]
+		n = child
+		if n.ok {
+			value, ok = n.value, true
+		}
+	}
+	return value, ok
+}
+
+// childIndex returns the index of the child whose label starts with c, or -1
+func (n *trieNode[V]) childIndex(c byte) int {
+	for i, child := range n.children {
+		if child.label[0] == c {
+			return i
+		}
+	}
+	return -1
+}
+
+// commonPrefixLen returns the length of the common prefix of a and b
+func commonPrefixLen(a, b string) int {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
+
+// hasLowerPrefix reports whether s starts with the lowercase prefix,
+// ignoring ASCII case in s
+func hasLowerPrefix(s, prefix string) bool {
+	if len(s) < len(prefix) {
+		return false
+	}
+	for i := 0; i < len(prefix); i++ {
+		if lowerASCII(s[i]) != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// lowerASCII lowercases an ASCII letter
+func lowerASCII(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + ('a' - 'A')
+	}
+	return c
+}
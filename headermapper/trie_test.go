@@ -0,0 +1,63 @@
+package headermapper
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestHeaderTrie_LongestPrefix(t *testing.T) {
+	trie := &headerTrie[string]{}
+	for _, prefix := range []string{"X-Custom-", "X-Custom-Tenant-", "X-Cust", "Authorization", "x-internal-"} {
+		trie.insert(prefix, prefix)
+	}
+
+	tests := []struct {
+		name     string
+		expected string
+		ok       bool
+	}{
+		{"X-Custom-Color", "X-Custom-", true},
+		{"x-custom-tenant-id", "X-Custom-Tenant-", true},
+		{"X-CUSTOMER", "X-Cust", true},
+		{"X-Cus", "", false},
+		{"authorization", "Authorization", true},
+		{"Authorization-Extra", "Authorization", true},
+		{"X-Internal-Route", "x-internal-", true},
+		{"X-Other", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := trie.longestPrefix(tt.name)
+		if got != tt.expected || ok != tt.ok {
+			t.Errorf("longestPrefix(%q) = %q, %v, want %q, %v", tt.name, got, ok, tt.expected, tt.ok)
+		}
+	}
+}
+
+func TestHeaderTrie_Replace(t *testing.T) {
+	trie := &headerTrie[int]{}
+	trie.insert("X-A-", 1)
+	trie.insert("x-a-", 2)
+
+	if got, _ := trie.longestPrefix("X-A-B"); got != 2 {
+		t.Errorf("longestPrefix() = %d, want 2", got)
+	}
+}
+
+func TestHeaderTrie_NoAllocations(t *testing.T) {
+	trie := &headerTrie[struct{}]{}
+	for i := 0; i < 500; i++ {
+		trie.insert(fmt.Sprintf("X-Tenant-%d-", i), struct{}{})
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = trie.longestPrefix("X-TENANT-499-Color")
+	})
+	if allocs != 0 {
+		t.Errorf("longestPrefix() allocations = %v, want 0", allocs)
+	}
+	if _, ok := trie.longestPrefix("X-Tenant-500-Color"); ok {
+		t.Error("longestPrefix() matched a prefix that was not inserted")
+	}
+}
@@ -0,0 +1,163 @@
+package headermapper
+
+import (
+	"mime"
+	"strings"
+)
+
+// APIVersionKey carries the normalized API version of a request to backends
+const APIVersionKey = "api-version"
+
+// APIVersions validates the API version requested through X-API-Version,
+// Accept-Version or versioned Accept media types against the versions a
+// service supports
+type APIVersions struct {
+	supported map[string]bool
+	// fallback is the version of requests not asking for one
+	fallback string
+}
+
+// NewAPIVersions creates a validator for the supported versions, e.g. v1,
+// 2.1 or 2024-06-01; the first is used for requests not asking for a
+// version. Versions that do not parse are ignored.
+func NewAPIVersions(supported ...string) *APIVersions {
+	v := &APIVersions{supported: make(map[string]bool)}
+	for _, version := range supported {
+		normalized := NormalizeAPIVersion(version)
+		if normalized == "" {
+			continue
+		}
+		if v.fallback == "" {
+			v.fallback = normalized
+		}
+		v.supported[normalized] = true
+	}
+	return v
+}
+
+// NormalizeAPIVersion returns the canonical form of a version: numeric
+// versions are prefixed with v and lose trailing zero components, so 2,
+// V2 and v2.0 all become v2, and dates (2024-06-01) are kept. Values that
+// are not versions yield "".
+func NormalizeAPIVersion(value string) string {
+	value = strings.Trim(strings.TrimSpace(value), `"`)
+	if isDateVersion(value) {
+		return value
+	}
+	if len(value) > 0 && (value[0] == 'v' || value[0] == 'V') {
+		value = value[1:]
+	}
+
+	parts := strings.Split(value, ".")
+	for _, part := range parts {
+		if part == "" || strings.Trim(part, "0123456789") != "" {
+			return ""
+		}
+	}
+	for len(parts) > 1 && strings.Trim(parts[len(parts)-1], "0") == "" {
+		parts = parts[:len(parts)-1]
+	}
+	return "v" + strings.Join(parts, ".")
+}
+
+// isDateVersion reports whether value is a YYYY-MM-DD date version
+func isDateVersion(value string) bool {
+	if len(value) != 10 || value[4] != '-' || value[7] != '-' {
+		return false
+	}
+	return strings.Trim(value[:4]+value[5:7]+value[8:], "0123456789") == ""
+}
+
+// Parse returns the normalized version of an X-API-Version or
+// Accept-Version value, or "" if it is not supported
+func (v *APIVersions) Parse(value string) string {
+	if normalized := NormalizeAPIVersion(value); v.supported[normalized] {
+		return normalized
+	}
+	return ""
+}
+
+// ParseAccept returns the normalized version of the first versioned media
+// type of an Accept value, given as a vendor subtype such as
+// application/vnd.foo.v2+json or a version parameter such as
+// application/json; version=2. Accept values without a version yield the
+// default version and unsupported versions yield "".
+func (v *APIVersions) ParseAccept(accept string) string {
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(mediaRange)
+		if err != nil {
+			continue
+		}
+		if version, ok := params["version"]; ok {
+			return v.Parse(version)
+		}
+		if version := mediaTypeVersion(mediaType); version != "" {
+			return v.Parse(version)
+		}
+	}
+	return v.fallback
+}
+
+// mediaTypeVersion extracts the version of a vendor media type such as
+// application/vnd.foo.v2+json or application/vnd.foo-v2.1+json
+func mediaTypeVersion(mediaType string) string {
+	_, subtype, _ := strings.Cut(mediaType, "/")
+	if !strings.HasPrefix(subtype, "vnd.") {
+		return ""
+	}
+	subtype, _, _ = strings.Cut(subtype, "+")
+	for i := 0; i+2 < len(subtype); i++ {
+		if (subtype[i] == '.' || subtype[i] == '-') && subtype[i+1] == 'v' {
+			if version := NormalizeAPIVersion(subtype[i+1:]); version != "" {
+				return version
+			}
+		}
+	}
+	return ""
+}
+
+// Transform returns a TransformFunc normalizing X-API-Version and
+// Accept-Version values and dropping unsupported versions
+func (v *APIVersions) Transform() TransformFunc {
+	return v.Parse
+}
+
+// AcceptTransform returns a TransformFunc replacing an Accept value with
+// the version it requests
+func (v *APIVersions) AcceptTransform() TransformFunc {
+	return v.ParseAccept
+}
+
+// Mappings returns incoming mappings of X-API-Version, Accept-Version and
+// Accept, in that order of precedence, to the normalized version in
+// APIVersionKey, defaulting to the first supported version. A header
+// requesting an unsupported version is ignored, so backends only see
+// supported versions and can branch on them:
+//
+//	version, _ := headermapper.Get(ctx, headermapper.APIVersionKey)
+func (v *APIVersions) Mappings() []HeaderMapping {
+	return []HeaderMapping{
+		{
+			HTTPHeader:     "X-API-Version",
+			GRPCMetadata:   APIVersionKey,
+			Direction:      Incoming,
+			Transform:      v.Transform(),
+			CacheTransform: true,
+		},
+		{
+			HTTPHeader:     "Accept-Version",
+			GRPCMetadata:   APIVersionKey,
+			Direction:      Incoming,
+			Transform:      v.Transform(),
+			CacheTransform: true,
+		},
+		{
+			HTTPHeader:     "Accept",
+			GRPCMetadata:   APIVersionKey,
+			Direction:      Incoming,
+			Transform:      v.AcceptTransform(),
+			DefaultValue:   v.fallback,
+			CacheTransform: true,
+		},
+	}
+}
@@ -0,0 +1,93 @@
+package headermapper
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNormalizeAPIVersion(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"2", "v2"},
+		{"V2", "v2"},
+		{"v2.0", "v2"},
+		{"2.1.0", "v2.1"},
+		{" \"3\" ", "v3"},
+		{"2024-06-01", "2024-06-01"},
+		{"latest", ""},
+		{"v", ""},
+		{"2..1", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := NormalizeAPIVersion(tt.value); got != tt.expected {
+				t.Errorf("NormalizeAPIVersion(%q) = %q, want %q", tt.value, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAPIVersions_ParseAccept(t *testing.T) {
+	versions := NewAPIVersions("v1", "v2", "2.1")
+
+	tests := []struct {
+		name     string
+		accept   string
+		expected string
+	}{
+		{"vendor subtype", "application/vnd.foo.v2+json", "v2"},
+		{"vendor subtype with dash", "application/vnd.foo-v2.1+json", "v2.1"},
+		{"version parameter", "application/json; version=2", "v2"},
+		{"first versioned range", "text/html, application/vnd.foo.v2+json;q=0.9", "v2"},
+		{"unversioned", "application/json", "v1"},
+		{"vendor name starting with v", "application/vnd.vendor+json", "v1"},
+		{"unsupported", "application/vnd.foo.v9+json", ""},
+		{"malformed", ";;", "v1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := versions.ParseAccept(tt.accept); got != tt.expected {
+				t.Errorf("ParseAccept(%q) = %q, want %q", tt.accept, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAPIVersions_Mappings(t *testing.T) {
+	versions := NewAPIVersions("v1", "v2")
+	mapper := NewBuilder().AddMappings(versions.Mappings()...).Build()
+	if err := mapper.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		expected string
+	}{
+		{"X-API-Version", map[string]string{"X-API-Version": "2", "Accept": "application/json"}, "v2"},
+		{"Accept-Version", map[string]string{"Accept-Version": "v2.0"}, "v2"},
+		{"precedence", map[string]string{"X-API-Version": "1", "Accept-Version": "2"}, "v1"},
+		{"Accept", map[string]string{"Accept": "application/vnd.foo.v2+json"}, "v2"},
+		{"unsupported falls back", map[string]string{"X-API-Version": "7", "Accept": "*/*"}, "v1"},
+		{"no headers", nil, "v1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/test", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			md := mapper.MetadataAnnotator()(context.Background(), req)
+			if got := md.Get(APIVersionKey); len(got) != 1 || got[0] != tt.expected {
+				t.Errorf("api-version = %v, want %s", got, tt.expected)
+			}
+		})
+	}
+}
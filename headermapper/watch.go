@@ -0,0 +1,122 @@
+package headermapper
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// WatchConfigFile loads the YAML or JSON configuration at path into the
+// mapper and reloads it whenever the file changes, so matchers and
+// annotators already registered with the gateway pick up the new mappings
+// without a restart. Like the admin endpoint, reloads replace the mappings
+// and mapping options, keeping the transforms and generators set in code
+// for the mappings the file repeats. Invalid revisions, including those
+// changing policies or removing mappings with such transforms, are reported
+// to onError, or logged when it is nil, and the previous configuration stays
+// active.
+//
+// The directory of the file is watched, so edits in place, atomic renames
+// and the symlink swaps of Kubernetes ConfigMap volumes are all detected. The
+// returned function stops watching.
+func (hm *HeaderMapper) WatchConfigFile(path string, onError func(error)) (stop func(), err error) {
+	if onError == nil {
+		onError = func(err error) {
+			hm.log().Error("Config reload failed:", err)
+		}
+	}
+
+	w := &configWatcher{hm: hm, path: path, onError: onError, done: make(chan struct{})}
+	if err := w.reload(); err != nil {
+		return nil, err
+	}
+
+	// Renames replace the file, so its directory is watched rather than the
+	// file itself
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("watch config: %w", err)
+	}
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("watch config: %w", err)
+	}
+
+	go w.run(watcher)
+	var once sync.Once
+	return func() { once.Do(func() { close(w.done) }) }, nil
+}
+
+// configWatcher reloads a configuration file for WatchConfigFile
+type configWatcher struct {
+	hm      *HeaderMapper
+	path    string
+	onError func(error)
+	done    chan struct{}
+
+	// modTime, size and data identify the last revision seen, so events
+	// for other files or touching the file without changing it do not
+	// reload
+	modTime time.Time
+	size    int64
+	data    []byte
+}
+
+func (w *configWatcher) run(watcher *fsnotify.Watcher) {
+	defer watcher.Close()
+	for {
+		select {
+		case <-w.done:
+			return
+		case _, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if err := w.reload(); err != nil {
+				w.onError(err)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			w.onError(fmt.Errorf("watch config: %w", err))
+		}
+	}
+}
+
+// reload applies the file when it changed since the last revision seen
+func (w *configWatcher) reload() error {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return fmt.Errorf("watch config: %w", err)
+	}
+	if info.ModTime().Equal(w.modTime) && info.Size() == w.size {
+		return nil
+	}
+	w.modTime, w.size = info.ModTime(), info.Size()
+
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		return fmt.Errorf("watch config: %w", err)
+	}
+	if w.data != nil && bytes.Equal(data, w.data) {
+		return nil
+	}
+	w.data = data
+
+	config, err := parseConfig(data)
+	if err != nil {
+		return fmt.Errorf("watch config %s: %w", w.path, err)
+	}
+	err = w.hm.modifyConfig(func(active *Config) error { return mergeConfig(active, active, config) })
+	if err != nil {
+		return fmt.Errorf("watch config %s: %w", w.path, err)
+	}
+	w.hm.log().Info("Reloaded config from", w.path)
+	return nil
+}
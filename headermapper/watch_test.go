@@ -0,0 +1,167 @@
+package headermapper
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mapper.yaml")
+	write := func(content string) {
+		t.Helper()
+		// Rename so the watcher never reads a partial file
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`
+mappings:
+  - http_header: "X-User-ID"
+    grpc_metadata: "user-id"
+    direction: incoming
+`)
+
+	mapper := NewHeaderMapper(&Config{})
+	errs := make(chan error, 10)
+	stop, err := mapper.WatchConfigFile(path, func(err error) { errs <- err })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	annotator := mapper.MetadataAnnotator()
+	mapped := func(header, key string) bool {
+		req := httptest.NewRequest("GET", "/v1/echo", nil)
+		req.Header.Set(header, "v")
+		return len(annotator(context.Background(), req).Get(key)) > 0
+	}
+	eventually := func(cond func() bool, what string) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	if !mapped("X-User-ID", "user-id") {
+		t.Fatal("initial configuration not applied")
+	}
+
+	write(`
+mappings:
+  - http_header: "X-Tenant-ID"
+    grpc_metadata: "tenant-id"
+    direction: incoming
+`)
+	eventually(func() bool { return mapped("X-Tenant-ID", "tenant-id") }, "reload")
+	if mapped("X-User-ID", "user-id") {
+		t.Error("previous mapping still applied after reload")
+	}
+
+	write(`mappings: [{http_header: "X User", grpc_metadata: "user"}]`)
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Error("onError called with nil")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("invalid revision not reported")
+	}
+	if !mapped("X-Tenant-ID", "tenant-id") {
+		t.Error("invalid revision replaced the active configuration")
+	}
+}
+
+func TestWatchConfigFile_PolicyChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mapper.yaml")
+	if err := os.WriteFile(path, []byte("mappings: [{http_header: X-User-ID, grpc_metadata: user-id, direction: incoming}]\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	mapper := NewHeaderMapper(&Config{})
+	errs := make(chan error, 10)
+	stop, err := mapper.WatchConfigFile(path, func(err error) { errs <- err })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	// Edited in place rather than renamed
+	if err := os.WriteFile(path, []byte("mappings: [{http_header: X-User-ID, grpc_metadata: user-id, direction: incoming}]\nstrict_mode: true\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errs:
+		if err == nil || !strings.Contains(err.Error(), "strict_mode") {
+			t.Errorf("onError(%v), want the strict_mode change reported", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("policy change not reported")
+	}
+	if mapper.state().config.StrictMode {
+		t.Error("policy change reported as active")
+	}
+}
+
+func TestWatchConfigFile_Missing(t *testing.T) {
+	mapper := NewHeaderMapper(&Config{})
+	if _, err := mapper.WatchConfigFile(filepath.Join(t.TempDir(), "missing.yaml"), nil); err == nil {
+		t.Error("WatchConfigFile() error = nil for a missing file")
+	}
+}
+
+func TestWatchConfigFile_CodeSetFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mapper.yaml")
+	content := `
+mappings:
+  - http_header: "X-User-ID"
+    grpc_metadata: "user-id"
+    direction: incoming
+  - http_header: "X-Tenant-ID"
+    grpc_metadata: "tenant-id"
+    direction: incoming
+rate_limit:
+  key_metadata: ["user-id"]
+  rate: 100
+  burst: 100
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	store := NewMemoryRateLimitStore()
+	mapper := NewBuilder().
+		AddIncomingMapping("X-User-ID", "user-id").WithTransform(ToLower).
+		RateLimit(&RateLimitConfig{KeyMetadata: []string{"user-id"}, Rate: 100, Burst: 100, Store: store}).
+		Build()
+
+	stop, err := mapper.WatchConfigFile(path, nil)
+	if err != nil {
+		t.Fatalf("WatchConfigFile() error = %v", err)
+	}
+	defer stop()
+
+	req := httptest.NewRequest("GET", "/v1/echo", nil)
+	req.Header.Set("X-User-ID", "ALICE")
+	req.Header.Set("X-Tenant-ID", "acme")
+	md := mapper.MetadataAnnotator()(context.Background(), req)
+	if got := md.Get("user-id"); len(got) != 1 || got[0] != "alice" {
+		t.Errorf("user-id = %v, want the code transform applied", got)
+	}
+	if got := md.Get("tenant-id"); len(got) != 1 || got[0] != "acme" {
+		t.Errorf("tenant-id = %v, want the file's mapping", got)
+	}
+	if mapper.state().config.RateLimit.Store != store {
+		t.Error("reload dropped the rate limit store set in code")
+	}
+}